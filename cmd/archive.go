@@ -0,0 +1,136 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/makerdao/vulcanizedb/libraries/shared/retention"
+	"github.com/makerdao/vulcanizedb/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// archiveCmd represents the archive command
+var archiveCmd = &cobra.Command{
+	Use:   "archive",
+	Short: "Archives and prunes processed storage diffs and event logs according to retention policies",
+	Long: `Applies each retention policy in the config file: rows of the policy's table in one of its statuses, at least
+minAge blocks behind the latest synced header, and (optionally) from one of its addresses are written to gzipped JSON
+lines files under the archive directory, and then deleted (action "archive") or have their raw JSON cleared (action
+"stripRaw", event_logs only). Rows are only removed after their batch has been written to disk.
+
+Rows that transformed data still references (e.g. event logs with a row in an event table) are never deleted, since
+deleting them would cascade to the transformed data; use stripRaw to reclaim most of the space taken by such logs.
+Deleted diffs can't be read by get_contract_storage_at, so take storage snapshots (see snapshotStorage) before
+pruning the diffs of contracts whose state you still read. Archived rows can be loaded back with restoreArchive.
+
+Storage diff statuses: transformed, unwatched, noncanonical, abandoned. Unrecognized diffs are still retried, so
+they can't be archived until they're abandoned.
+Event log statuses: transformed, untransformed.
+
+Expects a config file with a retention section:
+
+  [retention]
+      directory = "/var/lib/vulcanizedb/archive"
+      batchSize = 10000
+      policies = ["old_diffs", "old_logs"]
+      [retention.old_diffs]
+          table = "storage_diff"
+          statuses = ["transformed", "unwatched", "noncanonical"]
+          minAge = 100000
+          action = "archive"
+      [retention.old_logs]
+          table = "event_logs"
+          statuses = ["transformed"]
+          minAge = 100000
+          addresses = ["0x..."]
+          action = "stripRaw"
+
+Use: ./vulcanizedb archive --config=<config path>`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		SubCommand = cmd.CalledAs()
+		LogWithCommand = *logrus.WithField("SubCommand", SubCommand)
+		return archive()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(archiveCmd)
+}
+
+func archive() error {
+	directory, directoryErr := getArchiveDirectory()
+	if directoryErr != nil {
+		return fmt.Errorf("SubCommand %v: %w", SubCommand, directoryErr)
+	}
+	policies, policiesErr := getRetentionPolicies()
+	if policiesErr != nil {
+		return fmt.Errorf("SubCommand %v: %w", SubCommand, policiesErr)
+	}
+
+	blockChain := getBlockChain()
+	db := utils.LoadPostgres(databaseConfig, blockChain.Node())
+	pruner := retention.NewPruner(&db, retention.NewFileArchive(directory), viper.GetInt("retention.batchSize"))
+
+	results, pruneErr := pruner.Run(policies)
+	if pruneErr != nil {
+		return fmt.Errorf("SubCommand %v: failed to apply retention policies: %w", SubCommand, pruneErr)
+	}
+	for _, policy := range policies {
+		result := results[policy.Name]
+		LogWithCommand.Infof("Policy %s archived %d rows to %d files, deleted %d and stripped %d", policy.Name,
+			result.Archived, result.Files, result.Deleted, result.Stripped)
+	}
+	return nil
+}
+
+func getArchiveDirectory() (string, error) {
+	directory := viper.GetString("retention.directory")
+	if directory == "" {
+		return "", fmt.Errorf("retention config is missing `directory` value")
+	}
+	return directory, nil
+}
+
+func getRetentionPolicies() ([]retention.Policy, error) {
+	names := viper.GetStringSlice("retention.policies")
+	if len(names) == 0 {
+		return nil, fmt.Errorf("retention config has no `policies`")
+	}
+	var policies []retention.Policy
+	for _, name := range names {
+		key := "retention." + name
+		if !viper.IsSet(key) {
+			return nil, fmt.Errorf("retention config is missing policy: %s", name)
+		}
+		policy := retention.Policy{
+			Name:      name,
+			Table:     viper.GetString(key + ".table"),
+			Statuses:  viper.GetStringSlice(key + ".statuses"),
+			MinAge:    viper.GetInt64(key + ".minAge"),
+			Addresses: viper.GetStringSlice(key + ".addresses"),
+			Action:    viper.GetString(key + ".action"),
+		}
+		if validationErr := policy.Validate(); validationErr != nil {
+			return nil, validationErr
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
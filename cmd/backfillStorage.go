@@ -16,8 +16,11 @@ var (
 	backfillStorageAddressFlag      = "backfill-storage-contract-address"
 	backfillStorageEndBlockFlag     = "backfill-storage-end-block"
 	backfillStorageEndBlockNumber   int64
+	backfillStorageLogBlocksOnly    bool
+	backfillStorageRangeSize        int64
 	backfillStorageStartBlockFlag   = "backfill-storage-start-block"
 	backfillStorageStartBlockNumber int64
+	backfillStorageWorkers          int
 )
 
 // backfillStorageCmd represents the backfillStorage command
@@ -34,7 +37,14 @@ Optional CLI flag is backfill-storage-contract-address (-a) to specify a single
 back-filled (if not necessary for all transformers).
 Before running this command, verify that you have run headerSync and execute for the desired blocks. Headers are
 required for generating queries for storage slots by hash, and execute is required since the identifier for storage
-slots that represent mappings and dynamic arrays depend on data derived from events.`,
+slots that represent mappings and dynamic arrays depend on data derived from events.
+
+Blocks are split into ranges of range-size blocks that are back-filled by workers in parallel. Progress through each
+range is checkpointed in the database, so re-running the command with the same config and blocks resumes where an
+interrupted run stopped. Before starting, the node is checked for state at the starting block - back-filling requires
+an archive node, and fails fast if the node has pruned that state.
+Optional CLI flag log-blocks-only restricts back-filling to blocks where the watched contracts emitted logs. This is
+much faster, but misses storage changed by calls that don't emit logs.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		SubCommand = cmd.CalledAs()
 		LogWithCommand = *logrus.WithField("SubCommand", SubCommand)
@@ -47,6 +57,9 @@ func init() {
 	backfillStorageCmd.Flags().StringVarP(&backfillStorageAddress, backfillStorageAddressFlag, "a", "", "address for which to back-fill storage")
 	backfillStorageCmd.Flags().Int64VarP(&backfillStorageStartBlockNumber, backfillStorageStartBlockFlag, "s", -1, "starting block from which to back-fill storage")
 	backfillStorageCmd.Flags().Int64VarP(&backfillStorageEndBlockNumber, backfillStorageEndBlockFlag, "e", -1, "ending block for back-filling storage")
+	backfillStorageCmd.Flags().IntVarP(&backfillStorageWorkers, "workers", "w", backfill.DefaultWorkers, "number of block ranges to back-fill in parallel")
+	backfillStorageCmd.Flags().Int64VarP(&backfillStorageRangeSize, "range-size", "r", backfill.DefaultRangeSize, "number of blocks in each checkpointed range")
+	backfillStorageCmd.Flags().BoolVar(&backfillStorageLogBlocksOnly, "log-blocks-only", false, "only back-fill blocks where the watched contracts emitted logs")
 }
 
 func backfillStorage() error {
@@ -67,19 +80,22 @@ func backfillStorage() error {
 		return fmt.Errorf("SubCommand %v: no storage transformers found in the given config", SubCommand)
 	}
 
-	var loader backfill.StorageValueLoader
 	if backfillStorageAddress != "" {
 		filteredInitializers, filterErr := filterByAddress(backfillStorageAddress, storageInitializers)
 		if filterErr != nil {
 			return filterErr
 		}
-		loader = backfill.NewStorageValueLoader(blockChain, &db, filteredInitializers, backfillStorageStartBlockNumber, backfillStorageEndBlockNumber)
-	} else {
-		loader = backfill.NewStorageValueLoader(blockChain, &db, storageInitializers, backfillStorageStartBlockNumber, backfillStorageEndBlockNumber)
+		storageInitializers = filteredInitializers
 	}
 
+	loader := backfill.NewParallelStorageLoader(blockChain, &db, storageInitializers, backfill.LoaderOptions{
+		Workers:       backfillStorageWorkers,
+		RangeSize:     backfillStorageRangeSize,
+		LogBlocksOnly: backfillStorageLogBlocksOnly,
+	})
+
 	LogWithCommand.Infof("Back-filling storage for blocks %d-%d", backfillStorageStartBlockNumber, backfillStorageEndBlockNumber)
-	return loader.Run()
+	return loader.Run(backfillStorageStartBlockNumber, backfillStorageEndBlockNumber)
 }
 
 func validateBackfillStorageArgs() error {
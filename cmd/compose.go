@@ -87,7 +87,9 @@ type variable for each transformer in the config. Currently there are watchers
 of event data from an eth node (eth_event) and storage data from an eth node 
 (eth_storage), and a more generic interface for accepting contract_watcher pkg
 based transformers which can perform event watching provided only a contract
-address (eth_contract).
+address (eth_contract). Account transformers (eth_account) export an
+AccountTransformerInitializer and transform the account diffs (balance, nonce,
+code hash and storage root changes) extracted for their contract's address.
 
 Transformers of different types can be ran together in the same command using a 
 single config file or in separate command instances using different config files
@@ -19,6 +19,8 @@ package cmd
 import (
 	"time"
 
+	storage2 "github.com/makerdao/vulcanizedb/libraries/shared/storage"
+	"github.com/makerdao/vulcanizedb/libraries/shared/watcher"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -88,7 +90,9 @@ type variable for each transformer in the config. Currently there are watchers
 of event data from an eth node (eth_event) and storage data from an eth node 
 (eth_storage), and a more generic interface for accepting contract_watcher pkg
 based transformers which can perform event watching provided only a conctract
-address (eth_contract).
+address (eth_contract). Account transformers (eth_account) export an
+AccountTransformerInitializer and transform the account diffs (balance, nonce,
+code hash and storage root changes) extracted for their contract's address.
 
 Transformers of different types can be ran together in the same command using a 
 single config file or in separate command instances using different config files
@@ -118,4 +122,7 @@ func init() {
 	composeAndExecuteCmd.Flags().BoolVarP(&recheckHeadersArg, "recheck-headers", "r", false, "whether to re-check headers for watched events")
 	composeAndExecuteCmd.Flags().DurationVarP(&retryInterval, "retry-interval", "i", 7*time.Second, "interval duration between retries on execution error")
 	composeAndExecuteCmd.Flags().IntVarP(&maxUnexpectedErrors, "max-unexpected-errs", "m", 5, "maximum number of unexpected errors to allow (with retries) before exiting")
+	composeAndExecuteCmd.Flags().IntVar(&maxUnrecognizedAttempts, "max-unrecognized-attempts", storage2.DefaultRetryPolicy.MaxAttempts, "number of times a diff with an unrecognized storage key is processed before it is abandoned, 0 to retry indefinitely")
+	composeAndExecuteCmd.Flags().DurationVar(&unrecognizedDiffBackoff, "unrecognized-diff-backoff", storage2.DefaultRetryPolicy.InitialBackoff, "delay before the first retry of a diff with an unrecognized storage key, doubled on each subsequent retry")
+	composeAndExecuteCmd.Flags().IntVar(&storageWorkers, "storage-workers", watcher.DefaultStorageWorkers, "number of contracts whose storage diffs are transformed concurrently")
 }
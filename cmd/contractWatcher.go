@@ -27,7 +27,11 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var etherscanAPIKey string
+var (
+	etherscanAPIKey            string
+	allowDestructiveMigrations bool
+	untilBlock                 int64
+)
 
 // contractWatcherCmd represents the contractWatcher command
 var contractWatcherCmd = &cobra.Command{
@@ -48,6 +52,8 @@ Requires a .toml config file:
 
   [contract]
     network  = ""
+    abiDirectory = "/path/to/abis"
+    offline = false
     addresses  = [
         "contractAddress1",
         "contractAddress2"
@@ -65,7 +71,62 @@ Requires a .toml config file:
 			"arg1",
 			"arg2"
 		]
+        methods = [
+            "totalSupply",
+            "balanceOf"
+        ]
+        methodInterval = 10
+        maxMethodCalls = 100
+        calls = [
+            "transferOwnership"
+        ]
         startingBlock = 4448566
+        endingBlock = 5000000
+        [contract.contractAddress2.filters]
+            event1 = "arg1 == 0x... AND arg2 >= 1000"
+
+Filters restrict an event's logs to those whose fields match the expression. Fields are
+compared with ==, != and IN (...), numeric fields also with <, <=, > and >=, and conditions
+are combined with AND, OR and parentheses. Conditions on indexed fields are pushed into the
+topics logs are fetched with.
+
+Overloaded events after the first in the abi get their own tables, named after the event and
+the first four bytes of its signature hash (e.g. transfer_e19260af_event). Anonymous events
+are matched by the number of topics and length of data their fields make up, which the
+[contract.<address>.anonymous.<event>] layout narrows with allowed topics and a dataLength.
+The layout must constrain at least one topic, or anonymous events are skipped (or refused,
+if listed in events), since every log of the contract would be fetched for them.
+
+Listed methods are called at each header (or every methodInterval blocks) and their
+results are persisted to cw_<address>.<method>_method tables, keyed by header.
+Methods must be view functions returning a single value; those taking addresses or
+bytes32 values are called with each combination of such values emitted by the contract's
+watched events since the previous poll. Every call is an eth_call to the node, so a method
+is called at most maxMethodCalls times per poll (100 by default; 0 removes the bound), and
+the combinations beyond it are skipped with a warning.
+
+Listed calls are decoded from the input of transactions sent to the contract into
+cw_<address>.<method>_call tables, with the transaction's sender and receipt status.
+The transactions in each block sent to the contract, including reverted ones and those
+without logs, are synced with their receipts to public.transactions and public.receipts first.
+
+Contracts configured without an abi have it looked up in the ABI registry: the
+<address>.json files in abiDirectory (plain ABIs, Hardhat/Foundry artifacts, or Sourcify
+metadata), then the public.contract_abi table (see the importAbi command). ABIs that aren't
+found there are fetched from Etherscan and cached into the registry, unless offline is set.
+
+Proxy contracts (EIP-1967, including beacon proxies, and EIP-897) are detected and
+watched with their implementation's ABI merged into their own, following upgrades.
+
+When a contract's ABI changes, its event and call tables are migrated: columns for new event
+fields and method arguments are added, columns whose type changed are cast to the new type,
+and each distinct ABI is recorded in public.contract_abi_version. Migrations that would drop
+columns of existing tables, or retype columns whose values can't be cast, fail unless
+--allow-destructive-migrations is passed.
+
+Contracts are watched from their startingBlock up to their endingBlock, if any. With
+--until-block, every contract's ending block is capped at the given block, and the
+command exits with a summary once every contract has been processed up to its end.
 
 Optionally, pass --etherscan-api-key (-k) to supply an Etherscan API
 to be used for ABI lookups.
@@ -86,6 +147,10 @@ func contractWatcher() {
 
 	con := config.ContractConfig{}
 	con.PrepConfig()
+	con.AllowDestructiveMigrations = allowDestructiveMigrations
+	if untilBlock != -1 {
+		con.CapEndingBlocks(untilBlock)
+	}
 
 	t := transformer.NewTransformer(con, blockChain, &db)
 
@@ -99,10 +164,28 @@ func contractWatcher() {
 		if err != nil {
 			LogWithCommand.Error("Execution error for transformer: ", t.GetConfig().Name, err)
 		}
+		if untilBlock == -1 {
+			continue
+		}
+		done, doneErr := t.Done()
+		if doneErr != nil {
+			LogWithCommand.Error("Error checking whether transformer is done: ", doneErr)
+			continue
+		}
+		if done {
+			for _, progress := range t.Summary() {
+				LogWithCommand.Infof("Contract %s processed from block %d to %d: checked %d headers, persisted %d logs and %d calls",
+					progress.Address, progress.StartingBlock, progress.EndingBlock, progress.HeadersChecked, progress.LogsPersisted,
+					progress.CallsPersisted)
+			}
+			return
+		}
 	}
 }
 
 func init() {
 	rootCmd.AddCommand(contractWatcherCmd)
 	contractWatcherCmd.Flags().StringVarP(&etherscanAPIKey, "etherscan-api-key", "k", "", "etherscan API key, for ABI lookups")
+	contractWatcherCmd.Flags().Int64Var(&untilBlock, "until-block", -1, "watch contracts up to this block at most, and exit once they have all been processed up to their end")
+	contractWatcherCmd.Flags().BoolVar(&allowDestructiveMigrations, "allow-destructive-migrations", false, "drop event and call table columns that no longer match a contract's ABI, or can't be cast to their new type")
 }
@@ -21,9 +21,11 @@ import (
 	"time"
 
 	"github.com/makerdao/vulcanizedb/libraries/shared/constants"
+	"github.com/makerdao/vulcanizedb/libraries/shared/factories/account"
 	"github.com/makerdao/vulcanizedb/libraries/shared/factories/event"
 	"github.com/makerdao/vulcanizedb/libraries/shared/factories/storage"
 	"github.com/makerdao/vulcanizedb/libraries/shared/logs"
+	storage2 "github.com/makerdao/vulcanizedb/libraries/shared/storage"
 	"github.com/makerdao/vulcanizedb/libraries/shared/transformer"
 	"github.com/makerdao/vulcanizedb/libraries/shared/watcher"
 	"github.com/makerdao/vulcanizedb/pkg/fs"
@@ -78,6 +80,9 @@ func init() {
 	executeCmd.Flags().DurationVarP(&retryInterval, "retry-interval", "i", 7*time.Second, "interval duration between retries on execution error")
 	executeCmd.Flags().IntVarP(&maxUnexpectedErrors, "max-unexpected-errs", "m", 5, "maximum number of unexpected errors to allow (with retries) before exiting")
 	executeCmd.Flags().Int64VarP(&diffBlockFromHeadOfChain, "diff-blocks-from-head", "d", -1, "number of blocks from head of chain to start reprocessing diffs, defaults to -1 so all diffs are processsed")
+	executeCmd.Flags().IntVar(&maxUnrecognizedAttempts, "max-unrecognized-attempts", storage2.DefaultRetryPolicy.MaxAttempts, "number of times a diff with an unrecognized storage key is processed before it is abandoned, 0 to retry indefinitely")
+	executeCmd.Flags().DurationVar(&unrecognizedDiffBackoff, "unrecognized-diff-backoff", storage2.DefaultRetryPolicy.InitialBackoff, "delay before the first retry of a diff with an unrecognized storage key, doubled on each subsequent retry")
+	executeCmd.Flags().IntVar(&storageWorkers, "storage-workers", watcher.DefaultStorageWorkers, "number of contracts whose storage diffs are transformed concurrently")
 }
 
 func executeTransformers() {
@@ -112,11 +117,30 @@ func executeTransformers() {
 		storageHealthCheckMessage := []byte("storage watcher starting\n")
 		statusWriter := fs.NewStatusWriter(healthCheckFile, storageHealthCheckMessage)
 		sw := watcher.NewStorageWatcher(&db, diffBlockFromHeadOfChain, statusWriter)
+		sw.StorageDiffRepository = storage2.NewDiffRepositoryWithRetryPolicy(&db, storage2.RetryPolicy{
+			MaxAttempts:    maxUnrecognizedAttempts,
+			InitialBackoff: unrecognizedDiffBackoff,
+			MaxBackoff:     storage2.DefaultRetryPolicy.MaxBackoff,
+		})
+		sw.Workers = storageWorkers
 		sw.AddTransformers(ethStorageInitializers)
 		wg.Add(1)
 		go watchEthStorage(&sw, &wg)
 	}
 
+	ethAccountInitializers, exportAccountsErr := exportAccountTransformers()
+	if exportAccountsErr != nil {
+		LogWithCommand.Fatalf("SubCommand %v: exporting account transformers failed: %v", SubCommand, exportAccountsErr)
+	}
+	if len(ethAccountInitializers) > 0 {
+		accountHealthCheckMessage := []byte("account watcher starting\n")
+		statusWriter := fs.NewStatusWriter(healthCheckFile, accountHealthCheckMessage)
+		aw := watcher.NewAccountWatcher(&db, statusWriter)
+		aw.AddTransformers(ethAccountInitializers)
+		wg.Add(1)
+		go watchEthAccounts(&aw, &wg)
+	}
+
 	if len(ethContractInitializers) > 0 {
 		gw := watcher.NewContractWatcher(&db, blockChain)
 		gw.AddTransformers(ethContractInitializers)
@@ -130,6 +154,11 @@ type Exporter interface {
 	Export() ([]event.TransformerInitializer, []storage.TransformerInitializer, []transformer.ContractTransformerInitializer)
 }
 
+// AccountExporter is implemented by plugins that export account transformers (eth_account)
+type AccountExporter interface {
+	ExportAccounts() []account.TransformerInitializer
+}
+
 func watchEthEvents(w *watcher.EventWatcher, wg *sync.WaitGroup) {
 	defer wg.Done()
 	// Execute over the EventTransformerInitializer set using the watcher
@@ -156,6 +185,16 @@ func watchEthStorage(w watcher.IStorageWatcher, wg *sync.WaitGroup) {
 	}
 }
 
+func watchEthAccounts(w watcher.IAccountWatcher, wg *sync.WaitGroup) {
+	defer wg.Done()
+	// Execute over the account.TransformerInitializer set using the account watcher
+	LogWithCommand.Info("executing account transformers")
+	err := w.Execute()
+	if err != nil {
+		LogWithCommand.Fatalf("error executing account watcher: %s", err.Error())
+	}
+}
+
 func watchEthContract(w *watcher.ContractWatcher, wg *sync.WaitGroup) {
 	defer wg.Done()
 	// Execute over the ContractTransformerInitializer set using the contract watcher
@@ -1,14 +1,23 @@
 package cmd
 
 import (
+	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/fsnotify/fsnotify"
 	"github.com/makerdao/vulcanizedb/libraries/shared/storage"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/backfill"
 	"github.com/makerdao/vulcanizedb/libraries/shared/storage/fetcher"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
 	"github.com/makerdao/vulcanizedb/libraries/shared/streamer"
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres"
 	"github.com/makerdao/vulcanizedb/pkg/fs"
 	"github.com/makerdao/vulcanizedb/utils"
 	"github.com/sirupsen/logrus"
@@ -20,9 +29,42 @@ import (
 var extractDiffsCmd = &cobra.Command{
 	Use:   "extractDiffs",
 	Short: "Extract storage diffs from a node and write them to postgres",
-	Long: `Reads storage diffs from either a CSV or JSON RPC subscription.
-	Configure which with the STORAGEDIFFS_SOURCE flag. Received diffs are
-	written to public.storage_diff.`,
+	Long: `Reads storage diffs from a CSV file, a JSON RPC subscription on the
+	patched geth client, or by tracing each new block on a node with tracing
+	APIs. Configure which with the STORAGEDIFFS_SOURCE flag (csv, geth or
+	tracer). The tracer source uses debug_traceBlockByHash with the
+	prestateTracer, or trace_replayBlockTransactions if STORAGEDIFFS_TRACER is
+	stateDiff. Received diffs are written to public.storage_diff.
+
+	When reading from a file, the position reached is saved alongside it in
+	<path>.checkpoint so that a restart resumes where it left off, and the file
+	is followed across log rotation. Rows that can't be parsed are appended to
+	<path>.quarantine with their line number instead of stopping ingestion.
+	STORAGEDIFFS_FORMAT sets the file format: parity-csv (the default), csv with
+	a header row, or jsonl. For csv and jsonl, the storageDiffs.columns config
+	maps the fields address, block_hash, block_height, storage_key and
+	storage_value to differently named columns or keys. With the --batch flag,
+	every file in the FILESYSTEM_STORAGEDIFFSPATH directory (gzipped if ending
+	in .gz) is read once, and the command exits when they've been written.
+
+	With the --filter-unwatched flag, diffs are only written for addresses in the
+	contract config or watched by the exporter's storage transformers, rather
+	than being written and later marked unwatched. Changes to the contract
+	addresses in the config file are picked up without a restart.
+
+	Diffs are buffered and written together, once --batch-size have been
+	received or every --flush-interval. Buffered diffs are written before
+	exiting on SIGINT or SIGTERM.
+
+	When reading from geth, the subscription is re-established if it fails. The
+	last block received is saved to public.storage_fetcher_checkpoint once its
+	diffs have been written. Blocks after it that were missed while disconnected
+	or stopped are back-filled with the configured storage transformers if the
+	--backfill-gaps flag is set, retrying while their headers aren't synced yet;
+	otherwise the missed range is logged so that it can be back-filled with
+	backfillStorage. The tracer source resumes after the last block it traced. With the
+	--account-diffs flag, balance, nonce and code hash changes of watched
+	addresses are also written to public.account_diff.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		SubCommand = cmd.CalledAs()
 		LogWithCommand = *logrus.WithField("SubCommand", SubCommand)
@@ -30,8 +72,23 @@ var extractDiffsCmd = &cobra.Command{
 	},
 }
 
+var (
+	backfillGaps         bool
+	extractAccountDiffs  bool
+	batchExtractDiffs    bool
+	filterUnwatchedDiffs bool
+	diffBatchSize        int
+	diffFlushInterval    time.Duration
+)
+
 func init() {
 	rootCmd.AddCommand(extractDiffsCmd)
+	extractDiffsCmd.Flags().BoolVar(&backfillGaps, "backfill-gaps", false, "back-fill storage for blocks missed while the geth subscription was down (requires exporter config)")
+	extractDiffsCmd.Flags().BoolVar(&batchExtractDiffs, "batch", false, "read every diffs file in the storage diffs directory once and exit (file source only)")
+	extractDiffsCmd.Flags().BoolVar(&filterUnwatchedDiffs, "filter-unwatched", false, "skip diffs for addresses that aren't in the contract config or the configured storage transformers")
+	extractDiffsCmd.Flags().IntVar(&diffBatchSize, "batch-size", storage.DefaultDiffBatchSize, "most diffs to buffer before writing them together")
+	extractDiffsCmd.Flags().DurationVar(&diffFlushInterval, "flush-interval", storage.DefaultDiffFlushInterval, "longest to buffer diffs before writing them")
+	extractDiffsCmd.Flags().BoolVar(&extractAccountDiffs, "account-diffs", false, "also extract balance, nonce and code hash changes of watched addresses (geth source only)")
 }
 
 func getContractAddresses() []string {
@@ -55,6 +112,10 @@ func extractDiffs() {
 	msg := []byte("geth storage fetcher connection established\n")
 	gethStatusWriter := fs.NewStatusWriter(healthCheckFile, msg)
 
+	if extractAccountDiffs && storageDiffsSource != "geth" {
+		LogWithCommand.Warnf("account diffs are only extracted from the geth source, not %q", storageDiffsSource)
+	}
+
 	// initialize fetcher
 	var storageFetcher fetcher.IStorageFetcher
 	logrus.Debug("fetching storage diffs from geth")
@@ -65,35 +126,157 @@ func extractDiffs() {
 		filterQuery := createFilterQuery(addressesToWatch)
 		stateDiffStreamer := streamer.NewEthStateChangeStreamer(ethClient, filterQuery)
 		payloadChan := make(chan filters.Payload)
-		storageFetcher = fetcher.NewGethRpcStorageFetcher(&stateDiffStreamer, payloadChan, gethStatusWriter)
+		var gapFiller fetcher.GapFiller
+		if backfillGaps {
+			_, storageInitializers, _, exportTransformersErr := exportTransformers()
+			if exportTransformersErr != nil {
+				LogWithCommand.Fatalf("exporting transformers for back-filling gaps failed: %s", exportTransformersErr.Error())
+			}
+			loader := backfill.NewStorageValueLoader(blockChain, &db, storageInitializers, 0, 0)
+			gapFiller = &loader
+		}
+		storageFetcher = fetcher.NewGethRpcStorageFetcher(&stateDiffStreamer, payloadChan, gethStatusWriter).
+			WithGapRecovery(blockChain, gapFiller, storage.NewFetcherCheckpointRepository(&db, storageDiffsSource))
+		if extractAccountDiffs {
+			go extractGethAccountDiffs(&db, addressesToWatch, gethStatusWriter)
+		}
+	case "tracer":
+		logrus.Infof("Tracing blocks with the %s tracer", storageDiffsTracer)
+		rpcClient, _ := getClients()
+		checkpoints := storage.NewFetcherCheckpointRepository(&db, storageDiffsSource)
+		lastBlock, lastBlockErr := checkpoints.LoadLastBlock()
+		if lastBlockErr != nil {
+			LogWithCommand.Fatalf("getting last traced block failed: %s", lastBlockErr.Error())
+		}
+		startingBlock := int64(0)
+		if lastBlock > 0 {
+			startingBlock = lastBlock + 1
+		}
+		tracerFetcher, tracerErr := fetcher.NewTracerStorageFetcher(rpcClient, blockChain, fetcher.Tracer(storageDiffsTracer),
+			toAddresses(addressesToWatch), gethStatusWriter, startingBlock)
+		if tracerErr != nil {
+			LogWithCommand.Fatalf("creating tracer storage fetcher failed: %s", tracerErr.Error())
+		}
+		storageFetcher = tracerFetcher.WithCheckpoints(checkpoints)
 	default:
-		logrus.Debug("fetching storage diffs from csv")
-		tailer := fs.FileTailer{Path: storageDiffsPath}
-		msg := []byte("csv tail storage fetcher connection established\n")
-		statusWriter := fs.NewStatusWriter(healthCheckFile, msg)
-
-		storageFetcher = fetcher.NewCsvTailStorageFetcher(tailer, statusWriter)
+		storageFetcher = getFileStorageFetcher(healthCheckFile)
 	}
 
 	// extract diffs
 	extractor := storage.NewDiffExtractor(storageFetcher, &db)
-	err := extractor.ExtractDiffs()
+	if filterUnwatchedDiffs {
+		extractor.AddressFilter = newWatchedAddresses(&db)
+	}
+	extractor.BatchSize = diffBatchSize
+	extractor.FlushInterval = diffFlushInterval
+	quit := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		LogWithCommand.Infof("received %s, writing buffered diffs before exiting", sig)
+		close(quit)
+	}()
+	err := extractor.ExtractDiffsUntil(quit)
 	if err != nil {
 		LogWithCommand.Fatalf("extracting diffs failed: %s", err.Error())
 	}
 }
 
+// newWatchedAddresses watches the contract config addresses and the addresses of any configured storage transformers.
+// Contract addresses are re-read when the config file changes.
+func newWatchedAddresses(db *postgres.DB) *storage.WatchedAddresses {
+	var transformerAddresses []common.Address
+	if viper.IsSet("exporter.transformerNames") {
+		_, storageInitializers, _, exportTransformersErr := exportTransformers()
+		if exportTransformersErr != nil {
+			LogWithCommand.Fatalf("exporting transformers for filtering diffs failed: %s", exportTransformersErr.Error())
+		}
+		for _, initializer := range storageInitializers {
+			transformerAddresses = append(transformerAddresses, initializer(db).GetContractAddress())
+		}
+	}
+	watchedAddresses := func() []common.Address {
+		return append(toAddresses(getContractAddresses()), transformerAddresses...)
+	}
+
+	addresses := watchedAddresses()
+	if len(addresses) == 0 {
+		LogWithCommand.Fatal("--filter-unwatched requires contract or storage transformer config")
+	}
+	LogWithCommand.Infof("persisting storage diffs for %d watched addresses", len(addresses))
+	watched := storage.NewWatchedAddresses(addresses)
+	if cfgFile != "" {
+		viper.OnConfigChange(func(event fsnotify.Event) {
+			addresses := watchedAddresses()
+			watched.Set(addresses)
+			LogWithCommand.Infof("%s changed, persisting storage diffs for %d watched addresses", event.Name, len(addresses))
+		})
+		viper.WatchConfig()
+	}
+	return watched
+}
+
+func getFileStorageFetcher(healthCheckFile string) fetcher.IStorageFetcher {
+	format, formatErr := types.NewDiffFormat(storageDiffsFormat,
+		types.DiffFormatConfig{Columns: viper.GetStringMapString("storageDiffs.columns")})
+	if formatErr != nil {
+		LogWithCommand.Fatalf("creating storage diff format failed: %s", formatErr.Error())
+	}
+	quarantine := fs.NewFileQuarantine(filepath.Clean(storageDiffsPath) + ".quarantine")
+
+	if batchExtractDiffs {
+		logrus.Infof("reading %s storage diffs from %s", storageDiffsFormat, storageDiffsPath)
+		return fetcher.NewDirectoryStorageFetcher(storageDiffsPath, format, quarantine)
+	}
+
+	logrus.Debugf("tailing %s storage diffs from %s", storageDiffsFormat, storageDiffsPath)
+	if fs.IsGzipped(storageDiffsPath) {
+		LogWithCommand.Fatalf("gzipped storage diffs can only be read with --batch")
+	}
+	if headered, ok := format.(types.HeaderedDiffFormat); ok {
+		// resuming from a checkpoint skips the header row
+		header, headerErr := fs.ReadFirstLine(storageDiffsPath)
+		if headerErr == nil && header != "" {
+			if setHeaderErr := headered.SetHeader(header); setHeaderErr != nil {
+				LogWithCommand.Fatalf("reading storage diffs header failed: %s", setHeaderErr.Error())
+			}
+		}
+	}
+	tailer := fs.NewRotatingFileTailer(storageDiffsPath)
+	checkpoints := fs.NewFileCheckpointStore(storageDiffsPath + ".checkpoint")
+	msg := []byte("csv tail storage fetcher connection established\n")
+	statusWriter := fs.NewStatusWriter(healthCheckFile, msg)
+	return fetcher.NewFileTailStorageFetcher(format, tailer, checkpoints, quarantine, statusWriter)
+}
+
+func extractGethAccountDiffs(db *postgres.DB, addressesToWatch []string, statusWriter fs.StatusWriter) {
+	_, ethClient := getClients()
+	stateDiffStreamer := streamer.NewEthStateChangeStreamer(ethClient, createFilterQuery(addressesToWatch))
+	payloadChan := make(chan filters.Payload)
+	accountDiffFetcher := fetcher.NewGethRpcAccountDiffFetcher(&stateDiffStreamer, payloadChan, statusWriter,
+		toAddresses(addressesToWatch))
+	extractor := storage.NewAccountDiffExtractor(accountDiffFetcher, db)
+	err := extractor.ExtractAccountDiffs()
+	if err != nil {
+		LogWithCommand.Fatalf("extracting account diffs failed: %s", err.Error())
+	}
+}
+
 func createFilterQuery(watchedAddresses []string) ethereum.FilterQuery {
 	logrus.Infof("Creating a filter query for %d watched addresses", len(watchedAddresses))
 	addressesToLog := strings.Join(watchedAddresses[:], ", ")
 	logrus.Infof("Watched addresses: %s", addressesToLog)
 
-	var addresses []common.Address
-	for _, addressString := range watchedAddresses {
-		addresses = append(addresses, common.HexToAddress(addressString))
+	return ethereum.FilterQuery{
+		Addresses: toAddresses(watchedAddresses),
 	}
+}
 
-	return ethereum.FilterQuery{
-		Addresses: addresses,
+func toAddresses(addressStrings []string) []common.Address {
+	var addresses []common.Address
+	for _, addressString := range addressStrings {
+		addresses = append(addresses, common.HexToAddress(addressString))
 	}
+	return addresses
 }
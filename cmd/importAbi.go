@@ -0,0 +1,78 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/registry"
+	"github.com/makerdao/vulcanizedb/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	importAbiAddress string
+	importAbiFile    string
+)
+
+// importAbiCmd represents the importAbi command
+var importAbiCmd = &cobra.Command{
+	Use:   "importAbi",
+	Short: "Stores a contract's ABI in the ABI registry",
+	Long: `Reads a contract's ABI from a file and stores it in the public.contract_abi table, where the contract
+watcher finds it without fetching it from Etherscan. The file may hold a plain ABI, a Hardhat, Foundry, or Truffle
+artifact, or Sourcify metadata. An ABI already stored for the address is replaced.
+
+Use: ./vulcanizedb importAbi --config=<config path> --address=<contract address> --file=<abi path>`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		SubCommand = cmd.CalledAs()
+		LogWithCommand = *logrus.WithField("SubCommand", SubCommand)
+		return importAbi()
+	},
+}
+
+func init() {
+	importAbiCmd.Flags().StringVarP(&importAbiAddress, "address", "a", "", "address of the contract")
+	importAbiCmd.Flags().StringVarP(&importAbiFile, "file", "f", "", "path of the abi, artifact, or metadata file")
+	rootCmd.AddCommand(importAbiCmd)
+}
+
+func importAbi() error {
+	if !common.IsHexAddress(importAbiAddress) {
+		return fmt.Errorf("SubCommand %v: invalid contract address %q", SubCommand, importAbiAddress)
+	}
+	contents, readErr := ioutil.ReadFile(importAbiFile)
+	if readErr != nil {
+		return fmt.Errorf("SubCommand %v: failed to read abi file: %w", SubCommand, readErr)
+	}
+	abi, extractErr := registry.ExtractAbi(contents)
+	if extractErr != nil {
+		return fmt.Errorf("SubCommand %v: failed to read abi from %s: %w", SubCommand, importAbiFile, extractErr)
+	}
+
+	blockChain := getBlockChain()
+	db := utils.LoadPostgres(databaseConfig, blockChain.Node())
+	putErr := registry.NewDBRegistry(&db).PutAbi(importAbiAddress, abi, registry.SourceImport)
+	if putErr != nil {
+		return fmt.Errorf("SubCommand %v: %w", SubCommand, putErr)
+	}
+	LogWithCommand.Infof("Imported abi for contract %s", importAbiAddress)
+	return nil
+}
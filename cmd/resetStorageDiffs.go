@@ -0,0 +1,90 @@
+// VulcanizeDB
+// Copyright © 2020 elizabethengelman
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage"
+	"github.com/makerdao/vulcanizedb/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	resetStorageDiffsAddress     string
+	resetStorageDiffsEndBlock    int64
+	resetStorageDiffsIDs         []int
+	resetStorageDiffsStartBlock  int64
+	resetStorageDiffsStorageKeys []string
+)
+
+// resetStorageDiffsCmd represents the resetStorageDiffs command
+var resetStorageDiffsCmd = &cobra.Command{
+	Use:   "resetStorageDiffs",
+	Short: "Resets unrecognized and abandoned storage diffs so that they are retried",
+	Long: `Returns unrecognized and abandoned storage diffs to the 'new' status and clears their retry count, so that the
+execute command processes them again immediately. Useful after deploying a keys loader that recognizes new storage keys.
+
+Diffs may be selected by id, contract address, storage key, and/or block range; at least one selector is required.
+
+Use: ./vulcanizedb resetStorageDiffs --address=<contract address> --start-block=<block number> --end-block=<block number>
+     ./vulcanizedb resetStorageDiffs --ids=<diff id>,<diff id>`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		SubCommand = cmd.CalledAs()
+		LogWithCommand = *logrus.WithField("SubCommand", SubCommand)
+
+		count, resetErr := resetStorageDiffs()
+		if resetErr != nil {
+			return fmt.Errorf("SubCommand %v: failed to reset storage diffs: %w", SubCommand, resetErr)
+		}
+
+		LogWithCommand.Infof("Reset %d storage diffs", count)
+		return nil
+	},
+}
+
+func init() {
+	resetStorageDiffsCmd.Flags().IntSliceVar(&resetStorageDiffsIDs, "ids", nil, "ids of the storage diffs to reset")
+	resetStorageDiffsCmd.Flags().StringVarP(&resetStorageDiffsAddress, "address", "a", "", "contract address of the storage diffs to reset")
+	resetStorageDiffsCmd.Flags().StringSliceVarP(&resetStorageDiffsStorageKeys, "storage-keys", "k", nil, "storage keys of the storage diffs to reset")
+	resetStorageDiffsCmd.Flags().Int64VarP(&resetStorageDiffsStartBlock, "start-block", "s", -1, "first block of the storage diffs to reset")
+	resetStorageDiffsCmd.Flags().Int64VarP(&resetStorageDiffsEndBlock, "end-block", "e", -1, "last block of the storage diffs to reset")
+	rootCmd.AddCommand(resetStorageDiffsCmd)
+}
+
+func resetStorageDiffs() (int64, error) {
+	filter := storage.ResetFilter{
+		StartBlock: resetStorageDiffsStartBlock,
+		EndBlock:   resetStorageDiffsEndBlock,
+	}
+	for _, id := range resetStorageDiffsIDs {
+		filter.IDs = append(filter.IDs, int64(id))
+	}
+	if resetStorageDiffsAddress != "" {
+		filter.Address = common.HexToAddress(resetStorageDiffsAddress)
+	}
+	for _, key := range resetStorageDiffsStorageKeys {
+		filter.StorageKeys = append(filter.StorageKeys, common.HexToHash(key))
+	}
+
+	blockChain := getBlockChain()
+	db := utils.LoadPostgres(databaseConfig, blockChain.Node())
+	repo := storage.NewDiffRepository(&db)
+	return repo.ResetDiffs(filter)
+}
@@ -0,0 +1,89 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/makerdao/vulcanizedb/libraries/shared/retention"
+	"github.com/makerdao/vulcanizedb/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	restoreArchiveEndBlock   int64
+	restoreArchiveReplay     bool
+	restoreArchiveStartBlock int64
+	restoreArchiveTable      string
+)
+
+// restoreArchiveCmd represents the restoreArchive command
+var restoreArchiveCmd = &cobra.Command{
+	Use:   "restoreArchive",
+	Short: "Restores archived storage diffs or event logs in a block range",
+	Long: `Loads the rows of a table that the archive command wrote to the archive directory back into the database, for
+blocks between the start and end blocks. Rows keep their original ids; rows that are already present are skipped, and
+event logs that had their raw JSON stripped get it back. Event logs whose header has since been replaced by a reorg
+are not restored. Restored storage diffs don't drop storage snapshots, since snapshots should be taken before pruning.
+
+With --replay, restored rows are marked to be transformed again: storage diffs get status new (and their retries are
+reset), and event logs are marked untransformed, so a running execute picks them up.
+Requires a config file with the retention directory, as for the archive command.
+
+Use: ./vulcanizedb restoreArchive --config=<config path> --table=<storage_diff|event_logs> -s=<start block> -e=<end block> [--replay]`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		SubCommand = cmd.CalledAs()
+		LogWithCommand = *logrus.WithField("SubCommand", SubCommand)
+		return restoreArchive()
+	},
+}
+
+func init() {
+	restoreArchiveCmd.Flags().StringVarP(&restoreArchiveTable, "table", "t", "", "table to restore: storage_diff or event_logs")
+	restoreArchiveCmd.Flags().Int64VarP(&restoreArchiveStartBlock, "start-block", "s", -1, "first block of the rows to restore")
+	restoreArchiveCmd.Flags().Int64VarP(&restoreArchiveEndBlock, "end-block", "e", -1, "last block of the rows to restore")
+	restoreArchiveCmd.Flags().BoolVar(&restoreArchiveReplay, "replay", false, "mark restored rows to be transformed again")
+	rootCmd.AddCommand(restoreArchiveCmd)
+}
+
+func restoreArchive() error {
+	validateStartErr := validateBlockNumberArg(restoreArchiveStartBlock, "start-block")
+	if validateStartErr != nil {
+		return validateStartErr
+	}
+	validateEndErr := validateBlockNumberArg(restoreArchiveEndBlock, "end-block")
+	if validateEndErr != nil {
+		return validateEndErr
+	}
+	directory, directoryErr := getArchiveDirectory()
+	if directoryErr != nil {
+		return fmt.Errorf("SubCommand %v: %w", SubCommand, directoryErr)
+	}
+
+	blockChain := getBlockChain()
+	db := utils.LoadPostgres(databaseConfig, blockChain.Node())
+	restorer := retention.NewRestorer(&db, retention.NewFileArchive(directory))
+
+	restored, restoreErr := restorer.Restore(restoreArchiveTable, restoreArchiveStartBlock, restoreArchiveEndBlock,
+		restoreArchiveReplay)
+	if restoreErr != nil {
+		return fmt.Errorf("SubCommand %v: failed to restore archive: %w", SubCommand, restoreErr)
+	}
+	LogWithCommand.Infof("Restored %d %s rows", restored, restoreArchiveTable)
+	return nil
+}
@@ -26,6 +26,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/makerdao/vulcanizedb/libraries/shared/factories/account"
 	"github.com/makerdao/vulcanizedb/libraries/shared/factories/event"
 	"github.com/makerdao/vulcanizedb/libraries/shared/factories/storage"
 	"github.com/makerdao/vulcanizedb/libraries/shared/transformer"
@@ -48,11 +49,16 @@ var (
 	genConfig                config.Plugin
 	ipc                      string
 	maxUnexpectedErrors      int
+	maxUnrecognizedAttempts  int
 	recheckHeadersArg        bool
 	retryInterval            time.Duration
 	startingBlockNumber      int64
 	storageDiffsPath         string
 	storageDiffsSource       string
+	storageDiffsTracer       string
+	storageDiffsFormat       string
+	storageWorkers           int
+	unrecognizedDiffBackoff  time.Duration
 )
 
 const (
@@ -85,6 +91,8 @@ func setViperConfigs() {
 	ipc = viper.GetString("client.ipcpath")
 	storageDiffsPath = viper.GetString("filesystem.storageDiffsPath")
 	storageDiffsSource = viper.GetString("storageDiffs.source")
+	storageDiffsTracer = viper.GetString("storageDiffs.tracer")
+	storageDiffsFormat = viper.GetString("storageDiffs.format")
 	databaseConfig = config.Database{
 		Name:     viper.GetString("database.name"),
 		Hostname: viper.GetString("database.hostname"),
@@ -121,8 +129,10 @@ func init() {
 	rootCmd.PersistentFlags().String("database-user", "", "database user")
 	rootCmd.PersistentFlags().String("database-password", "", "database password")
 	rootCmd.PersistentFlags().String("client-ipcPath", "", "location of geth.ipc file")
-	rootCmd.PersistentFlags().String("filesystem-storageDiffsPath", "", "location of storage diffs csv file")
-	rootCmd.PersistentFlags().String("storageDiffs-source", "csv", "where to get the state diffs: csv or geth")
+	rootCmd.PersistentFlags().String("filesystem-storageDiffsPath", "", "location of storage diffs file (or directory, with extractDiffs --batch)")
+	rootCmd.PersistentFlags().String("storageDiffs-source", "csv", "where to get the state diffs: csv (a diffs file), geth or tracer")
+	rootCmd.PersistentFlags().String("storageDiffs-format", "parity-csv", "format of storage diffs files: parity-csv, csv (with a header row) or jsonl")
+	rootCmd.PersistentFlags().String("storageDiffs-tracer", "prestate", "tracing API used by the tracer source: prestate (debug_traceBlockByHash) or stateDiff (trace_replayBlockTransactions)")
 	rootCmd.PersistentFlags().String("exporter-name", "exporter", "name of exporter plugin")
 	rootCmd.PersistentFlags().String("log-level", logrus.InfoLevel.String(), "Log level (trace, debug, info, warn, error, fatal, panic")
 
@@ -134,6 +144,8 @@ func init() {
 	viper.BindPFlag("client.ipcPath", rootCmd.PersistentFlags().Lookup("client-ipcPath"))
 	viper.BindPFlag("filesystem.storageDiffsPath", rootCmd.PersistentFlags().Lookup("filesystem-storageDiffsPath"))
 	viper.BindPFlag("storageDiffs.source", rootCmd.PersistentFlags().Lookup("storageDiffs-source"))
+	viper.BindPFlag("storageDiffs.tracer", rootCmd.PersistentFlags().Lookup("storageDiffs-tracer"))
+	viper.BindPFlag("storageDiffs.format", rootCmd.PersistentFlags().Lookup("storageDiffs-format"))
 	viper.BindPFlag("exporter.fileName", rootCmd.PersistentFlags().Lookup("exporter-name"))
 	viper.BindPFlag("log.level", rootCmd.PersistentFlags().Lookup("log-level"))
 }
@@ -204,7 +216,7 @@ func prepConfig() error {
 		}
 		transformerType := config.GetTransformerType(t)
 		if transformerType == config.UnknownTransformerType {
-			return errors.New(`unknown transformer type in exporter config accepted types are "eth_event", "eth_storage"`)
+			return errors.New(`unknown transformer type in exporter config accepted types are "eth_event", "eth_storage", "eth_contract", "eth_account"`)
 		}
 
 		transformers[name] = config.Transformer{
@@ -227,41 +239,66 @@ func prepConfig() error {
 }
 
 func exportTransformers() ([]event.TransformerInitializer, []storage.TransformerInitializer, []transformer.ContractTransformerInitializer, error) {
+	exporter, loadErr := loadExporter()
+	if loadErr != nil {
+		return nil, nil, nil, loadErr
+	}
+
+	// Use the Exporters export method to load the EventTransformerInitializer, StorageTransformerInitializer, and ContractTransformerInitializer sets
+	eventTransformerInitializers, storageTransformerInitializers, contractTransformerInitializers := exporter.Export()
+
+	return eventTransformerInitializers, storageTransformerInitializers, contractTransformerInitializers, nil
+}
+
+// exportAccountTransformers loads the AccountTransformerInitializer set from the plugin
+// Plugins composed before account transformers were supported don't export any
+func exportAccountTransformers() ([]account.TransformerInitializer, error) {
+	exporter, loadErr := loadExporter()
+	if loadErr != nil {
+		return nil, loadErr
+	}
+
+	accountExporter, ok := exporter.(AccountExporter)
+	if !ok {
+		return nil, nil
+	}
+	return accountExporter.ExportAccounts(), nil
+}
+
+// loadExporter links the plugin and returns its Exporter symbol
+func loadExporter() (Exporter, error) {
 	// Build plugin generator config
 	configErr := prepConfig()
 	if configErr != nil {
-		return nil, nil, nil, fmt.Errorf("SubCommand %v: failed to to prepare config: %v", SubCommand, configErr)
+		return nil, fmt.Errorf("SubCommand %v: failed to to prepare config: %v", SubCommand, configErr)
 	}
 
 	// Get the plugin path and load the plugin
 	_, pluginPath, pathErr := genConfig.GetPluginPaths()
 	if pathErr != nil {
-		return nil, nil, nil, fmt.Errorf("SubCommand %v: failed to get plugin paths: %v", SubCommand, pathErr)
+		return nil, fmt.Errorf("SubCommand %v: failed to get plugin paths: %v", SubCommand, pathErr)
 	}
 
 	LogWithCommand.Info("linking plugin ", pluginPath)
 	plug, openErr := plugin.Open(pluginPath)
 	if openErr != nil {
-		return nil, nil, nil, fmt.Errorf("SubCommand %v: linking plugin failed: %v", SubCommand, openErr)
+		return nil, fmt.Errorf("SubCommand %v: linking plugin failed: %v", SubCommand, openErr)
 	}
 
 	// Load the `Exporter` symbol from the plugin
 	LogWithCommand.Info("loading transformers from plugin")
 	symExporter, lookupErr := plug.Lookup("Exporter")
 	if lookupErr != nil {
-		return nil, nil, nil, fmt.Errorf("SubCommand %v: loading Exporter symbol failed: %v", SubCommand, lookupErr)
+		return nil, fmt.Errorf("SubCommand %v: loading Exporter symbol failed: %v", SubCommand, lookupErr)
 	}
 
 	// Assert that the symbol is of type Exporter
 	exporter, ok := symExporter.(Exporter)
 	if !ok {
-		return nil, nil, nil, fmt.Errorf("SubCommand %v: plugged-in symbol not of type Exporter", SubCommand)
+		return nil, fmt.Errorf("SubCommand %v: plugged-in symbol not of type Exporter", SubCommand)
 	}
 
-	// Use the Exporters export method to load the EventTransformerInitializer, StorageTransformerInitializer, and ContractTransformerInitializer sets
-	eventTransformerInitializers, storageTransformerInitializers, contractTransformerInitializers := exporter.Export()
-
-	return eventTransformerInitializers, storageTransformerInitializers, contractTransformerInitializers, nil
+	return exporter, nil
 }
 
 func validateBlockNumberArg(blockNumber int64, argName string) error {
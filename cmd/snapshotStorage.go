@@ -0,0 +1,80 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage"
+	"github.com/makerdao/vulcanizedb/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var snapshotStorageInterval int64
+
+// snapshotStorageCmd represents the snapshotStorage command
+var snapshotStorageCmd = &cobra.Command{
+	Use:   "snapshotStorage",
+	Short: "Snapshots the storage of watched contracts to speed up reading their state at recent blocks",
+	Long: `Saves the storage of each contract with a storage transformer at the latest synced block that's a multiple of the
+interval, unless the contract already has a snapshot there. Reading the storage of a contract at a block (with the
+get_contract_storage_at SQL function or the ContractStateReader Go API) starts from the latest snapshot at or before
+the block, so only later diffs are read.
+
+Snapshots are optional, and are dropped automatically when a diff is back-filled or marked noncanonical at or before
+their block (but not when archived diffs are restored with restoreArchive). Run this command periodically (e.g. from cron) to keep snapshots recent.
+Requires a config file structured the same as it would be for running compose or composeAndExecute.
+
+Use: ./vulcanizedb snapshotStorage --config=<config path> --interval=<blocks between snapshots>`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		SubCommand = cmd.CalledAs()
+		LogWithCommand = *logrus.WithField("SubCommand", SubCommand)
+		return snapshotStorage()
+	},
+}
+
+func init() {
+	snapshotStorageCmd.Flags().Int64VarP(&snapshotStorageInterval, "interval", "i", storage.DefaultSnapshotInterval, "number of blocks between snapshots")
+	rootCmd.AddCommand(snapshotStorageCmd)
+}
+
+func snapshotStorage() error {
+	_, storageInitializers, _, exportTransformersErr := exportTransformers()
+	if exportTransformersErr != nil {
+		return fmt.Errorf("SubCommand %v: exporting transformers failed: %v", SubCommand, exportTransformersErr)
+	}
+	if len(storageInitializers) == 0 {
+		return fmt.Errorf("SubCommand %v: no storage transformers found in the given config", SubCommand)
+	}
+	var addresses []common.Address
+	for _, initializer := range storageInitializers {
+		addresses = append(addresses, initializer(nil).GetContractAddress())
+	}
+
+	blockChain := getBlockChain()
+	db := utils.LoadPostgres(databaseConfig, blockChain.Node())
+	snapshotter := storage.NewSnapshotter(&db, snapshotStorageInterval)
+
+	created, snapshotErr := snapshotter.SnapshotLatest(addresses)
+	if snapshotErr != nil {
+		return fmt.Errorf("SubCommand %v: failed to snapshot storage: %w", SubCommand, snapshotErr)
+	}
+	LogWithCommand.Infof("Created %d storage snapshots", created)
+	return nil
+}
@@ -0,0 +1,97 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/proof"
+	"github.com/makerdao/vulcanizedb/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	verifyStorageEndBlock       int64
+	verifyStorageFlagMismatches bool
+	verifyStorageSampleSize     int
+	verifyStorageStartBlock     int64
+)
+
+// verifyStorageCmd represents the verifyStorage command
+var verifyStorageCmd = &cobra.Command{
+	Use:   "verifyStorage",
+	Short: "Verifies persisted storage diffs against Merkle proofs of chain state",
+	Long: `Checks that the values in storage_diff match chain state. For each storage slot changed at each block in the
+range, fetches a proof of the slot with eth_getProof, verifies the proof against the state root of the stored header
+for that block, and compares the proven value with the latest diff to the slot at that block.
+
+Every diff in the range is checked unless a sample size is given, in which case that many are checked at random.
+Mismatches are logged, and can be flagged by setting storage_diff.proof_mismatch. Diffs are skipped if the stored
+header isn't the block they came from, or if the node's proof doesn't verify against the stored state root. Requires a
+node that serves eth_getProof for historical blocks - usually an archive node.
+
+Use: ./vulcanizedb verifyStorage --start-block=<block number> --end-block=<block number> [--sample=<count>] [--flag-mismatches]`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		SubCommand = cmd.CalledAs()
+		LogWithCommand = *logrus.WithField("SubCommand", SubCommand)
+		return verifyStorage()
+	},
+}
+
+func init() {
+	verifyStorageCmd.Flags().Int64VarP(&verifyStorageStartBlock, "start-block", "s", -1, "first block of the storage diffs to verify")
+	verifyStorageCmd.Flags().Int64VarP(&verifyStorageEndBlock, "end-block", "e", -1, "last block of the storage diffs to verify")
+	verifyStorageCmd.Flags().IntVar(&verifyStorageSampleSize, "sample", 0, "number of storage diffs to verify at random (0 verifies all of them)")
+	verifyStorageCmd.Flags().BoolVar(&verifyStorageFlagMismatches, "flag-mismatches", false, "flag mismatched storage diffs in storage_diff.proof_mismatch")
+	rootCmd.AddCommand(verifyStorageCmd)
+}
+
+func verifyStorage() error {
+	validateStartErr := validateBlockNumberArg(verifyStorageStartBlock, "start-block")
+	if validateStartErr != nil {
+		return validateStartErr
+	}
+	validateEndErr := validateBlockNumberArg(verifyStorageEndBlock, "end-block")
+	if validateEndErr != nil {
+		return validateEndErr
+	}
+
+	blockChain := getBlockChain()
+	db := utils.LoadPostgres(databaseConfig, blockChain.Node())
+	verifier := proof.NewVerifier(blockChain, &db)
+
+	result, verifyErr := verifier.Verify(verifyStorageStartBlock, verifyStorageEndBlock, proof.Options{
+		SampleSize:     verifyStorageSampleSize,
+		FlagMismatches: verifyStorageFlagMismatches,
+	})
+	if verifyErr != nil {
+		return fmt.Errorf("SubCommand %v: failed to verify storage diffs: %w", SubCommand, verifyErr)
+	}
+
+	for _, mismatch := range result.Mismatches {
+		LogWithCommand.Errorf("storage diff %d for %s key %s at block %d has value %s but the proven value is %s",
+			mismatch.Diff.ID, mismatch.Diff.Address.Hex(), mismatch.Diff.StorageKey.Hex(), mismatch.Diff.BlockHeight,
+			mismatch.Diff.StorageValue.Hex(), mismatch.ProvenValue.Hex())
+	}
+	LogWithCommand.Infof("Verified %d storage diffs, found %d mismatches, skipped %d", result.Verified,
+		len(result.Mismatches), result.Skipped)
+	if len(result.Mismatches) > 0 {
+		return fmt.Errorf("SubCommand %v: %d storage diffs don't match chain state", SubCommand, len(result.Mismatches))
+	}
+	return nil
+}
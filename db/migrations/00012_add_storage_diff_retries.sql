@@ -0,0 +1,18 @@
+-- +goose NO TRANSACTION
+-- +goose Up
+ALTER TYPE public.diff_status ADD VALUE IF NOT EXISTS 'abandoned';
+
+ALTER TABLE public.storage_diff
+    ADD COLUMN retry_count     INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN next_attempt_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX storage_diff_unrecognized_next_attempt_index
+    ON public.storage_diff (next_attempt_at) WHERE status = 'unrecognized';
+
+-- +goose Down
+-- enum values can't be dropped, so abandoned diffs are returned to unrecognized instead
+UPDATE public.storage_diff SET status = 'unrecognized' WHERE status = 'abandoned';
+DROP INDEX public.storage_diff_unrecognized_next_attempt_index;
+ALTER TABLE public.storage_diff
+    DROP COLUMN retry_count,
+    DROP COLUMN next_attempt_at;
@@ -0,0 +1,11 @@
+-- +goose NO TRANSACTION
+-- +goose Up
+ALTER TYPE public.diff_status ADD VALUE IF NOT EXISTS 'pending_header';
+
+CREATE INDEX storage_diff_pending_header_index
+    ON public.storage_diff (address, storage_key, block_height) WHERE status = 'pending_header';
+
+-- +goose Down
+-- enum values can't be dropped, so pending diffs are returned to new instead
+UPDATE public.storage_diff SET status = 'new' WHERE status = 'pending_header';
+DROP INDEX public.storage_diff_pending_header_index;
@@ -0,0 +1,6 @@
+-- +goose Up
+CREATE INDEX storage_diff_address_block_height_index
+    ON public.storage_diff (address, block_height, id) WHERE status IN ('new', 'unrecognized');
+
+-- +goose Down
+DROP INDEX public.storage_diff_address_block_height_index;
@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE public.account_diff
+(
+    id           BIGSERIAL PRIMARY KEY,
+    address      BYTEA,
+    block_height BIGINT,
+    block_hash   BYTEA,
+    balance      NUMERIC,
+    nonce        BIGINT,
+    code_hash    BYTEA,
+    storage_root BYTEA,
+    eth_node_id  INTEGER     NOT NULL REFERENCES public.eth_nodes (id) ON DELETE CASCADE,
+    status       diff_status NOT NULL DEFAULT 'new',
+    UNIQUE (block_height, block_hash, address, balance, nonce, code_hash, storage_root)
+);
+
+CREATE INDEX account_diff_new_status_index
+    ON public.account_diff (status) WHERE status = 'new';
+CREATE INDEX account_diff_eth_node
+    ON public.account_diff (eth_node_id);
+
+-- +goose Down
+DROP TABLE public.account_diff;
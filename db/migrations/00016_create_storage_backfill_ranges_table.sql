@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE public.storage_backfill_range
+(
+    id             SERIAL PRIMARY KEY,
+    job            TEXT   NOT NULL,
+    starting_block BIGINT NOT NULL,
+    ending_block   BIGINT NOT NULL,
+    last_block     BIGINT,
+    UNIQUE (job, starting_block, ending_block)
+);
+
+-- +goose Down
+DROP TABLE public.storage_backfill_range;
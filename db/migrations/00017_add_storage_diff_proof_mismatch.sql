@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE public.storage_diff
+    ADD COLUMN proof_mismatch BOOLEAN NOT NULL DEFAULT false;
+
+CREATE INDEX storage_diff_proof_mismatch_index
+    ON public.storage_diff (id) WHERE proof_mismatch;
+
+-- +goose Down
+DROP INDEX public.storage_diff_proof_mismatch_index;
+ALTER TABLE public.storage_diff
+    DROP COLUMN proof_mismatch;
@@ -0,0 +1,186 @@
+-- +goose Up
+CREATE TABLE public.storage_snapshot
+(
+    id           SERIAL PRIMARY KEY,
+    address      BYTEA     NOT NULL,
+    block_height BIGINT    NOT NULL,
+    created      TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (address, block_height)
+);
+
+CREATE TABLE public.storage_snapshot_value
+(
+    snapshot_id   INTEGER NOT NULL REFERENCES public.storage_snapshot (id) ON DELETE CASCADE,
+    storage_key   BYTEA   NOT NULL,
+    storage_value BYTEA   NOT NULL,
+    PRIMARY KEY (snapshot_id, storage_key)
+);
+
+CREATE INDEX storage_diff_address_storage_key_block_height_index
+    ON public.storage_diff (address, storage_key, block_height);
+
+-- +goose StatementBegin
+-- get_storage_at returns the value of a storage slot at the end of a block, or null if it was never written. Only diffs
+-- from the stored header at each height are read.
+CREATE OR REPLACE FUNCTION public.get_storage_at(address BYTEA, storage_key BYTEA, block_height BIGINT) RETURNS BYTEA AS
+$$
+SELECT storage_diff.storage_value
+FROM public.storage_diff
+         JOIN public.headers ON headers.block_number = storage_diff.block_height
+    AND headers.hash = '0x' || encode(storage_diff.block_hash, 'hex')
+WHERE storage_diff.address = get_storage_at.address
+  AND storage_diff.storage_key = get_storage_at.storage_key
+  AND storage_diff.block_height <= get_storage_at.block_height
+  AND storage_diff.status != 'noncanonical'
+ORDER BY storage_diff.block_height DESC, storage_diff.id DESC
+LIMIT 1
+$$
+    LANGUAGE sql STABLE;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- get_contract_storage_at returns every known storage slot of a contract at the end of a block, starting from the
+-- latest snapshot at or before the block. Only diffs from the stored header at each height are read.
+CREATE OR REPLACE FUNCTION public.get_contract_storage_at(address BYTEA, block_height BIGINT)
+    RETURNS TABLE
+            (
+                storage_key   BYTEA,
+                storage_value BYTEA
+            )
+AS
+$$
+WITH snapshot AS (
+    SELECT storage_snapshot.id, storage_snapshot.block_height
+    FROM public.storage_snapshot
+    WHERE storage_snapshot.address = get_contract_storage_at.address
+      AND storage_snapshot.block_height <= get_contract_storage_at.block_height
+    ORDER BY storage_snapshot.block_height DESC
+    LIMIT 1
+),
+     recent AS (
+         SELECT DISTINCT ON (storage_diff.storage_key) storage_diff.storage_key, storage_diff.storage_value
+         FROM public.storage_diff
+                  JOIN public.headers ON headers.block_number = storage_diff.block_height
+             AND headers.hash = '0x' || encode(storage_diff.block_hash, 'hex')
+         WHERE storage_diff.address = get_contract_storage_at.address
+           AND storage_diff.block_height <= get_contract_storage_at.block_height
+           AND storage_diff.block_height > COALESCE((SELECT snapshot.block_height FROM snapshot), -1)
+           AND storage_diff.status != 'noncanonical'
+         ORDER BY storage_diff.storage_key, storage_diff.block_height DESC, storage_diff.id DESC
+     )
+SELECT recent.storage_key, recent.storage_value
+FROM recent
+UNION ALL
+SELECT snapshot_value.storage_key, snapshot_value.storage_value
+FROM public.storage_snapshot_value AS snapshot_value
+WHERE snapshot_value.snapshot_id = (SELECT snapshot.id FROM snapshot)
+  AND NOT EXISTS(SELECT 1 FROM recent WHERE recent.storage_key = snapshot_value.storage_key)
+$$
+    LANGUAGE sql STABLE;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- create_storage_snapshot replaces any snapshot of a contract at a block with its current known storage
+CREATE OR REPLACE FUNCTION public.create_storage_snapshot(address BYTEA, block_height BIGINT) RETURNS INTEGER AS
+$$
+DECLARE
+    new_snapshot_id INTEGER;
+BEGIN
+    DELETE
+    FROM public.storage_snapshot
+    WHERE storage_snapshot.address = create_storage_snapshot.address
+      AND storage_snapshot.block_height = create_storage_snapshot.block_height;
+
+    -- storage is read in the same statement that creates the snapshot, so it doesn't see the new (empty) snapshot
+    WITH state AS (
+        SELECT * FROM public.get_contract_storage_at(create_storage_snapshot.address, create_storage_snapshot.block_height)
+    ),
+         snapshot AS (
+             INSERT INTO public.storage_snapshot (address, block_height)
+                 VALUES (create_storage_snapshot.address, create_storage_snapshot.block_height)
+                 RETURNING id
+         ),
+         snapshot_values AS (
+             INSERT INTO public.storage_snapshot_value (snapshot_id, storage_key, storage_value)
+                 SELECT snapshot.id, state.storage_key, state.storage_value
+                 FROM snapshot,
+                      state
+         )
+    SELECT snapshot.id
+    INTO new_snapshot_id
+    FROM snapshot;
+
+    RETURN new_snapshot_id;
+END
+$$
+    LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- invalidate_storage_snapshots drops snapshots that a noncanonical diff has made stale
+CREATE OR REPLACE FUNCTION public.invalidate_storage_snapshots() RETURNS TRIGGER AS
+$$
+BEGIN
+    DELETE
+    FROM public.storage_snapshot
+    WHERE storage_snapshot.address = NEW.address
+      AND storage_snapshot.block_height >= NEW.block_height;
+    RETURN NULL;
+END
+$$
+    LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- invalidate_storage_snapshots_after_insert drops snapshots that diffs inserted at or before their block have made
+-- stale, deleting once per statement from the lowest inserted block of each address
+CREATE OR REPLACE FUNCTION public.invalidate_storage_snapshots_after_insert() RETURNS TRIGGER AS
+$$
+BEGIN
+    DELETE
+    FROM public.storage_snapshot
+        USING (
+            SELECT inserted_diffs.address, MIN(inserted_diffs.block_height) AS block_height
+            FROM inserted_diffs
+            GROUP BY inserted_diffs.address
+        ) AS inserted
+    WHERE storage_snapshot.address = inserted.address
+      AND storage_snapshot.block_height >= inserted.block_height;
+    RETURN NULL;
+END
+$$
+    LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER storage_snapshot_invalidated_by_insert
+    AFTER INSERT
+    ON public.storage_diff
+    REFERENCING NEW TABLE AS inserted_diffs
+    FOR EACH STATEMENT
+EXECUTE PROCEDURE public.invalidate_storage_snapshots_after_insert();
+
+CREATE TRIGGER storage_snapshot_invalidated_by_noncanonical
+    AFTER UPDATE OF status
+    ON public.storage_diff
+    FOR EACH ROW
+    WHEN (NEW.status = 'noncanonical' AND OLD.status != 'noncanonical')
+EXECUTE PROCEDURE public.invalidate_storage_snapshots();
+
+COMMENT ON FUNCTION public.create_storage_snapshot(address BYTEA, block_height BIGINT)
+    IS E'@omit';
+COMMENT ON FUNCTION public.invalidate_storage_snapshots()
+    IS E'@omit';
+COMMENT ON FUNCTION public.invalidate_storage_snapshots_after_insert()
+    IS E'@omit';
+
+-- +goose Down
+DROP TRIGGER storage_snapshot_invalidated_by_noncanonical ON public.storage_diff;
+DROP TRIGGER storage_snapshot_invalidated_by_insert ON public.storage_diff;
+DROP FUNCTION public.invalidate_storage_snapshots_after_insert();
+DROP FUNCTION public.invalidate_storage_snapshots();
+DROP FUNCTION public.create_storage_snapshot(address BYTEA, block_height BIGINT);
+DROP FUNCTION public.get_contract_storage_at(address BYTEA, block_height BIGINT);
+DROP FUNCTION public.get_storage_at(address BYTEA, storage_key BYTEA, block_height BIGINT);
+DROP INDEX public.storage_diff_address_storage_key_block_height_index;
+DROP TABLE public.storage_snapshot_value;
+DROP TABLE public.storage_snapshot;
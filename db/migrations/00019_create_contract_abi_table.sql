@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE public.contract_abi
+(
+    address VARCHAR(42) PRIMARY KEY,
+    abi     TEXT      NOT NULL,
+    source  TEXT      NOT NULL,
+    created TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- +goose Down
+DROP TABLE public.contract_abi;
@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE public.contract_abi_version
+(
+    id      SERIAL PRIMARY KEY,
+    address VARCHAR(42) NOT NULL,
+    version INTEGER     NOT NULL,
+    abi     TEXT        NOT NULL,
+    created TIMESTAMP   NOT NULL DEFAULT NOW(),
+    UNIQUE (address, version)
+);
+
+-- +goose Down
+DROP TABLE public.contract_abi_version;
@@ -0,0 +1,91 @@
+-- +goose Up
+CREATE TABLE public.checked_events
+(
+    id   SERIAL PRIMARY KEY,
+    name TEXT UNIQUE NOT NULL
+);
+
+ALTER TABLE public.checked_headers
+    RENAME TO checked_headers_by_column;
+ALTER TABLE public.checked_headers_by_column
+    RENAME CONSTRAINT checked_headers_pkey TO checked_headers_by_column_pkey;
+ALTER TABLE public.checked_headers_by_column
+    RENAME CONSTRAINT checked_headers_header_id_key TO checked_headers_by_column_header_id_key;
+ALTER TABLE public.checked_headers_by_column
+    RENAME CONSTRAINT checked_headers_header_id_fkey TO checked_headers_by_column_header_id_fkey;
+
+CREATE TABLE public.checked_headers
+(
+    header_id   INTEGER NOT NULL REFERENCES public.headers (id) ON DELETE CASCADE,
+    event_id    INTEGER NOT NULL REFERENCES public.checked_events (id) ON DELETE CASCADE,
+    check_count INTEGER NOT NULL DEFAULT 1,
+    PRIMARY KEY (header_id, event_id)
+);
+
+CREATE INDEX checked_headers_event_id_index
+    ON public.checked_headers (event_id);
+
+-- +goose StatementBegin
+DO
+$$
+    DECLARE
+        check_column TEXT;
+    BEGIN
+        FOR check_column IN
+            SELECT column_name
+            FROM information_schema.columns
+            WHERE table_schema = 'public'
+              AND table_name = 'checked_headers_by_column'
+              AND column_name NOT IN ('id', 'header_id')
+            LOOP
+                INSERT INTO public.checked_events (name) VALUES (check_column);
+                EXECUTE format('INSERT INTO public.checked_headers (header_id, event_id, check_count)
+                                SELECT header_id, (SELECT id FROM public.checked_events WHERE name = %L), %I
+                                FROM public.checked_headers_by_column
+                                WHERE %I > 0', check_column, check_column, check_column);
+            END LOOP;
+    END
+$$;
+-- +goose StatementEnd
+
+DROP TABLE public.checked_headers_by_column;
+
+-- +goose Down
+ALTER TABLE public.checked_headers
+    RENAME TO checked_headers_by_event;
+ALTER TABLE public.checked_headers_by_event
+    RENAME CONSTRAINT checked_headers_pkey TO checked_headers_by_event_pkey;
+ALTER TABLE public.checked_headers_by_event
+    RENAME CONSTRAINT checked_headers_header_id_fkey TO checked_headers_by_event_header_id_fkey;
+
+CREATE TABLE public.checked_headers
+(
+    id        SERIAL PRIMARY KEY,
+    header_id INTEGER UNIQUE NOT NULL REFERENCES headers (id) ON DELETE CASCADE
+);
+
+INSERT INTO public.checked_headers (header_id)
+SELECT DISTINCT header_id
+FROM public.checked_headers_by_event;
+
+-- +goose StatementBegin
+DO
+$$
+    DECLARE
+        checked_event RECORD;
+    BEGIN
+        FOR checked_event IN SELECT id, name FROM public.checked_events
+            LOOP
+                EXECUTE format('ALTER TABLE public.checked_headers ADD COLUMN %I INTEGER NOT NULL DEFAULT 0',
+                               checked_event.name);
+                EXECUTE format('UPDATE public.checked_headers SET %I = checks.check_count
+                                FROM public.checked_headers_by_event AS checks
+                                WHERE checks.header_id = checked_headers.header_id
+                                  AND checks.event_id = %s', checked_event.name, checked_event.id);
+            END LOOP;
+    END
+$$;
+-- +goose StatementEnd
+
+DROP TABLE public.checked_headers_by_event;
+DROP TABLE public.checked_events;
@@ -0,0 +1,46 @@
+-- +goose Up
+-- +goose StatementBegin
+-- invalidate_storage_snapshots_after_insert drops snapshots that diffs inserted at or before their block have made
+-- stale, deleting once per statement from the lowest inserted block of each address. Restoring archived diffs skips it.
+CREATE OR REPLACE FUNCTION public.invalidate_storage_snapshots_after_insert() RETURNS TRIGGER AS
+$$
+BEGIN
+    -- diffs restored from an archive were pruned after the snapshots that include them were taken
+    IF current_setting('vulcanize.restoring_archive', true) = 'on' THEN
+        RETURN NULL;
+    END IF;
+
+    DELETE
+    FROM public.storage_snapshot
+        USING (
+            SELECT inserted_diffs.address, MIN(inserted_diffs.block_height) AS block_height
+            FROM inserted_diffs
+            GROUP BY inserted_diffs.address
+        ) AS inserted
+    WHERE storage_snapshot.address = inserted.address
+      AND storage_snapshot.block_height >= inserted.block_height;
+    RETURN NULL;
+END
+$$
+    LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION public.invalidate_storage_snapshots_after_insert() RETURNS TRIGGER AS
+$$
+BEGIN
+    DELETE
+    FROM public.storage_snapshot
+        USING (
+            SELECT inserted_diffs.address, MIN(inserted_diffs.block_height) AS block_height
+            FROM inserted_diffs
+            GROUP BY inserted_diffs.address
+        ) AS inserted
+    WHERE storage_snapshot.address = inserted.address
+      AND storage_snapshot.block_height >= inserted.block_height;
+    RETURN NULL;
+END
+$$
+    LANGUAGE plpgsql;
+-- +goose StatementEnd
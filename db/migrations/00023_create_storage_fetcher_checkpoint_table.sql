@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE TABLE public.storage_fetcher_checkpoint
+(
+    source       TEXT PRIMARY KEY,
+    block_height BIGINT NOT NULL
+);
+
+-- +goose Down
+DROP TABLE public.storage_fetcher_checkpoint;
@@ -0,0 +1,6 @@
+-- +goose Up
+CREATE INDEX account_diff_pending_header_index
+    ON public.account_diff (address, block_height) WHERE status = 'pending_header';
+
+-- +goose Down
+DROP INDEX public.account_diff_pending_header_index;
@@ -25,7 +25,9 @@ CREATE TYPE public.diff_status AS ENUM (
     'transformed',
     'unrecognized',
     'noncanonical',
-    'unwatched'
+    'unwatched',
+    'abandoned',
+    'pending_header'
 );
 
 
@@ -77,6 +79,88 @@ $$;
 COMMENT ON FUNCTION public.create_back_filled_diff(block_height bigint, block_hash bytea, address bytea, storage_key bytea, storage_value bytea, eth_node_id integer) IS '@omit';
 
 
+--
+-- Name: create_storage_snapshot(bytea, bigint); Type: FUNCTION; Schema: public; Owner: -
+--
+
+CREATE FUNCTION public.create_storage_snapshot(address bytea, block_height bigint) RETURNS integer
+    LANGUAGE plpgsql
+    AS $$
+DECLARE
+    new_snapshot_id INTEGER;
+BEGIN
+    DELETE
+    FROM public.storage_snapshot
+    WHERE storage_snapshot.address = create_storage_snapshot.address
+      AND storage_snapshot.block_height = create_storage_snapshot.block_height;
+
+    -- storage is read in the same statement that creates the snapshot, so it doesn't see the new (empty) snapshot
+    WITH state AS (
+        SELECT * FROM public.get_contract_storage_at(create_storage_snapshot.address, create_storage_snapshot.block_height)
+    ),
+         snapshot AS (
+             INSERT INTO public.storage_snapshot (address, block_height)
+                 VALUES (create_storage_snapshot.address, create_storage_snapshot.block_height)
+                 RETURNING id
+         ),
+         snapshot_values AS (
+             INSERT INTO public.storage_snapshot_value (snapshot_id, storage_key, storage_value)
+                 SELECT snapshot.id, state.storage_key, state.storage_value
+                 FROM snapshot,
+                      state
+         )
+    SELECT snapshot.id
+    INTO new_snapshot_id
+    FROM snapshot;
+
+    RETURN new_snapshot_id;
+END
+$$;
+
+
+--
+-- Name: FUNCTION create_storage_snapshot(address bytea, block_height bigint); Type: COMMENT; Schema: public; Owner: -
+--
+
+COMMENT ON FUNCTION public.create_storage_snapshot(address bytea, block_height bigint) IS '@omit';
+
+
+--
+-- Name: get_contract_storage_at(bytea, bigint); Type: FUNCTION; Schema: public; Owner: -
+--
+
+CREATE FUNCTION public.get_contract_storage_at(address bytea, block_height bigint) RETURNS TABLE(storage_key bytea, storage_value bytea)
+    LANGUAGE sql STABLE
+    AS $$
+WITH snapshot AS (
+    SELECT storage_snapshot.id, storage_snapshot.block_height
+    FROM public.storage_snapshot
+    WHERE storage_snapshot.address = get_contract_storage_at.address
+      AND storage_snapshot.block_height <= get_contract_storage_at.block_height
+    ORDER BY storage_snapshot.block_height DESC
+    LIMIT 1
+),
+     recent AS (
+         SELECT DISTINCT ON (storage_diff.storage_key) storage_diff.storage_key, storage_diff.storage_value
+         FROM public.storage_diff
+                  JOIN public.headers ON headers.block_number = storage_diff.block_height
+             AND headers.hash = '0x' || encode(storage_diff.block_hash, 'hex')
+         WHERE storage_diff.address = get_contract_storage_at.address
+           AND storage_diff.block_height <= get_contract_storage_at.block_height
+           AND storage_diff.block_height > COALESCE((SELECT snapshot.block_height FROM snapshot), -1)
+           AND storage_diff.status != 'noncanonical'
+         ORDER BY storage_diff.storage_key, storage_diff.block_height DESC, storage_diff.id DESC
+     )
+SELECT recent.storage_key, recent.storage_value
+FROM recent
+UNION ALL
+SELECT snapshot_value.storage_key, snapshot_value.storage_value
+FROM public.storage_snapshot_value AS snapshot_value
+WHERE snapshot_value.snapshot_id = (SELECT snapshot.id FROM snapshot)
+  AND NOT EXISTS(SELECT 1 FROM recent WHERE recent.storage_key = snapshot_value.storage_key)
+$$;
+
+
 --
 -- Name: get_or_create_header(bigint, character varying, jsonb, numeric, integer); Type: FUNCTION; Schema: public; Owner: -
 --
@@ -132,6 +216,84 @@ $$;
 COMMENT ON FUNCTION public.get_or_create_header(block_number bigint, hash character varying, raw jsonb, block_timestamp numeric, eth_node_id integer) IS '@omit';
 
 
+--
+-- Name: get_storage_at(bytea, bytea, bigint); Type: FUNCTION; Schema: public; Owner: -
+--
+
+CREATE FUNCTION public.get_storage_at(address bytea, storage_key bytea, block_height bigint) RETURNS bytea
+    LANGUAGE sql STABLE
+    AS $$
+SELECT storage_diff.storage_value
+FROM public.storage_diff
+         JOIN public.headers ON headers.block_number = storage_diff.block_height
+    AND headers.hash = '0x' || encode(storage_diff.block_hash, 'hex')
+WHERE storage_diff.address = get_storage_at.address
+  AND storage_diff.storage_key = get_storage_at.storage_key
+  AND storage_diff.block_height <= get_storage_at.block_height
+  AND storage_diff.status != 'noncanonical'
+ORDER BY storage_diff.block_height DESC, storage_diff.id DESC
+LIMIT 1
+$$;
+
+
+--
+-- Name: invalidate_storage_snapshots(); Type: FUNCTION; Schema: public; Owner: -
+--
+
+CREATE FUNCTION public.invalidate_storage_snapshots() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    DELETE
+    FROM public.storage_snapshot
+    WHERE storage_snapshot.address = NEW.address
+      AND storage_snapshot.block_height >= NEW.block_height;
+    RETURN NULL;
+END
+$$;
+
+
+--
+-- Name: FUNCTION invalidate_storage_snapshots(); Type: COMMENT; Schema: public; Owner: -
+--
+
+COMMENT ON FUNCTION public.invalidate_storage_snapshots() IS '@omit';
+
+
+--
+-- Name: invalidate_storage_snapshots_after_insert(); Type: FUNCTION; Schema: public; Owner: -
+--
+
+CREATE FUNCTION public.invalidate_storage_snapshots_after_insert() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    -- diffs restored from an archive were pruned after the snapshots that include them were taken
+    IF current_setting('vulcanize.restoring_archive', true) = 'on' THEN
+        RETURN NULL;
+    END IF;
+
+    DELETE
+    FROM public.storage_snapshot
+        USING (
+            SELECT inserted_diffs.address, MIN(inserted_diffs.block_height) AS block_height
+            FROM inserted_diffs
+            GROUP BY inserted_diffs.address
+        ) AS inserted
+    WHERE storage_snapshot.address = inserted.address
+      AND storage_snapshot.block_height >= inserted.block_height;
+    RETURN NULL;
+END
+$$;
+
+
+--
+-- Name: FUNCTION invalidate_storage_snapshots_after_insert(); Type: COMMENT; Schema: public; Owner: -
+--
+
+COMMENT ON FUNCTION public.invalidate_storage_snapshots_after_insert() IS '@omit';
+
+
 --
 -- Name: set_header_updated(); Type: FUNCTION; Schema: public; Owner: -
 --
@@ -150,6 +312,43 @@ SET default_tablespace = '';
 
 SET default_with_oids = false;
 
+--
+-- Name: account_diff; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.account_diff (
+    id bigint NOT NULL,
+    address bytea,
+    block_height bigint,
+    block_hash bytea,
+    balance numeric,
+    nonce bigint,
+    code_hash bytea,
+    storage_root bytea,
+    eth_node_id integer NOT NULL,
+    status public.diff_status DEFAULT 'new'::public.diff_status NOT NULL
+);
+
+
+--
+-- Name: account_diff_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE public.account_diff_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: account_diff_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE public.account_diff_id_seq OWNED BY public.account_diff.id;
+
+
 --
 -- Name: addresses; Type: TABLE; Schema: public; Owner: -
 --
@@ -179,21 +378,77 @@ CREATE SEQUENCE public.addresses_id_seq
 ALTER SEQUENCE public.addresses_id_seq OWNED BY public.addresses.id;
 
 
+--
+-- Name: checked_events; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.checked_events (
+    id integer NOT NULL,
+    name text NOT NULL
+);
+
+
+--
+-- Name: checked_events_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE public.checked_events_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: checked_events_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE public.checked_events_id_seq OWNED BY public.checked_events.id;
+
+
 --
 -- Name: checked_headers; Type: TABLE; Schema: public; Owner: -
 --
 
 CREATE TABLE public.checked_headers (
+    header_id integer NOT NULL,
+    event_id integer NOT NULL,
+    check_count integer DEFAULT 1 NOT NULL
+);
+
+
+--
+-- Name: contract_abi; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.contract_abi (
+    address character varying(42) NOT NULL,
+    abi text NOT NULL,
+    source text NOT NULL,
+    created timestamp without time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: contract_abi_version; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.contract_abi_version (
     id integer NOT NULL,
-    header_id integer NOT NULL
+    address character varying(42) NOT NULL,
+    version integer NOT NULL,
+    abi text NOT NULL,
+    created timestamp without time zone DEFAULT now() NOT NULL
 );
 
 
 --
--- Name: checked_headers_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+-- Name: contract_abi_version_id_seq; Type: SEQUENCE; Schema: public; Owner: -
 --
 
-CREATE SEQUENCE public.checked_headers_id_seq
+CREATE SEQUENCE public.contract_abi_version_id_seq
     AS integer
     START WITH 1
     INCREMENT BY 1
@@ -203,10 +458,10 @@ CREATE SEQUENCE public.checked_headers_id_seq
 
 
 --
--- Name: checked_headers_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+-- Name: contract_abi_version_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
 --
 
-ALTER SEQUENCE public.checked_headers_id_seq OWNED BY public.checked_headers.id;
+ALTER SEQUENCE public.contract_abi_version_id_seq OWNED BY public.contract_abi_version.id;
 
 
 --
@@ -388,6 +643,39 @@ CREATE SEQUENCE public.receipts_id_seq
 ALTER SEQUENCE public.receipts_id_seq OWNED BY public.receipts.id;
 
 
+--
+-- Name: storage_backfill_range; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.storage_backfill_range (
+    id integer NOT NULL,
+    job text NOT NULL,
+    starting_block bigint NOT NULL,
+    ending_block bigint NOT NULL,
+    last_block bigint
+);
+
+
+--
+-- Name: storage_backfill_range_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE public.storage_backfill_range_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: storage_backfill_range_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE public.storage_backfill_range_id_seq OWNED BY public.storage_backfill_range.id;
+
+
 --
 -- Name: storage_diff; Type: TABLE; Schema: public; Owner: -
 --
@@ -401,7 +689,10 @@ CREATE TABLE public.storage_diff (
     storage_value bytea,
     eth_node_id integer NOT NULL,
     status public.diff_status DEFAULT 'new'::public.diff_status NOT NULL,
-    from_backfill boolean DEFAULT false NOT NULL
+    from_backfill boolean DEFAULT false NOT NULL,
+    retry_count integer DEFAULT 0 NOT NULL,
+    next_attempt_at timestamp with time zone,
+    proof_mismatch boolean DEFAULT false NOT NULL
 );
 
 
@@ -424,6 +715,59 @@ CREATE SEQUENCE public.storage_diff_id_seq
 ALTER SEQUENCE public.storage_diff_id_seq OWNED BY public.storage_diff.id;
 
 
+--
+-- Name: storage_fetcher_checkpoint; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.storage_fetcher_checkpoint (
+    source text NOT NULL,
+    block_height bigint NOT NULL
+);
+
+
+--
+-- Name: storage_snapshot; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.storage_snapshot (
+    id integer NOT NULL,
+    address bytea NOT NULL,
+    block_height bigint NOT NULL,
+    created timestamp without time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: storage_snapshot_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE public.storage_snapshot_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: storage_snapshot_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE public.storage_snapshot_id_seq OWNED BY public.storage_snapshot.id;
+
+
+--
+-- Name: storage_snapshot_value; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.storage_snapshot_value (
+    snapshot_id integer NOT NULL,
+    storage_key bytea NOT NULL,
+    storage_value bytea NOT NULL
+);
+
+
 --
 -- Name: transactions; Type: TABLE; Schema: public; Owner: -
 --
@@ -495,6 +839,13 @@ CREATE SEQUENCE public.watched_logs_id_seq
 ALTER SEQUENCE public.watched_logs_id_seq OWNED BY public.watched_logs.id;
 
 
+--
+-- Name: account_diff id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.account_diff ALTER COLUMN id SET DEFAULT nextval('public.account_diff_id_seq'::regclass);
+
+
 --
 -- Name: addresses id; Type: DEFAULT; Schema: public; Owner: -
 --
@@ -503,10 +854,17 @@ ALTER TABLE ONLY public.addresses ALTER COLUMN id SET DEFAULT nextval('public.ad
 
 
 --
--- Name: checked_headers id; Type: DEFAULT; Schema: public; Owner: -
+-- Name: checked_events id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.checked_events ALTER COLUMN id SET DEFAULT nextval('public.checked_events_id_seq'::regclass);
+
+
+--
+-- Name: contract_abi_version id; Type: DEFAULT; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY public.checked_headers ALTER COLUMN id SET DEFAULT nextval('public.checked_headers_id_seq'::regclass);
+ALTER TABLE ONLY public.contract_abi_version ALTER COLUMN id SET DEFAULT nextval('public.contract_abi_version_id_seq'::regclass);
 
 
 --
@@ -544,6 +902,13 @@ ALTER TABLE ONLY public.headers ALTER COLUMN id SET DEFAULT nextval('public.head
 ALTER TABLE ONLY public.receipts ALTER COLUMN id SET DEFAULT nextval('public.receipts_id_seq'::regclass);
 
 
+--
+-- Name: storage_backfill_range id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.storage_backfill_range ALTER COLUMN id SET DEFAULT nextval('public.storage_backfill_range_id_seq'::regclass);
+
+
 --
 -- Name: storage_diff id; Type: DEFAULT; Schema: public; Owner: -
 --
@@ -551,6 +916,13 @@ ALTER TABLE ONLY public.receipts ALTER COLUMN id SET DEFAULT nextval('public.rec
 ALTER TABLE ONLY public.storage_diff ALTER COLUMN id SET DEFAULT nextval('public.storage_diff_id_seq'::regclass);
 
 
+--
+-- Name: storage_snapshot id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.storage_snapshot ALTER COLUMN id SET DEFAULT nextval('public.storage_snapshot_id_seq'::regclass);
+
+
 --
 -- Name: transactions id; Type: DEFAULT; Schema: public; Owner: -
 --
@@ -565,6 +937,22 @@ ALTER TABLE ONLY public.transactions ALTER COLUMN id SET DEFAULT nextval('public
 ALTER TABLE ONLY public.watched_logs ALTER COLUMN id SET DEFAULT nextval('public.watched_logs_id_seq'::regclass);
 
 
+--
+-- Name: account_diff account_diff_block_height_block_hash_address_balance_nonce__key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.account_diff
+    ADD CONSTRAINT account_diff_block_height_block_hash_address_balance_nonce__key UNIQUE (block_height, block_hash, address, balance, nonce, code_hash, storage_root);
+
+
+--
+-- Name: account_diff account_diff_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.account_diff
+    ADD CONSTRAINT account_diff_pkey PRIMARY KEY (id);
+
+
 --
 -- Name: addresses addresses_address_key; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -582,11 +970,19 @@ ALTER TABLE ONLY public.addresses
 
 
 --
--- Name: checked_headers checked_headers_header_id_key; Type: CONSTRAINT; Schema: public; Owner: -
+-- Name: checked_events checked_events_name_key; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY public.checked_headers
-    ADD CONSTRAINT checked_headers_header_id_key UNIQUE (header_id);
+ALTER TABLE ONLY public.checked_events
+    ADD CONSTRAINT checked_events_name_key UNIQUE (name);
+
+
+--
+-- Name: checked_events checked_events_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.checked_events
+    ADD CONSTRAINT checked_events_pkey PRIMARY KEY (id);
 
 
 --
@@ -594,7 +990,31 @@ ALTER TABLE ONLY public.checked_headers
 --
 
 ALTER TABLE ONLY public.checked_headers
-    ADD CONSTRAINT checked_headers_pkey PRIMARY KEY (id);
+    ADD CONSTRAINT checked_headers_pkey PRIMARY KEY (header_id, event_id);
+
+
+--
+-- Name: contract_abi contract_abi_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.contract_abi
+    ADD CONSTRAINT contract_abi_pkey PRIMARY KEY (address);
+
+
+--
+-- Name: contract_abi_version contract_abi_version_address_version_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.contract_abi_version
+    ADD CONSTRAINT contract_abi_version_address_version_key UNIQUE (address, version);
+
+
+--
+-- Name: contract_abi_version contract_abi_version_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.contract_abi_version
+    ADD CONSTRAINT contract_abi_version_pkey PRIMARY KEY (id);
 
 
 --
@@ -669,6 +1089,22 @@ ALTER TABLE ONLY public.receipts
     ADD CONSTRAINT receipts_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: storage_backfill_range storage_backfill_range_job_starting_block_ending_block_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.storage_backfill_range
+    ADD CONSTRAINT storage_backfill_range_job_starting_block_ending_block_key UNIQUE (job, starting_block, ending_block);
+
+
+--
+-- Name: storage_backfill_range storage_backfill_range_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.storage_backfill_range
+    ADD CONSTRAINT storage_backfill_range_pkey PRIMARY KEY (id);
+
+
 --
 -- Name: storage_diff storage_diff_block_height_block_hash_address_storage_key_st_key; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -685,6 +1121,38 @@ ALTER TABLE ONLY public.storage_diff
     ADD CONSTRAINT storage_diff_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: storage_fetcher_checkpoint storage_fetcher_checkpoint_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.storage_fetcher_checkpoint
+    ADD CONSTRAINT storage_fetcher_checkpoint_pkey PRIMARY KEY (source);
+
+
+--
+-- Name: storage_snapshot storage_snapshot_address_block_height_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.storage_snapshot
+    ADD CONSTRAINT storage_snapshot_address_block_height_key UNIQUE (address, block_height);
+
+
+--
+-- Name: storage_snapshot storage_snapshot_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.storage_snapshot
+    ADD CONSTRAINT storage_snapshot_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: storage_snapshot_value storage_snapshot_value_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.storage_snapshot_value
+    ADD CONSTRAINT storage_snapshot_value_pkey PRIMARY KEY (snapshot_id, storage_key);
+
+
 --
 -- Name: transactions transactions_hash_key; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -709,6 +1177,34 @@ ALTER TABLE ONLY public.watched_logs
     ADD CONSTRAINT watched_logs_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: account_diff_eth_node; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX account_diff_eth_node ON public.account_diff USING btree (eth_node_id);
+
+
+--
+-- Name: account_diff_new_status_index; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX account_diff_new_status_index ON public.account_diff USING btree (status) WHERE (status = 'new'::public.diff_status);
+
+
+--
+-- Name: account_diff_pending_header_index; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX account_diff_pending_header_index ON public.account_diff USING btree (address, block_height) WHERE (status = 'pending_header'::public.diff_status);
+
+
+--
+-- Name: checked_headers_event_id_index; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX checked_headers_event_id_index ON public.checked_headers USING btree (event_id);
+
+
 --
 -- Name: event_logs_address; Type: INDEX; Schema: public; Owner: -
 --
@@ -772,6 +1268,20 @@ CREATE INDEX receipts_contract_address ON public.receipts USING btree (contract_
 CREATE INDEX receipts_transaction ON public.receipts USING btree (transaction_id);
 
 
+--
+-- Name: storage_diff_address_block_height_index; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX storage_diff_address_block_height_index ON public.storage_diff USING btree (address, block_height, id) WHERE (status = ANY (ARRAY['new'::public.diff_status, 'unrecognized'::public.diff_status]));
+
+
+--
+-- Name: storage_diff_address_storage_key_block_height_index; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX storage_diff_address_storage_key_block_height_index ON public.storage_diff USING btree (address, storage_key, block_height);
+
+
 --
 -- Name: storage_diff_eth_node; Type: INDEX; Schema: public; Owner: -
 --
@@ -786,6 +1296,27 @@ CREATE INDEX storage_diff_eth_node ON public.storage_diff USING btree (eth_node_
 CREATE INDEX storage_diff_new_status_index ON public.storage_diff USING btree (status) WHERE (status = 'new'::public.diff_status);
 
 
+--
+-- Name: storage_diff_pending_header_index; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX storage_diff_pending_header_index ON public.storage_diff USING btree (address, storage_key, block_height) WHERE (status = 'pending_header'::public.diff_status);
+
+
+--
+-- Name: storage_diff_proof_mismatch_index; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX storage_diff_proof_mismatch_index ON public.storage_diff USING btree (id) WHERE proof_mismatch;
+
+
+--
+-- Name: storage_diff_unrecognized_next_attempt_index; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX storage_diff_unrecognized_next_attempt_index ON public.storage_diff USING btree (next_attempt_at) WHERE (status = 'unrecognized'::public.diff_status);
+
+
 --
 -- Name: storage_diff_unrecognized_status_index; Type: INDEX; Schema: public; Owner: -
 --
@@ -807,6 +1338,36 @@ CREATE INDEX transactions_header ON public.transactions USING btree (header_id);
 CREATE TRIGGER header_updated BEFORE UPDATE ON public.headers FOR EACH ROW EXECUTE PROCEDURE public.set_header_updated();
 
 
+--
+-- Name: storage_diff storage_snapshot_invalidated_by_insert; Type: TRIGGER; Schema: public; Owner: -
+--
+
+CREATE TRIGGER storage_snapshot_invalidated_by_insert AFTER INSERT ON public.storage_diff REFERENCING NEW TABLE AS inserted_diffs FOR EACH STATEMENT EXECUTE PROCEDURE public.invalidate_storage_snapshots_after_insert();
+
+
+--
+-- Name: storage_diff storage_snapshot_invalidated_by_noncanonical; Type: TRIGGER; Schema: public; Owner: -
+--
+
+CREATE TRIGGER storage_snapshot_invalidated_by_noncanonical AFTER UPDATE OF status ON public.storage_diff FOR EACH ROW WHEN (((new.status = 'noncanonical'::public.diff_status) AND (old.status <> 'noncanonical'::public.diff_status))) EXECUTE PROCEDURE public.invalidate_storage_snapshots();
+
+
+--
+-- Name: account_diff account_diff_eth_node_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.account_diff
+    ADD CONSTRAINT account_diff_eth_node_id_fkey FOREIGN KEY (eth_node_id) REFERENCES public.eth_nodes(id) ON DELETE CASCADE;
+
+
+--
+-- Name: checked_headers checked_headers_event_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.checked_headers
+    ADD CONSTRAINT checked_headers_event_id_fkey FOREIGN KEY (event_id) REFERENCES public.checked_events(id) ON DELETE CASCADE;
+
+
 --
 -- Name: checked_headers checked_headers_header_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
 --
@@ -879,6 +1440,14 @@ ALTER TABLE ONLY public.storage_diff
     ADD CONSTRAINT storage_diff_eth_node_id_fkey FOREIGN KEY (eth_node_id) REFERENCES public.eth_nodes(id) ON DELETE CASCADE;
 
 
+--
+-- Name: storage_snapshot_value storage_snapshot_value_snapshot_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.storage_snapshot_value
+    ADD CONSTRAINT storage_snapshot_value_snapshot_id_fkey FOREIGN KEY (snapshot_id) REFERENCES public.storage_snapshot(id) ON DELETE CASCADE;
+
+
 --
 -- Name: transactions transactions_header_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
 --
@@ -5,6 +5,7 @@ go 1.12
 require (
 	github.com/dave/jennifer v1.3.0
 	github.com/ethereum/go-ethereum v1.9.8
+	github.com/fsnotify/fsnotify v1.4.7
 	github.com/hashicorp/golang-lru v0.5.4
 	github.com/hpcloud/tail v1.0.0
 	github.com/jmoiron/sqlx v0.0.0-20181024163419-82935fac6c1a
@@ -246,24 +246,29 @@ var _ = Describe("contractWatcher transformer", func() {
 			Expect(err).ToNot(HaveOccurred())
 			Expect(transferLog.HeaderID).ToNot(Equal(newOwnerLog.HeaderID))
 
-			type checkedHeader struct {
-				ID       int64 `db:"id"`
-				HeaderID int64 `db:"header_id"`
-				NewOwner int64 `db:"newowner_0x314159265dd8dbb310642f98f50c066173c1259b"`
-				Transfer int64 `db:"transfer_0x8dd5fbce2f6a956c3022ba3663759011dd51e73e"`
+			checkCounts := func(headerID int64) map[string]int64 {
+				var checks []struct {
+					Name       string `db:"name"`
+					CheckCount int64  `db:"check_count"`
+				}
+				err := db.Select(&checks, `SELECT checked_events.name, checked_headers.check_count FROM public.checked_headers
+					JOIN public.checked_events ON checked_events.id = checked_headers.event_id
+					WHERE checked_headers.header_id = $1`, headerID)
+				Expect(err).ToNot(HaveOccurred())
+				counts := make(map[string]int64, len(checks))
+				for _, check := range checks {
+					counts[check.Name] = check.CheckCount
+				}
+				return counts
 			}
 
-			transferCheckedHeader := new(checkedHeader)
-			err = db.QueryRowx("SELECT * FROM public.checked_headers WHERE header_id = $1", transferLog.HeaderID).StructScan(transferCheckedHeader)
-			Expect(err).ToNot(HaveOccurred())
-			Expect(transferCheckedHeader.Transfer).To(Equal(int64(1)))
-			Expect(transferCheckedHeader.NewOwner).To(Equal(int64(1)))
+			transferCheckedHeader := checkCounts(transferLog.HeaderID)
+			Expect(transferCheckedHeader).To(HaveKeyWithValue("transfer_0x8dd5fbce2f6a956c3022ba3663759011dd51e73e", int64(1)))
+			Expect(transferCheckedHeader).To(HaveKeyWithValue("newowner_0x314159265dd8dbb310642f98f50c066173c1259b", int64(1)))
 
-			newOwnerCheckedHeader := new(checkedHeader)
-			err = db.QueryRowx("SELECT * FROM public.checked_headers WHERE header_id = $1", newOwnerLog.HeaderID).StructScan(newOwnerCheckedHeader)
-			Expect(err).ToNot(HaveOccurred())
-			Expect(newOwnerCheckedHeader.NewOwner).To(Equal(int64(1)))
-			Expect(newOwnerCheckedHeader.Transfer).To(Equal(int64(1)))
+			newOwnerCheckedHeader := checkCounts(newOwnerLog.HeaderID)
+			Expect(newOwnerCheckedHeader).To(HaveKeyWithValue("newowner_0x314159265dd8dbb310642f98f50c066173c1259b", int64(1)))
+			Expect(newOwnerCheckedHeader).To(HaveKeyWithValue("transfer_0x8dd5fbce2f6a956c3022ba3663759011dd51e73e", int64(1)))
 		})
 	})
 })
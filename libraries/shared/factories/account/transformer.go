@@ -0,0 +1,48 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package account
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres"
+)
+
+type ITransformer interface {
+	Execute(diff types.PersistedAccountDiff) error
+	GetContractAddress() common.Address
+}
+
+type TransformerInitializer func(db *postgres.DB) ITransformer
+
+type Transformer struct {
+	Address    common.Address
+	Repository Repository
+}
+
+func (transformer Transformer) GetContractAddress() common.Address {
+	return transformer.Address
+}
+
+func (transformer Transformer) NewTransformer(db *postgres.DB) ITransformer {
+	transformer.Repository.SetDB(db)
+	return &transformer
+}
+
+func (transformer Transformer) Execute(diff types.PersistedAccountDiff) error {
+	return transformer.Repository.Create(diff.ID, diff.HeaderID, diff.RawAccountDiff)
+}
@@ -0,0 +1,84 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package account_test
+
+import (
+	"math/big"
+	"math/rand"
+
+	"github.com/makerdao/vulcanizedb/libraries/shared/factories/account"
+	"github.com/makerdao/vulcanizedb/libraries/shared/mocks"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
+	"github.com/makerdao/vulcanizedb/libraries/shared/test_data"
+	"github.com/makerdao/vulcanizedb/pkg/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Account transformer", func() {
+	var (
+		repository *mocks.MockAccountRepository
+		t          account.Transformer
+	)
+
+	BeforeEach(func() {
+		repository = &mocks.MockAccountRepository{}
+		t = account.Transformer{
+			Address:    fakes.FakeAddress,
+			Repository: repository,
+		}
+	})
+
+	It("returns the contract address being watched", func() {
+		Expect(t.GetContractAddress()).To(Equal(fakes.FakeAddress))
+	})
+
+	It("sets the db on the repository when initialized", func() {
+		t.NewTransformer(nil)
+
+		Expect(repository.SetDBCalled).To(BeTrue())
+	})
+
+	It("creates a row for the account diff", func() {
+		diff := types.PersistedAccountDiff{
+			RawAccountDiff: types.RawAccountDiff{
+				Address:     fakes.FakeAddress,
+				BlockHash:   test_data.FakeHash(),
+				BlockHeight: rand.Int(),
+				Balance:     big.NewInt(rand.Int63()),
+				Nonce:       rand.Uint64(),
+			},
+			ID:       rand.Int63(),
+			HeaderID: rand.Int63(),
+		}
+
+		err := t.Execute(diff)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(repository.PassedDiffIDs).To(ConsistOf(diff.ID))
+		Expect(repository.PassedHeaderIDs).To(ConsistOf(diff.HeaderID))
+		Expect(repository.PassedAccountDiffs).To(ConsistOf(diff.RawAccountDiff))
+	})
+
+	It("returns an error if creating the row fails", func() {
+		repository.CreateErr = fakes.FakeError
+
+		err := t.Execute(types.PersistedAccountDiff{})
+
+		Expect(err).To(MatchError(fakes.FakeError))
+	})
+})
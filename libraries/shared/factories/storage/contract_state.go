@@ -0,0 +1,108 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres"
+)
+
+type DecodedValue struct {
+	Key      common.Hash
+	Metadata types.ValueMetadata
+	Value    interface{}
+}
+
+// ContractState is the storage of a contract at the end of a block. Values are decoded with the transformer's keys
+// lookup; slots it doesn't recognize are kept raw.
+type ContractState struct {
+	Address      common.Address
+	BlockHeight  int64
+	Values       []DecodedValue
+	Unrecognized map[common.Hash]common.Hash
+}
+
+// ContractStateReader rebuilds the storage of a transformer's contract at any block from its storage diffs
+type ContractStateReader struct {
+	Repository  storage.StateRepository
+	Transformer ITransformer
+}
+
+func NewContractStateReader(db *postgres.DB, transformer ITransformer) ContractStateReader {
+	return ContractStateReader{
+		Repository:  storage.NewStateRepository(db),
+		Transformer: transformer,
+	}
+}
+
+// GetStateAt returns every known storage slot of the contract at the end of the block, with recognized values sorted
+// by name and then by key
+func (reader ContractStateReader) GetStateAt(blockHeight int64) (ContractState, error) {
+	address := reader.Transformer.GetContractAddress()
+	state := ContractState{
+		Address:      address,
+		BlockHeight:  blockHeight,
+		Unrecognized: make(map[common.Hash]common.Hash),
+	}
+	rawStorage, storageErr := reader.Repository.GetContractStorageAt(address, blockHeight)
+	if storageErr != nil {
+		return state, storageErr
+	}
+
+	for key, value := range rawStorage {
+		decoded, decodeErr := reader.decode(key, value)
+		if errors.Is(decodeErr, types.ErrKeyNotFound) {
+			state.Unrecognized[key] = value
+			continue
+		}
+		if decodeErr != nil {
+			return state, decodeErr
+		}
+		state.Values = append(state.Values, decoded)
+	}
+	sort.Slice(state.Values, func(i, j int) bool {
+		if state.Values[i].Metadata.Name != state.Values[j].Metadata.Name {
+			return state.Values[i].Metadata.Name < state.Values[j].Metadata.Name
+		}
+		return state.Values[i].Key.Hex() < state.Values[j].Key.Hex()
+	})
+	return state, nil
+}
+
+// GetValueAt returns the decoded value of one storage slot at the end of the block
+func (reader ContractStateReader) GetValueAt(key common.Hash, blockHeight int64) (DecodedValue, error) {
+	value, storageErr := reader.Repository.GetStorageAt(reader.Transformer.GetContractAddress(), key, blockHeight)
+	if storageErr != nil {
+		return DecodedValue{}, storageErr
+	}
+	return reader.decode(key, value)
+}
+
+func (reader ContractStateReader) decode(key, value common.Hash) (DecodedValue, error) {
+	metadata, lookupErr := reader.Transformer.GetStorageKeysLookup().Lookup(key)
+	if lookupErr != nil {
+		return DecodedValue{}, fmt.Errorf("error getting metadata for storage key: %w", lookupErr)
+	}
+	diff := types.PersistedDiff{RawDiff: types.RawDiff{StorageKey: key, StorageValue: value}}
+	return DecodedValue{Key: key, Metadata: metadata, Value: storage.Decode(diff, metadata)}, nil
+}
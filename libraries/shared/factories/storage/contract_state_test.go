@@ -0,0 +1,108 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package storage_test
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/makerdao/vulcanizedb/libraries/shared/factories/storage"
+	"github.com/makerdao/vulcanizedb/libraries/shared/mocks"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
+	"github.com/makerdao/vulcanizedb/libraries/shared/test_data"
+	"github.com/makerdao/vulcanizedb/pkg/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Contract state reader", func() {
+	var (
+		address       = test_data.FakeAddress()
+		ownerKey      = common.HexToHash("0x00")
+		supplyKey     = common.HexToHash("0x01")
+		unknownKey    = common.HexToHash("0x02")
+		ownerMetadata = types.GetValueMetadata("owner", nil, types.Address)
+		supplyMeta    = types.GetValueMetadata("supply", nil, types.Uint256)
+		repository    *mocks.MockStateRepository
+		reader        storage.ContractStateReader
+	)
+
+	BeforeEach(func() {
+		repository = &mocks.MockStateRepository{Storage: map[common.Hash]common.Hash{
+			ownerKey:   address.Hash(),
+			supplyKey:  common.HexToHash("0x64"),
+			unknownKey: common.HexToHash("0x03"),
+		}}
+		keysLookup := &mocks.MockStorageKeysLookup{MetadataByKey: map[common.Hash]types.ValueMetadata{
+			ownerKey:  ownerMetadata,
+			supplyKey: supplyMeta,
+		}}
+		reader = storage.ContractStateReader{
+			Repository:  repository,
+			Transformer: &mocks.MockStorageTransformer{Address: address, StorageKeysLookup: keysLookup},
+		}
+	})
+
+	Describe("GetStateAt", func() {
+		It("gets the contract's storage at the block", func() {
+			_, err := reader.GetStateAt(123)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(repository.PassedAddress).To(Equal(address))
+			Expect(repository.PassedBlockHeight).To(Equal(int64(123)))
+		})
+
+		It("decodes recognized values, sorted by name", func() {
+			state, err := reader.GetStateAt(123)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(state.Values).To(Equal([]storage.DecodedValue{
+				{Key: ownerKey, Metadata: ownerMetadata, Value: address.Hex()},
+				{Key: supplyKey, Metadata: supplyMeta, Value: "100"},
+			}))
+		})
+
+		It("keeps unrecognized values raw", func() {
+			state, err := reader.GetStateAt(123)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(state.Unrecognized).To(Equal(map[common.Hash]common.Hash{unknownKey: common.HexToHash("0x03")}))
+		})
+
+		It("returns an error if getting storage fails", func() {
+			repository.GetContractStorageAtErr = fakes.FakeError
+
+			_, err := reader.GetStateAt(123)
+
+			Expect(err).To(MatchError(fakes.FakeError))
+		})
+	})
+
+	Describe("GetValueAt", func() {
+		It("decodes the value of the key at the block", func() {
+			value, err := reader.GetValueAt(supplyKey, 123)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(repository.PassedBlockHeight).To(Equal(int64(123)))
+			Expect(value).To(Equal(storage.DecodedValue{Key: supplyKey, Metadata: supplyMeta, Value: "100"}))
+		})
+
+		It("returns an error if the key isn't recognized", func() {
+			_, err := reader.GetValueAt(unknownKey, 123)
+
+			Expect(err).To(MatchError(types.ErrKeyNotFound))
+		})
+	})
+})
@@ -26,3 +26,11 @@ type KeysLoader interface {
 	LoadMappings() (map[common.Hash]types.ValueMetadata, error)
 	SetDB(db *postgres.DB)
 }
+
+// IncrementalKeysLoader is implemented by loaders that can return only the mappings added after a watermark
+// (e.g. the highest event log ID they have already read), so that a lookup miss doesn't reload every mapping.
+// A watermark of zero requests all mappings; the returned watermark is passed back in on the next call.
+type IncrementalKeysLoader interface {
+	KeysLoader
+	LoadMappingsSince(watermark int64) (map[common.Hash]types.ValueMetadata, int64, error)
+}
@@ -18,63 +18,171 @@ package storage
 
 import (
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/hashicorp/golang-lru"
 	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
 	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres"
+	"github.com/sirupsen/logrus"
 )
 
 type KeysLookup interface {
 	Lookup(key common.Hash) (types.ValueMetadata, error)
 	SetDB(db *postgres.DB)
 	GetKeys() ([]common.Hash, error)
+	Stats() KeysLookupStats
+}
+
+// KeysLookupConfig bounds how much work a lookup does for keys it doesn't recognize
+type KeysLookupConfig struct {
+	NegativeCacheSize  int           // max number of unrecognized keys remembered between refreshes
+	NegativeCacheTTL   time.Duration // how long an unrecognized key is answered from the cache without a refresh
+	MinRefreshInterval time.Duration // minimum time between two refreshes triggered by lookup misses
+}
+
+var DefaultKeysLookupConfig = KeysLookupConfig{
+	NegativeCacheSize:  10000,
+	NegativeCacheTTL:   time.Minute,
+	MinRefreshInterval: 5 * time.Second,
+}
+
+// KeysLookupStats counts lookup outcomes so that loaders for large contracts can be tuned
+type KeysLookupStats struct {
+	Hits      int64
+	Misses    int64
+	Refreshes int64
 }
 
 type keysLookup struct {
-	loader   KeysLoader
-	mappings map[common.Hash]types.ValueMetadata
+	sync.Mutex
+	loader        KeysLoader
+	mappings      map[common.Hash]types.ValueMetadata
+	watermark     int64
+	lastRefresh   time.Time
+	negativeCache *lru.Cache // unrecognized key => time.Time when the entry expires
+	config        KeysLookupConfig
+	stats         KeysLookupStats
+}
+
+func NewKeysLookup(loader KeysLoader) KeysLookup {
+	return NewKeysLookupWithConfig(loader, DefaultKeysLookupConfig)
+}
+
+func NewKeysLookupWithConfig(loader KeysLoader, config KeysLookupConfig) KeysLookup {
+	cacheSize := config.NegativeCacheSize
+	if cacheSize <= 0 {
+		cacheSize = 1
+	}
+	negativeCache, _ := lru.New(cacheSize)
+	return &keysLookup{
+		loader:        loader,
+		mappings:      make(map[common.Hash]types.ValueMetadata),
+		negativeCache: negativeCache,
+		config:        config,
+	}
 }
 
 func (lookup *keysLookup) GetKeys() ([]common.Hash, error) {
+	lookup.Lock()
+	defer lookup.Unlock()
 	var keys []common.Hash
 	refreshErr := lookup.refreshMappings()
 	if refreshErr != nil {
 		return []common.Hash{}, fmt.Errorf("error refreshing mappings while getting keys: %w", refreshErr)
 	}
-	for key, _ := range lookup.mappings {
+	for key := range lookup.mappings {
 		keys = append(keys, key)
 	}
 	return keys, nil
 }
 
-func NewKeysLookup(loader KeysLoader) KeysLookup {
-	return &keysLookup{loader: loader, mappings: make(map[common.Hash]types.ValueMetadata)}
-}
-
+// Lookup returns the metadata for a key, refreshing the known mappings on a miss unless the key was recently
+// unrecognized or the last refresh happened less than MinRefreshInterval ago
 func (lookup *keysLookup) Lookup(key common.Hash) (types.ValueMetadata, error) {
+	lookup.Lock()
+	defer lookup.Unlock()
 	metadata, ok := lookup.mappings[key]
+	if ok {
+		lookup.stats.Hits++
+		return metadata, nil
+	}
+	lookup.stats.Misses++
+
+	if lookup.isKnownMissing(key) || lookup.isRefreshRateLimited() {
+		return metadata, fmt.Errorf("%w: %s", types.ErrKeyNotFound, key.Hex())
+	}
+
+	refreshErr := lookup.refreshMappings()
+	if refreshErr != nil {
+		return metadata, fmt.Errorf("error refreshing mappings in keys lookup: %w", refreshErr)
+	}
+	metadata, ok = lookup.mappings[key]
 	if !ok {
-		refreshErr := lookup.refreshMappings()
-		if refreshErr != nil {
-			return metadata, fmt.Errorf("error refreshing mappings in keys lookup: %w", refreshErr)
-		}
-		metadata, ok = lookup.mappings[key]
-		if !ok {
-			return metadata, fmt.Errorf("%w: %s", types.ErrKeyNotFound, key.Hex())
-		}
+		lookup.negativeCache.Add(key, time.Now().Add(lookup.config.NegativeCacheTTL))
+		return metadata, fmt.Errorf("%w: %s", types.ErrKeyNotFound, key.Hex())
 	}
 	return metadata, nil
 }
 
+func (lookup *keysLookup) SetDB(db *postgres.DB) {
+	lookup.Lock()
+	defer lookup.Unlock()
+	lookup.loader.SetDB(db)
+	lookup.mappings = make(map[common.Hash]types.ValueMetadata)
+	lookup.watermark = 0
+	lookup.lastRefresh = time.Time{}
+	lookup.negativeCache.Purge()
+}
+
+func (lookup *keysLookup) Stats() KeysLookupStats {
+	lookup.Lock()
+	defer lookup.Unlock()
+	return lookup.stats
+}
+
+func (lookup *keysLookup) isKnownMissing(key common.Hash) bool {
+	expiry, ok := lookup.negativeCache.Get(key)
+	if !ok {
+		return false
+	}
+	if time.Now().Before(expiry.(time.Time)) {
+		return true
+	}
+	lookup.negativeCache.Remove(key)
+	return false
+}
+
+func (lookup *keysLookup) isRefreshRateLimited() bool {
+	if lookup.lastRefresh.IsZero() {
+		return false
+	}
+	return time.Now().Sub(lookup.lastRefresh) < lookup.config.MinRefreshInterval
+}
+
 func (lookup *keysLookup) refreshMappings() error {
-	newMappings, err := lookup.loader.LoadMappings()
+	lookup.stats.Refreshes++
+	lookup.lastRefresh = time.Now()
+	logrus.Debugf("refreshing storage key mappings: %+v", lookup.stats)
+
+	incrementalLoader, ok := lookup.loader.(IncrementalKeysLoader)
+	if !ok {
+		newMappings, err := lookup.loader.LoadMappings()
+		if err != nil {
+			return fmt.Errorf("error loading mappings: %w", err)
+		}
+		lookup.mappings = newMappings
+		return nil
+	}
+
+	newMappings, watermark, err := incrementalLoader.LoadMappingsSince(lookup.watermark)
 	if err != nil {
-		return fmt.Errorf("error loading mappings: %w", err)
+		return fmt.Errorf("error loading mappings since %d: %w", lookup.watermark, err)
+	}
+	for key, metadata := range newMappings {
+		lookup.mappings[key] = metadata
 	}
-	lookup.mappings = newMappings
+	lookup.watermark = watermark
 	return nil
 }
-
-func (lookup *keysLookup) SetDB(db *postgres.DB) {
-	lookup.loader.SetDB(db)
-}
@@ -17,6 +17,8 @@
 package storage_test
 
 import (
+	"time"
+
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/makerdao/vulcanizedb/libraries/shared/factories/storage"
 	"github.com/makerdao/vulcanizedb/libraries/shared/mocks"
@@ -97,6 +99,107 @@ var _ = Describe("Storage keys lookup", func() {
 			Expect(err).To(HaveOccurred())
 			Expect(err).To(MatchError(types.ErrKeyNotFound))
 		})
+
+		Describe("when key was recently not found", func() {
+			It("does not refresh keys again before the negative cache entry expires", func() {
+				lookup = storage.NewKeysLookupWithConfig(loader, storage.KeysLookupConfig{
+					NegativeCacheSize: 10,
+					NegativeCacheTTL:  time.Hour,
+				})
+				fakeKey := test_data.FakeHash()
+
+				_, errOne := lookup.Lookup(fakeKey)
+				Expect(errOne).To(MatchError(types.ErrKeyNotFound))
+				_, errTwo := lookup.Lookup(fakeKey)
+				Expect(errTwo).To(MatchError(types.ErrKeyNotFound))
+
+				Expect(loader.LoadMappingsCallCount).To(Equal(1))
+			})
+
+			It("refreshes keys once the negative cache entry expires", func() {
+				lookup = storage.NewKeysLookupWithConfig(loader, storage.KeysLookupConfig{
+					NegativeCacheSize: 10,
+					NegativeCacheTTL:  time.Millisecond,
+				})
+				fakeKey := test_data.FakeHash()
+				_, errOne := lookup.Lookup(fakeKey)
+				Expect(errOne).To(MatchError(types.ErrKeyNotFound))
+				time.Sleep(2 * time.Millisecond)
+				loader.StorageKeyMappings = map[common.Hash]types.ValueMetadata{fakeKey: fakeMetadata}
+
+				metadata, errTwo := lookup.Lookup(fakeKey)
+
+				Expect(errTwo).NotTo(HaveOccurred())
+				Expect(metadata).To(Equal(fakeMetadata))
+				Expect(loader.LoadMappingsCallCount).To(Equal(2))
+			})
+		})
+
+		It("does not refresh keys more often than the min refresh interval", func() {
+			lookup = storage.NewKeysLookupWithConfig(loader, storage.KeysLookupConfig{
+				NegativeCacheSize:  10,
+				MinRefreshInterval: time.Hour,
+			})
+
+			_, errOne := lookup.Lookup(test_data.FakeHash())
+			Expect(errOne).To(MatchError(types.ErrKeyNotFound))
+			_, errTwo := lookup.Lookup(test_data.FakeHash())
+			Expect(errTwo).To(MatchError(types.ErrKeyNotFound))
+
+			Expect(loader.LoadMappingsCallCount).To(Equal(1))
+		})
+
+		Describe("with an incremental loader", func() {
+			var incrementalLoader *mocks.MockIncrementalStorageKeysLoader
+
+			BeforeEach(func() {
+				incrementalLoader = &mocks.MockIncrementalStorageKeysLoader{}
+				lookup = storage.NewKeysLookupWithConfig(incrementalLoader, storage.KeysLookupConfig{NegativeCacheSize: 10})
+			})
+
+			It("loads mappings since the last watermark and keeps previously loaded keys", func() {
+				keyOne := test_data.FakeHash()
+				keyTwo := test_data.FakeHash()
+				incrementalLoader.StorageKeyMappings = map[common.Hash]types.ValueMetadata{keyOne: fakeMetadata}
+				incrementalLoader.WatermarkToReturn = 5
+				_, errOne := lookup.Lookup(keyOne)
+				Expect(errOne).NotTo(HaveOccurred())
+
+				incrementalLoader.StorageKeyMappings = map[common.Hash]types.ValueMetadata{keyTwo: fakeMetadata}
+				incrementalLoader.WatermarkToReturn = 8
+				_, errTwo := lookup.Lookup(keyTwo)
+				Expect(errTwo).NotTo(HaveOccurred())
+				_, errThree := lookup.Lookup(keyOne)
+				Expect(errThree).NotTo(HaveOccurred())
+
+				Expect(incrementalLoader.PassedWatermarks).To(Equal([]int64{0, 5}))
+				Expect(incrementalLoader.LoadMappingsCallCount).To(BeZero())
+			})
+
+			It("returns error if loading mappings since the watermark fails", func() {
+				incrementalLoader.LoadMappingsSinceError = fakes.FakeError
+
+				_, err := lookup.Lookup(test_data.FakeHash())
+
+				Expect(err).To(MatchError(fakes.FakeError))
+			})
+		})
+	})
+
+	Describe("Stats", func() {
+		It("counts hits, misses, and refreshes", func() {
+			fakeKey := test_data.FakeHash()
+			loader.StorageKeyMappings = map[common.Hash]types.ValueMetadata{fakeKey: fakeMetadata}
+
+			_, errOne := lookup.Lookup(fakeKey)
+			Expect(errOne).NotTo(HaveOccurred())
+			_, errTwo := lookup.Lookup(fakeKey)
+			Expect(errTwo).NotTo(HaveOccurred())
+			_, errThree := lookup.Lookup(test_data.FakeHash())
+			Expect(errThree).To(MatchError(types.ErrKeyNotFound))
+
+			Expect(lookup.Stats()).To(Equal(storage.KeysLookupStats{Hits: 1, Misses: 2, Refreshes: 1}))
+		})
 	})
 
 	Describe("SetDB", func() {
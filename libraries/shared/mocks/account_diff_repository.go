@@ -0,0 +1,82 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package mocks
+
+import (
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
+)
+
+type MockAccountDiffRepository struct {
+	CreatePassedRawDiffs                   []types.RawAccountDiff
+	CreateAccountDiffErr                   error
+	GetNewDiffsDiffs                       []types.PersistedAccountDiff
+	GetNewDiffsErrors                      []error
+	GetNewDiffsPassedMinIDs                []int
+	MarkTransformedPassedIDs               []int64
+	MarkNoncanonicalPassedID               int64
+	MarkUnwatchedPassedID                  int64
+	MarkPendingHeaderPassedIDs             []int64
+	HasPendingHeaderPredecessorPassedDiffs []types.PersistedAccountDiff
+	HasPendingHeaderPredecessorToReturn    bool
+	HasPendingHeaderPredecessorErr         error
+	ReleasePendingHeaderDiffsCalled        bool
+	ReleasePendingHeaderDiffsErr           error
+}
+
+func (repository *MockAccountDiffRepository) CreateAccountDiff(rawDiff types.RawAccountDiff) (int64, error) {
+	repository.CreatePassedRawDiffs = append(repository.CreatePassedRawDiffs, rawDiff)
+	return 0, repository.CreateAccountDiffErr
+}
+
+func (repository *MockAccountDiffRepository) GetNewAccountDiffs(minID, limit int) ([]types.PersistedAccountDiff, error) {
+	repository.GetNewDiffsPassedMinIDs = append(repository.GetNewDiffsPassedMinIDs, minID)
+	err := repository.GetNewDiffsErrors[0]
+	if len(repository.GetNewDiffsErrors) > 1 {
+		repository.GetNewDiffsErrors = repository.GetNewDiffsErrors[1:]
+	}
+	return repository.GetNewDiffsDiffs, err
+}
+
+func (repository *MockAccountDiffRepository) MarkTransformed(id int64) error {
+	repository.MarkTransformedPassedIDs = append(repository.MarkTransformedPassedIDs, id)
+	return nil
+}
+
+func (repository *MockAccountDiffRepository) MarkNoncanonical(id int64) error {
+	repository.MarkNoncanonicalPassedID = id
+	return nil
+}
+
+func (repository *MockAccountDiffRepository) MarkUnwatched(id int64) error {
+	repository.MarkUnwatchedPassedID = id
+	return nil
+}
+
+func (repository *MockAccountDiffRepository) MarkPendingHeader(id int64) error {
+	repository.MarkPendingHeaderPassedIDs = append(repository.MarkPendingHeaderPassedIDs, id)
+	return nil
+}
+
+func (repository *MockAccountDiffRepository) HasPendingHeaderPredecessor(diff types.PersistedAccountDiff) (bool, error) {
+	repository.HasPendingHeaderPredecessorPassedDiffs = append(repository.HasPendingHeaderPredecessorPassedDiffs, diff)
+	return repository.HasPendingHeaderPredecessorToReturn, repository.HasPendingHeaderPredecessorErr
+}
+
+func (repository *MockAccountDiffRepository) ReleasePendingHeaderDiffs() (int64, error) {
+	repository.ReleasePendingHeaderDiffsCalled = true
+	return 0, repository.ReleasePendingHeaderDiffsErr
+}
@@ -0,0 +1,41 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package mocks
+
+import (
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres"
+)
+
+type MockAccountRepository struct {
+	CreateErr          error
+	PassedDiffIDs      []int64
+	PassedHeaderIDs    []int64
+	PassedAccountDiffs []types.RawAccountDiff
+	SetDBCalled        bool
+}
+
+func (repository *MockAccountRepository) Create(diffID, headerID int64, diff types.RawAccountDiff) error {
+	repository.PassedDiffIDs = append(repository.PassedDiffIDs, diffID)
+	repository.PassedHeaderIDs = append(repository.PassedHeaderIDs, headerID)
+	repository.PassedAccountDiffs = append(repository.PassedAccountDiffs, diff)
+	return repository.CreateErr
+}
+
+func (repository *MockAccountRepository) SetDB(db *postgres.DB) {
+	repository.SetDBCalled = true
+}
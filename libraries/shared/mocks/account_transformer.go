@@ -0,0 +1,43 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package mocks
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/makerdao/vulcanizedb/libraries/shared/factories/account"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres"
+)
+
+type MockAccountTransformer struct {
+	Address     common.Address
+	ExecuteErr  error
+	PassedDiffs []types.PersistedAccountDiff
+}
+
+func (transformer *MockAccountTransformer) Execute(diff types.PersistedAccountDiff) error {
+	transformer.PassedDiffs = append(transformer.PassedDiffs, diff)
+	return transformer.ExecuteErr
+}
+
+func (transformer *MockAccountTransformer) GetContractAddress() common.Address {
+	return transformer.Address
+}
+
+func (transformer *MockAccountTransformer) FakeTransformerInitializer(db *postgres.DB) account.ITransformer {
+	return transformer
+}
@@ -0,0 +1,51 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package mocks
+
+import (
+	"encoding/json"
+
+	"github.com/makerdao/vulcanizedb/libraries/shared/retention"
+)
+
+type MockArchive struct {
+	WrittenTables []string
+	WrittenRows   [][]retention.Row
+	WriteErr      error
+	Files         []retention.File
+	ListErr       error
+	ListedRanges  [][2]int64
+	FileRows      map[string][]json.RawMessage
+	ReadFiles     []retention.File
+	ReadErr       error
+}
+
+func (archive *MockArchive) Write(table string, rows []retention.Row) (retention.File, error) {
+	archive.WrittenTables = append(archive.WrittenTables, table)
+	archive.WrittenRows = append(archive.WrittenRows, rows)
+	return retention.File{}, archive.WriteErr
+}
+
+func (archive *MockArchive) List(table string, startingBlock, endingBlock int64) ([]retention.File, error) {
+	archive.ListedRanges = append(archive.ListedRanges, [2]int64{startingBlock, endingBlock})
+	return archive.Files, archive.ListErr
+}
+
+func (archive *MockArchive) Read(file retention.File) ([]json.RawMessage, error) {
+	archive.ReadFiles = append(archive.ReadFiles, file)
+	return archive.FileRows[file.Path], archive.ReadErr
+}
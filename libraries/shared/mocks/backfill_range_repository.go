@@ -0,0 +1,104 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package mocks
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/backfill"
+	"github.com/makerdao/vulcanizedb/pkg/core"
+)
+
+type CreateRangesCall struct {
+	Job           string
+	StartingBlock int64
+	EndingBlock   int64
+	RangeSize     int64
+}
+
+type MockRangeRepository struct {
+	CreateRangesCalls      []CreateRangesCall
+	CreateRangesErr        error
+	IncompleteRanges       []backfill.Range
+	GetIncompleteRangesErr error
+	UpdateLastBlockErr     error
+	mutex                  sync.Mutex
+	lastBlocks             map[int64][]int64
+}
+
+func (repository *MockRangeRepository) CreateRanges(job string, startingBlock, endingBlock, rangeSize int64) error {
+	repository.CreateRangesCalls = append(repository.CreateRangesCalls, CreateRangesCall{
+		Job:           job,
+		StartingBlock: startingBlock,
+		EndingBlock:   endingBlock,
+		RangeSize:     rangeSize,
+	})
+	return repository.CreateRangesErr
+}
+
+func (repository *MockRangeRepository) GetIncompleteRanges(job string, startingBlock, endingBlock int64) ([]backfill.Range, error) {
+	return repository.IncompleteRanges, repository.GetIncompleteRangesErr
+}
+
+func (repository *MockRangeRepository) UpdateLastBlock(id, lastBlock int64) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	if repository.lastBlocks == nil {
+		repository.lastBlocks = make(map[int64][]int64)
+	}
+	repository.lastBlocks[id] = append(repository.lastBlocks[id], lastBlock)
+	return repository.UpdateLastBlockErr
+}
+
+// UpdatedLastBlocks returns the last blocks recorded for the range, in order
+func (repository *MockRangeRepository) UpdatedLastBlocks(id int64) []int64 {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	return repository.lastBlocks[id]
+}
+
+type MockLogHeaderRepository struct {
+	HeadersToReturn       []core.Header
+	GetHeadersWithLogsErr error
+	PassedAddresses       []common.Address
+	PassedStartingBlocks  []int64
+	PassedEndingBlocks    []int64
+}
+
+func (repository *MockLogHeaderRepository) GetHeadersWithLogs(addresses []common.Address, startingBlock, endingBlock int64) ([]core.Header, error) {
+	repository.PassedAddresses = addresses
+	repository.PassedStartingBlocks = append(repository.PassedStartingBlocks, startingBlock)
+	repository.PassedEndingBlocks = append(repository.PassedEndingBlocks, endingBlock)
+	return repository.HeadersToReturn, repository.GetHeadersWithLogsErr
+}
+
+type MockStorageValueRepository struct {
+	Values                map[common.Hash]common.Hash
+	GetStorageValuesAtErr error
+	PassedAddresses       []common.Address
+	PassedBlockHeights    []int64
+	mutex                 sync.Mutex
+}
+
+func (repository *MockStorageValueRepository) GetStorageValuesAt(address common.Address, blockHeight int64) (map[common.Hash]common.Hash, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	repository.PassedAddresses = append(repository.PassedAddresses, address)
+	repository.PassedBlockHeights = append(repository.PassedBlockHeights, blockHeight)
+	return repository.Values, repository.GetStorageValuesAtErr
+}
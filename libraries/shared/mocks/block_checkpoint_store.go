@@ -0,0 +1,44 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package mocks
+
+import "sync"
+
+type MockBlockCheckpointStore struct {
+	LastBlockToReturn int64
+	LoadLastBlockErr  error
+	SaveLastBlockErr  error
+	savedBlocks       []int64
+	mutex             sync.Mutex
+}
+
+func (store *MockBlockCheckpointStore) LoadLastBlock() (int64, error) {
+	return store.LastBlockToReturn, store.LoadLastBlockErr
+}
+
+func (store *MockBlockCheckpointStore) SaveLastBlock(blockNumber int64) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.savedBlocks = append(store.savedBlocks, blockNumber)
+	return store.SaveLastBlockErr
+}
+
+func (store *MockBlockCheckpointStore) SavedBlocks() []int64 {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	return append([]int64{}, store.savedBlocks...)
+}
@@ -0,0 +1,42 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package mocks
+
+type GapRange struct {
+	StartingBlock int64
+	EndingBlock   int64
+}
+
+type MockGapFiller struct {
+	FillGapPassedRanges []GapRange
+	FillGapError        error
+	// FillGapErrors are returned by successive calls before falling back to FillGapError
+	FillGapErrors []error
+}
+
+func (filler *MockGapFiller) FillGap(startingBlock, endingBlock int64) error {
+	filler.FillGapPassedRanges = append(filler.FillGapPassedRanges, GapRange{
+		StartingBlock: startingBlock,
+		EndingBlock:   endingBlock,
+	})
+	if len(filler.FillGapErrors) > 0 {
+		err := filler.FillGapErrors[0]
+		filler.FillGapErrors = filler.FillGapErrors[1:]
+		return err
+	}
+	return filler.FillGapError
+}
@@ -0,0 +1,56 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package mocks
+
+import (
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
+)
+
+type GetDiffsToVerifyCall struct {
+	StartingBlock int64
+	EndingBlock   int64
+	SampleSize    int
+}
+
+type MockProofRepository struct {
+	Diffs                 []types.PersistedDiff
+	GetDiffsToVerifyCalls []GetDiffsToVerifyCall
+	GetDiffsToVerifyErr   error
+	FlaggedIDs            []int64
+	FlagMismatchErr       error
+}
+
+// GetDiffsToVerify returns the diffs at blocks in the range, in the order they were given
+func (repository *MockProofRepository) GetDiffsToVerify(startingBlock, endingBlock int64, sampleSize int) ([]types.PersistedDiff, error) {
+	repository.GetDiffsToVerifyCalls = append(repository.GetDiffsToVerifyCalls, GetDiffsToVerifyCall{
+		StartingBlock: startingBlock,
+		EndingBlock:   endingBlock,
+		SampleSize:    sampleSize,
+	})
+	var diffs []types.PersistedDiff
+	for _, diff := range repository.Diffs {
+		if int64(diff.BlockHeight) >= startingBlock && int64(diff.BlockHeight) <= endingBlock {
+			diffs = append(diffs, diff)
+		}
+	}
+	return diffs, repository.GetDiffsToVerifyErr
+}
+
+func (repository *MockProofRepository) FlagMismatch(diffID int64) error {
+	repository.FlaggedIDs = append(repository.FlaggedIDs, diffID)
+	return repository.FlagMismatchErr
+}
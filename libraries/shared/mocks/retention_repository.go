@@ -0,0 +1,94 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package mocks
+
+import (
+	"encoding/json"
+
+	"github.com/makerdao/vulcanizedb/libraries/shared/retention"
+)
+
+type GetRetentionBatchCall struct {
+	Policy   retention.Policy
+	MaxBlock int64
+	AfterID  int64
+	Limit    int
+}
+
+type RestoreRetentionCall struct {
+	Table         string
+	Rows          []json.RawMessage
+	StartingBlock int64
+	EndingBlock   int64
+	Replay        bool
+}
+
+type MockRetentionRepository struct {
+	Batches        [][]retention.Row
+	GetBatchCalls  []GetRetentionBatchCall
+	GetBatchErr    error
+	DeletedIDs     [][]int64
+	DeleteErr      error
+	StrippedIDs    [][]int64
+	StripRawErr    error
+	RestoreCalls   []RestoreRetentionCall
+	RestoreErr     error
+	RestoredCounts []int64
+}
+
+// GetBatch returns the next of Batches on each call, and no rows once they run out
+func (repository *MockRetentionRepository) GetBatch(policy retention.Policy, maxBlock, afterID int64, limit int) ([]retention.Row, error) {
+	repository.GetBatchCalls = append(repository.GetBatchCalls, GetRetentionBatchCall{
+		Policy:   policy,
+		MaxBlock: maxBlock,
+		AfterID:  afterID,
+		Limit:    limit,
+	})
+	if repository.GetBatchErr != nil || len(repository.Batches) == 0 {
+		return nil, repository.GetBatchErr
+	}
+	batch := repository.Batches[0]
+	repository.Batches = repository.Batches[1:]
+	return batch, nil
+}
+
+func (repository *MockRetentionRepository) Delete(table string, ids []int64) (int64, error) {
+	repository.DeletedIDs = append(repository.DeletedIDs, ids)
+	return int64(len(ids)), repository.DeleteErr
+}
+
+func (repository *MockRetentionRepository) StripRaw(ids []int64) (int64, error) {
+	repository.StrippedIDs = append(repository.StrippedIDs, ids)
+	return int64(len(ids)), repository.StripRawErr
+}
+
+// Restore returns the next of RestoredCounts, or the number of rows passed once they run out
+func (repository *MockRetentionRepository) Restore(table string, rows []json.RawMessage, startingBlock, endingBlock int64, replay bool) (int64, error) {
+	repository.RestoreCalls = append(repository.RestoreCalls, RestoreRetentionCall{
+		Table:         table,
+		Rows:          rows,
+		StartingBlock: startingBlock,
+		EndingBlock:   endingBlock,
+		Replay:        replay,
+	})
+	count := int64(len(rows))
+	if len(repository.RestoredCounts) > 0 {
+		count = repository.RestoredCounts[0]
+		repository.RestoredCounts = repository.RestoredCounts[1:]
+	}
+	return count, repository.RestoreErr
+}
@@ -0,0 +1,69 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package mocks
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/makerdao/vulcanizedb/pkg/core"
+)
+
+// MockRpcClient unmarshals canned JSON responses into call results, keyed by RPC method
+type MockRpcClient struct {
+	CallContextErr       error
+	CallContextErrTimes  int // If positive, CallContextErr is only returned by that many calls
+	CallContextResponses map[string][]string
+	PassedMethods        []string
+	PassedArgs           [][]interface{}
+}
+
+func (client *MockRpcClient) CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	client.PassedMethods = append(client.PassedMethods, method)
+	client.PassedArgs = append(client.PassedArgs, args)
+	if client.CallContextErr != nil {
+		err := client.CallContextErr
+		if client.CallContextErrTimes > 0 {
+			client.CallContextErrTimes--
+			if client.CallContextErrTimes == 0 {
+				client.CallContextErr = nil
+			}
+		}
+		return err
+	}
+	responses := client.CallContextResponses[method]
+	if len(responses) == 0 {
+		return nil
+	}
+	response := responses[0]
+	if len(responses) > 1 {
+		client.CallContextResponses[method] = responses[1:]
+	}
+	return json.Unmarshal([]byte(response), result)
+}
+
+func (client *MockRpcClient) BatchCall(batch []core.BatchElem) error {
+	panic("implement me")
+}
+
+func (client *MockRpcClient) IpcPath() string {
+	panic("implement me")
+}
+
+func (client *MockRpcClient) Subscribe(namespace string, payloadChan interface{}, args ...interface{}) (core.Subscription, error) {
+	panic("implement me")
+}
@@ -17,6 +17,10 @@
 package mocks
 
 import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage"
 	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
 )
 
@@ -24,6 +28,9 @@ type MockStorageDiffRepository struct {
 	CreateBackFilledStorageValuePassedRawDiffs []types.RawDiff
 	CreateBackFilledStorageValueReturnError    error
 	CreatePassedRawDiffs                       []types.RawDiff
+	CreateStorageDiffErrs                      []error
+	CreateStorageDiffsPassedBatches            [][]types.RawDiff
+	CreateStorageDiffsErr                      error
 	GetNewDiffsDiffs                           []types.PersistedDiff
 	GetNewDiffsErrors                          []error
 	GetNewDiffsPassedMinIDs                    []int
@@ -32,17 +39,48 @@ type MockStorageDiffRepository struct {
 	MarkUnrecognizedPassedID                   int64
 	MarkNoncanonicalPassedID                   int64
 	MarkUnwatchedPassedID                      int64
-	GetFirstDiffIDToReturn                     int64
-	GetFirstDiffIDErr                          error
-	GetFirstDiffBlockHeightPassed              int64
+	MarkPendingHeaderPassedIDs                 []int64
+	HasPendingHeaderPredecessorPassedDiffs     []types.PersistedDiff
+	HasPendingHeaderPredecessorToReturn        bool
+	HasPendingHeaderPredecessorErr             error
+	ReleasePendingHeaderDiffsPassedAddresses   []common.Address
+	ReleasePendingHeaderDiffsHeightToReturn    int64
+	ReleasePendingHeaderDiffsErr               error
+	GetNewDiffsForAddressDiffs                 []types.PersistedDiff
+	GetNewDiffsForAddressErrors                []error
+	GetNewDiffsForAddressPassedAddresses       []common.Address
+	GetNewDiffsForAddressPassedCursors         []storage.DiffCursor
+	GetNewDiffsForAddressPassedLimits          []int
+	MarkUnwatchedExceptPassedAddresses         []common.Address
+	MarkUnwatchedExceptErr                     error
+	ResetDiffsPassedFilter                     storage.ResetFilter
+	ResetDiffsCountToReturn                    int64
+	ResetDiffsErr                              error
+	mutex                                      sync.Mutex
 }
 
 func (repository *MockStorageDiffRepository) CreateStorageDiff(rawDiff types.RawDiff) (int64, error) {
 	repository.CreatePassedRawDiffs = append(repository.CreatePassedRawDiffs, rawDiff)
+	if len(repository.CreateStorageDiffErrs) > 0 {
+		err := repository.CreateStorageDiffErrs[0]
+		repository.CreateStorageDiffErrs = repository.CreateStorageDiffErrs[1:]
+		return 0, err
+	}
 	return 0, nil
 }
 
+func (repository *MockStorageDiffRepository) CreateStorageDiffs(rawDiffs []types.RawDiff) (int64, error) {
+	repository.CreateStorageDiffsPassedBatches = append(repository.CreateStorageDiffsPassedBatches, rawDiffs)
+	if repository.CreateStorageDiffsErr != nil {
+		return 0, repository.CreateStorageDiffsErr
+	}
+	repository.CreatePassedRawDiffs = append(repository.CreatePassedRawDiffs, rawDiffs...)
+	return int64(len(rawDiffs)), nil
+}
+
 func (repository *MockStorageDiffRepository) CreateBackFilledStorageValue(rawDiff types.RawDiff) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
 	repository.CreateBackFilledStorageValuePassedRawDiffs = append(repository.CreateBackFilledStorageValuePassedRawDiffs, rawDiff)
 	return repository.CreateBackFilledStorageValueReturnError
 }
@@ -77,7 +115,38 @@ func (repository *MockStorageDiffRepository) MarkUnwatched(id int64) error {
 	return nil
 }
 
-func (repository *MockStorageDiffRepository) GetFirstDiffIDForBlockHeight(blockHeight int64) (int64, error) {
-	repository.GetFirstDiffBlockHeightPassed = blockHeight
-	return repository.GetFirstDiffIDToReturn, repository.GetFirstDiffIDErr
+func (repository *MockStorageDiffRepository) MarkPendingHeader(id int64) error {
+	repository.MarkPendingHeaderPassedIDs = append(repository.MarkPendingHeaderPassedIDs, id)
+	return nil
+}
+
+func (repository *MockStorageDiffRepository) HasPendingHeaderPredecessor(diff types.PersistedDiff) (bool, error) {
+	repository.HasPendingHeaderPredecessorPassedDiffs = append(repository.HasPendingHeaderPredecessorPassedDiffs, diff)
+	return repository.HasPendingHeaderPredecessorToReturn, repository.HasPendingHeaderPredecessorErr
+}
+
+func (repository *MockStorageDiffRepository) ReleasePendingHeaderDiffs(address common.Address) (int64, error) {
+	repository.ReleasePendingHeaderDiffsPassedAddresses = append(repository.ReleasePendingHeaderDiffsPassedAddresses, address)
+	return repository.ReleasePendingHeaderDiffsHeightToReturn, repository.ReleasePendingHeaderDiffsErr
+}
+
+func (repository *MockStorageDiffRepository) GetNewDiffsForAddress(address common.Address, cursor storage.DiffCursor, limit int) ([]types.PersistedDiff, error) {
+	repository.GetNewDiffsForAddressPassedAddresses = append(repository.GetNewDiffsForAddressPassedAddresses, address)
+	repository.GetNewDiffsForAddressPassedCursors = append(repository.GetNewDiffsForAddressPassedCursors, cursor)
+	repository.GetNewDiffsForAddressPassedLimits = append(repository.GetNewDiffsForAddressPassedLimits, limit)
+	err := repository.GetNewDiffsForAddressErrors[0]
+	if len(repository.GetNewDiffsForAddressErrors) > 1 {
+		repository.GetNewDiffsForAddressErrors = repository.GetNewDiffsForAddressErrors[1:]
+	}
+	return repository.GetNewDiffsForAddressDiffs, err
+}
+
+func (repository *MockStorageDiffRepository) MarkUnwatchedExcept(addresses []common.Address) (int64, error) {
+	repository.MarkUnwatchedExceptPassedAddresses = addresses
+	return 0, repository.MarkUnwatchedExceptErr
+}
+
+func (repository *MockStorageDiffRepository) ResetDiffs(filter storage.ResetFilter) (int64, error) {
+	repository.ResetDiffsPassedFilter = filter
+	return repository.ResetDiffsCountToReturn, repository.ResetDiffsErr
 }
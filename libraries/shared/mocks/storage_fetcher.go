@@ -24,6 +24,9 @@ type MockStorageFetcher struct {
 	DiffsToReturn           []types.RawDiff
 	ErrsToReturn            []error
 	FetchStorageDiffsCalled bool
+	// DiffsSent, if set, is closed once every diff has been received
+	DiffsSent             chan struct{}
+	DiffsFlushedCallCount int
 }
 
 func NewMockStorageFetcher() *MockStorageFetcher {
@@ -35,7 +38,14 @@ func (fetcher *MockStorageFetcher) FetchStorageDiffs(out chan<- types.RawDiff, e
 	for _, diff := range fetcher.DiffsToReturn {
 		out <- diff
 	}
+	if fetcher.DiffsSent != nil {
+		close(fetcher.DiffsSent)
+	}
 	for _, err := range fetcher.ErrsToReturn {
 		errs <- err
 	}
 }
+
+func (fetcher *MockStorageFetcher) DiffsFlushed() {
+	fetcher.DiffsFlushedCallCount++
+}
@@ -37,3 +37,17 @@ func (loader *MockStorageKeysLoader) LoadMappings() (map[common.Hash]types.Value
 func (loader *MockStorageKeysLoader) SetDB(db *postgres.DB) {
 	loader.SetDBCalled = true
 }
+
+type MockIncrementalStorageKeysLoader struct {
+	MockStorageKeysLoader
+	LoadMappingsSinceCallCount int
+	LoadMappingsSinceError     error
+	PassedWatermarks           []int64
+	WatermarkToReturn          int64
+}
+
+func (loader *MockIncrementalStorageKeysLoader) LoadMappingsSince(watermark int64) (map[common.Hash]types.ValueMetadata, int64, error) {
+	loader.LoadMappingsSinceCallCount++
+	loader.PassedWatermarks = append(loader.PassedWatermarks, watermark)
+	return loader.StorageKeyMappings, loader.WatermarkToReturn, loader.LoadMappingsSinceError
+}
@@ -18,22 +18,32 @@ package mocks
 
 import (
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/makerdao/vulcanizedb/libraries/shared/factories/storage"
 	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
 	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres"
 )
 
 type MockStorageKeysLookup struct {
 	Metadata      types.ValueMetadata
+	MetadataByKey map[common.Hash]types.ValueMetadata
 	LookupCalled  bool
 	LookupErr     error
 	KeysToReturn  []common.Hash
 	GetKeysCalled bool
 	GetKeysError  error
+	StatsToReturn storage.KeysLookupStats
 	db            *postgres.DB
 }
 
 func (lookup *MockStorageKeysLookup) Lookup(key common.Hash) (types.ValueMetadata, error) {
 	lookup.LookupCalled = true
+	if lookup.MetadataByKey != nil {
+		metadata, ok := lookup.MetadataByKey[key]
+		if !ok {
+			return metadata, types.ErrKeyNotFound
+		}
+		return metadata, lookup.LookupErr
+	}
 	return lookup.Metadata, lookup.LookupErr
 }
 
@@ -43,3 +53,7 @@ func (lookup *MockStorageKeysLookup) GetKeys() ([]common.Hash, error) {
 }
 
 func (lookup *MockStorageKeysLookup) SetDB(db *postgres.DB) {}
+
+func (lookup *MockStorageKeysLookup) Stats() storage.KeysLookupStats {
+	return lookup.StatsToReturn
+}
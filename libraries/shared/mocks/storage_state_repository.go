@@ -0,0 +1,67 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package mocks
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type CreateSnapshotCall struct {
+	Address     common.Address
+	BlockHeight int64
+}
+
+type MockStateRepository struct {
+	Storage                   map[common.Hash]common.Hash
+	GetContractStorageAtErr   error
+	GetStorageAtErr           error
+	PassedAddress             common.Address
+	PassedBlockHeight         int64
+	CreateSnapshotCalls       []CreateSnapshotCall
+	CreateSnapshotErr         error
+	LatestSnapshotBlocks      map[common.Address]int64
+	GetLatestSnapshotBlockErr error
+}
+
+func (repository *MockStateRepository) GetStorageAt(address common.Address, key common.Hash, blockHeight int64) (common.Hash, error) {
+	repository.PassedAddress = address
+	repository.PassedBlockHeight = blockHeight
+	return repository.Storage[key], repository.GetStorageAtErr
+}
+
+func (repository *MockStateRepository) GetContractStorageAt(address common.Address, blockHeight int64) (map[common.Hash]common.Hash, error) {
+	repository.PassedAddress = address
+	repository.PassedBlockHeight = blockHeight
+	return repository.Storage, repository.GetContractStorageAtErr
+}
+
+func (repository *MockStateRepository) CreateSnapshot(address common.Address, blockHeight int64) error {
+	repository.CreateSnapshotCalls = append(repository.CreateSnapshotCalls, CreateSnapshotCall{
+		Address:     address,
+		BlockHeight: blockHeight,
+	})
+	return repository.CreateSnapshotErr
+}
+
+// GetLatestSnapshotBlock returns -1 for addresses without a latest snapshot block
+func (repository *MockStateRepository) GetLatestSnapshotBlock(address common.Address) (int64, error) {
+	blockHeight, ok := repository.LatestSnapshotBlocks[address]
+	if !ok {
+		return -1, repository.GetLatestSnapshotBlockErr
+	}
+	return blockHeight, repository.GetLatestSnapshotBlockErr
+}
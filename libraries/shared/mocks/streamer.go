@@ -11,10 +11,17 @@ type MockStoragediffStreamer struct {
 	ClientSubscription *fakes.MockSubscription
 	PassedPayloadChan  chan filters.Payload
 	streamPayloads     []filters.Payload
+	StreamCallCount    int
 }
 
 func (streamer *MockStoragediffStreamer) Stream(statediffPayloadChan chan filters.Payload) (core.Subscription, error) {
+	streamer.StreamCallCount++
 	streamer.PassedPayloadChan = statediffPayloadChan
+	if streamer.subscribeError != nil {
+		err := streamer.subscribeError
+		streamer.subscribeError = nil
+		return nil, err
+	}
 
 	go func() {
 		for _, payload := range streamer.streamPayloads {
@@ -22,9 +29,10 @@ func (streamer *MockStoragediffStreamer) Stream(statediffPayloadChan chan filter
 		}
 	}()
 
-	return streamer.ClientSubscription, streamer.subscribeError
+	return streamer.ClientSubscription, nil
 }
 
+// SetSubscribeError causes the next call to Stream to fail with the given error
 func (streamer *MockStoragediffStreamer) SetSubscribeError(err error) {
 	streamer.subscribeError = err
 }
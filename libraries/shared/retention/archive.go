@@ -0,0 +1,172 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package retention
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/makerdao/vulcanizedb/pkg/fs"
+)
+
+// Row is an archived row: its id and block, and the whole row as JSON
+type Row struct {
+	ID          int64
+	BlockNumber int64 `db:"block_number"`
+	Data        json.RawMessage
+}
+
+// File is a batch of archived rows of a table, covering a range of blocks
+type File struct {
+	Path          string
+	StartingBlock int64
+	EndingBlock   int64
+	FirstID       int64
+}
+
+type Archive interface {
+	Write(table string, rows []Row) (File, error)
+	List(table string, startingBlock, endingBlock int64) ([]File, error)
+	Read(file File) ([]json.RawMessage, error)
+}
+
+// FileArchive keeps each batch as a gzipped JSON lines file named after its blocks, under a directory per table
+type FileArchive struct {
+	Directory string
+}
+
+func NewFileArchive(directory string) FileArchive {
+	return FileArchive{Directory: directory}
+}
+
+// Write saves the rows to a new file, which only appears under its final name once it's complete
+func (archive FileArchive) Write(table string, rows []Row) (File, error) {
+	if len(rows) == 0 {
+		return File{}, fmt.Errorf("no %s rows to archive", table)
+	}
+	file := File{StartingBlock: rows[0].BlockNumber, EndingBlock: rows[0].BlockNumber, FirstID: rows[0].ID}
+	for _, row := range rows {
+		if row.BlockNumber < file.StartingBlock {
+			file.StartingBlock = row.BlockNumber
+		}
+		if row.BlockNumber > file.EndingBlock {
+			file.EndingBlock = row.BlockNumber
+		}
+	}
+	tableDirectory := filepath.Join(archive.Directory, table)
+	mkdirErr := os.MkdirAll(tableDirectory, 0755)
+	if mkdirErr != nil {
+		return File{}, fmt.Errorf("error creating archive directory: %w", mkdirErr)
+	}
+	file.Path = filepath.Join(tableDirectory, fmt.Sprintf("%d-%d-%d.jsonl.gz", file.StartingBlock, file.EndingBlock,
+		file.FirstID))
+
+	tmp, createErr := ioutil.TempFile(tableDirectory, ".archive-")
+	if createErr != nil {
+		return File{}, fmt.Errorf("error creating archive file: %w", createErr)
+	}
+	defer os.Remove(tmp.Name())
+	writeErr := writeRows(tmp, rows)
+	closeErr := tmp.Close()
+	if writeErr != nil {
+		return File{}, fmt.Errorf("error writing archive file: %w", writeErr)
+	}
+	if closeErr != nil {
+		return File{}, fmt.Errorf("error closing archive file: %w", closeErr)
+	}
+	renameErr := os.Rename(tmp.Name(), file.Path)
+	if renameErr != nil {
+		return File{}, fmt.Errorf("error saving archive file: %w", renameErr)
+	}
+	return file, nil
+}
+
+func writeRows(file *os.File, rows []Row) error {
+	compressed := gzip.NewWriter(file)
+	for _, row := range rows {
+		if _, err := compressed.Write(row.Data); err != nil {
+			return err
+		}
+		if _, err := compressed.Write([]byte{'\n'}); err != nil {
+			return err
+		}
+	}
+	if err := compressed.Close(); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// List returns the table's files with blocks in the range, ordered by block
+func (archive FileArchive) List(table string, startingBlock, endingBlock int64) ([]File, error) {
+	paths, globErr := filepath.Glob(filepath.Join(archive.Directory, table, "*.jsonl.gz"))
+	if globErr != nil {
+		return nil, fmt.Errorf("error listing archive files: %w", globErr)
+	}
+	var files []File
+	for _, path := range paths {
+		file := File{Path: path}
+		_, scanErr := fmt.Sscanf(filepath.Base(path), "%d-%d-%d.jsonl.gz", &file.StartingBlock, &file.EndingBlock,
+			&file.FirstID)
+		if scanErr != nil {
+			return nil, fmt.Errorf("unexpected archive file %s: %w", path, scanErr)
+		}
+		if file.EndingBlock < startingBlock || file.StartingBlock > endingBlock {
+			continue
+		}
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].StartingBlock != files[j].StartingBlock {
+			return files[i].StartingBlock < files[j].StartingBlock
+		}
+		return files[i].FirstID < files[j].FirstID
+	})
+	return files, nil
+}
+
+func (archive FileArchive) Read(file File) ([]json.RawMessage, error) {
+	reader, openErr := fs.OpenDecompressed(file.Path)
+	if openErr != nil {
+		return nil, fmt.Errorf("error opening archive file: %w", openErr)
+	}
+	defer reader.Close()
+
+	var rows []json.RawMessage
+	buffered := bufio.NewReader(reader)
+	for {
+		line, readErr := buffered.ReadBytes('\n')
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			rows = append(rows, line)
+		}
+		if readErr == io.EOF {
+			return rows, nil
+		}
+		if readErr != nil {
+			return nil, fmt.Errorf("error reading archive file %s: %w", file.Path, readErr)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package retention_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/makerdao/vulcanizedb/libraries/shared/retention"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("File archive", func() {
+	var (
+		directory string
+		archive   retention.FileArchive
+		rows      []retention.Row
+	)
+
+	BeforeEach(func() {
+		var err error
+		directory, err = ioutil.TempDir("", "archive")
+		Expect(err).NotTo(HaveOccurred())
+		archive = retention.NewFileArchive(directory)
+		rows = []retention.Row{
+			{ID: 7, BlockNumber: 120, Data: json.RawMessage(`{"id":7,"block_height":120}`)},
+			{ID: 8, BlockNumber: 100, Data: json.RawMessage(`{"id":8,"block_height":100}`)},
+		}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(directory)).To(Succeed())
+	})
+
+	It("writes rows to a file named after their blocks and first id", func() {
+		file, err := archive.Write(retention.StorageDiffTable, rows)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(file).To(Equal(retention.File{
+			Path:          filepath.Join(directory, retention.StorageDiffTable, "100-120-7.jsonl.gz"),
+			StartingBlock: 100,
+			EndingBlock:   120,
+			FirstID:       7,
+		}))
+		Expect(file.Path).To(BeAnExistingFile())
+	})
+
+	It("reads back the written rows", func() {
+		file, writeErr := archive.Write(retention.StorageDiffTable, rows)
+		Expect(writeErr).NotTo(HaveOccurred())
+
+		read, err := archive.Read(file)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(read).To(Equal([]json.RawMessage{rows[0].Data, rows[1].Data}))
+	})
+
+	It("returns an error when there are no rows to write", func() {
+		_, err := archive.Write(retention.StorageDiffTable, nil)
+
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("lists the table's files overlapping a block range in block order", func() {
+		later, writeErr := archive.Write(retention.StorageDiffTable, []retention.Row{
+			{ID: 20, BlockNumber: 300, Data: json.RawMessage(`{}`)},
+		})
+		Expect(writeErr).NotTo(HaveOccurred())
+		earlier, writeErr := archive.Write(retention.StorageDiffTable, rows)
+		Expect(writeErr).NotTo(HaveOccurred())
+		_, writeErr = archive.Write(retention.StorageDiffTable, []retention.Row{
+			{ID: 30, BlockNumber: 500, Data: json.RawMessage(`{}`)},
+		})
+		Expect(writeErr).NotTo(HaveOccurred())
+		_, writeErr = archive.Write(retention.EventLogsTable, rows)
+		Expect(writeErr).NotTo(HaveOccurred())
+
+		files, err := archive.List(retention.StorageDiffTable, 110, 400)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(Equal([]retention.File{earlier, later}))
+	})
+
+	It("lists no files for a table that hasn't been archived", func() {
+		files, err := archive.List(retention.EventLogsTable, 0, 1000)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(BeEmpty())
+	})
+})
@@ -0,0 +1,98 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package retention
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage"
+)
+
+const (
+	StorageDiffTable = "storage_diff"
+	EventLogsTable   = "event_logs"
+
+	// ArchiveAction exports rows to the archive and then deletes them
+	ArchiveAction = "archive"
+	// StripRawAction exports event logs to the archive and then clears their raw JSON, keeping the rows
+	StripRawAction = "stripRaw"
+
+	Transformed   = "transformed"
+	Untransformed = "untransformed"
+)
+
+var (
+	ErrUnknownTable  = errors.New("unknown retention table")
+	ErrUnknownAction = errors.New("unknown retention action")
+	ErrInvalidStatus = errors.New("invalid retention status")
+
+	// statuses of rows that are safe to archive; new, pending and unrecognized diffs are still being processed
+	tableStatuses = map[string][]string{
+		StorageDiffTable: {storage.Transformed, storage.Unwatched, storage.Noncanonical, storage.Abandoned},
+		EventLogsTable:   {Transformed, Untransformed},
+	}
+)
+
+// Policy selects rows of a table to archive: rows in one of the statuses, at least MinAge blocks behind the latest
+// header, and (if any are given) from one of the addresses
+type Policy struct {
+	Name      string
+	Table     string
+	Statuses  []string
+	MinAge    int64
+	Addresses []string
+	Action    string
+}
+
+func (policy Policy) Validate() error {
+	allowedStatuses, ok := tableStatuses[policy.Table]
+	if !ok {
+		return fmt.Errorf("policy %s: %w: %q", policy.Name, ErrUnknownTable, policy.Table)
+	}
+	switch policy.Action {
+	case ArchiveAction:
+	case StripRawAction:
+		if policy.Table != EventLogsTable {
+			return fmt.Errorf("policy %s: %w: %s only applies to %s", policy.Name, ErrUnknownAction, StripRawAction,
+				EventLogsTable)
+		}
+	default:
+		return fmt.Errorf("policy %s: %w: %q", policy.Name, ErrUnknownAction, policy.Action)
+	}
+	if len(policy.Statuses) == 0 {
+		return fmt.Errorf("policy %s: %w: at least one status is required", policy.Name, ErrInvalidStatus)
+	}
+	for _, status := range policy.Statuses {
+		if !contains(allowedStatuses, status) {
+			return fmt.Errorf("policy %s: %w: %q is not one of %v", policy.Name, ErrInvalidStatus, status, allowedStatuses)
+		}
+	}
+	if policy.MinAge < 0 {
+		return fmt.Errorf("policy %s: minimum age can't be negative", policy.Name)
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package retention_test
+
+import (
+	"github.com/makerdao/vulcanizedb/libraries/shared/retention"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Retention policy", func() {
+	var policy retention.Policy
+
+	BeforeEach(func() {
+		policy = retention.Policy{
+			Name:     "old-diffs",
+			Table:    retention.StorageDiffTable,
+			Statuses: []string{storage.Transformed, storage.Unwatched},
+			MinAge:   100000,
+			Action:   retention.ArchiveAction,
+		}
+	})
+
+	It("accepts a valid policy", func() {
+		Expect(policy.Validate()).To(Succeed())
+	})
+
+	It("rejects an unknown table", func() {
+		policy.Table = "headers"
+
+		Expect(policy.Validate()).To(MatchError(retention.ErrUnknownTable))
+	})
+
+	It("rejects an unknown action", func() {
+		policy.Action = "drop"
+
+		Expect(policy.Validate()).To(MatchError(retention.ErrUnknownAction))
+	})
+
+	It("only strips raw JSON from event logs", func() {
+		policy.Action = retention.StripRawAction
+
+		Expect(policy.Validate()).To(MatchError(retention.ErrUnknownAction))
+
+		policy.Table = retention.EventLogsTable
+		policy.Statuses = []string{retention.Transformed}
+		Expect(policy.Validate()).To(Succeed())
+	})
+
+	It("requires a status", func() {
+		policy.Statuses = nil
+
+		Expect(policy.Validate()).To(MatchError(retention.ErrInvalidStatus))
+	})
+
+	It("rejects statuses of rows that are still being processed", func() {
+		policy.Statuses = []string{storage.New}
+
+		Expect(policy.Validate()).To(MatchError(retention.ErrInvalidStatus))
+	})
+
+	It("rejects unrecognized diffs, which are still retried", func() {
+		policy.Statuses = []string{storage.Unrecognized}
+
+		Expect(policy.Validate()).To(MatchError(retention.ErrInvalidStatus))
+	})
+
+	It("rejects diff statuses for event logs", func() {
+		policy.Table = retention.EventLogsTable
+
+		Expect(policy.Validate()).To(MatchError(retention.ErrInvalidStatus))
+	})
+
+	It("rejects a negative minimum age", func() {
+		policy.MinAge = -1
+
+		Expect(policy.Validate()).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,122 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package retention
+
+import (
+	"fmt"
+
+	"github.com/makerdao/vulcanizedb/pkg/datastore"
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres"
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres/repositories"
+)
+
+var DefaultBatchSize = 10000
+
+// Result counts the rows handled by a retention policy
+type Result struct {
+	Archived int64
+	Deleted  int64
+	Stripped int64
+	Files    int
+}
+
+// Pruner applies retention policies, writing each batch of matching rows to the archive before deleting them (or
+// stripping their raw JSON), so that no row is removed without an archived copy
+type Pruner struct {
+	Repository       Repository
+	HeaderRepository datastore.HeaderRepository
+	Archive          Archive
+	BatchSize        int
+}
+
+func NewPruner(db *postgres.DB, archive Archive, batchSize int) Pruner {
+	if batchSize < 1 {
+		batchSize = DefaultBatchSize
+	}
+	return Pruner{
+		Repository:       NewRepository(db),
+		HeaderRepository: repositories.NewHeaderRepository(db),
+		Archive:          archive,
+		BatchSize:        batchSize,
+	}
+}
+
+func (pruner Pruner) Run(policies []Policy) (map[string]Result, error) {
+	for _, policy := range policies {
+		validationErr := policy.Validate()
+		if validationErr != nil {
+			return nil, validationErr
+		}
+	}
+	head, headErr := pruner.HeaderRepository.GetMostRecentHeaderBlockNumber()
+	if headErr != nil {
+		return nil, fmt.Errorf("error getting latest header for retention: %w", headErr)
+	}
+
+	results := make(map[string]Result, len(policies))
+	for _, policy := range policies {
+		result, err := pruner.apply(policy, head-policy.MinAge)
+		results[policy.Name] = result
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+func (pruner Pruner) apply(policy Policy, maxBlock int64) (Result, error) {
+	var result Result
+	if maxBlock < 0 {
+		return result, nil
+	}
+	var afterID int64
+	for {
+		rows, batchErr := pruner.Repository.GetBatch(policy, maxBlock, afterID, pruner.BatchSize)
+		if batchErr != nil {
+			return result, batchErr
+		}
+		if len(rows) == 0 {
+			return result, nil
+		}
+
+		_, writeErr := pruner.Archive.Write(policy.Table, rows)
+		if writeErr != nil {
+			return result, writeErr
+		}
+		result.Files++
+		result.Archived += int64(len(rows))
+
+		ids := make([]int64, len(rows))
+		for i, row := range rows {
+			ids[i] = row.ID
+		}
+		if policy.Action == StripRawAction {
+			stripped, stripErr := pruner.Repository.StripRaw(ids)
+			if stripErr != nil {
+				return result, stripErr
+			}
+			result.Stripped += stripped
+		} else {
+			deleted, deleteErr := pruner.Repository.Delete(policy.Table, ids)
+			if deleteErr != nil {
+				return result, deleteErr
+			}
+			result.Deleted += deleted
+		}
+		afterID = ids[len(ids)-1]
+	}
+}
@@ -0,0 +1,166 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package retention_test
+
+import (
+	"encoding/json"
+
+	"github.com/makerdao/vulcanizedb/libraries/shared/mocks"
+	"github.com/makerdao/vulcanizedb/libraries/shared/retention"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage"
+	"github.com/makerdao/vulcanizedb/pkg/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Pruner", func() {
+	var (
+		repository       *mocks.MockRetentionRepository
+		headerRepository *fakes.MockHeaderRepository
+		archive          *mocks.MockArchive
+		pruner           retention.Pruner
+		policy           retention.Policy
+		firstBatch       []retention.Row
+		secondBatch      []retention.Row
+	)
+
+	BeforeEach(func() {
+		repository = &mocks.MockRetentionRepository{}
+		headerRepository = fakes.NewMockHeaderRepository()
+		headerRepository.MostRecentHeaderBlockNumber = 1000
+		archive = &mocks.MockArchive{}
+		pruner = retention.Pruner{
+			Repository:       repository,
+			HeaderRepository: headerRepository,
+			Archive:          archive,
+			BatchSize:        2,
+		}
+		policy = retention.Policy{
+			Name:     "old-diffs",
+			Table:    retention.StorageDiffTable,
+			Statuses: []string{storage.Transformed},
+			MinAge:   100,
+			Action:   retention.ArchiveAction,
+		}
+		firstBatch = []retention.Row{
+			{ID: 1, BlockNumber: 10, Data: json.RawMessage(`{"id":1}`)},
+			{ID: 3, BlockNumber: 11, Data: json.RawMessage(`{"id":3}`)},
+		}
+		secondBatch = []retention.Row{{ID: 4, BlockNumber: 12, Data: json.RawMessage(`{"id":4}`)}}
+		repository.Batches = [][]retention.Row{firstBatch, secondBatch}
+	})
+
+	It("gets batches of rows older than the policy's minimum age", func() {
+		_, err := pruner.Run([]retention.Policy{policy})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(repository.GetBatchCalls).To(Equal([]mocks.GetRetentionBatchCall{
+			{Policy: policy, MaxBlock: 900, AfterID: 0, Limit: 2},
+			{Policy: policy, MaxBlock: 900, AfterID: 3, Limit: 2},
+			{Policy: policy, MaxBlock: 900, AfterID: 4, Limit: 2},
+		}))
+	})
+
+	It("archives each batch and then deletes it", func() {
+		results, err := pruner.Run([]retention.Policy{policy})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(archive.WrittenTables).To(Equal([]string{retention.StorageDiffTable, retention.StorageDiffTable}))
+		Expect(archive.WrittenRows).To(Equal([][]retention.Row{firstBatch, secondBatch}))
+		Expect(repository.DeletedIDs).To(Equal([][]int64{{1, 3}, {4}}))
+		Expect(repository.StrippedIDs).To(BeEmpty())
+		Expect(results).To(Equal(map[string]retention.Result{
+			"old-diffs": {Archived: 3, Deleted: 3, Files: 2},
+		}))
+	})
+
+	It("strips raw JSON instead of deleting for stripRaw policies", func() {
+		policy.Table = retention.EventLogsTable
+		policy.Statuses = []string{retention.Transformed}
+		policy.Action = retention.StripRawAction
+
+		results, err := pruner.Run([]retention.Policy{policy})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(repository.StrippedIDs).To(Equal([][]int64{{1, 3}, {4}}))
+		Expect(repository.DeletedIDs).To(BeEmpty())
+		Expect(results["old-diffs"]).To(Equal(retention.Result{Archived: 3, Stripped: 3, Files: 2}))
+	})
+
+	It("does nothing when the chain is younger than the minimum age", func() {
+		policy.MinAge = 2000
+
+		results, err := pruner.Run([]retention.Policy{policy})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(repository.GetBatchCalls).To(BeEmpty())
+		Expect(results["old-diffs"]).To(Equal(retention.Result{}))
+	})
+
+	It("validates every policy before pruning", func() {
+		invalid := retention.Policy{Name: "invalid", Table: "headers"}
+
+		_, err := pruner.Run([]retention.Policy{policy, invalid})
+
+		Expect(err).To(MatchError(retention.ErrUnknownTable))
+		Expect(repository.GetBatchCalls).To(BeEmpty())
+	})
+
+	It("returns an error if getting the latest header fails", func() {
+		headerRepository.MostRecentHeaderBlockNumberErr = fakes.FakeError
+
+		_, err := pruner.Run([]retention.Policy{policy})
+
+		Expect(err).To(MatchError(fakes.FakeError))
+	})
+
+	It("returns an error if getting a batch fails", func() {
+		repository.GetBatchErr = fakes.FakeError
+
+		_, err := pruner.Run([]retention.Policy{policy})
+
+		Expect(err).To(MatchError(fakes.FakeError))
+	})
+
+	It("doesn't delete rows if archiving them fails", func() {
+		archive.WriteErr = fakes.FakeError
+
+		_, err := pruner.Run([]retention.Policy{policy})
+
+		Expect(err).To(MatchError(fakes.FakeError))
+		Expect(repository.DeletedIDs).To(BeEmpty())
+	})
+
+	It("returns an error if deleting fails", func() {
+		repository.DeleteErr = fakes.FakeError
+
+		_, err := pruner.Run([]retention.Policy{policy})
+
+		Expect(err).To(MatchError(fakes.FakeError))
+	})
+
+	It("returns an error if stripping raw JSON fails", func() {
+		policy.Table = retention.EventLogsTable
+		policy.Statuses = []string{retention.Transformed}
+		policy.Action = retention.StripRawAction
+		repository.StripRawErr = fakes.FakeError
+
+		_, err := pruner.Run([]retention.Policy{policy})
+
+		Expect(err).To(MatchError(fakes.FakeError))
+	})
+})
@@ -0,0 +1,280 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package retention
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres"
+	"github.com/sirupsen/logrus"
+)
+
+type Repository interface {
+	GetBatch(policy Policy, maxBlock, afterID int64, limit int) ([]Row, error)
+	Delete(table string, ids []int64) (int64, error)
+	StripRaw(ids []int64) (int64, error)
+	Restore(table string, rows []json.RawMessage, startingBlock, endingBlock int64, replay bool) (int64, error)
+}
+
+// blockColumns are the columns holding the block number of each table's rows
+var blockColumns = map[string]string{
+	StorageDiffTable: "block_height",
+	EventLogsTable:   "block_number",
+}
+
+type repository struct {
+	db *postgres.DB
+}
+
+func NewRepository(db *postgres.DB) repository {
+	return repository{db: db}
+}
+
+type batchRow struct {
+	ID          int64
+	BlockNumber int64 `db:"block_number"`
+	Data        []byte
+}
+
+// GetBatch returns rows matching the policy at or before maxBlock with ids after afterID, ordered by id. Rows that are
+// still referenced by transformed data (e.g. an event log with a row in an event table) are never archived for
+// deletion, since deleting them would cascade to the transformed data.
+func (repository repository) GetBatch(policy Policy, maxBlock, afterID int64, limit int) ([]Row, error) {
+	blockColumn := blockColumns[policy.Table]
+	conditions := []string{"t.id > $1", fmt.Sprintf("t.%s <= $2", blockColumn)}
+	args := []interface{}{afterID, maxBlock}
+
+	switch policy.Table {
+	case StorageDiffTable:
+		args = append(args, pq.StringArray(policy.Statuses))
+		conditions = append(conditions, fmt.Sprintf("t.status = ANY($%d::public.diff_status[])", len(args)))
+	case EventLogsTable:
+		transformed, untransformed := contains(policy.Statuses, Transformed), contains(policy.Statuses, Untransformed)
+		if transformed && !untransformed {
+			conditions = append(conditions, "t.transformed")
+		} else if untransformed && !transformed {
+			conditions = append(conditions, "NOT t.transformed")
+		}
+	}
+
+	if len(policy.Addresses) > 0 {
+		switch policy.Table {
+		case StorageDiffTable:
+			addresses := make([][]byte, len(policy.Addresses))
+			for i, address := range policy.Addresses {
+				addresses[i] = common.HexToAddress(address).Bytes()
+			}
+			args = append(args, pq.ByteaArray(addresses))
+			conditions = append(conditions, fmt.Sprintf("t.address = ANY($%d::BYTEA[])", len(args)))
+		case EventLogsTable:
+			addresses := make([]string, len(policy.Addresses))
+			for i, address := range policy.Addresses {
+				addresses[i] = common.HexToAddress(address).Hex()
+			}
+			args = append(args, pq.StringArray(addresses))
+			conditions = append(conditions, fmt.Sprintf(
+				"t.address IN (SELECT id FROM public.addresses WHERE address = ANY($%d::VARCHAR[]))", len(args)))
+		}
+	}
+
+	if policy.Action == StripRawAction {
+		conditions = append(conditions, "t.raw IS NOT NULL")
+	} else {
+		unreferenced, referencesErr := repository.unreferencedConditions(policy.Table)
+		if referencesErr != nil {
+			return nil, referencesErr
+		}
+		conditions = append(conditions, unreferenced...)
+	}
+
+	args = append(args, limit)
+	query := fmt.Sprintf(`SELECT t.id, t.%s AS block_number, row_to_json(t) AS data FROM public.%s AS t
+		WHERE %s ORDER BY t.id LIMIT $%d`, blockColumn, policy.Table, strings.Join(conditions, " AND "), len(args))
+	var batch []batchRow
+	err := repository.db.Select(&batch, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error getting %s rows for retention policy %s: %w", policy.Table, policy.Name, err)
+	}
+	rows := make([]Row, len(batch))
+	for i, row := range batch {
+		rows[i] = Row{ID: row.ID, BlockNumber: row.BlockNumber, Data: row.Data}
+	}
+	return rows, nil
+}
+
+// Delete deletes rows by id, skipping any that have become referenced by transformed data since they were archived
+func (repository repository) Delete(table string, ids []int64) (int64, error) {
+	unreferenced, referencesErr := repository.unreferencedConditions(table)
+	if referencesErr != nil {
+		return 0, referencesErr
+	}
+	conditions := append([]string{"t.id = ANY($1::BIGINT[])"}, unreferenced...)
+	result, err := repository.db.Exec(fmt.Sprintf(`DELETE FROM public.%s AS t WHERE %s`, table,
+		strings.Join(conditions, " AND ")), pq.Int64Array(ids))
+	if err != nil {
+		return 0, fmt.Errorf("error deleting archived %s rows: %w", table, err)
+	}
+	return result.RowsAffected()
+}
+
+func (repository repository) StripRaw(ids []int64) (int64, error) {
+	result, err := repository.db.Exec(`UPDATE public.event_logs SET raw = NULL WHERE id = ANY($1::BIGINT[])`,
+		pq.Int64Array(ids))
+	if err != nil {
+		return 0, fmt.Errorf("error stripping raw JSON from archived event logs: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// Restore writes archived rows in the block range back to the table, keeping their ids. Event logs that still exist
+// get back their raw JSON, and logs whose header has since been replaced are skipped. Replaying returns the rows to the
+// state in which they're transformed again. Restored storage diffs don't drop storage snapshots, since snapshots taken
+// before the diffs were pruned already include them.
+func (repository repository) Restore(table string, rows []json.RawMessage, startingBlock, endingBlock int64, replay bool) (int64, error) {
+	if replay {
+		var replayErr error
+		rows, replayErr = setReplayFields(table, rows)
+		if replayErr != nil {
+			return 0, replayErr
+		}
+	}
+	data, marshalErr := json.Marshal(rows)
+	if marshalErr != nil {
+		return 0, fmt.Errorf("error encoding archived %s rows: %w", table, marshalErr)
+	}
+
+	var query string
+	switch table {
+	case StorageDiffTable:
+		query = `INSERT INTO public.storage_diff
+			SELECT r.* FROM json_populate_recordset(NULL::public.storage_diff, $1) AS r
+			WHERE r.block_height BETWEEN $2 AND $3
+			ON CONFLICT DO NOTHING`
+	case EventLogsTable:
+		query = `INSERT INTO public.event_logs
+			SELECT r.* FROM json_populate_recordset(NULL::public.event_logs, $1) AS r
+			WHERE r.block_number BETWEEN $2 AND $3
+			AND EXISTS(SELECT 1 FROM public.headers WHERE headers.id = r.header_id)
+			AND NOT EXISTS(SELECT 1 FROM public.event_logs AS e WHERE e.header_id = r.header_id
+				AND e.tx_index = r.tx_index AND e.log_index = r.log_index AND e.id != r.id)
+			ON CONFLICT (id) DO UPDATE SET raw = COALESCE(event_logs.raw, EXCLUDED.raw),
+				transformed = event_logs.transformed AND NOT $4`
+	default:
+		return 0, fmt.Errorf("%w: %q", ErrUnknownTable, table)
+	}
+	args := []interface{}{string(data), startingBlock, endingBlock}
+	if table == EventLogsTable {
+		args = append(args, replay)
+	}
+
+	tx, beginErr := repository.db.Beginx()
+	if beginErr != nil {
+		return 0, fmt.Errorf("error beginning transaction to restore archived %s rows: %w", table, beginErr)
+	}
+	if table == StorageDiffTable {
+		// checked by the trigger that drops snapshots made stale by inserted diffs; reset when the transaction ends
+		_, setErr := tx.Exec(`SELECT set_config('vulcanize.restoring_archive', 'on', true)`)
+		if setErr != nil {
+			rollback(tx, table)
+			return 0, fmt.Errorf("error skipping snapshot invalidation while restoring archived %s rows: %w", table,
+				setErr)
+		}
+	}
+	result, err := tx.Exec(query, args...)
+	if err != nil {
+		rollback(tx, table)
+		return 0, fmt.Errorf("error restoring archived %s rows: %w", table, err)
+	}
+	restored, rowsErr := result.RowsAffected()
+	if rowsErr != nil {
+		rollback(tx, table)
+		return 0, rowsErr
+	}
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		return 0, fmt.Errorf("error committing restored %s rows: %w", table, commitErr)
+	}
+	return restored, nil
+}
+
+func rollback(tx *sqlx.Tx, table string) {
+	rollbackErr := tx.Rollback()
+	if rollbackErr != nil {
+		logrus.Warnf("error rolling back restore of archived %s rows: %s", table, rollbackErr.Error())
+	}
+}
+
+// unreferencedConditions returns a condition for each foreign key referencing the table, excluding rows it references
+func (repository repository) unreferencedConditions(table string) ([]string, error) {
+	var references []struct {
+		Table  string
+		Column string
+	}
+	err := repository.db.Select(&references, `SELECT c.conrelid::regclass::text AS table, a.attname AS column
+		FROM pg_constraint AS c
+		JOIN pg_attribute AS a ON a.attrelid = c.conrelid AND a.attnum = c.conkey[1]
+		WHERE c.contype = 'f' AND c.confrelid = $1::regclass
+		ORDER BY 1, 2`, "public."+table)
+	if err != nil {
+		return nil, fmt.Errorf("error getting references to %s: %w", table, err)
+	}
+	conditions := make([]string, len(references))
+	for i, reference := range references {
+		conditions[i] = fmt.Sprintf("NOT EXISTS(SELECT 1 FROM %s WHERE %s.%s = t.id)", reference.Table,
+			reference.Table, pq.QuoteIdentifier(reference.Column))
+	}
+	return conditions, nil
+}
+
+// setReplayFields marks restored rows as unprocessed so that they're transformed again
+func setReplayFields(table string, rows []json.RawMessage) ([]json.RawMessage, error) {
+	var replayFields map[string]json.RawMessage
+	switch table {
+	case StorageDiffTable:
+		replayFields = map[string]json.RawMessage{
+			"status": json.RawMessage(`"new"`), "retry_count": json.RawMessage(`0`),
+			"next_attempt_at": json.RawMessage(`null`),
+		}
+	case EventLogsTable:
+		replayFields = map[string]json.RawMessage{"transformed": json.RawMessage(`false`)}
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownTable, table)
+	}
+	replayed := make([]json.RawMessage, len(rows))
+	for i, row := range rows {
+		// decode to raw values so ids and block numbers keep their precision
+		var fields map[string]json.RawMessage
+		decodeErr := json.Unmarshal(row, &fields)
+		if decodeErr != nil {
+			return nil, fmt.Errorf("error decoding archived %s row: %w", table, decodeErr)
+		}
+		for field, value := range replayFields {
+			fields[field] = value
+		}
+		encoded, encodeErr := json.Marshal(fields)
+		if encodeErr != nil {
+			return nil, fmt.Errorf("error encoding archived %s row: %w", table, encodeErr)
+		}
+		replayed[i] = encoded
+	}
+	return replayed, nil
+}
@@ -0,0 +1,197 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package retention_test
+
+import (
+	"encoding/json"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/makerdao/vulcanizedb/libraries/shared/retention"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
+	"github.com/makerdao/vulcanizedb/libraries/shared/test_data"
+	"github.com/makerdao/vulcanizedb/test_config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Retention repository", func() {
+	var (
+		db           = test_config.NewTestDB(test_config.NewTestNode())
+		diffRepo     = storage.NewDiffRepository(db)
+		repo         retention.Repository
+		address      = test_data.FakeAddress()
+		otherAddress = test_data.FakeAddress()
+		policy       retention.Policy
+	)
+
+	BeforeEach(func() {
+		test_config.CleanTestDB(db)
+		repo = retention.NewRepository(db)
+		policy = retention.Policy{
+			Name:     "old-diffs",
+			Table:    retention.StorageDiffTable,
+			Statuses: []string{storage.Transformed},
+			Action:   retention.ArchiveAction,
+		}
+	})
+
+	createDiff := func(address common.Address, blockHeight int, status string) int64 {
+		id, err := diffRepo.CreateStorageDiff(types.RawDiff{
+			Address:      address,
+			BlockHash:    test_data.FakeHash(),
+			BlockHeight:  blockHeight,
+			StorageKey:   test_data.FakeHash(),
+			StorageValue: test_data.FakeHash(),
+		})
+		Expect(err).NotTo(HaveOccurred())
+		_, updateErr := db.Exec(`UPDATE public.storage_diff SET status = $1 WHERE id = $2`, status, id)
+		Expect(updateErr).NotTo(HaveOccurred())
+		return id
+	}
+
+	rowIDs := func(rows []retention.Row) []int64 {
+		var ids []int64
+		for _, row := range rows {
+			ids = append(ids, row.ID)
+		}
+		return ids
+	}
+
+	Describe("GetBatch", func() {
+		It("returns rows in the policy's statuses at or before the block, ordered by id", func() {
+			firstID := createDiff(address, 1, storage.Transformed)
+			createDiff(address, 2, storage.New)
+			secondID := createDiff(otherAddress, 3, storage.Transformed)
+			createDiff(address, 4, storage.Transformed)
+
+			rows, err := repo.GetBatch(policy, 3, 0, 10)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rowIDs(rows)).To(Equal([]int64{firstID, secondID}))
+			Expect(rows[1].BlockNumber).To(Equal(int64(3)))
+			var data map[string]interface{}
+			Expect(json.Unmarshal(rows[1].Data, &data)).To(Succeed())
+			Expect(data["status"]).To(Equal(storage.Transformed))
+		})
+
+		It("pages by id", func() {
+			firstID := createDiff(address, 1, storage.Transformed)
+			secondID := createDiff(address, 1, storage.Transformed)
+			thirdID := createDiff(address, 1, storage.Transformed)
+
+			rows, err := repo.GetBatch(policy, 1, firstID, 1)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rowIDs(rows)).To(Equal([]int64{secondID}))
+			Expect(rowIDs(rows)).NotTo(ContainElement(thirdID))
+		})
+
+		It("filters by address", func() {
+			createDiff(address, 1, storage.Transformed)
+			otherID := createDiff(otherAddress, 1, storage.Transformed)
+			policy.Addresses = []string{otherAddress.Hex()}
+
+			rows, err := repo.GetBatch(policy, 1, 0, 10)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rowIDs(rows)).To(Equal([]int64{otherID}))
+		})
+	})
+
+	Describe("Delete", func() {
+		It("deletes the rows", func() {
+			id := createDiff(address, 1, storage.Transformed)
+			otherID := createDiff(address, 1, storage.Transformed)
+
+			deleted, err := repo.Delete(retention.StorageDiffTable, []int64{id})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(deleted).To(Equal(int64(1)))
+			var ids []int64
+			Expect(db.Select(&ids, `SELECT id FROM public.storage_diff`)).To(Succeed())
+			Expect(ids).To(Equal([]int64{otherID}))
+		})
+	})
+
+	Describe("Restore", func() {
+		var rows []retention.Row
+
+		BeforeEach(func() {
+			createDiff(address, 1, storage.Transformed)
+			createDiff(address, 5, storage.Transformed)
+			var err error
+			rows, err = repo.GetBatch(policy, 10, 0, 10)
+			Expect(err).NotTo(HaveOccurred())
+			_, deleteErr := repo.Delete(retention.StorageDiffTable, rowIDs(rows))
+			Expect(deleteErr).NotTo(HaveOccurred())
+		})
+
+		rowData := func() []json.RawMessage {
+			var data []json.RawMessage
+			for _, row := range rows {
+				data = append(data, row.Data)
+			}
+			return data
+		}
+
+		It("restores rows in the block range with their ids", func() {
+			restored, err := repo.Restore(retention.StorageDiffTable, rowData(), 0, 2, false)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(restored).To(Equal(int64(1)))
+			var diffs []types.PersistedDiff
+			Expect(db.Select(&diffs, `SELECT id, address, block_height, block_hash, storage_key, storage_value,
+				eth_node_id, status, from_backfill FROM public.storage_diff`)).To(Succeed())
+			Expect(len(diffs)).To(Equal(1))
+			Expect(diffs[0].ID).To(Equal(rows[0].ID))
+			Expect(diffs[0].Address).To(Equal(address))
+			Expect(diffs[0].Status).To(Equal(storage.Transformed))
+		})
+
+		It("marks restored rows as new when replaying", func() {
+			_, err := repo.Restore(retention.StorageDiffTable, rowData(), 0, 10, true)
+
+			Expect(err).NotTo(HaveOccurred())
+			var statuses []string
+			Expect(db.Select(&statuses, `SELECT status FROM public.storage_diff`)).To(Succeed())
+			Expect(statuses).To(Equal([]string{storage.New, storage.New}))
+		})
+
+		It("doesn't drop snapshots taken after the rows were pruned", func() {
+			stateRepo := storage.NewStateRepository(db)
+			Expect(stateRepo.CreateSnapshot(address, 10)).To(Succeed())
+
+			_, err := repo.Restore(retention.StorageDiffTable, rowData(), 0, 10, false)
+
+			Expect(err).NotTo(HaveOccurred())
+			blockHeight, snapshotErr := stateRepo.GetLatestSnapshotBlock(address)
+			Expect(snapshotErr).NotTo(HaveOccurred())
+			Expect(blockHeight).To(Equal(int64(10)))
+		})
+
+		It("skips rows that are already present", func() {
+			_, firstErr := repo.Restore(retention.StorageDiffTable, rowData(), 0, 10, false)
+			Expect(firstErr).NotTo(HaveOccurred())
+
+			restored, err := repo.Restore(retention.StorageDiffTable, rowData(), 0, 10, false)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(restored).To(BeZero())
+		})
+	})
+})
@@ -0,0 +1,64 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package retention
+
+import (
+	"fmt"
+
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres"
+	"github.com/sirupsen/logrus"
+)
+
+// Restorer loads archived rows in a block range back into the database, e.g. to replay them through transformers
+type Restorer struct {
+	Repository Repository
+	Archive    Archive
+}
+
+func NewRestorer(db *postgres.DB, archive Archive) Restorer {
+	return Restorer{
+		Repository: NewRepository(db),
+		Archive:    archive,
+	}
+}
+
+// Restore restores the table's archived rows between the starting and ending blocks, returning the number of rows
+// written. With replay, restored rows are marked to be transformed again.
+func (restorer Restorer) Restore(table string, startingBlock, endingBlock int64, replay bool) (int64, error) {
+	if _, ok := blockColumns[table]; !ok {
+		return 0, fmt.Errorf("%w: %q", ErrUnknownTable, table)
+	}
+	files, listErr := restorer.Archive.List(table, startingBlock, endingBlock)
+	if listErr != nil {
+		return 0, listErr
+	}
+
+	var restored int64
+	for _, file := range files {
+		rows, readErr := restorer.Archive.Read(file)
+		if readErr != nil {
+			return restored, readErr
+		}
+		count, restoreErr := restorer.Repository.Restore(table, rows, startingBlock, endingBlock, replay)
+		if restoreErr != nil {
+			return restored, fmt.Errorf("error restoring %s: %w", file.Path, restoreErr)
+		}
+		logrus.Infof("restored %d %s rows from %s", count, table, file.Path)
+		restored += count
+	}
+	return restored, nil
+}
@@ -0,0 +1,101 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package retention_test
+
+import (
+	"encoding/json"
+
+	"github.com/makerdao/vulcanizedb/libraries/shared/mocks"
+	"github.com/makerdao/vulcanizedb/libraries/shared/retention"
+	"github.com/makerdao/vulcanizedb/pkg/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Restorer", func() {
+	var (
+		repository *mocks.MockRetentionRepository
+		archive    *mocks.MockArchive
+		restorer   retention.Restorer
+		firstFile  = retention.File{Path: "first", StartingBlock: 10, EndingBlock: 20, FirstID: 1}
+		secondFile = retention.File{Path: "second", StartingBlock: 20, EndingBlock: 30, FirstID: 5}
+		firstRows  = []json.RawMessage{json.RawMessage(`{"id":1}`), json.RawMessage(`{"id":2}`)}
+		secondRows = []json.RawMessage{json.RawMessage(`{"id":5}`)}
+	)
+
+	BeforeEach(func() {
+		repository = &mocks.MockRetentionRepository{}
+		archive = &mocks.MockArchive{
+			Files:    []retention.File{firstFile, secondFile},
+			FileRows: map[string][]json.RawMessage{"first": firstRows, "second": secondRows},
+		}
+		restorer = retention.Restorer{Repository: repository, Archive: archive}
+	})
+
+	It("restores the rows of each archived file in the range", func() {
+		restored, err := restorer.Restore(retention.StorageDiffTable, 15, 25, true)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(restored).To(Equal(int64(3)))
+		Expect(archive.ListedRanges).To(Equal([][2]int64{{15, 25}}))
+		Expect(archive.ReadFiles).To(Equal([]retention.File{firstFile, secondFile}))
+		Expect(repository.RestoreCalls).To(Equal([]mocks.RestoreRetentionCall{
+			{Table: retention.StorageDiffTable, Rows: firstRows, StartingBlock: 15, EndingBlock: 25, Replay: true},
+			{Table: retention.StorageDiffTable, Rows: secondRows, StartingBlock: 15, EndingBlock: 25, Replay: true},
+		}))
+	})
+
+	It("counts only the rows the repository wrote", func() {
+		repository.RestoredCounts = []int64{1, 0}
+
+		restored, err := restorer.Restore(retention.EventLogsTable, 15, 25, false)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(restored).To(Equal(int64(1)))
+	})
+
+	It("rejects an unknown table", func() {
+		_, err := restorer.Restore("headers", 15, 25, false)
+
+		Expect(err).To(MatchError(retention.ErrUnknownTable))
+		Expect(archive.ListedRanges).To(BeEmpty())
+	})
+
+	It("returns an error if listing files fails", func() {
+		archive.ListErr = fakes.FakeError
+
+		_, err := restorer.Restore(retention.StorageDiffTable, 15, 25, false)
+
+		Expect(err).To(MatchError(fakes.FakeError))
+	})
+
+	It("returns an error if reading a file fails", func() {
+		archive.ReadErr = fakes.FakeError
+
+		_, err := restorer.Restore(retention.StorageDiffTable, 15, 25, false)
+
+		Expect(err).To(MatchError(fakes.FakeError))
+	})
+
+	It("returns an error if restoring rows fails", func() {
+		repository.RestoreErr = fakes.FakeError
+
+		_, err := restorer.Restore(retention.StorageDiffTable, 15, 25, false)
+
+		Expect(err).To(MatchError(fakes.FakeError))
+	})
+})
@@ -14,30 +14,22 @@
 // You should have received a copy of the GNU Affero General Public License
 // along with this program.  If not, see <http://www.gnu.org/licenses/>.
 
-package fakes
+package retention_test
 
 import (
-	"github.com/hpcloud/tail"
-	"gopkg.in/tomb.v1"
-)
+	"io/ioutil"
+	"testing"
 
-type MockTailer struct {
-	Lines   chan *tail.Line
-	TailErr error
-}
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus"
+)
 
-func NewMockTailer() *MockTailer {
-	return &MockTailer{
-		Lines: make(chan *tail.Line, 1),
-	}
+func TestRetention(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Retention Suite")
 }
 
-func (mock *MockTailer) Tail() (*tail.Tail, error) {
-	fakeTail := &tail.Tail{
-		Filename: "",
-		Lines:    mock.Lines,
-		Config:   tail.Config{},
-		Tomb:     tomb.Tomb{},
-	}
-	return fakeTail, mock.TailErr
-}
+var _ = BeforeSuite(func() {
+	logrus.SetOutput(ioutil.Discard)
+})
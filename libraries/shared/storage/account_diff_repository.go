@@ -0,0 +1,160 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres"
+)
+
+type AccountDiffRepository interface {
+	CreateAccountDiff(rawDiff types.RawAccountDiff) (int64, error)
+	GetNewAccountDiffs(minID, limit int) ([]types.PersistedAccountDiff, error)
+	MarkTransformed(id int64) error
+	MarkNoncanonical(id int64) error
+	MarkUnwatched(id int64) error
+	MarkPendingHeader(id int64) error
+	HasPendingHeaderPredecessor(diff types.PersistedAccountDiff) (bool, error)
+	ReleasePendingHeaderDiffs() (int64, error)
+}
+
+type accountDiffRepository struct {
+	db *postgres.DB
+}
+
+func NewAccountDiffRepository(db *postgres.DB) accountDiffRepository {
+	return accountDiffRepository{db: db}
+}
+
+type accountDiffRow struct {
+	ID          int64
+	Address     []byte
+	BlockHeight int    `db:"block_height"`
+	BlockHash   []byte `db:"block_hash"`
+	Balance     string
+	Nonce       uint64
+	CodeHash    []byte `db:"code_hash"`
+	StorageRoot []byte `db:"storage_root"`
+	EthNodeID   int64  `db:"eth_node_id"`
+	Status      string
+}
+
+// CreateAccountDiff writes a raw account diff to the database
+func (repository accountDiffRepository) CreateAccountDiff(rawDiff types.RawAccountDiff) (int64, error) {
+	var accountDiffID int64
+	row := repository.db.QueryRowx(`INSERT INTO public.account_diff
+		(address, block_height, block_hash, balance, nonce, code_hash, storage_root, eth_node_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT DO NOTHING RETURNING id`, rawDiff.Address.Bytes(), rawDiff.BlockHeight, rawDiff.BlockHash.Bytes(),
+		rawDiff.Balance.String(), rawDiff.Nonce, rawDiff.CodeHash.Bytes(), rawDiff.StorageRoot.Bytes(),
+		repository.db.NodeID)
+	err := row.Scan(&accountDiffID)
+	if err != nil {
+		return 0, fmt.Errorf("error creating account diff: %w", err)
+	}
+	return accountDiffID, nil
+}
+
+func (repository accountDiffRepository) GetNewAccountDiffs(minID, limit int) ([]types.PersistedAccountDiff, error) {
+	var rows []accountDiffRow
+	err := repository.db.Select(&rows,
+		`SELECT id, address, block_height, block_hash, balance, nonce, code_hash, storage_root, eth_node_id, status
+			FROM public.account_diff WHERE status = $1 AND id > $2 ORDER BY id ASC LIMIT $3`,
+		New, minID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error getting new account diffs with id greater than %d: %w", minID, err)
+	}
+
+	result := make([]types.PersistedAccountDiff, 0, len(rows))
+	for _, row := range rows {
+		balance, ok := new(big.Int).SetString(row.Balance, 10)
+		if !ok {
+			return nil, fmt.Errorf("error parsing balance %q of account diff %d", row.Balance, row.ID)
+		}
+		result = append(result, types.PersistedAccountDiff{
+			RawAccountDiff: types.RawAccountDiff{
+				Address:     common.BytesToAddress(row.Address),
+				BlockHash:   common.BytesToHash(row.BlockHash),
+				BlockHeight: row.BlockHeight,
+				Balance:     balance,
+				Nonce:       row.Nonce,
+				CodeHash:    common.BytesToHash(row.CodeHash),
+				StorageRoot: common.BytesToHash(row.StorageRoot),
+			},
+			ID:        row.ID,
+			Status:    row.Status,
+			EthNodeID: row.EthNodeID,
+		})
+	}
+	return result, nil
+}
+
+func (repository accountDiffRepository) MarkTransformed(id int64) error {
+	return repository.markStatus(id, Transformed)
+}
+
+func (repository accountDiffRepository) MarkNoncanonical(id int64) error {
+	return repository.markStatus(id, Noncanonical)
+}
+
+func (repository accountDiffRepository) MarkUnwatched(id int64) error {
+	return repository.markStatus(id, Unwatched)
+}
+
+// MarkPendingHeader parks an account diff whose block has no header yet, so that it's skipped until the header is synced
+func (repository accountDiffRepository) MarkPendingHeader(id int64) error {
+	return repository.markStatus(id, PendingHeader)
+}
+
+// HasPendingHeaderPredecessor checks whether an earlier diff for the same account is still waiting on its header, in
+// which case the given diff must wait too so that it doesn't overtake the earlier values
+func (repository accountDiffRepository) HasPendingHeaderPredecessor(diff types.PersistedAccountDiff) (bool, error) {
+	var exists bool
+	err := repository.db.Get(&exists, `SELECT EXISTS(SELECT 1 FROM public.account_diff
+		WHERE status = $1 AND address = $2
+		AND (block_height < $3 OR (block_height = $3 AND id < $4)))`,
+		PendingHeader, diff.Address.Bytes(), diff.BlockHeight, diff.ID)
+	if err != nil {
+		return false, fmt.Errorf("error checking for pending predecessors of account diff %d: %w", diff.ID, err)
+	}
+	return exists, nil
+}
+
+// ReleasePendingHeaderDiffs returns account diffs whose header has since been synced to the new status, returning the
+// number of diffs released
+func (repository accountDiffRepository) ReleasePendingHeaderDiffs() (int64, error) {
+	result, err := repository.db.Exec(`UPDATE public.account_diff SET status = $1
+		WHERE status = $2
+		AND EXISTS(SELECT 1 FROM public.headers WHERE headers.block_number = account_diff.block_height)`,
+		New, PendingHeader)
+	if err != nil {
+		return 0, fmt.Errorf("error releasing account diffs pending header: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+func (repository accountDiffRepository) markStatus(id int64, status string) error {
+	_, err := repository.db.Exec(`UPDATE public.account_diff SET status = $1 WHERE id = $2`, status, id)
+	if err != nil {
+		return fmt.Errorf("error marking account diff %d %s: %w", id, status, err)
+	}
+	return nil
+}
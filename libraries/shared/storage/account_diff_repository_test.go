@@ -0,0 +1,237 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package storage_test
+
+import (
+	"database/sql"
+	"math/big"
+	"math/rand"
+
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
+	"github.com/makerdao/vulcanizedb/libraries/shared/test_data"
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres/repositories"
+	"github.com/makerdao/vulcanizedb/pkg/fakes"
+	"github.com/makerdao/vulcanizedb/test_config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Account diffs repository", func() {
+	var (
+		db              = test_config.NewTestDB(test_config.NewTestNode())
+		repo            storage.AccountDiffRepository
+		fakeAccountDiff types.RawAccountDiff
+	)
+
+	BeforeEach(func() {
+		test_config.CleanTestDB(db)
+		repo = storage.NewAccountDiffRepository(db)
+		fakeAccountDiff = types.RawAccountDiff{
+			Address:     test_data.FakeAddress(),
+			BlockHash:   test_data.FakeHash(),
+			BlockHeight: rand.Int(),
+			Balance:     new(big.Int).Mul(big.NewInt(rand.Int63()), big.NewInt(rand.Int63())),
+			Nonce:       uint64(rand.Int63()),
+			CodeHash:    test_data.FakeHash(),
+			StorageRoot: test_data.FakeHash(),
+		}
+	})
+
+	Describe("CreateAccountDiff", func() {
+		It("adds an account diff to the db, returning id", func() {
+			id, createErr := repo.CreateAccountDiff(fakeAccountDiff)
+
+			Expect(createErr).NotTo(HaveOccurred())
+			Expect(id).NotTo(BeZero())
+			diffs, getErr := repo.GetNewAccountDiffs(0, 1)
+			Expect(getErr).NotTo(HaveOccurred())
+			Expect(len(diffs)).To(Equal(1))
+			Expect(diffs[0].ID).To(Equal(id))
+			Expect(diffs[0].RawAccountDiff).To(Equal(fakeAccountDiff))
+			Expect(diffs[0].Status).To(Equal(storage.New))
+			Expect(diffs[0].EthNodeID).To(Equal(db.NodeID))
+		})
+
+		It("does not duplicate account diffs", func() {
+			_, createErr := repo.CreateAccountDiff(fakeAccountDiff)
+			Expect(createErr).NotTo(HaveOccurred())
+
+			_, createTwoErr := repo.CreateAccountDiff(fakeAccountDiff)
+			Expect(createTwoErr).To(MatchError(sql.ErrNoRows))
+
+			var count int
+			getErr := db.Get(&count, `SELECT count(*) FROM public.account_diff`)
+			Expect(getErr).NotTo(HaveOccurred())
+			Expect(count).To(Equal(1))
+		})
+	})
+
+	Describe("GetNewAccountDiffs", func() {
+		It("returns new diffs with ids greater than the min id, up to the limit", func() {
+			firstID, createErr := repo.CreateAccountDiff(fakeAccountDiff)
+			Expect(createErr).NotTo(HaveOccurred())
+			secondDiff := fakeAccountDiff
+			secondDiff.Nonce = fakeAccountDiff.Nonce + 1
+			secondID, createSecondErr := repo.CreateAccountDiff(secondDiff)
+			Expect(createSecondErr).NotTo(HaveOccurred())
+			thirdDiff := fakeAccountDiff
+			thirdDiff.Nonce = fakeAccountDiff.Nonce + 2
+			_, createThirdErr := repo.CreateAccountDiff(thirdDiff)
+			Expect(createThirdErr).NotTo(HaveOccurred())
+
+			diffs, getErr := repo.GetNewAccountDiffs(int(firstID), 1)
+
+			Expect(getErr).NotTo(HaveOccurred())
+			Expect(len(diffs)).To(Equal(1))
+			Expect(diffs[0].ID).To(Equal(secondID))
+		})
+
+		It("does not return diffs that are not new", func() {
+			id, createErr := repo.CreateAccountDiff(fakeAccountDiff)
+			Expect(createErr).NotTo(HaveOccurred())
+			markErr := repo.MarkTransformed(id)
+			Expect(markErr).NotTo(HaveOccurred())
+
+			diffs, getErr := repo.GetNewAccountDiffs(0, 1)
+
+			Expect(getErr).NotTo(HaveOccurred())
+			Expect(diffs).To(BeEmpty())
+		})
+	})
+
+	Describe("marking diffs", func() {
+		var id int64
+
+		BeforeEach(func() {
+			var createErr error
+			id, createErr = repo.CreateAccountDiff(fakeAccountDiff)
+			Expect(createErr).NotTo(HaveOccurred())
+		})
+
+		getStatus := func() string {
+			var status string
+			getErr := db.Get(&status, `SELECT status FROM public.account_diff WHERE id = $1`, id)
+			Expect(getErr).NotTo(HaveOccurred())
+			return status
+		}
+
+		It("marks a diff transformed", func() {
+			Expect(repo.MarkTransformed(id)).To(Succeed())
+			Expect(getStatus()).To(Equal(storage.Transformed))
+		})
+
+		It("marks a diff noncanonical", func() {
+			Expect(repo.MarkNoncanonical(id)).To(Succeed())
+			Expect(getStatus()).To(Equal(storage.Noncanonical))
+		})
+
+		It("marks a diff unwatched", func() {
+			Expect(repo.MarkUnwatched(id)).To(Succeed())
+			Expect(getStatus()).To(Equal(storage.Unwatched))
+		})
+
+		It("marks a diff pending header", func() {
+			Expect(repo.MarkPendingHeader(id)).To(Succeed())
+			Expect(getStatus()).To(Equal(storage.PendingHeader))
+		})
+	})
+
+	Describe("HasPendingHeaderPredecessor", func() {
+		var pendingDiff types.PersistedAccountDiff
+
+		BeforeEach(func() {
+			id, createErr := repo.CreateAccountDiff(fakeAccountDiff)
+			Expect(createErr).NotTo(HaveOccurred())
+			Expect(repo.MarkPendingHeader(id)).To(Succeed())
+			pendingDiff = types.PersistedAccountDiff{RawAccountDiff: fakeAccountDiff, ID: id}
+		})
+
+		It("returns true if an earlier diff for the same account is pending header", func() {
+			laterDiff := pendingDiff
+			laterDiff.BlockHeight = pendingDiff.BlockHeight + 1
+			laterDiff.ID = pendingDiff.ID + 1
+
+			hasPredecessor, err := repo.HasPendingHeaderPredecessor(laterDiff)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hasPredecessor).To(BeTrue())
+		})
+
+		It("returns false if the pending diff is later", func() {
+			earlierDiff := pendingDiff
+			earlierDiff.BlockHeight = pendingDiff.BlockHeight - 1
+			earlierDiff.ID = pendingDiff.ID + 1
+
+			hasPredecessor, err := repo.HasPendingHeaderPredecessor(earlierDiff)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hasPredecessor).To(BeFalse())
+		})
+
+		It("returns false if the pending diff is for another account", func() {
+			otherDiff := pendingDiff
+			otherDiff.Address = test_data.FakeAddress()
+			otherDiff.BlockHeight = pendingDiff.BlockHeight + 1
+			otherDiff.ID = pendingDiff.ID + 1
+
+			hasPredecessor, err := repo.HasPendingHeaderPredecessor(otherDiff)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hasPredecessor).To(BeFalse())
+		})
+	})
+
+	Describe("ReleasePendingHeaderDiffs", func() {
+		var id int64
+
+		BeforeEach(func() {
+			fakeAccountDiff.BlockHeight = rand.Intn(1000000)
+			var createErr error
+			id, createErr = repo.CreateAccountDiff(fakeAccountDiff)
+			Expect(createErr).NotTo(HaveOccurred())
+			Expect(repo.MarkPendingHeader(id)).To(Succeed())
+		})
+
+		getStatus := func() string {
+			var status string
+			getErr := db.Get(&status, `SELECT status FROM public.account_diff WHERE id = $1`, id)
+			Expect(getErr).NotTo(HaveOccurred())
+			return status
+		}
+
+		It("returns diffs to new once their header exists", func() {
+			headerRepository := repositories.NewHeaderRepository(db)
+			_, headerErr := headerRepository.CreateOrUpdateHeader(fakes.GetFakeHeader(int64(fakeAccountDiff.BlockHeight)))
+			Expect(headerErr).NotTo(HaveOccurred())
+
+			released, err := repo.ReleasePendingHeaderDiffs()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(released).To(Equal(int64(1)))
+			Expect(getStatus()).To(Equal(storage.New))
+		})
+
+		It("leaves diffs pending if their header is still missing", func() {
+			released, err := repo.ReleasePendingHeaderDiffs()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(released).To(BeZero())
+			Expect(getStatus()).To(Equal(storage.PendingHeader))
+		})
+	})
+})
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/fetcher"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres"
+	"github.com/sirupsen/logrus"
+)
+
+type AccountDiffExtractor struct {
+	AccountDiffRepository AccountDiffRepository
+	AccountDiffFetcher    fetcher.IAccountDiffFetcher
+}
+
+func NewAccountDiffExtractor(fetcher fetcher.IAccountDiffFetcher, db *postgres.DB) AccountDiffExtractor {
+	return AccountDiffExtractor{
+		AccountDiffRepository: NewAccountDiffRepository(db),
+		AccountDiffFetcher:    fetcher,
+	}
+}
+
+func (extractor AccountDiffExtractor) ExtractAccountDiffs() error {
+	diffsChan := make(chan types.RawAccountDiff)
+	errsChan := make(chan error)
+
+	defer close(diffsChan)
+	defer close(errsChan)
+
+	go extractor.AccountDiffFetcher.FetchAccountDiffs(diffsChan, errsChan)
+
+	for {
+		select {
+		case fetchErr := <-errsChan:
+			logrus.Warnf("error fetching account diffs: %s", fetchErr.Error())
+			return fmt.Errorf("error fetching account diffs: %w", fetchErr)
+		case diff := <-diffsChan:
+			extractor.persistDiff(diff)
+		}
+	}
+}
+
+func (extractor AccountDiffExtractor) persistDiff(rawDiff types.RawAccountDiff) {
+	_, err := extractor.AccountDiffRepository.CreateAccountDiff(rawDiff)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			logrus.Tracef("ignoring duplicate account diff. Block number: %v, blockHash: %v, address: %v",
+				rawDiff.BlockHeight, rawDiff.BlockHash.Hex(), rawDiff.Address.Hex())
+			return
+		}
+		logrus.Warnf("failed to persist account diff: %s", err.Error())
+	}
+}
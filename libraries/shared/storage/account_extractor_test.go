@@ -0,0 +1,67 @@
+package storage_test
+
+import (
+	"math/big"
+	"math/rand"
+
+	"github.com/makerdao/vulcanizedb/libraries/shared/mocks"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
+	"github.com/makerdao/vulcanizedb/libraries/shared/test_data"
+	"github.com/makerdao/vulcanizedb/pkg/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Account diff extractor", func() {
+	var (
+		mockFetcher    *mocks.MockAccountDiffFetcher
+		mockRepository *mocks.MockAccountDiffRepository
+		extractor      storage.AccountDiffExtractor
+	)
+
+	BeforeEach(func() {
+		mockFetcher = &mocks.MockAccountDiffFetcher{}
+		mockRepository = &mocks.MockAccountDiffRepository{}
+		extractor = storage.AccountDiffExtractor{
+			AccountDiffRepository: mockRepository,
+			AccountDiffFetcher:    mockFetcher,
+		}
+	})
+
+	Describe("ExtractAccountDiffs", func() {
+		It("fetches account diffs", func() {
+			mockFetcher.ErrsToReturn = []error{fakes.FakeError}
+
+			_ = extractor.ExtractAccountDiffs()
+
+			Expect(mockFetcher.FetchAccountDiffsCalled).To(BeTrue())
+		})
+
+		It("returns error if fetching account diffs fails", func() {
+			mockFetcher.ErrsToReturn = []error{fakes.FakeError}
+
+			err := extractor.ExtractAccountDiffs()
+
+			Expect(err).To(MatchError(fakes.FakeError))
+		})
+
+		It("persists fetched account diffs", func() {
+			fakeDiff := types.RawAccountDiff{
+				Address:     test_data.FakeAddress(),
+				BlockHash:   test_data.FakeHash(),
+				BlockHeight: rand.Int(),
+				Balance:     big.NewInt(rand.Int63()),
+				Nonce:       rand.Uint64(),
+				CodeHash:    test_data.FakeHash(),
+				StorageRoot: test_data.FakeHash(),
+			}
+			mockFetcher.DiffsToReturn = []types.RawAccountDiff{fakeDiff}
+			mockFetcher.ErrsToReturn = []error{fakes.FakeError}
+
+			_ = extractor.ExtractAccountDiffs()
+
+			Expect(mockRepository.CreatePassedRawDiffs).To(Equal([]types.RawAccountDiff{fakeDiff}))
+		})
+	})
+})
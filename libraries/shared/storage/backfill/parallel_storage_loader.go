@@ -0,0 +1,245 @@
+package backfill
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/makerdao/vulcanizedb/libraries/shared/factories/storage"
+	storage2 "github.com/makerdao/vulcanizedb/libraries/shared/storage"
+	"github.com/makerdao/vulcanizedb/pkg/core"
+	"github.com/makerdao/vulcanizedb/pkg/datastore"
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres"
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres/repositories"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	DefaultWorkers   = 4
+	DefaultRangeSize = int64(10000)
+
+	ErrArchiveNodeRequired = errors.New("node doesn't serve historical state; back-filling storage requires an archive node")
+	errStopped             = errors.New("back-fill stopped after another range failed")
+
+	// substrings of the errors nodes return when asked for state they've pruned
+	prunedStateMessages = []string{"missing trie node", "pruned", "pruning", "state not available",
+		"state is not available", "historical state"}
+)
+
+type LoaderOptions struct {
+	// Workers is how many ranges are back-filled at once
+	Workers int
+	// RangeSize is how many blocks are in each checkpointed range
+	RangeSize int64
+	// LogBlocksOnly restricts back-filling to blocks where the watched addresses emitted logs. Storage changed by
+	// calls that don't emit logs is missed.
+	LogBlocksOnly bool
+}
+
+var DefaultLoaderOptions = LoaderOptions{Workers: DefaultWorkers, RangeSize: DefaultRangeSize}
+
+// ParallelStorageLoader back-fills storage values for ranges of blocks across workers, checkpointing each range so
+// that an interrupted back-fill resumes where it left off
+type ParallelStorageLoader struct {
+	bc               core.BlockChain
+	db               *postgres.DB
+	HeaderRepo       datastore.HeaderRepository
+	LogHeaderRepo    LogHeaderRepository
+	RangeRepo        RangeRepository
+	StorageDiffRepo  storage2.DiffRepository
+	StorageValueRepo StorageValueRepository
+	initializers     []storage.TransformerInitializer
+	options          LoaderOptions
+}
+
+func NewParallelStorageLoader(bc core.BlockChain, db *postgres.DB, initializers []storage.TransformerInitializer,
+	options LoaderOptions) ParallelStorageLoader {
+	if options.Workers < 1 {
+		options.Workers = 1
+	}
+	if options.RangeSize < 1 {
+		options.RangeSize = DefaultRangeSize
+	}
+	return ParallelStorageLoader{
+		bc:               bc,
+		db:               db,
+		HeaderRepo:       repositories.NewHeaderRepository(db),
+		LogHeaderRepo:    NewLogHeaderRepository(db),
+		RangeRepo:        NewRangeRepository(db),
+		StorageDiffRepo:  storage2.NewDiffRepository(db),
+		StorageValueRepo: NewStorageValueRepository(db),
+		initializers:     initializers,
+		options:          options,
+	}
+}
+
+func (loader ParallelStorageLoader) Run(startingBlock, endingBlock int64) error {
+	if len(loader.initializers) == 0 {
+		return ErrNoTransformers
+	}
+	keysByAddress, getKeysErr := getKeysByAddress(loader.db, loader.initializers)
+	if getKeysErr != nil {
+		return getKeysErr
+	}
+	addresses := sortedAddresses(keysByAddress)
+
+	archiveErr := CheckArchiveNode(loader.bc, addresses[0], startingBlock)
+	if archiveErr != nil {
+		return archiveErr
+	}
+
+	job := loader.jobID(addresses, startingBlock, endingBlock)
+	createErr := loader.RangeRepo.CreateRanges(job, startingBlock, endingBlock, loader.options.RangeSize)
+	if createErr != nil {
+		return createErr
+	}
+	ranges, getRangesErr := loader.RangeRepo.GetIncompleteRanges(job, startingBlock, endingBlock)
+	if getRangesErr != nil {
+		return getRangesErr
+	}
+	logrus.Infof("Back-filling storage for %d addresses in %d ranges with %d workers", len(addresses),
+		len(ranges), loader.options.Workers)
+
+	rangesChan := make(chan Range)
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	var firstErr error
+	var wg sync.WaitGroup
+	for i := 0; i < loader.options.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range rangesChan {
+				rangeErr := loader.loadRange(r, keysByAddress, addresses, stop)
+				if rangeErr != nil {
+					stopOnce.Do(func() {
+						firstErr = rangeErr
+						close(stop)
+					})
+				}
+			}
+		}()
+	}
+dispatch:
+	for _, r := range ranges {
+		select {
+		case rangesChan <- r:
+		case <-stop:
+			break dispatch
+		}
+	}
+	close(rangesChan)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	logrus.Infof("Finished persisting storage values for %v addresses from block %v to %v.", len(addresses),
+		startingBlock, endingBlock)
+	return nil
+}
+
+func (loader ParallelStorageLoader) loadRange(r Range, keysByAddress map[common.Address][]storageKey,
+	addresses []common.Address, stop <-chan struct{}) error {
+	var headers []core.Header
+	var getHeadersErr error
+	if loader.options.LogBlocksOnly {
+		headers, getHeadersErr = loader.LogHeaderRepo.GetHeadersWithLogs(addresses, r.NextBlock(), r.EndingBlock)
+	} else {
+		headers, getHeadersErr = loader.HeaderRepo.GetHeadersInRange(r.NextBlock(), r.EndingBlock)
+	}
+	if getHeadersErr != nil {
+		return getHeadersErr
+	}
+	logrus.Infof("Back-filling storage for blocks %d-%d (%d headers)", r.NextBlock(), r.EndingBlock, len(headers))
+
+	storageByAddress := make(map[common.Address]chunksOfKeysToValues, len(keysByAddress))
+	for address, keys := range keysByAddress {
+		storageByAddress[address] = newChunksOfKeysToValues(keys)
+	}
+	seedErr := loader.seedStorageValues(storageByAddress, r.NextBlock()-1)
+	if seedErr != nil {
+		return seedErr
+	}
+	for _, header := range headers {
+		select {
+		case <-stop:
+			return errStopped
+		default:
+		}
+		persistErr := persistStorageValues(loader.bc, loader.StorageDiffRepo, storageByAddress, header.BlockNumber,
+			header.Hash)
+		if persistErr != nil {
+			return fmt.Errorf("error back-filling storage at block %d: %w", header.BlockNumber, persistErr)
+		}
+		updateErr := loader.RangeRepo.UpdateLastBlock(r.ID, header.BlockNumber)
+		if updateErr != nil {
+			return updateErr
+		}
+	}
+	return loader.RangeRepo.UpdateLastBlock(r.ID, r.EndingBlock)
+}
+
+// seedStorageValues sets the last known value of each key to its persisted value at the end of the block before a
+// range (or its resume point), so that the range's first block only persists values that differ from what's already
+// known. Keys whose earlier ranges haven't been loaded yet keep empty values.
+func (loader ParallelStorageLoader) seedStorageValues(storageByAddress map[common.Address]chunksOfKeysToValues,
+	blockHeight int64) error {
+	for address, chunkedKeysToValues := range storageByAddress {
+		knownStorage, getStorageErr := loader.StorageValueRepo.GetStorageValuesAt(address, blockHeight)
+		if getStorageErr != nil {
+			return fmt.Errorf("error seeding storage values for blocks after %d: %w", blockHeight, getStorageErr)
+		}
+		for _, keysToValues := range chunkedKeysToValues {
+			for key := range keysToValues {
+				if knownValue, ok := knownStorage[key]; ok {
+					keysToValues[key] = knownValue
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// jobID identifies the addresses, blocks and options being back-filled, so that ranges are only resumed by the same job
+func (loader ParallelStorageLoader) jobID(addresses []common.Address, startingBlock, endingBlock int64) string {
+	hexAddresses := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		hexAddresses = append(hexAddresses, address.Hex())
+	}
+	description := fmt.Sprintf("%s;blocks=%d-%d;rangeSize=%d;logBlocksOnly=%t", strings.Join(hexAddresses, ","),
+		startingBlock, endingBlock, loader.options.RangeSize, loader.options.LogBlocksOnly)
+	return crypto.Keccak256Hash([]byte(description)).Hex()
+}
+
+// CheckArchiveNode asks the node for storage at the block, returning ErrArchiveNodeRequired if the node has pruned
+// the state at that block
+func CheckArchiveNode(bc core.BlockChain, address common.Address, blockNumber int64) error {
+	_, err := bc.BatchGetStorageAt(address, []common.Hash{{}}, big.NewInt(blockNumber))
+	if err == nil {
+		return nil
+	}
+	message := strings.ToLower(err.Error())
+	for _, prunedMessage := range prunedStateMessages {
+		if strings.Contains(message, prunedMessage) {
+			return fmt.Errorf("%w (getting storage at block %d: %s)", ErrArchiveNodeRequired, blockNumber, err.Error())
+		}
+	}
+	return fmt.Errorf("error checking node for storage at block %d: %w", blockNumber, err)
+}
+
+func sortedAddresses(keysByAddress map[common.Address][]storageKey) []common.Address {
+	addresses := make([]common.Address, 0, len(keysByAddress))
+	for address := range keysByAddress {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		return addresses[i].Hex() < addresses[j].Hex()
+	})
+	return addresses
+}
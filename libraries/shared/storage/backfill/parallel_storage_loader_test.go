@@ -0,0 +1,280 @@
+package backfill_test
+
+import (
+	"database/sql"
+	"errors"
+	"math/big"
+	"math/rand"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/makerdao/vulcanizedb/libraries/shared/factories/storage"
+	"github.com/makerdao/vulcanizedb/libraries/shared/mocks"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/backfill"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
+	"github.com/makerdao/vulcanizedb/libraries/shared/test_data"
+	"github.com/makerdao/vulcanizedb/pkg/core"
+	"github.com/makerdao/vulcanizedb/pkg/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ParallelStorageLoader", func() {
+	var (
+		bc            *fakes.MockBlockChain
+		keysLookup    mocks.MockStorageKeysLookup
+		address       common.Address
+		key           common.Hash
+		value         common.Hash
+		blockNumber   int64
+		header        core.Header
+		headerRepo    fakes.MockHeaderRepository
+		logHeaderRepo mocks.MockLogHeaderRepository
+		rangeRepo     mocks.MockRangeRepository
+		diffRepo      mocks.MockStorageDiffRepository
+		valueRepo     mocks.MockStorageValueRepository
+		initializers  []storage.TransformerInitializer
+		options       backfill.LoaderOptions
+		loader        backfill.ParallelStorageLoader
+		testRange     backfill.Range
+	)
+
+	BeforeEach(func() {
+		bc = fakes.NewMockBlockChain()
+		blockNumber = rand.Int63n(1000000) + 10
+		address = test_data.FakeAddress()
+		key = test_data.FakeHash()
+		value = test_data.FakeHash()
+		bc.SetStorageValuesToReturn(blockNumber, address, value[:])
+		keysLookup = mocks.MockStorageKeysLookup{KeysToReturn: []common.Hash{key}}
+		initializers = []storage.TransformerInitializer{storage.Transformer{
+			Address:           address,
+			StorageKeysLookup: &keysLookup,
+			Repository:        &mocks.MockStorageRepository{},
+		}.NewTransformer}
+
+		header = fakes.FakeHeader
+		header.BlockNumber = blockNumber
+		headerRepo = fakes.MockHeaderRepository{AllHeaders: []core.Header{header}}
+		logHeaderRepo = mocks.MockLogHeaderRepository{}
+		testRange = backfill.Range{ID: rand.Int63(), StartingBlock: blockNumber - 5, EndingBlock: blockNumber + 5}
+		rangeRepo = mocks.MockRangeRepository{IncompleteRanges: []backfill.Range{testRange}}
+		diffRepo = mocks.MockStorageDiffRepository{}
+		valueRepo = mocks.MockStorageValueRepository{}
+		options = backfill.LoaderOptions{Workers: 1, RangeSize: 100}
+	})
+
+	newLoader := func() backfill.ParallelStorageLoader {
+		newLoader := backfill.NewParallelStorageLoader(bc, nil, initializers, options)
+		newLoader.HeaderRepo = &headerRepo
+		newLoader.LogHeaderRepo = &logHeaderRepo
+		newLoader.RangeRepo = &rangeRepo
+		newLoader.StorageDiffRepo = &diffRepo
+		newLoader.StorageValueRepo = &valueRepo
+		return newLoader
+	}
+
+	JustBeforeEach(func() {
+		loader = newLoader()
+	})
+
+	It("returns an error if initialized without transformers", func() {
+		loader = backfill.NewParallelStorageLoader(bc, nil, nil, options)
+
+		err := loader.Run(0, 1)
+
+		Expect(err).To(MatchError(backfill.ErrNoTransformers))
+	})
+
+	It("returns an error if the node has pruned the state at the starting block", func() {
+		bc.BatchGetStorageAtError = errors.New("missing trie node 0xabc (path )")
+
+		err := loader.Run(blockNumber, blockNumber)
+
+		Expect(err).To(MatchError(backfill.ErrArchiveNodeRequired))
+		Expect(rangeRepo.CreateRangesCalls).To(BeEmpty())
+	})
+
+	It("returns other errors from checking the node as they are", func() {
+		bc.BatchGetStorageAtError = fakes.FakeError
+
+		err := loader.Run(blockNumber, blockNumber)
+
+		Expect(err).To(MatchError(fakes.FakeError))
+		Expect(err).NotTo(MatchError(backfill.ErrArchiveNodeRequired))
+	})
+
+	It("creates ranges for the job", func() {
+		err := loader.Run(blockNumber-5, blockNumber+5)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(len(rangeRepo.CreateRangesCalls)).To(Equal(1))
+		call := rangeRepo.CreateRangesCalls[0]
+		Expect(call.Job).NotTo(BeEmpty())
+		Expect(call.StartingBlock).To(Equal(blockNumber - 5))
+		Expect(call.EndingBlock).To(Equal(blockNumber + 5))
+		Expect(call.RangeSize).To(Equal(int64(100)))
+	})
+
+	It("uses a different job for different options", func() {
+		Expect(loader.Run(blockNumber, blockNumber)).To(Succeed())
+		options.LogBlocksOnly = true
+
+		Expect(newLoader().Run(blockNumber, blockNumber)).To(Succeed())
+
+		Expect(len(rangeRepo.CreateRangesCalls)).To(Equal(2))
+		Expect(rangeRepo.CreateRangesCalls[0].Job).NotTo(Equal(rangeRepo.CreateRangesCalls[1].Job))
+	})
+
+	It("uses a different job for different blocks", func() {
+		Expect(loader.Run(blockNumber, blockNumber)).To(Succeed())
+
+		Expect(newLoader().Run(blockNumber, blockNumber+1)).To(Succeed())
+
+		Expect(len(rangeRepo.CreateRangesCalls)).To(Equal(2))
+		Expect(rangeRepo.CreateRangesCalls[0].Job).NotTo(Equal(rangeRepo.CreateRangesCalls[1].Job))
+	})
+
+	It("uses the same job to resume the same blocks", func() {
+		Expect(loader.Run(blockNumber, blockNumber)).To(Succeed())
+
+		Expect(newLoader().Run(blockNumber, blockNumber)).To(Succeed())
+
+		Expect(len(rangeRepo.CreateRangesCalls)).To(Equal(2))
+		Expect(rangeRepo.CreateRangesCalls[0].Job).To(Equal(rangeRepo.CreateRangesCalls[1].Job))
+	})
+
+	It("persists storage values for the headers in each incomplete range", func() {
+		err := loader.Run(blockNumber-5, blockNumber+5)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(headerRepo.GetHeadersInRangeStartingBlocks).To(Equal([]int64{testRange.StartingBlock}))
+		Expect(headerRepo.GetHeadersInRangeEndingBlocks).To(Equal([]int64{testRange.EndingBlock}))
+		Expect(diffRepo.CreateBackFilledStorageValuePassedRawDiffs).To(ConsistOf(types.RawDiff{
+			Address:      address,
+			BlockHash:    common.HexToHash(header.Hash),
+			BlockHeight:  int(blockNumber),
+			StorageKey:   key,
+			StorageValue: value,
+		}))
+	})
+
+	It("resumes a range after its last completed block", func() {
+		testRange.LastBlock = sql.NullInt64{Int64: blockNumber - 2, Valid: true}
+		rangeRepo.IncompleteRanges = []backfill.Range{testRange}
+
+		err := loader.Run(blockNumber-5, blockNumber+5)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(headerRepo.GetHeadersInRangeStartingBlocks).To(Equal([]int64{blockNumber - 1}))
+	})
+
+	It("seeds values from persisted storage at the block before the range", func() {
+		testRange.LastBlock = sql.NullInt64{Int64: blockNumber - 2, Valid: true}
+		rangeRepo.IncompleteRanges = []backfill.Range{testRange}
+		valueRepo.Values = map[common.Hash]common.Hash{key: value}
+
+		err := loader.Run(blockNumber-5, blockNumber+5)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(valueRepo.PassedAddresses).To(Equal([]common.Address{address}))
+		Expect(valueRepo.PassedBlockHeights).To(Equal([]int64{blockNumber - 2}))
+		Expect(diffRepo.CreateBackFilledStorageValuePassedRawDiffs).To(BeEmpty())
+	})
+
+	It("returns an error if seeding values fails", func() {
+		valueRepo.GetStorageValuesAtErr = fakes.FakeError
+
+		err := loader.Run(blockNumber-5, blockNumber+5)
+
+		Expect(err).To(MatchError(fakes.FakeError))
+		Expect(rangeRepo.UpdatedLastBlocks(testRange.ID)).To(BeEmpty())
+	})
+
+	It("checkpoints each block and marks the range complete", func() {
+		err := loader.Run(blockNumber-5, blockNumber+5)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rangeRepo.UpdatedLastBlocks(testRange.ID)).To(Equal([]int64{blockNumber, testRange.EndingBlock}))
+	})
+
+	It("only loads blocks with logs from the watched addresses if configured", func() {
+		options.LogBlocksOnly = true
+		logHeaderRepo.HeadersToReturn = []core.Header{header}
+
+		err := newLoader().Run(blockNumber-5, blockNumber+5)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(logHeaderRepo.PassedAddresses).To(Equal([]common.Address{address}))
+		Expect(logHeaderRepo.PassedStartingBlocks).To(Equal([]int64{testRange.StartingBlock}))
+		Expect(headerRepo.GetHeadersInRangeStartingBlocks).To(BeEmpty())
+		Expect(len(diffRepo.CreateBackFilledStorageValuePassedRawDiffs)).To(Equal(1))
+	})
+
+	It("loads ranges across workers", func() {
+		options.Workers = 3
+		var ranges []backfill.Range
+		for i := int64(0); i < 5; i++ {
+			ranges = append(ranges, backfill.Range{ID: i, StartingBlock: i * 10, EndingBlock: i*10 + 9})
+		}
+		rangeRepo.IncompleteRanges = ranges
+
+		err := newLoader().Run(0, 49)
+
+		Expect(err).NotTo(HaveOccurred())
+		for _, r := range ranges {
+			updated := rangeRepo.UpdatedLastBlocks(r.ID)
+			Expect(updated[len(updated)-1]).To(Equal(r.EndingBlock))
+		}
+	})
+
+	It("returns an error and doesn't complete the range if persisting a value fails", func() {
+		diffRepo.CreateBackFilledStorageValueReturnError = fakes.FakeError
+
+		err := loader.Run(blockNumber-5, blockNumber+5)
+
+		Expect(err).To(MatchError(fakes.FakeError))
+		Expect(rangeRepo.UpdatedLastBlocks(testRange.ID)).To(BeEmpty())
+	})
+
+	It("returns an error if checkpointing fails", func() {
+		rangeRepo.UpdateLastBlockErr = fakes.FakeError
+
+		err := loader.Run(blockNumber-5, blockNumber+5)
+
+		Expect(err).To(MatchError(fakes.FakeError))
+	})
+})
+
+var _ = Describe("Range", func() {
+	It("starts from the beginning if no block has been completed", func() {
+		r := backfill.Range{StartingBlock: 10, EndingBlock: 20}
+
+		Expect(r.NextBlock()).To(Equal(int64(10)))
+	})
+
+	It("starts after the last completed block", func() {
+		r := backfill.Range{StartingBlock: 10, EndingBlock: 20, LastBlock: sql.NullInt64{Int64: 15, Valid: true}}
+
+		Expect(r.NextBlock()).To(Equal(int64(16)))
+	})
+})
+
+var _ = Describe("CheckArchiveNode", func() {
+	It("recognises pruned state errors from other clients", func() {
+		bc := fakes.NewMockBlockChain()
+		bc.BatchGetStorageAtError = errors.New("This request is not supported because your node is running with state pruning")
+
+		err := backfill.CheckArchiveNode(bc, test_data.FakeAddress(), 1)
+
+		Expect(err).To(MatchError(backfill.ErrArchiveNodeRequired))
+	})
+
+	It("asks for storage at the given block", func() {
+		bc := fakes.NewMockBlockChain()
+
+		err := backfill.CheckArchiveNode(bc, test_data.FakeAddress(), 123)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(bc.BatchGetStorageAtCalls[0].BlockNumber).To(Equal(big.NewInt(123)))
+	})
+})
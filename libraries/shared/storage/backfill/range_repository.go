@@ -0,0 +1,163 @@
+package backfill
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/lib/pq"
+	"github.com/makerdao/vulcanizedb/pkg/core"
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres"
+)
+
+// Range is a span of blocks back-filled by one worker, with the last block completed so far
+type Range struct {
+	ID            int64
+	Job           string
+	StartingBlock int64         `db:"starting_block"`
+	EndingBlock   int64         `db:"ending_block"`
+	LastBlock     sql.NullInt64 `db:"last_block"`
+}
+
+// NextBlock is the first block in the range that hasn't been back-filled
+func (r Range) NextBlock() int64 {
+	if r.LastBlock.Valid {
+		return r.LastBlock.Int64 + 1
+	}
+	return r.StartingBlock
+}
+
+type RangeRepository interface {
+	CreateRanges(job string, startingBlock, endingBlock, rangeSize int64) error
+	GetIncompleteRanges(job string, startingBlock, endingBlock int64) ([]Range, error)
+	UpdateLastBlock(id, lastBlock int64) error
+}
+
+type rangeRepository struct {
+	db *postgres.DB
+}
+
+func NewRangeRepository(db *postgres.DB) rangeRepository {
+	return rangeRepository{db: db}
+}
+
+// CreateRanges splits the blocks into ranges of rangeSize for the job, leaving existing ranges as they are
+func (repository rangeRepository) CreateRanges(job string, startingBlock, endingBlock, rangeSize int64) error {
+	tx, txErr := repository.db.Beginx()
+	if txErr != nil {
+		return fmt.Errorf("error beginning transaction to create back-fill ranges: %w", txErr)
+	}
+	for start := startingBlock; start <= endingBlock; start += rangeSize {
+		end := start + rangeSize - 1
+		if end > endingBlock {
+			end = endingBlock
+		}
+		_, insertErr := tx.Exec(`INSERT INTO public.storage_backfill_range (job, starting_block, ending_block)
+			VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`, job, start, end)
+		if insertErr != nil {
+			rollbackErr := tx.Rollback()
+			if rollbackErr != nil {
+				return fmt.Errorf("error rolling back back-fill ranges: %w", rollbackErr)
+			}
+			return fmt.Errorf("error creating back-fill range %d-%d: %w", start, end, insertErr)
+		}
+	}
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		return fmt.Errorf("error committing back-fill ranges: %w", commitErr)
+	}
+	return nil
+}
+
+func (repository rangeRepository) GetIncompleteRanges(job string, startingBlock, endingBlock int64) ([]Range, error) {
+	var ranges []Range
+	err := repository.db.Select(&ranges, `SELECT id, job, starting_block, ending_block, last_block
+		FROM public.storage_backfill_range
+		WHERE job = $1 AND starting_block >= $2 AND ending_block <= $3
+			AND (last_block IS NULL OR last_block < ending_block)
+		ORDER BY starting_block`, job, startingBlock, endingBlock)
+	if err != nil {
+		return nil, fmt.Errorf("error getting incomplete back-fill ranges: %w", err)
+	}
+	return ranges, nil
+}
+
+func (repository rangeRepository) UpdateLastBlock(id, lastBlock int64) error {
+	_, err := repository.db.Exec(`UPDATE public.storage_backfill_range SET last_block = $1 WHERE id = $2`,
+		lastBlock, id)
+	if err != nil {
+		return fmt.Errorf("error updating back-fill range %d to block %d: %w", id, lastBlock, err)
+	}
+	return nil
+}
+
+// LogHeaderRepository finds the headers of blocks where watched addresses emitted logs
+type LogHeaderRepository interface {
+	GetHeadersWithLogs(addresses []common.Address, startingBlock, endingBlock int64) ([]core.Header, error)
+}
+
+type logHeaderRepository struct {
+	db *postgres.DB
+}
+
+func NewLogHeaderRepository(db *postgres.DB) logHeaderRepository {
+	return logHeaderRepository{db: db}
+}
+
+func (repository logHeaderRepository) GetHeadersWithLogs(addresses []common.Address, startingBlock, endingBlock int64) ([]core.Header, error) {
+	hexAddresses := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		hexAddresses = append(hexAddresses, address.Hex())
+	}
+	var headers []core.Header
+	err := repository.db.Select(&headers, `SELECT DISTINCT headers.id, headers.block_number, headers.hash, headers.raw,
+			headers.block_timestamp
+		FROM public.headers
+			JOIN public.event_logs ON event_logs.header_id = headers.id
+			JOIN public.addresses ON addresses.id = event_logs.address
+		WHERE addresses.address = ANY($1) AND headers.block_number BETWEEN $2 AND $3
+		ORDER BY headers.block_number`, pq.Array(hexAddresses), startingBlock, endingBlock)
+	if err != nil {
+		return nil, fmt.Errorf("error getting headers with logs from blocks %d-%d: %w", startingBlock, endingBlock, err)
+	}
+	return headers, nil
+}
+
+// StorageValueRepository reads the storage values already persisted for a contract
+type StorageValueRepository interface {
+	GetStorageValuesAt(address common.Address, blockHeight int64) (map[common.Hash]common.Hash, error)
+}
+
+type storageValueRepository struct {
+	db *postgres.DB
+}
+
+func NewStorageValueRepository(db *postgres.DB) storageValueRepository {
+	return storageValueRepository{db: db}
+}
+
+// GetStorageValuesAt returns the latest canonical value of each of the contract's storage keys at or before the block,
+// from diffs whose headers have been synced
+func (repository storageValueRepository) GetStorageValuesAt(address common.Address, blockHeight int64) (map[common.Hash]common.Hash, error) {
+	var rows []struct {
+		StorageKey   []byte `db:"storage_key"`
+		StorageValue []byte `db:"storage_value"`
+	}
+	err := repository.db.Select(&rows, `SELECT DISTINCT ON (storage_diff.storage_key) storage_diff.storage_key,
+			storage_diff.storage_value
+		FROM public.storage_diff
+			JOIN public.headers ON headers.block_number = storage_diff.block_height
+				AND headers.hash = '0x' || encode(storage_diff.block_hash, 'hex')
+		WHERE storage_diff.address = $1 AND storage_diff.block_height <= $2
+			AND storage_diff.status != 'noncanonical'
+		ORDER BY storage_diff.storage_key, storage_diff.block_height DESC, storage_diff.id DESC`,
+		address.Bytes(), blockHeight)
+	if err != nil {
+		return nil, fmt.Errorf("error getting storage values of %s at block %d: %w", address.Hex(), blockHeight, err)
+	}
+	values := make(map[common.Hash]common.Hash, len(rows))
+	for _, row := range rows {
+		values[common.BytesToHash(row.StorageKey)] = common.BytesToHash(row.StorageValue)
+	}
+	return values, nil
+}
@@ -0,0 +1,171 @@
+package backfill_test
+
+import (
+	"database/sql"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/backfill"
+	storageTypes "github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
+	"github.com/makerdao/vulcanizedb/libraries/shared/test_data"
+	"github.com/makerdao/vulcanizedb/pkg/datastore"
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres"
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres/repositories"
+	"github.com/makerdao/vulcanizedb/pkg/fakes"
+	"github.com/makerdao/vulcanizedb/test_config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Back-fill range repository", func() {
+	var (
+		db   = test_config.NewTestDB(test_config.NewTestNode())
+		repo backfill.RangeRepository
+		job  = "job"
+	)
+
+	BeforeEach(func() {
+		test_config.CleanTestDB(db)
+		repo = backfill.NewRangeRepository(db)
+	})
+
+	It("splits the blocks into ranges", func() {
+		Expect(repo.CreateRanges(job, 0, 24, 10)).To(Succeed())
+
+		ranges, err := repo.GetIncompleteRanges(job, 0, 24)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(len(ranges)).To(Equal(3))
+		Expect([]int64{ranges[0].StartingBlock, ranges[0].EndingBlock}).To(Equal([]int64{0, 9}))
+		Expect([]int64{ranges[1].StartingBlock, ranges[1].EndingBlock}).To(Equal([]int64{10, 19}))
+		Expect([]int64{ranges[2].StartingBlock, ranges[2].EndingBlock}).To(Equal([]int64{20, 24}))
+		Expect(ranges[0].LastBlock.Valid).To(BeFalse())
+	})
+
+	It("keeps the progress of existing ranges", func() {
+		Expect(repo.CreateRanges(job, 0, 9, 10)).To(Succeed())
+		ranges, err := repo.GetIncompleteRanges(job, 0, 9)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(repo.UpdateLastBlock(ranges[0].ID, 4)).To(Succeed())
+
+		Expect(repo.CreateRanges(job, 0, 9, 10)).To(Succeed())
+
+		resumed, resumeErr := repo.GetIncompleteRanges(job, 0, 9)
+		Expect(resumeErr).NotTo(HaveOccurred())
+		Expect(len(resumed)).To(Equal(1))
+		Expect(resumed[0].LastBlock).To(Equal(sql.NullInt64{Int64: 4, Valid: true}))
+	})
+
+	It("doesn't return completed ranges, or ranges of other jobs or blocks", func() {
+		Expect(repo.CreateRanges(job, 0, 19, 10)).To(Succeed())
+		Expect(repo.CreateRanges("other job", 0, 19, 10)).To(Succeed())
+		Expect(repo.CreateRanges(job, 100, 109, 10)).To(Succeed())
+		ranges, err := repo.GetIncompleteRanges(job, 0, 19)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(repo.UpdateLastBlock(ranges[0].ID, 9)).To(Succeed())
+
+		incomplete, incompleteErr := repo.GetIncompleteRanges(job, 0, 19)
+
+		Expect(incompleteErr).NotTo(HaveOccurred())
+		Expect(len(incomplete)).To(Equal(1))
+		Expect(incomplete[0].StartingBlock).To(Equal(int64(10)))
+		Expect(incomplete[0].Job).To(Equal(job))
+	})
+})
+
+var _ = Describe("Log header repository", func() {
+	var (
+		db         = test_config.NewTestDB(test_config.NewTestNode())
+		repo       backfill.LogHeaderRepository
+		headerRepo = repositories.NewHeaderRepository(db)
+	)
+
+	BeforeEach(func() {
+		test_config.CleanTestDB(db)
+		repo = backfill.NewLogHeaderRepository(db)
+	})
+
+	It("gets headers of blocks where the addresses emitted logs", func() {
+		withLog := fakes.GetFakeHeader(10)
+		withLogID, headerErr := headerRepo.CreateOrUpdateHeader(withLog)
+		Expect(headerErr).NotTo(HaveOccurred())
+		withoutLog := fakes.GetFakeHeader(11)
+		_, headerErr = headerRepo.CreateOrUpdateHeader(withoutLog)
+		Expect(headerErr).NotTo(HaveOccurred())
+		withOtherLog := fakes.GetFakeHeader(12)
+		withOtherLogID, headerErr := headerRepo.CreateOrUpdateHeader(withOtherLog)
+		Expect(headerErr).NotTo(HaveOccurred())
+
+		address := test_data.FakeAddress()
+		log := test_data.GenericTestLog()
+		log.Address = address
+		createLog(db, withLogID, log, headerRepo)
+		otherLog := test_data.GenericTestLog()
+		createLog(db, withOtherLogID, otherLog, headerRepo)
+
+		headers, err := repo.GetHeadersWithLogs([]common.Address{address}, 0, 20)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(len(headers)).To(Equal(1))
+		Expect(headers[0].BlockNumber).To(Equal(int64(10)))
+		Expect(headers[0].Hash).To(Equal(withLog.Hash))
+	})
+})
+
+var _ = Describe("Storage value repository", func() {
+	var (
+		db         = test_config.NewTestDB(test_config.NewTestNode())
+		repo       backfill.StorageValueRepository
+		diffRepo   storage.DiffRepository
+		headerRepo = repositories.NewHeaderRepository(db)
+		address    = test_data.FakeAddress()
+		key        = test_data.FakeHash()
+		otherKey   = test_data.FakeHash()
+		blockHash  = test_data.FakeHash()
+	)
+
+	BeforeEach(func() {
+		test_config.CleanTestDB(db)
+		repo = backfill.NewStorageValueRepository(db)
+		diffRepo = storage.NewDiffRepository(db)
+	})
+
+	createDiff := func(blockHeight int, storageKey, value common.Hash) int64 {
+		header := fakes.GetFakeHeader(int64(blockHeight))
+		header.Hash = blockHash.Hex()
+		_, headerErr := headerRepo.CreateOrUpdateHeader(header)
+		Expect(headerErr).NotTo(HaveOccurred())
+		id, err := diffRepo.CreateStorageDiff(storageTypes.RawDiff{
+			Address:      address,
+			BlockHash:    blockHash,
+			BlockHeight:  blockHeight,
+			StorageKey:   storageKey,
+			StorageValue: value,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		return id
+	}
+
+	It("gets the latest canonical value of each key at or before the block", func() {
+		createDiff(1, key, common.HexToHash("0x01"))
+		createDiff(2, otherKey, common.HexToHash("0x02"))
+		noncanonicalID := createDiff(3, key, common.HexToHash("0x03"))
+		Expect(diffRepo.MarkNoncanonical(noncanonicalID)).To(Succeed())
+		createDiff(4, key, common.HexToHash("0x04"))
+
+		values, err := repo.GetStorageValuesAt(address, 3)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(values).To(Equal(map[common.Hash]common.Hash{
+			key:      common.HexToHash("0x01"),
+			otherKey: common.HexToHash("0x02"),
+		}))
+	})
+})
+
+func createLog(db *postgres.DB, headerID int64, log types.Log, headerRepo datastore.HeaderRepository) {
+	test_data.CreateMatchingTx(log, headerID, headerRepo)
+	err := repositories.NewEventLogRepository(db).CreateEventLogs(headerID, []types.Log{log})
+	Expect(err).NotTo(HaveOccurred())
+}
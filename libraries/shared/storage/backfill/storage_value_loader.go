@@ -2,11 +2,13 @@ package backfill
 
 import (
 	"errors"
+	"fmt"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/makerdao/vulcanizedb/libraries/shared/factories/storage"
 	storage2 "github.com/makerdao/vulcanizedb/libraries/shared/storage"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/fetcher"
 	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
 	"github.com/makerdao/vulcanizedb/pkg/core"
 	"github.com/makerdao/vulcanizedb/pkg/datastore"
@@ -50,69 +52,121 @@ type StorageValueLoader struct {
 }
 
 func (r *StorageValueLoader) Run() error {
+	_, runErr := r.run()
+	return runErr
+}
+
+// run persists storage values at each synced header in the range, returning how many blocks had a header
+func (r *StorageValueLoader) run() (int, error) {
 	if r.storageByAddress == nil {
-		return ErrNoTransformers
+		return 0, ErrNoTransformers
 	}
 	getKeysErr := r.addKeysToStorageByAddress()
 	if getKeysErr != nil {
-		return getKeysErr
+		return 0, getKeysErr
 	}
 	headers, getHeadersErr := r.HeaderRepo.GetHeadersInRange(r.startingBlock, r.endingBlock)
 	if getHeadersErr != nil {
-		return getHeadersErr
+		return 0, getHeadersErr
 	}
 
+	blocks := make(map[int64]bool, len(headers))
 	for _, header := range headers {
 		persistStorageErr := r.getAndPersistStorageValues(header.BlockNumber, header.Hash)
 		if persistStorageErr != nil {
-			return persistStorageErr
+			return 0, persistStorageErr
 		}
-
+		blocks[header.BlockNumber] = true
 	}
 	logrus.Infof("Finished persisting storage values for %v addresses from block %v to %v.", len(r.storageByAddress), r.startingBlock, r.endingBlock)
 
+	return len(blocks), nil
+}
+
+// FillGap persists storage values for the given range of blocks, starting from empty values so that every non-empty
+// value in the range is recorded. Returns an error wrapping fetcher.ErrIncompleteGap if headers in the range haven't
+// been synced, since storage can't be loaded for them.
+func (r *StorageValueLoader) FillGap(startingBlock, endingBlock int64) error {
+	if r.storageByAddress == nil {
+		return ErrNoTransformers
+	}
+	r.storageByAddress = make(map[common.Address]chunksOfKeysToValues, len(r.initializers))
+	r.startingBlock = startingBlock
+	r.endingBlock = endingBlock
+	blockCount, runErr := r.run()
+	if runErr != nil {
+		return runErr
+	}
+	if missing := endingBlock - startingBlock + 1 - int64(blockCount); missing > 0 {
+		return fmt.Errorf("%w: %d headers in blocks %d-%d not synced", fetcher.ErrIncompleteGap, missing,
+			startingBlock, endingBlock)
+	}
 	return nil
 }
 
 func (r *StorageValueLoader) addKeysToStorageByAddress() error {
-	for _, i := range r.initializers {
-		transformer := i(r.db)
+	keysByAddress, getKeysErr := getKeysByAddress(r.db, r.initializers)
+	if getKeysErr != nil {
+		return getKeysErr
+	}
+	for address, keys := range keysByAddress {
+		r.storageByAddress[address] = newChunksOfKeysToValues(keys)
+	}
+	return nil
+}
+
+// getKeysByAddress gets the storage keys known to each transformer's keys lookup
+func getKeysByAddress(db *postgres.DB, initializers []storage.TransformerInitializer) (map[common.Address][]storageKey, error) {
+	keysByAddress := make(map[common.Address][]storageKey, len(initializers))
+	for _, i := range initializers {
+		transformer := i(db)
 		keysLookup := transformer.GetStorageKeysLookup()
 		keys, getKeysErr := keysLookup.GetKeys()
 		if getKeysErr != nil {
-			return getKeysErr
+			return nil, getKeysErr
 		}
 		address := transformer.GetContractAddress()
-		chunkedKeys := chunkKeys(keys)
-		for chunkIndex, chunk := range chunkedKeys {
-			nextChunkOfKeysToValues := make(map[storageKey]storageValue, len(chunk))
-			r.storageByAddress[address] = append(r.storageByAddress[address], nextChunkOfKeysToValues)
-			for _, key := range chunk {
-				// set default initial value to empty
-				r.storageByAddress[address][chunkIndex][key] = emptyStorageValue
-			}
-		}
+		keysByAddress[address] = append(keysByAddress[address], keys...)
 		logrus.Infof("Received %v storage keys for address:%v", len(keys), address.Hex())
 	}
+	return keysByAddress, nil
+}
 
-	return nil
+// newChunksOfKeysToValues splits keys into request-sized chunks, with every value initially empty
+func newChunksOfKeysToValues(keys []storageKey) chunksOfKeysToValues {
+	var result chunksOfKeysToValues
+	for _, chunk := range chunkKeys(keys) {
+		keysToValues := make(map[storageKey]storageValue, len(chunk))
+		for _, key := range chunk {
+			keysToValues[key] = emptyStorageValue
+		}
+		result = append(result, keysToValues)
+	}
+	return result
 }
 
 func (r *StorageValueLoader) getAndPersistStorageValues(blockNumber int64, headerHashStr string) error {
+	return persistStorageValues(r.bc, r.StorageDiffRepo, r.storageByAddress, blockNumber, headerHashStr)
+}
+
+// persistStorageValues gets the values of every key at the block, persisting those that differ from the last known
+// value and updating storageByAddress
+func persistStorageValues(bc core.BlockChain, diffRepo storage2.DiffRepository,
+	storageByAddress map[common.Address]chunksOfKeysToValues, blockNumber int64, headerHashStr string) error {
 	blockNumberBigInt := big.NewInt(blockNumber)
 	blockHash := common.HexToHash(headerHashStr)
 
-	for address, chunkedKeysToValues := range r.storageByAddress {
-		for chunkIndex, currentKeysToValues := range chunkedKeysToValues {
+	for address, chunkedKeysToValues := range storageByAddress {
+		for _, currentKeysToValues := range chunkedKeysToValues {
 			var keys []storageKey
-			for key, _ := range currentKeysToValues {
+			for key := range currentKeysToValues {
 				keys = append(keys, key)
 			}
 			logrus.WithFields(logrus.Fields{
 				"Address":     address.Hex(),
 				"BlockNumber": blockNumber,
 			}).Infof("Getting and persisting %v storage values", len(keys))
-			newKeysToValues, getStorageValuesErr := r.bc.BatchGetStorageAt(address, keys, blockNumberBigInt)
+			newKeysToValues, getStorageValuesErr := bc.BatchGetStorageAt(address, keys, blockNumberBigInt)
 			if getStorageValuesErr != nil {
 				return getStorageValuesErr
 			}
@@ -128,11 +182,11 @@ func (r *StorageValueLoader) getAndPersistStorageValues(blockNumber int64, heade
 						StorageKey:   key,
 						StorageValue: newValueHash,
 					}
-					createDiffErr := r.StorageDiffRepo.CreateBackFilledStorageValue(diff)
+					createDiffErr := diffRepo.CreateBackFilledStorageValue(diff)
 					if createDiffErr != nil {
 						return createDiffErr
 					}
-					r.storageByAddress[address][chunkIndex][key] = newValueHash
+					currentKeysToValues[key] = newValueHash
 				}
 			}
 		}
@@ -8,6 +8,7 @@ import (
 	"github.com/makerdao/vulcanizedb/libraries/shared/factories/storage"
 	"github.com/makerdao/vulcanizedb/libraries/shared/mocks"
 	"github.com/makerdao/vulcanizedb/libraries/shared/storage/backfill"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/fetcher"
 	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
 	"github.com/makerdao/vulcanizedb/libraries/shared/test_data"
 	"github.com/makerdao/vulcanizedb/pkg/core"
@@ -282,4 +283,46 @@ var _ = Describe("StorageValueLoader", func() {
 		Expect(runnerErr).To(HaveOccurred())
 		Expect(runnerErr).To(Equal(fakes.FakeError))
 	})
+
+	Describe("FillGap", func() {
+		It("returns error if loader initialized without transformers", func() {
+			runner = backfill.StorageValueLoader{}
+
+			err := runner.FillGap(blockOne, blockTwo)
+
+			Expect(err).To(MatchError(backfill.ErrNoTransformers))
+		})
+
+		It("fetches headers in the given gap", func() {
+			gapStart, gapEnd := blockTwo+1, blockTwo+2
+			gapStartHeader, gapEndHeader := fakes.FakeHeader, fakes.FakeHeader
+			gapStartHeader.BlockNumber, gapEndHeader.BlockNumber = gapStart, gapEnd
+			headerRepo.AllHeaders = []core.Header{gapStartHeader, gapEndHeader}
+
+			err := runner.FillGap(gapStart, gapEnd)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(headerRepo.GetHeadersInRangeStartingBlocks).To(ConsistOf(gapStart))
+			Expect(headerRepo.GetHeadersInRangeEndingBlocks).To(ConsistOf(gapEnd))
+		})
+
+		It("persists values for each gap without carrying over values from a previous run", func() {
+			runErr := runner.Run()
+			Expect(runErr).NotTo(HaveOccurred())
+			Expect(len(diffRepo.CreateBackFilledStorageValuePassedRawDiffs)).To(Equal(2))
+
+			fillErr := runner.FillGap(blockOne, blockOne)
+
+			Expect(fillErr).NotTo(HaveOccurred())
+			Expect(len(diffRepo.CreateBackFilledStorageValuePassedRawDiffs)).To(Equal(4))
+			Expect(keysLookupOne.GetKeysCalled).To(BeTrue())
+		})
+
+		It("returns an error if headers in the gap haven't been synced", func() {
+			err := runner.FillGap(blockOne, blockTwo)
+
+			Expect(err).To(MatchError(fetcher.ErrIncompleteGap))
+			Expect(len(diffRepo.CreateBackFilledStorageValuePassedRawDiffs)).To(Equal(2))
+		})
+	})
 })
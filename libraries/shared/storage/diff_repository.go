@@ -17,37 +17,98 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/lib/pq"
 	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
 	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres"
 )
 
 type DiffRepository interface {
 	CreateStorageDiff(rawDiff types.RawDiff) (int64, error)
+	CreateStorageDiffs(rawDiffs []types.RawDiff) (int64, error)
 	CreateBackFilledStorageValue(rawDiff types.RawDiff) error
 	GetNewDiffs(minID, limit int) ([]types.PersistedDiff, error)
+	GetNewDiffsForAddress(address common.Address, cursor DiffCursor, limit int) ([]types.PersistedDiff, error)
 	MarkTransformed(id int64) error
 	MarkNoncanonical(id int64) error
 	MarkUnrecognized(id int64) error
 	MarkUnwatched(id int64) error
-	GetFirstDiffIDForBlockHeight(blockHeight int64) (int64, error)
+	MarkPendingHeader(id int64) error
+	HasPendingHeaderPredecessor(diff types.PersistedDiff) (bool, error)
+	ReleasePendingHeaderDiffs(address common.Address) (int64, error)
+	MarkUnwatchedExcept(addresses []common.Address) (int64, error)
+	ResetDiffs(filter ResetFilter) (int64, error)
 }
 
 var (
-	New          = `new`
-	Noncanonical = `noncanonical`
-	Transformed  = `transformed`
-	Unrecognized = `unrecognized`
-	Unwatched    = `unwatched`
+	New           = `new`
+	Noncanonical  = `noncanonical`
+	Transformed   = `transformed`
+	Unrecognized  = `unrecognized`
+	Unwatched     = `unwatched`
+	Abandoned     = `abandoned`
+	PendingHeader = `pending_header`
 )
 
+// RetryPolicy controls how often an unrecognized diff is retried before it is marked abandoned.
+// The delay before the nth retry is InitialBackoff * 2^(n-1), capped at MaxBackoff. A MaxAttempts of zero or less
+// retries unrecognized diffs indefinitely.
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    25,
+	InitialBackoff: time.Minute,
+	MaxBackoff:     6 * time.Hour,
+}
+
+// ResetFilter selects unrecognized or abandoned diffs to be retried from scratch, e.g. after deploying a new
+// keys loader. Empty fields don't restrict the selection, but at least one field must be set.
+type ResetFilter struct {
+	IDs         []int64
+	Address     common.Address
+	StorageKeys []common.Hash
+	StartBlock  int64
+	EndBlock    int64
+}
+
+func (filter ResetFilter) isEmpty() bool {
+	return len(filter.IDs) == 0 && filter.Address == (common.Address{}) && len(filter.StorageKeys) == 0 &&
+		filter.StartBlock <= 0 && filter.EndBlock <= 0
+}
+
+var ErrEmptyResetFilter = errors.New("at least one diff id, address, storage key, or block bound is required")
+
+// DiffCursor is a position in the (block_height, id) ordering of a contract's storage diffs
+type DiffCursor struct {
+	BlockHeight int64
+	ID          int64
+}
+
+// NewDiffCursor returns a cursor positioned before every diff at or above the given block height
+func NewDiffCursor(blockHeight int64) DiffCursor {
+	return DiffCursor{BlockHeight: blockHeight, ID: 0}
+}
+
 type diffRepository struct {
-	db *postgres.DB
+	db          *postgres.DB
+	retryPolicy RetryPolicy
 }
 
 func NewDiffRepository(db *postgres.DB) diffRepository {
-	return diffRepository{db: db}
+	return NewDiffRepositoryWithRetryPolicy(db, DefaultRetryPolicy)
+}
+
+func NewDiffRepositoryWithRetryPolicy(db *postgres.DB, retryPolicy RetryPolicy) diffRepository {
+	return diffRepository{db: db, retryPolicy: retryPolicy}
 }
 
 // CreateStorageDiff writes a raw storage diff to the database
@@ -64,6 +125,42 @@ func (repository diffRepository) CreateStorageDiff(rawDiff types.RawDiff) (int64
 	return storageDiffID, nil
 }
 
+// maxDiffsPerInsert keeps multi-row inserts under postgres' limit of 65535 bind parameters
+const maxDiffsPerInsert = 1000
+
+// CreateStorageDiffs writes raw storage diffs with multi-row inserts, skipping duplicates. It returns how many diffs
+// were new.
+func (repository diffRepository) CreateStorageDiffs(rawDiffs []types.RawDiff) (int64, error) {
+	var created int64
+	for start := 0; start < len(rawDiffs); start += maxDiffsPerInsert {
+		end := start + maxDiffsPerInsert
+		if end > len(rawDiffs) {
+			end = len(rawDiffs)
+		}
+		chunk := rawDiffs[start:end]
+		values := make([]string, 0, len(chunk))
+		args := make([]interface{}, 0, len(chunk)*6)
+		for i, rawDiff := range chunk {
+			n := i * 6
+			values = append(values, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6))
+			args = append(args, rawDiff.Address.Bytes(), rawDiff.BlockHeight, rawDiff.BlockHash.Bytes(),
+				rawDiff.StorageKey.Bytes(), rawDiff.StorageValue.Bytes(), repository.db.NodeID)
+		}
+		result, err := repository.db.Exec(`INSERT INTO public.storage_diff
+			(address, block_height, block_hash, storage_key, storage_value, eth_node_id) VALUES `+
+			strings.Join(values, ", ")+` ON CONFLICT DO NOTHING`, args...)
+		if err != nil {
+			return created, fmt.Errorf("error creating %d storage diffs: %w", len(chunk), err)
+		}
+		rows, rowsErr := result.RowsAffected()
+		if rowsErr != nil {
+			return created, fmt.Errorf("error counting created storage diffs: %w", rowsErr)
+		}
+		created += rows
+	}
+	return created, nil
+}
+
 func (repository diffRepository) CreateBackFilledStorageValue(rawDiff types.RawDiff) error {
 	_, err := repository.db.Exec(`SELECT * FROM public.create_back_filled_diff($1, $2, $3, $4, $5, $6)`,
 		rawDiff.BlockHeight, rawDiff.BlockHash.Bytes(), rawDiff.Address.Bytes(),
@@ -78,7 +175,9 @@ func (repository diffRepository) GetNewDiffs(minID, limit int) ([]types.Persiste
 	var result []types.PersistedDiff
 	err := repository.db.Select(
 		&result,
-		`SELECT * FROM public.storage_diff WHERE (status = $1 OR status = $2) AND id > $3 ORDER BY id ASC LIMIT $4`,
+		`SELECT * FROM public.storage_diff
+			WHERE (status = $1 OR (status = $2 AND (next_attempt_at IS NULL OR next_attempt_at <= NOW())))
+			AND id > $3 ORDER BY id ASC LIMIT $4`,
 		New, Unrecognized, minID, limit,
 	)
 	if err != nil {
@@ -87,6 +186,25 @@ func (repository diffRepository) GetNewDiffs(minID, limit int) ([]types.Persiste
 	return result, nil
 }
 
+// GetNewDiffsForAddress returns diffs ready to be transformed for one contract after the cursor, ordered by block
+// height so that values for the same storage slot are always transformed in the order they were written
+func (repository diffRepository) GetNewDiffsForAddress(address common.Address, cursor DiffCursor, limit int) ([]types.PersistedDiff, error) {
+	var result []types.PersistedDiff
+	err := repository.db.Select(
+		&result,
+		`SELECT * FROM public.storage_diff
+			WHERE address = $1
+			AND (status = $2 OR (status = $3 AND (next_attempt_at IS NULL OR next_attempt_at <= NOW())))
+			AND (block_height, id) > ($4, $5)
+			ORDER BY block_height ASC, id ASC LIMIT $6`,
+		address.Bytes(), New, Unrecognized, cursor.BlockHeight, cursor.ID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error getting unchecked storage diffs for address %s after %+v: %w", address.Hex(), cursor, err)
+	}
+	return result, nil
+}
+
 func (repository diffRepository) MarkTransformed(id int64) error {
 	_, err := repository.db.Exec(`UPDATE public.storage_diff SET status = $1 WHERE id = $2`, Transformed, id)
 	if err != nil {
@@ -95,8 +213,16 @@ func (repository diffRepository) MarkTransformed(id int64) error {
 	return nil
 }
 
+// MarkUnrecognized records a failed attempt to recognize the diff's storage key, scheduling the next attempt with
+// exponential backoff or marking the diff abandoned once the retry policy's max attempts are exhausted
 func (repository diffRepository) MarkUnrecognized(id int64) error {
-	_, err := repository.db.Exec(`UPDATE public.storage_diff SET status = $1 WHERE id = $2`, Unrecognized, id)
+	_, err := repository.db.Exec(`UPDATE public.storage_diff
+		SET retry_count     = retry_count + 1,
+			status          = (CASE WHEN $2 > 0 AND retry_count + 1 >= $2 THEN $3 ELSE $4 END)::public.diff_status,
+			next_attempt_at = NOW() + LEAST($5 * POWER(2, LEAST(retry_count, 32)), $6) * INTERVAL '1 second'
+		WHERE id = $1`,
+		id, repository.retryPolicy.MaxAttempts, Abandoned, Unrecognized,
+		repository.retryPolicy.InitialBackoff.Seconds(), repository.retryPolicy.MaxBackoff.Seconds())
 	if err != nil {
 		return fmt.Errorf("error marking diff %d checked: %w", id, err)
 	}
@@ -119,12 +245,95 @@ func (repository diffRepository) MarkUnwatched(id int64) error {
 	return nil
 }
 
-func (repository diffRepository) GetFirstDiffIDForBlockHeight(blockHeight int64) (int64, error) {
-	var diffID int64
-	err := repository.db.Get(&diffID,
-		`SELECT id FROM public.storage_diff WHERE block_height >= $1 LIMIT 1`, blockHeight)
+// MarkPendingHeader parks a diff whose block has no header yet, so that it's skipped until the header is synced
+func (repository diffRepository) MarkPendingHeader(id int64) error {
+	_, err := repository.db.Exec(`UPDATE public.storage_diff SET status = $1 WHERE id = $2`, PendingHeader, id)
+	if err != nil {
+		return fmt.Errorf("error marking diff %d pending header: %w", id, err)
+	}
+	return nil
+}
+
+// HasPendingHeaderPredecessor checks whether an earlier diff for the same storage slot is still waiting on its header,
+// in which case the given diff must wait too so that it doesn't overtake the earlier value
+func (repository diffRepository) HasPendingHeaderPredecessor(diff types.PersistedDiff) (bool, error) {
+	var exists bool
+	err := repository.db.Get(&exists, `SELECT EXISTS(SELECT 1 FROM public.storage_diff
+		WHERE status = $1 AND address = $2 AND storage_key = $3
+		AND (block_height < $4 OR (block_height = $4 AND id < $5)))`,
+		PendingHeader, diff.Address.Bytes(), diff.StorageKey.Bytes(), diff.BlockHeight, diff.ID)
 	if err != nil {
-		return diffID, fmt.Errorf("error getting first diff ID for block height %d: %w", blockHeight, err)
+		return false, fmt.Errorf("error checking for pending predecessors of diff %d: %w", diff.ID, err)
+	}
+	return exists, nil
+}
+
+// ReleasePendingHeaderDiffs returns a contract's diffs whose header has since been synced to the new status, returning
+// the lowest released block height (or -1 if none were released) so that the caller can rewind its cursor
+func (repository diffRepository) ReleasePendingHeaderDiffs(address common.Address) (int64, error) {
+	var minReleasedBlockHeight int64
+	err := repository.db.Get(&minReleasedBlockHeight, `WITH released AS (
+			UPDATE public.storage_diff SET status = $1
+			WHERE status = $2 AND address = $3
+			AND EXISTS(SELECT 1 FROM public.headers WHERE headers.block_number = storage_diff.block_height)
+			RETURNING block_height
+		)
+		SELECT COALESCE(MIN(block_height), -1) FROM released`, New, PendingHeader, address.Bytes())
+	if err != nil {
+		return 0, fmt.Errorf("error releasing diffs pending header for address %s: %w", address.Hex(), err)
+	}
+	return minReleasedBlockHeight, nil
+}
+
+// MarkUnwatchedExcept marks new diffs for every contract not in the given list as unwatched, returning the number of
+// diffs marked
+func (repository diffRepository) MarkUnwatchedExcept(addresses []common.Address) (int64, error) {
+	watched := make([][]byte, len(addresses))
+	for i, address := range addresses {
+		watched[i] = address.Bytes()
+	}
+	result, err := repository.db.Exec(`UPDATE public.storage_diff SET status = $1
+		WHERE status = $2 AND address <> ALL($3::BYTEA[])`, Unwatched, New, pq.ByteaArray(watched))
+	if err != nil {
+		return 0, fmt.Errorf("error marking diffs %s: %w", Unwatched, err)
+	}
+	rowsAffected, rowsErr := result.RowsAffected()
+	if rowsErr != nil {
+		return 0, fmt.Errorf("error getting number of unwatched diffs: %w", rowsErr)
+	}
+	return rowsAffected, nil
+}
+
+// ResetDiffs returns unrecognized and abandoned diffs matching the filter to the new status with no recorded
+// retries, returning the number of diffs reset
+func (repository diffRepository) ResetDiffs(filter ResetFilter) (int64, error) {
+	if filter.isEmpty() {
+		return 0, ErrEmptyResetFilter
+	}
+	storageKeys := make([][]byte, len(filter.StorageKeys))
+	for i, key := range filter.StorageKeys {
+		storageKeys[i] = key.Bytes()
+	}
+	var address []byte
+	if filter.Address != (common.Address{}) {
+		address = filter.Address.Bytes()
+	}
+	result, err := repository.db.Exec(`UPDATE public.storage_diff
+		SET status = $1, retry_count = 0, next_attempt_at = NULL
+		WHERE (status = $2 OR status = $3)
+		AND (CARDINALITY($4::BIGINT[]) = 0 OR id = ANY($4::BIGINT[]))
+		AND ($5::BYTEA IS NULL OR address = $5)
+		AND (CARDINALITY($6::BYTEA[]) = 0 OR storage_key = ANY($6::BYTEA[]))
+		AND ($7::BIGINT <= 0 OR block_height >= $7::BIGINT)
+		AND ($8::BIGINT <= 0 OR block_height <= $8::BIGINT)`,
+		New, Unrecognized, Abandoned, pq.Array(filter.IDs), address, pq.ByteaArray(storageKeys),
+		filter.StartBlock, filter.EndBlock)
+	if err != nil {
+		return 0, fmt.Errorf("error resetting diffs: %w", err)
+	}
+	rowsAffected, rowsErr := result.RowsAffected()
+	if rowsErr != nil {
+		return 0, fmt.Errorf("error getting number of reset diffs: %w", rowsErr)
 	}
-	return diffID, nil
+	return rowsAffected, nil
 }
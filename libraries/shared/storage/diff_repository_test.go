@@ -19,12 +19,15 @@ package storage_test
 import (
 	"database/sql"
 	"math/rand"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/makerdao/vulcanizedb/libraries/shared/storage"
 	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
 	"github.com/makerdao/vulcanizedb/libraries/shared/test_data"
 	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres"
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres/repositories"
+	"github.com/makerdao/vulcanizedb/pkg/fakes"
 	"github.com/makerdao/vulcanizedb/test_config"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -82,6 +85,55 @@ var _ = Describe("Storage diffs repository", func() {
 		})
 	})
 
+	Describe("CreateStorageDiffs", func() {
+		It("adds storage diffs to the db, returning how many were created", func() {
+			otherDiff := fakeStorageDiff
+			otherDiff.StorageKey = test_data.FakeHash()
+
+			created, createErr := repo.CreateStorageDiffs([]types.RawDiff{fakeStorageDiff, otherDiff})
+
+			Expect(createErr).NotTo(HaveOccurred())
+			Expect(created).To(Equal(int64(2)))
+			var persisted []types.PersistedDiff
+			getErr := db.Select(&persisted, `SELECT * FROM public.storage_diff ORDER BY id`)
+			Expect(getErr).NotTo(HaveOccurred())
+			Expect(len(persisted)).To(Equal(2))
+			Expect(persisted[0].RawDiff).To(Equal(fakeStorageDiff))
+			Expect(persisted[1].RawDiff).To(Equal(otherDiff))
+			Expect(persisted[0].Status).To(Equal(storage.New))
+		})
+
+		It("skips duplicates, within the batch or already persisted", func() {
+			_, createErr := repo.CreateStorageDiff(fakeStorageDiff)
+			Expect(createErr).NotTo(HaveOccurred())
+			otherDiff := fakeStorageDiff
+			otherDiff.StorageKey = test_data.FakeHash()
+
+			created, createTwoErr := repo.CreateStorageDiffs([]types.RawDiff{fakeStorageDiff, otherDiff, otherDiff})
+
+			Expect(createTwoErr).NotTo(HaveOccurred())
+			Expect(created).To(Equal(int64(1)))
+			var count int
+			getErr := db.Get(&count, `SELECT count(*) FROM public.storage_diff`)
+			Expect(getErr).NotTo(HaveOccurred())
+			Expect(count).To(Equal(2))
+		})
+
+		It("creates more diffs than fit in one insert", func() {
+			var diffs []types.RawDiff
+			for i := 0; i < 1001; i++ {
+				diff := fakeStorageDiff
+				diff.StorageKey = test_data.FakeHash()
+				diffs = append(diffs, diff)
+			}
+
+			created, createErr := repo.CreateStorageDiffs(diffs)
+
+			Expect(createErr).NotTo(HaveOccurred())
+			Expect(created).To(Equal(int64(1001)))
+		})
+	})
+
 	Describe("CreateBackFilledStorageValue", func() {
 		It("creates a storage diff", func() {
 			createErr := repo.CreateBackFilledStorageValue(fakeStorageDiff)
@@ -254,6 +306,39 @@ var _ = Describe("Storage diffs repository", func() {
 			Expect(diffs).To(BeEmpty())
 		})
 
+		It("does not send unrecognized diffs before their next attempt", func() {
+			unrecognizedPersistedDiff := types.PersistedDiff{
+				RawDiff:   fakeStorageDiff,
+				ID:        rand.Int63(),
+				Status:    storage.Unrecognized,
+				EthNodeID: db.NodeID,
+			}
+			insertTestDiff(unrecognizedPersistedDiff, db)
+			_, updateErr := db.Exec(`UPDATE public.storage_diff SET next_attempt_at = NOW() + INTERVAL '1 hour' WHERE id = $1`,
+				unrecognizedPersistedDiff.ID)
+			Expect(updateErr).NotTo(HaveOccurred())
+
+			diffs, err := repo.GetNewDiffs(0, 1)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diffs).To(BeEmpty())
+		})
+
+		It("does not send diffs that are marked as abandoned", func() {
+			abandonedPersistedDiff := types.PersistedDiff{
+				RawDiff:   fakeStorageDiff,
+				ID:        rand.Int63(),
+				Status:    storage.Abandoned,
+				EthNodeID: db.NodeID,
+			}
+			insertTestDiff(abandonedPersistedDiff, db)
+
+			diffs, err := repo.GetNewDiffs(0, 1)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diffs).To(BeEmpty())
+		})
+
 		It("enables seeking diffs with greater ID", func() {
 			blockZero := rand.Int()
 			for i := 0; i < 2; i++ {
@@ -286,6 +371,95 @@ var _ = Describe("Storage diffs repository", func() {
 		})
 	})
 
+	Describe("GetNewDiffsForAddress", func() {
+		var address common.Address
+
+		BeforeEach(func() {
+			address = test_data.FakeAddress()
+		})
+
+		insertDiffForAddress := func(blockHeight int, status string) types.PersistedDiff {
+			rawDiff := test_data.FakeRawDiff()
+			rawDiff.Address = address
+			rawDiff.BlockHeight = blockHeight
+			persistedDiff := types.PersistedDiff{
+				RawDiff:   rawDiff,
+				ID:        rand.Int63(),
+				Status:    status,
+				EthNodeID: db.NodeID,
+			}
+			insertTestDiff(persistedDiff, db)
+			return persistedDiff
+		}
+
+		It("sends new diffs for the given address ordered by block height", func() {
+			blockHeight := rand.Intn(1000000)
+			laterDiff := insertDiffForAddress(blockHeight+1, storage.New)
+			earlierDiff := insertDiffForAddress(blockHeight, storage.Unrecognized)
+			insertTestDiff(types.PersistedDiff{
+				RawDiff:   test_data.FakeRawDiff(),
+				ID:        rand.Int63(),
+				Status:    storage.New,
+				EthNodeID: db.NodeID,
+			}, db)
+
+			diffs, err := repo.GetNewDiffsForAddress(address, storage.NewDiffCursor(0), 10)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diffs).To(Equal([]types.PersistedDiff{earlierDiff, laterDiff}))
+		})
+
+		It("does not send diffs that are not new or unrecognized", func() {
+			insertDiffForAddress(rand.Intn(1000000), storage.Transformed)
+			insertDiffForAddress(rand.Intn(1000000), storage.PendingHeader)
+
+			diffs, err := repo.GetNewDiffsForAddress(address, storage.NewDiffCursor(0), 10)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diffs).To(BeEmpty())
+		})
+
+		It("enables seeking diffs after a cursor", func() {
+			blockHeight := rand.Intn(1000000)
+			firstDiff := insertDiffForAddress(blockHeight, storage.New)
+			secondDiff := insertDiffForAddress(blockHeight+1, storage.New)
+
+			diffs, err := repo.GetNewDiffsForAddress(address,
+				storage.DiffCursor{BlockHeight: int64(firstDiff.BlockHeight), ID: firstDiff.ID}, 10)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diffs).To(Equal([]types.PersistedDiff{secondDiff}))
+		})
+	})
+
+	Describe("MarkUnwatchedExcept", func() {
+		It("marks new diffs for addresses not in the list as unwatched", func() {
+			watchedDiff := types.PersistedDiff{
+				RawDiff:   test_data.FakeRawDiff(),
+				ID:        rand.Int63(),
+				Status:    storage.New,
+				EthNodeID: db.NodeID,
+			}
+			insertTestDiff(watchedDiff, db)
+			unwatchedDiff := types.PersistedDiff{
+				RawDiff:   test_data.FakeRawDiff(),
+				ID:        rand.Int63(),
+				Status:    storage.New,
+				EthNodeID: db.NodeID,
+			}
+			insertTestDiff(unwatchedDiff, db)
+
+			count, err := repo.MarkUnwatchedExcept([]common.Address{watchedDiff.Address})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(count).To(Equal(int64(1)))
+			var statuses []string
+			getStatusesErr := db.Select(&statuses, `SELECT status FROM public.storage_diff ORDER BY id = $1 DESC`, watchedDiff.ID)
+			Expect(getStatusesErr).NotTo(HaveOccurred())
+			Expect(statuses).To(Equal([]string{storage.New, storage.Unwatched}))
+		})
+	})
+
 	Describe("Changing the diff status", func() {
 		var fakePersistedDiff types.PersistedDiff
 		BeforeEach(func() {
@@ -318,6 +492,61 @@ var _ = Describe("Storage diffs repository", func() {
 			Expect(status).To(Equal(storage.Unrecognized))
 		})
 
+		Describe("when marking a diff as unrecognized", func() {
+			var retryPolicy = storage.RetryPolicy{
+				MaxAttempts:    3,
+				InitialBackoff: time.Minute,
+				MaxBackoff:     3 * time.Minute,
+			}
+
+			BeforeEach(func() {
+				repo = storage.NewDiffRepositoryWithRetryPolicy(db, retryPolicy)
+			})
+
+			It("increments the retry count and backs off exponentially up to the max backoff", func() {
+				expectedBackoffs := []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute}
+				for i := 0; i < 2; i++ {
+					err := repo.MarkUnrecognized(fakePersistedDiff.ID)
+					Expect(err).NotTo(HaveOccurred())
+
+					var persisted types.PersistedDiff
+					getErr := db.Get(&persisted, `SELECT * FROM public.storage_diff WHERE id = $1`, fakePersistedDiff.ID)
+					Expect(getErr).NotTo(HaveOccurred())
+					Expect(persisted.RetryCount).To(Equal(i + 1))
+					Expect(persisted.NextAttemptAt.Valid).To(BeTrue())
+					Expect(persisted.NextAttemptAt.Time).To(BeTemporally("~", time.Now().Add(expectedBackoffs[i]), 5*time.Second))
+				}
+			})
+
+			It("marks the diff as abandoned once max attempts are exhausted", func() {
+				for i := 0; i < retryPolicy.MaxAttempts; i++ {
+					err := repo.MarkUnrecognized(fakePersistedDiff.ID)
+					Expect(err).NotTo(HaveOccurred())
+				}
+
+				var status string
+				getStatusErr := db.Get(&status, `SELECT status FROM public.storage_diff WHERE id = $1`, fakePersistedDiff.ID)
+				Expect(getStatusErr).NotTo(HaveOccurred())
+				Expect(status).To(Equal(storage.Abandoned))
+			})
+
+			It("never abandons the diff if max attempts is zero", func() {
+				repo = storage.NewDiffRepositoryWithRetryPolicy(db, storage.RetryPolicy{
+					InitialBackoff: time.Minute,
+					MaxBackoff:     time.Hour,
+				})
+				for i := 0; i < 5; i++ {
+					err := repo.MarkUnrecognized(fakePersistedDiff.ID)
+					Expect(err).NotTo(HaveOccurred())
+				}
+
+				var status string
+				getStatusErr := db.Get(&status, `SELECT status FROM public.storage_diff WHERE id = $1`, fakePersistedDiff.ID)
+				Expect(getStatusErr).NotTo(HaveOccurred())
+				Expect(status).To(Equal(storage.Unrecognized))
+			})
+		})
+
 		It("marks a diff as noncanonical", func() {
 			err := repo.MarkNoncanonical(fakePersistedDiff.ID)
 
@@ -339,72 +568,209 @@ var _ = Describe("Storage diffs repository", func() {
 		})
 	})
 
-	Describe("GetFirstDiffIDForBlockHeight", func() {
-		It("sends first diff for a given block height", func() {
-			blockHeight := fakeStorageDiff.BlockHeight
-			fakeStorageDiff2 := types.RawDiff{
-				Address:      test_data.FakeAddress(),
-				BlockHash:    test_data.FakeHash(),
-				BlockHeight:  blockHeight,
-				StorageKey:   test_data.FakeHash(),
-				StorageValue: test_data.FakeHash(),
+	Describe("MarkPendingHeader", func() {
+		It("marks a diff as pending header", func() {
+			fakePersistedDiff := types.PersistedDiff{
+				RawDiff:   fakeStorageDiff,
+				ID:        rand.Int63(),
+				Status:    storage.New,
+				EthNodeID: db.NodeID,
 			}
+			insertTestDiff(fakePersistedDiff, db)
 
-			id1, create1Err := repo.CreateStorageDiff(fakeStorageDiff)
-			Expect(create1Err).NotTo(HaveOccurred())
-			_, create2Err := repo.CreateStorageDiff(fakeStorageDiff2)
-			Expect(create2Err).NotTo(HaveOccurred())
+			err := repo.MarkPendingHeader(fakePersistedDiff.ID)
 
-			diffID, diffErr := repo.GetFirstDiffIDForBlockHeight(int64(blockHeight))
-			Expect(diffErr).NotTo(HaveOccurred())
-			Expect(diffID).To(Equal(id1))
+			Expect(err).NotTo(HaveOccurred())
+			var status string
+			getStatusErr := db.Get(&status, `SELECT status FROM public.storage_diff WHERE id = $1`, fakePersistedDiff.ID)
+			Expect(getStatusErr).NotTo(HaveOccurred())
+			Expect(status).To(Equal(storage.PendingHeader))
 		})
 
-		It("sends a diff for the next block height if one doesn't exist for the block passed in", func() {
-			blockHeight := fakeStorageDiff.BlockHeight
-			fakeStorageDiff2 := types.RawDiff{
-				Address:      test_data.FakeAddress(),
-				BlockHash:    test_data.FakeHash(),
-				BlockHeight:  blockHeight,
-				StorageKey:   test_data.FakeHash(),
-				StorageValue: test_data.FakeHash(),
+		It("excludes diffs pending header from new diffs", func() {
+			fakePersistedDiff := types.PersistedDiff{
+				RawDiff:   fakeStorageDiff,
+				ID:        rand.Int63(),
+				Status:    storage.PendingHeader,
+				EthNodeID: db.NodeID,
 			}
+			insertTestDiff(fakePersistedDiff, db)
 
-			id1, create1Err := repo.CreateStorageDiff(fakeStorageDiff)
-			Expect(create1Err).NotTo(HaveOccurred())
-			_, create2Err := repo.CreateStorageDiff(fakeStorageDiff2)
-			Expect(create2Err).NotTo(HaveOccurred())
+			diffs, err := repo.GetNewDiffs(0, 1)
 
-			blockBeforeDiffBlockHeight := int64(blockHeight - 1)
-			diffID, diffErr := repo.GetFirstDiffIDForBlockHeight(blockBeforeDiffBlockHeight)
-			Expect(diffErr).NotTo(HaveOccurred())
-			Expect(diffID).To(Equal(id1))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diffs).To(BeEmpty())
 		})
+	})
 
-		It("won't fail if all of the diffs within the id range are already checked", func() {
-			fakePersistedDiff := types.PersistedDiff{
+	Describe("HasPendingHeaderPredecessor", func() {
+		var pendingDiff types.PersistedDiff
+
+		BeforeEach(func() {
+			fakeStorageDiff.BlockHeight = rand.Intn(1000000) + 1
+			pendingDiff = types.PersistedDiff{
+				RawDiff:   fakeStorageDiff,
+				ID:        rand.Int63n(1000000) + 1,
+				Status:    storage.PendingHeader,
+				EthNodeID: db.NodeID,
+			}
+			insertTestDiff(pendingDiff, db)
+		})
+
+		It("returns true for a later diff to the same slot", func() {
+			laterDiff := types.PersistedDiff{RawDiff: fakeStorageDiff, ID: pendingDiff.ID + 1}
+			laterDiff.BlockHeight = pendingDiff.BlockHeight + 1
+
+			hasPredecessor, err := repo.HasPendingHeaderPredecessor(laterDiff)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hasPredecessor).To(BeTrue())
+		})
+
+		It("returns false for an earlier diff to the same slot", func() {
+			earlierDiff := types.PersistedDiff{RawDiff: fakeStorageDiff, ID: pendingDiff.ID - 1}
+			earlierDiff.BlockHeight = pendingDiff.BlockHeight - 1
+
+			hasPredecessor, err := repo.HasPendingHeaderPredecessor(earlierDiff)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hasPredecessor).To(BeFalse())
+		})
+
+		It("returns false for a later diff to a different slot", func() {
+			otherDiff := types.PersistedDiff{RawDiff: fakeStorageDiff, ID: pendingDiff.ID + 1}
+			otherDiff.BlockHeight = pendingDiff.BlockHeight + 1
+			otherDiff.StorageKey = test_data.FakeHash()
+
+			hasPredecessor, err := repo.HasPendingHeaderPredecessor(otherDiff)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hasPredecessor).To(BeFalse())
+		})
+	})
+
+	Describe("ReleasePendingHeaderDiffs", func() {
+		var pendingDiff types.PersistedDiff
+
+		BeforeEach(func() {
+			fakeStorageDiff.BlockHeight = rand.Intn(1000000)
+			pendingDiff = types.PersistedDiff{
+				RawDiff:   fakeStorageDiff,
+				ID:        rand.Int63(),
+				Status:    storage.PendingHeader,
+				EthNodeID: db.NodeID,
+			}
+			insertTestDiff(pendingDiff, db)
+		})
+
+		It("returns diffs to new once their header exists", func() {
+			headerRepository := repositories.NewHeaderRepository(db)
+			_, headerErr := headerRepository.CreateOrUpdateHeader(fakes.GetFakeHeader(int64(pendingDiff.BlockHeight)))
+			Expect(headerErr).NotTo(HaveOccurred())
+
+			minReleasedBlockHeight, err := repo.ReleasePendingHeaderDiffs(pendingDiff.Address)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(minReleasedBlockHeight).To(Equal(int64(pendingDiff.BlockHeight)))
+			var status string
+			getStatusErr := db.Get(&status, `SELECT status FROM public.storage_diff WHERE id = $1`, pendingDiff.ID)
+			Expect(getStatusErr).NotTo(HaveOccurred())
+			Expect(status).To(Equal(storage.New))
+		})
+
+		It("only releases diffs for the given address", func() {
+			headerRepository := repositories.NewHeaderRepository(db)
+			_, headerErr := headerRepository.CreateOrUpdateHeader(fakes.GetFakeHeader(int64(pendingDiff.BlockHeight)))
+			Expect(headerErr).NotTo(HaveOccurred())
+
+			minReleasedBlockHeight, err := repo.ReleasePendingHeaderDiffs(test_data.FakeAddress())
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(minReleasedBlockHeight).To(Equal(int64(-1)))
+		})
+
+		It("leaves diffs pending if their header is still missing", func() {
+			minReleasedBlockHeight, err := repo.ReleasePendingHeaderDiffs(pendingDiff.Address)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(minReleasedBlockHeight).To(Equal(int64(-1)))
+			var status string
+			getStatusErr := db.Get(&status, `SELECT status FROM public.storage_diff WHERE id = $1`, pendingDiff.ID)
+			Expect(getStatusErr).NotTo(HaveOccurred())
+			Expect(status).To(Equal(storage.PendingHeader))
+		})
+	})
+
+	Describe("ResetDiffs", func() {
+		var abandonedDiff, unrecognizedDiff, transformedDiff types.PersistedDiff
+
+		BeforeEach(func() {
+			abandonedDiff = types.PersistedDiff{
 				RawDiff:   fakeStorageDiff,
 				ID:        rand.Int63(),
+				Status:    storage.Abandoned,
+				EthNodeID: db.NodeID,
+			}
+			insertTestDiff(abandonedDiff, db)
+			unrecognizedDiff = types.PersistedDiff{
+				RawDiff:   test_data.FakeRawDiff(),
+				ID:        rand.Int63(),
+				Status:    storage.Unrecognized,
+				EthNodeID: db.NodeID,
+			}
+			insertTestDiff(unrecognizedDiff, db)
+			transformedDiff = types.PersistedDiff{
+				RawDiff:   test_data.FakeRawDiff(),
+				ID:        rand.Int63(),
 				Status:    storage.Transformed,
 				EthNodeID: db.NodeID,
 			}
+			insertTestDiff(transformedDiff, db)
+			_, updateErr := db.Exec(`UPDATE public.storage_diff SET retry_count = 3, next_attempt_at = NOW()`)
+			Expect(updateErr).NotTo(HaveOccurred())
+		})
 
-			insertTestDiff(fakePersistedDiff, db)
+		It("resets diffs selected by id", func() {
+			count, err := repo.ResetDiffs(storage.ResetFilter{IDs: []int64{abandonedDiff.ID, transformedDiff.ID}})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(count).To(Equal(int64(1)))
+			var persisted types.PersistedDiff
+			getErr := db.Get(&persisted, `SELECT * FROM public.storage_diff WHERE id = $1`, abandonedDiff.ID)
+			Expect(getErr).NotTo(HaveOccurred())
+			Expect(persisted.Status).To(Equal(storage.New))
+			Expect(persisted.RetryCount).To(BeZero())
+			Expect(persisted.NextAttemptAt.Valid).To(BeFalse())
+		})
+
+		It("resets diffs selected by address and storage key", func() {
+			count, err := repo.ResetDiffs(storage.ResetFilter{
+				Address:     unrecognizedDiff.Address,
+				StorageKeys: []common.Hash{unrecognizedDiff.StorageKey},
+			})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(count).To(Equal(int64(1)))
+			var status string
+			getStatusErr := db.Get(&status, `SELECT status FROM public.storage_diff WHERE id = $1`, unrecognizedDiff.ID)
+			Expect(getStatusErr).NotTo(HaveOccurred())
+			Expect(status).To(Equal(storage.New))
+		})
 
-			var insertedDiffID int64
-			getInsertedDiffIDErr := db.Get(&insertedDiffID, `SELECT id FROM storage_diff LIMIT 1`)
-			Expect(getInsertedDiffIDErr).NotTo(HaveOccurred())
+		It("resets diffs selected by block range", func() {
+			count, err := repo.ResetDiffs(storage.ResetFilter{
+				StartBlock: int64(abandonedDiff.BlockHeight),
+				EndBlock:   int64(abandonedDiff.BlockHeight),
+			})
 
-			blockBeforeDiffBlockHeight := int64(fakeStorageDiff.BlockHeight - 1)
-			diffID, diffErr := repo.GetFirstDiffIDForBlockHeight(blockBeforeDiffBlockHeight)
-			Expect(diffErr).NotTo(HaveOccurred())
-			Expect(diffID).To(Equal(insertedDiffID))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(count).To(Equal(int64(1)))
 		})
 
-		It("returns an error if getting the diff fails", func() {
-			_, diffErr := repo.GetFirstDiffIDForBlockHeight(0)
-			Expect(diffErr).To(HaveOccurred())
-			Expect(diffErr).To(MatchError(sql.ErrNoRows))
+		It("returns an error if the filter is empty", func() {
+			_, err := repo.ResetDiffs(storage.ResetFilter{})
+
+			Expect(err).To(MatchError(storage.ErrEmptyResetFilter))
 		})
 	})
 })
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/makerdao/vulcanizedb/libraries/shared/storage/fetcher"
 	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
@@ -11,9 +12,26 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+var (
+	// UnwatchedDiffLogInterval is how many unwatched diffs are skipped between log messages
+	UnwatchedDiffLogInterval = 1000
+	DefaultDiffBatchSize     = 500
+	DefaultDiffFlushInterval = time.Second
+	DefaultStatsInterval     = time.Minute
+	ErrUnwrittenDiffs        = errors.New("failed to persist storage diffs before stopping")
+)
+
 type DiffExtractor struct {
 	StorageDiffRepository DiffRepository
 	StorageFetcher        fetcher.IStorageFetcher
+	// AddressFilter, if set, skips diffs for addresses it doesn't watch instead of persisting them
+	AddressFilter AddressFilter
+	// Diffs are buffered and written together once BatchSize have been received or FlushInterval has passed.
+	// Without a BatchSize and FlushInterval each diff is written as it's received.
+	BatchSize     int
+	FlushInterval time.Duration
+	// StatsInterval is how often throughput and the buffered backlog are logged; zero disables logging them
+	StatsInterval time.Duration
 }
 
 func NewDiffExtractor(fetcher fetcher.IStorageFetcher, db *postgres.DB) DiffExtractor {
@@ -21,37 +39,157 @@ func NewDiffExtractor(fetcher fetcher.IStorageFetcher, db *postgres.DB) DiffExtr
 	return DiffExtractor{
 		StorageDiffRepository: repo,
 		StorageFetcher:        fetcher,
+		BatchSize:             DefaultDiffBatchSize,
+		FlushInterval:         DefaultDiffFlushInterval,
+		StatsInterval:         DefaultStatsInterval,
 	}
 }
 
+type extractionStats struct {
+	since      time.Time
+	persisted  int64
+	duplicates int64
+	failed     int64
+	unwatched  int64
+}
+
 func (extractor DiffExtractor) ExtractDiffs() error {
+	return extractor.ExtractDiffsUntil(nil)
+}
+
+// ExtractDiffsUntil extracts diffs until the fetcher fails or runs out of diffs, or quit is closed. Buffered diffs
+// are written before it returns.
+func (extractor DiffExtractor) ExtractDiffsUntil(quit <-chan struct{}) error {
+	// the channels aren't closed on return, since the fetcher may still be sending
 	diffsChan := make(chan types.RawDiff)
 	errsChan := make(chan error)
 
-	defer close(diffsChan)
-	defer close(errsChan)
-
 	go extractor.StorageFetcher.FetchStorageDiffs(diffsChan, errsChan)
 
+	var flushTick, statsTick <-chan time.Time
+	if extractor.BatchSize > 1 && extractor.FlushInterval > 0 {
+		flushTicker := time.NewTicker(extractor.FlushInterval)
+		defer flushTicker.Stop()
+		flushTick = flushTicker.C
+	}
+	if extractor.StatsInterval > 0 {
+		statsTicker := time.NewTicker(extractor.StatsInterval)
+		defer statsTicker.Stop()
+		statsTick = statsTicker.C
+	}
+
+	stats := extractionStats{since: time.Now()}
+	var buffer []types.RawDiff
+	var unwatched int64
+	// once a flush has failed, the diffs it kept are only retried on the flush interval rather than on every new diff
+	var flushFailed bool
 	for {
 		select {
+		case <-quit:
+			buffer, _ = extractor.flush(buffer, &stats)
+			extractor.logStats(&stats, len(buffer))
+			return unwrittenDiffsError(buffer)
 		case fetchErr := <-errsChan:
+			buffer, _ = extractor.flush(buffer, &stats)
+			if errors.Is(fetchErr, fetcher.ErrNoMoreDiffs) {
+				extractor.logStats(&stats, len(buffer))
+				return unwrittenDiffsError(buffer)
+			}
 			logrus.Warnf("error fetching storage diffs: %s", fetchErr.Error())
 			return fmt.Errorf("error fetching storage diffs: %w", fetchErr)
 		case diff := <-diffsChan:
-			extractor.persistDiff(diff)
+			if extractor.AddressFilter != nil && !extractor.AddressFilter.Watches(diff.Address) {
+				if unwatched%int64(UnwatchedDiffLogInterval) == 0 {
+					logrus.Infof("skipping storage diffs for unwatched addresses (%d so far), e.g. %s at block %d",
+						unwatched+1, diff.Address.Hex(), diff.BlockHeight)
+				}
+				unwatched++
+				stats.unwatched++
+				continue
+			}
+			buffer = append(buffer, diff)
+			if flushTick == nil || (len(buffer) >= extractor.BatchSize && !flushFailed) {
+				buffer, flushFailed = extractor.flush(buffer, &stats)
+			}
+		case <-flushTick:
+			buffer, flushFailed = extractor.flush(buffer, &stats)
+		case <-statsTick:
+			extractor.logStats(&stats, len(buffer))
+		}
+	}
+}
+
+// flush writes the buffered diffs, falling back to one insert per diff if the batch fails. It returns the diffs that
+// couldn't be written, to be retried on the next flush, and whether there were any. The fetcher is only told the
+// diffs were flushed once all of them have been written.
+func (extractor DiffExtractor) flush(buffer []types.RawDiff, stats *extractionStats) ([]types.RawDiff, bool) {
+	if len(buffer) == 0 {
+		return nil, false
+	}
+	var unwritten []types.RawDiff
+	if len(buffer) == 1 {
+		if err := extractor.persistDiff(buffer[0], stats); err != nil {
+			unwritten = buffer
 		}
+	} else {
+		created, err := extractor.StorageDiffRepository.CreateStorageDiffs(buffer)
+		if err != nil {
+			logrus.Warnf("failed to persist %d storage diffs together, persisting them one at a time: %s",
+				len(buffer), err.Error())
+			for _, diff := range buffer {
+				if persistErr := extractor.persistDiff(diff, stats); persistErr != nil {
+					unwritten = append(unwritten, diff)
+				}
+			}
+		} else {
+			stats.persisted += created
+			stats.duplicates += int64(len(buffer)) - created
+		}
+	}
+	if len(unwritten) > 0 {
+		logrus.Warnf("keeping %d storage diffs that couldn't be persisted to retry", len(unwritten))
+		return unwritten, true
+	}
+	if observer, ok := extractor.StorageFetcher.(fetcher.FlushObserver); ok {
+		observer.DiffsFlushed()
 	}
+	return nil, false
 }
 
-func (extractor DiffExtractor) persistDiff(rawDiff types.RawDiff) {
+// persistDiff writes a single diff, returning an error only if it wasn't written and isn't a duplicate
+func (extractor DiffExtractor) persistDiff(rawDiff types.RawDiff, stats *extractionStats) error {
 	_, err := extractor.StorageDiffRepository.CreateStorageDiff(rawDiff)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			logrus.Tracef("ignoring duplicate diff. Block number: %v, blockHash: %v, address: %v, storageKey: %v, storageValue: %v",
 				rawDiff.BlockHeight, rawDiff.BlockHash.Hex(), rawDiff.Address, rawDiff.StorageKey, rawDiff.StorageValue)
-			return
+			stats.duplicates++
+			return nil
 		}
 		logrus.Warnf("failed to persist storage diff: %s", err.Error())
+		stats.failed++
+		return err
+	}
+	stats.persisted++
+	return nil
+}
+
+// unwrittenDiffsError reports diffs still buffered after the final flush, which are lost when extraction stops
+func unwrittenDiffsError(buffer []types.RawDiff) error {
+	if len(buffer) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %d storage diffs", ErrUnwrittenDiffs, len(buffer))
+}
+
+// logStats logs what's happened to diffs since the last call, and resets the counts
+func (extractor DiffExtractor) logStats(stats *extractionStats, buffered int) {
+	elapsed := time.Since(stats.since)
+	if elapsed <= 0 {
+		elapsed = time.Nanosecond
 	}
+	logrus.Infof("persisted %d storage diffs in %s (%.1f/s); %d duplicates, %d failed, %d unwatched; %d buffered",
+		stats.persisted, elapsed.Round(time.Millisecond), float64(stats.persisted)/elapsed.Seconds(),
+		stats.duplicates, stats.failed, stats.unwatched, buffered)
+	*stats = extractionStats{since: time.Now()}
 }
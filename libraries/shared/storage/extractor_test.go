@@ -2,9 +2,12 @@ package storage_test
 
 import (
 	"math/rand"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/makerdao/vulcanizedb/libraries/shared/mocks"
 	"github.com/makerdao/vulcanizedb/libraries/shared/storage"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/fetcher"
 	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
 	"github.com/makerdao/vulcanizedb/libraries/shared/test_data"
 	"github.com/makerdao/vulcanizedb/pkg/fakes"
@@ -46,6 +49,14 @@ var _ = Describe("Storage diff extractor", func() {
 			Expect(err).To(MatchError(fakes.FakeError))
 		})
 
+		It("returns without error once the fetcher has no more diffs", func() {
+			mockFetcher.ErrsToReturn = []error{fetcher.ErrNoMoreDiffs}
+
+			err := extractor.ExtractDiffs()
+
+			Expect(err).NotTo(HaveOccurred())
+		})
+
 		It("persists fetched storage diff", func() {
 			fakeDiff := types.RawDiff{
 				Address:      test_data.FakeAddress(),
@@ -61,5 +72,137 @@ var _ = Describe("Storage diff extractor", func() {
 
 			Expect(mockRepository.CreatePassedRawDiffs).To(Equal([]types.RawDiff{fakeDiff}))
 		})
+
+		Describe("batching", func() {
+			var diffs []types.RawDiff
+
+			BeforeEach(func() {
+				diffs = []types.RawDiff{
+					{Address: test_data.FakeAddress(), BlockHeight: rand.Int()},
+					{Address: test_data.FakeAddress(), BlockHeight: rand.Int()},
+					{Address: test_data.FakeAddress(), BlockHeight: rand.Int()},
+				}
+				extractor.BatchSize = 2
+				extractor.FlushInterval = time.Hour
+				mockFetcher.DiffsToReturn = diffs
+				mockFetcher.ErrsToReturn = []error{fakes.FakeError}
+			})
+
+			It("writes diffs together once the batch is full", func() {
+				_ = extractor.ExtractDiffs()
+
+				Expect(mockRepository.CreateStorageDiffsPassedBatches[0]).To(Equal(diffs[:2]))
+			})
+
+			It("writes buffered diffs before returning an error", func() {
+				err := extractor.ExtractDiffs()
+
+				Expect(err).To(MatchError(fakes.FakeError))
+				Expect(mockRepository.CreatePassedRawDiffs).To(Equal(diffs))
+			})
+
+			It("writes diffs one at a time if writing them together fails", func() {
+				mockRepository.CreateStorageDiffsErr = fakes.FakeError
+
+				_ = extractor.ExtractDiffs()
+
+				Expect(mockRepository.CreatePassedRawDiffs).To(Equal(diffs))
+			})
+
+			It("writes buffered diffs after the flush interval", func() {
+				extractor.BatchSize = 10
+				extractor.FlushInterval = 10 * time.Millisecond
+				mockFetcher.ErrsToReturn = nil
+
+				go extractor.ExtractDiffs()
+
+				Eventually(func() int { return len(mockRepository.CreateStorageDiffsPassedBatches) }).Should(Equal(1))
+			})
+
+			It("writes buffered diffs when told to quit", func() {
+				mockFetcher.ErrsToReturn = nil
+				mockFetcher.DiffsSent = make(chan struct{})
+				quit := make(chan struct{})
+				go func() {
+					<-mockFetcher.DiffsSent
+					close(quit)
+				}()
+
+				err := extractor.ExtractDiffsUntil(quit)
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(mockRepository.CreatePassedRawDiffs).To(Equal(diffs))
+			})
+
+			It("tells the fetcher when diffs have been written", func() {
+				_ = extractor.ExtractDiffs()
+
+				Expect(mockFetcher.DiffsFlushedCallCount).To(Equal(2))
+			})
+
+			It("doesn't tell the fetcher diffs were flushed if any couldn't be written", func() {
+				mockRepository.CreateStorageDiffsErr = fakes.FakeError
+				mockRepository.CreateStorageDiffErrs = []error{nil, fakes.FakeError, fakes.FakeError, fakes.FakeError}
+				mockFetcher.ErrsToReturn = []error{fetcher.ErrNoMoreDiffs}
+
+				err := extractor.ExtractDiffs()
+
+				Expect(err).To(MatchError(storage.ErrUnwrittenDiffs))
+				Expect(mockFetcher.DiffsFlushedCallCount).To(Equal(0))
+			})
+
+			It("retries diffs that couldn't be written on the next flush", func() {
+				extractor.BatchSize = 0
+				mockRepository.CreateStorageDiffErrs = []error{fakes.FakeError}
+				mockFetcher.ErrsToReturn = []error{fetcher.ErrNoMoreDiffs}
+
+				err := extractor.ExtractDiffs()
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(mockRepository.CreateStorageDiffsPassedBatches).To(Equal([][]types.RawDiff{diffs[:2]}))
+				Expect(mockRepository.CreatePassedRawDiffs).To(Equal([]types.RawDiff{diffs[0], diffs[0], diffs[1], diffs[2]}))
+				Expect(mockFetcher.DiffsFlushedCallCount).To(Equal(2))
+			})
+		})
+
+		Describe("with an address filter", func() {
+			var (
+				watchedDiff, unwatchedDiff types.RawDiff
+				watchedAddresses           *storage.WatchedAddresses
+			)
+
+			BeforeEach(func() {
+				watchedDiff = types.RawDiff{Address: test_data.FakeAddress(), BlockHeight: rand.Int()}
+				unwatchedDiff = types.RawDiff{Address: test_data.FakeAddress(), BlockHeight: rand.Int()}
+				watchedAddresses = storage.NewWatchedAddresses([]common.Address{watchedDiff.Address})
+				extractor.AddressFilter = watchedAddresses
+				mockFetcher.ErrsToReturn = []error{fakes.FakeError}
+			})
+
+			It("persists diffs for watched addresses", func() {
+				mockFetcher.DiffsToReturn = []types.RawDiff{watchedDiff}
+
+				_ = extractor.ExtractDiffs()
+
+				Expect(mockRepository.CreatePassedRawDiffs).To(Equal([]types.RawDiff{watchedDiff}))
+			})
+
+			It("skips diffs for unwatched addresses", func() {
+				mockFetcher.DiffsToReturn = []types.RawDiff{unwatchedDiff, watchedDiff}
+
+				_ = extractor.ExtractDiffs()
+
+				Expect(mockRepository.CreatePassedRawDiffs).To(Equal([]types.RawDiff{watchedDiff}))
+			})
+
+			It("uses the latest watched addresses", func() {
+				watchedAddresses.Set([]common.Address{unwatchedDiff.Address})
+				mockFetcher.DiffsToReturn = []types.RawDiff{unwatchedDiff, watchedDiff}
+
+				_ = extractor.ExtractDiffs()
+
+				Expect(mockRepository.CreatePassedRawDiffs).To(Equal([]types.RawDiff{unwatchedDiff}))
+			})
+		})
 	})
 })
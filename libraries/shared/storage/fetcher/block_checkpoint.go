@@ -0,0 +1,78 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher
+
+import (
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// BlockCheckpointStore saves the last block a fetcher has delivered in full, so that a restarted fetcher can resume
+// after it rather than after the last block that happened to have diffs
+type BlockCheckpointStore interface {
+	LoadLastBlock() (int64, error)
+	SaveLastBlock(blockNumber int64) error
+}
+
+// blockProgress tracks the last block received, which is saved once the diffs sent up to it have been persisted
+type blockProgress struct {
+	mutex    sync.Mutex
+	store    BlockCheckpointStore
+	received int64
+	saved    int64
+	// pending is whether diffs have been sent since they were last flushed
+	pending bool
+}
+
+// diffSent records that a diff has been sent, and so must be flushed before the block it's from is saved
+func (progress *blockProgress) diffSent() {
+	progress.mutex.Lock()
+	defer progress.mutex.Unlock()
+	progress.pending = true
+}
+
+// blockReceived records that every diff of the block has been sent, saving it right away if they've been flushed
+func (progress *blockProgress) blockReceived(blockNumber int64) {
+	progress.mutex.Lock()
+	defer progress.mutex.Unlock()
+	progress.received = blockNumber
+	if !progress.pending {
+		progress.save()
+	}
+}
+
+// flushed records that every diff sent so far has been persisted, saving the last block received
+func (progress *blockProgress) flushed() {
+	progress.mutex.Lock()
+	defer progress.mutex.Unlock()
+	progress.pending = false
+	progress.save()
+}
+
+func (progress *blockProgress) save() {
+	if progress.store == nil || progress.received <= 0 || progress.received == progress.saved {
+		return
+	}
+	saveErr := progress.store.SaveLastBlock(progress.received)
+	if saveErr != nil {
+		// blocks after the last saved checkpoint are fetched again on restart, and duplicate diffs are ignored
+		logrus.Warnf("failed to save storage fetcher checkpoint at block %d: %s", progress.received, saveErr.Error())
+		return
+	}
+	progress.saved = progress.received
+}
@@ -0,0 +1,128 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
+	"github.com/makerdao/vulcanizedb/pkg/fs"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrNoMoreDiffs is sent by fetchers that read a fixed set of diffs once they've all been fetched
+var ErrNoMoreDiffs = errors.New("no more storage diffs to fetch")
+
+const maxDiffLineLength = 1024 * 1024
+
+// DirectoryStorageFetcher reads every diff file in a directory once, in name order. Gzipped files (ending in .gz)
+// are decompressed, and rows that can't be parsed are quarantined.
+type DirectoryStorageFetcher struct {
+	path       string
+	format     types.DiffFormat
+	quarantine fs.Quarantine
+}
+
+func NewDirectoryStorageFetcher(path string, format types.DiffFormat, quarantine fs.Quarantine) DirectoryStorageFetcher {
+	return DirectoryStorageFetcher{
+		path:       path,
+		format:     format,
+		quarantine: quarantine,
+	}
+}
+
+func (storageFetcher DirectoryStorageFetcher) FetchStorageDiffs(out chan<- types.RawDiff, errs chan<- error) {
+	paths, pathsErr := storageFetcher.diffFiles()
+	if pathsErr != nil {
+		errs <- pathsErr
+		return
+	}
+	for _, path := range paths {
+		logrus.Infof("reading storage diffs from %s", path)
+		count, readErr := storageFetcher.fetchFileDiffs(path, out)
+		if readErr != nil {
+			errs <- readErr
+			return
+		}
+		logrus.Infof("read %d storage diffs from %s", count, path)
+	}
+	errs <- ErrNoMoreDiffs
+}
+
+// diffFiles returns the path if it's a file, or the non-hidden files in it if it's a directory
+func (storageFetcher DirectoryStorageFetcher) diffFiles() ([]string, error) {
+	info, statErr := os.Stat(storageFetcher.path)
+	if statErr != nil {
+		return nil, fmt.Errorf("error reading storage diffs path %s: %w", storageFetcher.path, statErr)
+	}
+	if !info.IsDir() {
+		return []string{storageFetcher.path}, nil
+	}
+	entries, readErr := ioutil.ReadDir(storageFetcher.path)
+	if readErr != nil {
+		return nil, fmt.Errorf("error reading storage diffs directory %s: %w", storageFetcher.path, readErr)
+	}
+	var paths []string
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		paths = append(paths, filepath.Join(storageFetcher.path, entry.Name()))
+	}
+	return paths, nil
+}
+
+func (storageFetcher DirectoryStorageFetcher) fetchFileDiffs(path string, out chan<- types.RawDiff) (int, error) {
+	file, openErr := fs.OpenDecompressed(path)
+	if openErr != nil {
+		return 0, openErr
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxDiffLineLength)
+	var lineNumber int64
+	count := 0
+	for scanner.Scan() {
+		lineNumber++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		diff, ok, parseErr := storageFetcher.format.ParseLine(lineNumber, text)
+		if parseErr != nil {
+			reason := fmt.Errorf("%s: %w", filepath.Base(path), parseErr)
+			logrus.Warnf("quarantining malformed storage diff on line %d of %s: %s", lineNumber, path, parseErr.Error())
+			quarantineErr := storageFetcher.quarantine.Quarantine(lineNumber, text, reason)
+			if quarantineErr != nil {
+				return count, quarantineErr
+			}
+			continue
+		}
+		if ok {
+			out <- diff
+			count++
+		}
+	}
+	if scanErr := scanner.Err(); scanErr != nil {
+		return count, fmt.Errorf("error reading storage diffs file %s: %w", path, scanErr)
+	}
+	return count, nil
+}
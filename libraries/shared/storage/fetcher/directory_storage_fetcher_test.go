@@ -0,0 +1,136 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher_test
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/fetcher"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
+	"github.com/makerdao/vulcanizedb/pkg/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Directory Storage Fetcher", func() {
+	var (
+		dir            string
+		diffsChannel   chan types.RawDiff
+		errorsChannel  chan error
+		mockQuarantine *fakes.MockQuarantine
+		storageFetcher fetcher.DirectoryStorageFetcher
+		firstRow       = "0x123,0x456,1,0x987,0x654"
+		secondRow      = "0x123,0x456,2,0x987,0x654"
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "diffs")
+		Expect(err).NotTo(HaveOccurred())
+		diffsChannel = make(chan types.RawDiff, 10)
+		errorsChannel = make(chan error, 1)
+		mockQuarantine = &fakes.MockQuarantine{}
+		format, formatErr := types.NewDiffFormat(types.ParityCsvFormat, types.DiffFormatConfig{})
+		Expect(formatErr).NotTo(HaveOccurred())
+		storageFetcher = fetcher.NewDirectoryStorageFetcher(dir, format, mockQuarantine)
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	writeGzipped := func(path, contents string) {
+		file, err := os.Create(path)
+		Expect(err).NotTo(HaveOccurred())
+		writer := gzip.NewWriter(file)
+		_, err = writer.Write([]byte(contents))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(writer.Close()).To(Succeed())
+		Expect(file.Close()).To(Succeed())
+	}
+
+	receivedHeights := func() []int {
+		var heights []int
+		for len(diffsChannel) > 0 {
+			heights = append(heights, (<-diffsChannel).BlockHeight)
+		}
+		return heights
+	}
+
+	It("reads every file in name order, then reports that there are no more diffs", func() {
+		Expect(ioutil.WriteFile(filepath.Join(dir, "b.csv"), []byte(secondRow+"\n"), 0644)).To(Succeed())
+		writeGzipped(filepath.Join(dir, "a.csv.gz"), firstRow+"\n")
+		Expect(ioutil.WriteFile(filepath.Join(dir, ".hidden"), []byte("ignored\n"), 0644)).To(Succeed())
+
+		storageFetcher.FetchStorageDiffs(diffsChannel, errorsChannel)
+
+		Expect(<-errorsChannel).To(MatchError(fetcher.ErrNoMoreDiffs))
+		Expect(receivedHeights()).To(Equal([]int{1, 2}))
+	})
+
+	It("reads a single file", func() {
+		path := filepath.Join(dir, "diffs.csv")
+		Expect(ioutil.WriteFile(path, []byte(firstRow+"\n"+secondRow), 0644)).To(Succeed())
+		format, formatErr := types.NewDiffFormat(types.ParityCsvFormat, types.DiffFormatConfig{})
+		Expect(formatErr).NotTo(HaveOccurred())
+		storageFetcher = fetcher.NewDirectoryStorageFetcher(path, format, mockQuarantine)
+
+		storageFetcher.FetchStorageDiffs(diffsChannel, errorsChannel)
+
+		Expect(<-errorsChannel).To(MatchError(fetcher.ErrNoMoreDiffs))
+		diff := <-diffsChannel
+		Expect(diff.Address).To(Equal(common.HexToAddress("0x123")))
+		Expect(receivedHeights()).To(Equal([]int{2}))
+	})
+
+	It("quarantines rows that can't be parsed", func() {
+		Expect(ioutil.WriteFile(filepath.Join(dir, "diffs.csv"), []byte("invalid\n"+secondRow+"\n"), 0644)).To(Succeed())
+
+		storageFetcher.FetchStorageDiffs(diffsChannel, errorsChannel)
+
+		Expect(<-errorsChannel).To(MatchError(fetcher.ErrNoMoreDiffs))
+		Expect(receivedHeights()).To(Equal([]int{2}))
+		quarantined := mockQuarantine.QuarantinedLines()
+		Expect(len(quarantined)).To(Equal(1))
+		Expect(quarantined[0].LineNumber).To(Equal(int64(1)))
+		Expect(quarantined[0].Text).To(Equal("invalid"))
+		Expect(quarantined[0].Reason.Error()).To(ContainSubstring("diffs.csv"))
+	})
+
+	It("adds error to errors channel if quarantining a row fails", func() {
+		mockQuarantine.QuarantineErr = fakes.FakeError
+		Expect(ioutil.WriteFile(filepath.Join(dir, "diffs.csv"), []byte("invalid\n"), 0644)).To(Succeed())
+
+		storageFetcher.FetchStorageDiffs(diffsChannel, errorsChannel)
+
+		Expect(<-errorsChannel).To(MatchError(fakes.FakeError))
+	})
+
+	It("adds error to errors channel if the path doesn't exist", func() {
+		format, formatErr := types.NewDiffFormat(types.ParityCsvFormat, types.DiffFormatConfig{})
+		Expect(formatErr).NotTo(HaveOccurred())
+		storageFetcher = fetcher.NewDirectoryStorageFetcher(filepath.Join(dir, "missing"), format, mockQuarantine)
+
+		storageFetcher.FetchStorageDiffs(diffsChannel, errorsChannel)
+
+		Expect(<-errorsChannel).To(HaveOccurred())
+	})
+})
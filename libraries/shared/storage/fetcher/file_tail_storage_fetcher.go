@@ -0,0 +1,124 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
+	"github.com/makerdao/vulcanizedb/pkg/fs"
+	"github.com/sirupsen/logrus"
+)
+
+var ErrTailerStopped = errors.New("stopped tailing storage diffs file")
+
+type FileTailStorageFetcher struct {
+	format       types.DiffFormat
+	tailer       fs.LineTailer
+	checkpoints  fs.CheckpointStore
+	quarantine   fs.Quarantine
+	statusWriter fs.StatusWriter
+	progress     *tailProgress
+}
+
+// tailProgress tracks the checkpoint after the last line handled, which is saved once the diffs sent up to that line
+// have been persisted
+type tailProgress struct {
+	mutex   sync.Mutex
+	handled fs.Checkpoint
+	saved   fs.Checkpoint
+}
+
+func NewFileTailStorageFetcher(format types.DiffFormat, tailer fs.LineTailer, checkpoints fs.CheckpointStore,
+	quarantine fs.Quarantine, statusWriter fs.StatusWriter) FileTailStorageFetcher {
+	return FileTailStorageFetcher{
+		format:       format,
+		tailer:       tailer,
+		checkpoints:  checkpoints,
+		quarantine:   quarantine,
+		statusWriter: statusWriter,
+		progress:     &tailProgress{},
+	}
+}
+
+// FetchStorageDiffs tails the diffs file from the last saved checkpoint. Rows that can't be parsed are quarantined
+// rather than halting ingestion.
+func (storageFetcher FileTailStorageFetcher) FetchStorageDiffs(out chan<- types.RawDiff, errs chan<- error) {
+	checkpoint, loadErr := storageFetcher.checkpoints.Load()
+	if loadErr != nil {
+		errs <- loadErr
+		return
+	}
+	writeErr := storageFetcher.statusWriter.Write()
+	if writeErr != nil {
+		errs <- writeErr
+	}
+
+	lines := make(chan fs.Line)
+	tailErrs := make(chan error)
+	go func() {
+		tailErrs <- storageFetcher.tailer.TailFrom(checkpoint, lines)
+	}()
+
+	storageFetcher.progress.mutex.Lock()
+	storageFetcher.progress.handled, storageFetcher.progress.saved = checkpoint, checkpoint
+	storageFetcher.progress.mutex.Unlock()
+	for {
+		select {
+		case line := <-lines:
+			diff, ok, parseErr := storageFetcher.format.ParseLine(line.LineNumber, line.Text)
+			if parseErr != nil {
+				logrus.Warnf("quarantining malformed storage diff on line %d: %s", line.LineNumber, parseErr.Error())
+				quarantineErr := storageFetcher.quarantine.Quarantine(line.LineNumber, line.Text, parseErr)
+				if quarantineErr != nil {
+					errs <- quarantineErr
+					return
+				}
+			} else if ok {
+				out <- diff
+			}
+			storageFetcher.progress.mutex.Lock()
+			storageFetcher.progress.handled = line.Checkpoint
+			storageFetcher.progress.mutex.Unlock()
+		case tailErr := <-tailErrs:
+			if tailErr == nil {
+				tailErr = ErrTailerStopped
+			}
+			errs <- fmt.Errorf("error tailing storage diffs: %w", tailErr)
+			return
+		}
+	}
+}
+
+// DiffsFlushed saves the checkpoint after the last line handled, since every diff sent up to it has been persisted
+func (storageFetcher FileTailStorageFetcher) DiffsFlushed() {
+	progress := storageFetcher.progress
+	progress.mutex.Lock()
+	defer progress.mutex.Unlock()
+	if progress.handled == progress.saved {
+		return
+	}
+	saveErr := storageFetcher.checkpoints.Save(progress.handled)
+	if saveErr != nil {
+		// diffs after the last saved checkpoint are re-read on restart, and duplicates are ignored
+		logrus.Warnf("failed to save storage diffs checkpoint at line %d: %s", progress.handled.LineNumber, saveErr.Error())
+		return
+	}
+	progress.saved = progress.handled
+}
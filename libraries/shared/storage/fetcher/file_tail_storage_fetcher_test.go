@@ -0,0 +1,207 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher_test
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/fetcher"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
+	"github.com/makerdao/vulcanizedb/pkg/fakes"
+	"github.com/makerdao/vulcanizedb/pkg/fs"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("File Tail Storage Fetcher", func() {
+	var (
+		errorsChannel       chan error
+		mockTailer          *fakes.MockLineTailer
+		mockCheckpointStore *fakes.MockCheckpointStore
+		mockQuarantine      *fakes.MockQuarantine
+		mockStatusWriter    fakes.MockStatusWriter
+		diffsChannel        chan types.RawDiff
+		storageFetcher      fetcher.FileTailStorageFetcher
+	)
+
+	BeforeEach(func() {
+		errorsChannel = make(chan error)
+		diffsChannel = make(chan types.RawDiff)
+		mockTailer = fakes.NewMockLineTailer()
+		mockCheckpointStore = &fakes.MockCheckpointStore{}
+		mockQuarantine = &fakes.MockQuarantine{}
+		mockStatusWriter = fakes.MockStatusWriter{}
+		format, formatErr := types.NewDiffFormat(types.ParityCsvFormat, types.DiffFormatConfig{})
+		Expect(formatErr).NotTo(HaveOccurred())
+		storageFetcher = fetcher.NewFileTailStorageFetcher(format, mockTailer, mockCheckpointStore, mockQuarantine, &mockStatusWriter)
+	})
+
+	It("adds error to errors channel if loading the checkpoint fails", func(done Done) {
+		mockCheckpointStore.LoadErr = fakes.FakeError
+
+		go storageFetcher.FetchStorageDiffs(diffsChannel, errorsChannel)
+
+		Expect(<-errorsChannel).To(MatchError(fakes.FakeError))
+		close(done)
+	})
+
+	It("tails the file from the saved checkpoint", func(done Done) {
+		checkpoint := fs.Checkpoint{Inode: 1, Offset: 2, LineNumber: 3}
+		mockCheckpointStore.CheckpointToLoad = checkpoint
+
+		go storageFetcher.FetchStorageDiffs(diffsChannel, errorsChannel)
+		mockTailer.Lines <- getFakeLine(4)
+
+		Expect(<-diffsChannel).NotTo(BeZero())
+		Expect(mockTailer.PassedCheckpoint).To(Equal(checkpoint))
+		close(done)
+	})
+
+	It("adds error to errors channel if tailing file fails", func(done Done) {
+		mockTailer.TailFromErr = fakes.FakeError
+
+		go storageFetcher.FetchStorageDiffs(diffsChannel, errorsChannel)
+
+		Expect(<-errorsChannel).To(MatchError(fakes.FakeError))
+		close(done)
+	})
+
+	Describe("when establishing connection succeeds", func() {
+		It("creates file for health check when connection established", func(done Done) {
+			go storageFetcher.FetchStorageDiffs(diffsChannel, errorsChannel)
+
+			Eventually(func() bool {
+				return mockStatusWriter.WriteCalled
+			}).Should(BeTrue())
+			close(done)
+		})
+
+		It("adds parsed csv row to rows channel for storage diff", func(done Done) {
+			line := getFakeLine(1)
+
+			go storageFetcher.FetchStorageDiffs(diffsChannel, errorsChannel)
+			mockTailer.Lines <- line
+
+			expectedRow, err := types.FromParityCsvRow(strings.Split(line.Text, ","))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(<-diffsChannel).To(Equal(expectedRow))
+			close(done)
+		})
+
+		It("quarantines a row that can't be parsed and carries on", func(done Done) {
+			invalidLine := fs.Line{Text: "invalid", LineNumber: 1, Checkpoint: fs.Checkpoint{Offset: 8, LineNumber: 1}}
+			validLine := getFakeLine(2)
+
+			go storageFetcher.FetchStorageDiffs(diffsChannel, errorsChannel)
+			mockTailer.Lines <- invalidLine
+			mockTailer.Lines <- validLine
+
+			expectedRow, err := types.FromParityCsvRow(strings.Split(validLine.Text, ","))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(<-diffsChannel).To(Equal(expectedRow))
+			quarantined := mockQuarantine.QuarantinedLines()
+			Expect(len(quarantined)).To(Equal(1))
+			Expect(quarantined[0].LineNumber).To(Equal(int64(1)))
+			Expect(quarantined[0].Text).To(Equal("invalid"))
+			Expect(quarantined[0].Reason).To(HaveOccurred())
+			close(done)
+		})
+
+		It("skips lines without a diff", func(done Done) {
+			csvFormat, formatErr := types.NewDiffFormat(types.CsvFormat, types.DiffFormatConfig{})
+			Expect(formatErr).NotTo(HaveOccurred())
+			storageFetcher = fetcher.NewFileTailStorageFetcher(csvFormat, mockTailer, mockCheckpointStore, mockQuarantine, &mockStatusWriter)
+			header := "address,block_hash,block_height,storage_key,storage_value"
+			validLine := getFakeLine(2)
+
+			go storageFetcher.FetchStorageDiffs(diffsChannel, errorsChannel)
+			mockTailer.Lines <- fs.Line{Text: header, LineNumber: 1}
+			mockTailer.Lines <- validLine
+
+			expectedRow, err := types.FromParityCsvRow(strings.Split(validLine.Text, ","))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(<-diffsChannel).To(Equal(expectedRow))
+			Expect(mockQuarantine.QuarantinedLines()).To(BeEmpty())
+			close(done)
+		})
+
+		It("adds error to errors channel if quarantining a row fails", func(done Done) {
+			mockQuarantine.QuarantineErr = fakes.FakeError
+
+			go storageFetcher.FetchStorageDiffs(diffsChannel, errorsChannel)
+			mockTailer.Lines <- fs.Line{Text: "invalid", LineNumber: 1}
+
+			Expect(<-errorsChannel).To(MatchError(fakes.FakeError))
+			select {
+			case <-diffsChannel:
+				Fail("value passed to rows channel on error")
+			default:
+				Succeed()
+			}
+			close(done)
+		})
+
+		It("saves the checkpoint of the last row handled once diffs have been flushed", func(done Done) {
+			firstLine := getFakeLine(1)
+			secondLine := fs.Line{Text: "invalid", LineNumber: 2, Checkpoint: fs.Checkpoint{Inode: 1, Offset: 100, LineNumber: 2}}
+
+			go storageFetcher.FetchStorageDiffs(diffsChannel, errorsChannel)
+			mockTailer.Lines <- firstLine
+			<-diffsChannel
+			mockTailer.Lines <- secondLine
+			Consistently(mockCheckpointStore.SavedCheckpoints).Should(BeEmpty())
+
+			Eventually(func() []fs.Checkpoint {
+				storageFetcher.DiffsFlushed()
+				return mockCheckpointStore.SavedCheckpoints()
+			}).Should(Equal([]fs.Checkpoint{secondLine.Checkpoint}))
+			close(done)
+		})
+
+		It("doesn't save the checkpoint again if nothing has been handled since", func(done Done) {
+			go storageFetcher.FetchStorageDiffs(diffsChannel, errorsChannel)
+			mockTailer.Lines <- getFakeLine(1)
+			<-diffsChannel
+			Eventually(func() []fs.Checkpoint {
+				storageFetcher.DiffsFlushed()
+				return mockCheckpointStore.SavedCheckpoints()
+			}).Should(HaveLen(1))
+
+			storageFetcher.DiffsFlushed()
+
+			Expect(mockCheckpointStore.SavedCheckpoints()).To(HaveLen(1))
+			close(done)
+		})
+	})
+})
+
+func getFakeLine(lineNumber int64) fs.Line {
+	address := common.HexToAddress("0x1234567890abcdef")
+	blockHash := []byte{4, 5, 6}
+	blockHeight := int64(789)
+	storageKey := []byte{9, 8, 7}
+	storageValue := []byte{6, 5, 4}
+	text := fmt.Sprintf("%s,%s,%d,%s,%s", common.Bytes2Hex(address.Bytes()), common.Bytes2Hex(blockHash),
+		blockHeight, common.Bytes2Hex(storageKey), common.Bytes2Hex(storageValue))
+	return fs.Line{
+		Text:       text,
+		LineNumber: lineNumber,
+		Checkpoint: fs.Checkpoint{Inode: 1, Offset: lineNumber * int64(len(text)+1), LineNumber: lineNumber},
+	}
+}
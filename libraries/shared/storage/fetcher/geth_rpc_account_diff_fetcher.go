@@ -0,0 +1,101 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
+	"github.com/makerdao/vulcanizedb/libraries/shared/streamer"
+	"github.com/makerdao/vulcanizedb/pkg/fs"
+	"github.com/sirupsen/logrus"
+)
+
+// GethRpcAccountDiffFetcher emits the balance, nonce, code hash and storage root of watched accounts updated in
+// each state diff from the patched geth subscription
+type GethRpcAccountDiffFetcher struct {
+	statediffPayloadChan chan filters.Payload
+	streamer             streamer.Streamer
+	statusWriter         fs.StatusWriter
+	watchedAddresses     map[common.Address]bool
+	RetryInterval        time.Duration
+	MaxRetryInterval     time.Duration
+}
+
+func NewGethRpcAccountDiffFetcher(streamer streamer.Streamer, statediffPayloadChan chan filters.Payload, statusWriter fs.StatusWriter, watchedAddresses []common.Address) GethRpcAccountDiffFetcher {
+	watched := make(map[common.Address]bool, len(watchedAddresses))
+	for _, address := range watchedAddresses {
+		watched[address] = true
+	}
+	return GethRpcAccountDiffFetcher{
+		statediffPayloadChan: statediffPayloadChan,
+		streamer:             streamer,
+		statusWriter:         statusWriter,
+		watchedAddresses:     watched,
+		RetryInterval:        DefaultRetryInterval,
+		MaxRetryInterval:     DefaultMaxRetryInterval,
+	}
+}
+
+func (fetcher GethRpcAccountDiffFetcher) FetchAccountDiffs(out chan<- types.RawAccountDiff, errs chan<- error) {
+	for {
+		clientSubscription := subscribeWithBackoff(fetcher.streamer, fetcher.statediffPayloadChan, fetcher.statusWriter,
+			fetcher.RetryInterval, fetcher.MaxRetryInterval, errs)
+		fetcher.streamAccountDiffs(clientSubscription.Err(), out, errs)
+		clientSubscription.Unsubscribe()
+	}
+}
+
+func (fetcher GethRpcAccountDiffFetcher) streamAccountDiffs(subscriptionErrs <-chan error, out chan<- types.RawAccountDiff, errs chan<- error) {
+	for {
+		select {
+		case err := <-subscriptionErrs:
+			logrus.Errorf("error with client subscription, reconnecting: %s", err.Error())
+			return
+		case diffPayload := <-fetcher.statediffPayloadChan:
+			logrus.Trace("received a statediff payload")
+			fetcher.handleDiffPayload(diffPayload, out, errs)
+		}
+	}
+}
+
+func (fetcher GethRpcAccountDiffFetcher) handleDiffPayload(payload filters.Payload, out chan<- types.RawAccountDiff, errs chan<- error) {
+	var stateDiff filters.StateDiff
+	decodeErr := rlp.DecodeBytes(payload.StateDiffRlp, &stateDiff)
+	if decodeErr != nil {
+		errs <- fmt.Errorf("error decoding account diff from geth payload: %w", decodeErr)
+		return
+	}
+
+	for _, account := range stateDiff.UpdatedAccounts {
+		if !fetcher.watchedAddresses[common.BytesToAddress(account.Key)] {
+			continue
+		}
+		accountDiff, formatErr := types.FromGethAccountDiff(account, &stateDiff)
+		if formatErr != nil {
+			errs <- formatErr
+			return
+		}
+		logrus.Tracef("adding account diff to out channel. address: %v, block height: %v, balance: %v, nonce: %v",
+			accountDiff.Address.Hex(), accountDiff.BlockHeight, accountDiff.Balance, accountDiff.Nonce)
+		out <- accountDiff
+	}
+}
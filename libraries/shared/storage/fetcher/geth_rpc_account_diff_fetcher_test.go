@@ -0,0 +1,109 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher_test
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/makerdao/vulcanizedb/libraries/shared/mocks"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/fetcher"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
+	"github.com/makerdao/vulcanizedb/libraries/shared/test_data"
+	"github.com/makerdao/vulcanizedb/pkg/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Geth RPC Account Diff Fetcher", func() {
+	var (
+		streamer             *mocks.MockStoragediffStreamer
+		statediffPayloadChan chan filters.Payload
+		accountDiffFetcher   fetcher.GethRpcAccountDiffFetcher
+		accountDiffChan      chan types.RawAccountDiff
+		subscription         *fakes.MockSubscription
+		errorChan            chan error
+		statusWriter         fakes.MockStatusWriter
+		watchedAddress       common.Address
+	)
+
+	BeforeEach(func() {
+		subscription = &fakes.MockSubscription{Errs: make(chan error)}
+		streamer = &mocks.MockStoragediffStreamer{ClientSubscription: subscription}
+		statediffPayloadChan = make(chan filters.Payload, 1)
+		statusWriter = fakes.MockStatusWriter{}
+		watchedAddress = common.BytesToAddress(test_data.ContractLeafKey[:])
+		accountDiffFetcher = fetcher.NewGethRpcAccountDiffFetcher(streamer, statediffPayloadChan, &statusWriter,
+			[]common.Address{watchedAddress})
+		accountDiffFetcher.RetryInterval = time.Millisecond
+		accountDiffChan = make(chan types.RawAccountDiff)
+		errorChan = make(chan error)
+	})
+
+	It("creates file for health check when connection established", func(done Done) {
+		go accountDiffFetcher.FetchAccountDiffs(accountDiffChan, errorChan)
+
+		Eventually(func() bool {
+			return statusWriter.WriteCalled
+		}).Should(BeTrue())
+		close(done)
+	})
+
+	It("adds account diffs for watched addresses to the out channel", func(done Done) {
+		streamer.SetPayloads([]filters.Payload{test_data.MockStatediffPayload})
+
+		go accountDiffFetcher.FetchAccountDiffs(accountDiffChan, errorChan)
+
+		Expect(<-accountDiffChan).To(Equal(types.RawAccountDiff{
+			Address:     watchedAddress,
+			BlockHash:   common.HexToHash(test_data.BlockHash),
+			BlockHeight: int(test_data.BlockNumber.Int64()),
+			Balance:     big.NewInt(test_data.NewBalanceValue),
+			Nonce:       test_data.NewNonceValue,
+			CodeHash:    common.BytesToHash(test_data.CodeHash),
+			StorageRoot: test_data.ContractRoot,
+		}))
+		Consistently(accountDiffChan).ShouldNot(Receive())
+		close(done)
+	})
+
+	It("adds errors to error channel if decoding the state diff RLP fails", func(done Done) {
+		streamer.SetPayloads([]filters.Payload{{}})
+
+		go accountDiffFetcher.FetchAccountDiffs(accountDiffChan, errorChan)
+
+		expectedErr := fmt.Errorf("error decoding account diff from geth payload: %w", io.EOF)
+		Expect(<-errorChan).To(MatchError(expectedErr))
+		close(done)
+	})
+
+	It("unsubscribes and reconnects if the subscription fails", func(done Done) {
+		go accountDiffFetcher.FetchAccountDiffs(accountDiffChan, errorChan)
+
+		subscription.Errs <- fakes.FakeError
+
+		Eventually(func() int {
+			return streamer.StreamCallCount
+		}).Should(Equal(2))
+		Expect(subscription.UnsubscribeCalled).To(BeTrue())
+		close(done)
+	})
+})
@@ -15,21 +15,46 @@
 package fetcher
 
 import (
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/eth/filters"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
 	"github.com/makerdao/vulcanizedb/libraries/shared/streamer"
+	"github.com/makerdao/vulcanizedb/pkg/core"
 	"github.com/makerdao/vulcanizedb/pkg/fs"
 	"github.com/sirupsen/logrus"
 )
 
+var (
+	DefaultRetryInterval      = time.Second
+	DefaultMaxRetryInterval   = time.Minute
+	DefaultMaxGapFillAttempts = 10
+	// ErrIncompleteGap is wrapped by GapFiller errors when some blocks in the range couldn't be filled yet, e.g.
+	// because their headers haven't been synced
+	ErrIncompleteGap = errors.New("gap not fully back-filled")
+)
+
+// GapFiller back-fills storage for blocks whose diffs were missed while the fetcher was disconnected
+type GapFiller interface {
+	FillGap(startingBlock, endingBlock int64) error
+}
+
 type GethRpcStorageFetcher struct {
 	statediffPayloadChan chan filters.Payload
 	streamer             streamer.Streamer
 	statusWriter         fs.StatusWriter
+	blockChain           core.BlockChain
+	gapFiller            GapFiller
+	progress             *blockProgress
+	RetryInterval        time.Duration
+	MaxRetryInterval     time.Duration
+	// MaxGapFillAttempts is how many times filling a gap is tried while it can't be covered, e.g. because headers in
+	// it are still being synced
+	MaxGapFillAttempts int
 }
 
 func NewGethRpcStorageFetcher(streamer streamer.Streamer, statediffPayloadChan chan filters.Payload, statusWriter fs.StatusWriter) GethRpcStorageFetcher {
@@ -37,46 +62,168 @@ func NewGethRpcStorageFetcher(streamer streamer.Streamer, statediffPayloadChan c
 		statediffPayloadChan: statediffPayloadChan,
 		streamer:             streamer,
 		statusWriter:         statusWriter,
+		progress:             &blockProgress{},
+		RetryInterval:        DefaultRetryInterval,
+		MaxRetryInterval:     DefaultMaxRetryInterval,
+		MaxGapFillAttempts:   DefaultMaxGapFillAttempts,
 	}
 }
 
+// WithGapRecovery enables back-filling the blocks between the last block received and the head of the chain whenever
+// the subscription is (re)established. The last block received is saved to checkpoints once its diffs have been
+// persisted, and on startup recovery starts after the block last saved there. A nil gapFiller only logs the missed
+// range.
+func (fetcher GethRpcStorageFetcher) WithGapRecovery(blockChain core.BlockChain, gapFiller GapFiller, checkpoints BlockCheckpointStore) GethRpcStorageFetcher {
+	fetcher.blockChain = blockChain
+	fetcher.gapFiller = gapFiller
+	fetcher.progress.store = checkpoints
+	return fetcher
+}
+
 var (
 	processingDiffsLogString = "processing %d storage diffs for account %s"
 	addingDiffsLogString     = "adding storage diff to out channel. keccak of address: %v, block height: %v, storage key: %v, storage value: %v"
 )
 
+type blockRange struct {
+	start, end int64
+}
+
 func (fetcher GethRpcStorageFetcher) FetchStorageDiffs(out chan<- types.RawDiff, errs chan<- error) {
-	ethStatediffPayloadChan := fetcher.statediffPayloadChan
-	clientSubscription, clientSubErr := fetcher.streamer.Stream(ethStatediffPayloadChan)
-	if clientSubErr != nil {
-		errs <- clientSubErr
-		panic(fmt.Sprintf("Error creating a geth client subscription: %v", clientSubErr))
+	var lastBlock int64
+	if fetcher.progress.store != nil {
+		checkpoint, loadErr := fetcher.progress.store.LoadLastBlock()
+		if loadErr != nil {
+			errs <- fmt.Errorf("error loading last block received: %w", loadErr)
+			return
+		}
+		lastBlock = checkpoint
+		fetcher.progress.mutex.Lock()
+		fetcher.progress.received, fetcher.progress.saved = checkpoint, checkpoint
+		fetcher.progress.mutex.Unlock()
+	}
+
+	gaps := make(chan blockRange, 10)
+	go fetcher.fillGaps(gaps)
+
+	for {
+		clientSubscription := fetcher.subscribe(errs)
+		fetcher.recoverGap(lastBlock, gaps)
+		lastBlock = fetcher.streamDiffs(clientSubscription, out, errs, lastBlock)
+		clientSubscription.Unsubscribe()
 	}
-	logrus.Info("Successfully created a geth client subscription: ", clientSubscription)
+}
+
+func (fetcher GethRpcStorageFetcher) subscribe(errs chan<- error) core.Subscription {
+	return subscribeWithBackoff(fetcher.streamer, fetcher.statediffPayloadChan, fetcher.statusWriter,
+		fetcher.RetryInterval, fetcher.MaxRetryInterval, errs)
+}
+
+// subscribeWithBackoff retries creating the subscription with exponential backoff until it succeeds
+func subscribeWithBackoff(streamer streamer.Streamer, payloadChan chan filters.Payload, statusWriter fs.StatusWriter,
+	retryInterval, maxRetryInterval time.Duration, errs chan<- error) core.Subscription {
+	for {
+		clientSubscription, clientSubErr := streamer.Stream(payloadChan)
+		if clientSubErr == nil {
+			logrus.Info("Successfully created a geth client subscription: ", clientSubscription)
+			writeErr := statusWriter.Write()
+			if writeErr != nil {
+				errs <- writeErr
+			}
+			return clientSubscription
+		}
 
-	writeErr := fetcher.statusWriter.Write()
-	if writeErr != nil {
-		errs <- writeErr
+		logrus.Errorf("error creating a geth client subscription, retrying in %s: %s", retryInterval, clientSubErr.Error())
+		time.Sleep(retryInterval)
+		retryInterval *= 2
+		if retryInterval > maxRetryInterval {
+			retryInterval = maxRetryInterval
+		}
 	}
+}
 
+// streamDiffs handles payloads until the subscription fails, returning the last block received
+func (fetcher GethRpcStorageFetcher) streamDiffs(clientSubscription core.Subscription, out chan<- types.RawDiff, errs chan<- error, lastBlock int64) int64 {
 	for {
 		select {
 		case err := <-clientSubscription.Err():
-			logrus.Errorf("error with client subscription: %s", err.Error())
-			errs <- err
-		case diffPayload := <-ethStatediffPayloadChan:
+			logrus.Errorf("error with client subscription, reconnecting: %s", err.Error())
+			return lastBlock
+		case diffPayload := <-fetcher.statediffPayloadChan:
 			logrus.Trace("received a statediff payload")
-			fetcher.handleDiffPayload(diffPayload, out, errs)
+			blockNumber := fetcher.handleDiffPayload(diffPayload, out, errs)
+			if blockNumber > 0 {
+				fetcher.progress.blockReceived(blockNumber)
+			}
+			if blockNumber > lastBlock {
+				lastBlock = blockNumber
+			}
+		}
+	}
+}
+
+func (fetcher GethRpcStorageFetcher) recoverGap(lastBlock int64, gaps chan<- blockRange) {
+	if fetcher.blockChain == nil || lastBlock <= 0 {
+		return
+	}
+	head, headErr := fetcher.blockChain.LastBlock()
+	if headErr != nil {
+		logrus.Errorf("error getting head of chain to check for missed storage diffs after block %d: %s", lastBlock, headErr.Error())
+		return
+	}
+	gap := blockRange{start: lastBlock + 1, end: head.Int64()}
+	if gap.start > gap.end {
+		return
+	}
+	if fetcher.gapFiller == nil {
+		logrus.Warnf("storage diffs may have been missed for blocks %d-%d; run backfillStorage for that range", gap.start, gap.end)
+		return
+	}
+	logrus.Infof("back-filling storage for blocks %d-%d missed while disconnected", gap.start, gap.end)
+	gaps <- gap
+}
+
+// fillGaps back-fills missed ranges one at a time so that filling doesn't block streaming new diffs
+func (fetcher GethRpcStorageFetcher) fillGaps(gaps <-chan blockRange) {
+	for gap := range gaps {
+		fetcher.fillGap(gap)
+	}
+}
+
+// fillGap back-fills the range, retrying with backoff while the filler can't cover all of it
+func (fetcher GethRpcStorageFetcher) fillGap(gap blockRange) {
+	retryInterval := fetcher.RetryInterval
+	for attempt := 1; ; attempt++ {
+		fillErr := fetcher.gapFiller.FillGap(gap.start, gap.end)
+		if fillErr == nil {
+			return
+		}
+		if !errors.Is(fillErr, ErrIncompleteGap) || attempt >= fetcher.MaxGapFillAttempts {
+			logrus.Errorf("error back-filling storage for blocks %d-%d; run backfillStorage for that range: %s",
+				gap.start, gap.end, fillErr.Error())
+			return
+		}
+		logrus.Warnf("storage for blocks %d-%d not fully back-filled, retrying in %s: %s", gap.start, gap.end,
+			retryInterval, fillErr.Error())
+		time.Sleep(retryInterval)
+		retryInterval *= 2
+		if retryInterval > fetcher.MaxRetryInterval {
+			retryInterval = fetcher.MaxRetryInterval
 		}
 	}
 }
 
-func (fetcher GethRpcStorageFetcher) handleDiffPayload(payload filters.Payload, out chan<- types.RawDiff, errs chan<- error) {
+// DiffsFlushed saves the last block received, since every diff sent up to it has been persisted
+func (fetcher GethRpcStorageFetcher) DiffsFlushed() {
+	fetcher.progress.flushed()
+}
+
+func (fetcher GethRpcStorageFetcher) handleDiffPayload(payload filters.Payload, out chan<- types.RawDiff, errs chan<- error) int64 {
 	var stateDiff filters.StateDiff
 	decodeErr := rlp.DecodeBytes(payload.StateDiffRlp, &stateDiff)
 	if decodeErr != nil {
 		errs <- fmt.Errorf("error decoding storage diff from geth payload: %w", decodeErr)
-		return
+		return 0
 	}
 
 	for _, account := range stateDiff.UpdatedAccounts {
@@ -85,11 +232,16 @@ func (fetcher GethRpcStorageFetcher) handleDiffPayload(payload filters.Payload,
 			rawDiff, formatErr := types.FromGethStateDiff(account, &stateDiff, accountStorage)
 			if formatErr != nil {
 				errs <- formatErr
-				return
+				return 0
 			}
 
 			logrus.Tracef(addingDiffsLogString, rawDiff.Address.Hex(), rawDiff.BlockHeight, rawDiff.StorageKey.Hex(), rawDiff.StorageValue.Hex())
 			out <- rawDiff
+			fetcher.progress.diffSent()
 		}
 	}
+	if stateDiff.BlockNumber == nil {
+		return 0
+	}
+	return stateDiff.BlockNumber.Int64()
 }
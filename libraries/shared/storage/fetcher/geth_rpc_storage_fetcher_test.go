@@ -17,6 +17,8 @@ package fetcher_test
 import (
 	"fmt"
 	"io"
+	"math/big"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/eth/filters"
@@ -51,22 +53,23 @@ var _ = Describe("Geth RPC Storage Fetcher", func() {
 			streamer = &mocks.MockStoragediffStreamer{ClientSubscription: subscription}
 			statediffPayloadChan = make(chan filters.Payload, 1)
 			statediffFetcher = fetcher.NewGethRpcStorageFetcher(streamer, statediffPayloadChan, &statusWriter)
+			statediffFetcher.RetryInterval = time.Millisecond
 			storagediffChan = make(chan types.RawDiff)
 			errorChan = make(chan error)
 			stateDiffPayloads = []filters.Payload{test_data.MockStatediffPayload}
 		})
 
-		It("adds errors to errors channel if the streamer fails to subscribe", func(done Done) {
+		It("retries subscribing if the streamer fails to subscribe", func(done Done) {
 			streamer.SetSubscribeError(fakes.FakeError)
 
-			go func() {
-				failedSub := func() {
-					statediffFetcher.FetchStorageDiffs(storagediffChan, errorChan)
-				}
-				Expect(failedSub).To(Panic())
-			}()
+			go statediffFetcher.FetchStorageDiffs(storagediffChan, errorChan)
 
-			Expect(<-errorChan).To(MatchError(fakes.FakeError))
+			Eventually(func() int {
+				return streamer.StreamCallCount
+			}).Should(Equal(2))
+			Eventually(func() bool {
+				return statusWriter.WriteCalled
+			}).Should(BeTrue())
 			close(done)
 		})
 
@@ -91,12 +94,15 @@ var _ = Describe("Geth RPC Storage Fetcher", func() {
 				close(done)
 			})
 
-			It("adds error to errors channel if the subscription fails", func(done Done) {
+			It("unsubscribes and reconnects if the subscription fails", func(done Done) {
 				go statediffFetcher.FetchStorageDiffs(storagediffChan, errorChan)
 
 				subscription.Errs <- fakes.FakeError
 
-				Expect(<-errorChan).To(MatchError(fakes.FakeError))
+				Eventually(func() int {
+					return streamer.StreamCallCount
+				}).Should(Equal(2))
+				Expect(subscription.UnsubscribeCalled).To(BeTrue())
 				close(done)
 			})
 
@@ -166,5 +172,124 @@ var _ = Describe("Geth RPC Storage Fetcher", func() {
 				close(done)
 			})
 		})
+
+		Describe("gap recovery", func() {
+			var (
+				blockChain  *fakes.MockBlockChain
+				gapFiller   *mocks.MockGapFiller
+				checkpoints *mocks.MockBlockCheckpointStore
+				lastBlock   int64
+			)
+
+			BeforeEach(func() {
+				blockChain = fakes.NewMockBlockChain()
+				gapFiller = &mocks.MockGapFiller{}
+				lastBlock = test_data.BlockNumber.Int64() - 10
+				checkpoints = &mocks.MockBlockCheckpointStore{LastBlockToReturn: lastBlock}
+			})
+
+			It("back-fills blocks between the last block received and the head of the chain", func(done Done) {
+				blockChain.SetLastBlock(big.NewInt(lastBlock + 5))
+				statediffFetcher = statediffFetcher.WithGapRecovery(blockChain, gapFiller, checkpoints)
+
+				go statediffFetcher.FetchStorageDiffs(storagediffChan, errorChan)
+
+				Eventually(func() []mocks.GapRange {
+					return gapFiller.FillGapPassedRanges
+				}).Should(ConsistOf(mocks.GapRange{StartingBlock: lastBlock + 1, EndingBlock: lastBlock + 5}))
+				close(done)
+			})
+
+			It("does not back-fill if the head of the chain has not advanced", func(done Done) {
+				blockChain.SetLastBlock(big.NewInt(lastBlock))
+				statediffFetcher = statediffFetcher.WithGapRecovery(blockChain, gapFiller, checkpoints)
+
+				go statediffFetcher.FetchStorageDiffs(storagediffChan, errorChan)
+
+				Eventually(func() bool {
+					return statusWriter.WriteCalled
+				}).Should(BeTrue())
+				Consistently(func() []mocks.GapRange {
+					return gapFiller.FillGapPassedRanges
+				}).Should(BeEmpty())
+				close(done)
+			})
+
+			It("back-fills from the last block streamed after reconnecting", func(done Done) {
+				streamedBlock := test_data.BlockNumber.Int64()
+				blockChain.SetLastBlock(big.NewInt(streamedBlock + 3))
+				streamer.SetPayloads(stateDiffPayloads)
+				statediffFetcher = statediffFetcher.WithGapRecovery(blockChain, gapFiller, checkpoints)
+
+				go statediffFetcher.FetchStorageDiffs(storagediffChan, errorChan)
+				<-storagediffChan
+				<-storagediffChan
+				<-storagediffChan
+				subscription.Errs <- fakes.FakeError
+
+				Eventually(func() []mocks.GapRange {
+					return gapFiller.FillGapPassedRanges
+				}).Should(ContainElement(mocks.GapRange{StartingBlock: streamedBlock + 1, EndingBlock: streamedBlock + 3}))
+				close(done)
+			})
+
+			It("retries filling a gap while it can't be fully covered", func(done Done) {
+				blockChain.SetLastBlock(big.NewInt(lastBlock + 5))
+				gapFiller.FillGapErrors = []error{fmt.Errorf("%w: headers not synced", fetcher.ErrIncompleteGap)}
+				statediffFetcher = statediffFetcher.WithGapRecovery(blockChain, gapFiller, checkpoints)
+
+				go statediffFetcher.FetchStorageDiffs(storagediffChan, errorChan)
+
+				Eventually(func() []mocks.GapRange {
+					return gapFiller.FillGapPassedRanges
+				}).Should(HaveLen(2))
+				Consistently(func() []mocks.GapRange {
+					return gapFiller.FillGapPassedRanges
+				}).Should(HaveLen(2))
+				close(done)
+			})
+
+			It("doesn't retry filling a gap that failed for another reason", func(done Done) {
+				blockChain.SetLastBlock(big.NewInt(lastBlock + 5))
+				gapFiller.FillGapError = fakes.FakeError
+				statediffFetcher = statediffFetcher.WithGapRecovery(blockChain, gapFiller, checkpoints)
+
+				go statediffFetcher.FetchStorageDiffs(storagediffChan, errorChan)
+
+				Eventually(func() []mocks.GapRange {
+					return gapFiller.FillGapPassedRanges
+				}).Should(HaveLen(1))
+				Consistently(func() []mocks.GapRange {
+					return gapFiller.FillGapPassedRanges
+				}).Should(HaveLen(1))
+				close(done)
+			})
+
+			It("returns an error if loading the last block received fails", func(done Done) {
+				checkpoints.LoadLastBlockErr = fakes.FakeError
+				statediffFetcher = statediffFetcher.WithGapRecovery(blockChain, gapFiller, checkpoints)
+
+				go statediffFetcher.FetchStorageDiffs(storagediffChan, errorChan)
+
+				Expect(<-errorChan).To(MatchError(fakes.FakeError))
+				close(done)
+			})
+
+			It("saves the last block received once its diffs have been flushed", func(done Done) {
+				blockChain.SetLastBlock(big.NewInt(lastBlock))
+				streamer.SetPayloads(stateDiffPayloads)
+				statediffFetcher = statediffFetcher.WithGapRecovery(blockChain, gapFiller, checkpoints)
+
+				go statediffFetcher.FetchStorageDiffs(storagediffChan, errorChan)
+				<-storagediffChan
+				<-storagediffChan
+				<-storagediffChan
+
+				Consistently(checkpoints.SavedBlocks).Should(BeEmpty())
+				statediffFetcher.DiffsFlushed()
+				Eventually(checkpoints.SavedBlocks).Should(ConsistOf(test_data.BlockNumber.Int64()))
+				close(done)
+			})
+		})
 	})
 })
@@ -21,3 +21,14 @@ import (
 type IStorageFetcher interface {
 	FetchStorageDiffs(out chan<- types.RawDiff, errs chan<- error)
 }
+
+// FlushObserver is implemented by fetchers that need to know when the diffs they've sent have been persisted, e.g.
+// to record how far through their source they've got
+type FlushObserver interface {
+	// DiffsFlushed is called once every diff received from the fetcher so far has been persisted
+	DiffsFlushed()
+}
+
+type IAccountDiffFetcher interface {
+	FetchAccountDiffs(out chan<- types.RawAccountDiff, errs chan<- error)
+}
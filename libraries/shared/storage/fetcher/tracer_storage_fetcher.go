@@ -0,0 +1,244 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
+	"github.com/makerdao/vulcanizedb/pkg/core"
+	"github.com/makerdao/vulcanizedb/pkg/fs"
+	"github.com/sirupsen/logrus"
+)
+
+type Tracer string
+
+const (
+	// PrestateTracer uses debug_traceBlockByHash with geth's prestateTracer in diff mode
+	PrestateTracer Tracer = "prestate"
+	// StateDiffTracer uses trace_replayBlockTransactions with the stateDiff trace type (Parity, OpenEthereum, Erigon)
+	StateDiffTracer Tracer = "stateDiff"
+)
+
+var (
+	DefaultTracerPollingInterval = 5 * time.Second
+	DefaultTracerReorgWindow     = int64(15)
+	DefaultTracerMaxRetries      = 10
+	MaxTracerBackoff             = time.Minute
+)
+
+// TracerStorageFetcher derives storage diffs by tracing each new block on a node that exposes tracing APIs, so that
+// diffs can be extracted without a patched client
+type TracerStorageFetcher struct {
+	rpcClient        core.RpcClient
+	blockChain       core.BlockChain
+	tracer           Tracer
+	watchedAddresses map[common.Address]bool
+	statusWriter     fs.StatusWriter
+	startingBlock    int64
+	progress         *blockProgress
+	PollingInterval  time.Duration
+	// ReorgWindow is the number of recently traced blocks re-checked each poll so that replaced blocks get re-traced
+	ReorgWindow int64
+	// MaxRetries is the number of consecutive failed polls retried before the fetcher gives up with an error
+	MaxRetries int
+}
+
+// NewTracerStorageFetcher creates a fetcher that traces blocks from startingBlock onward, or from the head of the
+// chain if startingBlock is not positive. Only diffs for the watched addresses are emitted.
+func NewTracerStorageFetcher(rpcClient core.RpcClient, blockChain core.BlockChain, tracer Tracer, watchedAddresses []common.Address, statusWriter fs.StatusWriter, startingBlock int64) (TracerStorageFetcher, error) {
+	if tracer != PrestateTracer && tracer != StateDiffTracer {
+		return TracerStorageFetcher{}, fmt.Errorf("unsupported tracer %q: expected %q or %q", tracer, PrestateTracer, StateDiffTracer)
+	}
+	watched := make(map[common.Address]bool, len(watchedAddresses))
+	for _, address := range watchedAddresses {
+		watched[address] = true
+	}
+	return TracerStorageFetcher{
+		rpcClient:        rpcClient,
+		blockChain:       blockChain,
+		tracer:           tracer,
+		watchedAddresses: watched,
+		statusWriter:     statusWriter,
+		startingBlock:    startingBlock,
+		progress:         &blockProgress{},
+		PollingInterval:  DefaultTracerPollingInterval,
+		ReorgWindow:      DefaultTracerReorgWindow,
+		MaxRetries:       DefaultTracerMaxRetries,
+	}, nil
+}
+
+// WithCheckpoints saves the last block traced to checkpoints once its diffs have been persisted, so that a restarted
+// fetcher can start after it
+func (fetcher TracerStorageFetcher) WithCheckpoints(checkpoints BlockCheckpointStore) TracerStorageFetcher {
+	fetcher.progress.store = checkpoints
+	return fetcher
+}
+
+// DiffsFlushed saves the last block traced, since every diff sent up to it has been persisted
+func (fetcher TracerStorageFetcher) DiffsFlushed() {
+	fetcher.progress.flushed()
+}
+
+// FetchStorageDiffs traces new blocks as they're added to the chain
+// Failed polls, such as those hitting transient RPC errors, are retried with backoff; an error is only sent once
+// MaxRetries consecutive polls have failed, after which the fetcher stops
+func (fetcher TracerStorageFetcher) FetchStorageDiffs(out chan<- types.RawDiff, errs chan<- error) {
+	writeErr := fetcher.statusWriter.Write()
+	if writeErr != nil {
+		errs <- writeErr
+	}
+
+	nextBlock := fetcher.startingBlock
+	tracedHashes := make(map[int64]string)
+	failures := 0
+	for {
+		pollErr := fetcher.poll(&nextBlock, tracedHashes, out)
+		if pollErr == nil {
+			failures = 0
+			time.Sleep(fetcher.PollingInterval)
+			continue
+		}
+		failures++
+		if failures > fetcher.MaxRetries {
+			errs <- fmt.Errorf("giving up after %d consecutive failures: %w", failures, pollErr)
+			return
+		}
+		backoff := fetcher.backoff(failures)
+		logrus.Warnf("error tracing storage diffs, retrying in %s (%d of %d): %s", backoff, failures, fetcher.MaxRetries, pollErr.Error())
+		time.Sleep(backoff)
+	}
+}
+
+// poll traces the blocks from nextBlock up to the head of the chain, after rewinding to any recently traced block that
+// was replaced, and advances nextBlock past the blocks traced
+func (fetcher TracerStorageFetcher) poll(nextBlock *int64, tracedHashes map[int64]string, out chan<- types.RawDiff) error {
+	head, headErr := fetcher.blockChain.LastBlock()
+	if headErr != nil {
+		return fmt.Errorf("error getting head of chain for tracing storage diffs: %w", headErr)
+	}
+	if *nextBlock <= 0 {
+		*nextBlock = head.Int64()
+	}
+
+	reorgedBlock, reorgErr := fetcher.findReorgedBlock(tracedHashes, *nextBlock)
+	if reorgErr != nil {
+		return reorgErr
+	}
+	if reorgedBlock < *nextBlock {
+		logrus.Infof("block %d was replaced since it was traced, re-tracing from there", reorgedBlock)
+		*nextBlock = reorgedBlock
+	}
+
+	for *nextBlock <= head.Int64() {
+		hash, traceErr := fetcher.fetchBlockDiffs(*nextBlock, out)
+		if traceErr != nil {
+			return traceErr
+		}
+		fetcher.progress.blockReceived(*nextBlock)
+		tracedHashes[*nextBlock] = hash
+		delete(tracedHashes, *nextBlock-fetcher.ReorgWindow)
+		*nextBlock++
+	}
+	return nil
+}
+
+// backoff returns how long to wait before retrying after the given number of consecutive failures, doubling from the
+// polling interval up to MaxTracerBackoff
+func (fetcher TracerStorageFetcher) backoff(failures int) time.Duration {
+	backoff := fetcher.PollingInterval
+	for i := 1; i < failures && backoff < MaxTracerBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > MaxTracerBackoff {
+		return MaxTracerBackoff
+	}
+	return backoff
+}
+
+// findReorgedBlock returns the earliest recently traced block whose hash has changed, or nextBlock if there is none
+func (fetcher TracerStorageFetcher) findReorgedBlock(tracedHashes map[int64]string, nextBlock int64) (int64, error) {
+	if len(tracedHashes) == 0 {
+		return nextBlock, nil
+	}
+	var blockNumbers []int64
+	for blockNumber := range tracedHashes {
+		blockNumbers = append(blockNumbers, blockNumber)
+	}
+	headers, headersErr := fetcher.blockChain.GetHeadersByNumbers(blockNumbers)
+	if headersErr != nil {
+		return nextBlock, fmt.Errorf("error checking traced blocks for reorgs: %w", headersErr)
+	}
+	reorgedBlock := nextBlock
+	for _, header := range headers {
+		if header.Hash != tracedHashes[header.BlockNumber] && header.BlockNumber < reorgedBlock {
+			reorgedBlock = header.BlockNumber
+		}
+	}
+	return reorgedBlock, nil
+}
+
+func (fetcher TracerStorageFetcher) fetchBlockDiffs(blockNumber int64, out chan<- types.RawDiff) (string, error) {
+	header, headerErr := fetcher.blockChain.GetHeaderByNumber(blockNumber)
+	if headerErr != nil {
+		return "", fmt.Errorf("error getting header %d for tracing storage diffs: %w", blockNumber, headerErr)
+	}
+
+	diffs, traceErr := fetcher.traceBlock(header)
+	if traceErr != nil {
+		return "", fmt.Errorf("error tracing storage diffs for block %d: %w", blockNumber, traceErr)
+	}
+
+	for _, diff := range diffs {
+		if fetcher.watchedAddresses[diff.Address] {
+			logrus.Tracef(addingDiffsLogString, diff.Address.Hex(), diff.BlockHeight, diff.StorageKey.Hex(), diff.StorageValue.Hex())
+			out <- diff
+			fetcher.progress.diffSent()
+		}
+	}
+	return header.Hash, nil
+}
+
+func (fetcher TracerStorageFetcher) traceBlock(header core.Header) ([]types.RawDiff, error) {
+	blockHash := common.HexToHash(header.Hash)
+	blockHeight := int(header.BlockNumber)
+	switch fetcher.tracer {
+	case StateDiffTracer:
+		var traces []types.StateDiffTrace
+		callErr := fetcher.rpcClient.CallContext(context.Background(), &traces, "trace_replayBlockTransactions",
+			hexutil.EncodeUint64(uint64(header.BlockNumber)), []string{"stateDiff"})
+		if callErr != nil {
+			return nil, callErr
+		}
+		return types.FromStateDiffTraces(blockHash, blockHeight, traces)
+	default:
+		var traces []types.PrestateTrace
+		tracerConfig := map[string]interface{}{
+			"tracer":       "prestateTracer",
+			"tracerConfig": map[string]interface{}{"diffMode": true},
+		}
+		callErr := fetcher.rpcClient.CallContext(context.Background(), &traces, "debug_traceBlockByHash", blockHash, tracerConfig)
+		if callErr != nil {
+			return nil, callErr
+		}
+		return types.FromPrestateTraces(blockHash, blockHeight, traces), nil
+	}
+}
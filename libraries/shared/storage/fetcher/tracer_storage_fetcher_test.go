@@ -0,0 +1,195 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher_test
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/makerdao/vulcanizedb/libraries/shared/mocks"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/fetcher"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
+	"github.com/makerdao/vulcanizedb/libraries/shared/test_data"
+	"github.com/makerdao/vulcanizedb/pkg/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tracer Storage Fetcher", func() {
+	var (
+		rpcClient        *mocks.MockRpcClient
+		blockChain       *fakes.MockBlockChain
+		statusWriter     fakes.MockStatusWriter
+		storagediffChan  chan types.RawDiff
+		errorChan        chan error
+		watchedAddress   common.Address
+		unwatchedAddress common.Address
+		storageKey       common.Hash
+		storageValue     common.Hash
+		blockNumber      int64
+		blockHash        string
+	)
+
+	BeforeEach(func() {
+		rpcClient = &mocks.MockRpcClient{CallContextResponses: make(map[string][]string)}
+		blockChain = fakes.NewMockBlockChain()
+		statusWriter = fakes.MockStatusWriter{}
+		storagediffChan = make(chan types.RawDiff)
+		errorChan = make(chan error)
+		watchedAddress = test_data.FakeAddress()
+		unwatchedAddress = test_data.FakeAddress()
+		storageKey = test_data.FakeHash()
+		storageValue = test_data.FakeHash()
+		blockNumber = 100
+		blockHash = test_data.FakeHash().Hex()
+		blockChain.SetLastBlock(big.NewInt(blockNumber))
+		blockChain.SetHeaderHash(blockNumber, blockHash)
+	})
+
+	newFetcher := func(tracer fetcher.Tracer) fetcher.TracerStorageFetcher {
+		tracerFetcher, err := fetcher.NewTracerStorageFetcher(rpcClient, blockChain, tracer,
+			[]common.Address{watchedAddress}, &statusWriter, blockNumber)
+		Expect(err).NotTo(HaveOccurred())
+		tracerFetcher.PollingInterval = time.Millisecond
+		return tracerFetcher
+	}
+
+	prestateResponse := func(address common.Address, value common.Hash) string {
+		return `[{"result":{"pre":{},"post":{"` + address.Hex() + `":{"storage":{"` + storageKey.Hex() + `":"` + value.Hex() + `"}}}}}]`
+	}
+
+	expectedDiff := func(hash string, value common.Hash) types.RawDiff {
+		return types.RawDiff{
+			Address:      watchedAddress,
+			BlockHash:    common.HexToHash(hash),
+			BlockHeight:  int(blockNumber),
+			StorageKey:   storageKey,
+			StorageValue: value,
+		}
+	}
+
+	It("returns an error for an unsupported tracer", func() {
+		_, err := fetcher.NewTracerStorageFetcher(rpcClient, blockChain, "callTracer", nil, &statusWriter, 0)
+
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("creates file for health check", func(done Done) {
+		go newFetcher(fetcher.PrestateTracer).FetchStorageDiffs(storagediffChan, errorChan)
+
+		Eventually(func() bool {
+			return statusWriter.WriteCalled
+		}).Should(BeTrue())
+		close(done)
+	})
+
+	It("traces blocks with the prestateTracer in diff mode", func(done Done) {
+		rpcClient.CallContextResponses["debug_traceBlockByHash"] = []string{prestateResponse(watchedAddress, storageValue)}
+
+		go newFetcher(fetcher.PrestateTracer).FetchStorageDiffs(storagediffChan, errorChan)
+
+		Expect(<-storagediffChan).To(Equal(expectedDiff(blockHash, storageValue)))
+		Expect(rpcClient.PassedMethods[0]).To(Equal("debug_traceBlockByHash"))
+		Expect(rpcClient.PassedArgs[0][0]).To(Equal(common.HexToHash(blockHash)))
+		close(done)
+	})
+
+	It("traces blocks with trace_replayBlockTransactions", func(done Done) {
+		rpcClient.CallContextResponses["trace_replayBlockTransactions"] = []string{
+			`[{"stateDiff":{"` + watchedAddress.Hex() + `":{"storage":{"` + storageKey.Hex() + `":{"+":"` + storageValue.Hex() + `"}}}}}]`,
+		}
+
+		go newFetcher(fetcher.StateDiffTracer).FetchStorageDiffs(storagediffChan, errorChan)
+
+		Expect(<-storagediffChan).To(Equal(expectedDiff(blockHash, storageValue)))
+		Expect(rpcClient.PassedMethods[0]).To(Equal("trace_replayBlockTransactions"))
+		Expect(rpcClient.PassedArgs[0]).To(Equal([]interface{}{hexutil.EncodeUint64(uint64(blockNumber)), []string{"stateDiff"}}))
+		close(done)
+	})
+
+	It("only emits diffs for watched addresses", func(done Done) {
+		rpcClient.CallContextResponses["debug_traceBlockByHash"] = []string{
+			prestateResponse(unwatchedAddress, storageValue),
+			prestateResponse(watchedAddress, storageValue),
+		}
+		blockChain.SetLastBlock(big.NewInt(blockNumber + 1))
+
+		go newFetcher(fetcher.PrestateTracer).FetchStorageDiffs(storagediffChan, errorChan)
+
+		diff := <-storagediffChan
+		Expect(diff.Address).To(Equal(watchedAddress))
+		Expect(diff.BlockHeight).To(Equal(int(blockNumber + 1)))
+		close(done)
+	})
+
+	It("saves each traced block once its diffs have been flushed", func(done Done) {
+		rpcClient.CallContextResponses["debug_traceBlockByHash"] = []string{prestateResponse(watchedAddress, storageValue)}
+		checkpoints := &mocks.MockBlockCheckpointStore{}
+		tracerFetcher := newFetcher(fetcher.PrestateTracer).WithCheckpoints(checkpoints)
+
+		go tracerFetcher.FetchStorageDiffs(storagediffChan, errorChan)
+		<-storagediffChan
+
+		Consistently(checkpoints.SavedBlocks).Should(BeEmpty())
+		tracerFetcher.DiffsFlushed()
+		Eventually(checkpoints.SavedBlocks).Should(ConsistOf(blockNumber))
+		close(done)
+	})
+
+	It("re-traces blocks that were replaced after being traced", func(done Done) {
+		newValue := test_data.FakeHash()
+		newHash := test_data.FakeHash().Hex()
+		rpcClient.CallContextResponses["debug_traceBlockByHash"] = []string{
+			prestateResponse(watchedAddress, storageValue),
+			prestateResponse(watchedAddress, newValue),
+		}
+
+		go newFetcher(fetcher.PrestateTracer).FetchStorageDiffs(storagediffChan, errorChan)
+
+		Expect(<-storagediffChan).To(Equal(expectedDiff(blockHash, storageValue)))
+		blockChain.SetHeaderHash(blockNumber, newHash)
+		Expect(<-storagediffChan).To(Equal(expectedDiff(newHash, newValue)))
+		close(done)
+	})
+
+	It("retries when tracing fails", func(done Done) {
+		rpcClient.CallContextErr = fakes.FakeError
+		rpcClient.CallContextErrTimes = 2
+		rpcClient.CallContextResponses["debug_traceBlockByHash"] = []string{prestateResponse(watchedAddress, storageValue)}
+
+		go newFetcher(fetcher.PrestateTracer).FetchStorageDiffs(storagediffChan, errorChan)
+
+		Expect(<-storagediffChan).To(Equal(expectedDiff(blockHash, storageValue)))
+		Expect(rpcClient.PassedMethods).To(HaveLen(3))
+		Consistently(errorChan).ShouldNot(Receive())
+		close(done)
+	})
+
+	It("adds an error to the errors channel once retries are exhausted", func(done Done) {
+		rpcClient.CallContextErr = fakes.FakeError
+		tracerFetcher := newFetcher(fetcher.PrestateTracer)
+		tracerFetcher.MaxRetries = 2
+
+		go tracerFetcher.FetchStorageDiffs(storagediffChan, errorChan)
+
+		Expect(<-errorChan).To(MatchError(ContainSubstring(fakes.FakeError.Error())))
+		Expect(rpcClient.PassedMethods).To(HaveLen(3))
+		close(done)
+	})
+})
@@ -0,0 +1,59 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres"
+)
+
+// FetcherCheckpointRepository saves the last block a storage diff source has delivered in full, as a
+// fetcher.BlockCheckpointStore
+type FetcherCheckpointRepository struct {
+	db     *postgres.DB
+	source string
+}
+
+func NewFetcherCheckpointRepository(db *postgres.DB, source string) FetcherCheckpointRepository {
+	return FetcherCheckpointRepository{db: db, source: source}
+}
+
+// LoadLastBlock returns the last block saved for the source, or zero if none has been
+func (repository FetcherCheckpointRepository) LoadLastBlock() (int64, error) {
+	var blockHeight int64
+	err := repository.db.Get(&blockHeight, `SELECT block_height FROM public.storage_fetcher_checkpoint WHERE source = $1`,
+		repository.source)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error getting last block received from %s: %w", repository.source, err)
+	}
+	return blockHeight, nil
+}
+
+func (repository FetcherCheckpointRepository) SaveLastBlock(blockHeight int64) error {
+	_, err := repository.db.Exec(`INSERT INTO public.storage_fetcher_checkpoint (source, block_height) VALUES ($1, $2)
+		ON CONFLICT (source) DO UPDATE SET block_height = excluded.block_height`, repository.source, blockHeight)
+	if err != nil {
+		return fmt.Errorf("error saving last block received from %s: %w", repository.source, err)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package storage_test
+
+import (
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage"
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres"
+	"github.com/makerdao/vulcanizedb/test_config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Fetcher checkpoint repository", func() {
+	var (
+		db         *postgres.DB
+		repository storage.FetcherCheckpointRepository
+	)
+
+	BeforeEach(func() {
+		db = test_config.NewTestDB(test_config.NewTestNode())
+		test_config.CleanTestDB(db)
+		repository = storage.NewFetcherCheckpointRepository(db, "geth")
+	})
+
+	It("returns zero before a block has been saved", func() {
+		lastBlock, err := repository.LoadLastBlock()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lastBlock).To(BeZero())
+	})
+
+	It("returns the last block saved", func() {
+		Expect(repository.SaveLastBlock(10)).To(Succeed())
+		Expect(repository.SaveLastBlock(11)).To(Succeed())
+
+		lastBlock, err := repository.LoadLastBlock()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lastBlock).To(Equal(int64(11)))
+	})
+
+	It("keeps a separate checkpoint for each source", func() {
+		Expect(storage.NewFetcherCheckpointRepository(db, "tracer").SaveLastBlock(20)).To(Succeed())
+		Expect(repository.SaveLastBlock(10)).To(Succeed())
+
+		lastBlock, err := repository.LoadLastBlock()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lastBlock).To(Equal(int64(10)))
+	})
+})
@@ -0,0 +1,19 @@
+package proof_test
+
+import (
+	"io/ioutil"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus"
+)
+
+func TestProof(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Proof Suite")
+}
+
+var _ = BeforeSuite(func() {
+	logrus.SetOutput(ioutil.Discard)
+})
@@ -0,0 +1,63 @@
+package proof
+
+import (
+	"fmt"
+
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres"
+)
+
+type Repository interface {
+	GetDiffsToVerify(startingBlock, endingBlock int64, sampleSize int) ([]types.PersistedDiff, error)
+	FlagMismatch(diffID int64) error
+}
+
+type repository struct {
+	db *postgres.DB
+}
+
+func NewRepository(db *postgres.DB) repository {
+	return repository{db: db}
+}
+
+// latestDiffsQuery selects the last diff written to each storage slot at each block in the range - the value the
+// slot held at the end of that block. Only diffs from the stored (canonical) header at each height are selected.
+const latestDiffsQuery = `SELECT DISTINCT ON (address, storage_key, block_height, block_hash)
+		id, address, block_height, block_hash, storage_key, storage_value
+	FROM public.storage_diff
+	WHERE block_height BETWEEN $1 AND $2
+	  AND status != 'noncanonical'
+	  AND EXISTS(SELECT 1
+	      FROM public.headers
+	      WHERE headers.block_number = storage_diff.block_height
+	        AND headers.hash = '0x' || encode(storage_diff.block_hash, 'hex'))
+	ORDER BY address, storage_key, block_height, block_hash, id DESC`
+
+// GetDiffsToVerify returns the latest canonical diff for each storage slot changed at each block in the range, ordered
+// by block and address. A positive sampleSize returns that many at random instead of all of them.
+func (repository repository) GetDiffsToVerify(startingBlock, endingBlock int64, sampleSize int) ([]types.PersistedDiff, error) {
+	var (
+		diffs []types.PersistedDiff
+		err   error
+	)
+	if sampleSize > 0 {
+		err = repository.db.Select(&diffs, `SELECT * FROM (
+				SELECT * FROM (`+latestDiffsQuery+`) latest ORDER BY random() LIMIT $3
+			) sampled ORDER BY block_height, block_hash, address, storage_key`, startingBlock, endingBlock, sampleSize)
+	} else {
+		err = repository.db.Select(&diffs, `SELECT * FROM (`+latestDiffsQuery+`) latest
+			ORDER BY block_height, block_hash, address, storage_key`, startingBlock, endingBlock)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting storage diffs to verify for blocks %d-%d: %w", startingBlock, endingBlock, err)
+	}
+	return diffs, nil
+}
+
+func (repository repository) FlagMismatch(diffID int64) error {
+	_, err := repository.db.Exec(`UPDATE public.storage_diff SET proof_mismatch = true WHERE id = $1`, diffID)
+	if err != nil {
+		return fmt.Errorf("error flagging storage diff %d as mismatched: %w", diffID, err)
+	}
+	return nil
+}
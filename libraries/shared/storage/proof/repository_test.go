@@ -0,0 +1,121 @@
+package proof_test
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/proof"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
+	"github.com/makerdao/vulcanizedb/libraries/shared/test_data"
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres/repositories"
+	"github.com/makerdao/vulcanizedb/pkg/fakes"
+	"github.com/makerdao/vulcanizedb/test_config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Storage proof repository", func() {
+	var (
+		db         = test_config.NewTestDB(test_config.NewTestNode())
+		diffRepo   = storage.NewDiffRepository(db)
+		repo       proof.Repository
+		headerRepo = repositories.NewHeaderRepository(db)
+		address    = test_data.FakeAddress()
+		key        = test_data.FakeHash()
+		blockHash  = common.BigToHash(common.Big1)
+	)
+
+	BeforeEach(func() {
+		test_config.CleanTestDB(db)
+		repo = proof.NewRepository(db)
+	})
+
+	createDiffWithHash := func(blockHeight int, hash, value common.Hash) int64 {
+		id, err := diffRepo.CreateStorageDiff(types.RawDiff{
+			Address:      address,
+			BlockHash:    hash,
+			BlockHeight:  blockHeight,
+			StorageKey:   key,
+			StorageValue: value,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		return id
+	}
+
+	createDiff := func(blockHeight int, value common.Hash) int64 {
+		header := fakes.GetFakeHeader(int64(blockHeight))
+		header.Hash = blockHash.Hex()
+		_, headerErr := headerRepo.CreateOrUpdateHeader(header)
+		Expect(headerErr).NotTo(HaveOccurred())
+		return createDiffWithHash(blockHeight, blockHash, value)
+	}
+
+	Describe("GetDiffsToVerify", func() {
+		It("returns the latest diff to each slot at each block in the range", func() {
+			createDiff(1, common.HexToHash("0x01"))
+			latestID := createDiff(1, common.HexToHash("0x02"))
+			nextBlockID := createDiff(2, common.HexToHash("0x03"))
+			createDiff(3, common.HexToHash("0x04"))
+
+			diffs, err := repo.GetDiffsToVerify(0, 2, 0)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(len(diffs)).To(Equal(2))
+			Expect(diffs[0].ID).To(Equal(latestID))
+			Expect(diffs[0].StorageValue).To(Equal(common.HexToHash("0x02")))
+			Expect(diffs[1].ID).To(Equal(nextBlockID))
+		})
+
+		It("doesn't return noncanonical diffs", func() {
+			canonicalID := createDiff(1, common.HexToHash("0x01"))
+			noncanonicalID := createDiff(1, common.HexToHash("0x02"))
+			Expect(diffRepo.MarkNoncanonical(noncanonicalID)).To(Succeed())
+
+			diffs, err := repo.GetDiffsToVerify(0, 1, 0)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(len(diffs)).To(Equal(1))
+			Expect(diffs[0].ID).To(Equal(canonicalID))
+		})
+
+		It("doesn't return diffs from blocks that aren't the stored header at their height", func() {
+			canonicalID := createDiff(1, common.HexToHash("0x01"))
+			createDiffWithHash(1, test_data.FakeHash(), common.HexToHash("0x02"))
+			createDiffWithHash(2, test_data.FakeHash(), common.HexToHash("0x03"))
+
+			diffs, err := repo.GetDiffsToVerify(0, 2, 0)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(len(diffs)).To(Equal(1))
+			Expect(diffs[0].ID).To(Equal(canonicalID))
+		})
+
+		It("returns a sample ordered by block", func() {
+			for blockHeight := 1; blockHeight <= 10; blockHeight++ {
+				createDiff(blockHeight, common.HexToHash("0x01"))
+			}
+
+			diffs, err := repo.GetDiffsToVerify(0, 10, 3)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(len(diffs)).To(Equal(3))
+			Expect(diffs[0].BlockHeight).To(BeNumerically("<", diffs[1].BlockHeight))
+			Expect(diffs[1].BlockHeight).To(BeNumerically("<", diffs[2].BlockHeight))
+		})
+	})
+
+	Describe("FlagMismatch", func() {
+		It("flags the diff", func() {
+			id := createDiff(1, common.HexToHash("0x01"))
+			otherID := createDiff(2, common.HexToHash("0x02"))
+
+			err := repo.FlagMismatch(id)
+
+			Expect(err).NotTo(HaveOccurred())
+			var flagged, notFlagged bool
+			Expect(db.Get(&flagged, `SELECT proof_mismatch FROM public.storage_diff WHERE id = $1`, id)).To(Succeed())
+			Expect(db.Get(&notFlagged, `SELECT proof_mismatch FROM public.storage_diff WHERE id = $1`, otherID)).To(Succeed())
+			Expect(flagged).To(BeTrue())
+			Expect(notFlagged).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,192 @@
+package proof
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
+	"github.com/makerdao/vulcanizedb/pkg/core"
+	"github.com/makerdao/vulcanizedb/pkg/datastore"
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres"
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres/repositories"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	DefaultBlocksPerQuery = int64(1000)
+
+	errNoStateRoot = errors.New("no state root to verify against")
+)
+
+type Options struct {
+	// SampleSize is how many diffs to check at random; zero checks every diff in the range
+	SampleSize int
+	// FlagMismatches marks diffs that don't match the proven value in storage_diff.proof_mismatch
+	FlagMismatches bool
+}
+
+type Mismatch struct {
+	Diff        types.PersistedDiff
+	ProvenValue common.Hash
+}
+
+type Result struct {
+	Verified   int
+	Skipped    int
+	Mismatches []Mismatch
+}
+
+// Verifier checks persisted storage diffs against Merkle proofs of chain state, proven against the state root of the
+// stored header for each diff's block
+type Verifier struct {
+	BlockChain       core.BlockChain
+	HeaderRepository datastore.HeaderRepository
+	Repository       Repository
+	BlocksPerQuery   int64
+}
+
+func NewVerifier(bc core.BlockChain, db *postgres.DB) Verifier {
+	return Verifier{
+		BlockChain:       bc,
+		HeaderRepository: repositories.NewHeaderRepository(db),
+		Repository:       NewRepository(db),
+		BlocksPerQuery:   DefaultBlocksPerQuery,
+	}
+}
+
+func (verifier Verifier) Verify(startingBlock, endingBlock int64, options Options) (Result, error) {
+	var result Result
+	if options.SampleSize > 0 {
+		diffs, getErr := verifier.Repository.GetDiffsToVerify(startingBlock, endingBlock, options.SampleSize)
+		if getErr != nil {
+			return result, getErr
+		}
+		return result, verifier.verifyDiffs(diffs, options, &result)
+	}
+
+	blocksPerQuery := verifier.BlocksPerQuery
+	if blocksPerQuery < 1 {
+		blocksPerQuery = DefaultBlocksPerQuery
+	}
+	for windowStart := startingBlock; windowStart <= endingBlock; windowStart += blocksPerQuery {
+		windowEnd := windowStart + blocksPerQuery - 1
+		if windowEnd > endingBlock {
+			windowEnd = endingBlock
+		}
+		diffs, getErr := verifier.Repository.GetDiffsToVerify(windowStart, windowEnd, 0)
+		if getErr != nil {
+			return result, getErr
+		}
+		verifyErr := verifier.verifyDiffs(diffs, options, &result)
+		if verifyErr != nil {
+			return result, verifyErr
+		}
+	}
+	return result, nil
+}
+
+// verifyDiffs checks diffs ordered by block and address, fetching one proof per address at each block. Diffs are
+// grouped by block hash as well as height, so each group is only proven against the header it came from.
+func (verifier Verifier) verifyDiffs(diffs []types.PersistedDiff, options Options, result *Result) error {
+	for blockStart := 0; blockStart < len(diffs); {
+		blockEnd := blockStart
+		for blockEnd < len(diffs) && diffs[blockEnd].BlockHeight == diffs[blockStart].BlockHeight &&
+			diffs[blockEnd].BlockHash == diffs[blockStart].BlockHash {
+			blockEnd++
+		}
+		blockErr := verifier.verifyBlock(diffs[blockStart:blockEnd], options, result)
+		if blockErr != nil {
+			return blockErr
+		}
+		blockStart = blockEnd
+	}
+	return nil
+}
+
+func (verifier Verifier) verifyBlock(diffs []types.PersistedDiff, options Options, result *Result) error {
+	blockNumber := int64(diffs[0].BlockHeight)
+	stateRoot, rootErr := verifier.getStateRoot(blockNumber, diffs[0].BlockHash)
+	if errors.Is(rootErr, errNoStateRoot) {
+		logrus.Warnf("skipping verification of %d storage diffs at block %d: %s", len(diffs), blockNumber, rootErr.Error())
+		result.Skipped += len(diffs)
+		return nil
+	}
+	if rootErr != nil {
+		return rootErr
+	}
+
+	for addressStart := 0; addressStart < len(diffs); {
+		addressEnd := addressStart
+		var keys []common.Hash
+		for addressEnd < len(diffs) && diffs[addressEnd].Address == diffs[addressStart].Address {
+			keys = append(keys, diffs[addressEnd].StorageKey)
+			addressEnd++
+		}
+		addressDiffs := diffs[addressStart:addressEnd]
+		addressStart = addressEnd
+
+		address := addressDiffs[0].Address
+		accountProof, proofErr := verifier.BlockChain.GetProof(address, keys, big.NewInt(blockNumber))
+		if proofErr != nil {
+			return fmt.Errorf("error getting proof of storage for %s at block %d: %w", address.Hex(), blockNumber, proofErr)
+		}
+		values, verifyErr := VerifyStorageValues(stateRoot, accountProof)
+		if verifyErr != nil {
+			logrus.Warnf("skipping verification of %d storage diffs for %s at block %d: %s", len(addressDiffs),
+				address.Hex(), blockNumber, verifyErr.Error())
+			result.Skipped += len(addressDiffs)
+			continue
+		}
+
+		for _, diff := range addressDiffs {
+			provenValue, ok := values[diff.StorageKey]
+			if !ok {
+				logrus.Warnf("skipping verification of storage diff %d: no proof of key %s", diff.ID, diff.StorageKey.Hex())
+				result.Skipped++
+				continue
+			}
+			if provenValue == diff.StorageValue {
+				result.Verified++
+				continue
+			}
+			result.Mismatches = append(result.Mismatches, Mismatch{Diff: diff, ProvenValue: provenValue})
+			if options.FlagMismatches {
+				flagErr := verifier.Repository.FlagMismatch(diff.ID)
+				if flagErr != nil {
+					return flagErr
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// getStateRoot returns the state root of the stored header for a block, if that header is the one the diffs came from
+func (verifier Verifier) getStateRoot(blockNumber int64, blockHash common.Hash) (common.Hash, error) {
+	header, headerErr := verifier.HeaderRepository.GetHeaderByBlockNumber(blockNumber)
+	if headerErr != nil {
+		if errors.Is(headerErr, sql.ErrNoRows) {
+			return common.Hash{}, fmt.Errorf("%w: no stored header", errNoStateRoot)
+		}
+		return common.Hash{}, fmt.Errorf("error getting header for block %d: %w", blockNumber, headerErr)
+	}
+	if common.HexToHash(header.Hash) != blockHash {
+		return common.Hash{}, fmt.Errorf("%w: diffs are from block %s but the stored header is %s", errNoStateRoot,
+			blockHash.Hex(), header.Hash)
+	}
+	var raw struct {
+		Root common.Hash `json:"stateRoot"`
+	}
+	decodeErr := json.Unmarshal(header.Raw, &raw)
+	if decodeErr != nil {
+		return common.Hash{}, fmt.Errorf("%w: decoding stored header: %v", errNoStateRoot, decodeErr)
+	}
+	if raw.Root == (common.Hash{}) {
+		return common.Hash{}, fmt.Errorf("%w: stored header has none", errNoStateRoot)
+	}
+	return raw.Root, nil
+}
@@ -0,0 +1,182 @@
+package proof_test
+
+import (
+	"database/sql"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/makerdao/vulcanizedb/libraries/shared/mocks"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/proof"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
+	"github.com/makerdao/vulcanizedb/libraries/shared/test_data"
+	"github.com/makerdao/vulcanizedb/pkg/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Storage proof verifier", func() {
+	var (
+		address     = test_data.FakeAddress()
+		key         = test_data.FakeHash()
+		value       = common.HexToHash("0x01")
+		blockHash   = test_data.FakeHash()
+		blockNumber = 100
+		blockChain  *fakes.MockBlockChain
+		headerRepo  *fakes.MockHeaderRepository
+		repo        *mocks.MockProofRepository
+		verifier    proof.Verifier
+		matching    types.PersistedDiff
+	)
+
+	BeforeEach(func() {
+		stateDB, stateRoot := newState(address, key, value)
+		blockChain = fakes.NewMockBlockChain()
+		blockChain.SetProofToReturn(int64(blockNumber), address, getProof(stateDB, address, key))
+		headerRepo = fakes.NewMockHeaderRepository()
+		headerRepo.GetHeaderByBlockNumberReturnHash = blockHash.Hex()
+		headerRepo.GetHeaderByBlockNumberReturnRaw = []byte(fmt.Sprintf(`{"stateRoot":"%s"}`, stateRoot.Hex()))
+		repo = &mocks.MockProofRepository{}
+		verifier = proof.Verifier{
+			BlockChain:       blockChain,
+			HeaderRepository: headerRepo,
+			Repository:       repo,
+			BlocksPerQuery:   proof.DefaultBlocksPerQuery,
+		}
+		matching = newDiff(1, address, blockNumber, blockHash, key, value)
+	})
+
+	It("counts diffs that match the proven value", func() {
+		repo.Diffs = []types.PersistedDiff{matching}
+
+		result, err := verifier.Verify(0, 200, proof.Options{})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Verified).To(Equal(1))
+		Expect(result.Mismatches).To(BeEmpty())
+		Expect(blockChain.GetProofCalls).To(ConsistOf(fakes.BatchGetStorageAtCall{
+			Account:     address,
+			Keys:        []common.Hash{key},
+			BlockNumber: big.NewInt(int64(blockNumber)),
+		}))
+	})
+
+	It("reports diffs that don't match the proven value", func() {
+		mismatched := newDiff(2, address, blockNumber, blockHash, key, common.HexToHash("0x02"))
+		repo.Diffs = []types.PersistedDiff{mismatched}
+
+		result, err := verifier.Verify(0, 200, proof.Options{})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Verified).To(BeZero())
+		Expect(result.Mismatches).To(Equal([]proof.Mismatch{{Diff: mismatched, ProvenValue: value}}))
+		Expect(repo.FlaggedIDs).To(BeEmpty())
+	})
+
+	It("optionally flags diffs that don't match the proven value", func() {
+		mismatched := newDiff(2, address, blockNumber, blockHash, key, common.HexToHash("0x02"))
+		repo.Diffs = []types.PersistedDiff{matching, mismatched}
+
+		_, err := verifier.Verify(0, 200, proof.Options{FlagMismatches: true})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(repo.FlaggedIDs).To(Equal([]int64{mismatched.ID}))
+	})
+
+	It("skips diffs from a block other than the stored header", func() {
+		repo.Diffs = []types.PersistedDiff{newDiff(1, address, blockNumber, test_data.FakeHash(), key, value)}
+
+		result, err := verifier.Verify(0, 200, proof.Options{})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Skipped).To(Equal(1))
+		Expect(blockChain.GetProofCalls).To(BeEmpty())
+	})
+
+	It("only proves diffs at a height against the header they came from", func() {
+		orphaned := newDiff(2, address, blockNumber, test_data.FakeHash(), key, common.HexToHash("0x02"))
+		repo.Diffs = []types.PersistedDiff{orphaned, matching}
+
+		result, err := verifier.Verify(0, 200, proof.Options{})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Skipped).To(Equal(1))
+		Expect(result.Verified).To(Equal(1))
+		Expect(result.Mismatches).To(BeEmpty())
+	})
+
+	It("skips diffs without a stored header", func() {
+		headerRepo.GetHeaderByBlockNumberError = sql.ErrNoRows
+		repo.Diffs = []types.PersistedDiff{matching}
+
+		result, err := verifier.Verify(0, 200, proof.Options{})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Skipped).To(Equal(1))
+	})
+
+	It("skips diffs whose proof doesn't verify against the stored state root", func() {
+		headerRepo.GetHeaderByBlockNumberReturnRaw = []byte(fmt.Sprintf(`{"stateRoot":"%s"}`, test_data.FakeHash().Hex()))
+		repo.Diffs = []types.PersistedDiff{matching}
+
+		result, err := verifier.Verify(0, 200, proof.Options{})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Skipped).To(Equal(1))
+		Expect(result.Verified).To(BeZero())
+	})
+
+	It("returns an error if getting a proof fails", func() {
+		blockChain.GetProofError = fakes.FakeError
+		repo.Diffs = []types.PersistedDiff{matching}
+
+		_, err := verifier.Verify(0, 200, proof.Options{})
+
+		Expect(err).To(MatchError(fakes.FakeError))
+	})
+
+	It("returns an error if getting diffs fails", func() {
+		repo.GetDiffsToVerifyErr = fakes.FakeError
+
+		_, err := verifier.Verify(0, 200, proof.Options{})
+
+		Expect(err).To(MatchError(fakes.FakeError))
+	})
+
+	It("checks every diff in windows of blocks", func() {
+		verifier.BlocksPerQuery = 100
+
+		_, err := verifier.Verify(0, 250, proof.Options{})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(repo.GetDiffsToVerifyCalls).To(Equal([]mocks.GetDiffsToVerifyCall{
+			{StartingBlock: 0, EndingBlock: 99},
+			{StartingBlock: 100, EndingBlock: 199},
+			{StartingBlock: 200, EndingBlock: 250},
+		}))
+	})
+
+	It("samples diffs across the whole range", func() {
+		verifier.BlocksPerQuery = 100
+
+		_, err := verifier.Verify(0, 250, proof.Options{SampleSize: 10})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(repo.GetDiffsToVerifyCalls).To(Equal([]mocks.GetDiffsToVerifyCall{
+			{StartingBlock: 0, EndingBlock: 250, SampleSize: 10},
+		}))
+	})
+})
+
+func newDiff(id int64, address common.Address, blockNumber int, blockHash, key, value common.Hash) types.PersistedDiff {
+	return types.PersistedDiff{
+		ID: id,
+		RawDiff: types.RawDiff{
+			Address:      address,
+			BlockHash:    blockHash,
+			BlockHeight:  blockNumber,
+			StorageKey:   key,
+			StorageValue: value,
+		},
+	}
+}
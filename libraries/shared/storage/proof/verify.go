@@ -0,0 +1,89 @@
+package proof
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/makerdao/vulcanizedb/pkg/core"
+)
+
+var ErrInvalidProof = errors.New("invalid Merkle proof")
+
+// stateAccount is an account as it's encoded in the state trie
+type stateAccount struct {
+	Nonce    uint64
+	Balance  *big.Int
+	Root     common.Hash
+	CodeHash []byte
+}
+
+// VerifyStorageValues checks an eth_getProof response against a state root and returns the proven value of each
+// requested storage key. Values come from the proof rather than the response's value fields, so a node can't report a
+// value that isn't in the state it proved.
+func VerifyStorageValues(stateRoot common.Hash, accountProof core.AccountProof) (map[common.Hash]common.Hash, error) {
+	accountValue, accountErr := verifyProof(stateRoot, accountProof.Address.Bytes(), accountProof.AccountProof)
+	if accountErr != nil {
+		return nil, fmt.Errorf("%w for account %s: %v", ErrInvalidProof, accountProof.Address.Hex(), accountErr)
+	}
+
+	values := make(map[common.Hash]common.Hash)
+	if accountValue == nil {
+		// the proof shows the account doesn't exist, so none of its storage does either
+		for _, storageProof := range accountProof.StorageProof {
+			values[common.HexToHash(storageProof.Key)] = common.Hash{}
+		}
+		return values, nil
+	}
+
+	var account stateAccount
+	decodeErr := rlp.DecodeBytes(accountValue, &account)
+	if decodeErr != nil {
+		return nil, fmt.Errorf("%w: decoding account %s: %v", ErrInvalidProof, accountProof.Address.Hex(), decodeErr)
+	}
+
+	for _, storageProof := range accountProof.StorageProof {
+		key := common.HexToHash(storageProof.Key)
+		if account.Root == types.EmptyRootHash {
+			values[key] = common.Hash{}
+			continue
+		}
+		value, storageErr := verifyProof(account.Root, key.Bytes(), storageProof.Proof)
+		if storageErr != nil {
+			return nil, fmt.Errorf("%w for storage key %s of account %s: %v", ErrInvalidProof, key.Hex(),
+				accountProof.Address.Hex(), storageErr)
+		}
+		if value == nil {
+			values[key] = common.Hash{}
+			continue
+		}
+		// storage values are RLP encoded in the trie, with leading zeroes trimmed
+		_, content, _, splitErr := rlp.Split(value)
+		if splitErr != nil {
+			return nil, fmt.Errorf("%w: decoding storage key %s of account %s: %v", ErrInvalidProof, key.Hex(),
+				accountProof.Address.Hex(), splitErr)
+		}
+		values[key] = common.BytesToHash(content)
+	}
+	return values, nil
+}
+
+// verifyProof returns the value at the hashed key in the trie with the given root, or nil if the proof shows that the
+// key is absent
+func verifyProof(root common.Hash, key []byte, nodes []hexutil.Bytes) ([]byte, error) {
+	proofDB := memorydb.New()
+	for _, node := range nodes {
+		putErr := proofDB.Put(crypto.Keccak256(node), node)
+		if putErr != nil {
+			return nil, putErr
+		}
+	}
+	return trie.VerifyProof(root, crypto.Keccak256(key), proofDB)
+}
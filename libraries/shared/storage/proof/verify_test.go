@@ -0,0 +1,119 @@
+package proof_test
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/proof"
+	"github.com/makerdao/vulcanizedb/libraries/shared/test_data"
+	"github.com/makerdao/vulcanizedb/pkg/core"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Verifying storage proofs", func() {
+	var (
+		address        = test_data.FakeAddress()
+		key            = test_data.FakeHash()
+		unsetKey       = test_data.FakeHash()
+		value          = common.HexToHash("0x01")
+		stateDB        *state.StateDB
+		stateRoot      common.Hash
+		missingAddress = test_data.FakeAddress()
+	)
+
+	BeforeEach(func() {
+		stateDB, stateRoot = newState(address, key, value)
+	})
+
+	It("returns the proven value of each key", func() {
+		accountProof := getProof(stateDB, address, key, unsetKey)
+
+		values, err := proof.VerifyStorageValues(stateRoot, accountProof)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(values).To(Equal(map[common.Hash]common.Hash{key: value, unsetKey: {}}))
+	})
+
+	It("uses the value in the proof rather than the reported value", func() {
+		accountProof := getProof(stateDB, address, key)
+		accountProof.StorageProof[0].Value = (*hexutil.Big)(big.NewInt(2))
+
+		values, err := proof.VerifyStorageValues(stateRoot, accountProof)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(values[key]).To(Equal(value))
+	})
+
+	It("returns empty values for an account that doesn't exist", func() {
+		accountProof := getProof(stateDB, missingAddress, key)
+
+		values, err := proof.VerifyStorageValues(stateRoot, accountProof)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(values).To(Equal(map[common.Hash]common.Hash{key: {}}))
+	})
+
+	It("returns an error if the account proof isn't for the state root", func() {
+		accountProof := getProof(stateDB, address, key)
+
+		_, err := proof.VerifyStorageValues(test_data.FakeHash(), accountProof)
+
+		Expect(err).To(MatchError(proof.ErrInvalidProof))
+	})
+
+	It("returns an error if a storage proof is incomplete", func() {
+		accountProof := getProof(stateDB, address, key)
+		accountProof.StorageProof[0].Proof = accountProof.StorageProof[0].Proof[1:]
+
+		_, err := proof.VerifyStorageValues(stateRoot, accountProof)
+
+		Expect(err).To(MatchError(proof.ErrInvalidProof))
+	})
+})
+
+func newState(address common.Address, key, value common.Hash) (*state.StateDB, common.Hash) {
+	stateDB, stateErr := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	Expect(stateErr).NotTo(HaveOccurred())
+	stateDB.SetNonce(address, 1)
+	stateDB.SetState(address, key, value)
+	stateDB.SetState(address, test_data.FakeHash(), common.HexToHash("0x02"))
+	stateDB.SetNonce(test_data.FakeAddress(), 1)
+	root, _, commitErr := stateDB.Commit(false)
+	Expect(commitErr).NotTo(HaveOccurred())
+	return stateDB, root
+}
+
+// getProof builds an eth_getProof response the way geth does
+func getProof(stateDB *state.StateDB, address common.Address, keys ...common.Hash) core.AccountProof {
+	accountNodes, accountErr := stateDB.GetProof(address)
+	Expect(accountErr).NotTo(HaveOccurred())
+	accountProof := core.AccountProof{
+		Address:      address,
+		AccountProof: toHexBytes(accountNodes),
+	}
+	for _, key := range keys {
+		storageProof := core.StorageProof{
+			Key:   key.Hex(),
+			Value: (*hexutil.Big)(stateDB.GetState(address, key).Big()),
+		}
+		if stateDB.Exist(address) {
+			storageNodes, storageErr := stateDB.GetStorageProof(address, key)
+			Expect(storageErr).NotTo(HaveOccurred())
+			storageProof.Proof = toHexBytes(storageNodes)
+		}
+		accountProof.StorageProof = append(accountProof.StorageProof, storageProof)
+	}
+	return accountProof
+}
+
+func toHexBytes(nodes [][]byte) []hexutil.Bytes {
+	var result []hexutil.Bytes
+	for _, node := range nodes {
+		result = append(result, node)
+	}
+	return result
+}
@@ -0,0 +1,76 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/makerdao/vulcanizedb/pkg/datastore"
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres"
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres/repositories"
+	"github.com/sirupsen/logrus"
+)
+
+var DefaultSnapshotInterval = int64(10000)
+
+// Snapshotter takes snapshots of contract storage every Interval blocks, so that reading the storage of a contract at a
+// recent block only applies the diffs since the last snapshot
+type Snapshotter struct {
+	HeaderRepository datastore.HeaderRepository
+	StateRepository  StateRepository
+	Interval         int64
+}
+
+func NewSnapshotter(db *postgres.DB, interval int64) Snapshotter {
+	if interval < 1 {
+		interval = DefaultSnapshotInterval
+	}
+	return Snapshotter{
+		HeaderRepository: repositories.NewHeaderRepository(db),
+		StateRepository:  NewStateRepository(db),
+		Interval:         interval,
+	}
+}
+
+// SnapshotLatest snapshots each contract at the latest synced block that's a multiple of the interval, unless it
+// already has a snapshot there or later. It returns the number of snapshots taken.
+func (snapshotter Snapshotter) SnapshotLatest(addresses []common.Address) (int, error) {
+	latestBlock, headerErr := snapshotter.HeaderRepository.GetMostRecentHeaderBlockNumber()
+	if headerErr != nil {
+		return 0, fmt.Errorf("error getting latest header for storage snapshots: %w", headerErr)
+	}
+	snapshotBlock := latestBlock - latestBlock%snapshotter.Interval
+
+	var created int
+	for _, address := range addresses {
+		latestSnapshotBlock, snapshotErr := snapshotter.StateRepository.GetLatestSnapshotBlock(address)
+		if snapshotErr != nil {
+			return created, snapshotErr
+		}
+		if latestSnapshotBlock >= snapshotBlock {
+			continue
+		}
+		createErr := snapshotter.StateRepository.CreateSnapshot(address, snapshotBlock)
+		if createErr != nil {
+			return created, createErr
+		}
+		logrus.Infof("created storage snapshot of %s at block %d", address.Hex(), snapshotBlock)
+		created++
+	}
+	return created, nil
+}
@@ -0,0 +1,88 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package storage_test
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/makerdao/vulcanizedb/libraries/shared/mocks"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage"
+	"github.com/makerdao/vulcanizedb/libraries/shared/test_data"
+	"github.com/makerdao/vulcanizedb/pkg/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Storage snapshotter", func() {
+	var (
+		address          = test_data.FakeAddress()
+		otherAddress     = test_data.FakeAddress()
+		headerRepository *fakes.MockHeaderRepository
+		stateRepository  *mocks.MockStateRepository
+		snapshotter      storage.Snapshotter
+	)
+
+	BeforeEach(func() {
+		headerRepository = fakes.NewMockHeaderRepository()
+		headerRepository.MostRecentHeaderBlockNumber = 2345
+		stateRepository = &mocks.MockStateRepository{}
+		snapshotter = storage.Snapshotter{
+			HeaderRepository: headerRepository,
+			StateRepository:  stateRepository,
+			Interval:         1000,
+		}
+	})
+
+	It("snapshots each contract at the latest multiple of the interval", func() {
+		created, err := snapshotter.SnapshotLatest([]common.Address{address, otherAddress})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(created).To(Equal(2))
+		Expect(stateRepository.CreateSnapshotCalls).To(Equal([]mocks.CreateSnapshotCall{
+			{Address: address, BlockHeight: 2000},
+			{Address: otherAddress, BlockHeight: 2000},
+		}))
+	})
+
+	It("skips contracts that already have a snapshot at or after that block", func() {
+		stateRepository.LatestSnapshotBlocks = map[common.Address]int64{address: 2000, otherAddress: 1000}
+
+		created, err := snapshotter.SnapshotLatest([]common.Address{address, otherAddress})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(created).To(Equal(1))
+		Expect(stateRepository.CreateSnapshotCalls).To(Equal([]mocks.CreateSnapshotCall{
+			{Address: otherAddress, BlockHeight: 2000},
+		}))
+	})
+
+	It("returns an error if getting the latest header fails", func() {
+		headerRepository.MostRecentHeaderBlockNumberErr = fakes.FakeError
+
+		_, err := snapshotter.SnapshotLatest([]common.Address{address})
+
+		Expect(err).To(MatchError(fakes.FakeError))
+	})
+
+	It("returns an error if creating a snapshot fails", func() {
+		stateRepository.CreateSnapshotErr = fakes.FakeError
+
+		created, err := snapshotter.SnapshotLatest([]common.Address{address})
+
+		Expect(err).To(MatchError(fakes.FakeError))
+		Expect(created).To(BeZero())
+	})
+})
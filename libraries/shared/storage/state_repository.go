@@ -0,0 +1,96 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres"
+)
+
+// StateRepository reads the storage of contracts at past blocks from canonical storage diffs, including back-filled
+// ones, and keeps snapshots of it so that reads for recent blocks don't scan every diff
+type StateRepository interface {
+	GetStorageAt(address common.Address, key common.Hash, blockHeight int64) (common.Hash, error)
+	GetContractStorageAt(address common.Address, blockHeight int64) (map[common.Hash]common.Hash, error)
+	CreateSnapshot(address common.Address, blockHeight int64) error
+	GetLatestSnapshotBlock(address common.Address) (int64, error)
+}
+
+type stateRepository struct {
+	db *postgres.DB
+}
+
+func NewStateRepository(db *postgres.DB) stateRepository {
+	return stateRepository{db: db}
+}
+
+// GetStorageAt returns the value of a storage slot at the end of a block, which is empty if it was never written
+func (repository stateRepository) GetStorageAt(address common.Address, key common.Hash, blockHeight int64) (common.Hash, error) {
+	var value []byte
+	err := repository.db.Get(&value, `SELECT public.get_storage_at($1, $2, $3)`, address.Bytes(), key.Bytes(), blockHeight)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("error getting storage key %s of %s at block %d: %w", key.Hex(), address.Hex(),
+			blockHeight, err)
+	}
+	return common.BytesToHash(value), nil
+}
+
+// GetContractStorageAt returns every storage slot of a contract that has a diff at or before the block, with its value
+// at the end of the block
+func (repository stateRepository) GetContractStorageAt(address common.Address, blockHeight int64) (map[common.Hash]common.Hash, error) {
+	var rows []struct {
+		StorageKey   []byte `db:"storage_key"`
+		StorageValue []byte `db:"storage_value"`
+	}
+	err := repository.db.Select(&rows, `SELECT storage_key, storage_value FROM public.get_contract_storage_at($1, $2)`,
+		address.Bytes(), blockHeight)
+	if err != nil {
+		return nil, fmt.Errorf("error getting storage of %s at block %d: %w", address.Hex(), blockHeight, err)
+	}
+	storage := make(map[common.Hash]common.Hash, len(rows))
+	for _, row := range rows {
+		storage[common.BytesToHash(row.StorageKey)] = common.BytesToHash(row.StorageValue)
+	}
+	return storage, nil
+}
+
+// CreateSnapshot saves the storage of a contract at a block, replacing any existing snapshot at that block. Snapshots
+// are dropped when a diff is inserted or marked noncanonical at or before their block.
+func (repository stateRepository) CreateSnapshot(address common.Address, blockHeight int64) error {
+	_, err := repository.db.Exec(`SELECT public.create_storage_snapshot($1, $2)`, address.Bytes(), blockHeight)
+	if err != nil {
+		return fmt.Errorf("error creating storage snapshot of %s at block %d: %w", address.Hex(), blockHeight, err)
+	}
+	return nil
+}
+
+// GetLatestSnapshotBlock returns the block of the latest snapshot of a contract, or -1 if there isn't one
+func (repository stateRepository) GetLatestSnapshotBlock(address common.Address) (int64, error) {
+	var blockHeight sql.NullInt64
+	err := repository.db.Get(&blockHeight, `SELECT MAX(block_height) FROM public.storage_snapshot WHERE address = $1`,
+		address.Bytes())
+	if err != nil {
+		return 0, fmt.Errorf("error getting latest storage snapshot of %s: %w", address.Hex(), err)
+	}
+	if !blockHeight.Valid {
+		return -1, nil
+	}
+	return blockHeight.Int64, nil
+}
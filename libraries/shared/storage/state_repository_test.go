@@ -0,0 +1,230 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package storage_test
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
+	"github.com/makerdao/vulcanizedb/libraries/shared/test_data"
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres/repositories"
+	"github.com/makerdao/vulcanizedb/pkg/fakes"
+	"github.com/makerdao/vulcanizedb/test_config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Storage state repository", func() {
+	var (
+		db         = test_config.NewTestDB(test_config.NewTestNode())
+		diffRepo   storage.DiffRepository
+		repo       storage.StateRepository
+		headerRepo = repositories.NewHeaderRepository(db)
+		address    = test_data.FakeAddress()
+		key        = test_data.FakeHash()
+		otherKey   = test_data.FakeHash()
+		blockHash  = test_data.FakeHash()
+	)
+
+	BeforeEach(func() {
+		test_config.CleanTestDB(db)
+		diffRepo = storage.NewDiffRepository(db)
+		repo = storage.NewStateRepository(db)
+	})
+
+	createHeader := func(blockHeight int) {
+		header := fakes.GetFakeHeader(int64(blockHeight))
+		header.Hash = blockHash.Hex()
+		_, err := headerRepo.CreateOrUpdateHeader(header)
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	newDiff := func(blockHeight int, storageKey, value common.Hash) types.RawDiff {
+		return types.RawDiff{
+			Address:      address,
+			BlockHash:    blockHash,
+			BlockHeight:  blockHeight,
+			StorageKey:   storageKey,
+			StorageValue: value,
+		}
+	}
+
+	createDiff := func(blockHeight int, storageKey, value common.Hash) int64 {
+		createHeader(blockHeight)
+		id, err := diffRepo.CreateStorageDiff(newDiff(blockHeight, storageKey, value))
+		Expect(err).NotTo(HaveOccurred())
+		return id
+	}
+
+	createOrphanedDiff := func(blockHeight int, storageKey, value common.Hash) int64 {
+		createHeader(blockHeight)
+		orphaned := newDiff(blockHeight, storageKey, value)
+		orphaned.BlockHash = test_data.FakeHash()
+		id, err := diffRepo.CreateStorageDiff(orphaned)
+		Expect(err).NotTo(HaveOccurred())
+		return id
+	}
+
+	Describe("GetStorageAt", func() {
+		It("returns the latest value at or before the block", func() {
+			createDiff(1, key, common.HexToHash("0x01"))
+			createDiff(3, key, common.HexToHash("0x03"))
+
+			value, err := repo.GetStorageAt(address, key, 2)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(value).To(Equal(common.HexToHash("0x01")))
+		})
+
+		It("returns an empty value if the slot wasn't written", func() {
+			createDiff(3, key, common.HexToHash("0x03"))
+
+			value, err := repo.GetStorageAt(address, key, 2)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(value).To(Equal(common.Hash{}))
+		})
+
+		It("ignores noncanonical diffs", func() {
+			createDiff(1, key, common.HexToHash("0x01"))
+			noncanonicalID := createDiff(2, key, common.HexToHash("0x02"))
+			Expect(diffRepo.MarkNoncanonical(noncanonicalID)).To(Succeed())
+
+			value, err := repo.GetStorageAt(address, key, 2)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(value).To(Equal(common.HexToHash("0x01")))
+		})
+
+		It("ignores diffs from blocks other than the stored header at their height", func() {
+			createDiff(1, key, common.HexToHash("0x01"))
+			createOrphanedDiff(2, key, common.HexToHash("0x02"))
+
+			value, err := repo.GetStorageAt(address, key, 2)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(value).To(Equal(common.HexToHash("0x01")))
+		})
+	})
+
+	Describe("GetContractStorageAt", func() {
+		It("returns the latest value of every slot at or before the block", func() {
+			createDiff(1, key, common.HexToHash("0x01"))
+			createDiff(2, key, common.HexToHash("0x02"))
+			createDiff(2, otherKey, common.HexToHash("0x04"))
+			createDiff(3, key, common.HexToHash("0x03"))
+
+			storageAt, err := repo.GetContractStorageAt(address, 2)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(storageAt).To(Equal(map[common.Hash]common.Hash{
+				key:      common.HexToHash("0x02"),
+				otherKey: common.HexToHash("0x04"),
+			}))
+		})
+
+		It("ignores diffs from blocks other than the stored header at their height", func() {
+			createDiff(1, key, common.HexToHash("0x01"))
+			createOrphanedDiff(2, key, common.HexToHash("0x02"))
+			createOrphanedDiff(2, otherKey, common.HexToHash("0x04"))
+
+			storageAt, err := repo.GetContractStorageAt(address, 2)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(storageAt).To(Equal(map[common.Hash]common.Hash{key: common.HexToHash("0x01")}))
+		})
+
+		It("combines the latest snapshot with later diffs", func() {
+			createDiff(1, key, common.HexToHash("0x01"))
+			createDiff(1, otherKey, common.HexToHash("0x04"))
+			Expect(repo.CreateSnapshot(address, 2)).To(Succeed())
+			createDiff(3, key, common.HexToHash("0x03"))
+
+			storageAt, err := repo.GetContractStorageAt(address, 3)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(storageAt).To(Equal(map[common.Hash]common.Hash{
+				key:      common.HexToHash("0x03"),
+				otherKey: common.HexToHash("0x04"),
+			}))
+		})
+	})
+
+	Describe("snapshots", func() {
+		It("records the block of the latest snapshot", func() {
+			createDiff(1, key, common.HexToHash("0x01"))
+			Expect(repo.CreateSnapshot(address, 10)).To(Succeed())
+			Expect(repo.CreateSnapshot(address, 20)).To(Succeed())
+
+			blockHeight, err := repo.GetLatestSnapshotBlock(address)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(blockHeight).To(Equal(int64(20)))
+		})
+
+		It("returns -1 if the contract doesn't have a snapshot", func() {
+			blockHeight, err := repo.GetLatestSnapshotBlock(address)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(blockHeight).To(Equal(int64(-1)))
+		})
+
+		It("drops snapshots made stale by a back-filled diff", func() {
+			createDiff(1, key, common.HexToHash("0x01"))
+			Expect(repo.CreateSnapshot(address, 5)).To(Succeed())
+			Expect(repo.CreateSnapshot(address, 10)).To(Succeed())
+
+			createDiff(7, key, common.HexToHash("0x07"))
+
+			blockHeight, err := repo.GetLatestSnapshotBlock(address)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(blockHeight).To(Equal(int64(5)))
+			storageAt, storageErr := repo.GetContractStorageAt(address, 10)
+			Expect(storageErr).NotTo(HaveOccurred())
+			Expect(storageAt[key]).To(Equal(common.HexToHash("0x07")))
+		})
+
+		It("drops snapshots from the lowest block of a multi-row insert", func() {
+			createDiff(1, key, common.HexToHash("0x01"))
+			Expect(repo.CreateSnapshot(address, 5)).To(Succeed())
+			Expect(repo.CreateSnapshot(address, 10)).To(Succeed())
+			createHeader(8)
+			createHeader(4)
+
+			_, err := diffRepo.CreateStorageDiffs([]types.RawDiff{
+				newDiff(8, key, common.HexToHash("0x08")),
+				newDiff(4, otherKey, common.HexToHash("0x04")),
+			})
+
+			Expect(err).NotTo(HaveOccurred())
+			blockHeight, snapshotErr := repo.GetLatestSnapshotBlock(address)
+			Expect(snapshotErr).NotTo(HaveOccurred())
+			Expect(blockHeight).To(Equal(int64(-1)))
+		})
+
+		It("drops snapshots made stale by a noncanonical diff", func() {
+			noncanonicalID := createDiff(1, key, common.HexToHash("0x01"))
+			Expect(repo.CreateSnapshot(address, 10)).To(Succeed())
+
+			Expect(diffRepo.MarkNoncanonical(noncanonicalID)).To(Succeed())
+
+			blockHeight, err := repo.GetLatestSnapshotBlock(address)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(blockHeight).To(Equal(int64(-1)))
+		})
+	})
+})
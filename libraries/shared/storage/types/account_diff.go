@@ -0,0 +1,67 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// RawAccountDiff is an account's balance, nonce, code hash and storage root after a block that changed it
+type RawAccountDiff struct {
+	Address     common.Address
+	BlockHash   common.Hash
+	BlockHeight int
+	Balance     *big.Int
+	Nonce       uint64
+	CodeHash    common.Hash
+	StorageRoot common.Hash
+}
+
+type PersistedAccountDiff struct {
+	RawAccountDiff
+	ID        int64
+	HeaderID  int64
+	Status    string
+	EthNodeID int64
+}
+
+func FromGethAccountDiff(account filters.AccountDiff, stateDiff *filters.StateDiff) (RawAccountDiff, error) {
+	var decodedAccount state.Account
+	err := rlp.DecodeBytes(account.Value, &decodedAccount)
+	if err != nil {
+		return RawAccountDiff{}, err
+	}
+
+	balance := decodedAccount.Balance
+	if balance == nil {
+		balance = big.NewInt(0)
+	}
+	return RawAccountDiff{
+		Address:     common.BytesToAddress(account.Key),
+		BlockHash:   stateDiff.BlockHash,
+		BlockHeight: int(stateDiff.BlockNumber.Int64()),
+		Balance:     balance,
+		Nonce:       decodedAccount.Nonce,
+		CodeHash:    common.BytesToHash(decodedAccount.CodeHash),
+		StorageRoot: decodedAccount.Root,
+	}, nil
+}
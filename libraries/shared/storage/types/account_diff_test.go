@@ -0,0 +1,77 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package types_test
+
+import (
+	"math/big"
+	"math/rand"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
+	"github.com/makerdao/vulcanizedb/libraries/shared/test_data"
+	"github.com/makerdao/vulcanizedb/pkg/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Account diff parsing", func() {
+	Describe("FromGethAccountDiff", func() {
+		var (
+			address   = test_data.FakeAddress()
+			stateDiff = &filters.StateDiff{
+				BlockNumber: big.NewInt(rand.Int63()),
+				BlockHash:   fakes.FakeHash,
+			}
+		)
+
+		It("decodes the account's balance, nonce, code hash and storage root", func() {
+			account := state.Account{
+				Nonce:    rand.Uint64(),
+				Balance:  big.NewInt(rand.Int63()),
+				Root:     test_data.FakeHash(),
+				CodeHash: test_data.FakeHash().Bytes(),
+			}
+			accountRlp, encodeErr := rlp.EncodeToBytes(account)
+			Expect(encodeErr).NotTo(HaveOccurred())
+			accountDiff := filters.AccountDiff{Key: address.Bytes(), Value: accountRlp}
+
+			result, err := types.FromGethAccountDiff(accountDiff, stateDiff)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(types.RawAccountDiff{
+				Address:     address,
+				BlockHash:   stateDiff.BlockHash,
+				BlockHeight: int(stateDiff.BlockNumber.Int64()),
+				Balance:     account.Balance,
+				Nonce:       account.Nonce,
+				CodeHash:    common.BytesToHash(account.CodeHash),
+				StorageRoot: account.Root,
+			}))
+		})
+
+		It("returns an error if decoding the account RLP fails", func() {
+			accountDiff := filters.AccountDiff{Key: address.Bytes(), Value: []byte{1, 2, 3}}
+
+			_, err := types.FromGethAccountDiff(accountDiff, stateDiff)
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -17,6 +17,7 @@
 package types
 
 import (
+	"database/sql"
 	"strconv"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -37,11 +38,14 @@ type RawDiff struct {
 
 type PersistedDiff struct {
 	RawDiff
-	Status       string
-	FromBackfill bool `db:"from_backfill"`
-	ID           int64
-	HeaderID     int64 `db:"header_id"`
-	EthNodeID    int64 `db:"eth_node_id"`
+	Status        string
+	FromBackfill  bool `db:"from_backfill"`
+	ID            int64
+	HeaderID      int64        `db:"header_id"`
+	EthNodeID     int64        `db:"eth_node_id"`
+	RetryCount    int          `db:"retry_count"`
+	NextAttemptAt sql.NullTime `db:"next_attempt_at"`
+	ProofMismatch bool         `db:"proof_mismatch"`
 }
 
 func FromParityCsvRow(csvRow []string) (RawDiff, error) {
@@ -0,0 +1,215 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	ParityCsvFormat = "parity-csv"
+	CsvFormat       = "csv"
+	JsonLinesFormat = "jsonl"
+
+	AddressField      = "address"
+	BlockHashField    = "block_hash"
+	BlockHeightField  = "block_height"
+	StorageKeyField   = "storage_key"
+	StorageValueField = "storage_value"
+)
+
+var diffFields = []string{AddressField, BlockHashField, BlockHeightField, StorageKeyField, StorageValueField}
+
+// DiffFormat parses the lines of a storage diff file. Formats may keep per-file state (such as a header row), so a
+// DiffFormat should only be used for one file at a time.
+type DiffFormat interface {
+	// ParseLine parses the line numbered lineNumber (counting from 1). ok is false for lines that don't contain a
+	// diff, such as a header row.
+	ParseLine(lineNumber int64, line string) (diff RawDiff, ok bool, err error)
+}
+
+// HeaderedDiffFormat is a DiffFormat that reads column positions from the first line of a file
+type HeaderedDiffFormat interface {
+	DiffFormat
+	SetHeader(line string) error
+}
+
+// DiffFormatConfig maps the fields of a diff (address, block_hash, block_height, storage_key and storage_value) to
+// the column names or JSON keys of a file. Fields that aren't mapped are expected under their own name.
+type DiffFormatConfig struct {
+	Columns map[string]string
+}
+
+func (config DiffFormatConfig) column(field string) string {
+	if column, ok := config.Columns[field]; ok && column != "" {
+		return column
+	}
+	return field
+}
+
+type DiffFormatConstructor func(config DiffFormatConfig) DiffFormat
+
+var diffFormats = map[string]DiffFormatConstructor{
+	ParityCsvFormat: func(DiffFormatConfig) DiffFormat { return parityCsvDiffFormat{} },
+	CsvFormat:       func(config DiffFormatConfig) DiffFormat { return NewCsvDiffFormat(config) },
+	JsonLinesFormat: func(config DiffFormatConfig) DiffFormat { return NewJsonLinesDiffFormat(config) },
+}
+
+// RegisterDiffFormat makes a diff format available to NewDiffFormat under name, replacing any existing format
+func RegisterDiffFormat(name string, constructor DiffFormatConstructor) {
+	diffFormats[name] = constructor
+}
+
+func NewDiffFormat(name string, config DiffFormatConfig) (DiffFormat, error) {
+	constructor, ok := diffFormats[name]
+	if !ok {
+		return nil, ErrUnknownDiffFormat{Name: name}
+	}
+	return constructor(config), nil
+}
+
+// parityCsvDiffFormat is the headerless csv written by the patched parity client: address, block hash, block height,
+// storage key and storage value, in that order
+type parityCsvDiffFormat struct{}
+
+func (parityCsvDiffFormat) ParseLine(_ int64, line string) (RawDiff, bool, error) {
+	diff, err := FromParityCsvRow(strings.Split(line, ","))
+	return diff, err == nil, err
+}
+
+// CsvDiffFormat is csv with a header row naming its columns
+type CsvDiffFormat struct {
+	config  DiffFormatConfig
+	indexes map[string]int
+}
+
+func NewCsvDiffFormat(config DiffFormatConfig) *CsvDiffFormat {
+	return &CsvDiffFormat{config: config}
+}
+
+func (format *CsvDiffFormat) SetHeader(line string) error {
+	header, err := readCsvLine(line)
+	if err != nil {
+		return err
+	}
+	positions := make(map[string]int, len(header))
+	for i, column := range header {
+		positions[strings.TrimSpace(column)] = i
+	}
+	indexes := make(map[string]int, len(diffFields))
+	for _, field := range diffFields {
+		column := format.config.column(field)
+		index, ok := positions[column]
+		if !ok {
+			return ErrDiffFieldMissing{Field: column}
+		}
+		indexes[field] = index
+	}
+	format.indexes = indexes
+	return nil
+}
+
+func (format *CsvDiffFormat) ParseLine(lineNumber int64, line string) (RawDiff, bool, error) {
+	if lineNumber == 1 {
+		return RawDiff{}, false, format.SetHeader(line)
+	}
+	if format.indexes == nil {
+		return RawDiff{}, false, ErrDiffHeaderMissing
+	}
+	row, err := readCsvLine(line)
+	if err != nil {
+		return RawDiff{}, false, err
+	}
+	values := make(map[string]string, len(diffFields))
+	for field, index := range format.indexes {
+		if index >= len(row) {
+			return RawDiff{}, false, ErrDiffFieldMissing{Field: format.config.column(field)}
+		}
+		values[field] = strings.TrimSpace(row[index])
+	}
+	diff, err := fromFieldValues(values)
+	return diff, err == nil, err
+}
+
+// JsonLinesDiffFormat is one JSON object per line, with hex strings for hashes and a number or string for the block
+// height
+type JsonLinesDiffFormat struct {
+	config DiffFormatConfig
+}
+
+func NewJsonLinesDiffFormat(config DiffFormatConfig) JsonLinesDiffFormat {
+	return JsonLinesDiffFormat{config: config}
+}
+
+func (format JsonLinesDiffFormat) ParseLine(_ int64, line string) (RawDiff, bool, error) {
+	var object map[string]json.RawMessage
+	err := json.Unmarshal([]byte(line), &object)
+	if err != nil {
+		return RawDiff{}, false, fmt.Errorf("error decoding diff json: %w", err)
+	}
+	values := make(map[string]string, len(diffFields))
+	for _, field := range diffFields {
+		key := format.config.column(field)
+		raw, ok := object[key]
+		if !ok {
+			return RawDiff{}, false, ErrDiffFieldMissing{Field: key}
+		}
+		var value string
+		if unmarshalErr := json.Unmarshal(raw, &value); unmarshalErr != nil {
+			// block heights may be plain numbers
+			value = string(raw)
+		}
+		values[field] = value
+	}
+	diff, err := fromFieldValues(values)
+	return diff, err == nil, err
+}
+
+func fromFieldValues(values map[string]string) (RawDiff, error) {
+	height, err := parseBlockHeight(values[BlockHeightField])
+	if err != nil {
+		return RawDiff{}, err
+	}
+	return RawDiff{
+		Address:      common.HexToAddress(values[AddressField]),
+		BlockHash:    common.HexToHash(values[BlockHashField]),
+		BlockHeight:  height,
+		StorageKey:   common.HexToHash(values[StorageKeyField]),
+		StorageValue: common.HexToHash(values[StorageValueField]),
+	}, nil
+}
+
+// parseBlockHeight accepts decimal or 0x-prefixed hex
+func parseBlockHeight(value string) (int, error) {
+	if strings.HasPrefix(value, "0x") || strings.HasPrefix(value, "0X") {
+		height, err := strconv.ParseInt(value[2:], 16, 64)
+		return int(height), err
+	}
+	return strconv.Atoi(value)
+}
+
+func readCsvLine(line string) ([]string, error) {
+	reader := csv.NewReader(strings.NewReader(line))
+	reader.FieldsPerRecord = -1
+	return reader.Read()
+}
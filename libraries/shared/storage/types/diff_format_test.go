@@ -0,0 +1,159 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package types_test
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Storage diff formats", func() {
+	var expectedDiff = types.RawDiff{
+		Address:      common.HexToAddress("0x123"),
+		BlockHash:    common.HexToHash("0x456"),
+		BlockHeight:  789,
+		StorageKey:   common.HexToHash("0x987"),
+		StorageValue: common.HexToHash("0x654"),
+	}
+
+	It("returns an error for an unknown format", func() {
+		_, err := types.NewDiffFormat("xml", types.DiffFormatConfig{})
+
+		Expect(err).To(MatchError(types.ErrUnknownDiffFormat{Name: "xml"}))
+	})
+
+	It("uses registered formats", func() {
+		types.RegisterDiffFormat("test", func(types.DiffFormatConfig) types.DiffFormat {
+			return types.NewJsonLinesDiffFormat(types.DiffFormatConfig{})
+		})
+
+		format, err := types.NewDiffFormat("test", types.DiffFormatConfig{})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(format).To(BeAssignableToTypeOf(types.JsonLinesDiffFormat{}))
+	})
+
+	Describe("parity csv", func() {
+		It("parses positional columns", func() {
+			format, err := types.NewDiffFormat(types.ParityCsvFormat, types.DiffFormatConfig{})
+			Expect(err).NotTo(HaveOccurred())
+
+			diff, ok, parseErr := format.ParseLine(1, "0x123,0x456,789,0x987,0x654")
+
+			Expect(parseErr).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(diff).To(Equal(expectedDiff))
+		})
+	})
+
+	Describe("csv with a header", func() {
+		It("reads column positions from the first line", func() {
+			format := types.NewCsvDiffFormat(types.DiffFormatConfig{})
+
+			_, ok, headerErr := format.ParseLine(1, "storage_value,storage_key,block_height,block_hash,address,extra")
+			Expect(headerErr).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+
+			diff, ok, parseErr := format.ParseLine(2, "0x654,0x987,789,0x456,0x123,ignored")
+			Expect(parseErr).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(diff).To(Equal(expectedDiff))
+		})
+
+		It("maps fields to configured column names", func() {
+			format := types.NewCsvDiffFormat(types.DiffFormatConfig{Columns: map[string]string{
+				types.AddressField:     "contract",
+				types.BlockHeightField: "block",
+			}})
+			Expect(format.SetHeader("contract,block_hash,block,storage_key,storage_value")).To(Succeed())
+
+			diff, ok, parseErr := format.ParseLine(5, "0x123,0x456,0x315,0x987,0x654")
+
+			Expect(parseErr).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(diff).To(Equal(expectedDiff))
+		})
+
+		It("returns an error if the header is missing a column", func() {
+			format := types.NewCsvDiffFormat(types.DiffFormatConfig{})
+
+			_, _, err := format.ParseLine(1, "address,block_hash,block_height,storage_key")
+
+			Expect(err).To(MatchError(types.ErrDiffFieldMissing{Field: types.StorageValueField}))
+		})
+
+		It("returns an error if no header has been read", func() {
+			format := types.NewCsvDiffFormat(types.DiffFormatConfig{})
+
+			_, _, err := format.ParseLine(2, "0x123,0x456,789,0x987,0x654")
+
+			Expect(err).To(MatchError(types.ErrDiffHeaderMissing))
+		})
+
+		It("returns an error if a row is too short", func() {
+			format := types.NewCsvDiffFormat(types.DiffFormatConfig{})
+			Expect(format.SetHeader("address,block_hash,block_height,storage_key,storage_value")).To(Succeed())
+
+			_, _, err := format.ParseLine(2, "0x123,0x456")
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("json lines", func() {
+		It("parses named fields", func() {
+			format := types.NewJsonLinesDiffFormat(types.DiffFormatConfig{})
+
+			diff, ok, err := format.ParseLine(1,
+				`{"address":"0x123","block_hash":"0x456","block_height":789,"storage_key":"0x987","storage_value":"0x654"}`)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(diff).To(Equal(expectedDiff))
+		})
+
+		It("maps fields to configured keys", func() {
+			format := types.NewJsonLinesDiffFormat(types.DiffFormatConfig{Columns: map[string]string{
+				types.BlockHeightField: "blockNumber",
+			}})
+
+			diff, _, err := format.ParseLine(1,
+				`{"address":"0x123","block_hash":"0x456","blockNumber":"0x315","storage_key":"0x987","storage_value":"0x654"}`)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diff).To(Equal(expectedDiff))
+		})
+
+		It("returns an error if a field is missing", func() {
+			format := types.NewJsonLinesDiffFormat(types.DiffFormatConfig{})
+
+			_, _, err := format.ParseLine(1, `{"address":"0x123","block_hash":"0x456","block_height":789}`)
+
+			Expect(err).To(MatchError(types.ErrDiffFieldMissing{Field: types.StorageKeyField}))
+		})
+
+		It("returns an error if the line isn't json", func() {
+			format := types.NewJsonLinesDiffFormat(types.DiffFormatConfig{})
+
+			_, _, err := format.ParseLine(1, "0x123,0x456,789,0x987,0x654")
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -46,3 +46,29 @@ func (e ErrRowMalformed) Error() string {
 }
 
 var ErrKeyNotFound = errors.New("unknown storage key")
+
+type ErrStateDiffTraceMalformed struct {
+	Value string
+}
+
+func (e ErrStateDiffTraceMalformed) Error() string {
+	return fmt.Sprintf("state diff trace malformed: unexpected storage change %s", e.Value)
+}
+
+type ErrUnknownDiffFormat struct {
+	Name string
+}
+
+func (e ErrUnknownDiffFormat) Error() string {
+	return fmt.Sprintf("unknown storage diff format: %s", e.Name)
+}
+
+type ErrDiffFieldMissing struct {
+	Field string
+}
+
+func (e ErrDiffFieldMissing) Error() string {
+	return fmt.Sprintf("storage diff malformed: missing %s", e.Field)
+}
+
+var ErrDiffHeaderMissing = errors.New("storage diff csv has no header row")
@@ -0,0 +1,161 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// PrestateTraceAccount is an account's state as reported by geth's prestateTracer
+type PrestateTraceAccount struct {
+	Storage map[common.Hash]common.Hash `json:"storage"`
+}
+
+// PrestateTraceResult is the diff mode result of geth's prestateTracer for a single transaction
+type PrestateTraceResult struct {
+	Pre  map[common.Address]PrestateTraceAccount `json:"pre"`
+	Post map[common.Address]PrestateTraceAccount `json:"post"`
+}
+
+// PrestateTrace is an element of the response to debug_traceBlockByHash with the prestateTracer in diff mode
+type PrestateTrace struct {
+	TxHash common.Hash         `json:"txHash"`
+	Result PrestateTraceResult `json:"result"`
+}
+
+// StateDiffTraceAccount is an account's changes as reported by a Parity-style stateDiff trace. Storage values are
+// either "=" (unchanged) or an object keyed by "+" (created), "-" (deleted) or "*" (changed).
+type StateDiffTraceAccount struct {
+	Storage map[common.Hash]json.RawMessage `json:"storage"`
+}
+
+// StateDiffTrace is an element of the response to trace_replayBlockTransactions with the stateDiff trace type
+type StateDiffTrace struct {
+	TransactionHash common.Hash                              `json:"transactionHash"`
+	StateDiff       map[common.Address]StateDiffTraceAccount `json:"stateDiff"`
+}
+
+type stateDiffTraceChange struct {
+	From common.Hash `json:"from"`
+	To   common.Hash `json:"to"`
+}
+
+type blockStorage map[common.Address]map[common.Hash]common.Hash
+
+func (storage blockStorage) set(address common.Address, key, value common.Hash) {
+	if storage[address] == nil {
+		storage[address] = make(map[common.Hash]common.Hash)
+	}
+	storage[address][key] = value
+}
+
+func (storage blockStorage) toRawDiffs(blockHash common.Hash, blockHeight int) []RawDiff {
+	var diffs []RawDiff
+	for address, keysToValues := range storage {
+		for key, value := range keysToValues {
+			diffs = append(diffs, RawDiff{
+				Address:      address,
+				BlockHash:    blockHash,
+				BlockHeight:  blockHeight,
+				StorageKey:   key,
+				StorageValue: value,
+			})
+		}
+	}
+	return diffs
+}
+
+// FromPrestateTraces converts a block's prestateTracer diffs into one RawDiff per storage slot, holding the slot's
+// value at the end of the block. Slots present before a transaction but missing after it were cleared.
+func FromPrestateTraces(blockHash common.Hash, blockHeight int, traces []PrestateTrace) []RawDiff {
+	storage := make(blockStorage)
+	for _, trace := range traces {
+		for address, account := range trace.Result.Pre {
+			for key := range account.Storage {
+				if _, ok := trace.Result.Post[address].Storage[key]; !ok {
+					storage.set(address, key, common.Hash{})
+				}
+			}
+		}
+		for address, account := range trace.Result.Post {
+			for key, value := range account.Storage {
+				storage.set(address, key, value)
+			}
+		}
+	}
+	return storage.toRawDiffs(blockHash, blockHeight)
+}
+
+// FromStateDiffTraces converts a block's Parity-style stateDiff traces into one RawDiff per storage slot, holding the
+// slot's value at the end of the block
+func FromStateDiffTraces(blockHash common.Hash, blockHeight int, traces []StateDiffTrace) ([]RawDiff, error) {
+	storage := make(blockStorage)
+	for _, trace := range traces {
+		for address, account := range trace.StateDiff {
+			for key, rawChange := range account.Storage {
+				value, changed, parseErr := parseStateDiffTraceValue(rawChange)
+				if parseErr != nil {
+					return nil, fmt.Errorf("error parsing state diff trace for slot %s of %s in tx %s: %w",
+						key.Hex(), address.Hex(), trace.TransactionHash.Hex(), parseErr)
+				}
+				if changed {
+					storage.set(address, key, value)
+				}
+			}
+		}
+	}
+	return storage.toRawDiffs(blockHash, blockHeight), nil
+}
+
+func parseStateDiffTraceValue(rawChange json.RawMessage) (common.Hash, bool, error) {
+	var unchanged string
+	if json.Unmarshal(rawChange, &unchanged) == nil {
+		if unchanged == "=" {
+			return common.Hash{}, false, nil
+		}
+		return common.Hash{}, false, ErrStateDiffTraceMalformed{Value: string(rawChange)}
+	}
+
+	var change map[string]json.RawMessage
+	unmarshalErr := json.Unmarshal(rawChange, &change)
+	if unmarshalErr != nil {
+		return common.Hash{}, false, unmarshalErr
+	}
+	if created, ok := change["+"]; ok {
+		var value common.Hash
+		createdErr := json.Unmarshal(created, &value)
+		if createdErr != nil {
+			return common.Hash{}, false, createdErr
+		}
+		return value, true, nil
+	}
+	if _, ok := change["-"]; ok {
+		return common.Hash{}, true, nil
+	}
+	if modified, ok := change["*"]; ok {
+		var value stateDiffTraceChange
+		modifiedErr := json.Unmarshal(modified, &value)
+		if modifiedErr != nil {
+			return common.Hash{}, false, modifiedErr
+		}
+		return value.To, true, nil
+	}
+	return common.Hash{}, false, ErrStateDiffTraceMalformed{Value: string(rawChange)}
+}
@@ -0,0 +1,166 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package types_test
+
+import (
+	"encoding/json"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
+	"github.com/makerdao/vulcanizedb/libraries/shared/test_data"
+	"github.com/makerdao/vulcanizedb/pkg/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Storage trace parsing", func() {
+	var (
+		blockHash   = fakes.FakeHash
+		blockHeight = 123
+		address     common.Address
+		keyOne      common.Hash
+		keyTwo      common.Hash
+		valueOne    common.Hash
+		valueTwo    common.Hash
+	)
+
+	BeforeEach(func() {
+		address = test_data.FakeAddress()
+		keyOne = test_data.FakeHash()
+		keyTwo = test_data.FakeHash()
+		valueOne = test_data.FakeHash()
+		valueTwo = test_data.FakeHash()
+	})
+
+	expectedDiff := func(key, value common.Hash) types.RawDiff {
+		return types.RawDiff{
+			Address:      address,
+			BlockHash:    blockHash,
+			BlockHeight:  blockHeight,
+			StorageKey:   key,
+			StorageValue: value,
+		}
+	}
+
+	Describe("FromPrestateTraces", func() {
+		It("converts post-transaction storage to diffs", func() {
+			traces := []types.PrestateTrace{{Result: types.PrestateTraceResult{
+				Pre:  map[common.Address]types.PrestateTraceAccount{address: {Storage: map[common.Hash]common.Hash{keyOne: valueTwo}}},
+				Post: map[common.Address]types.PrestateTraceAccount{address: {Storage: map[common.Hash]common.Hash{keyOne: valueOne}}},
+			}}}
+
+			diffs := types.FromPrestateTraces(blockHash, blockHeight, traces)
+
+			Expect(diffs).To(ConsistOf(expectedDiff(keyOne, valueOne)))
+		})
+
+		It("treats slots missing from the post state as cleared", func() {
+			traces := []types.PrestateTrace{{Result: types.PrestateTraceResult{
+				Pre: map[common.Address]types.PrestateTraceAccount{address: {Storage: map[common.Hash]common.Hash{keyOne: valueOne}}},
+			}}}
+
+			diffs := types.FromPrestateTraces(blockHash, blockHeight, traces)
+
+			Expect(diffs).To(ConsistOf(expectedDiff(keyOne, common.Hash{})))
+		})
+
+		It("keeps the last value of each slot in the block", func() {
+			traces := []types.PrestateTrace{
+				{Result: types.PrestateTraceResult{
+					Post: map[common.Address]types.PrestateTraceAccount{address: {Storage: map[common.Hash]common.Hash{keyOne: valueOne, keyTwo: valueOne}}},
+				}},
+				{Result: types.PrestateTraceResult{
+					Pre:  map[common.Address]types.PrestateTraceAccount{address: {Storage: map[common.Hash]common.Hash{keyOne: valueOne}}},
+					Post: map[common.Address]types.PrestateTraceAccount{address: {Storage: map[common.Hash]common.Hash{keyOne: valueTwo}}},
+				}},
+			}
+
+			diffs := types.FromPrestateTraces(blockHash, blockHeight, traces)
+
+			Expect(diffs).To(ConsistOf(expectedDiff(keyOne, valueTwo), expectedDiff(keyTwo, valueOne)))
+		})
+	})
+
+	Describe("FromStateDiffTraces", func() {
+		stateDiffTrace := func(storage map[common.Hash]string) types.StateDiffTrace {
+			rawStorage := make(map[common.Hash]json.RawMessage)
+			for key, change := range storage {
+				rawStorage[key] = json.RawMessage(change)
+			}
+			return types.StateDiffTrace{
+				StateDiff: map[common.Address]types.StateDiffTraceAccount{address: {Storage: rawStorage}},
+			}
+		}
+
+		It("converts created, changed and deleted slots to diffs", func() {
+			keyThree := test_data.FakeHash()
+			traces := []types.StateDiffTrace{stateDiffTrace(map[common.Hash]string{
+				keyOne:   `{"+":"` + valueOne.Hex() + `"}`,
+				keyTwo:   `{"*":{"from":"` + valueOne.Hex() + `","to":"` + valueTwo.Hex() + `"}}`,
+				keyThree: `{"-":"` + valueOne.Hex() + `"}`,
+			})}
+
+			diffs, err := types.FromStateDiffTraces(blockHash, blockHeight, traces)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diffs).To(ConsistOf(
+				expectedDiff(keyOne, valueOne),
+				expectedDiff(keyTwo, valueTwo),
+				expectedDiff(keyThree, common.Hash{}),
+			))
+		})
+
+		It("ignores unchanged slots", func() {
+			traces := []types.StateDiffTrace{stateDiffTrace(map[common.Hash]string{keyOne: `"="`})}
+
+			diffs, err := types.FromStateDiffTraces(blockHash, blockHeight, traces)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diffs).To(BeEmpty())
+		})
+
+		It("keeps the last value of each slot in the block", func() {
+			traces := []types.StateDiffTrace{
+				stateDiffTrace(map[common.Hash]string{keyOne: `{"+":"` + valueOne.Hex() + `"}`}),
+				stateDiffTrace(map[common.Hash]string{keyOne: `{"*":{"from":"` + valueOne.Hex() + `","to":"` + valueTwo.Hex() + `"}}`}),
+			}
+
+			diffs, err := types.FromStateDiffTraces(blockHash, blockHeight, traces)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diffs).To(ConsistOf(expectedDiff(keyOne, valueTwo)))
+		})
+
+		It("returns an error if a storage change is malformed", func() {
+			traces := []types.StateDiffTrace{stateDiffTrace(map[common.Hash]string{keyOne: `"?"`})}
+
+			_, err := types.FromStateDiffTraces(blockHash, blockHeight, traces)
+
+			Expect(err).To(MatchError(ContainSubstring(types.ErrStateDiffTraceMalformed{Value: `"?"`}.Error())))
+		})
+
+		It("returns an error if a created or changed value is malformed", func() {
+			for _, change := range []string{`{"+":123}`, `{"*":{"from":"0x1","to":123}}`} {
+				traces := []types.StateDiffTrace{stateDiffTrace(map[common.Hash]string{keyOne: change})}
+
+				_, err := types.FromStateDiffTraces(blockHash, blockHeight, traces)
+
+				Expect(err).To(HaveOccurred())
+			}
+		})
+	})
+})
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// AddressFilter decides whether storage diffs for an address are worth persisting
+type AddressFilter interface {
+	Watches(address common.Address) bool
+}
+
+// WatchedAddresses is a set of addresses that can be replaced while it's in use, e.g. when config is reloaded
+type WatchedAddresses struct {
+	mutex     sync.RWMutex
+	addresses map[common.Address]bool
+}
+
+func NewWatchedAddresses(addresses []common.Address) *WatchedAddresses {
+	watched := &WatchedAddresses{}
+	watched.Set(addresses)
+	return watched
+}
+
+func (watched *WatchedAddresses) Set(addresses []common.Address) {
+	set := make(map[common.Address]bool, len(addresses))
+	for _, address := range addresses {
+		set[address] = true
+	}
+	watched.mutex.Lock()
+	defer watched.mutex.Unlock()
+	watched.addresses = set
+}
+
+func (watched *WatchedAddresses) Watches(address common.Address) bool {
+	watched.mutex.RLock()
+	defer watched.mutex.RUnlock()
+	return watched.addresses[address]
+}
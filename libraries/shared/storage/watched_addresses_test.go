@@ -0,0 +1,31 @@
+package storage_test
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage"
+	"github.com/makerdao/vulcanizedb/libraries/shared/test_data"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Watched addresses", func() {
+	It("watches the addresses it was created with", func() {
+		address := test_data.FakeAddress()
+
+		watched := storage.NewWatchedAddresses([]common.Address{address})
+
+		Expect(watched.Watches(address)).To(BeTrue())
+		Expect(watched.Watches(test_data.FakeAddress())).To(BeFalse())
+	})
+
+	It("replaces the watched addresses", func() {
+		oldAddress := test_data.FakeAddress()
+		newAddress := test_data.FakeAddress()
+		watched := storage.NewWatchedAddresses([]common.Address{oldAddress})
+
+		watched.Set([]common.Address{newAddress})
+
+		Expect(watched.Watches(oldAddress)).To(BeFalse())
+		Expect(watched.Watches(newAddress)).To(BeTrue())
+	})
+})
@@ -24,6 +24,7 @@ import (
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/makerdao/vulcanizedb/libraries/shared/factories/event"
+	storageTypes "github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
 )
 
 var startingBlockNumber = rand.Int63()
@@ -59,6 +60,16 @@ func FakeHash() common.Hash {
 	return common.HexToHash("0x" + randomString(64))
 }
 
+func FakeRawDiff() storageTypes.RawDiff {
+	return storageTypes.RawDiff{
+		Address:      FakeAddress(),
+		BlockHash:    FakeHash(),
+		BlockHeight:  rand.Int(),
+		StorageKey:   FakeHash(),
+		StorageValue: FakeHash(),
+	}
+}
+
 func randomString(length int) string {
 	var seededRand = rand.New(
 		rand.NewSource(time.Now().UnixNano()))
@@ -17,6 +17,9 @@
 package transactions
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/makerdao/vulcanizedb/pkg/core"
@@ -27,6 +30,7 @@ import (
 
 type ITransactionsSyncer interface {
 	SyncTransactions(headerID int64, logs []types.Log) error
+	SyncTransactionsTo(header core.Header, addresses []string) error
 }
 
 type TransactionsSyncer struct {
@@ -58,6 +62,39 @@ func (syncer TransactionsSyncer) SyncTransactions(headerID int64, logs []types.L
 	return nil
 }
 
+// SyncTransactionsTo persists the transactions in the header's block sent to any of the addresses, along with their
+// receipts, including those that reverted or emitted no logs
+func (syncer TransactionsSyncer) SyncTransactionsTo(header core.Header, addresses []string) error {
+	blockTransactions, blockErr := syncer.BlockChain.GetBlockTransactions(common.HexToHash(header.Hash))
+	if blockErr != nil {
+		return fmt.Errorf("error getting transactions in block %d: %w", header.BlockNumber, blockErr)
+	}
+	recipients := make(map[string]bool, len(addresses))
+	for _, address := range addresses {
+		recipients[strings.ToLower(address)] = true
+	}
+	var transactions []core.TransactionModel
+	var transactionHashes []common.Hash
+	for _, transaction := range blockTransactions {
+		if transaction.To != "" && recipients[strings.ToLower(transaction.To)] {
+			transactions = append(transactions, transaction)
+			transactionHashes = append(transactionHashes, common.HexToHash(transaction.Hash))
+		}
+	}
+	if len(transactions) < 1 {
+		return nil
+	}
+
+	receipts, receiptsErr := syncer.BlockChain.GetTransactionReceipts(transactionHashes)
+	if receiptsErr != nil {
+		return fmt.Errorf("error getting transaction receipts in block %d: %w", header.BlockNumber, receiptsErr)
+	}
+	for index := range transactions {
+		transactions[index].Receipt = receipts[index]
+	}
+	return syncer.Repository.CreateTransactionsWithReceipts(header.Id, transactions)
+}
+
 func getUniqueTransactionHashes(logs []types.Log) []common.Hash {
 	seen := make(map[common.Hash]struct{}, len(logs))
 	var result []common.Hash
@@ -17,7 +17,11 @@
 package transactions_test
 
 import (
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/makerdao/vulcanizedb/libraries/shared/test_data"
 	"github.com/makerdao/vulcanizedb/libraries/shared/transactions"
 	"github.com/makerdao/vulcanizedb/pkg/core"
 	"github.com/makerdao/vulcanizedb/pkg/fakes"
@@ -95,4 +99,69 @@ var _ = Describe("Transaction syncer", func() {
 		Expect(err).To(HaveOccurred())
 		Expect(err).To(MatchError(fakes.FakeError))
 	})
+	Describe("SyncTransactionsTo", func() {
+		var (
+			header               = core.Header{Id: 1, BlockNumber: 10, Hash: fakes.FakeHash.Hex()}
+			watched              = "0x8dd5fbCe2F6a956C3022bA3663759011Dd51e73E"
+			revertedHash         = common.HexToHash("0x01")
+			mockHeaderRepository *fakes.MockHeaderRepository
+		)
+
+		BeforeEach(func() {
+			mockHeaderRepository = fakes.NewMockHeaderRepository()
+			syncer.Repository = mockHeaderRepository
+			blockChain.BlockTransactions = []core.TransactionModel{
+				{Hash: revertedHash.Hex(), To: watched, TxIndex: 0},
+				{Hash: common.HexToHash("0x02").Hex(), To: fakes.FakeAddress.Hex(), TxIndex: 1},
+				{Hash: common.HexToHash("0x03").Hex(), TxIndex: 2},
+			}
+			blockChain.Receipts = map[common.Hash]core.Receipt{revertedHash: {TxHash: revertedHash.Hex(), Status: 0}}
+		})
+
+		It("persists the block's transactions to the addresses with their receipts", func() {
+			err := syncer.SyncTransactionsTo(header, []string{strings.ToLower(watched)})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(blockChain.GetBlockTransactionsPassedHashes).To(Equal([]common.Hash{fakes.FakeHash}))
+			Expect(blockChain.GetTransactionReceiptsPassedHashes).To(Equal([][]common.Hash{{revertedHash}}))
+			Expect(mockHeaderRepository.CreateTransactionsWithReceiptsPassedTransactions).To(Equal([]core.TransactionModel{{
+				Hash:    revertedHash.Hex(),
+				To:      watched,
+				Receipt: core.Receipt{TxHash: revertedHash.Hex(), Status: 0},
+			}}))
+		})
+
+		It("doesn't fetch receipts if no transactions were sent to the addresses", func() {
+			err := syncer.SyncTransactionsTo(header, []string{test_data.FakeAddress().Hex()})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(blockChain.GetTransactionReceiptsPassedHashes).To(BeEmpty())
+			Expect(mockHeaderRepository.CreateTransactionsWithReceiptsPassedTransactions).To(BeEmpty())
+		})
+
+		It("returns an error if fetching the block's transactions fails", func() {
+			blockChain.GetBlockTransactionsError = fakes.FakeError
+
+			err := syncer.SyncTransactionsTo(header, []string{watched})
+
+			Expect(err).To(MatchError(fakes.FakeError))
+		})
+
+		It("returns an error if fetching receipts fails", func() {
+			blockChain.GetTransactionReceiptsError = fakes.FakeError
+
+			err := syncer.SyncTransactionsTo(header, []string{watched})
+
+			Expect(err).To(MatchError(fakes.FakeError))
+			Expect(mockHeaderRepository.CreateTransactionsWithReceiptsPassedTransactions).To(BeEmpty())
+		})
+
+		It("returns an error if persisting fails", func() {
+			mockHeaderRepository.CreateTransactionsWithReceiptsError = fakes.FakeError
+
+			err := syncer.SyncTransactionsTo(header, []string{watched})
+
+			Expect(err).To(MatchError(fakes.FakeError))
+		})
+	})
 })
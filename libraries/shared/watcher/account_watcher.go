@@ -0,0 +1,170 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package watcher
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/makerdao/vulcanizedb/libraries/shared/factories/account"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
+	"github.com/makerdao/vulcanizedb/pkg/datastore"
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres"
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres/repositories"
+	"github.com/makerdao/vulcanizedb/pkg/fs"
+	"github.com/sirupsen/logrus"
+)
+
+type IAccountWatcher interface {
+	AddTransformers(initializers []account.TransformerInitializer)
+	Execute() error
+}
+
+// AccountWatcher transforms account diffs (balance, nonce, code hash and storage root changes) with the transformer
+// registered for the account's address, the way StorageWatcher transforms storage diffs
+type AccountWatcher struct {
+	db                    *postgres.DB
+	HeaderRepository      datastore.HeaderRepository
+	AddressTransformers   map[common.Address]account.ITransformer // account address => transformer
+	AccountDiffRepository storage.AccountDiffRepository
+	StatusWriter          fs.StatusWriter
+}
+
+func NewAccountWatcher(db *postgres.DB, statusWriter fs.StatusWriter) AccountWatcher {
+	return AccountWatcher{
+		db:                    db,
+		HeaderRepository:      repositories.NewHeaderRepository(db),
+		AddressTransformers:   make(map[common.Address]account.ITransformer),
+		AccountDiffRepository: storage.NewAccountDiffRepository(db),
+		StatusWriter:          statusWriter,
+	}
+}
+
+func (watcher AccountWatcher) AddTransformers(initializers []account.TransformerInitializer) {
+	for _, initializer := range initializers {
+		accountTransformer := initializer(watcher.db)
+		watcher.AddressTransformers[accountTransformer.GetContractAddress()] = accountTransformer
+	}
+}
+
+func (watcher AccountWatcher) Execute() error {
+	writeErr := watcher.StatusWriter.Write()
+	if writeErr != nil {
+		return fmt.Errorf("error confirming health check: %w", writeErr)
+	}
+
+	for {
+		err := watcher.transformDiffs()
+		if err != nil {
+			logrus.Errorf("error transforming account diffs: %s", err.Error())
+			return err
+		}
+	}
+}
+
+func (watcher AccountWatcher) transformDiffs() error {
+	_, releaseErr := watcher.AccountDiffRepository.ReleasePendingHeaderDiffs()
+	if releaseErr != nil {
+		return fmt.Errorf("error releasing account diffs pending header: %w", releaseErr)
+	}
+
+	minID := 0
+	for {
+		diffs, getDiffsErr := watcher.AccountDiffRepository.GetNewAccountDiffs(minID, ResultsLimit)
+		if getDiffsErr != nil {
+			return fmt.Errorf("error getting new account diffs: %w", getDiffsErr)
+		}
+		for _, diff := range diffs {
+			transformErr := watcher.transformDiff(diff)
+			if transformErr != nil {
+				return fmt.Errorf("error transforming account diff: %w", transformErr)
+			}
+		}
+		lenDiffs := len(diffs)
+		if lenDiffs > 0 {
+			minID = int(diffs[lenDiffs-1].ID)
+		}
+		if lenDiffs < ResultsLimit {
+			return nil
+		}
+	}
+}
+
+func (watcher AccountWatcher) transformDiff(diff types.PersistedAccountDiff) error {
+	t, watching := watcher.AddressTransformers[diff.Address]
+	if !watching {
+		markUnwatchedErr := watcher.AccountDiffRepository.MarkUnwatched(diff.ID)
+		if markUnwatchedErr != nil {
+			return fmt.Errorf("error marking account diff %s: %w", storage.Unwatched, markUnwatchedErr)
+		}
+		return nil
+	}
+
+	hasPendingPredecessor, predecessorErr := watcher.AccountDiffRepository.HasPendingHeaderPredecessor(diff)
+	if predecessorErr != nil {
+		return fmt.Errorf("error checking for pending predecessors of account diff: %w", predecessorErr)
+	}
+	if hasPendingPredecessor {
+		return watcher.markPendingHeader(diff)
+	}
+
+	header, headerErr := watcher.HeaderRepository.GetHeaderByBlockNumber(int64(diff.BlockHeight))
+	if headerErr != nil {
+		if errors.Is(headerErr, sql.ErrNoRows) {
+			return watcher.markPendingHeader(diff)
+		}
+		return fmt.Errorf("error getting header by block number %d: %w", diff.BlockHeight, headerErr)
+	}
+	if diff.BlockHash != common.HexToHash(header.Hash) {
+		return watcher.handleDiffWithInvalidHeaderHash(diff)
+	}
+	diff.HeaderID = header.Id
+
+	executeErr := t.Execute(diff)
+	if executeErr != nil {
+		return fmt.Errorf("error executing account transformer: %w", executeErr)
+	}
+
+	markTransformedErr := watcher.AccountDiffRepository.MarkTransformed(diff.ID)
+	if markTransformedErr != nil {
+		return fmt.Errorf("error marking account diff %s: %w", storage.Transformed, markTransformedErr)
+	}
+	return nil
+}
+
+func (watcher AccountWatcher) markPendingHeader(diff types.PersistedAccountDiff) error {
+	markPendingErr := watcher.AccountDiffRepository.MarkPendingHeader(diff.ID)
+	if markPendingErr != nil {
+		return fmt.Errorf("error marking account diff %s: %w", storage.PendingHeader, markPendingErr)
+	}
+	return nil
+}
+
+func (watcher AccountWatcher) handleDiffWithInvalidHeaderHash(diff types.PersistedAccountDiff) error {
+	maxBlock, maxBlockErr := watcher.HeaderRepository.GetMostRecentHeaderBlockNumber()
+	if maxBlockErr != nil {
+		msg := "error getting max block while handling account diff %d with invalid header hash: %w"
+		return fmt.Errorf(msg, diff.ID, maxBlockErr)
+	}
+	if diff.BlockHeight < int(maxBlock)-ReorgWindow {
+		return watcher.AccountDiffRepository.MarkNoncanonical(diff.ID)
+	}
+	return nil
+}
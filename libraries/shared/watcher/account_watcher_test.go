@@ -0,0 +1,163 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package watcher_test
+
+import (
+	"database/sql"
+	"math/big"
+	"math/rand"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/makerdao/vulcanizedb/libraries/shared/factories/account"
+	"github.com/makerdao/vulcanizedb/libraries/shared/mocks"
+	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
+	"github.com/makerdao/vulcanizedb/libraries/shared/test_data"
+	"github.com/makerdao/vulcanizedb/libraries/shared/watcher"
+	"github.com/makerdao/vulcanizedb/pkg/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Account Watcher", func() {
+	var (
+		statusWriter         fakes.MockStatusWriter
+		accountWatcher       watcher.AccountWatcher
+		mockDiffsRepository  *mocks.MockAccountDiffRepository
+		mockHeaderRepository *fakes.MockHeaderRepository
+		mockTransformer      *mocks.MockAccountTransformer
+		accountAddress       common.Address
+		diff                 types.PersistedAccountDiff
+	)
+
+	BeforeEach(func() {
+		statusWriter = fakes.MockStatusWriter{}
+		mockDiffsRepository = &mocks.MockAccountDiffRepository{}
+		mockHeaderRepository = &fakes.MockHeaderRepository{}
+		accountWatcher = watcher.NewAccountWatcher(nil, &statusWriter)
+		accountWatcher.HeaderRepository = mockHeaderRepository
+		accountWatcher.AccountDiffRepository = mockDiffsRepository
+		accountAddress = test_data.FakeAddress()
+		mockTransformer = &mocks.MockAccountTransformer{Address: accountAddress}
+		accountWatcher.AddTransformers([]account.TransformerInitializer{mockTransformer.FakeTransformerInitializer})
+		diff = types.PersistedAccountDiff{
+			RawAccountDiff: types.RawAccountDiff{
+				Address:     accountAddress,
+				BlockHash:   test_data.FakeHash(),
+				BlockHeight: rand.Int(),
+				Balance:     big.NewInt(rand.Int63()),
+			},
+			ID: rand.Int63(),
+		}
+		mockHeaderRepository.GetHeaderByBlockNumberReturnHash = diff.BlockHash.Hex()
+		mockHeaderRepository.GetHeaderByBlockNumberReturnID = rand.Int63()
+		mockDiffsRepository.GetNewDiffsDiffs = []types.PersistedAccountDiff{diff}
+		mockDiffsRepository.GetNewDiffsErrors = []error{nil, fakes.FakeError}
+	})
+
+	It("adds transformers", func() {
+		Expect(accountWatcher.AddressTransformers[accountAddress]).To(Equal(mockTransformer))
+	})
+
+	It("creates file for health check", func() {
+		err := accountWatcher.Execute()
+
+		Expect(err).To(MatchError(fakes.FakeError))
+		Expect(statusWriter.WriteCalled).To(BeTrue())
+	})
+
+	It("executes the transformer for the diff's address with the header id", func() {
+		err := accountWatcher.Execute()
+
+		Expect(err).To(MatchError(fakes.FakeError))
+		expectedDiff := diff
+		expectedDiff.HeaderID = mockHeaderRepository.GetHeaderByBlockNumberReturnID
+		Expect(mockTransformer.PassedDiffs).To(ConsistOf(expectedDiff))
+		Expect(mockDiffsRepository.MarkTransformedPassedIDs).To(ConsistOf(diff.ID))
+	})
+
+	It("marks diffs for unwatched addresses as unwatched", func() {
+		diff.Address = test_data.FakeAddress()
+		mockDiffsRepository.GetNewDiffsDiffs = []types.PersistedAccountDiff{diff}
+
+		err := accountWatcher.Execute()
+
+		Expect(err).To(MatchError(fakes.FakeError))
+		Expect(mockTransformer.PassedDiffs).To(BeEmpty())
+		Expect(mockDiffsRepository.MarkUnwatchedPassedID).To(Equal(diff.ID))
+	})
+
+	It("releases diffs pending header before fetching diffs", func() {
+		mockDiffsRepository.GetNewDiffsErrors = []error{fakes.FakeError}
+
+		err := accountWatcher.Execute()
+
+		Expect(err).To(MatchError(fakes.FakeError))
+		Expect(mockDiffsRepository.ReleasePendingHeaderDiffsCalled).To(BeTrue())
+	})
+
+	It("returns an error if releasing diffs pending header fails", func() {
+		mockDiffsRepository.ReleasePendingHeaderDiffsErr = fakes.FakeError
+
+		err := accountWatcher.Execute()
+
+		Expect(err).To(MatchError(fakes.FakeError))
+		Expect(mockDiffsRepository.GetNewDiffsPassedMinIDs).To(BeEmpty())
+	})
+
+	It("marks diffs pending header if their header has not been synced", func() {
+		mockHeaderRepository.GetHeaderByBlockNumberError = sql.ErrNoRows
+
+		err := accountWatcher.Execute()
+
+		Expect(err).To(MatchError(fakes.FakeError))
+		Expect(mockTransformer.PassedDiffs).To(BeEmpty())
+		Expect(mockDiffsRepository.MarkTransformedPassedIDs).To(BeEmpty())
+		Expect(mockDiffsRepository.MarkPendingHeaderPassedIDs).To(ConsistOf(diff.ID))
+	})
+
+	It("marks diffs pending header if an earlier diff for the same account is pending header", func() {
+		mockDiffsRepository.HasPendingHeaderPredecessorToReturn = true
+
+		err := accountWatcher.Execute()
+
+		Expect(err).To(MatchError(fakes.FakeError))
+		Expect(mockDiffsRepository.HasPendingHeaderPredecessorPassedDiffs).To(ConsistOf(diff))
+		Expect(mockTransformer.PassedDiffs).To(BeEmpty())
+		Expect(mockDiffsRepository.MarkPendingHeaderPassedIDs).To(ConsistOf(diff.ID))
+	})
+
+	It("marks diffs noncanonical if their hash doesn't match a header outside the reorg window", func() {
+		mockHeaderRepository.GetHeaderByBlockNumberReturnHash = test_data.FakeHash().Hex()
+		mockHeaderRepository.MostRecentHeaderBlockNumber = int64(diff.BlockHeight + watcher.ReorgWindow + 1)
+
+		err := accountWatcher.Execute()
+
+		Expect(err).To(MatchError(fakes.FakeError))
+		Expect(mockTransformer.PassedDiffs).To(BeEmpty())
+		Expect(mockDiffsRepository.MarkNoncanonicalPassedID).To(Equal(diff.ID))
+	})
+
+	It("returns an error if the transformer fails", func() {
+		mockTransformer.ExecuteErr = fakes.FakeError
+		mockDiffsRepository.GetNewDiffsErrors = []error{nil}
+
+		err := accountWatcher.Execute()
+
+		Expect(err).To(MatchError(fakes.FakeError))
+		Expect(mockDiffsRepository.MarkTransformedPassedIDs).To(BeEmpty())
+	})
+})
@@ -20,6 +20,8 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	storage2 "github.com/makerdao/vulcanizedb/libraries/shared/factories/storage"
@@ -36,6 +38,14 @@ var (
 	ErrHeaderMismatch = errors.New("header hash doesn't match between db and diff")
 	ReorgWindow       = 250
 	ResultsLimit      = 500
+	// DefaultStorageWorkers is the number of contracts whose diffs are transformed concurrently by default
+	DefaultStorageWorkers = 4
+	// DefaultPartitionRetries is how many consecutive times a contract's turn is retried after failing by default
+	DefaultPartitionRetries = 5
+	// DefaultPartitionRetryInterval is the delay before a failed turn is first retried, doubling on each retry
+	DefaultPartitionRetryInterval = time.Second
+	// DefaultStatsInterval is how often storage key lookup stats are logged by default
+	DefaultStatsInterval = time.Minute
 )
 
 type IStorageWatcher interface {
@@ -50,6 +60,12 @@ type StorageWatcher struct {
 	StorageDiffRepository     storage.DiffRepository
 	DiffBlocksFromHeadOfChain int64 // the number of blocks from the head of the chain where diffs should be processed
 	StatusWriter              fs.StatusWriter
+	Workers                   int // the number of contracts whose diffs are transformed concurrently
+	// A contract whose turn fails is retried after PartitionRetryInterval, doubling on each consecutive failure, while
+	// other contracts carry on; once it has failed PartitionRetries times in a row, Execute returns its error
+	PartitionRetries       int
+	PartitionRetryInterval time.Duration
+	StatsInterval          time.Duration // how often each contract's storage key lookup stats are logged; zero disables logging them
 }
 
 func NewStorageWatcher(db *postgres.DB, backFromHeadOfChain int64, statusWriter fs.StatusWriter) StorageWatcher {
@@ -63,6 +79,10 @@ func NewStorageWatcher(db *postgres.DB, backFromHeadOfChain int64, statusWriter
 		StorageDiffRepository:     storageDiffRepository,
 		DiffBlocksFromHeadOfChain: backFromHeadOfChain,
 		StatusWriter:              statusWriter,
+		Workers:                   DefaultStorageWorkers,
+		PartitionRetries:          DefaultPartitionRetries,
+		PartitionRetryInterval:    DefaultPartitionRetryInterval,
+		StatsInterval:             DefaultStatsInterval,
 	}
 }
 
@@ -73,78 +93,211 @@ func (watcher StorageWatcher) AddTransformers(initializers []storage2.Transforme
 	}
 }
 
+// Execute transforms diffs for each watched contract in its own partition, so that a contract with a large backlog
+// or a slow repository only delays its own diffs. Partitions take turns on a pool of workers; each turn processes
+// the contract's pending diffs in block order until caught up. Diffs for unwatched contracts get a partition of
+// their own that marks them unwatched. A partition whose turn fails is retried with backoff without holding up the
+// others; if it keeps failing, every worker is stopped and waited for before its error is returned.
 func (watcher StorageWatcher) Execute() error {
 	writeErr := watcher.StatusWriter.Write()
 	if writeErr != nil {
 		return fmt.Errorf("error confirming health check: %w", writeErr)
 	}
 
+	partitions := make(chan diffPartition, len(watcher.AddressTransformers)+1)
+	partitions <- diffPartition{unwatched: true}
+	for address := range watcher.AddressTransformers {
+		partitions <- diffPartition{address: address}
+	}
+
+	workers := watcher.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	errs := make(chan error, workers)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			watcher.work(partitions, errs, done)
+		}()
+	}
+	if watcher.StatsInterval > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			watcher.logLookupStats(done)
+		}()
+	}
+
+	err := <-errs
+	close(done)
+	wg.Wait()
+	logrus.Errorf("error transforming diffs: %s", err.Error())
+	return err
+}
+
+// logLookupStats logs how each contract's storage key lookups fared since the last log, until done is closed
+func (watcher StorageWatcher) logLookupStats(done <-chan struct{}) {
+	ticker := time.NewTicker(watcher.StatsInterval)
+	defer ticker.Stop()
+	previous := make(map[common.Address]storage2.KeysLookupStats)
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			for address, transformer := range watcher.AddressTransformers {
+				lookup := transformer.GetStorageKeysLookup()
+				if lookup == nil {
+					continue
+				}
+				stats := lookup.Stats()
+				last := previous[address]
+				logrus.Infof("looked up storage keys for %s in %s: %d hits, %d misses, %d refreshes",
+					address.Hex(), watcher.StatsInterval, stats.Hits-last.Hits, stats.Misses-last.Misses,
+					stats.Refreshes-last.Refreshes)
+				previous[address] = stats
+			}
+		}
+	}
+}
+
+type diffPartition struct {
+	address   common.Address
+	unwatched bool
+	failures  int
+}
+
+func (partition diffPartition) String() string {
+	if partition.unwatched {
+		return "unwatched diffs"
+	}
+	return "diffs for " + partition.address.Hex()
+}
+
+func (watcher StorageWatcher) work(partitions chan diffPartition, errs chan<- error, done <-chan struct{}) {
 	for {
-		err := watcher.transformDiffs()
-		if err != nil {
-			logrus.Errorf("error transforming diffs: %s", err.Error())
-			return err
+		select {
+		case <-done:
+			return
+		case partition := <-partitions:
+			var err error
+			if partition.unwatched {
+				err = watcher.markUnwatchedDiffs()
+			} else {
+				err = watcher.transformDiffs(partition.address, done)
+			}
+			if err == nil {
+				partition.failures = 0
+				partitions <- partition
+				continue
+			}
+			partition.failures++
+			if partition.failures > watcher.PartitionRetries {
+				errs <- err
+				return
+			}
+			retryInterval := watcher.partitionRetryInterval(partition.failures)
+			logrus.Warnf("error transforming %s, retrying in %s (%d of %d): %s", partition, retryInterval,
+				partition.failures, watcher.PartitionRetries, err.Error())
+			// the partition isn't taken again until the retry is due, so other partitions keep the workers busy
+			retry := partition
+			time.AfterFunc(retryInterval, func() { partitions <- retry })
 		}
 	}
 }
 
-func (watcher StorageWatcher) getMinDiffID() (int, error) {
-	var minID = 0
+// partitionRetryInterval returns the delay before retrying a partition after the given number of consecutive failures
+func (watcher StorageWatcher) partitionRetryInterval(failures int) time.Duration {
+	retryInterval := watcher.PartitionRetryInterval
+	for i := 1; i < failures; i++ {
+		retryInterval *= 2
+	}
+	return retryInterval
+}
+
+func (watcher StorageWatcher) markUnwatchedDiffs() error {
+	var watched []common.Address
+	for address := range watcher.AddressTransformers {
+		watched = append(watched, address)
+	}
+	_, markUnwatchedErr := watcher.StorageDiffRepository.MarkUnwatchedExcept(watched)
+	if markUnwatchedErr != nil {
+		return fmt.Errorf("error marking diffs %s: %w", storage.Unwatched, markUnwatchedErr)
+	}
+	return nil
+}
+
+func (watcher StorageWatcher) getStartingCursor(address common.Address) (storage.DiffCursor, error) {
+	minReleasedBlockHeight, releaseErr := watcher.StorageDiffRepository.ReleasePendingHeaderDiffs(address)
+	if releaseErr != nil {
+		return storage.DiffCursor{}, fmt.Errorf("error releasing diffs pending header: %w", releaseErr)
+	}
+
+	var minBlockHeight int64 = 0
 	if watcher.DiffBlocksFromHeadOfChain != -1 {
 		mostRecentHeaderBlockNumber, getHeaderErr := watcher.HeaderRepository.GetMostRecentHeaderBlockNumber()
-		if getHeaderErr != nil {
-			return 0, fmt.Errorf("error getting most recent header block number: %w", getHeaderErr)
+		if getHeaderErr != nil && !errors.Is(getHeaderErr, sql.ErrNoRows) {
+			return storage.DiffCursor{}, fmt.Errorf("error getting most recent header block number: %w", getHeaderErr)
 		}
-		blockNumber := mostRecentHeaderBlockNumber - watcher.DiffBlocksFromHeadOfChain
-		diffID, getDiffErr := watcher.StorageDiffRepository.GetFirstDiffIDForBlockHeight(blockNumber)
-		if getDiffErr != nil {
-			return 0, fmt.Errorf("error getting first diff id for block height %d: %w", blockNumber, getDiffErr)
+		if getHeaderErr == nil {
+			minBlockHeight = mostRecentHeaderBlockNumber - watcher.DiffBlocksFromHeadOfChain
 		}
+	}
 
-		// We are subtracting an offset from the diffID because it will be passed to GetNewDiffs which returns diffs with ids
-		// greater than id passed in (minID), and we want to make sure that this diffID here is included in that collection
-		diffOffset := int64(1)
-		minID = int(diffID - diffOffset)
+	// Diffs released from pending header may be older than the configured window, but still need to be transformed
+	if minReleasedBlockHeight >= 0 && minReleasedBlockHeight < minBlockHeight {
+		minBlockHeight = minReleasedBlockHeight
 	}
 
-	return minID, nil
+	return storage.NewDiffCursor(minBlockHeight), nil
 }
 
-func (watcher StorageWatcher) transformDiffs() error {
-	minID, minIDErr := watcher.getMinDiffID()
-	if minIDErr != nil && !errors.Is(minIDErr, sql.ErrNoRows) {
-		return fmt.Errorf("error getting min diff ID: %w", minIDErr)
+// transformDiffs processes the contract's pending diffs until caught up, or until done is closed
+func (watcher StorageWatcher) transformDiffs(address common.Address, done <-chan struct{}) error {
+	cursor, cursorErr := watcher.getStartingCursor(address)
+	if cursorErr != nil {
+		return fmt.Errorf("error getting starting cursor for address %s: %w", address.Hex(), cursorErr)
 	}
 
+	t := watcher.AddressTransformers[address]
 	for {
-		diffs, extractErr := watcher.StorageDiffRepository.GetNewDiffs(minID, ResultsLimit)
+		diffs, extractErr := watcher.StorageDiffRepository.GetNewDiffsForAddress(address, cursor, ResultsLimit)
 		if extractErr != nil {
 			return fmt.Errorf("error getting new diffs: %w", extractErr)
 		}
 		for _, diff := range diffs {
-			transformErr := watcher.transformDiff(diff)
+			transformErr := watcher.transformDiff(t, diff)
 			if handleErr := watcher.handleTransformError(transformErr, diff); handleErr != nil {
 				return fmt.Errorf("error transforming diff: %w", handleErr)
 			}
 		}
 		lenDiffs := len(diffs)
 		if lenDiffs > 0 {
-			minID = int(diffs[lenDiffs-1].ID)
+			lastDiff := diffs[lenDiffs-1]
+			cursor = storage.DiffCursor{BlockHeight: int64(lastDiff.BlockHeight), ID: lastDiff.ID}
 		}
 		if lenDiffs < ResultsLimit {
 			return nil
 		}
+		select {
+		case <-done:
+			return nil
+		default:
+		}
 	}
 }
 
-func (watcher StorageWatcher) transformDiff(diff types.PersistedDiff) error {
-	t, watching := watcher.getTransformer(diff)
-	if !watching {
-		markUnwatchedErr := watcher.StorageDiffRepository.MarkUnwatched(diff.ID)
-		if markUnwatchedErr != nil {
-			return fmt.Errorf("error marking diff %s: %w", storage.Unwatched, markUnwatchedErr)
-		}
-		return nil
+func (watcher StorageWatcher) transformDiff(t storage2.ITransformer, diff types.PersistedDiff) error {
+	hasPendingPredecessor, predecessorErr := watcher.StorageDiffRepository.HasPendingHeaderPredecessor(diff)
+	if predecessorErr != nil {
+		return fmt.Errorf("error checking for pending predecessors of diff: %w", predecessorErr)
+	}
+	if hasPendingPredecessor {
+		return watcher.markPendingHeader(diff)
 	}
 
 	headerID, headerErr := watcher.getHeaderID(diff)
@@ -152,6 +305,9 @@ func (watcher StorageWatcher) transformDiff(diff types.PersistedDiff) error {
 		if errors.Is(headerErr, ErrHeaderMismatch) {
 			return watcher.handleDiffWithInvalidHeaderHash(diff)
 		}
+		if errors.Is(headerErr, sql.ErrNoRows) {
+			return watcher.markPendingHeader(diff)
+		}
 		return fmt.Errorf("error getting header for diff: %w", headerErr)
 	}
 	diff.HeaderID = headerID
@@ -169,11 +325,6 @@ func (watcher StorageWatcher) transformDiff(diff types.PersistedDiff) error {
 	return nil
 }
 
-func (watcher StorageWatcher) getTransformer(diff types.PersistedDiff) (storage2.ITransformer, bool) {
-	storageTransformer, ok := watcher.AddressTransformers[diff.Address]
-	return storageTransformer, ok
-}
-
 func (watcher StorageWatcher) getHeaderID(diff types.PersistedDiff) (int64, error) {
 	header, getHeaderErr := watcher.HeaderRepository.GetHeaderByBlockNumber(int64(diff.BlockHeight))
 	if getHeaderErr != nil {
@@ -187,6 +338,14 @@ func (watcher StorageWatcher) getHeaderID(diff types.PersistedDiff) (int64, erro
 	return header.Id, nil
 }
 
+func (watcher StorageWatcher) markPendingHeader(diff types.PersistedDiff) error {
+	markPendingErr := watcher.StorageDiffRepository.MarkPendingHeader(diff.ID)
+	if markPendingErr != nil {
+		return fmt.Errorf("error marking diff %s: %w", storage.PendingHeader, markPendingErr)
+	}
+	return nil
+}
+
 func (watcher StorageWatcher) handleDiffWithInvalidHeaderHash(diff types.PersistedDiff) error {
 	maxBlock, maxBlockErr := watcher.HeaderRepository.GetMostRecentHeaderBlockNumber()
 	if maxBlockErr != nil {
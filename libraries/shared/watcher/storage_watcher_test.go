@@ -20,10 +20,12 @@ import (
 	"database/sql"
 	"errors"
 	"math/rand"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/makerdao/vulcanizedb/libraries/shared/factories/storage"
 	"github.com/makerdao/vulcanizedb/libraries/shared/mocks"
+	storage2 "github.com/makerdao/vulcanizedb/libraries/shared/storage"
 	"github.com/makerdao/vulcanizedb/libraries/shared/storage/types"
 	"github.com/makerdao/vulcanizedb/libraries/shared/test_data"
 	"github.com/makerdao/vulcanizedb/libraries/shared/watcher"
@@ -31,6 +33,8 @@ import (
 	"github.com/makerdao/vulcanizedb/test_config"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
 )
 
 var _ = Describe("Storage Watcher", func() {
@@ -52,6 +56,8 @@ var _ = Describe("Storage Watcher", func() {
 			storageWatcher       watcher.StorageWatcher
 			mockDiffsRepository  *mocks.MockStorageDiffRepository
 			mockHeaderRepository *fakes.MockHeaderRepository
+			contractAddress      common.Address
+			mockTransformer      *mocks.MockStorageTransformer
 		)
 
 		BeforeEach(func() {
@@ -60,10 +66,17 @@ var _ = Describe("Storage Watcher", func() {
 			storageWatcher = watcher.NewStorageWatcher(test_config.NewTestDB(test_config.NewTestNode()), -1, &statusWriter)
 			storageWatcher.HeaderRepository = mockHeaderRepository
 			storageWatcher.StorageDiffRepository = mockDiffsRepository
+			// a single worker takes partitions in order: unwatched diffs first, then the watched contract
+			storageWatcher.Workers = 1
+			// fail on a partition's first error unless a test enables retries
+			storageWatcher.PartitionRetries = 0
+			contractAddress = test_data.FakeAddress()
+			mockTransformer = &mocks.MockStorageTransformer{Address: contractAddress}
+			storageWatcher.AddTransformers([]storage.TransformerInitializer{mockTransformer.FakeTransformerInitializer})
 		})
 
 		It("creates file for health check", func() {
-			mockDiffsRepository.GetNewDiffsErrors = []error{fakes.FakeError}
+			mockDiffsRepository.MarkUnwatchedExceptErr = fakes.FakeError
 
 			err := storageWatcher.Execute()
 
@@ -71,192 +84,193 @@ var _ = Describe("Storage Watcher", func() {
 			Expect(statusWriter.WriteCalled).To(BeTrue())
 		})
 
-		It("fetches diffs with results limit", func() {
-			mockDiffsRepository.GetNewDiffsErrors = []error{fakes.FakeError}
+		It("marks diffs as unwatched if no transformer is watching their address", func() {
+			mockDiffsRepository.MarkUnwatchedExceptErr = fakes.FakeError
 
 			err := storageWatcher.Execute()
 
 			Expect(err).To(HaveOccurred())
-			Expect(mockDiffsRepository.GetNewDiffsPassedLimits).To(ConsistOf(watcher.ResultsLimit))
+			Expect(err).To(MatchError(fakes.FakeError))
+			Expect(mockDiffsRepository.MarkUnwatchedExceptPassedAddresses).To(Equal([]common.Address{contractAddress}))
+		})
+
+		It("fetches diffs for each watched address with results limit", func() {
+			mockDiffsRepository.GetNewDiffsForAddressErrors = []error{fakes.FakeError}
+
+			err := storageWatcher.Execute()
+
+			Expect(err).To(HaveOccurred())
+			Expect(mockDiffsRepository.GetNewDiffsForAddressPassedAddresses).To(Equal([]common.Address{contractAddress}))
+			Expect(mockDiffsRepository.GetNewDiffsForAddressPassedLimits).To(ConsistOf(watcher.ResultsLimit))
+		})
+
+		It("releases the address's diffs pending header before fetching diffs", func() {
+			mockDiffsRepository.GetNewDiffsForAddressErrors = []error{fakes.FakeError}
+
+			err := storageWatcher.Execute()
+
+			Expect(err).To(HaveOccurred())
+			Expect(mockDiffsRepository.ReleasePendingHeaderDiffsPassedAddresses).To(Equal([]common.Address{contractAddress}))
 		})
 
-		It("fetches diffs with min ID from subsequent queries when previous query returns max results", func() {
+		Describe("when a partition fails", func() {
+			BeforeEach(func() {
+				storageWatcher.PartitionRetries = 2
+				storageWatcher.PartitionRetryInterval = time.Millisecond
+			})
+
+			It("retries the partition until it has failed more than the configured number of times", func() {
+				mockDiffsRepository.ReleasePendingHeaderDiffsErr = fakes.FakeError
+
+				err := storageWatcher.Execute()
+
+				Expect(err).To(MatchError(fakes.FakeError))
+				Expect(mockDiffsRepository.ReleasePendingHeaderDiffsPassedAddresses).To(Equal([]common.Address{contractAddress, contractAddress, contractAddress}))
+			})
+
+			It("keeps transforming other partitions while the failing one waits to be retried", func() {
+				mockDiffsRepository.MarkUnwatchedExceptErr = fakes.FakeError
+				mockDiffsRepository.GetNewDiffsForAddressErrors = []error{nil}
+
+				err := storageWatcher.Execute()
+
+				Expect(err).To(MatchError(fakes.FakeError))
+				Expect(len(mockDiffsRepository.GetNewDiffsForAddressPassedAddresses)).To(BeNumerically(">", 1))
+			})
+
+			It("logs each contract's storage key lookup stats while it runs", func() {
+				hook := test.NewLocal(logrus.StandardLogger())
+				defer logrus.StandardLogger().ReplaceHooks(make(logrus.LevelHooks))
+				mockTransformer.StorageKeysLookup = &mocks.MockStorageKeysLookup{
+					StatsToReturn: storage.KeysLookupStats{Hits: 3, Misses: 2, Refreshes: 1},
+				}
+				storageWatcher.StatsInterval = time.Millisecond
+				storageWatcher.PartitionRetryInterval = 10 * time.Millisecond
+				mockDiffsRepository.ReleasePendingHeaderDiffsErr = fakes.FakeError
+
+				err := storageWatcher.Execute()
+
+				Expect(err).To(MatchError(fakes.FakeError))
+				var messages []string
+				for _, entry := range hook.AllEntries() {
+					messages = append(messages, entry.Message)
+				}
+				Expect(messages).To(ContainElement(ContainSubstring(
+					"looked up storage keys for " + contractAddress.Hex() + " in 1ms: 3 hits, 2 misses, 1 refreshes")))
+			})
+		})
+
+		It("returns an error if releasing diffs pending header fails", func() {
+			mockDiffsRepository.ReleasePendingHeaderDiffsErr = fakes.FakeError
+
+			err := storageWatcher.Execute()
+
+			Expect(err).To(HaveOccurred())
+			Expect(err).To(MatchError(fakes.FakeError))
+			Expect(mockDiffsRepository.GetNewDiffsForAddressPassedCursors).To(BeEmpty())
+		})
+
+		It("fetches diffs after the last diff from subsequent queries when previous query returns max results", func() {
 			var diffs []types.PersistedDiff
-			diffID := rand.Int()
+			diffID := rand.Int63()
+			blockHeight := rand.Int()
 			for i := 0; i < watcher.ResultsLimit; i++ {
-				diffID = diffID + i
 				diff := types.PersistedDiff{
 					RawDiff: types.RawDiff{
-						Address: test_data.FakeAddress(),
+						Address:     contractAddress,
+						BlockHeight: blockHeight + i,
 					},
-					ID: int64(diffID),
+					ID: diffID + int64(i),
 				}
 				diffs = append(diffs, diff)
 			}
-			mockDiffsRepository.GetNewDiffsDiffs = diffs
-			mockDiffsRepository.GetNewDiffsErrors = []error{nil, fakes.FakeError}
+			mockDiffsRepository.GetNewDiffsForAddressDiffs = diffs
+			mockDiffsRepository.GetNewDiffsForAddressErrors = []error{nil, fakes.FakeError}
 
 			err := storageWatcher.Execute()
 
 			Expect(err).To(HaveOccurred())
-			Expect(mockDiffsRepository.GetNewDiffsPassedMinIDs).To(ConsistOf(0, diffID))
+			lastDiff := diffs[len(diffs)-1]
+			expectedSecondCursor := storage2.DiffCursor{BlockHeight: int64(lastDiff.BlockHeight), ID: lastDiff.ID}
+			Expect(mockDiffsRepository.GetNewDiffsForAddressPassedCursors).To(ConsistOf(storage2.NewDiffCursor(0), expectedSecondCursor))
 		})
 
-		It("resets min ID to zero when previous query returns fewer than max results", func() {
+		It("resets cursor when previous query returns fewer than max results", func() {
 			var diffs []types.PersistedDiff
-			diffID := rand.Int()
+			diffID := rand.Int63()
 			for i := 0; i < watcher.ResultsLimit-1; i++ {
-				diffID = diffID + i
 				diff := types.PersistedDiff{
 					RawDiff: types.RawDiff{
-						Address: test_data.FakeAddress(),
+						Address: contractAddress,
 					},
-					ID: int64(diffID),
+					ID: diffID + int64(i),
 				}
 				diffs = append(diffs, diff)
 			}
-			mockDiffsRepository.GetNewDiffsDiffs = diffs
-			mockDiffsRepository.GetNewDiffsErrors = []error{nil, fakes.FakeError}
+			mockDiffsRepository.GetNewDiffsForAddressDiffs = diffs
+			mockDiffsRepository.GetNewDiffsForAddressErrors = []error{nil, fakes.FakeError}
 
 			err := storageWatcher.Execute()
 
 			Expect(err).To(HaveOccurred())
-			Expect(mockDiffsRepository.GetNewDiffsPassedMinIDs).To(ConsistOf(0, 0))
-		})
-
-		It("marks diff as unwatched if no transformer is watching its address", func() {
-			unwatchedDiff := types.PersistedDiff{
-				RawDiff: types.RawDiff{
-					Address: test_data.FakeAddress(),
-				},
-				ID: rand.Int63(),
-			}
-			mockDiffsRepository.GetNewDiffsDiffs = []types.PersistedDiff{unwatchedDiff}
-			mockDiffsRepository.GetNewDiffsErrors = []error{nil, fakes.FakeError}
-
-			err := storageWatcher.Execute()
-
-			Expect(err).To(HaveOccurred())
-			Expect(err).To(MatchError(fakes.FakeError))
-			Expect(mockDiffsRepository.MarkUnwatchedPassedID).To(Equal(unwatchedDiff.ID))
+			Expect(mockDiffsRepository.GetNewDiffsForAddressPassedCursors).To(ConsistOf(storage2.NewDiffCursor(0), storage2.NewDiffCursor(0)))
 		})
 
 		Describe("When the watcher is configured to skip old diffs", func() {
-			var diffs []types.PersistedDiff
 			var numberOfBlocksFromHeadOfChain = int64(500)
 
 			BeforeEach(func() {
-				storageWatcher = watcher.StorageWatcher{
-					HeaderRepository:          mockHeaderRepository,
-					StorageDiffRepository:     mockDiffsRepository,
-					AddressTransformers:       map[common.Address]storage.ITransformer{},
-					DiffBlocksFromHeadOfChain: numberOfBlocksFromHeadOfChain,
-					StatusWriter:              &statusWriter,
-				}
-				diffID := rand.Int()
-				for i := 0; i < watcher.ResultsLimit; i++ {
-					diffID = diffID + i
-					diff := types.PersistedDiff{
-						RawDiff: types.RawDiff{
-							Address: test_data.FakeAddress(),
-						},
-						ID: int64(diffID),
-					}
-					diffs = append(diffs, diff)
-				}
+				storageWatcher.DiffBlocksFromHeadOfChain = numberOfBlocksFromHeadOfChain
+				mockDiffsRepository.ReleasePendingHeaderDiffsHeightToReturn = -1
+				mockDiffsRepository.GetNewDiffsForAddressErrors = []error{fakes.FakeError}
 			})
 
 			It("skips diffs that are from a block more than n from the head of the chain", func() {
 				headerBlockNumber := rand.Int63()
 				mockHeaderRepository.MostRecentHeaderBlockNumber = headerBlockNumber
 
-				mockDiffsRepository.GetFirstDiffIDToReturn = diffs[0].ID
-				mockDiffsRepository.GetNewDiffsDiffs = diffs
-				mockDiffsRepository.GetNewDiffsErrors = []error{nil, fakes.FakeError}
-
-				expectedFirstMinDiffID := int(diffs[0].ID - 1)
-				expectedSecondMinDiffID := int(diffs[len(diffs)-1].ID)
-
 				err := storageWatcher.Execute()
 
 				Expect(err).To(HaveOccurred())
 				Expect(err).To(MatchError(fakes.FakeError))
-				Expect(mockDiffsRepository.GetFirstDiffBlockHeightPassed).To(Equal(headerBlockNumber - numberOfBlocksFromHeadOfChain))
-				Expect(mockDiffsRepository.GetNewDiffsPassedMinIDs).To(ConsistOf(expectedFirstMinDiffID, expectedSecondMinDiffID))
+				expectedCursor := storage2.NewDiffCursor(headerBlockNumber - numberOfBlocksFromHeadOfChain)
+				Expect(mockDiffsRepository.GetNewDiffsForAddressPassedCursors).To(ConsistOf(expectedCursor))
 			})
 
-			It("resets min ID back to new min diff when previous query returns fewer than max results", func() {
-				var diffs []types.PersistedDiff
-				diffID := rand.Int()
-				for i := 0; i < watcher.ResultsLimit-1; i++ {
-					diffID = diffID + i
-					diff := types.PersistedDiff{
-						RawDiff: types.RawDiff{
-							Address: test_data.FakeAddress(),
-						},
-						ID: int64(diffID),
-					}
-					diffs = append(diffs, diff)
-				}
-
+			It("rewinds cursor to include diffs released from pending header", func() {
 				headerBlockNumber := rand.Int63()
 				mockHeaderRepository.MostRecentHeaderBlockNumber = headerBlockNumber
-
-				mockDiffsRepository.GetFirstDiffIDToReturn = diffs[0].ID
-				mockDiffsRepository.GetNewDiffsDiffs = diffs
-				mockDiffsRepository.GetNewDiffsErrors = []error{nil, fakes.FakeError}
-
-				expectedFirstMinDiffID := int(diffs[0].ID - 1)
+				releasedBlockHeight := headerBlockNumber - numberOfBlocksFromHeadOfChain - 10
+				mockDiffsRepository.ReleasePendingHeaderDiffsHeightToReturn = releasedBlockHeight
 
 				err := storageWatcher.Execute()
 
 				Expect(err).To(HaveOccurred())
-				Expect(err).To(MatchError(fakes.FakeError))
-				Expect(mockDiffsRepository.GetFirstDiffBlockHeightPassed).To(Equal(headerBlockNumber - numberOfBlocksFromHeadOfChain))
-				Expect(mockDiffsRepository.GetNewDiffsPassedMinIDs).To(ConsistOf(expectedFirstMinDiffID, expectedFirstMinDiffID))
+				Expect(mockDiffsRepository.GetNewDiffsForAddressPassedCursors).To(ConsistOf(storage2.NewDiffCursor(releasedBlockHeight)))
 			})
 
-			It("sets minID to 0 if there are no headers with the given block height", func() {
+			It("starts from block zero if there are no headers", func() {
 				mockHeaderRepository.MostRecentHeaderBlockNumberErr = sql.ErrNoRows
-				mockDiffsRepository.GetNewDiffsDiffs = diffs
-				mockDiffsRepository.GetNewDiffsErrors = []error{nil, fakes.FakeError}
+
 				err := storageWatcher.Execute()
 
 				Expect(err).To(HaveOccurred())
 				Expect(err).To(MatchError(fakes.FakeError))
-
-				expectedFirstMinDiffID := 0
-				expectedSecondMinDiffID := int(diffs[len(diffs)-1].ID)
-				Expect(mockDiffsRepository.GetNewDiffsPassedMinIDs).To(ConsistOf(expectedFirstMinDiffID, expectedSecondMinDiffID))
+				Expect(mockDiffsRepository.GetNewDiffsForAddressPassedCursors).To(ConsistOf(storage2.NewDiffCursor(0)))
 			})
 
-			It("sets minID to 0 if there are no diffs with given block range", func() {
-				mockDiffsRepository.GetFirstDiffIDErr = sql.ErrNoRows
-				mockDiffsRepository.GetNewDiffsDiffs = diffs
-				mockDiffsRepository.GetNewDiffsErrors = []error{nil, fakes.FakeError}
+			It("returns an error if getting the most recent header fails", func() {
+				mockHeaderRepository.MostRecentHeaderBlockNumberErr = fakes.FakeError
+
 				err := storageWatcher.Execute()
 
 				Expect(err).To(HaveOccurred())
 				Expect(err).To(MatchError(fakes.FakeError))
-
-				expectedFirstMinDiffID := 0
-				expectedSecondMinDiffID := int(diffs[len(diffs)-1].ID)
-				Expect(mockDiffsRepository.GetNewDiffsPassedMinIDs).To(ConsistOf(expectedFirstMinDiffID, expectedSecondMinDiffID))
+				Expect(mockDiffsRepository.GetNewDiffsForAddressPassedCursors).To(BeEmpty())
 			})
 		})
 
 		Describe("when diff's address is watched", func() {
-			var (
-				contractAddress common.Address
-				mockTransformer *mocks.MockStorageTransformer
-			)
-
-			BeforeEach(func() {
-				contractAddress = test_data.FakeAddress()
-				mockTransformer = &mocks.MockStorageTransformer{Address: contractAddress}
-				storageWatcher.AddTransformers([]storage.TransformerInitializer{mockTransformer.FakeTransformerInitializer})
-			})
-
 			It("does not mark diff checked if no matching header", func() {
 				diffWithoutHeader := types.PersistedDiff{
 					RawDiff: types.RawDiff{
@@ -266,8 +280,8 @@ var _ = Describe("Storage Watcher", func() {
 					},
 					ID: rand.Int63(),
 				}
-				mockDiffsRepository.GetNewDiffsDiffs = []types.PersistedDiff{diffWithoutHeader}
-				mockDiffsRepository.GetNewDiffsErrors = []error{nil, fakes.FakeError}
+				mockDiffsRepository.GetNewDiffsForAddressDiffs = []types.PersistedDiff{diffWithoutHeader}
+				mockDiffsRepository.GetNewDiffsForAddressErrors = []error{nil, fakes.FakeError}
 				mockHeaderRepository.GetHeaderByBlockNumberError = errors.New("no matching header")
 
 				err := storageWatcher.Execute()
@@ -277,6 +291,50 @@ var _ = Describe("Storage Watcher", func() {
 				Expect(mockDiffsRepository.MarkCheckedPassedID).NotTo(Equal(diffWithoutHeader.ID))
 			})
 
+			It("marks diff pending header if its block has no header yet", func() {
+				diffWithoutHeader := types.PersistedDiff{
+					RawDiff: types.RawDiff{
+						Address:     contractAddress,
+						BlockHash:   test_data.FakeHash(),
+						BlockHeight: rand.Int(),
+					},
+					ID: rand.Int63(),
+				}
+				mockDiffsRepository.GetNewDiffsForAddressDiffs = []types.PersistedDiff{diffWithoutHeader}
+				mockDiffsRepository.GetNewDiffsForAddressErrors = []error{nil, fakes.FakeError}
+				mockHeaderRepository.GetHeaderByBlockNumberError = sql.ErrNoRows
+
+				err := storageWatcher.Execute()
+
+				Expect(err).To(HaveOccurred())
+				Expect(err).To(MatchError(fakes.FakeError))
+				Expect(mockDiffsRepository.MarkPendingHeaderPassedIDs).To(ConsistOf(diffWithoutHeader.ID))
+				Expect(mockTransformer.PassedDiff).To(BeZero())
+			})
+
+			It("marks diff pending header if an earlier diff for the same slot is pending header", func() {
+				fakeBlockHash := test_data.FakeHash()
+				laterDiff := types.PersistedDiff{
+					RawDiff: types.RawDiff{
+						Address:   contractAddress,
+						BlockHash: fakeBlockHash,
+					},
+					ID: rand.Int63(),
+				}
+				mockHeaderRepository.GetHeaderByBlockNumberReturnHash = fakeBlockHash.Hex()
+				mockDiffsRepository.GetNewDiffsForAddressDiffs = []types.PersistedDiff{laterDiff}
+				mockDiffsRepository.GetNewDiffsForAddressErrors = []error{nil, fakes.FakeError}
+				mockDiffsRepository.HasPendingHeaderPredecessorToReturn = true
+
+				err := storageWatcher.Execute()
+
+				Expect(err).To(HaveOccurred())
+				Expect(err).To(MatchError(fakes.FakeError))
+				Expect(mockDiffsRepository.HasPendingHeaderPredecessorPassedDiffs).To(ConsistOf(laterDiff))
+				Expect(mockDiffsRepository.MarkPendingHeaderPassedIDs).To(ConsistOf(laterDiff.ID))
+				Expect(mockTransformer.PassedDiff).To(BeZero())
+			})
+
 			Describe("when non-matching header found", func() {
 				var (
 					blockNumber       int
@@ -299,12 +357,12 @@ var _ = Describe("Storage Watcher", func() {
 						RawDiff: fakeRawDiff,
 						ID:      rand.Int63(),
 					}
-					mockDiffsRepository.GetNewDiffsDiffs = []types.PersistedDiff{fakePersistedDiff}
+					mockDiffsRepository.GetNewDiffsForAddressDiffs = []types.PersistedDiff{fakePersistedDiff}
 				})
 
 				It("does not mark diff checked if getting max known block height fails", func() {
 					mockHeaderRepository.MostRecentHeaderBlockNumberErr = errors.New("getting max header failed")
-					mockDiffsRepository.GetNewDiffsErrors = []error{nil, fakes.FakeError}
+					mockDiffsRepository.GetNewDiffsForAddressErrors = []error{nil, fakes.FakeError}
 
 					err := storageWatcher.Execute()
 
@@ -315,7 +373,7 @@ var _ = Describe("Storage Watcher", func() {
 
 				It("marks diff noncanonical if block height less than max known block height minus reorg window", func() {
 					mockHeaderRepository.MostRecentHeaderBlockNumber = int64(blockNumber + watcher.ReorgWindow + 1)
-					mockDiffsRepository.GetNewDiffsErrors = []error{nil, fakes.FakeError}
+					mockDiffsRepository.GetNewDiffsForAddressErrors = []error{nil, fakes.FakeError}
 
 					err := storageWatcher.Execute()
 
@@ -326,7 +384,7 @@ var _ = Describe("Storage Watcher", func() {
 
 				It("does not mark diff checked if block height is within reorg window", func() {
 					mockHeaderRepository.MostRecentHeaderBlockNumber = int64(blockNumber + watcher.ReorgWindow)
-					mockDiffsRepository.GetNewDiffsErrors = []error{nil, fakes.FakeError}
+					mockDiffsRepository.GetNewDiffsForAddressErrors = []error{nil, fakes.FakeError}
 
 					err := storageWatcher.Execute()
 
@@ -353,12 +411,12 @@ var _ = Describe("Storage Watcher", func() {
 						RawDiff: fakeRawDiff,
 						ID:      rand.Int63(),
 					}
-					mockDiffsRepository.GetNewDiffsDiffs = []types.PersistedDiff{fakePersistedDiff}
+					mockDiffsRepository.GetNewDiffsForAddressDiffs = []types.PersistedDiff{fakePersistedDiff}
 				})
 
 				It("does not mark diff checked if transformer execution fails", func() {
 					mockTransformer.ExecuteErr = errors.New("execute failed")
-					mockDiffsRepository.GetNewDiffsErrors = []error{nil, fakes.FakeError}
+					mockDiffsRepository.GetNewDiffsForAddressErrors = []error{nil, fakes.FakeError}
 
 					err := storageWatcher.Execute()
 
@@ -369,7 +427,7 @@ var _ = Describe("Storage Watcher", func() {
 
 				It("marks diff as 'unrecognized' when transforming the diff returns a ErrKeyNotFound error", func() {
 					mockTransformer.ExecuteErr = types.ErrKeyNotFound
-					mockDiffsRepository.GetNewDiffsErrors = []error{nil, types.ErrKeyNotFound}
+					mockDiffsRepository.GetNewDiffsForAddressErrors = []error{nil, types.ErrKeyNotFound}
 
 					err := storageWatcher.Execute()
 
@@ -379,8 +437,8 @@ var _ = Describe("Storage Watcher", func() {
 				})
 
 				It("marks diff checked if transformer execution doesn't fail", func() {
-					mockDiffsRepository.GetNewDiffsDiffs = []types.PersistedDiff{fakePersistedDiff}
-					mockDiffsRepository.GetNewDiffsErrors = []error{nil, fakes.FakeError}
+					mockDiffsRepository.GetNewDiffsForAddressDiffs = []types.PersistedDiff{fakePersistedDiff}
+					mockDiffsRepository.GetNewDiffsForAddressErrors = []error{nil, fakes.FakeError}
 
 					err := storageWatcher.Execute()
 
@@ -35,9 +35,18 @@ type ContractConfig struct {
 	Addresses map[string]bool
 
 	// Map of contract address to abi
-	// If an address has no associated abi the parser will attempt to fetch one from etherscan
+	// If an address has no associated abi the parser will attempt to find one in the abi registry, or fetch one from etherscan
 	Abis map[string]string
 
+	// Directory of <address>.json abi files, consulted (along with the contract_abi table) before etherscan
+	AbiDirectory string
+
+	// Whether to never fetch abis from etherscan
+	Offline bool
+
+	// Whether event tables may drop or retype columns when a contract's abi changes
+	AllowDestructiveMigrations bool
+
 	// Map of contract address to slice of events
 	// Used to set which addresses to watch
 	// If any events are listed in the slice only those will be watched
@@ -49,18 +58,75 @@ type ContractConfig struct {
 	// Otherwise arguments are not filtered on events
 	EventArgs map[string][]string
 
+	// Map of contract address to a map of event name to the filter expression its logs must match
+	// Event names are lower cased, since viper lower cases map keys
+	EventFilters map[string]map[string]string
+
+	// Map of contract address to a map of anonymous event name to the layout identifying its logs
+	// Event names are lower cased, since viper lower cases map keys
+	AnonymousEvents map[string]map[string]AnonymousEvent
+
 	// Map of contract address to their starting block
 	StartingBlocks map[string]int64
+
+	// Map of contract address to the last block they're watched at; -1 if they're watched indefinitely
+	EndingBlocks map[string]int64
+
+	// Map of contract address to slice of methods to poll
+	// Methods must be view functions returning a single value, taking either no arguments
+	// or addresses and bytes32 values, which are filled in with values emitted by the watched events since the last poll
+	Methods map[string][]string
+
+	// Map of contract address to the number of blocks between method polls
+	MethodIntervals map[string]int64
+
+	// Map of contract address to the maximum number of calls made to each polled method at a header; 0 if unbounded
+	MaxMethodCalls map[string]int64
+
+	// Map of contract address to slice of methods whose calls to the contract are decoded from transaction input
+	Calls map[string][]string
+}
+
+// CapEndingBlocks bounds the ending block of every contract by the given block, so that contracts watched indefinitely
+// are watched up to it
+func (contractConfig *ContractConfig) CapEndingBlocks(block int64) {
+	if contractConfig.EndingBlocks == nil {
+		contractConfig.EndingBlocks = make(map[string]int64, len(contractConfig.Addresses))
+	}
+	for addr := range contractConfig.Addresses {
+		end, ok := contractConfig.EndingBlocks[addr]
+		if !ok || end == -1 || end > block {
+			contractConfig.EndingBlocks[addr] = block
+		}
+	}
+}
+
+// AnonymousEvent is the configured layout of an anonymous event's logs, which have no signature topic to be matched by
+type AnonymousEvent struct {
+	// Hex values allowed at each topic position; empty positions allow any value
+	Topics [][]string
+
+	// Length of the log data in bytes; if zero, it's derived from the event's fields
+	DataLength int64
 }
 
 func (contractConfig *ContractConfig) PrepConfig() {
 	addrs := viper.GetStringSlice("contract.addresses")
 	contractConfig.Network = viper.GetString("contract.network")
+	contractConfig.AbiDirectory = viper.GetString("contract.abiDirectory")
+	contractConfig.Offline = viper.GetBool("contract.offline")
 	contractConfig.Addresses = make(map[string]bool, len(addrs))
 	contractConfig.Abis = make(map[string]string, len(addrs))
 	contractConfig.Events = make(map[string][]string, len(addrs))
 	contractConfig.EventArgs = make(map[string][]string, len(addrs))
+	contractConfig.EventFilters = make(map[string]map[string]string, len(addrs))
+	contractConfig.AnonymousEvents = make(map[string]map[string]AnonymousEvent, len(addrs))
 	contractConfig.StartingBlocks = make(map[string]int64, len(addrs))
+	contractConfig.EndingBlocks = make(map[string]int64, len(addrs))
+	contractConfig.Methods = make(map[string][]string, len(addrs))
+	contractConfig.MethodIntervals = make(map[string]int64, len(addrs))
+	contractConfig.MaxMethodCalls = make(map[string]int64, len(addrs))
+	contractConfig.Calls = make(map[string][]string, len(addrs))
 	// De-dupe addresses
 	for _, addr := range addrs {
 		contractConfig.Addresses[strings.ToLower(addr)] = true
@@ -74,7 +140,7 @@ func (contractConfig *ContractConfig) PrepConfig() {
 		var abi string
 		abiInterface, abiOK := transformer["abi"]
 		if !abiOK {
-			log.Warnf("contract %s not configured with an ABI, will attempt to fetch it from Etherscan\r\n", addr)
+			log.Warnf("contract %s not configured with an ABI, will attempt to find it in the ABI registry or fetch it from Etherscan\r\n", addr)
 		} else {
 			abi, abiOK = abiInterface.(string)
 			if !abiOK {
@@ -130,6 +196,38 @@ func (contractConfig *ContractConfig) PrepConfig() {
 		}
 		contractConfig.EventArgs[strings.ToLower(addr)] = eventArgs
 
+		// Get and check filters
+		filters := make(map[string]string)
+		filtersInterface, filtersOK := transformer["filters"]
+		if filtersOK {
+			filtersI, filtersOK := filtersInterface.(map[string]interface{})
+			if !filtersOK {
+				log.Fatal(addr, "transformer `filters` not a table of event names to expressions\r\n")
+			}
+			for event, exprI := range filtersI {
+				expr, exprOK := exprI.(string)
+				if !exprOK {
+					log.Fatal(addr, "transformer `filters` not a table of event names to expressions\r\n")
+				}
+				filters[strings.ToLower(event)] = expr
+			}
+		}
+		contractConfig.EventFilters[strings.ToLower(addr)] = filters
+
+		// Get and check anonymous event layouts
+		anonymousEvents := make(map[string]AnonymousEvent)
+		anonymousInterface, anonymousOK := transformer["anonymous"]
+		if anonymousOK {
+			anonymousI, anonymousOK := anonymousInterface.(map[string]interface{})
+			if !anonymousOK {
+				log.Fatal(addr, "transformer `anonymous` not a table of event names to layouts\r\n")
+			}
+			for event, layoutI := range anonymousI {
+				anonymousEvents[strings.ToLower(event)] = anonymousEvent(addr, event, layoutI)
+			}
+		}
+		contractConfig.AnonymousEvents[strings.ToLower(addr)] = anonymousEvents
+
 		// Get and check startingBlock
 		startInterface, startOK := transformer["startingblock"]
 		if !startOK {
@@ -140,5 +238,112 @@ func (contractConfig *ContractConfig) PrepConfig() {
 			log.Fatal(addr, "transformer `startingBlock` not of type int\r\n")
 		}
 		contractConfig.StartingBlocks[strings.ToLower(addr)] = start
+
+		// Get and check endingBlock; defaults to watching indefinitely
+		end := int64(-1)
+		endInterface, endOK := transformer["endingblock"]
+		if endOK {
+			end, endOK = endInterface.(int64)
+			if !endOK || end < start {
+				log.Fatal(addr, "transformer `endingBlock` not an int at or after `startingBlock`\r\n")
+			}
+		}
+		contractConfig.EndingBlocks[strings.ToLower(addr)] = end
+
+		// Get and check methods
+		methods := make([]string, 0)
+		methodsInterface, methodsOK := transformer["methods"]
+		if methodsOK {
+			methodsI, methodsOK := methodsInterface.([]interface{})
+			if !methodsOK {
+				log.Fatal(addr, "transformer `methods` not of type []string\r\n")
+			}
+			for _, strI := range methodsI {
+				str, strOK := strI.(string)
+				if !strOK {
+					log.Fatal(addr, "transformer `methods` not of type []string\r\n")
+				}
+				methods = append(methods, str)
+			}
+		}
+		contractConfig.Methods[strings.ToLower(addr)] = methods
+
+		// Get and check methodInterval; defaults to polling at every header
+		interval := int64(1)
+		intervalInterface, intervalOK := transformer["methodinterval"]
+		if intervalOK {
+			interval, intervalOK = intervalInterface.(int64)
+			if !intervalOK || interval < 1 {
+				log.Fatal(addr, "transformer `methodInterval` not a positive int\r\n")
+			}
+		}
+		contractConfig.MethodIntervals[strings.ToLower(addr)] = interval
+
+		// Get and check maxMethodCalls; absent contracts get the default bound when initialized
+		maxCallsInterface, maxCallsOK := transformer["maxmethodcalls"]
+		if maxCallsOK {
+			maxCalls, maxCallsOK := maxCallsInterface.(int64)
+			if !maxCallsOK || maxCalls < 0 {
+				log.Fatal(addr, "transformer `maxMethodCalls` not a non-negative int\r\n")
+			}
+			contractConfig.MaxMethodCalls[strings.ToLower(addr)] = maxCalls
+		}
+
+		// Get and check calls
+		calls := make([]string, 0)
+		callsInterface, callsOK := transformer["calls"]
+		if callsOK {
+			callsI, callsOK := callsInterface.([]interface{})
+			if !callsOK {
+				log.Fatal(addr, "transformer `calls` not of type []string\r\n")
+			}
+			for _, strI := range callsI {
+				str, strOK := strI.(string)
+				if !strOK {
+					log.Fatal(addr, "transformer `calls` not of type []string\r\n")
+				}
+				calls = append(calls, str)
+			}
+		}
+		contractConfig.Calls[strings.ToLower(addr)] = calls
 	}
 }
+
+func anonymousEvent(addr, event string, layoutInterface interface{}) AnonymousEvent {
+	var anonymous AnonymousEvent
+	layout, layoutOK := layoutInterface.(map[string]interface{})
+	if !layoutOK {
+		log.Fatal(addr, "transformer `anonymous` layout of ", event, " not a table\r\n")
+	}
+
+	if topicsInterface, topicsOK := layout["topics"]; topicsOK {
+		topicsI, topicsOK := topicsInterface.([]interface{})
+		if !topicsOK {
+			log.Fatal(addr, "transformer `anonymous` topics of ", event, " not of type [][]string\r\n")
+		}
+		for _, positionI := range topicsI {
+			valuesI, valuesOK := positionI.([]interface{})
+			if !valuesOK {
+				log.Fatal(addr, "transformer `anonymous` topics of ", event, " not of type [][]string\r\n")
+			}
+			values := make([]string, 0, len(valuesI))
+			for _, strI := range valuesI {
+				str, strOK := strI.(string)
+				if !strOK {
+					log.Fatal(addr, "transformer `anonymous` topics of ", event, " not of type [][]string\r\n")
+				}
+				values = append(values, str)
+			}
+			anonymous.Topics = append(anonymous.Topics, values)
+		}
+	}
+
+	if lengthInterface, lengthOK := layout["datalength"]; lengthOK {
+		anonymous.DataLength, lengthOK = lengthInterface.(int64)
+		if !lengthOK || anonymous.DataLength < 0 {
+			log.Fatal(addr, "transformer `anonymous` dataLength of ", event, " not a non-negative int\r\n")
+		}
+	}
+
+	return anonymous
+}
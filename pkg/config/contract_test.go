@@ -0,0 +1,38 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package config_test
+
+import (
+	"github.com/makerdao/vulcanizedb/pkg/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Contract config", func() {
+	Describe("CapEndingBlocks", func() {
+		It("Bounds the ending block of every contract by the given block", func() {
+			contractConfig := config.ContractConfig{
+				Addresses:    map[string]bool{"0x1": true, "0x2": true, "0x3": true, "0x4": true},
+				EndingBlocks: map[string]int64{"0x1": -1, "0x2": 50, "0x3": 200},
+			}
+
+			contractConfig.CapEndingBlocks(100)
+
+			Expect(contractConfig.EndingBlocks).To(Equal(map[string]int64{"0x1": 100, "0x2": 50, "0x3": 100, "0x4": 100}))
+		})
+	})
+})
@@ -112,6 +112,7 @@ const (
 	EthEvent
 	EthStorage
 	EthContract
+	EthAccount
 )
 
 func (transformerType TransformerType) String() string {
@@ -120,9 +121,10 @@ func (transformerType TransformerType) String() string {
 		"eth_event",
 		"eth_storage",
 		"eth_contract",
+		"eth_account",
 	}
 
-	if transformerType > EthContract || transformerType < EthEvent {
+	if transformerType > EthAccount || transformerType < EthEvent {
 		return "Unknown"
 	}
 
@@ -134,6 +136,7 @@ func GetTransformerType(str string) TransformerType {
 		EthEvent,
 		EthStorage,
 		EthContract,
+		EthAccount,
 	}
 
 	for _, ty := range types {
@@ -239,3 +239,13 @@ var _ = Describe("GetMigrationsPaths", func() {
 		Expect(err.Error()).To(ContainSubstring("duplicate paths with different ranks present"))
 	})
 })
+
+var _ = Describe("TransformerType", func() {
+	It("Parses each transformer type from its name", func() {
+		for _, transformerType := range []config.TransformerType{config.EthEvent, config.EthStorage, config.EthContract, config.EthAccount} {
+			Expect(config.GetTransformerType(transformerType.String())).To(Equal(transformerType))
+		}
+		Expect(config.EthAccount.String()).To(Equal("eth_account"))
+		Expect(config.GetTransformerType("eth_unknown")).To(Equal(config.UnknownTransformerType))
+	})
+})
@@ -17,19 +17,47 @@
 package contract
 
 import (
+	"sort"
+
 	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/filter"
 	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/types"
 )
 
+// DefaultMaxMethodCalls is the number of calls made to a polled method at a header by default
+const DefaultMaxMethodCalls = 100
+
 // Contract object to hold our contract data
 type Contract struct {
-	Address       string                 // Address of the contract
-	Network       string                 // Network on which the contract is deployed; default empty "" is Ethereum mainnet
-	StartingBlock int64                  // Starting block of the contract
-	Abi           string                 // Abi string
-	ParsedAbi     abi.ABI                // Parsed abi
-	Events        map[string]types.Event // List of events to watch
-	FilterArgs    map[string]bool        // User-input list of values to filter event logs for
+	Address        string                     // Address of the contract
+	Network        string                     // Network on which the contract is deployed; default empty "" is Ethereum mainnet
+	StartingBlock  int64                      // Starting block of the contract
+	EndingBlock    int64                      // Last block the contract is watched at; -1 if it's watched indefinitely
+	Abi            string                     // Abi string
+	ParsedAbi      abi.ABI                    // Parsed abi
+	Events         map[string]types.Event     // List of events to watch
+	FilterArgs     map[string]bool            // User-input list of values to filter event logs for
+	Filters        map[string]*filter.Filter  // Filter expressions over the fields of events, by event name
+	Layouts        map[string]types.LogLayout // Layouts identifying the logs of anonymous events, by event name
+	Methods        map[string]types.Method    // List of methods to poll
+	MethodInterval int64                      // Number of blocks between method polls
+	MaxMethodCalls int64                      // Maximum number of calls made to a polled method at a header; 0 if unbounded
+	Calls          map[string]types.Call      // Methods whose calls to the contract are decoded from transaction input
+	EmittedAddrs   map[string]bool            // Addresses emitted by watched events since the last poll, used as method arguments
+	EmittedHashes  map[string]bool            // 32 byte values emitted by watched events since the last poll, used as method arguments
+	// Implementations behind a proxy contract, ordered by starting block; empty if the contract isn't a proxy
+	// Abi and ParsedAbi then hold the proxy's abi merged with every implementation's, so that all of their events are watched
+	Implementations []Implementation
+	ProxyAbi        string // Abi of the proxy itself
+}
+
+// Implementation is the contract a proxy delegates to from its starting block on
+type Implementation struct {
+	Address       string  // Address of the implementation
+	StartingBlock int64   // Block at which the proxy was upgraded to the implementation
+	Abi           string  // Abi of the proxy merged with the implementation's abi
+	ParsedAbi     abi.ABI // Parsed abi
 }
 
 // Init initializes a contract object
@@ -37,6 +65,11 @@ func (c Contract) Init() *Contract {
 	return &c
 }
 
+// WatchedAt returns true if the block is within the range the contract is watched over
+func (c *Contract) WatchedAt(blockNumber int64) bool {
+	return blockNumber >= c.StartingBlock && (c.EndingBlock == -1 || blockNumber <= c.EndingBlock)
+}
+
 // WantedEventArg returns true if address is in list of arguments to
 // filter events for or if no filtering is specified
 func (c *Contract) WantedEventArg(arg string) bool {
@@ -62,3 +95,180 @@ func (c *Contract) PassesEventFilter(args map[string]string) bool {
 
 	return false
 }
+
+// PassesFilters returns true if the event log's name-value mapping passes both the argument filter and the filter
+// expression configured for the event, if any
+func (c *Contract) PassesFilters(eventName string, args map[string]string) bool {
+	if !c.PassesEventFilter(args) {
+		return false
+	}
+	f, ok := c.Filters[eventName]
+	return !ok || f.Matches(args)
+}
+
+// LayoutOf returns the layout identifying the logs of the anonymous event; without a configured layout, logs are
+// identified by their number of topics and length of data alone
+func (c *Contract) LayoutOf(event types.Event) types.LogLayout {
+	return c.Layouts[event.Name]
+}
+
+// CallFor returns the watched method the transaction input is calldata for, if any
+func (c *Contract) CallFor(input []byte) (types.Call, bool) {
+	for _, call := range c.Calls {
+		if call.Matches(input) {
+			return call, true
+		}
+	}
+
+	return types.Call{}, false
+}
+
+// TakesEmittedArgs returns true if any polled method needs values emitted by events as arguments
+func (c *Contract) TakesEmittedArgs() bool {
+	for _, method := range c.Methods {
+		if len(method.Args) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AddEmittedAddr keeps track of addresses emitted by events, if any polled method takes arguments
+func (c *Contract) AddEmittedAddr(addresses ...interface{}) {
+	if !c.TakesEmittedArgs() {
+		return
+	}
+	if c.EmittedAddrs == nil {
+		c.EmittedAddrs = map[string]bool{}
+	}
+	for _, addr := range addresses {
+		switch a := addr.(type) {
+		case common.Address:
+			c.EmittedAddrs[a.Hex()] = true
+		case string:
+			c.EmittedAddrs[common.HexToAddress(a).Hex()] = true
+		}
+	}
+}
+
+// AddEmittedHash keeps track of 32 byte values emitted by events, if any polled method takes arguments
+func (c *Contract) AddEmittedHash(hashes ...interface{}) {
+	if !c.TakesEmittedArgs() {
+		return
+	}
+	if c.EmittedHashes == nil {
+		c.EmittedHashes = map[string]bool{}
+	}
+	for _, hash := range hashes {
+		switch h := hash.(type) {
+		case common.Hash:
+			c.EmittedHashes[h.Hex()] = true
+		case string:
+			c.EmittedHashes[common.HexToHash(h).Hex()] = true
+		}
+	}
+}
+
+// ClearEmittedArgs forgets the values emitted since the last poll, once the contract's methods have been polled
+func (c *Contract) ClearEmittedArgs() {
+	c.EmittedAddrs = nil
+	c.EmittedHashes = nil
+}
+
+// MethodArgs returns each combination of values emitted since the last poll that can be passed as the method's
+// arguments, in a stable order; a method without arguments is called once with none
+// Combinations beyond MaxMethodCalls are left out, in which case the second return value is true
+func (c *Contract) MethodArgs(method types.Method) ([][]string, bool) {
+	combinations := [][]string{{}}
+	truncated := false
+	for _, arg := range method.Args {
+		values := sortedKeys(c.EmittedHashes)
+		if arg.Type.T == abi.AddressTy {
+			values = sortedKeys(c.EmittedAddrs)
+		}
+		size := int64(len(combinations) * len(values))
+		if c.MaxMethodCalls > 0 && size > c.MaxMethodCalls {
+			size = c.MaxMethodCalls
+			truncated = true
+		}
+		next := make([][]string, 0, size)
+	combine:
+		for _, combination := range combinations {
+			for _, value := range values {
+				if int64(len(next)) == size {
+					break combine
+				}
+				args := make([]string, len(combination), len(combination)+1)
+				copy(args, combination)
+				next = append(next, append(args, value))
+			}
+		}
+		combinations = next
+	}
+
+	return combinations, truncated
+}
+
+// IsProxy returns true if the contract delegates to an implementation
+func (c *Contract) IsProxy() bool {
+	return len(c.Implementations) > 0
+}
+
+// ImplementationAt returns the address of the implementation in effect at the block, if any
+func (c *Contract) ImplementationAt(blockNumber int64) string {
+	implementation, ok := c.implementationAt(blockNumber)
+	if !ok {
+		return ""
+	}
+	return implementation.Address
+}
+
+// AbiAt returns the abi used to decode logs emitted at the block: the proxy's abi merged with that of the
+// implementation in effect, or the contract's abi if it isn't a proxy
+func (c *Contract) AbiAt(blockNumber int64) abi.ABI {
+	implementation, ok := c.implementationAt(blockNumber)
+	if !ok {
+		return c.ParsedAbi
+	}
+	return implementation.ParsedAbi
+}
+
+// AddImplementation records an upgrade of a proxy, replacing any implementation starting at the same block
+func (c *Contract) AddImplementation(implementation Implementation) {
+	i := sort.Search(len(c.Implementations), func(i int) bool {
+		return c.Implementations[i].StartingBlock >= implementation.StartingBlock
+	})
+	if i < len(c.Implementations) && c.Implementations[i].StartingBlock == implementation.StartingBlock {
+		c.Implementations[i] = implementation
+		return
+	}
+	c.Implementations = append(c.Implementations, Implementation{})
+	copy(c.Implementations[i+1:], c.Implementations[i:])
+	c.Implementations[i] = implementation
+}
+
+// implementationAt returns the latest implementation starting at or before the block; logs before the first
+// known upgrade are decoded with the first implementation
+func (c *Contract) implementationAt(blockNumber int64) (Implementation, bool) {
+	if len(c.Implementations) == 0 {
+		return Implementation{}, false
+	}
+	i := sort.Search(len(c.Implementations), func(i int) bool {
+		return c.Implementations[i].StartingBlock > blockNumber
+	})
+	if i == 0 {
+		return c.Implementations[0], true
+	}
+	return c.Implementations[i-1], true
+}
+
+func sortedKeys(values map[string]bool) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -17,13 +17,24 @@
 package contract_test
 
 import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/constants"
 	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/contract"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/filter"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/types"
+	"github.com/makerdao/vulcanizedb/pkg/eth"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
 
 var _ = Describe("Contract", func() {
-	var info *contract.Contract
+	var (
+		info       *contract.Contract
+		uint256, _ = abi.NewType("uint256", "", nil)
+	)
 
 	Describe("IsEventAddr", func() {
 		BeforeEach(func() {
@@ -119,4 +130,251 @@ var _ = Describe("Contract", func() {
 			Expect(pass).To(Equal(false))
 		})
 	})
+
+	Describe("PassesFilters", func() {
+		var transfer types.Event
+
+		BeforeEach(func() {
+			transfer = types.Event{Name: "Transfer", Fields: []types.Field{
+				{Argument: abi.Argument{Name: "value", Type: uint256}},
+			}}
+			valueFilter, err := filter.Compile("value > 10", transfer)
+			Expect(err).NotTo(HaveOccurred())
+			info = &contract.Contract{
+				FilterArgs: map[string]bool{},
+				Filters:    map[string]*filter.Filter{"Transfer": valueFilter},
+			}
+		})
+
+		It("Returns true if the log matches the event's filter expression", func() {
+			Expect(info.PassesFilters("Transfer", map[string]string{"value": "11"})).To(BeTrue())
+			Expect(info.PassesFilters("Transfer", map[string]string{"value": "10"})).To(BeFalse())
+		})
+
+		It("Returns true for events without a filter expression", func() {
+			Expect(info.PassesFilters("Approval", map[string]string{"value": "1"})).To(BeTrue())
+		})
+
+		It("Returns false if the log doesn't pass the argument filter", func() {
+			info.FilterArgs["0x1"] = true
+
+			Expect(info.PassesFilters("Transfer", map[string]string{"value": "11"})).To(BeFalse())
+		})
+	})
+
+	Describe("WatchedAt", func() {
+		It("Returns true for blocks within the contract's starting and ending blocks", func() {
+			info = &contract.Contract{StartingBlock: 10, EndingBlock: 20}
+
+			Expect(info.WatchedAt(9)).To(BeFalse())
+			Expect(info.WatchedAt(10)).To(BeTrue())
+			Expect(info.WatchedAt(20)).To(BeTrue())
+			Expect(info.WatchedAt(21)).To(BeFalse())
+		})
+
+		It("Returns true for every block from the start if the contract has no ending block", func() {
+			info = &contract.Contract{StartingBlock: 10, EndingBlock: -1}
+
+			Expect(info.WatchedAt(9)).To(BeFalse())
+			Expect(info.WatchedAt(1000000)).To(BeTrue())
+		})
+	})
+
+	Describe("CallFor", func() {
+		var transfer, setOwner types.Call
+
+		BeforeEach(func() {
+			parsedAbi, err := eth.ParseAbi(constants.DaiAbiString)
+			Expect(err).NotTo(HaveOccurred())
+			transfer = types.NewCall(parsedAbi.Methods["transfer"])
+			setOwner = types.NewCall(parsedAbi.Methods["setOwner"])
+			info = &contract.Contract{Calls: map[string]types.Call{"transfer": transfer, "setOwner": setOwner}}
+		})
+
+		It("Returns the watched method the input is calldata for", func() {
+			input := append(common.FromHex("0xa9059cbb"), make([]byte, 64)...)
+
+			call, ok := info.CallFor(input)
+
+			Expect(ok).To(BeTrue())
+			Expect(call).To(Equal(transfer))
+		})
+
+		It("Returns false for input that isn't calldata for a watched method", func() {
+			_, ok := info.CallFor(common.FromHex("0x095ea7b3"))
+			Expect(ok).To(BeFalse())
+			_, ok = info.CallFor(common.FromHex("0xa905"))
+			Expect(ok).To(BeFalse())
+			_, ok = info.CallFor(nil)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("Decodes the arguments of the call", func() {
+			parsedAbi, err := eth.ParseAbi(constants.DaiAbiString)
+			Expect(err).NotTo(HaveOccurred())
+			input, err := parsedAbi.Pack("transfer", common.HexToAddress("0x2"), big.NewInt(1000))
+			Expect(err).NotTo(HaveOccurred())
+			tx := types.Transaction{HeaderID: 1, Hash: "0x3", TxIndex: 4, From: "0x5", Input: input}
+
+			call, ok := info.CallFor(input)
+			Expect(ok).To(BeTrue())
+			result, err := call.Decode(tx)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Transaction).To(Equal(tx))
+			Expect(result.Values).To(Equal(map[string]string{
+				"dst": common.HexToAddress("0x2").Hex(),
+				"wad": "1000",
+			}))
+		})
+
+		It("Fails to decode input that is too short for the call's arguments", func() {
+			_, err := transfer.Decode(types.Transaction{Hash: "0x3", Input: common.FromHex("0xa9059cbb0000")})
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("MethodArgs", func() {
+		var (
+			owner     = common.HexToAddress("0x1")
+			spender   = common.HexToAddress("0x2")
+			balanceOf types.Method
+			allowance types.Method
+		)
+
+		BeforeEach(func() {
+			parsedAbi, err := eth.ParseAbi(constants.DaiAbiString)
+			Expect(err).NotTo(HaveOccurred())
+			balanceOf, err = types.NewMethod(parsedAbi.Methods["balanceOf"])
+			Expect(err).NotTo(HaveOccurred())
+			allowance, err = types.NewMethod(parsedAbi.Methods["allowance"])
+			Expect(err).NotTo(HaveOccurred())
+			info = &contract.Contract{Methods: map[string]types.Method{"balanceOf": balanceOf}}
+		})
+
+		It("Returns a single call without arguments for methods that take none", func() {
+			parsedAbi, err := eth.ParseAbi(constants.DaiAbiString)
+			Expect(err).NotTo(HaveOccurred())
+			totalSupply, err := types.NewMethod(parsedAbi.Methods["totalSupply"])
+			Expect(err).NotTo(HaveOccurred())
+
+			args, truncated := info.MethodArgs(totalSupply)
+
+			Expect(args).To(Equal([][]string{{}}))
+			Expect(truncated).To(BeFalse())
+		})
+
+		It("Returns each emitted address for a method taking an address", func() {
+			info.AddEmittedAddr(spender, owner, owner)
+
+			args, truncated := info.MethodArgs(balanceOf)
+
+			Expect(args).To(Equal([][]string{{owner.Hex()}, {spender.Hex()}}))
+			Expect(truncated).To(BeFalse())
+		})
+
+		It("Returns each combination of emitted addresses for a method taking two", func() {
+			info.AddEmittedAddr(owner, spender)
+
+			args, truncated := info.MethodArgs(allowance)
+
+			Expect(args).To(Equal([][]string{
+				{owner.Hex(), owner.Hex()},
+				{owner.Hex(), spender.Hex()},
+				{spender.Hex(), owner.Hex()},
+				{spender.Hex(), spender.Hex()},
+			}))
+			Expect(truncated).To(BeFalse())
+		})
+
+		It("Leaves out combinations beyond the maximum number of calls", func() {
+			info.MaxMethodCalls = 3
+			info.AddEmittedAddr(owner, spender)
+
+			args, truncated := info.MethodArgs(allowance)
+
+			Expect(args).To(Equal([][]string{
+				{owner.Hex(), owner.Hex()},
+				{owner.Hex(), spender.Hex()},
+				{spender.Hex(), owner.Hex()},
+			}))
+			Expect(truncated).To(BeTrue())
+		})
+
+		It("Returns no combinations once the emitted values are cleared", func() {
+			info.AddEmittedAddr(owner, spender)
+
+			info.ClearEmittedArgs()
+
+			args, _ := info.MethodArgs(balanceOf)
+			Expect(args).To(BeEmpty())
+		})
+
+		It("Doesn't keep emitted values if no method takes arguments", func() {
+			info.Methods = nil
+
+			info.AddEmittedAddr(owner)
+			info.AddEmittedHash(common.HexToHash("0x3"))
+
+			Expect(info.EmittedAddrs).To(BeEmpty())
+			Expect(info.EmittedHashes).To(BeEmpty())
+		})
+	})
+
+	Describe("Implementations", func() {
+		var v1, v2, v3 contract.Implementation
+
+		BeforeEach(func() {
+			tusdAbi, err := eth.ParseAbi(constants.TusdAbiString)
+			Expect(err).NotTo(HaveOccurred())
+			daiAbi, err := eth.ParseAbi(constants.DaiAbiString)
+			Expect(err).NotTo(HaveOccurred())
+			v1 = contract.Implementation{Address: "0x1", StartingBlock: 10, ParsedAbi: tusdAbi}
+			v2 = contract.Implementation{Address: "0x2", StartingBlock: 20, ParsedAbi: daiAbi}
+			v3 = contract.Implementation{Address: "0x3", StartingBlock: 30, ParsedAbi: tusdAbi}
+			info = &contract.Contract{}
+		})
+
+		It("Isn't a proxy without implementations", func() {
+			Expect(info.IsProxy()).To(BeFalse())
+			Expect(info.ImplementationAt(10)).To(BeEmpty())
+		})
+
+		It("Keeps implementations ordered by starting block", func() {
+			info.AddImplementation(v3)
+			info.AddImplementation(v1)
+			info.AddImplementation(v2)
+
+			Expect(info.IsProxy()).To(BeTrue())
+			Expect(info.Implementations).To(Equal([]contract.Implementation{v1, v2, v3}))
+		})
+
+		It("Replaces an implementation starting at the same block", func() {
+			info.AddImplementation(v1)
+			replacement := contract.Implementation{Address: "0x4", StartingBlock: 10}
+
+			info.AddImplementation(replacement)
+
+			Expect(info.Implementations).To(Equal([]contract.Implementation{replacement}))
+		})
+
+		It("Returns the implementation and abi in effect at a block", func() {
+			info.AddImplementation(v1)
+			info.AddImplementation(v2)
+
+			Expect(info.ImplementationAt(5)).To(Equal("0x1"))
+			Expect(info.ImplementationAt(19)).To(Equal("0x1"))
+			Expect(info.ImplementationAt(20)).To(Equal("0x2"))
+			Expect(info.ImplementationAt(100)).To(Equal("0x2"))
+			Expect(info.AbiAt(19)).To(Equal(v1.ParsedAbi))
+			Expect(info.AbiAt(20)).To(Equal(v2.ParsedAbi))
+		})
+
+		It("Returns the contract's abi if it isn't a proxy", func() {
+			info.ParsedAbi = v2.ParsedAbi
+
+			Expect(info.AbiAt(10)).To(Equal(v2.ParsedAbi))
+		})
+	})
 })
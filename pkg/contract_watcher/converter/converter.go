@@ -18,16 +18,15 @@ package converter
 
 import (
 	"encoding/json"
-	"fmt"
-	"math/big"
-	"strconv"
+	"sort"
+	"strings"
 
-	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/common/hexutil"
 	gethTypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/contract"
 	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/types"
+	"github.com/sirupsen/logrus"
 )
 
 // Converter is the interface for converting geth logs to our custom log type
@@ -51,169 +50,221 @@ func (c *converter) Update(info *contract.Contract) {
 }
 
 // Convert the given watched event log into a types.Log for the given event
+// Logs that can't be decoded as an anonymous event aren't logs of that event, and are skipped
 func (c *converter) Convert(logs []gethTypes.Log, event types.Event, headerID int64) ([]types.Log, error) {
-	boundContract := bind.NewBoundContract(common.HexToAddress(c.ContractInfo.Address), c.ContractInfo.ParsedAbi, nil, nil, nil)
 	returnLogs := make([]types.Log, 0, len(logs))
 	for _, log := range logs {
-		values := make(map[string]interface{})
-		for _, field := range event.Fields {
-			var i interface{}
-			values[field.Name] = i
+		decoded, err := c.decode(log, event)
+		if err != nil {
+			if event.Anonymous {
+				continue
+			}
+			return nil, err
+		}
+		converted, passes, err := c.convert(log, event, decoded, headerID)
+		if err != nil {
+			return nil, err
 		}
+		if passes {
+			returnLogs = append(returnLogs, converted)
+		}
+	}
 
-		err := boundContract.UnpackLogIntoMap(values, event.Name, log)
+	return returnLogs, nil
+}
+
+// ConvertBatch converts the given watched event logs into types.Logs; returns a map of event signatures to a slice of
+// their converted logs
+// Logs are matched to events by their signature topic, or, for anonymous events, by the contract's layout for them and
+// by decoding as them. Each log is converted for at most one event
+func (c *converter) ConvertBatch(logs []gethTypes.Log, events map[string]types.Event, headerID int64) (map[string][]types.Log, error) {
+	eventsToLogs := make(map[string][]types.Log, len(events))
+	signatures := make([]string, 0, len(events))
+	for signature := range events {
+		eventsToLogs[signature] = make([]types.Log, 0, len(logs))
+		signatures = append(signatures, signature)
+	}
+	// match ambiguous anonymous logs to the same event every time
+	sort.Strings(signatures)
+
+	for _, log := range logs {
+		signature, decoded, found, err := c.match(log, events, signatures)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			continue
+		}
+		converted, passes, err := c.convert(log, events[signature], decoded, headerID)
 		if err != nil {
 			return nil, err
 		}
+		if passes {
+			eventsToLogs[signature] = append(eventsToLogs[signature], converted)
+		}
+	}
 
-		strValues := make(map[string]string, len(values))
-		seenAddrs := make([]interface{}, 0, len(values))
-		seenHashes := make([]interface{}, 0, len(values))
-		for fieldName, input := range values {
-			// Postgres cannot handle custom types, resolve everything to strings
-			switch input.(type) {
-			case *big.Int:
-				b := input.(*big.Int)
-				strValues[fieldName] = b.String()
-			case common.Address:
-				a := input.(common.Address)
-				strValues[fieldName] = a.String()
-				seenAddrs = append(seenAddrs, a)
-			case common.Hash:
-				h := input.(common.Hash)
-				strValues[fieldName] = h.String()
-				seenHashes = append(seenHashes, h)
-			case string:
-				strValues[fieldName] = input.(string)
-			case bool:
-				strValues[fieldName] = strconv.FormatBool(input.(bool))
-			case []byte:
-				b := input.([]byte)
-				strValues[fieldName] = hexutil.Encode(b)
-				if len(b) == 32 {
-					seenHashes = append(seenHashes, common.HexToHash(strValues[fieldName]))
-				}
-			case uint64:
-				u := input.(uint64)
-				strValues[fieldName] = strconv.Itoa(int(u))
-			case uint8:
-				u := input.(uint8)
-				strValues[fieldName] = strconv.Itoa(int(u))
-			case [32]uint8:
-				raw := input.([32]uint8)
-				converted := convertUintSliceToHash(raw)
-				strValues[fieldName] = converted.String()
-				seenHashes = append(seenHashes, converted)
-			default:
-				return nil, fmt.Errorf("error: unhandled abi type %T", input)
-			}
+	return eventsToLogs, nil
+}
+
+// match returns the signature of the event that emitted the log, along with the log decoded as that event
+// A log matching several anonymous events is matched to the first of them by signature, with a warning
+func (c *converter) match(log gethTypes.Log, events map[string]types.Event, signatures []string) (string, decodedLog, bool, error) {
+	var matches []string
+	var firstDecoded decodedLog
+	for _, signature := range signatures {
+		event := events[signature]
+		if !c.isEventLog(event, log) {
+			continue
+		}
+		decoded, err := c.decode(log, event)
+		if !event.Anonymous {
+			return signature, decoded, err == nil, err
+		}
+		if err != nil {
+			continue
 		}
+		if len(matches) == 0 {
+			firstDecoded = decoded
+		}
+		matches = append(matches, signature)
+	}
+	if len(matches) == 0 {
+		return "", decodedLog{}, false, nil
+	}
+	if len(matches) > 1 {
+		logrus.Warnf("log %d of tx %s matches anonymous events %s; persisting it as %s only", log.Index,
+			log.TxHash.Hex(), strings.Join(matches, ", "), matches[0])
+	}
+	return matches[0], firstDecoded, true, nil
+}
 
-		// Only hold onto logs that pass our address filter, if any
-		if c.ContractInfo.PassesEventFilter(strValues) {
-			raw, err := json.Marshal(log)
-			if err != nil {
-				return nil, err
+// isEventLog returns true if the log may have been emitted by the event
+// Anonymous events match logs of the layout configured for them, unless the log carries the signature of one of the
+// contract's other events
+func (c *converter) isEventLog(event types.Event, log gethTypes.Log) bool {
+	if !event.Anonymous {
+		return len(log.Topics) > 0 && log.Topics[0] == event.Sig()
+	}
+	if len(log.Topics) > 0 {
+		for _, e := range c.ContractInfo.AbiAt(int64(log.BlockNumber)).Events {
+			if !e.Anonymous && e.ID == log.Topics[0] {
+				return false
 			}
-
-			returnLogs = append(returnLogs, types.Log{
-				LogIndex:         log.Index,
-				Values:           strValues,
-				Raw:              raw,
-				TransactionIndex: log.TxIndex,
-				HeaderID:         headerID,
-			})
 		}
 	}
+	return c.ContractInfo.LayoutOf(event).Matches(event, log)
+}
 
-	return returnLogs, nil
+// decodedLog is a log's values resolved to strings, along with the addresses and 32 byte values it emitted
+type decodedLog struct {
+	values     map[string]string
+	seenAddrs  []interface{}
+	seenHashes []interface{}
 }
 
-// ConvertBatch converts the given watched event logs into types.Logs; returns a map of event names to a slice of their converted logs
-func (c *converter) ConvertBatch(logs []gethTypes.Log, events map[string]types.Event, headerID int64) (map[string][]types.Log, error) {
-	boundContract := bind.NewBoundContract(common.HexToAddress(c.ContractInfo.Address), c.ContractInfo.ParsedAbi, nil, nil, nil)
-	eventsToLogs := make(map[string][]types.Log)
-	for _, event := range events {
-		eventsToLogs[event.Name] = make([]types.Log, 0, len(logs))
-		// Iterate through all event logs
-		for _, log := range logs {
-			// If the log is of this event type, process it as such
-			if event.Sig() == log.Topics[0] {
-				values := make(map[string]interface{})
-				err := boundContract.UnpackLogIntoMap(values, event.Name, log)
-				if err != nil {
-					return nil, err
-				}
-				// Postgres cannot handle custom types, so we will resolve everything to strings
-				strValues := make(map[string]string, len(values))
-				// Keep track of addresses and hashes emitted from events
-				seenAddrs := make([]interface{}, 0, len(values))
-				seenHashes := make([]interface{}, 0, len(values))
-				for fieldName, input := range values {
-					switch input.(type) {
-					case *big.Int:
-						b := input.(*big.Int)
-						strValues[fieldName] = b.String()
-					case common.Address:
-						a := input.(common.Address)
-						strValues[fieldName] = a.String()
-						seenAddrs = append(seenAddrs, a)
-					case common.Hash:
-						h := input.(common.Hash)
-						strValues[fieldName] = h.String()
-						seenHashes = append(seenHashes, h)
-					case string:
-						strValues[fieldName] = input.(string)
-					case bool:
-						strValues[fieldName] = strconv.FormatBool(input.(bool))
-					case []byte:
-						b := input.([]byte)
-						strValues[fieldName] = hexutil.Encode(b)
-						if len(b) == 32 { // collect byte arrays of size 32 as hashes
-							seenHashes = append(seenHashes, common.BytesToHash(b))
-						}
-					case uint64:
-						u := input.(uint64)
-						strValues[fieldName] = strconv.Itoa(int(u))
-					case uint8:
-						u := input.(uint8)
-						strValues[fieldName] = strconv.Itoa(int(u))
-					case [32]uint8:
-						raw := input.([32]uint8)
-						converted := convertUintSliceToHash(raw)
-						strValues[fieldName] = converted.String()
-						seenHashes = append(seenHashes, converted)
-					default:
-						return nil, fmt.Errorf("error: unhandled abi type %T", input)
-					}
-				}
-
-				// Only hold onto logs that pass our argument filter, if any
-				if c.ContractInfo.PassesEventFilter(strValues) {
-					raw, err := json.Marshal(log)
-					if err != nil {
-						return nil, err
-					}
-
-					eventsToLogs[event.Name] = append(eventsToLogs[event.Name], types.Log{
-						LogIndex:         log.Index,
-						Values:           strValues,
-						Raw:              raw,
-						TransactionIndex: log.TxIndex,
-						HeaderID:         headerID,
-					})
-				}
-			}
+// decode unpacks the log's data and topics as the event
+func (c *converter) decode(log gethTypes.Log, event types.Event) (decodedLog, error) {
+	abiEvent, eventAtLog := c.eventAt(event, log)
+	values := make(map[string]interface{})
+	if len(log.Data) > 0 {
+		err := abiEvent.Inputs.UnpackIntoMap(values, log.Data)
+		if err != nil {
+			return decodedLog{}, err
 		}
 	}
+	var indexed abi.Arguments
+	for _, input := range abiEvent.Inputs {
+		if input.Indexed {
+			indexed = append(indexed, input)
+		}
+	}
+	topics := log.Topics
+	if !abiEvent.Anonymous && len(topics) > 0 {
+		topics = topics[1:]
+	}
+	err := abi.ParseTopicsIntoMap(values, indexed, topics)
+	if err != nil {
+		return decodedLog{}, err
+	}
 
-	return eventsToLogs, nil
+	// Postgres cannot handle custom types, so we will resolve everything to strings
+	strValues, seenAddrs, seenHashes, err := stringValues(eventAtLog, values)
+	if err != nil {
+		return decodedLog{}, err
+	}
+	return decodedLog{values: strValues, seenAddrs: seenAddrs, seenHashes: seenHashes}, nil
+}
+
+// convert returns the decoded log as a types.Log along with whether it passes our filters
+func (c *converter) convert(log gethTypes.Log, event types.Event, decoded decodedLog, headerID int64) (types.Log, bool, error) {
+	// Only hold onto logs that pass our filters, if any
+	if !c.ContractInfo.PassesFilters(event.Name, decoded.values) {
+		return types.Log{}, false, nil
+	}
+	// Keep track of emitted values, for use as method arguments
+	c.ContractInfo.AddEmittedAddr(decoded.seenAddrs...)
+	c.ContractInfo.AddEmittedHash(decoded.seenHashes...)
+
+	raw, err := json.Marshal(log)
+	if err != nil {
+		return types.Log{}, false, err
+	}
+
+	return types.Log{
+		LogIndex:         log.Index,
+		Values:           decoded.values,
+		Raw:              raw,
+		TransactionIndex: log.TxIndex,
+		HeaderID:         headerID,
+	}, true, nil
+}
+
+// eventAt returns the event as declared by the abi in effect at the log's block, both as an abi.Event for decoding
+// and as our event; these differ from the contract's event for proxies whose implementation has been upgraded
+func (c *converter) eventAt(event types.Event, log gethTypes.Log) (abi.Event, types.Event) {
+	sig := event.Sig()
+	for _, e := range c.ContractInfo.AbiAt(int64(log.BlockNumber)).Events {
+		if e.ID == sig && e.Anonymous == event.Anonymous {
+			eventAtLog := types.NewEvent(e)
+			eventAtLog.Name = event.Name
+			return e, eventAtLog
+		}
+	}
+	inputs := make(abi.Arguments, len(event.Fields))
+	for i, field := range event.Fields {
+		inputs[i] = field.Argument
+	}
+	return abi.NewEvent(event.Name, event.RawName, event.Anonymous, inputs), event
 }
 
-func convertUintSliceToHash(raw [32]uint8) common.Hash {
-	var asBytes []byte
-	for _, u := range raw {
-		asBytes = append(asBytes, u)
+// stringValues resolves the event's unpacked values into the string values of its columns
+// Also returns the addresses and 32 byte values emitted by the event
+func stringValues(event types.Event, values map[string]interface{}) (map[string]string, []interface{}, []interface{}, error) {
+	strValues := make(map[string]string, len(values))
+	seenAddrs := make([]interface{}, 0, len(values))
+	seenHashes := make([]interface{}, 0, len(values))
+	for _, field := range event.Fields {
+		value, ok := values[field.Name]
+		if !ok {
+			continue
+		}
+		columnValues, err := types.FieldValues(field, value)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for name, columnValue := range columnValues {
+			strValues[name] = columnValue
+		}
+
+		// Keep track of addresses and hashes emitted from events
+		switch v := value.(type) {
+		case common.Address:
+			seenAddrs = append(seenAddrs, v)
+		case [32]uint8:
+			seenHashes = append(seenHashes, common.BytesToHash(v[:]))
+		}
 	}
-	return common.BytesToHash(asBytes)
+
+	return strValues, seenAddrs, seenHashes, nil
 }
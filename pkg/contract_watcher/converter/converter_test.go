@@ -17,15 +17,19 @@
 package converter_test
 
 import (
+	"math/big"
 	"math/rand"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/constants"
 	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/contract"
 	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/converter"
 	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/helpers"
 	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/helpers/test_helpers"
 	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/helpers/test_helpers/mocks"
+	cwTypes "github.com/makerdao/vulcanizedb/pkg/contract_watcher/types"
+	"github.com/makerdao/vulcanizedb/pkg/eth"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -56,10 +60,10 @@ var _ = Describe("Converter", func() {
 	Describe("Convert", func() {
 		It("Converts a watched event log to mapping of event input names to values", func() {
 			con := test_helpers.SetupTusdContract(tusdWantedEvents)
-			_, ok := con.Events["Approval"]
+			_, ok := con.Events["Approval(address,address,uint256)"]
 			Expect(ok).To(Equal(false))
 
-			event, ok := con.Events["Transfer"]
+			event, ok := con.Events["Transfer(address,address,uint256)"]
 			Expect(ok).To(Equal(true))
 
 			c := converter.NewConverter()
@@ -84,7 +88,7 @@ var _ = Describe("Converter", func() {
 
 		It("correctly parses bytes32", func() {
 			con := test_helpers.SetupMarketPlaceContract(marketPlaceWantedEvents)
-			event, ok := con.Events["OrderCreated"]
+			event, ok := con.Events["OrderCreated(bytes32,uint256,address,address,uint256,uint256)"]
 			Expect(ok).To(BeTrue())
 
 			c := converter.NewConverter()
@@ -98,7 +102,7 @@ var _ = Describe("Converter", func() {
 
 		It("correctly parses uint8", func() {
 			con := test_helpers.SetupMolochContract(molochWantedEvents)
-			event, ok := con.Events["SubmitVote"]
+			event, ok := con.Events["SubmitVote(uint256,address,address,uint8)"]
 			Expect(ok).To(BeTrue())
 
 			c := converter.NewConverter()
@@ -112,7 +116,7 @@ var _ = Describe("Converter", func() {
 
 		It("correctly parses uint64", func() {
 			con := test_helpers.SetupOasisContract(oasisWantedEvents)
-			event, ok := con.Events["LogMake"]
+			event, ok := con.Events["LogMake(bytes32,bytes32,address,address,address,uint128,uint128,uint64)"]
 			Expect(ok).To(BeTrue())
 
 			c := converter.NewConverter()
@@ -124,9 +128,191 @@ var _ = Describe("Converter", func() {
 			Expect(result[0].Values["timestamp"]).To(Equal("1580153827"))
 		})
 
+		It("resolves tuples, arrays, and nested arrays", func() {
+			con := test_helpers.SetupAbiTypesContract()
+			event, ok := con.Events[test_helpers.AbiTypesSignature]
+			Expect(ok).To(BeTrue())
+
+			c := converter.NewConverter()
+			c.Update(con)
+			result, err := c.Convert([]types.Log{test_helpers.AbiTypesLog()}, event, fakeHeaderID)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(len(result)).To(Equal(1))
+			Expect(result[0].Values).To(Equal(map[string]string{
+				"owner":           test_helpers.AbiTypesOwner.String(),
+				"tags":            test_helpers.AbiTypesTagsHash.String(),
+				"small":           "7",
+				"signed":          "-1000000000000000000000",
+				"flag":            "true",
+				"data":            "0x010203",
+				"id":              test_helpers.AbiTypesID.String(),
+				"selector":        "0xdeadbeef",
+				"label":           "hello, world",
+				"amounts":         `["1","2"]`,
+				"pair":            `["` + test_helpers.AbiTypesOwner.String() + `","` + test_helpers.AbiTypesRecipient.String() + `"]`,
+				"ids":             `["` + test_helpers.AbiTypesID.String() + `","` + common.Hash{}.String() + `"]`,
+				"flags":           `["true","false"]`,
+				"order_amount":    "100",
+				"order_recipient": test_helpers.AbiTypesRecipient.String(),
+				"order_meta_ok":   "true",
+				"order_meta_note": "paid",
+				"orders":          `[{"amount":"5","recipient":"` + test_helpers.AbiTypesOwner.String() + `"}]`,
+				"matrix":          `[["1"],["2","3"]]`,
+			}))
+		})
+
+		It("decodes the logs of a proxy with the abi of the implementation in effect at their block", func() {
+			v1Abi := `[{"anonymous":false,"name":"Transfer","type":"event","inputs":[{"indexed":true,"name":"from","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":false,"name":"value","type":"uint256"}]}]`
+			v2Abi := `[{"anonymous":false,"name":"Transfer","type":"event","inputs":[{"indexed":true,"name":"from","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":true,"name":"value","type":"uint256"}]}]`
+			v1, err := eth.ParseAbi(v1Abi)
+			Expect(err).NotTo(HaveOccurred())
+			v2, err := eth.ParseAbi(v2Abi)
+			Expect(err).NotTo(HaveOccurred())
+			con := contract.Contract{
+				Address:   constants.TusdContractAddress,
+				Abi:       v2Abi,
+				ParsedAbi: v2,
+				Implementations: []contract.Implementation{
+					{Address: "0x1", StartingBlock: 1, Abi: v1Abi, ParsedAbi: v1},
+					{Address: "0x2", StartingBlock: 20, Abi: v2Abi, ParsedAbi: v2},
+				},
+				FilterArgs: map[string]bool{},
+			}.Init()
+			event := cwTypes.NewEvent(v2.Events["Transfer"])
+			from := common.HexToHash("0x000000000000000000000000000000000000Af21")
+			to := common.HexToHash("0x00000000000000000000000009BbBBE21a5975cAc061D82f7b843bCE061BA391")
+			value := common.BigToHash(big.NewInt(1000))
+			beforeUpgrade := types.Log{BlockNumber: 10, Topics: []common.Hash{v1.Events["Transfer"].ID, from, to}, Data: value.Bytes()}
+			afterUpgrade := types.Log{BlockNumber: 20, Topics: []common.Hash{v2.Events["Transfer"].ID, from, to, value}}
+
+			c := converter.NewConverter()
+			c.Update(con)
+			result, err := c.ConvertBatch([]types.Log{beforeUpgrade, afterUpgrade}, map[string]cwTypes.Event{event.Signature(): event}, fakeHeaderID)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result["Transfer(address,address,uint256)"]).To(HaveLen(2))
+			for _, log := range result["Transfer(address,address,uint256)"] {
+				Expect(log.Values["from"]).To(Equal("0x000000000000000000000000000000000000Af21"))
+				Expect(log.Values["to"]).To(Equal("0x09BbBBE21a5975cAc061D82f7b843bCE061BA391"))
+				Expect(log.Values["value"]).To(Equal("1000"))
+			}
+		})
+
+		Describe("Matching logs to events", func() {
+			var (
+				pokeAbi = `[
+					{"anonymous":false,"name":"Transfer","type":"event","inputs":[{"indexed":true,"name":"from","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":false,"name":"value","type":"uint256"}]},
+					{"anonymous":false,"name":"Transfer","type":"event","inputs":[{"indexed":true,"name":"from","type":"address"},{"indexed":false,"name":"value","type":"uint256"}]},
+					{"anonymous":true,"name":"Poke","type":"event","inputs":[{"indexed":true,"name":"who","type":"address"},{"indexed":false,"name":"value","type":"uint256"}]},
+					{"anonymous":true,"name":"Flag","type":"event","inputs":[{"indexed":true,"name":"who","type":"address"},{"indexed":false,"name":"on","type":"bool"}]}
+				]`
+				transferSig      = "Transfer(address,address,uint256)"
+				shortTransferSig = "Transfer(address,uint256)"
+				pokeSig          = "Poke(address,uint256)"
+				flagSig          = "Flag(address,bool)"
+				who              = common.HexToAddress("0x000000000000000000000000000000000000Af21")
+				value            = common.BigToHash(big.NewInt(1000)).Bytes()
+				con              *contract.Contract
+				c                converter.Converter
+			)
+
+			BeforeEach(func() {
+				p := mocks.NewParser(pokeAbi)
+				Expect(p.Parse()).To(Succeed())
+				con = contract.Contract{
+					Address:    constants.TusdContractAddress,
+					Abi:        p.Abi(),
+					ParsedAbi:  p.ParsedAbi(),
+					Events:     p.GetEvents([]string{}),
+					FilterArgs: map[string]bool{},
+				}.Init()
+				c = converter.NewConverter()
+				c.Update(con)
+			})
+
+			It("Converts the logs of overloaded events as the overload matching their signature", func() {
+				transfer := types.Log{Topics: []common.Hash{con.Events[transferSig].Sig(), who.Hash(), who.Hash()}, Data: value}
+				shortTransfer := types.Log{Topics: []common.Hash{con.Events[shortTransferSig].Sig(), who.Hash()}, Data: value, Index: 1}
+
+				result, err := c.ConvertBatch([]types.Log{transfer, shortTransfer}, con.Events, fakeHeaderID)
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result[transferSig]).To(HaveLen(1))
+				Expect(result[transferSig][0].Values["to"]).To(Equal(who.Hex()))
+				Expect(result[shortTransferSig]).To(HaveLen(1))
+				Expect(result[shortTransferSig][0].LogIndex).To(Equal(uint(1)))
+				Expect(result[pokeSig]).To(BeEmpty())
+			})
+
+			It("Converts the logs of anonymous events by their layout", func() {
+				poke := types.Log{Topics: []common.Hash{who.Hash()}, Data: value}
+				tooMuchData := types.Log{Topics: []common.Hash{who.Hash()}, Data: append(value, value...)}
+
+				result, err := c.ConvertBatch([]types.Log{poke, tooMuchData}, con.Events, fakeHeaderID)
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result[pokeSig]).To(HaveLen(1))
+				Expect(result[pokeSig][0].Values).To(Equal(map[string]string{"who": who.Hex(), "value": "1000"}))
+			})
+
+			It("Doesn't convert logs carrying the signature of another event as anonymous events", func() {
+				log := types.Log{Topics: []common.Hash{con.Events[transferSig].Sig()}, Data: value}
+				events := map[string]cwTypes.Event{pokeSig: con.Events[pokeSig]}
+
+				result, err := c.ConvertBatch([]types.Log{log}, events, fakeHeaderID)
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result[pokeSig]).To(BeEmpty())
+			})
+
+			It("Skips anonymous events a log can't be decoded as", func() {
+				poke := types.Log{Topics: []common.Hash{who.Hash()}, Data: value}
+
+				result, err := c.ConvertBatch([]types.Log{poke}, con.Events, fakeHeaderID)
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result[pokeSig]).To(HaveLen(1))
+				Expect(result[flagSig]).To(BeEmpty())
+			})
+
+			It("Converts a log matching several anonymous events for only one of them", func() {
+				ambiguous := types.Log{Topics: []common.Hash{who.Hash()}, Data: common.BigToHash(big.NewInt(1)).Bytes()}
+
+				result, err := c.ConvertBatch([]types.Log{ambiguous}, con.Events, fakeHeaderID)
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result[flagSig]).To(HaveLen(1))
+				Expect(result[flagSig][0].Values["on"]).To(Equal("true"))
+				Expect(result[pokeSig]).To(BeEmpty())
+			})
+
+			It("Skips logs that can't be decoded as a single anonymous event", func() {
+				poke := types.Log{Topics: []common.Hash{who.Hash()}, Data: value}
+
+				result, err := c.Convert([]types.Log{poke}, con.Events[flagSig], fakeHeaderID)
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result).To(BeEmpty())
+			})
+
+			It("Only converts the logs of anonymous events with the topics of their configured layout", func() {
+				other := common.HexToAddress("0x09BbBBE21a5975cAc061D82f7b843bCE061BA391")
+				con.Layouts = map[string]cwTypes.LogLayout{"Poke": {Topics: [][]common.Hash{{who.Hash()}}}}
+				poke := types.Log{Topics: []common.Hash{who.Hash()}, Data: value}
+				otherPoke := types.Log{Topics: []common.Hash{other.Hash()}, Data: value}
+
+				result, err := c.ConvertBatch([]types.Log{poke, otherPoke}, con.Events, fakeHeaderID)
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result[pokeSig]).To(HaveLen(1))
+				Expect(result[pokeSig][0].Values["who"]).To(Equal(who.Hex()))
+			})
+		})
+
 		It("Fails with an empty contract", func() {
 			con := contract.Contract{}.Init()
-			event := con.Events["Transfer"]
+			event := con.Events["Transfer(address,address,uint256)"]
 			c := converter.NewConverter()
 			c.Update(&contract.Contract{})
 
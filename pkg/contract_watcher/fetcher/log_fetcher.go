@@ -26,6 +26,14 @@ import (
 // Fetcher is the fetching interface
 type LogFetcher interface {
 	FetchLogs(contractAddresses []string, topics []common.Hash, missingHeader core.Header) ([]types.Log, error)
+	FetchFilteredLogs(filters []TopicFilter, missingHeader core.Header) ([]types.Log, error)
+}
+
+// TopicFilter restricts the logs fetched for a contract to those matching its topics, the first of which is usually
+// the event signature; empty positions match any topic
+type TopicFilter struct {
+	ContractAddress string
+	Topics          [][]common.Hash
 }
 
 type fetcher struct {
@@ -59,6 +67,26 @@ func (fetcher *fetcher) FetchLogs(contractAddresses []string, topic0s []common.H
 	return logs, nil
 }
 
+// FetchFilteredLogs fetches the logs matching each of the topic filters for the given header
+func (fetcher *fetcher) FetchFilteredLogs(filters []TopicFilter, header core.Header) ([]types.Log, error) {
+	blockHash := common.HexToHash(header.Hash)
+	var logs []types.Log
+	for _, filter := range filters {
+		query := ethereum.FilterQuery{
+			BlockHash: &blockHash,
+			Addresses: []common.Address{common.HexToAddress(filter.ContractAddress)},
+			Topics:    filter.Topics,
+		}
+		filteredLogs, err := fetcher.blockChain.GetEthLogsWithCustomQuery(query)
+		if err != nil {
+			return []types.Log{}, err
+		}
+		logs = append(logs, filteredLogs...)
+	}
+
+	return logs, nil
+}
+
 func hexStringsToAddresses(hexStrings []string) []common.Address {
 	var addresses []common.Address
 	for _, hexString := range hexStrings {
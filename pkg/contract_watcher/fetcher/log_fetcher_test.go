@@ -19,6 +19,7 @@ package fetcher_test
 import (
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/fetcher"
 	"github.com/makerdao/vulcanizedb/pkg/core"
 	"github.com/makerdao/vulcanizedb/pkg/fakes"
@@ -62,4 +63,36 @@ var _ = Describe("Fetcher", func() {
 			Expect(err).To(MatchError(fakes.FakeError))
 		})
 	})
+
+	Describe("FetchFilteredLogs", func() {
+		It("fetches the logs matching the topics of each filter", func() {
+			blockChain := fakes.NewMockBlockChain()
+			log := types.Log{Address: common.HexToAddress("0xfakeAddress")}
+			blockChain.SetGetEthLogsWithCustomQueryReturnLogs([]types.Log{log})
+			f := fetcher.NewFetcher(blockChain)
+			header := fakes.FakeHeader
+			topics := [][]common.Hash{{common.BytesToHash([]byte{1, 2, 3})}, nil, {common.BytesToHash([]byte{4, 5, 6})}}
+
+			logs, err := f.FetchFilteredLogs([]fetcher.TopicFilter{{ContractAddress: "0xfakeAddress", Topics: topics}}, header)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(logs).To(Equal([]types.Log{log}))
+			blockHash := common.HexToHash(header.Hash)
+			blockChain.AssertGetEthLogsWithCustomQueryCalledWith(ethereum.FilterQuery{
+				BlockHash: &blockHash,
+				Addresses: []common.Address{common.HexToAddress("0xfakeAddress")},
+				Topics:    topics,
+			})
+		})
+
+		It("returns an error if fetching the logs fails", func() {
+			blockChain := fakes.NewMockBlockChain()
+			blockChain.SetGetEthLogsWithCustomQueryErr(fakes.FakeError)
+			f := fetcher.NewFetcher(blockChain)
+
+			_, err := f.FetchFilteredLogs([]fetcher.TopicFilter{{ContractAddress: "0xfakeAddress"}}, core.Header{})
+
+			Expect(err).To(MatchError(fakes.FakeError))
+		})
+	})
 })
@@ -0,0 +1,448 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/types"
+)
+
+// ErrInvalidFilter is returned for filter expressions that can't be parsed, or don't apply to the event's fields
+var ErrInvalidFilter = errors.New("invalid event filter")
+
+// Filter is a compiled expression over the fields of an event, such as `to == 0x... AND (value >= 1000 OR memo IN ('a', 'b'))`
+// Fields are compared with ==, != and IN; numeric fields also with <, <=, > and >=; conditions are combined with AND
+// and OR (AND binds tighter) and grouped with parentheses
+type Filter struct {
+	Expression string
+	root       node
+	topics     [][]common.Hash
+}
+
+// Compile parses the expression and checks it against the event's fields
+func Compile(expression string, event types.Event) (*Filter, error) {
+	tokens, lexErr := lex(expression)
+	if lexErr != nil {
+		return nil, lexErr
+	}
+	p := &parser{tokens: tokens, event: event}
+	root, parseErr := p.parseOr()
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	if p.peek().kind != tokenEOF {
+		return nil, p.unexpected()
+	}
+	f := &Filter{Expression: expression, root: root}
+	if !event.Anonymous {
+		f.topics = topics(root)
+	}
+	return f, nil
+}
+
+// Matches returns true if the event's values, as converted for persisting, satisfy the filter
+func (f *Filter) Matches(values map[string]string) bool {
+	return f.root.matches(values)
+}
+
+// Topics returns the values allowed at each topic position after the event signature, for conditions on indexed fields
+// that every matching log satisfies; nil positions allow any value
+// Logs fetched with these topics still need to be checked with Matches
+func (f *Filter) Topics() [][]common.Hash {
+	return f.topics
+}
+
+type node interface {
+	matches(values map[string]string) bool
+}
+
+type allOf []node
+
+func (nodes allOf) matches(values map[string]string) bool {
+	for _, n := range nodes {
+		if !n.matches(values) {
+			return false
+		}
+	}
+	return true
+}
+
+type anyOf []node
+
+func (nodes anyOf) matches(values map[string]string) bool {
+	for _, n := range nodes {
+		if n.matches(values) {
+			return true
+		}
+	}
+	return false
+}
+
+type kind int
+
+const (
+	numericKind kind = iota
+	addressKind
+	boolKind
+	hexKind    // bytes values, compared as lower case hex
+	hashedKind // indexed strings and bytes, which logs only hold the keccak256 hash of
+	textKind
+)
+
+type comparison struct {
+	field    string
+	kind     kind
+	topic    int // Position of the field's topic; 0 if the field isn't indexed
+	op       string
+	literals []literal
+}
+
+type literal struct {
+	value  string
+	number *big.Int
+	topic  common.Hash
+}
+
+func (c comparison) matches(values map[string]string) bool {
+	value, ok := values[c.field]
+	if !ok {
+		return false
+	}
+	switch c.op {
+	case "==", "in":
+		for _, l := range c.literals {
+			if c.equals(value, l) {
+				return true
+			}
+		}
+		return false
+	case "!=":
+		return !c.equals(value, c.literals[0])
+	}
+	number, ok := new(big.Int).SetString(value, 10)
+	if !ok {
+		return false
+	}
+	cmp := number.Cmp(c.literals[0].number)
+	switch c.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+func (c comparison) equals(value string, l literal) bool {
+	switch c.kind {
+	case numericKind:
+		number, ok := new(big.Int).SetString(value, 10)
+		return ok && number.Cmp(l.number) == 0
+	case addressKind, hexKind, hashedKind:
+		return strings.ToLower(value) == l.value
+	default:
+		return value == l.value
+	}
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	event  types.Event
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) unexpected() error {
+	t := p.peek()
+	if t.kind == tokenEOF {
+		return fmt.Errorf("%w: unexpected end of expression", ErrInvalidFilter)
+	}
+	return fmt.Errorf("%w: unexpected %q at %d", ErrInvalidFilter, t.text, t.pos)
+}
+
+func (p *parser) parseOr() (node, error) {
+	var nodes anyOf
+	for {
+		n, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+		if p.peek().kind != tokenOr {
+			break
+		}
+		p.next()
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return nodes, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	var nodes allOf
+	for {
+		n, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		// Flatten nested conjunctions, so that their conditions can become topics
+		if conjunction, ok := n.(allOf); ok {
+			nodes = append(nodes, conjunction...)
+		} else {
+			nodes = append(nodes, n)
+		}
+		if p.peek().kind != tokenAnd {
+			break
+		}
+		p.next()
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return nodes, nil
+}
+
+func (p *parser) parseTerm() (node, error) {
+	if p.peek().kind == tokenLeftParen {
+		p.next()
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind != tokenRightParen {
+			return nil, p.unexpected()
+		}
+		p.next()
+		return n, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	if p.peek().kind != tokenIdent {
+		return nil, p.unexpected()
+	}
+	name := p.next()
+	c, fieldErr := p.comparisonOn(name.text)
+	if fieldErr != nil {
+		return nil, fieldErr
+	}
+
+	switch op := p.peek(); op.kind {
+	case tokenIn:
+		p.next()
+		c.op = "in"
+		if p.peek().kind != tokenLeftParen {
+			return nil, p.unexpected()
+		}
+		p.next()
+		for {
+			l, err := p.parseLiteral(c)
+			if err != nil {
+				return nil, err
+			}
+			c.literals = append(c.literals, l)
+			if p.peek().kind != tokenComma {
+				break
+			}
+			p.next()
+		}
+		if p.peek().kind != tokenRightParen {
+			return nil, p.unexpected()
+		}
+		p.next()
+	case tokenOperator:
+		p.next()
+		c.op = op.text
+		if c.op != "==" && c.op != "!=" && c.kind != numericKind {
+			return nil, fmt.Errorf("%w: %s is not numeric and can't be compared with %s", ErrInvalidFilter, c.field, c.op)
+		}
+		l, err := p.parseLiteral(c)
+		if err != nil {
+			return nil, err
+		}
+		c.literals = []literal{l}
+	default:
+		return nil, p.unexpected()
+	}
+	return c, nil
+}
+
+// Returns a comparison on the event's field with the given name, which is matched case insensitively
+func (p *parser) comparisonOn(name string) (comparison, error) {
+	topic := 0
+	for _, field := range p.event.Fields {
+		if field.Indexed {
+			topic++
+		}
+		if !strings.EqualFold(field.Name, name) {
+			continue
+		}
+		c := comparison{field: field.Name}
+		if field.Indexed {
+			c.topic = topic
+		}
+		switch field.Type.T {
+		case abi.IntTy, abi.UintTy:
+			c.kind = numericKind
+		case abi.AddressTy:
+			c.kind = addressKind
+		case abi.BoolTy:
+			c.kind = boolKind
+		case abi.FixedBytesTy, abi.HashTy:
+			c.kind = hexKind
+		case abi.BytesTy:
+			c.kind = hexKind
+			if field.Indexed {
+				c.kind = hashedKind
+			}
+		case abi.StringTy:
+			c.kind = textKind
+			if field.Indexed {
+				c.kind = hashedKind
+			}
+		default:
+			return comparison{}, fmt.Errorf("%w: %s of type %s can't be filtered on", ErrInvalidFilter, field.Name, field.Type.String())
+		}
+		return c, nil
+	}
+	return comparison{}, fmt.Errorf("%w: event %s has no field %s", ErrInvalidFilter, p.event.Name, name)
+}
+
+// Parses a literal compared with the field, normalizing it to the form the field's values are converted to
+func (p *parser) parseLiteral(c comparison) (literal, error) {
+	t := p.peek()
+	if t.kind != tokenNumber && t.kind != tokenString && t.kind != tokenIdent {
+		return literal{}, p.unexpected()
+	}
+	p.next()
+	invalid := fmt.Errorf("%w: %q is not a valid value for %s", ErrInvalidFilter, t.text, c.field)
+
+	switch c.kind {
+	case numericKind:
+		number, ok := new(big.Int).SetString(t.text, 0)
+		if !ok {
+			return literal{}, invalid
+		}
+		return literal{value: number.String(), number: number, topic: common.BigToHash(math.U256(new(big.Int).Set(number)))}, nil
+	case addressKind:
+		if !common.IsHexAddress(t.text) {
+			return literal{}, invalid
+		}
+		address := common.HexToAddress(t.text)
+		return literal{value: strings.ToLower(address.Hex()), topic: address.Hash()}, nil
+	case boolKind:
+		switch strings.ToLower(t.text) {
+		case "true":
+			return literal{value: "true", topic: common.BigToHash(big.NewInt(1))}, nil
+		case "false":
+			return literal{value: "false", topic: common.Hash{}}, nil
+		}
+		return literal{}, invalid
+	case hexKind:
+		b, err := hexutil.Decode(t.text)
+		if err != nil {
+			return literal{}, invalid
+		}
+		// Fixed size bytes are right padded, as solidity does with shorter literals
+		field := p.fieldType(c.field)
+		if field.T == abi.FixedBytesTy {
+			if len(b) > field.Size {
+				return literal{}, invalid
+			}
+			b = append(b, make([]byte, field.Size-len(b))...)
+		}
+		var topic common.Hash
+		copy(topic[:], b)
+		return literal{value: hexutil.Encode(b), topic: topic}, nil
+	case hashedKind:
+		preimage := []byte(t.text)
+		if p.fieldType(c.field).T == abi.BytesTy {
+			b, err := hexutil.Decode(t.text)
+			if err != nil {
+				return literal{}, invalid
+			}
+			preimage = b
+		}
+		hash := crypto.Keccak256Hash(preimage)
+		return literal{value: hash.Hex(), topic: hash}, nil
+	default:
+		return literal{value: t.text}, nil
+	}
+}
+
+func (p *parser) fieldType(name string) abi.Type {
+	for _, field := range p.event.Fields {
+		if field.Name == name {
+			return field.Type
+		}
+	}
+	return abi.Type{}
+}
+
+// Returns the topics allowed by the equality conditions on indexed fields that hold for every match: the root
+// condition, or the conditions it is a conjunction of
+func topics(root node) [][]common.Hash {
+	conditions := []node{root}
+	if conjunction, ok := root.(allOf); ok {
+		conditions = conjunction
+	}
+
+	var result [][]common.Hash
+	for _, condition := range conditions {
+		c, ok := condition.(comparison)
+		if !ok || c.topic == 0 || (c.op != "==" && c.op != "in") {
+			continue
+		}
+		allowed := make([]common.Hash, len(c.literals))
+		for i, l := range c.literals {
+			allowed[i] = l.topic
+		}
+		for len(result) < c.topic {
+			result = append(result, nil)
+		}
+		// Of several conditions on a field, the most restrictive is used
+		if result[c.topic-1] == nil || len(allowed) < len(result[c.topic-1]) {
+			result[c.topic-1] = allowed
+		}
+	}
+	return result
+}
@@ -0,0 +1,199 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package filter_test
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/filter"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const orderAbi = `[{"anonymous":false,"name":"Order","type":"event","inputs":[
+	{"indexed":true,"name":"maker","type":"address"},
+	{"indexed":true,"name":"pair","type":"bytes32"},
+	{"indexed":true,"name":"tag","type":"string"},
+	{"indexed":false,"name":"amount","type":"uint256"},
+	{"indexed":false,"name":"delta","type":"int256"},
+	{"indexed":false,"name":"filled","type":"bool"},
+	{"indexed":false,"name":"memo","type":"string"},
+	{"indexed":false,"name":"ids","type":"uint256[]"}]}]`
+
+var _ = Describe("Filter", func() {
+	var (
+		event  types.Event
+		maker  = "0x000000000000000000000000000000000000dEaD"
+		other  = "0x0000000000000000000000000000000000000bEE"
+		pair   = "0x4554480000000000000000000000000000000000000000000000000000000000"
+		values map[string]string
+	)
+
+	BeforeEach(func() {
+		parsed, err := abi.JSON(strings.NewReader(orderAbi))
+		Expect(err).NotTo(HaveOccurred())
+		event = types.NewEvent(parsed.Events["Order"])
+		values = map[string]string{
+			"maker":  maker,
+			"pair":   pair,
+			"tag":    crypto.Keccak256Hash([]byte("limit")).Hex(),
+			"amount": "1500",
+			"delta":  "-20",
+			"filled": "true",
+			"memo":   "hello",
+			"ids":    `["1","2"]`,
+		}
+	})
+
+	matches := func(expression string) bool {
+		f, err := filter.Compile(expression, event)
+		Expect(err).NotTo(HaveOccurred())
+		return f.Matches(values)
+	}
+
+	Describe("Matches", func() {
+		It("compares addresses case insensitively", func() {
+			Expect(matches("maker == " + strings.ToLower(maker))).To(BeTrue())
+			Expect(matches("maker == " + other)).To(BeFalse())
+			Expect(matches("maker != " + other)).To(BeTrue())
+		})
+
+		It("compares numbers numerically", func() {
+			Expect(matches("amount == 1500")).To(BeTrue())
+			Expect(matches("amount == 0x5dc")).To(BeTrue())
+			Expect(matches("amount > 1000")).To(BeTrue())
+			Expect(matches("amount >= 1500")).To(BeTrue())
+			Expect(matches("amount < 1500")).To(BeFalse())
+			Expect(matches("amount <= 1499")).To(BeFalse())
+			Expect(matches("delta < 0")).To(BeTrue())
+		})
+
+		It("checks set membership", func() {
+			Expect(matches("amount IN (1, 1500, 2000)")).To(BeTrue())
+			Expect(matches("memo in ('bye', 'hello')")).To(BeTrue())
+			Expect(matches("maker IN (" + other + ")")).To(BeFalse())
+		})
+
+		It("compares bools, bytes and strings", func() {
+			Expect(matches("filled == true")).To(BeTrue())
+			Expect(matches("pair == 0x455448")).To(BeTrue())
+			Expect(matches(`memo == "hello"`)).To(BeTrue())
+		})
+
+		It("compares indexed strings by their hash", func() {
+			Expect(matches("tag == 'limit'")).To(BeTrue())
+			Expect(matches("tag == 'market'")).To(BeFalse())
+		})
+
+		It("combines conditions with AND and OR, AND binding tighter", func() {
+			Expect(matches("amount > 2000 OR filled == true AND memo == 'hello'")).To(BeTrue())
+			Expect(matches("(amount > 2000 OR filled == true) AND memo == 'bye'")).To(BeFalse())
+			Expect(matches("amount > 2000 or (filled == false and memo == 'hello')")).To(BeFalse())
+		})
+
+		It("matches field names case insensitively", func() {
+			Expect(matches("Amount == 1500")).To(BeTrue())
+		})
+
+		It("doesn't match values that are missing", func() {
+			delete(values, "amount")
+
+			Expect(matches("amount > 1")).To(BeFalse())
+		})
+	})
+
+	Describe("Compile", func() {
+		It("rejects invalid expressions", func() {
+			for _, expression := range []string{
+				"",
+				"amount >",
+				"amount = 1",
+				"amount == 1 AND",
+				"(amount == 1",
+				"amount IN 1",
+				"amount == 'x'",
+				"maker == 0x1234",
+				"maker > " + maker,
+				"pair == 0x" + strings.Repeat("00", 33),
+				"filled == yes",
+				"ids == 1",
+				"unknown == 1",
+				"memo == 'unterminated",
+			} {
+				_, err := filter.Compile(expression, event)
+				Expect(errors.Is(err, filter.ErrInvalidFilter)).To(BeTrue(), expression)
+			}
+		})
+	})
+
+	Describe("Topics", func() {
+		It("restricts the topics of indexed fields compared for equality in every match", func() {
+			f, err := filter.Compile("tag == 'limit' AND maker IN ("+maker+", "+other+") AND amount > 5", event)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(f.Topics()).To(Equal([][]common.Hash{
+				{common.HexToAddress(maker).Hash(), common.HexToAddress(other).Hash()},
+				nil,
+				{crypto.Keccak256Hash([]byte("limit"))},
+			}))
+		})
+
+		It("pads fixed size bytes to the right", func() {
+			f, err := filter.Compile("pair == 0x455448", event)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(f.Topics()).To(Equal([][]common.Hash{nil, {common.HexToHash(pair)}}))
+		})
+
+		It("uses the most restrictive condition on a field", func() {
+			f, err := filter.Compile("maker IN ("+maker+", "+other+") AND (maker == "+maker+")", event)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(f.Topics()).To(Equal([][]common.Hash{{common.HexToAddress(maker).Hash()}}))
+		})
+
+		It("doesn't restrict topics for alternatives", func() {
+			f, err := filter.Compile("maker == "+maker+" OR amount > 5", event)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(f.Topics()).To(BeEmpty())
+		})
+
+		It("doesn't restrict topics of anonymous events", func() {
+			event.Anonymous = true
+			f, err := filter.Compile("maker == "+maker, event)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(f.Topics()).To(BeEmpty())
+		})
+
+		It("encodes negative numbers in two's complement", func() {
+			intEvent := types.Event{Name: "Delta", Fields: []types.Field{{Argument: abi.Argument{Name: "delta", Type: event.Fields[4].Type, Indexed: true}}}}
+			f, err := filter.Compile("delta == -1", intEvent)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(f.Topics()).To(Equal([][]common.Hash{{common.BigToHash(math.U256(big.NewInt(-1)))}}))
+		})
+	})
+})
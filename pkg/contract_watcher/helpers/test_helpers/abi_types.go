@@ -0,0 +1,131 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package test_helpers
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/contract"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/helpers/test_helpers/mocks"
+	"github.com/makerdao/vulcanizedb/pkg/eth"
+	. "github.com/onsi/gomega"
+)
+
+// AbiTypesAbiString declares an event covering the abi types the contract watcher decodes, including ABIEncoderV2
+// structs and nested arrays
+const AbiTypesAbiString = `[{"anonymous":false,"name":"Everything","type":"event","inputs":[
+	{"indexed":true,"name":"owner","type":"address"},
+	{"indexed":true,"name":"tags","type":"uint256[]"},
+	{"indexed":false,"name":"small","type":"uint8"},
+	{"indexed":false,"name":"signed","type":"int256"},
+	{"indexed":false,"name":"flag","type":"bool"},
+	{"indexed":false,"name":"data","type":"bytes"},
+	{"indexed":false,"name":"id","type":"bytes32"},
+	{"indexed":false,"name":"selector","type":"bytes4"},
+	{"indexed":false,"name":"label","type":"string"},
+	{"indexed":false,"name":"amounts","type":"uint256[]"},
+	{"indexed":false,"name":"pair","type":"address[2]"},
+	{"indexed":false,"name":"ids","type":"bytes32[]"},
+	{"indexed":false,"name":"flags","type":"bool[]"},
+	{"indexed":false,"name":"order","type":"tuple","components":[
+		{"name":"amount","type":"uint256"},
+		{"name":"recipient","type":"address"},
+		{"name":"meta","type":"tuple","components":[{"name":"ok","type":"bool"},{"name":"note","type":"string"}]}]},
+	{"indexed":false,"name":"orders","type":"tuple[]","components":[
+		{"name":"amount","type":"uint256"},
+		{"name":"recipient","type":"address"}]},
+	{"indexed":false,"name":"matrix","type":"uint256[][]"}]}]`
+
+// AbiTypesOrderMeta, AbiTypesOrder, and AbiTypesOrderSummary mirror the tuples of the Everything event
+type AbiTypesOrderMeta struct {
+	Ok   bool
+	Note string
+}
+
+type AbiTypesOrder struct {
+	Amount    *big.Int
+	Recipient common.Address
+	Meta      AbiTypesOrderMeta
+}
+
+type AbiTypesOrderSummary struct {
+	Amount    *big.Int
+	Recipient common.Address
+}
+
+// AbiTypesSignature is the signature of the Everything event, which keys it among the contract's events
+const AbiTypesSignature = "Everything(address,uint256[],uint8,int256,bool,bytes,bytes32,bytes4,string,uint256[],address[2],bytes32[],bool[],(uint256,address,(bool,string)),(uint256,address)[],uint256[][])"
+
+var (
+	AbiTypesContractAddress = "0x00000000000000000000000000000000000abcde"
+	AbiTypesOwner           = common.HexToAddress("0x000000000000000000000000000000000000Af21")
+	AbiTypesTagsHash        = common.HexToHash("0x633f94affdcabe07c000231f85c752c97b9cc43966b432ec4d18641e6d178233")
+	AbiTypesRecipient       = common.HexToAddress("0x09BbBBE21a5975cAc061D82f7b843bCE061BA391")
+	AbiTypesID              = common.HexToHash("0x01")
+)
+
+// AbiTypesLog returns a log of the Everything event
+func AbiTypesLog() types.Log {
+	parsedAbi, err := eth.ParseAbi(AbiTypesAbiString)
+	Expect(err).NotTo(HaveOccurred())
+	event := parsedAbi.Events["Everything"]
+
+	signed, _ := new(big.Int).SetString("-1000000000000000000000", 10)
+	data, err := event.Inputs.NonIndexed().Pack(
+		uint8(7),
+		signed,
+		true,
+		[]byte{1, 2, 3},
+		[32]byte(AbiTypesID),
+		[4]byte{0xde, 0xad, 0xbe, 0xef},
+		"hello, world",
+		[]*big.Int{big.NewInt(1), big.NewInt(2)},
+		[2]common.Address{AbiTypesOwner, AbiTypesRecipient},
+		[][32]byte{AbiTypesID, {}},
+		[]bool{true, false},
+		AbiTypesOrder{Amount: big.NewInt(100), Recipient: AbiTypesRecipient, Meta: AbiTypesOrderMeta{Ok: true, Note: "paid"}},
+		[]AbiTypesOrderSummary{{Amount: big.NewInt(5), Recipient: AbiTypesOwner}},
+		[][]*big.Int{{big.NewInt(1)}, {big.NewInt(2), big.NewInt(3)}},
+	)
+	Expect(err).NotTo(HaveOccurred())
+
+	return types.Log{
+		Address:     common.HexToAddress(AbiTypesContractAddress),
+		Topics:      []common.Hash{event.ID, common.BytesToHash(AbiTypesOwner.Bytes()), AbiTypesTagsHash},
+		Data:        data,
+		BlockNumber: 1,
+		TxIndex:     2,
+		Index:       3,
+	}
+}
+
+func SetupAbiTypesContract() *contract.Contract {
+	p := mocks.NewParser(AbiTypesAbiString)
+	err := p.Parse()
+	Expect(err).NotTo(HaveOccurred())
+
+	return contract.Contract{
+		Address:       AbiTypesContractAddress,
+		StartingBlock: 1,
+		Abi:           p.Abi(),
+		ParsedAbi:     p.ParsedAbi(),
+		Events:        p.GetEvents([]string{"Everything"}),
+		FilterArgs:    map[string]bool{},
+	}.Init()
+}
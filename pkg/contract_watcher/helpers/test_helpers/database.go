@@ -167,12 +167,7 @@ func TearDown(db *postgres.DB) {
 	_, err = tx.Exec(`DELETE FROM public.receipts`)
 	Expect(err).NotTo(HaveOccurred())
 
-	_, err = tx.Exec(`DROP TABLE public.checked_headers`)
-	Expect(err).NotTo(HaveOccurred())
-
-	_, err = tx.Exec(`CREATE TABLE public.checked_headers (
-    	id SERIAL PRIMARY KEY,
-    	header_id INTEGER UNIQUE NOT NULL REFERENCES headers (id) ON DELETE CASCADE);`)
+	_, err = tx.Exec(`DELETE FROM public.checked_events`)
 	Expect(err).NotTo(HaveOccurred())
 
 	_, err = tx.Exec(`DROP SCHEMA IF EXISTS cw_0x8dd5fbce2f6a956c3022ba3663759011dd51e73e CASCADE`)
@@ -181,9 +176,9 @@ func TearDown(db *postgres.DB) {
 	_, err = tx.Exec(`DROP SCHEMA IF EXISTS cw_0x314159265dd8dbb310642f98f50c066173c1259b CASCADE`)
 	Expect(err).NotTo(HaveOccurred())
 
-	err = tx.Commit()
+	_, err = tx.Exec(`DROP SCHEMA IF EXISTS cw_0x00000000000000000000000000000000000abcde CASCADE`)
 	Expect(err).NotTo(HaveOccurred())
 
-	_, err = db.Exec(`VACUUM public.checked_headers`)
+	err = tx.Commit()
 	Expect(err).NotTo(HaveOccurred())
 }
@@ -59,10 +59,12 @@ func (p *parser) Parse() error {
 func (p *parser) GetEvents(wanted []string) map[string]types.Event {
 	events := map[string]types.Event{}
 
-	for _, e := range p.parsedAbi.Events {
-		if len(wanted) == 0 || stringInSlice(wanted, e.Name) {
-			event := types.NewEvent(e)
-			events[e.Name] = event
+	names := types.EventNames(p.parsedAbi)
+	for key, e := range p.parsedAbi.Events {
+		event := types.NewEvent(e)
+		event.Name = names[key]
+		if len(wanted) == 0 || stringInSlice(wanted, event.RawName) || stringInSlice(wanted, event.Name) {
+			events[event.Signature()] = event
 		}
 	}
 
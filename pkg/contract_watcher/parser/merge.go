@@ -0,0 +1,80 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/makerdao/vulcanizedb/pkg/eth"
+)
+
+type abiEntry struct {
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Inputs []struct {
+		Type string `json:"type"`
+	} `json:"inputs"`
+}
+
+// MergeAbis combines abis into one, as for a proxy and its implementation
+// Entries of later abis replace those of earlier abis with the same type, name, and input types
+func MergeAbis(abis ...string) (string, error) {
+	merged := make([]json.RawMessage, 0)
+	positions := make(map[string]int)
+	for _, abiStr := range abis {
+		var entries []json.RawMessage
+		err := json.Unmarshal([]byte(abiStr), &entries)
+		if err != nil {
+			return "", fmt.Errorf("error decoding abi: %w", err)
+		}
+		for _, entry := range entries {
+			var e abiEntry
+			err = json.Unmarshal(entry, &e)
+			if err != nil {
+				return "", fmt.Errorf("error decoding abi entry: %w", err)
+			}
+			key := entryKey(e)
+			if i, ok := positions[key]; ok {
+				merged[i] = entry
+				continue
+			}
+			positions[key] = len(merged)
+			merged = append(merged, entry)
+		}
+	}
+
+	mergedAbi, err := json.Marshal(merged)
+	if err != nil {
+		return "", err
+	}
+	_, err = eth.ParseAbi(string(mergedAbi))
+	return string(mergedAbi), err
+}
+
+func entryKey(e abiEntry) string {
+	inputTypes := make([]string, len(e.Inputs))
+	for i, input := range e.Inputs {
+		inputTypes[i] = input.Type
+	}
+	// Types other than functions and events (constructor, fallback, receive) appear at most once
+	if e.Type != "function" && e.Type != "event" {
+		return e.Type
+	}
+	return fmt.Sprintf("%s %s(%s)", e.Type, e.Name, strings.Join(inputTypes, ","))
+}
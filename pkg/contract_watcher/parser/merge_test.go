@@ -0,0 +1,58 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package parser_test
+
+import (
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/parser"
+	"github.com/makerdao/vulcanizedb/pkg/eth"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MergeAbis", func() {
+	var (
+		proxyAbi = `[{"type":"constructor","inputs":[{"name":"logic","type":"address"}]},
+			{"type":"fallback"},
+			{"type":"event","name":"Upgraded","inputs":[{"indexed":true,"name":"implementation","type":"address"}]},
+			{"type":"function","name":"admin","inputs":[],"outputs":[{"name":"","type":"address"}]}]`
+		implementationAbi = `[{"type":"constructor","inputs":[]},
+			{"type":"event","name":"Transfer","inputs":[{"indexed":true,"name":"from","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":false,"name":"value","type":"uint256"}]},
+			{"type":"function","name":"admin","inputs":[],"outputs":[{"name":"","type":"bytes32"}]},
+			{"type":"function","name":"balanceOf","inputs":[{"name":"owner","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+			{"type":"function","name":"balanceOf","inputs":[{"name":"owner","type":"address"},{"name":"id","type":"uint256"}],"outputs":[{"name":"","type":"uint256"}]}]`
+	)
+
+	It("Combines the entries of the abis, with later entries replacing earlier ones", func() {
+		merged, err := parser.MergeAbis(proxyAbi, implementationAbi)
+		Expect(err).NotTo(HaveOccurred())
+
+		parsed, err := eth.ParseAbi(merged)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parsed.Events).To(HaveKey("Upgraded"))
+		Expect(parsed.Events).To(HaveKey("Transfer"))
+		Expect(parsed.Methods).To(HaveLen(3))
+		Expect(parsed.Methods["admin"].Outputs[0].Type.String()).To(Equal("bytes32"))
+		Expect(parsed.Constructor.Inputs).To(BeEmpty())
+		Expect(parsed.Fallback.Type).NotTo(BeZero())
+	})
+
+	It("Fails with an invalid abi", func() {
+		_, err := parser.MergeAbis(proxyAbi, "not an abi")
+
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -18,35 +18,56 @@ package parser
 
 import (
 	"errors"
+	"fmt"
+
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/constants"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/registry"
 	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/types"
 	"github.com/makerdao/vulcanizedb/pkg/eth"
+	"github.com/sirupsen/logrus"
 )
 
+// ErrOffline is returned when parsing a contract whose abi is neither in the registry nor the look-up table, with
+// Etherscan lookups disabled
+var ErrOffline = errors.New("abi not available offline")
+
 // Parser is used to fetch and parse contract ABIs
-// It is dependent on etherscan's api
+// ABIs are read from a local registry when possible, and otherwise fetched from etherscan's api
 type Parser interface {
 	Parse(contractAddr, apiKey string) error
 	ParseAbiStr(abiStr string) error
 	Abi() string
 	ParsedAbi() abi.ABI
 	GetEvents(wanted []string) map[string]types.Event
+	GetMethods(wanted []string) (map[string]types.Method, error)
+	GetCalls(wanted []string) (map[string]types.Call, error)
 }
 
 type parser struct {
 	client    *eth.EtherScanAPI
+	registry  registry.Registry
+	offline   bool
 	abi       string
 	parsedAbi abi.ABI
 }
 
 // NewParser returns a new Parser
 func NewParser(network string) Parser {
+	return NewParserWithRegistry(network, registry.NewRegistry(), false)
+}
+
+// NewParserWithRegistry returns a new Parser that consults the registry before etherscan, and caches abis fetched
+// from etherscan into it
+// If offline, abis are never fetched from etherscan
+func NewParserWithRegistry(network string, abiRegistry registry.Registry, offline bool) Parser {
 	url := eth.GenURL(network)
 
 	return &parser{
-		client: eth.NewEtherScanClient(url),
+		client:   eth.NewEtherScanClient(url),
+		registry: abiRegistry,
+		offline:  offline,
 	}
 }
 
@@ -62,25 +83,39 @@ func (p *parser) ParsedAbi() abi.ABI {
 
 // Parse retrieves and parses the abi string
 // for the given contract address
+// The registry is consulted first, then the internal look-up table, then etherscan
 func (p *parser) Parse(contractAddr, apiKey string) error {
+	registeredAbi, err := p.registry.GetAbi(contractAddr)
+	if err == nil {
+		return p.ParseAbiStr(registeredAbi)
+	}
+	if !errors.Is(err, registry.ErrAbiNotFound) {
+		return fmt.Errorf("error reading abi registry: %w", err)
+	}
 	// If the abi is one our locally stored abis, fetch
-	// TODO: Allow users to pass abis through config
 	knownAbi, err := p.lookUp(contractAddr)
 	if err == nil {
-		p.abi = knownAbi
-		p.parsedAbi, err = eth.ParseAbi(knownAbi)
-		return err
+		return p.ParseAbiStr(knownAbi)
+	}
+	if p.offline {
+		return fmt.Errorf("no abi for contract %s: %w", contractAddr, ErrOffline)
 	}
 	// Try getting abi from etherscan
 	abiStr, err := p.client.GetAbi(contractAddr, apiKey)
 	if err != nil {
 		return err
 	}
-	//TODO: Implement other ways to fetch abi
-	p.abi = abiStr
-	p.parsedAbi, err = eth.ParseAbi(abiStr)
+	err = p.ParseAbiStr(abiStr)
+	if err != nil {
+		return err
+	}
+	// Cache the abi, so that it needn't be fetched again
+	cacheErr := p.registry.PutAbi(contractAddr, abiStr, registry.SourceEtherscan)
+	if cacheErr != nil {
+		logrus.Warnf("failed to cache abi for contract %s: %s", contractAddr, cacheErr.Error())
+	}
 
-	return err
+	return nil
 }
 
 // ParseAbiStr loads and parses an abi from a given abi string
@@ -100,7 +135,8 @@ func (p *parser) lookUp(contractAddr string) (string, error) {
 	return "", errors.New("ABI not present in lookup table")
 }
 
-// GetEvents returns wanted events as map of types.Events
+// GetEvents returns wanted events as map of types.Events, keyed by their signatures
+// Events are wanted by name, which includes all of their overloads, by distinct name, or by signature
 // Empty wanted array => all events are returned
 // Nil wanted array => no events are returned
 func (p *parser) GetEvents(wanted []string) map[string]types.Event {
@@ -110,15 +146,66 @@ func (p *parser) GetEvents(wanted []string) map[string]types.Event {
 	}
 
 	length := len(wanted)
-	for _, e := range p.parsedAbi.Events {
-		if length == 0 || stringInSlice(wanted, e.Name) {
-			events[e.Name] = types.NewEvent(e)
+	names := types.EventNames(p.parsedAbi)
+	for key, e := range p.parsedAbi.Events {
+		event := types.NewEvent(e)
+		event.Name = names[key]
+		if length == 0 || stringInSlice(wanted, event.RawName) || stringInSlice(wanted, event.Name) || stringInSlice(wanted, event.Signature()) {
+			events[event.Signature()] = event
 		}
 	}
 
 	return events
 }
 
+// GetMethods returns wanted methods as map of types.Methods
+// Unlike events, no methods are returned for an empty wanted array
+// Returns an error if a wanted method isn't in the abi or can't be polled
+func (p *parser) GetMethods(wanted []string) (map[string]types.Method, error) {
+	methods := map[string]types.Method{}
+	for _, name := range wanted {
+		m, ok := p.parsedAbi.Methods[name]
+		if !ok {
+			return nil, fmt.Errorf("method %s not found in abi", name)
+		}
+		method, err := types.NewMethod(m)
+		if err != nil {
+			return nil, err
+		}
+		methods[name] = method
+	}
+
+	return methods, nil
+}
+
+// GetCalls returns the wanted methods whose calls are decoded from transaction input, as a map of types.Calls keyed
+// by their distinct name
+// Like methods, no calls are returned for an empty wanted array; overloaded methods are all returned when given by
+// name, or individually by their distinct name or signature
+// Returns an error if a wanted method isn't in the abi
+func (p *parser) GetCalls(wanted []string) (map[string]types.Call, error) {
+	calls := map[string]types.Call{}
+	found := make(map[string]bool, len(wanted))
+	names := types.CallNames(p.parsedAbi)
+	for key, m := range p.parsedAbi.Methods {
+		for _, name := range []string{m.RawName, names[key], m.Sig} {
+			if stringInSlice(wanted, name) {
+				found[name] = true
+				call := types.NewCall(m)
+				call.Name = names[key]
+				calls[call.Name] = call
+			}
+		}
+	}
+	for _, name := range wanted {
+		if !found[name] {
+			return nil, fmt.Errorf("method %s not found in abi", name)
+		}
+	}
+
+	return calls, nil
+}
+
 func stringInSlice(list []string, s string) bool {
 	for _, b := range list {
 		if b == s {
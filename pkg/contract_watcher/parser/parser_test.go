@@ -17,11 +17,15 @@
 package parser_test
 
 import (
+	"fmt"
+
 	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/constants"
 	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/helpers/test_helpers/mocks"
 	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/parser"
 	"github.com/makerdao/vulcanizedb/pkg/eth"
+	"github.com/makerdao/vulcanizedb/pkg/fakes"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -47,9 +51,9 @@ var _ = Describe("Parser", func() {
 			Expect(parsedAbi).To(Equal(expectedAbi))
 
 			events := mp.GetEvents([]string{"Transfer"})
-			_, ok := events["Mint"]
+			_, ok := events["Mint(address,uint256)"]
 			Expect(ok).To(Equal(false))
-			e, ok := events["Transfer"]
+			e, ok := events["Transfer(address,address,uint256)"]
 			Expect(ok).To(Equal(true))
 			Expect(len(e.Fields)).To(Equal(3))
 		})
@@ -76,6 +80,54 @@ var _ = Describe("Parser", func() {
 		})
 	})
 
+	Describe("Parse with a registry", func() {
+		var (
+			abiRegistry *fakes.MockAbiRegistry
+			address     = "0x00000000000000000000000000000000000abcde"
+		)
+
+		BeforeEach(func() {
+			abiRegistry = &fakes.MockAbiRegistry{}
+		})
+
+		It("Parses the abi held in the registry", func() {
+			abiRegistry.Abis = map[string]string{address: constants.TusdAbiString}
+			p = parser.NewParserWithRegistry("", abiRegistry, true)
+
+			err = p.Parse(address, "")
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(p.Abi()).To(Equal(constants.TusdAbiString))
+			Expect(abiRegistry.PutAddresses).To(BeEmpty())
+		})
+
+		It("Falls back to the look-up table when offline", func() {
+			p = parser.NewParserWithRegistry("", abiRegistry, true)
+
+			err = p.Parse(constants.TusdContractAddress, "")
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(p.Abi()).To(Equal(constants.TusdAbiString))
+		})
+
+		It("Fails without fetching from etherscan when offline", func() {
+			p = parser.NewParserWithRegistry("", abiRegistry, true)
+
+			err = p.Parse(address, "")
+
+			Expect(err).To(MatchError(parser.ErrOffline))
+		})
+
+		It("Fails if the registry can't be read", func() {
+			abiRegistry.GetAbiErr = fakes.FakeError
+			p = parser.NewParserWithRegistry("", abiRegistry, false)
+
+			err = p.Parse(address, "")
+
+			Expect(err).To(MatchError(fakes.FakeError))
+		})
+	})
+
 	Describe("GetEvents", func() {
 		It("Returns parsed events", func() {
 			contractAddr := "0x89d24a6b4ccb1b6faa2625fe562bdd9a23260359"
@@ -84,7 +136,7 @@ var _ = Describe("Parser", func() {
 
 			events := p.GetEvents([]string{"Transfer"})
 
-			e, ok := events["Transfer"]
+			e, ok := events["Transfer(address,address,uint256)"]
 			Expect(ok).To(Equal(true))
 
 			abiTy := e.Fields[0].Type.T
@@ -105,8 +157,149 @@ var _ = Describe("Parser", func() {
 			pgTy = e.Fields[2].PgType
 			Expect(pgTy).To(Equal("NUMERIC"))
 
-			_, ok = events["Approval"]
+			_, ok = events["Approval(address,address,uint256)"]
 			Expect(ok).To(Equal(false))
 		})
+
+		Describe("Overloaded events", func() {
+			overloadedAbi := `[
+				{"anonymous":false,"name":"Transfer","type":"event","inputs":[{"indexed":true,"name":"from","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":false,"name":"value","type":"uint256"}]},
+				{"anonymous":false,"name":"Transfer","type":"event","inputs":[{"indexed":true,"name":"from","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":false,"name":"value","type":"uint256"},{"indexed":false,"name":"data","type":"bytes"}]},
+				{"anonymous":false,"name":"Approval","type":"event","inputs":[{"indexed":true,"name":"owner","type":"address"},{"indexed":true,"name":"spender","type":"address"},{"indexed":false,"name":"value","type":"uint256"}]}
+			]`
+
+			BeforeEach(func() {
+				err = p.ParseAbiStr(overloadedAbi)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("Keys events by signature, and gives overloads after the first distinct names", func() {
+				events := p.GetEvents([]string{})
+
+				Expect(events).To(HaveLen(3))
+				transfer := events["Transfer(address,address,uint256)"]
+				Expect(transfer.Name).To(Equal("Transfer"))
+				Expect(transfer.RawName).To(Equal("Transfer"))
+				Expect(transfer.Sig()).To(Equal(crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))))
+				transferWithData := events["Transfer(address,address,uint256,bytes)"]
+				sig := crypto.Keccak256Hash([]byte("Transfer(address,address,uint256,bytes)"))
+				Expect(transferWithData.Name).To(Equal(fmt.Sprintf("Transfer_%x", sig[:4])))
+				Expect(transferWithData.Sig()).To(Equal(sig))
+				Expect(events["Approval(address,address,uint256)"].Name).To(Equal("Approval"))
+			})
+
+			It("Returns all overloads of an event wanted by name", func() {
+				events := p.GetEvents([]string{"Transfer"})
+
+				Expect(events).To(HaveLen(2))
+				Expect(events).To(HaveKey("Transfer(address,address,uint256)"))
+				Expect(events).To(HaveKey("Transfer(address,address,uint256,bytes)"))
+			})
+
+			It("Returns a single overload wanted by its signature or distinct name", func() {
+				sig := crypto.Keccak256Hash([]byte("Transfer(address,address,uint256,bytes)"))
+				events := p.GetEvents([]string{"Transfer(address,address,uint256)", fmt.Sprintf("Transfer_%x", sig[:4])})
+
+				Expect(events).To(HaveLen(2))
+				Expect(events).To(HaveKey("Transfer(address,address,uint256)"))
+				Expect(events).To(HaveKey("Transfer(address,address,uint256,bytes)"))
+
+				events = p.GetEvents([]string{"Transfer(address,address,uint256,bytes)"})
+
+				Expect(events).To(HaveLen(1))
+			})
+		})
+	})
+
+	Describe("GetMethods", func() {
+		BeforeEach(func() {
+			err = p.ParseAbiStr(constants.DaiAbiString)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("Returns parsed methods", func() {
+			methods, err := p.GetMethods([]string{"totalSupply", "balanceOf"})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(methods)).To(Equal(2))
+			Expect(methods["totalSupply"].Args).To(BeEmpty())
+			Expect(methods["totalSupply"].Return.PgType).To(Equal("NUMERIC"))
+			balanceOf := methods["balanceOf"]
+			Expect(len(balanceOf.Args)).To(Equal(1))
+			Expect(balanceOf.Args[0].Type.T).To(Equal(abi.AddressTy))
+			Expect(balanceOf.Args[0].PgType).To(Equal("CHARACTER VARYING(66)"))
+		})
+
+		It("Returns no methods if none are wanted", func() {
+			methods, err := p.GetMethods(nil)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(methods).To(BeEmpty())
+		})
+
+		It("Returns an error for a method that isn't in the abi", func() {
+			_, err := p.GetMethods([]string{"notAMethod"})
+
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Returns an error for a method that isn't a view function", func() {
+			_, err := p.GetMethods([]string{"transfer"})
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("GetCalls", func() {
+		BeforeEach(func() {
+			err = p.ParseAbiStr(constants.DaiAbiString)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("Returns parsed calls, including those of methods that aren't view functions", func() {
+			calls, err := p.GetCalls([]string{"transfer", "setOwner"})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(calls)).To(Equal(2))
+			transfer := calls["transfer"]
+			Expect(transfer.ID).To(Equal([]byte{0xa9, 0x05, 0x9c, 0xbb}))
+			Expect(len(transfer.Args)).To(Equal(2))
+			Expect(transfer.Args[0].Name).To(Equal("dst"))
+			Expect(transfer.Args[0].PgType).To(Equal("CHARACTER VARYING(66)"))
+			Expect(transfer.Args[1].Name).To(Equal("wad"))
+			Expect(transfer.Args[1].PgType).To(Equal("NUMERIC"))
+			Expect(calls["setOwner"].Args[0].Name).To(Equal("owner_"))
+		})
+
+		It("Returns all overloads of a method wanted by name, with distinct names", func() {
+			calls, err := p.GetCalls([]string{"mint"})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(calls)).To(Equal(2))
+			Expect(calls["mint_40c10f19"].RawName).To(Equal("mint"))
+			Expect(calls["mint_a0712d68"].RawName).To(Equal("mint"))
+		})
+
+		It("Returns a single overload wanted by its signature or distinct name", func() {
+			calls, err := p.GetCalls([]string{"mint(uint256)", "approve_daea85c5"})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(calls)).To(Equal(2))
+			Expect(calls).To(HaveKey("mint_a0712d68"))
+			Expect(calls).To(HaveKey("approve_daea85c5"))
+		})
+
+		It("Returns no calls if none are wanted", func() {
+			calls, err := p.GetCalls(nil)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(calls).To(BeEmpty())
+		})
+
+		It("Returns an error for a method that isn't in the abi", func() {
+			_, err := p.GetCalls([]string{"transfer", "notAMethod"})
+
+			Expect(err).To(HaveOccurred())
+		})
 	})
 })
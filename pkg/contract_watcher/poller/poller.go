@@ -0,0 +1,102 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package poller
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/contract"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/types"
+	"github.com/makerdao/vulcanizedb/pkg/core"
+	"github.com/sirupsen/logrus"
+)
+
+// Poller calls a contract's methods at a header
+type Poller interface {
+	PollMethods(con *contract.Contract, header core.Header) (map[string][]types.MethodResult, error)
+}
+
+type poller struct {
+	fetcher core.ContractDataFetcher
+}
+
+// NewPoller returns a new Poller
+func NewPoller(fetcher core.ContractDataFetcher) Poller {
+	return &poller{
+		fetcher: fetcher,
+	}
+}
+
+// PollMethods calls each of the contract's methods at the header's block, once for each combination of values emitted
+// since the last poll that can be passed as its arguments, up to the contract's MaxMethodCalls; returns a map of
+// method names to their results
+// Calls that revert (e.g. for arguments the method rejects) are skipped
+func (p *poller) PollMethods(con *contract.Contract, header core.Header) (map[string][]types.MethodResult, error) {
+	results := make(map[string][]types.MethodResult, len(con.Methods))
+	for name, method := range con.Methods {
+		methodArgs, truncated := con.MethodArgs(method)
+		if truncated {
+			logrus.Warnf("calling %s on contract %s with only the first %d combinations of emitted arguments at "+
+				"block %d; raise maxMethodCalls to call it with all of them", name, con.Address, con.MaxMethodCalls,
+				header.BlockNumber)
+		}
+		for _, args := range methodArgs {
+			output, callErr := p.call(con, method, args, header.BlockNumber)
+			if callErr != nil {
+				if isRevert(callErr) {
+					logrus.Debugf("call to %s on contract %s with args %v reverted at block %d", name, con.Address,
+						args, header.BlockNumber)
+					continue
+				}
+				return nil, fmt.Errorf("error calling %s on contract %s at block %d: %w", name, con.Address,
+					header.BlockNumber, callErr)
+			}
+			results[name] = append(results[name], types.MethodResult{
+				HeaderID: header.Id,
+				Inputs:   args,
+				Output:   output,
+			})
+		}
+	}
+
+	return results, nil
+}
+
+func (p *poller) call(con *contract.Contract, method types.Method, args []string, blockNumber int64) (string, error) {
+	methodArgs := make([]interface{}, len(args))
+	for i, arg := range args {
+		if method.Args[i].Type.T == abi.AddressTy {
+			methodArgs[i] = common.HexToAddress(arg)
+		} else {
+			methodArgs[i] = common.HexToHash(arg)
+		}
+	}
+
+	var output interface{}
+	err := p.fetcher.FetchContractData(con.Abi, con.Address, method.Name, methodArgs, &output, blockNumber)
+	if err != nil {
+		return "", err
+	}
+	return types.ValueToString(output)
+}
+
+func isRevert(err error) bool {
+	return strings.Contains(err.Error(), "execution reverted")
+}
@@ -0,0 +1,133 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package poller_test
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/constants"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/contract"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/parser"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/poller"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/types"
+	"github.com/makerdao/vulcanizedb/pkg/core"
+	"github.com/makerdao/vulcanizedb/pkg/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Poller", func() {
+	var (
+		blockChain *fakes.MockBlockChain
+		p          poller.Poller
+		con        *contract.Contract
+		header     = core.Header{Id: 5, BlockNumber: 100}
+		owner      = common.HexToAddress("0x1")
+	)
+
+	BeforeEach(func() {
+		blockChain = fakes.NewMockBlockChain()
+		p = poller.NewPoller(blockChain)
+
+		prsr := parser.NewParser("")
+		Expect(prsr.ParseAbiStr(constants.DaiAbiString)).To(Succeed())
+		con = contract.Contract{
+			Address: "0x89d24a6b4ccb1b6faa2625fe562bdd9a23260359",
+			Abi:     prsr.Abi(),
+		}.Init()
+	})
+
+	setMethods := func(names ...string) {
+		prsr := parser.NewParser("")
+		Expect(prsr.ParseAbiStr(constants.DaiAbiString)).To(Succeed())
+		methods, err := prsr.GetMethods(names)
+		Expect(err).NotTo(HaveOccurred())
+		con.Methods = methods
+	}
+
+	It("calls methods without arguments at the header's block", func() {
+		setMethods("totalSupply")
+		blockChain.FetchContractDataResult = big.NewInt(1000)
+
+		results, err := p.PollMethods(con, header)
+
+		Expect(err).NotTo(HaveOccurred())
+		blockChain.AssertFetchContractDataCalledWith(con.Abi, con.Address, "totalSupply", []interface{}{}, new(interface{}), 100)
+		Expect(results).To(Equal(map[string][]types.MethodResult{
+			"totalSupply": {{HeaderID: 5, Inputs: []string{}, Output: "1000"}},
+		}))
+	})
+
+	It("calls methods with each emitted value as an argument", func() {
+		setMethods("balanceOf")
+		con.AddEmittedAddr(owner)
+		blockChain.FetchContractDataResult = big.NewInt(7)
+
+		results, err := p.PollMethods(con, header)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(blockChain.FetchContractDataPassedArgs).To(Equal([][]interface{}{{owner}}))
+		Expect(results["balanceOf"]).To(Equal([]types.MethodResult{
+			{HeaderID: 5, Inputs: []string{owner.Hex()}, Output: "7"},
+		}))
+	})
+
+	It("calls methods with at most the contract's maximum number of argument combinations", func() {
+		setMethods("balanceOf")
+		con.MaxMethodCalls = 1
+		con.AddEmittedAddr(owner, common.HexToAddress("0x2"))
+		blockChain.FetchContractDataResult = big.NewInt(7)
+
+		results, err := p.PollMethods(con, header)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(blockChain.FetchContractDataPassedArgs).To(Equal([][]interface{}{{owner}}))
+		Expect(results["balanceOf"]).To(HaveLen(1))
+	})
+
+	It("doesn't call methods with arguments before any values are emitted", func() {
+		setMethods("balanceOf")
+
+		results, err := p.PollMethods(con, header)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(blockChain.FetchContractDataPassedArgs).To(BeEmpty())
+		Expect(results["balanceOf"]).To(BeEmpty())
+	})
+
+	It("skips calls that revert", func() {
+		setMethods("totalSupply")
+		blockChain.SetFetchContractDataErr(errors.New("execution reverted"))
+
+		results, err := p.PollMethods(con, header)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(results["totalSupply"]).To(BeEmpty())
+	})
+
+	It("returns an error if a call fails", func() {
+		setMethods("totalSupply")
+		blockChain.SetFetchContractDataErr(fakes.FakeError)
+
+		_, err := p.PollMethods(con, header)
+
+		Expect(err).To(HaveOccurred())
+		Expect(errors.Is(err, fakes.FakeError)).To(BeTrue())
+	})
+})
@@ -0,0 +1,126 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package proxy
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/makerdao/vulcanizedb/pkg/core"
+	"github.com/makerdao/vulcanizedb/pkg/eth"
+)
+
+var (
+	// EIP-1967 storage slots, holding the implementation or the beacon that provides it
+	ImplementationSlot = common.HexToHash("0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc")
+	BeaconSlot         = common.HexToHash("0xa3f0ad74e5423aebfd80d3ef4346578335a9a72aeaee59ff6cb3582b35133d50")
+	// Implementation slot of OpenZeppelin proxies preceding EIP-1967
+	LegacyImplementationSlot = common.HexToHash("0x7050c9e0f4ca769c69bd3a8ef740bc37934f8e2c036e5a723fd8ee048ed3f8c3")
+
+	UpgradedSig       = crypto.Keccak256Hash([]byte("Upgraded(address)"))
+	BeaconUpgradedSig = crypto.Keccak256Hash([]byte("BeaconUpgraded(address)"))
+)
+
+// UpgradeEvents are the events proxies emit when their implementation changes
+var UpgradeEvents = []string{"Upgraded", "BeaconUpgraded"}
+
+// UpgradeEventsAbi declares UpgradeEvents, for proxies whose abi doesn't
+const UpgradeEventsAbi = `[{"anonymous":false,"inputs":[{"indexed":true,"name":"implementation","type":"address"}],"name":"Upgraded","type":"event"},{"anonymous":false,"inputs":[{"indexed":true,"name":"beacon","type":"address"}],"name":"BeaconUpgraded","type":"event"}]`
+
+const implementationAbi = `[{"constant":true,"inputs":[],"name":"implementation","outputs":[{"name":"","type":"address"}],"payable":false,"stateMutability":"view","type":"function"}]`
+
+// Resolver finds the implementation behind a proxy contract
+type Resolver interface {
+	Implementation(proxyAddr, proxyAbi string, blockNumber int64) (string, error)
+}
+
+type resolver struct {
+	blockChain core.BlockChain
+}
+
+// NewResolver returns a Resolver reading proxy state from the blockchain
+func NewResolver(blockChain core.BlockChain) Resolver {
+	return resolver{blockChain: blockChain}
+}
+
+// Implementation returns the lower case address of the proxy's implementation at the block, or an empty string if
+// the contract isn't a proxy
+// EIP-1967 (and legacy OpenZeppelin) proxies are detected by their storage slots, including beacon proxies, and
+// EIP-897 proxies by their implementation() function
+func (r resolver) Implementation(proxyAddr, proxyAbi string, blockNumber int64) (string, error) {
+	slots := []common.Hash{ImplementationSlot, BeaconSlot, LegacyImplementationSlot}
+	values, err := r.blockChain.BatchGetStorageAt(common.HexToAddress(proxyAddr), slots, big.NewInt(blockNumber))
+	if err != nil {
+		return "", fmt.Errorf("error reading proxy slots of %s: %w", proxyAddr, err)
+	}
+	if implementation := slotAddress(values[ImplementationSlot]); implementation != "" {
+		return implementation, nil
+	}
+	if beacon := slotAddress(values[BeaconSlot]); beacon != "" {
+		return r.callImplementation(implementationAbi, beacon, blockNumber)
+	}
+	if implementation := slotAddress(values[LegacyImplementationSlot]); implementation != "" {
+		return implementation, nil
+	}
+	if hasImplementationFunction(proxyAbi) {
+		return r.callImplementation(proxyAbi, proxyAddr, blockNumber)
+	}
+	return "", nil
+}
+
+func (r resolver) callImplementation(abiStr, addr string, blockNumber int64) (string, error) {
+	var result interface{}
+	err := r.blockChain.FetchContractData(abiStr, addr, "implementation", nil, &result, blockNumber)
+	if err != nil {
+		return "", fmt.Errorf("error calling implementation() on %s: %w", addr, err)
+	}
+	implementation, ok := result.(common.Address)
+	if !ok {
+		return "", fmt.Errorf("error calling implementation() on %s: unexpected result %v", addr, result)
+	}
+	if implementation == (common.Address{}) {
+		return "", nil
+	}
+	return strings.ToLower(implementation.Hex()), nil
+}
+
+func slotAddress(value []byte) string {
+	address := common.BytesToAddress(value)
+	if address == (common.Address{}) {
+		return ""
+	}
+	return strings.ToLower(address.Hex())
+}
+
+func hasImplementationFunction(abiStr string) bool {
+	parsedAbi, err := eth.ParseAbi(abiStr)
+	if err != nil {
+		return false
+	}
+	method, ok := parsedAbi.Methods["implementation"]
+	return ok && len(method.Inputs) == 0 && len(method.Outputs) == 1 && method.Outputs[0].Type.T == abi.AddressTy
+}
+
+// IsUpgrade returns true for logs of UpgradeEvents
+func IsUpgrade(log types.Log) bool {
+	return len(log.Topics) > 0 && (log.Topics[0] == UpgradedSig || log.Topics[0] == BeaconUpgradedSig)
+}
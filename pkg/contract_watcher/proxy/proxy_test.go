@@ -0,0 +1,122 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package proxy_test
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/proxy"
+	"github.com/makerdao/vulcanizedb/pkg/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Resolver", func() {
+	var (
+		blockChain        *fakes.MockBlockChain
+		resolver          proxy.Resolver
+		proxyAddr         = "0x00000000000000000000000000000000000abcde"
+		implementation    = common.HexToAddress("0x09BbBBE21a5975cAc061D82f7b843bCE061BA391")
+		beacon            = common.HexToAddress("0x000000000000000000000000000000000000Af21")
+		implementationHex = "0x09bbbbe21a5975cac061d82f7b843bce061ba391"
+		eip897Abi         = `[{"constant":true,"inputs":[],"name":"implementation","outputs":[{"name":"","type":"address"}],"payable":false,"stateMutability":"view","type":"function"}]`
+	)
+
+	BeforeEach(func() {
+		blockChain = fakes.NewMockBlockChain()
+		resolver = proxy.NewResolver(blockChain)
+	})
+
+	It("reads the EIP-1967 implementation slot at the block", func() {
+		blockChain.SetStorageKeyValueToReturn(common.HexToAddress(proxyAddr), proxy.ImplementationSlot, common.LeftPadBytes(implementation.Bytes(), 32))
+
+		result, err := resolver.Implementation(proxyAddr, "[]", 100)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(implementationHex))
+		Expect(blockChain.BatchGetStorageAtCalls).To(ConsistOf(fakes.BatchGetStorageAtCall{
+			Account:     common.HexToAddress(proxyAddr),
+			Keys:        []common.Hash{proxy.ImplementationSlot, proxy.BeaconSlot, proxy.LegacyImplementationSlot},
+			BlockNumber: big.NewInt(100),
+		}))
+	})
+
+	It("calls implementation() on the beacon of an EIP-1967 beacon proxy", func() {
+		blockChain.SetStorageKeyValueToReturn(common.HexToAddress(proxyAddr), proxy.BeaconSlot, common.LeftPadBytes(beacon.Bytes(), 32))
+		blockChain.FetchContractDataResult = implementation
+
+		result, err := resolver.Implementation(proxyAddr, "[]", 100)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(implementationHex))
+		var expectedResult interface{} = implementation
+		blockChain.AssertFetchContractDataCalledWith(`[{"constant":true,"inputs":[],"name":"implementation","outputs":[{"name":"","type":"address"}],"payable":false,"stateMutability":"view","type":"function"}]`,
+			"0x000000000000000000000000000000000000af21", "implementation", nil, &expectedResult, 100)
+	})
+
+	It("reads the legacy OpenZeppelin implementation slot", func() {
+		blockChain.SetStorageKeyValueToReturn(common.HexToAddress(proxyAddr), proxy.LegacyImplementationSlot, common.LeftPadBytes(implementation.Bytes(), 32))
+
+		result, err := resolver.Implementation(proxyAddr, "[]", 100)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(implementationHex))
+	})
+
+	It("calls implementation() on EIP-897 proxies", func() {
+		blockChain.FetchContractDataResult = implementation
+
+		result, err := resolver.Implementation(proxyAddr, eip897Abi, 100)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(implementationHex))
+	})
+
+	It("returns no implementation for contracts that aren't proxies", func() {
+		result, err := resolver.Implementation(proxyAddr, "[]", 100)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(BeEmpty())
+	})
+
+	It("returns an error if the slots can't be read", func() {
+		blockChain.BatchGetStorageAtError = fakes.FakeError
+
+		_, err := resolver.Implementation(proxyAddr, "[]", 100)
+
+		Expect(err).To(MatchError(fakes.FakeError))
+	})
+
+	It("returns an error if implementation() can't be called", func() {
+		blockChain.SetFetchContractDataErr(fakes.FakeError)
+
+		_, err := resolver.Implementation(proxyAddr, eip897Abi, 100)
+
+		Expect(err).To(MatchError(fakes.FakeError))
+	})
+})
+
+var _ = Describe("IsUpgrade", func() {
+	It("identifies logs of upgrade events", func() {
+		Expect(proxy.IsUpgrade(types.Log{Topics: []common.Hash{proxy.UpgradedSig}})).To(BeTrue())
+		Expect(proxy.IsUpgrade(types.Log{Topics: []common.Hash{proxy.BeaconUpgradedSig}})).To(BeTrue())
+		Expect(proxy.IsUpgrade(types.Log{Topics: []common.Hash{{1}}})).To(BeFalse())
+		Expect(proxy.IsUpgrade(types.Log{})).To(BeFalse())
+	})
+})
@@ -0,0 +1,71 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/makerdao/vulcanizedb/pkg/eth"
+)
+
+var ErrInvalidArtifact = errors.New("no abi found in artifact")
+
+type artifact struct {
+	Abi    json.RawMessage `json:"abi"`
+	Output struct {
+		Abi json.RawMessage `json:"abi"`
+	} `json:"output"`
+}
+
+// ExtractAbi returns the abi held in a file's contents, which may be:
+// a plain abi, a Hardhat, Foundry, or Truffle artifact (the abi under the top level "abi" key),
+// or Sourcify/solc metadata (the abi under "output.abi")
+func ExtractAbi(contents []byte) (string, error) {
+	trimmed := bytes.TrimSpace(contents)
+	var abi json.RawMessage
+	if bytes.HasPrefix(trimmed, []byte("[")) {
+		abi = trimmed
+	} else {
+		var a artifact
+		err := json.Unmarshal(trimmed, &a)
+		if err != nil {
+			return "", fmt.Errorf("error decoding artifact: %w", err)
+		}
+		switch {
+		case len(a.Abi) > 0:
+			abi = a.Abi
+		case len(a.Output.Abi) > 0:
+			abi = a.Output.Abi
+		default:
+			return "", ErrInvalidArtifact
+		}
+	}
+
+	var compacted bytes.Buffer
+	err := json.Compact(&compacted, abi)
+	if err != nil {
+		return "", fmt.Errorf("error decoding abi: %w", err)
+	}
+	_, err = eth.ParseAbi(compacted.String())
+	if err != nil {
+		return "", err
+	}
+	return compacted.String(), nil
+}
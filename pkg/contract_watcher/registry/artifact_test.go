@@ -0,0 +1,76 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package registry_test
+
+import (
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/registry"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const transferAbi = `[{"anonymous":false,"inputs":[{"indexed":true,"name":"from","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":false,"name":"value","type":"uint256"}],"name":"Transfer","type":"event"}]`
+
+var _ = Describe("ExtractAbi", func() {
+	It("returns a plain abi", func() {
+		abi, err := registry.ExtractAbi([]byte("\n" + transferAbi + "\n"))
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(abi).To(Equal(transferAbi))
+	})
+
+	It("returns the abi of a Hardhat artifact", func() {
+		artifact := `{"_format": "hh-sol-artifact-1", "contractName": "Token", "sourceName": "contracts/Token.sol",
+			"abi": ` + transferAbi + `, "bytecode": "0x6080", "deployedBytecode": "0x6080", "linkReferences": {}}`
+
+		abi, err := registry.ExtractAbi([]byte(artifact))
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(abi).To(Equal(transferAbi))
+	})
+
+	It("returns the abi of a Foundry artifact", func() {
+		artifact := `{"abi": ` + transferAbi + `, "bytecode": {"object": "0x6080", "linkReferences": {}},
+			"deployedBytecode": {"object": "0x6080"}, "methodIdentifiers": {}}`
+
+		abi, err := registry.ExtractAbi([]byte(artifact))
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(abi).To(Equal(transferAbi))
+	})
+
+	It("returns the abi of Sourcify metadata", func() {
+		metadata := `{"compiler": {"version": "0.6.12+commit.27d51765"}, "language": "Solidity",
+			"output": {"abi": ` + transferAbi + `, "devdoc": {}, "userdoc": {}}, "settings": {}, "sources": {}, "version": 1}`
+
+		abi, err := registry.ExtractAbi([]byte(metadata))
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(abi).To(Equal(transferAbi))
+	})
+
+	It("returns an error if the artifact has no abi", func() {
+		_, err := registry.ExtractAbi([]byte(`{"bytecode": "0x6080"}`))
+
+		Expect(err).To(MatchError(registry.ErrInvalidArtifact))
+	})
+
+	It("returns an error if the abi is invalid", func() {
+		_, err := registry.ExtractAbi([]byte(`[{"type": "event", "inputs": [{"type": "notatype"}]}]`))
+
+		Expect(err).To(HaveOccurred())
+	})
+})
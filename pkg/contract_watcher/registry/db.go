@@ -0,0 +1,57 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package registry
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres"
+)
+
+type dbRegistry struct {
+	db *postgres.DB
+}
+
+// NewDBRegistry returns a registry backed by the public.contract_abi table
+func NewDBRegistry(db *postgres.DB) Registry {
+	return dbRegistry{db: db}
+}
+
+func (r dbRegistry) GetAbi(contractAddr string) (string, error) {
+	var abi string
+	err := r.db.Get(&abi, `SELECT abi FROM public.contract_abi WHERE address = $1`, strings.ToLower(contractAddr))
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrAbiNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("error getting abi for contract %s: %w", contractAddr, err)
+	}
+	return abi, nil
+}
+
+func (r dbRegistry) PutAbi(contractAddr, abi, source string) error {
+	_, err := r.db.Exec(`INSERT INTO public.contract_abi (address, abi, source) VALUES ($1, $2, $3)
+		ON CONFLICT (address) DO UPDATE SET abi = $2, source = $3, created = NOW()`,
+		strings.ToLower(contractAddr), abi, source)
+	if err != nil {
+		return fmt.Errorf("error storing abi for contract %s: %w", contractAddr, err)
+	}
+	return nil
+}
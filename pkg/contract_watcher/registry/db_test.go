@@ -0,0 +1,71 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package registry_test
+
+import (
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/registry"
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres"
+	"github.com/makerdao/vulcanizedb/test_config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DB registry", func() {
+	var (
+		db          *postgres.DB
+		abiRegistry registry.Registry
+		address     = "0x8dd5fbCe2F6a956C3022bA3663759011Dd51e73E"
+	)
+
+	BeforeEach(func() {
+		db = test_config.NewTestDB(test_config.NewTestNode())
+		test_config.CleanTestDB(db)
+		abiRegistry = registry.NewDBRegistry(db)
+	})
+
+	It("returns ErrAbiNotFound for an unknown contract", func() {
+		_, err := abiRegistry.GetAbi(address)
+
+		Expect(err).To(MatchError(registry.ErrAbiNotFound))
+	})
+
+	It("stores abis by lower case address", func() {
+		err := abiRegistry.PutAbi(address, transferAbi, registry.SourceImport)
+		Expect(err).NotTo(HaveOccurred())
+
+		abi, err := abiRegistry.GetAbi(address)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(abi).To(Equal(transferAbi))
+
+		var source string
+		err = db.Get(&source, `SELECT source FROM public.contract_abi WHERE address = '0x8dd5fbce2f6a956c3022ba3663759011dd51e73e'`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(source).To(Equal(registry.SourceImport))
+	})
+
+	It("replaces a stored abi", func() {
+		err := abiRegistry.PutAbi(address, "[]", registry.SourceEtherscan)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = abiRegistry.PutAbi(address, transferAbi, registry.SourceImport)
+		Expect(err).NotTo(HaveOccurred())
+
+		abi, err := abiRegistry.GetAbi(address)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(abi).To(Equal(transferAbi))
+	})
+})
@@ -0,0 +1,71 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package registry
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type directoryRegistry struct {
+	dir string
+}
+
+// NewDirectoryRegistry returns a registry of <address>.json files in dir
+// Files may hold a plain abi, a compiler artifact, or Sourcify metadata (see ExtractAbi)
+func NewDirectoryRegistry(dir string) Registry {
+	return directoryRegistry{dir: dir}
+}
+
+// GetAbi reads the abi from the contract's file, named with either the lower case or checksummed address
+func (r directoryRegistry) GetAbi(contractAddr string) (string, error) {
+	for _, name := range []string{strings.ToLower(contractAddr), common.HexToAddress(contractAddr).Hex()} {
+		path := filepath.Join(r.dir, name+".json")
+		contents, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("error reading abi file %s: %w", path, err)
+		}
+		abi, err := ExtractAbi(contents)
+		if err != nil {
+			return "", fmt.Errorf("error reading abi file %s: %w", path, err)
+		}
+		return abi, nil
+	}
+	return "", ErrAbiNotFound
+}
+
+// PutAbi writes the abi to the contract's file, named with the lower case address
+func (r directoryRegistry) PutAbi(contractAddr, abi, source string) error {
+	err := os.MkdirAll(r.dir, 0755)
+	if err != nil {
+		return fmt.Errorf("error creating abi directory: %w", err)
+	}
+	path := filepath.Join(r.dir, strings.ToLower(contractAddr)+".json")
+	err = ioutil.WriteFile(path, []byte(abi), 0644)
+	if err != nil {
+		return fmt.Errorf("error writing abi file %s: %w", path, err)
+	}
+	return nil
+}
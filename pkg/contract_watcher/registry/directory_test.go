@@ -0,0 +1,96 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package registry_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/registry"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Directory registry", func() {
+	var (
+		dir             string
+		abiRegistry     registry.Registry
+		checksumAddress = "0x8dd5fbCe2F6a956C3022bA3663759011Dd51e73E"
+		lowerAddress    = "0x8dd5fbce2f6a956c3022ba3663759011dd51e73e"
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "abis")
+		Expect(err).NotTo(HaveOccurred())
+		abiRegistry = registry.NewDirectoryRegistry(dir)
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("reads the abi from a file named with the lower case address", func() {
+		err := ioutil.WriteFile(filepath.Join(dir, lowerAddress+".json"), []byte(transferAbi), 0644)
+		Expect(err).NotTo(HaveOccurred())
+
+		abi, err := abiRegistry.GetAbi(checksumAddress)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(abi).To(Equal(transferAbi))
+	})
+
+	It("reads the abi from an artifact named with the checksummed address", func() {
+		artifact := `{"contractName": "Token", "abi": ` + transferAbi + `}`
+		err := ioutil.WriteFile(filepath.Join(dir, checksumAddress+".json"), []byte(artifact), 0644)
+		Expect(err).NotTo(HaveOccurred())
+
+		abi, err := abiRegistry.GetAbi(lowerAddress)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(abi).To(Equal(transferAbi))
+	})
+
+	It("returns ErrAbiNotFound without a file for the address", func() {
+		_, err := abiRegistry.GetAbi(lowerAddress)
+
+		Expect(err).To(MatchError(registry.ErrAbiNotFound))
+	})
+
+	It("returns an error if the file holds no abi", func() {
+		err := ioutil.WriteFile(filepath.Join(dir, lowerAddress+".json"), []byte(`{}`), 0644)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = abiRegistry.GetAbi(lowerAddress)
+
+		Expect(err).To(HaveOccurred())
+		Expect(err).NotTo(MatchError(registry.ErrAbiNotFound))
+	})
+
+	It("writes abis that it can read back", func() {
+		nestedRegistry := registry.NewDirectoryRegistry(filepath.Join(dir, "cache"))
+
+		err := nestedRegistry.PutAbi(checksumAddress, transferAbi, registry.SourceEtherscan)
+		Expect(err).NotTo(HaveOccurred())
+
+		abi, err := nestedRegistry.GetAbi(checksumAddress)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(abi).To(Equal(transferAbi))
+		Expect(filepath.Join(dir, "cache", lowerAddress+".json")).To(BeAnExistingFile())
+	})
+})
@@ -0,0 +1,72 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package registry
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sources of the abis held in a registry
+const (
+	SourceEtherscan = "etherscan"
+	SourceImport    = "import"
+)
+
+// ErrAbiNotFound is returned by registries without an abi for the requested contract
+var ErrAbiNotFound = errors.New("abi not found in registry")
+
+// Registry holds contract abis locally, so that they don't need to be fetched over the network
+type Registry interface {
+	GetAbi(contractAddr string) (string, error)
+	PutAbi(contractAddr, abi, source string) error
+}
+
+type multiRegistry struct {
+	registries []Registry
+}
+
+// NewRegistry combines registries into one
+// Abis are looked up in the given order, and stored in each of them
+func NewRegistry(registries ...Registry) Registry {
+	return multiRegistry{registries: registries}
+}
+
+// GetAbi returns the abi from the first registry that holds one for the contract
+func (r multiRegistry) GetAbi(contractAddr string) (string, error) {
+	for _, registry := range r.registries {
+		abi, err := registry.GetAbi(contractAddr)
+		if err == nil {
+			return abi, nil
+		}
+		if !errors.Is(err, ErrAbiNotFound) {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("no abi for contract %s: %w", contractAddr, ErrAbiNotFound)
+}
+
+// PutAbi stores the abi in every registry
+func (r multiRegistry) PutAbi(contractAddr, abi, source string) error {
+	for _, registry := range r.registries {
+		err := registry.PutAbi(contractAddr, abi, source)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package registry_test
+
+import (
+	"errors"
+
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/registry"
+	"github.com/makerdao/vulcanizedb/pkg/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Registry", func() {
+	var (
+		first, second *fakes.MockAbiRegistry
+		abiRegistry   registry.Registry
+		address       = "0x8dd5fbce2f6a956c3022ba3663759011dd51e73e"
+	)
+
+	BeforeEach(func() {
+		first = &fakes.MockAbiRegistry{}
+		second = &fakes.MockAbiRegistry{}
+		abiRegistry = registry.NewRegistry(first, second)
+	})
+
+	Describe("GetAbi", func() {
+		It("returns the abi from the first registry holding it", func() {
+			first.Abis = map[string]string{address: "first"}
+			second.Abis = map[string]string{address: "second"}
+
+			abi, err := abiRegistry.GetAbi(address)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(abi).To(Equal("first"))
+			Expect(second.GetAddresses).To(BeEmpty())
+		})
+
+		It("falls back to later registries", func() {
+			second.Abis = map[string]string{address: "second"}
+
+			abi, err := abiRegistry.GetAbi(address)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(abi).To(Equal("second"))
+		})
+
+		It("returns ErrAbiNotFound if no registry holds the abi", func() {
+			_, err := abiRegistry.GetAbi(address)
+
+			Expect(err).To(MatchError(registry.ErrAbiNotFound))
+
+			_, emptyErr := registry.NewRegistry().GetAbi(address)
+			Expect(emptyErr).To(MatchError(registry.ErrAbiNotFound))
+		})
+
+		It("returns other errors without consulting later registries", func() {
+			readErr := errors.New("read failed")
+			first.GetAbiErr = readErr
+			second.Abis = map[string]string{address: "second"}
+
+			_, err := abiRegistry.GetAbi(address)
+
+			Expect(err).To(MatchError(readErr))
+		})
+	})
+
+	Describe("PutAbi", func() {
+		It("stores the abi in every registry", func() {
+			err := abiRegistry.PutAbi(address, "abi", registry.SourceEtherscan)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(first.PutAbis).To(ConsistOf("abi"))
+			Expect(second.PutAbis).To(ConsistOf("abi"))
+			Expect(second.PutSources).To(ConsistOf(registry.SourceEtherscan))
+		})
+
+		It("returns an error if a registry fails to store the abi", func() {
+			putErr := errors.New("write failed")
+			first.PutAbiErr = putErr
+
+			err := abiRegistry.PutAbi(address, "abi", registry.SourceEtherscan)
+
+			Expect(err).To(MatchError(putErr))
+		})
+	})
+})
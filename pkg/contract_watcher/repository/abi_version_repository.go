@@ -0,0 +1,91 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres"
+	"github.com/sirupsen/logrus"
+)
+
+// AbiVersionRepository keeps a versioned record of the abis contracts are watched with
+type AbiVersionRepository interface {
+	RecordAbi(contractAddr, abi string) (version int64, changed bool, err error)
+}
+
+type abiVersionRepository struct {
+	db *postgres.DB
+}
+
+// NewAbiVersionRepository returns a new AbiVersionRepository backed by the public.contract_abi_version table
+func NewAbiVersionRepository(db *postgres.DB) AbiVersionRepository {
+	return &abiVersionRepository{db: db}
+}
+
+// RecordAbi stores the abi as the contract's next version if it differs from the latest one recorded
+// Returns the version of the abi, and whether it is a new version
+// Watchers recording the same contract's abi at once are serialized with a transaction-level advisory lock on its
+// address, since there may be no row to lock before its first version is recorded
+func (r *abiVersionRepository) RecordAbi(contractAddr, abi string) (int64, bool, error) {
+	addr := strings.ToLower(contractAddr)
+	tx, txErr := r.db.Beginx()
+	if txErr != nil {
+		return 0, false, fmt.Errorf("error beginning db transaction: %w", txErr)
+	}
+
+	_, lockErr := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('contract_abi_version'), hashtext($1))`, addr)
+	if lockErr != nil {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			logrus.Warnf("error rolling back transaction while recording abi: %s", rollbackErr.Error())
+		}
+		return 0, false, fmt.Errorf("error locking abi versions of %s: %w", contractAddr, lockErr)
+	}
+
+	var latest struct {
+		Version int64
+		Abi     string
+	}
+	getErr := tx.Get(&latest, `SELECT version, abi FROM public.contract_abi_version
+		WHERE address = $1 ORDER BY version DESC LIMIT 1`, addr)
+	if getErr != nil && !errors.Is(getErr, sql.ErrNoRows) {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			logrus.Warnf("error rolling back transaction while recording abi: %s", rollbackErr.Error())
+		}
+		return 0, false, fmt.Errorf("error getting abi version of %s: %w", contractAddr, getErr)
+	}
+	if getErr == nil && latest.Abi == abi {
+		return latest.Version, false, tx.Commit()
+	}
+
+	version := latest.Version + 1
+	_, insertErr := tx.Exec(`INSERT INTO public.contract_abi_version (address, version, abi) VALUES ($1, $2, $3)`,
+		addr, version, abi)
+	if insertErr != nil {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			logrus.Warnf("error rolling back transaction while recording abi: %s", rollbackErr.Error())
+		}
+		return 0, false, fmt.Errorf("error recording abi version of %s: %w", contractAddr, insertErr)
+	}
+	return version, true, tx.Commit()
+}
@@ -0,0 +1,108 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package repository_test
+
+import (
+	"sync"
+
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/repository"
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres"
+	"github.com/makerdao/vulcanizedb/test_config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AbiVersionRepository", func() {
+	var (
+		db               *postgres.DB
+		versions         repository.AbiVersionRepository
+		address          = "0x8dd5fbCe2F6a956C3022bA3663759011Dd51e73E"
+		v1Abi            = `[{"anonymous":false,"name":"Transfer","type":"event","inputs":[{"indexed":true,"name":"from","type":"address"}]}]`
+		v2Abi            = `[{"anonymous":false,"name":"Transfer","type":"event","inputs":[{"indexed":true,"name":"from","type":"address"},{"indexed":false,"name":"value","type":"uint256"}]}]`
+		recordedVersions = func() []int64 {
+			var recorded []int64
+			err := db.Select(&recorded, `SELECT version FROM public.contract_abi_version
+				WHERE address = '0x8dd5fbce2f6a956c3022ba3663759011dd51e73e' ORDER BY version`)
+			Expect(err).NotTo(HaveOccurred())
+			return recorded
+		}
+	)
+
+	BeforeEach(func() {
+		db = test_config.NewTestDB(test_config.NewTestNode())
+		test_config.CleanTestDB(db)
+		versions = repository.NewAbiVersionRepository(db)
+	})
+
+	It("records the first abi of a contract as version 1", func() {
+		version, changed, err := versions.RecordAbi(address, v1Abi)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(version).To(Equal(int64(1)))
+		Expect(changed).To(BeTrue())
+		Expect(recordedVersions()).To(Equal([]int64{1}))
+	})
+
+	It("doesn't record an unchanged abi again", func() {
+		_, _, err := versions.RecordAbi(address, v1Abi)
+		Expect(err).NotTo(HaveOccurred())
+
+		version, changed, err := versions.RecordAbi(address, v1Abi)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(version).To(Equal(int64(1)))
+		Expect(changed).To(BeFalse())
+		Expect(recordedVersions()).To(Equal([]int64{1}))
+	})
+
+	It("records a changed abi as the next version", func() {
+		_, _, err := versions.RecordAbi(address, v1Abi)
+		Expect(err).NotTo(HaveOccurred())
+
+		version, changed, err := versions.RecordAbi(address, v2Abi)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(version).To(Equal(int64(2)))
+		Expect(changed).To(BeTrue())
+		Expect(recordedVersions()).To(Equal([]int64{1, 2}))
+
+		var latest string
+		err = db.Get(&latest, `SELECT abi FROM public.contract_abi_version WHERE version = 2`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(latest).To(Equal(v2Abi))
+	})
+
+	It("records a contract's first abi once when watchers record it at the same time", func() {
+		var wg sync.WaitGroup
+		errs := make(chan error, 5)
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, _, err := versions.RecordAbi(address, v1Abi)
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(recordedVersions()).To(Equal([]int64{1}))
+	})
+})
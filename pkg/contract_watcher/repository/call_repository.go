@@ -0,0 +1,249 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/golang-lru"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/types"
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres"
+	"github.com/sirupsen/logrus"
+)
+
+const callCacheSize = 1000
+
+// CallRepository is used to read the transactions sent to watched contracts, and persist the calls decoded from their
+// input into custom tables
+type CallRepository interface {
+	GetTransactions(contractAddr string, headerID int64) ([]types.Transaction, error)
+	PersistCalls(results []types.CallResult, call types.Call, contractAddr string) error
+	CreateCallTable(contractAddr string, call types.Call) (bool, error)
+	CheckTableCache(key string) (interface{}, bool)
+}
+
+// Columns every call table has, besides those of the method's arguments
+var baseCallColumns = map[string]bool{"id": true, "header_id": true, "tx_hash": true, "tx_idx": true, "tx_from": true, "status": true}
+
+type callRepository struct {
+	db                         *postgres.DB
+	tables                     *lru.Cache        // Cache names of recently used tables to minimize db connections
+	tableColumns               map[string]string // Signature of the columns each cached table was checked against
+	allowDestructiveMigrations bool              // Whether to drop columns of existing tables that the method no longer has
+}
+
+// NewCallRepository returns a new CallRepository
+// Existing call tables are migrated to match the methods they are used with, the same way event tables are
+func NewCallRepository(db *postgres.DB, allowDestructiveMigrations bool) CallRepository {
+	ccs, _ := lru.New(callCacheSize)
+	return &callRepository{
+		db:                         db,
+		tables:                     ccs,
+		tableColumns:               map[string]string{},
+		allowDestructiveMigrations: allowDestructiveMigrations,
+	}
+}
+
+// GetTransactions returns the synced transactions sent to the contract at the header, in order, with the status of
+// their receipts if those have been synced too
+func (r *callRepository) GetTransactions(contractAddr string, headerID int64) ([]types.Transaction, error) {
+	var transactions []types.Transaction
+	err := r.db.Select(&transactions, `SELECT transactions.header_id, transactions.hash, transactions.tx_index,
+		transactions.tx_from, transactions.input_data, receipts.status
+		FROM public.transactions
+		LEFT JOIN public.receipts ON receipts.transaction_id = transactions.id
+		WHERE transactions.header_id = $1 AND LOWER(transactions.tx_to) = $2
+		ORDER BY transactions.tx_index`, headerID, strings.ToLower(contractAddr))
+	if err != nil {
+		return nil, fmt.Errorf("error getting transactions to %s: %w", contractAddr, err)
+	}
+	return transactions, nil
+}
+
+// PersistCalls creates a schema and table for the watched contract method if needed
+// Persists the decoded calls into this custom table
+func (r *callRepository) PersistCalls(results []types.CallResult, call types.Call, contractAddr string) error {
+	if len(results) == 0 {
+		return errors.New("call repository error: passed empty results slice")
+	}
+	_, tableErr := r.CreateCallTable(contractAddr, call)
+	if tableErr != nil {
+		return fmt.Errorf("error creating table for calls of %s on contract %s: %w", call.Name, contractAddr, tableErr)
+	}
+
+	return r.persistCalls(results, call, contractAddr)
+}
+
+// Creates a custom postgres command to persist the decoded calls of the given method, one row per transaction
+func (r *callRepository) persistCalls(results []types.CallResult, call types.Call, contractAddr string) error {
+	tx, txErr := r.db.Beginx()
+	if txErr != nil {
+		return fmt.Errorf("error beginning db transaction: %w", txErr)
+	}
+
+	columns := []string{"header_id", "tx_hash", "tx_idx", "tx_from", "status"}
+	var argColumns []types.Column
+	for _, arg := range call.Args {
+		for _, column := range arg.Columns() {
+			argColumns = append(argColumns, column)
+			columns = append(columns, strings.ToLower(column.Name)+"_") // Add underscore after to avoid any collisions with reserved pg words
+		}
+	}
+	placeholders := make([]string, len(columns))
+	for i := range columns {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	pgStr := fmt.Sprintf("INSERT INTO cw_%s.%s_call (%s) VALUES (%s) ON CONFLICT DO NOTHING",
+		strings.ToLower(contractAddr), strings.ToLower(call.Name), strings.Join(columns, ", "),
+		strings.Join(placeholders, ", "))
+	logrus.Tracef("query for inserting calls: %s", pgStr)
+
+	for _, result := range results {
+		data := make([]interface{}, 0, len(columns))
+		data = append(data, result.HeaderID, result.Hash, result.TxIndex, result.From, result.Status)
+		var valueErr error
+		for _, column := range argColumns {
+			var value interface{}
+			value, valueErr = pgValue(column.PgType, result.Values[column.Name])
+			if valueErr != nil {
+				break
+			}
+			data = append(data, value)
+		}
+		if valueErr != nil {
+			rollbackErr := tx.Rollback()
+			if rollbackErr != nil {
+				logrus.Warnf("error rolling back transaction while persisting calls: %s", rollbackErr.Error())
+			}
+			return fmt.Errorf("error converting values of %s: %w", call.Name, valueErr)
+		}
+
+		_, execErr := tx.Exec(pgStr, data...)
+		if execErr != nil {
+			rollbackErr := tx.Rollback()
+			if rollbackErr != nil {
+				logrus.Warnf("error rolling back transaction while persisting calls: %s", rollbackErr.Error())
+			}
+			return fmt.Errorf("error executing query: %w", execErr)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// CreateCallTable checks for the contract schema and call table and creates them if they do not already exist
+// If the table does exist, it is migrated to hold the method's arguments
+// Returns true if it created a new table; returns false if table already existed
+func (r *callRepository) CreateCallTable(contractAddr string, call types.Call) (bool, error) {
+	if contractAddr == "" {
+		return false, errors.New("error: no contract address specified")
+	}
+	tableID := fmt.Sprintf("cw_%s.%s_call", strings.ToLower(contractAddr), strings.ToLower(call.Name))
+	columns := callColumns(call)
+	signature := columnsSignature(columns)
+	// Check cache before querying pq to see if table exists
+	_, ok := r.tables.Get(tableID)
+	if ok && r.tableColumns[tableID] == signature {
+		return false, nil
+	}
+	tableExists, checkTableErr := r.checkForTable(contractAddr, call.Name)
+	if checkTableErr != nil {
+		return false, fmt.Errorf("error checking for table: %w", checkTableErr)
+	}
+
+	if tableExists {
+		migrateErr := migrateTable(r.db, tableID, columns, baseCallColumns, r.allowDestructiveMigrations)
+		if migrateErr != nil {
+			return false, fmt.Errorf("error migrating table: %w", migrateErr)
+		}
+	} else {
+		createTableErr := r.newCallTable(contractAddr, tableID, columns)
+		if createTableErr != nil {
+			return false, fmt.Errorf("error creating table: %w", createTableErr)
+		}
+	}
+
+	// Add table id to cache
+	r.tables.Add(tableID, true)
+	r.tableColumns[tableID] = signature
+
+	return !tableExists, nil
+}
+
+// Returns the columns holding the method's arguments, in order
+func callColumns(call types.Call) []types.Column {
+	var columns []types.Column
+	for _, arg := range call.Args {
+		for _, column := range arg.Columns() {
+			columns = append(columns, types.Column{Name: strings.ToLower(column.Name) + "_", PgType: column.PgType})
+		}
+	}
+	return columns
+}
+
+// Creates a table for the given contract and method, with one row per transaction calling it
+// The status is null for transactions whose receipts haven't been synced
+func (r *callRepository) newCallTable(contractAddr, tableID string, columns []types.Column) error {
+	tx, txErr := r.db.Beginx()
+	if txErr != nil {
+		return txErr
+	}
+	_, schemaErr := tx.Exec("CREATE SCHEMA IF NOT EXISTS cw_" + strings.ToLower(contractAddr))
+	if schemaErr != nil {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			logrus.Warnf("error rolling back transaction while creating call table: %s", rollbackErr.Error())
+		}
+		return schemaErr
+	}
+
+	pgStr := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s ", tableID)
+	pgStr = pgStr + "(id SERIAL, header_id INTEGER NOT NULL REFERENCES headers (id) ON DELETE CASCADE,"
+	pgStr = pgStr + " tx_hash CHARACTER VARYING(66) NOT NULL, tx_idx INTEGER NOT NULL,"
+	pgStr = pgStr + " tx_from CHARACTER VARYING(44) NOT NULL, status INTEGER,"
+	for _, column := range columns {
+		pgStr = pgStr + fmt.Sprintf(" %s %s NOT NULL,", column.Name, column.PgType)
+	}
+	pgStr = pgStr + " UNIQUE (header_id, tx_idx))"
+
+	_, tableErr := tx.Exec(pgStr)
+	if tableErr != nil {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			logrus.Warnf("error rolling back transaction while creating call table: %s", rollbackErr.Error())
+		}
+		return tableErr
+	}
+	return tx.Commit()
+}
+
+// Checks if a table already exists for the given contract and method
+func (r *callRepository) checkForTable(contractAddr string, callName string) (bool, error) {
+	pgStr := fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = 'cw_%s' AND table_name = '%s_call')", strings.ToLower(contractAddr), strings.ToLower(callName))
+
+	var exists bool
+	err := r.db.Get(&exists, pgStr)
+
+	return exists, err
+}
+
+// CheckTableCache is used to query the table name cache
+func (r *callRepository) CheckTableCache(key string) (interface{}, bool) {
+	return r.tables.Get(key)
+}
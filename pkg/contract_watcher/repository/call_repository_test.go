@@ -0,0 +1,249 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package repository_test
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/constants"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/contract"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/helpers/test_helpers"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/helpers/test_helpers/mocks"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/parser"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/repository"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/types"
+	"github.com/makerdao/vulcanizedb/pkg/core"
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres"
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres/repositories"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Call repository", func() {
+	var (
+		db        *postgres.DB
+		dataStore repository.CallRepository
+		con       *contract.Contract
+		transfer  types.Call
+		headerID  int64
+		sender    = "0x09BbBBE21a5975cAc061D82f7b843bCE061BA391"
+		recipient = "0x8dd5fbCe2F6a956C3022bA3663759011Dd51e73E"
+	)
+
+	BeforeEach(func() {
+		db, con = test_helpers.SetupTusdRepo(nil)
+		dataStore = repository.NewCallRepository(db, false)
+
+		prsr := parser.NewParser("")
+		Expect(prsr.ParseAbiStr(constants.TusdAbiString)).To(Succeed())
+		calls, err := prsr.GetCalls([]string{"transfer"})
+		Expect(err).ToNot(HaveOccurred())
+		transfer = calls["transfer"]
+
+		headerID, err = repositories.NewHeaderRepository(db).CreateOrUpdateHeader(mocks.MockHeader1)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		test_helpers.TearDown(db)
+	})
+
+	Describe("CreateCallTable", func() {
+		It("Creates the schema and table if they don't exist", func() {
+			created, err := dataStore.CreateCallTable(con.Address, transfer)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(created).To(Equal(true))
+
+			created, err = dataStore.CreateCallTable(con.Address, transfer)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(created).To(Equal(false))
+
+			tableID := fmt.Sprintf("cw_%s.%s_call", strings.ToLower(con.Address), strings.ToLower(transfer.Name))
+			v, ok := dataStore.CheckTableCache(tableID)
+			Expect(ok).To(Equal(true))
+			Expect(v).To(Equal(true))
+		})
+
+		Describe("when the table exists for different arguments", func() {
+			tableColumns := func() map[string]string {
+				var columns []struct {
+					Name     string `db:"column_name"`
+					DataType string `db:"data_type"`
+				}
+				err := db.Select(&columns, `SELECT column_name, data_type FROM information_schema.columns
+					WHERE table_schema = $1 AND table_name = 'transfer_call'`, "cw_"+strings.ToLower(con.Address))
+				Expect(err).ToNot(HaveOccurred())
+				byName := make(map[string]string, len(columns))
+				for _, column := range columns {
+					byName[column.Name] = column.DataType
+				}
+				return byName
+			}
+
+			BeforeEach(func() {
+				_, err := dataStore.CreateCallTable(con.Address, transfer)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("Adds columns for new arguments", func() {
+				memo := types.Field{Argument: abi.Argument{Name: "memo", Type: transfer.Args[0].Type}, PgType: "CHARACTER VARYING(66)"}
+				changed := types.Call{Name: transfer.Name, RawName: transfer.RawName, ID: transfer.ID,
+					Args: append(append([]types.Field{}, transfer.Args...), memo)}
+
+				created, err := dataStore.CreateCallTable(con.Address, changed)
+
+				Expect(err).ToNot(HaveOccurred())
+				Expect(created).To(BeFalse())
+				Expect(tableColumns()).To(HaveKeyWithValue("memo_", "character varying"))
+			})
+
+			It("Drops columns of removed arguments that hold no values", func() {
+				changed := types.Call{Name: transfer.Name, RawName: transfer.RawName, ID: transfer.ID, Args: transfer.Args[:1]}
+
+				_, err := dataStore.CreateCallTable(con.Address, changed)
+
+				Expect(err).ToNot(HaveOccurred())
+				columns := tableColumns()
+				Expect(columns).NotTo(HaveKey("value_"))
+				Expect(columns).To(HaveKey("tx_from"))
+				Expect(columns).To(HaveKey("status"))
+			})
+
+			It("Refuses to drop columns of removed arguments that hold values", func() {
+				results := []types.CallResult{{
+					Transaction: types.Transaction{HeaderID: headerID, Hash: "0x1", TxIndex: 2, From: sender},
+					Values:      map[string]string{"to": recipient, "value": big.NewInt(1000).String()},
+				}}
+				Expect(dataStore.PersistCalls(results, transfer, con.Address)).To(Succeed())
+				changed := types.Call{Name: transfer.Name, RawName: transfer.RawName, ID: transfer.ID, Args: transfer.Args[:1]}
+
+				_, err := repository.NewCallRepository(db, false).CreateCallTable(con.Address, changed)
+
+				Expect(err).To(HaveOccurred())
+				Expect(errors.Is(err, repository.ErrDestructiveMigration)).To(BeTrue())
+				Expect(tableColumns()).To(HaveKey("value_"))
+			})
+
+			It("Drops columns of removed arguments that hold values if destructive migrations are allowed", func() {
+				results := []types.CallResult{{
+					Transaction: types.Transaction{HeaderID: headerID, Hash: "0x1", TxIndex: 2, From: sender},
+					Values:      map[string]string{"to": recipient, "value": big.NewInt(1000).String()},
+				}}
+				Expect(dataStore.PersistCalls(results, transfer, con.Address)).To(Succeed())
+				changed := types.Call{Name: transfer.Name, RawName: transfer.RawName, ID: transfer.ID, Args: transfer.Args[:1]}
+
+				_, err := repository.NewCallRepository(db, true).CreateCallTable(con.Address, changed)
+
+				Expect(err).ToNot(HaveOccurred())
+				Expect(tableColumns()).NotTo(HaveKey("value_"))
+			})
+		})
+	})
+
+	Describe("GetTransactions", func() {
+		It("Returns the transactions sent to the contract at the header, with their receipt status", func() {
+			headerRepository := repositories.NewHeaderRepository(db)
+			input := append(common.FromHex("0xa9059cbb"), make([]byte, 64)...)
+			withReceipt := core.TransactionModel{Hash: "0x1", Data: input, From: sender, To: con.Address, TxIndex: 0, Value: "0"}
+			withoutReceipt := core.TransactionModel{Hash: "0x2", Data: input, From: sender, To: strings.ToLower(con.Address), TxIndex: 1, Value: "0"}
+			toOther := core.TransactionModel{Hash: "0x3", Data: input, From: sender, To: sender, TxIndex: 2, Value: "0"}
+			tx, err := db.Beginx()
+			Expect(err).ToNot(HaveOccurred())
+			for _, transaction := range []core.TransactionModel{withReceipt, withoutReceipt, toOther} {
+				txID, createErr := headerRepository.CreateTransactionInTx(tx, headerID, transaction)
+				Expect(createErr).ToNot(HaveOccurred())
+				if transaction.Hash == withReceipt.Hash {
+					receipt := core.Receipt{ContractAddress: con.Address, TxHash: transaction.Hash, Status: 1}
+					_, receiptErr := repositories.ReceiptRepository{}.CreateReceiptInTx(headerID, txID, receipt, tx)
+					Expect(receiptErr).ToNot(HaveOccurred())
+				}
+			}
+			Expect(tx.Commit()).To(Succeed())
+
+			transactions, err := dataStore.GetTransactions(con.Address, headerID)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(transactions).To(Equal([]types.Transaction{
+				{HeaderID: headerID, Hash: "0x1", TxIndex: 0, From: sender, Input: input, Status: sql.NullInt64{Int64: 1, Valid: true}},
+				{HeaderID: headerID, Hash: "0x2", TxIndex: 1, From: sender, Input: input},
+			}))
+		})
+
+		It("Returns the status of reverted transactions synced with their receipts", func() {
+			input := append(common.FromHex("0xa9059cbb"), make([]byte, 64)...)
+			reverted := core.TransactionModel{Hash: "0x1", Data: input, From: sender, To: con.Address, TxIndex: 0,
+				Value: "0", Receipt: core.Receipt{TxHash: "0x1", Status: 0}}
+			createErr := repositories.NewHeaderRepository(db).CreateTransactionsWithReceipts(headerID,
+				[]core.TransactionModel{reverted})
+			Expect(createErr).ToNot(HaveOccurred())
+
+			transactions, err := dataStore.GetTransactions(con.Address, headerID)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(transactions).To(Equal([]types.Transaction{
+				{HeaderID: headerID, Hash: "0x1", TxIndex: 0, From: sender, Input: input, Status: sql.NullInt64{Int64: 0, Valid: true}},
+			}))
+		})
+	})
+
+	Describe("PersistCalls", func() {
+		It("Persists decoded calls keyed by header and transaction", func() {
+			results := []types.CallResult{{
+				Transaction: types.Transaction{HeaderID: headerID, Hash: "0x1", TxIndex: 2, From: sender, Status: sql.NullInt64{Int64: 0, Valid: true}},
+				Values:      map[string]string{"to": recipient, "value": big.NewInt(1000).String()},
+			}}
+
+			err := dataStore.PersistCalls(results, transfer, con.Address)
+			Expect(err).ToNot(HaveOccurred())
+			// Duplicates are ignored
+			err = dataStore.PersistCalls(results, transfer, con.Address)
+			Expect(err).ToNot(HaveOccurred())
+
+			var persisted []struct {
+				HeaderID int64         `db:"header_id"`
+				TxHash   string        `db:"tx_hash"`
+				TxIdx    int64         `db:"tx_idx"`
+				TxFrom   string        `db:"tx_from"`
+				Status   sql.NullInt64 `db:"status"`
+				To       string        `db:"to_"`
+				Value    string        `db:"value_"`
+			}
+			err = db.Select(&persisted, fmt.Sprintf("SELECT header_id, tx_hash, tx_idx, tx_from, status, to_, value_ FROM cw_%s.transfer_call",
+				strings.ToLower(con.Address)))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(persisted)).To(Equal(1))
+			Expect(persisted[0].HeaderID).To(Equal(headerID))
+			Expect(persisted[0].TxHash).To(Equal("0x1"))
+			Expect(persisted[0].TxIdx).To(Equal(int64(2)))
+			Expect(persisted[0].TxFrom).To(Equal(sender))
+			Expect(persisted[0].Status).To(Equal(sql.NullInt64{Int64: 0, Valid: true}))
+			Expect(persisted[0].To).To(Equal(recipient))
+			Expect(persisted[0].Value).To(Equal("1000"))
+		})
+
+		It("Fails with empty results", func() {
+			err := dataStore.PersistCalls([]types.CallResult{}, transfer, con.Address)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -40,22 +40,37 @@ type EventRepository interface {
 	CreateContractSchema(contractName string) (bool, error)
 	CheckSchemaCache(key string) (interface{}, bool)
 	CheckTableCache(key string) (interface{}, bool)
+	GetEventBlockNumbers(contractAddr, eventName string) ([]int64, error)
 }
 
+// ErrDestructiveMigration is returned when an existing event or call table has columns that would need to be dropped,
+// or retyped to a type their values can't be cast to, to match the event or method, and destructive migrations aren't
+// allowed
+var ErrDestructiveMigration = errors.New("table requires a destructive migration")
+
+// Columns every event table has, besides those of the event's fields
+var baseEventColumns = map[string]bool{"id": true, "header_id": true, "raw_log": true, "log_idx": true, "tx_idx": true}
+
 type eventRepository struct {
-	db      *postgres.DB
-	schemas *lru.Cache // Cache names of recently used schemas to minimize db connections
-	tables  *lru.Cache // Cache names of recently used tables to minimize db connections
+	db                         *postgres.DB
+	schemas                    *lru.Cache        // Cache names of recently used schemas to minimize db connections
+	tables                     *lru.Cache        // Cache names of recently used tables to minimize db connections
+	tableColumns               map[string]string // Signature of the columns each cached table was checked against
+	allowDestructiveMigrations bool              // Whether to drop columns of existing tables that the event no longer has
 }
 
 // NewEventRepository returns a new EventRepository
-func NewEventRepository(db *postgres.DB) EventRepository {
+// Existing event tables are migrated to match the events they are used with; columns are only dropped, or retyped
+// when their values can't be cast to the new type, if allowDestructiveMigrations is set
+func NewEventRepository(db *postgres.DB, allowDestructiveMigrations bool) EventRepository {
 	ccs, _ := lru.New(contractCacheSize)
 	ecs, _ := lru.New(eventCacheSize)
 	return &eventRepository{
-		db:      db,
-		schemas: ccs,
-		tables:  ecs,
+		db:                         db,
+		schemas:                    ccs,
+		tables:                     ecs,
+		tableColumns:               map[string]string{},
+		allowDestructiveMigrations: allowDestructiveMigrations,
 	}
 }
 
@@ -86,6 +101,7 @@ func (r *eventRepository) persistEventLogs(logs []types.Log, eventInfo types.Eve
 		return fmt.Errorf("error beginning db transaction: %s", txErr.Error())
 	}
 
+	pgTypes := columnTypes(eventInfo)
 	for _, event := range logs {
 		// Begin pg query string
 		pgStr := fmt.Sprintf("INSERT INTO cw_%s.%s_event ", strings.ToLower(contractAddr), strings.ToLower(eventInfo.Name))
@@ -103,7 +119,15 @@ func (r *eventRepository) persistEventLogs(logs []types.Log, eventInfo types.Eve
 		// Iterate over inputs and append name to query string and value to input data
 		for inputName, input := range event.Values {
 			pgStr = pgStr + fmt.Sprintf(", %s_", strings.ToLower(inputName)) // Add underscore after to avoid any collisions with reserved pg words
-			data = append(data, input)
+			value, valueErr := pgValue(pgTypes[inputName], input)
+			if valueErr != nil {
+				rollbackErr := tx.Rollback()
+				if rollbackErr != nil {
+					logrus.Warnf("error rolling back transactions while persisting logs: %s", rollbackErr.Error())
+				}
+				return fmt.Errorf("error converting value of %s: %w", inputName, valueErr)
+			}
+			data = append(data, value)
 		}
 
 		// For each input entry we created we add its postgres command variable to the string
@@ -129,12 +153,15 @@ func (r *eventRepository) persistEventLogs(logs []types.Log, eventInfo types.Eve
 }
 
 // CreateEventTable checks for event table and creates it if it does not already exist
+// If it does exist, it is migrated to hold the event's fields
 // Returns true if it created a new table; returns false if table already existed
 func (r *eventRepository) CreateEventTable(contractAddr string, event types.Event) (bool, error) {
 	tableID := fmt.Sprintf("cw_%s.%s_event", strings.ToLower(contractAddr), strings.ToLower(event.Name))
+	columns := eventColumns(event)
+	signature := columnsSignature(columns)
 	// Check cache before querying pq to see if table exists
 	_, ok := r.tables.Get(tableID)
-	if ok {
+	if ok && r.tableColumns[tableID] == signature {
 		return false, nil
 	}
 	tableExists, checkTableErr := r.checkForTable(contractAddr, event.Name)
@@ -142,8 +169,13 @@ func (r *eventRepository) CreateEventTable(contractAddr string, event types.Even
 		return false, fmt.Errorf("error checking for table: %s", checkTableErr)
 	}
 
-	if !tableExists {
-		createTableErr := r.newEventTable(tableID, event)
+	if tableExists {
+		migrateErr := migrateTable(r.db, tableID, columns, baseEventColumns, r.allowDestructiveMigrations)
+		if migrateErr != nil {
+			return false, fmt.Errorf("error migrating table: %w", migrateErr)
+		}
+	} else {
+		createTableErr := r.newEventTable(tableID, columns)
 		if createTableErr != nil {
 			return false, fmt.Errorf("error creating table: %s", createTableErr.Error())
 		}
@@ -151,19 +183,31 @@ func (r *eventRepository) CreateEventTable(contractAddr string, event types.Even
 
 	// Add table id to cache
 	r.tables.Add(tableID, true)
+	r.tableColumns[tableID] = signature
 
 	return !tableExists, nil
 }
 
+// Returns the columns holding the event's fields, in order
+func eventColumns(event types.Event) []types.Column {
+	var columns []types.Column
+	for _, field := range event.Fields {
+		for _, column := range field.Columns() {
+			columns = append(columns, types.Column{Name: strings.ToLower(column.Name) + "_", PgType: column.PgType})
+		}
+	}
+	return columns
+}
+
 // Creates a table for the given contract and event
-func (r *eventRepository) newEventTable(tableID string, event types.Event) error {
+func (r *eventRepository) newEventTable(tableID string, columns []types.Column) error {
 	// Begin pg string
 	var pgStr = fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s ", tableID)
 
 	pgStr = pgStr + "(id SERIAL, header_id INTEGER NOT NULL REFERENCES headers (id) ON DELETE CASCADE, raw_log JSONB, log_idx INTEGER NOT NULL, tx_idx INTEGER NOT NULL,"
 
-	for _, field := range event.Fields {
-		pgStr = pgStr + fmt.Sprintf(" %s_ %s NOT NULL,", strings.ToLower(field.Name), field.PgType)
+	for _, column := range columns {
+		pgStr = pgStr + fmt.Sprintf(" %s %s NOT NULL,", column.Name, column.PgType)
 	}
 	pgStr = pgStr + " UNIQUE (header_id, tx_idx, log_idx))"
 
@@ -227,6 +271,25 @@ func (r *eventRepository) checkForSchema(contractAddr string) (bool, error) {
 	return exists, err
 }
 
+// GetEventBlockNumbers returns the distinct numbers of the blocks with persisted logs of the event, in ascending order
+// Returns no block numbers if the event table does not exist yet
+func (r *eventRepository) GetEventBlockNumbers(contractAddr, eventName string) ([]int64, error) {
+	tableExists, checkTableErr := r.checkForTable(contractAddr, eventName)
+	if checkTableErr != nil || !tableExists {
+		return nil, checkTableErr
+	}
+
+	var blockNumbers []int64
+	pgStr := fmt.Sprintf(`SELECT DISTINCT headers.block_number FROM cw_%s.%s_event AS events
+		JOIN public.headers ON headers.id = events.header_id
+		ORDER BY headers.block_number`, strings.ToLower(contractAddr), strings.ToLower(eventName))
+	selectErr := r.db.Select(&blockNumbers, pgStr)
+	if selectErr != nil {
+		return nil, fmt.Errorf("error getting blocks of %s logs: %w", eventName, selectErr)
+	}
+	return blockNumbers, nil
+}
+
 // CheckSchemaCache is used to query the schema name cache
 func (r *eventRepository) CheckSchemaCache(key string) (interface{}, bool) {
 	return r.schemas.Get(key)
@@ -17,11 +17,16 @@
 package repository_test
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	geth "github.com/ethereum/go-ethereum/core/types"
+	"github.com/lib/pq"
+	"github.com/makerdao/vulcanizedb/libraries/shared/test_data"
 	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/constants"
 	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/contract"
 	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/converter"
@@ -37,22 +42,23 @@ import (
 
 var _ = Describe("Repository", func() {
 	var (
-		db           *postgres.DB
-		dataStore    repository.EventRepository
-		logs         []types.Log
-		con          *contract.Contract
-		wantedEvents = []string{"Transfer"}
-		event        types.Event
-		headerID     int64
-		mockLog1     = mocks.MockTransferLog1
-		mockLog2     = mocks.MockTransferLog2
+		db            *postgres.DB
+		dataStore     repository.EventRepository
+		logs          []types.Log
+		con           *contract.Contract
+		wantedEvents  = []string{"Transfer"}
+		event         types.Event
+		headerID      int64
+		mockLog1      = mocks.MockTransferLog1
+		mockLog2      = mocks.MockTransferLog2
+		stringType, _ = abi.NewType("string", "", nil)
 	)
 
 	BeforeEach(func() {
 		db, con = test_helpers.SetupTusdRepo(wantedEvents)
 
-		event = con.Events["Transfer"]
-		dataStore = repository.NewEventRepository(db)
+		event = con.Events["Transfer(address,address,uint256)"]
+		dataStore = repository.NewEventRepository(db, false)
 	})
 
 	AfterEach(func() {
@@ -116,6 +122,124 @@ var _ = Describe("Repository", func() {
 			Expect(err).ToNot(HaveOccurred())
 			Expect(created).To(Equal(false))
 		})
+
+		Describe("when the event no longer matches its table", func() {
+			var tableColumns = func() map[string]string {
+				var columns []struct {
+					Name string `db:"column_name"`
+					Type string `db:"data_type"`
+				}
+				err := db.Select(&columns, `SELECT column_name, data_type FROM information_schema.columns
+					WHERE table_schema = $1 AND table_name = 'transfer_event'`, "cw_"+strings.ToLower(con.Address))
+				Expect(err).ToNot(HaveOccurred())
+				columnTypes := make(map[string]string, len(columns))
+				for _, column := range columns {
+					columnTypes[column.Name] = column.Type
+				}
+				return columnTypes
+			}
+
+			BeforeEach(func() {
+				_, err := dataStore.CreateContractSchema(con.Address)
+				Expect(err).ToNot(HaveOccurred())
+				_, err = dataStore.CreateEventTable(con.Address, event)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("Adds nullable columns for new fields", func() {
+				memo := types.Field{Argument: abi.Argument{Name: "memo", Type: stringType}, PgType: "TEXT"}
+				changed := types.Event{Name: event.Name, Fields: append(append([]types.Field{}, event.Fields...), memo)}
+
+				created, err := dataStore.CreateEventTable(con.Address, changed)
+
+				Expect(err).ToNot(HaveOccurred())
+				Expect(created).To(BeFalse())
+				Expect(tableColumns()).To(HaveKeyWithValue("memo_", "text"))
+				var nullable string
+				err = db.Get(&nullable, `SELECT is_nullable FROM information_schema.columns
+					WHERE table_schema = $1 AND table_name = 'transfer_event' AND column_name = 'memo_'`, "cw_"+strings.ToLower(con.Address))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(nullable).To(Equal("YES"))
+			})
+
+			It("Drops columns of removed fields that hold no values", func() {
+				changed := types.Event{Name: event.Name, Fields: event.Fields[:2]}
+
+				_, err := dataStore.CreateEventTable(con.Address, changed)
+
+				Expect(err).ToNot(HaveOccurred())
+				Expect(tableColumns()).NotTo(HaveKey("value_"))
+			})
+
+			It("Refuses to drop columns of removed fields that hold values", func() {
+				headerID, err := repositories.NewHeaderRepository(db).CreateOrUpdateHeader(mocks.MockHeader1)
+				Expect(err).ToNot(HaveOccurred())
+				insertTransferRow(db, con.Address, headerID, "10")
+				changed := types.Event{Name: event.Name, Fields: event.Fields[:2]}
+
+				_, err = dataStore.CreateEventTable(con.Address, changed)
+
+				Expect(err).To(HaveOccurred())
+				Expect(errors.Is(err, repository.ErrDestructiveMigration)).To(BeTrue())
+				Expect(tableColumns()).To(HaveKey("value_"))
+			})
+
+			It("Retypes columns in place, casting their values", func() {
+				headerID, err := repositories.NewHeaderRepository(db).CreateOrUpdateHeader(mocks.MockHeader1)
+				Expect(err).ToNot(HaveOccurred())
+				insertTransferRow(db, con.Address, headerID, "10")
+				value := event.Fields[2]
+				value.Type = stringType
+				value.PgType = "TEXT"
+				changed := types.Event{Name: event.Name, Fields: []types.Field{event.Fields[0], event.Fields[1], value}}
+
+				_, err = dataStore.CreateEventTable(con.Address, changed)
+
+				Expect(err).ToNot(HaveOccurred())
+				Expect(tableColumns()).To(HaveKeyWithValue("value_", "text"))
+				var values []string
+				err = db.Select(&values, fmt.Sprintf("SELECT value_ FROM cw_%s.transfer_event", strings.ToLower(con.Address)))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(values).To(ConsistOf("10"))
+			})
+
+			Describe("when values can't be cast to the new type", func() {
+				BeforeEach(func() {
+					value := event.Fields[2]
+					value.Type = stringType
+					value.PgType = "TEXT"
+					textEvent := types.Event{Name: event.Name, Fields: []types.Field{event.Fields[0], event.Fields[1], value}}
+					_, err := repository.NewEventRepository(db, false).CreateEventTable(con.Address, textEvent)
+					Expect(err).ToNot(HaveOccurred())
+					headerID, err := repositories.NewHeaderRepository(db).CreateOrUpdateHeader(mocks.MockHeader1)
+					Expect(err).ToNot(HaveOccurred())
+					insertTransferRow(db, con.Address, headerID, "not a number")
+				})
+
+				It("Refuses to retype the columns", func() {
+					_, err := repository.NewEventRepository(db, false).CreateEventTable(con.Address, event)
+
+					Expect(err).To(HaveOccurred())
+					Expect(errors.Is(err, repository.ErrDestructiveMigration)).To(BeTrue())
+					Expect(tableColumns()).To(HaveKeyWithValue("value_", "text"))
+				})
+
+				It("Drops and re-adds the columns if destructive migrations are allowed", func() {
+					changed := types.Event{Name: event.Name, Fields: []types.Field{event.Fields[0], event.Fields[2]}}
+
+					_, err := repository.NewEventRepository(db, true).CreateEventTable(con.Address, changed)
+
+					Expect(err).ToNot(HaveOccurred())
+					columns := tableColumns()
+					Expect(columns).To(HaveKeyWithValue("value_", "numeric"))
+					Expect(columns).NotTo(HaveKey("to_"))
+					var values []sql.NullString
+					err = db.Select(&values, fmt.Sprintf("SELECT value_ FROM cw_%s.transfer_event", strings.ToLower(con.Address)))
+					Expect(err).ToNot(HaveOccurred())
+					Expect(values).To(ConsistOf(sql.NullString{}))
+				})
+			})
+		})
 	})
 
 	Describe("PersistLogs", func() {
@@ -202,9 +326,126 @@ var _ = Describe("Repository", func() {
 			Expect(count).To(Equal(2))
 		})
 
+		It("Persists tuples, typed arrays, and nested arrays into typed columns", func() {
+			abiTypesContract := test_helpers.SetupAbiTypesContract()
+			everything := abiTypesContract.Events[test_helpers.AbiTypesSignature]
+			c := converter.NewConverter()
+			c.Update(abiTypesContract)
+			abiTypesLogs, err := c.Convert([]geth.Log{test_helpers.AbiTypesLog()}, everything, headerID)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = dataStore.PersistLogs(abiTypesLogs, everything, abiTypesContract.Address)
+			Expect(err).ToNot(HaveOccurred())
+
+			var row struct {
+				Owner          string         `db:"owner_"`
+				Tags           string         `db:"tags_"`
+				Signed         string         `db:"signed_"`
+				Data           []byte         `db:"data_"`
+				ID             []byte         `db:"id_"`
+				Amounts        pq.StringArray `db:"amounts_"`
+				Pair           pq.StringArray `db:"pair_"`
+				IDs            pq.ByteaArray  `db:"ids_"`
+				Flags          pq.BoolArray   `db:"flags_"`
+				OrderAmount    string         `db:"order_amount_"`
+				OrderRecipient string         `db:"order_recipient_"`
+				OrderMetaOk    bool           `db:"order_meta_ok_"`
+				OrderMetaNote  string         `db:"order_meta_note_"`
+				Orders         string         `db:"orders_"`
+				Matrix         string         `db:"matrix_"`
+			}
+			err = db.Get(&row, fmt.Sprintf(`SELECT owner_, tags_, signed_, data_, id_, amounts_, pair_, ids_, flags_,
+				order_amount_, order_recipient_, order_meta_ok_, order_meta_note_, orders_, matrix_
+				FROM cw_%s.everything_event`, abiTypesContract.Address))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(row.Owner).To(Equal(test_helpers.AbiTypesOwner.String()))
+			Expect(row.Tags).To(Equal(test_helpers.AbiTypesTagsHash.String()))
+			Expect(row.Signed).To(Equal("-1000000000000000000000"))
+			Expect(row.Data).To(Equal([]byte{1, 2, 3}))
+			Expect(row.ID).To(Equal(test_helpers.AbiTypesID.Bytes()))
+			Expect([]string(row.Amounts)).To(Equal([]string{"1", "2"}))
+			Expect([]string(row.Pair)).To(Equal([]string{test_helpers.AbiTypesOwner.String(), test_helpers.AbiTypesRecipient.String()}))
+			Expect([][]byte(row.IDs)).To(Equal([][]byte{test_helpers.AbiTypesID.Bytes(), make([]byte, 32)}))
+			Expect([]bool(row.Flags)).To(Equal([]bool{true, false}))
+			Expect(row.OrderAmount).To(Equal("100"))
+			Expect(row.OrderRecipient).To(Equal(test_helpers.AbiTypesRecipient.String()))
+			Expect(row.OrderMetaOk).To(BeTrue())
+			Expect(row.OrderMetaNote).To(Equal("paid"))
+			Expect(row.Orders).To(MatchJSON(`[{"amount": "5", "recipient": "` + test_helpers.AbiTypesOwner.String() + `"}]`))
+			Expect(row.Matrix).To(MatchJSON(`[["1"], ["2", "3"]]`))
+		})
+
+		It("Migrates tables created with the column types used before tuples and typed arrays were decoded", func() {
+			abiTypesContract := test_helpers.SetupAbiTypesContract()
+			everything := abiTypesContract.Events[test_helpers.AbiTypesSignature]
+			_, err = dataStore.CreateContractSchema(abiTypesContract.Address)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = db.Exec(fmt.Sprintf(`CREATE TABLE cw_%s.everything_event (id SERIAL,
+				header_id INTEGER NOT NULL REFERENCES headers (id) ON DELETE CASCADE, raw_log JSONB,
+				log_idx INTEGER NOT NULL, tx_idx INTEGER NOT NULL, owner_ CHARACTER VARYING(66) NOT NULL,
+				tags_ TEXT NOT NULL, small_ NUMERIC NOT NULL, signed_ NUMERIC NOT NULL, flag_ BOOLEAN NOT NULL,
+				data_ BYTEA NOT NULL, id_ BYTEA NOT NULL, selector_ BYTEA NOT NULL, label_ TEXT NOT NULL,
+				amounts_ TEXT NOT NULL, pair_ TEXT[] NOT NULL, ids_ TEXT NOT NULL, flags_ TEXT NOT NULL,
+				order_ TEXT NOT NULL, orders_ TEXT NOT NULL, matrix_ TEXT NOT NULL, UNIQUE (header_id, tx_idx, log_idx))`,
+				abiTypesContract.Address))
+			Expect(err).ToNot(HaveOccurred())
+			c := converter.NewConverter()
+			c.Update(abiTypesContract)
+			abiTypesLogs, err := c.Convert([]geth.Log{test_helpers.AbiTypesLog()}, everything, headerID)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = dataStore.PersistLogs(abiTypesLogs, everything, abiTypesContract.Address)
+
+			Expect(err).ToNot(HaveOccurred())
+			var row struct {
+				Amounts     pq.StringArray `db:"amounts_"`
+				Pair        pq.StringArray `db:"pair_"`
+				OrderAmount string         `db:"order_amount_"`
+				Matrix      string         `db:"matrix_"`
+			}
+			err = db.Get(&row, fmt.Sprintf(`SELECT amounts_, pair_, order_amount_, matrix_ FROM cw_%s.everything_event`,
+				abiTypesContract.Address))
+			Expect(err).ToNot(HaveOccurred())
+			Expect([]string(row.Amounts)).To(Equal([]string{"1", "2"}))
+			Expect([]string(row.Pair)).To(Equal([]string{test_helpers.AbiTypesOwner.String(), test_helpers.AbiTypesRecipient.String()}))
+			Expect(row.OrderAmount).To(Equal("100"))
+			Expect(row.Matrix).To(MatchJSON(`[["1"], ["2", "3"]]`))
+		})
+
 		It("Fails with empty log", func() {
 			err = dataStore.PersistLogs([]types.Log{}, event, con.Address)
 			Expect(err).To(HaveOccurred())
 		})
 	})
+
+	Describe("GetEventBlockNumbers", func() {
+		It("Returns no block numbers before the event table exists", func() {
+			blockNumbers, err := dataStore.GetEventBlockNumbers(con.Address, event.Name)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(blockNumbers).To(BeEmpty())
+		})
+
+		It("Returns the distinct numbers of blocks with persisted logs", func() {
+			headerID, err := repositories.NewHeaderRepository(db).CreateOrUpdateHeader(mocks.MockHeader1)
+			Expect(err).ToNot(HaveOccurred())
+			c := converter.NewConverter()
+			c.Update(con)
+			logs, err = c.Convert([]geth.Log{mockLog1, mockLog2}, event, headerID)
+			Expect(err).ToNot(HaveOccurred())
+			err = dataStore.PersistLogs(logs, event, con.Address)
+			Expect(err).ToNot(HaveOccurred())
+
+			blockNumbers, err := dataStore.GetEventBlockNumbers(con.Address, event.Name)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(blockNumbers).To(Equal([]int64{mocks.MockHeader1.BlockNumber}))
+		})
+	})
 })
+
+func insertTransferRow(db *postgres.DB, contractAddr string, headerID int64, value string) {
+	_, err := db.Exec(fmt.Sprintf(`INSERT INTO cw_%s.transfer_event (header_id, log_idx, tx_idx, from_, to_, value_)
+		VALUES ($1, 0, 0, $2, $3, $4)`, strings.ToLower(contractAddr)), headerID, test_data.FakeAddress().Hex(), test_data.FakeAddress().Hex(), value)
+	Expect(err).ToNot(HaveOccurred())
+}
@@ -17,9 +17,12 @@
 package repository
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/hashicorp/golang-lru"
+	"github.com/lib/pq"
 	"github.com/sirupsen/logrus"
 
 	"github.com/makerdao/vulcanizedb/pkg/core"
@@ -28,7 +31,12 @@ import (
 
 const columnCacheSize = 1000
 
+// ErrUnknownCheckID is returned when headers are marked or queried for an id that hasn't been added
+var ErrUnknownCheckID = errors.New("unknown check id")
+
 // HeaderRepository interfaces with the header and checked_headers tables
+// Event and method ids are registered in checked_events, and checked_headers holds a row per header and id with the
+// number of times the header was checked for it
 type HeaderRepository interface {
 	AddCheckColumn(id string) error
 	AddCheckColumns(ids []string) error
@@ -37,12 +45,21 @@ type HeaderRepository interface {
 	MarkHeadersCheckedForAll(headers []core.Header, ids []string) error
 	MissingHeaders(startingBlockNumber int64, endingBlockNumber int64, eventID string) ([]core.Header, error)
 	MissingHeadersForAll(startingBlockNumber, endingBlockNumber int64, ids []string) ([]core.Header, error)
+	MissingHeadersForRanges(ranges []CheckRange) ([][]core.Header, error)
+	HeadersSynced(ranges []CheckRange) (bool, error)
 	CheckCache(key string) (interface{}, bool)
 }
 
+// CheckRange is a range of blocks over which headers are checked for the ids; EndingBlock is -1 if it's unbounded
+type CheckRange struct {
+	StartingBlock int64
+	EndingBlock   int64
+	IDs           []string
+}
+
 type headerRepository struct {
 	db      *postgres.DB
-	columns *lru.Cache // Cache created columns to minimize db connections
+	columns *lru.Cache // Cache the checked_events ids of added event and method ids to minimize db connections
 }
 
 // NewHeaderRepository returns a new HeaderRepository
@@ -54,160 +71,228 @@ func NewHeaderRepository(db *postgres.DB) HeaderRepository {
 	}
 }
 
-// AddCheckColumn adds a checked_header column for the provided column id
+// AddCheckColumn registers the id, so that headers can be marked checked for it
 func (r *headerRepository) AddCheckColumn(id string) error {
-	// Check cache to see if column already exists before querying pg
-	_, ok := r.columns.Get(id)
-	if ok {
+	return r.AddCheckColumns([]string{id})
+}
+
+// AddCheckColumns registers all of the provided ids, so that headers can be marked checked for them
+func (r *headerRepository) AddCheckColumns(ids []string) error {
+	input := make([]string, 0, len(ids))
+	for _, id := range ids {
+		_, ok := r.columns.Get(id)
+		if !ok {
+			input = append(input, strings.ToLower(id))
+		}
+	}
+	if len(input) == 0 {
 		return nil
 	}
 
-	pgStr := "ALTER TABLE public.checked_headers ADD COLUMN IF NOT EXISTS "
-	pgStr = pgStr + id + " INTEGER NOT NULL DEFAULT 0"
-	_, err := r.db.Exec(pgStr)
+	// The no-op update makes the ids of existing rows be returned too
+	var added []struct {
+		ID   int64  `db:"id"`
+		Name string `db:"name"`
+	}
+	err := r.db.Select(&added, `INSERT INTO public.checked_events (name) SELECT DISTINCT unnest($1::TEXT[])
+		ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+		RETURNING id, name`, pq.Array(input))
 	if err != nil {
 		return err
 	}
-
-	// Add column name to cache
-	r.columns.Add(id, true)
+	checkIDs := make(map[string]int64, len(added))
+	for _, event := range added {
+		checkIDs[event.Name] = event.ID
+	}
+	for _, id := range ids {
+		if checkID, ok := checkIDs[strings.ToLower(id)]; ok {
+			r.columns.Add(id, checkID)
+		}
+	}
 
 	return nil
 }
 
-// AddCheckColumns adds a checked_header column for all of the provided column ids
-func (r *headerRepository) AddCheckColumns(ids []string) error {
-	var err error
-	baseQuery := "ALTER TABLE public.checked_headers"
-	input := make([]string, 0, len(ids))
+// Returns the checked_events ids of the provided ids, without duplicates
+// Fails with ErrUnknownCheckID if any of them hasn't been added
+func (r *headerRepository) checkIDs(ids []string) ([]int64, error) {
+	checkIDs := make([]int64, 0, len(ids))
+	seen := make(map[int64]bool, len(ids))
+	var uncached []string
 	for _, id := range ids {
-		_, ok := r.columns.Get(id)
+		checkID, ok := r.columns.Get(id)
 		if !ok {
-			baseQuery += " ADD COLUMN IF NOT EXISTS " + id + " INTEGER NOT NULL DEFAULT 0,"
-			input = append(input, id)
+			uncached = append(uncached, id)
+			continue
 		}
-	}
-	if len(input) > 0 {
-		_, err = r.db.Exec(baseQuery[:len(baseQuery)-1])
-		if err == nil {
-			for _, id := range input {
-				r.columns.Add(id, true)
-			}
+		if !seen[checkID.(int64)] {
+			seen[checkID.(int64)] = true
+			checkIDs = append(checkIDs, checkID.(int64))
 		}
 	}
+	if len(uncached) == 0 {
+		return checkIDs, nil
+	}
 
-	return err
+	lowerIDs := make([]string, len(uncached))
+	for i, id := range uncached {
+		lowerIDs[i] = strings.ToLower(id)
+	}
+	var existing []struct {
+		ID   int64  `db:"id"`
+		Name string `db:"name"`
+	}
+	err := r.db.Select(&existing, `SELECT id, name FROM public.checked_events WHERE name = ANY($1::TEXT[])`, pq.Array(lowerIDs))
+	if err != nil {
+		return nil, err
+	}
+	existingIDs := make(map[string]int64, len(existing))
+	for _, event := range existing {
+		existingIDs[event.Name] = event.ID
+	}
+	for _, id := range uncached {
+		checkID, ok := existingIDs[strings.ToLower(id)]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownCheckID, id)
+		}
+		r.columns.Add(id, checkID)
+		if !seen[checkID] {
+			seen[checkID] = true
+			checkIDs = append(checkIDs, checkID)
+		}
+	}
+	return checkIDs, nil
 }
 
-// MarkHeaderChecked marks the header checked for the provided column id
+// MarkHeaderChecked marks the header checked for the provided id
 func (r *headerRepository) MarkHeaderChecked(headerID int64, id string) error {
-	_, err := r.db.Exec(`INSERT INTO public.checked_headers (header_id, `+id+`)
-		VALUES ($1, $2) 
-		ON CONFLICT (header_id) DO
-			UPDATE SET `+id+` = checked_headers.`+id+` + 1`, headerID, 1)
-	return err
+	return r.MarkHeaderCheckedForAll(headerID, []string{id})
 }
 
-// MarkHeaderCheckedForAll marks the header checked for all of the provided column ids
+// MarkHeaderCheckedForAll marks the header checked for all of the provided ids
 func (r *headerRepository) MarkHeaderCheckedForAll(headerID int64, ids []string) error {
-	pgStr := "INSERT INTO public.checked_headers (header_id, "
-	for _, id := range ids {
-		pgStr += id + ", "
+	return r.markChecked([]int64{headerID}, ids)
+}
+
+// MarkHeadersCheckedForAll marks all of the provided headers checked for each of the provided ids
+func (r *headerRepository) MarkHeadersCheckedForAll(headers []core.Header, ids []string) error {
+	headerIDs := make([]int64, len(headers))
+	for i, header := range headers {
+		headerIDs[i] = header.Id
 	}
-	pgStr = pgStr[:len(pgStr)-2] + ") VALUES ($1, "
-	for i := 0; i < len(ids); i++ {
-		pgStr += "1, "
+	return r.markChecked(headerIDs, ids)
+}
+
+// Increments the check count of every pair of header and id in a single statement
+func (r *headerRepository) markChecked(headerIDs []int64, ids []string) error {
+	checkIDs, err := r.checkIDs(ids)
+	if err != nil {
+		return err
 	}
-	pgStr = pgStr[:len(pgStr)-2] + ") ON CONFLICT (header_id) DO UPDATE SET "
-	for _, id := range ids {
-		pgStr += id + `= checked_headers.` + id + ` + 1, `
+	if len(headerIDs) == 0 || len(checkIDs) == 0 {
+		return nil
 	}
-	pgStr = pgStr[:len(pgStr)-2]
-	_, err := r.db.Exec(pgStr, headerID)
+	_, err = r.db.Exec(`INSERT INTO public.checked_headers (header_id, event_id)
+		SELECT DISTINCT header_id, event_id
+		FROM unnest($1::INTEGER[]) AS header_id CROSS JOIN unnest($2::INTEGER[]) AS event_id
+		ON CONFLICT (header_id, event_id) DO UPDATE SET check_count = checked_headers.check_count + 1`,
+		pq.Array(headerIDs), pq.Array(checkIDs))
 	return err
 }
 
-// MarkHeadersCheckedForAll marks all of the provided headers checked for each of the provided column ids
-func (r *headerRepository) MarkHeadersCheckedForAll(headers []core.Header, ids []string) error {
-	tx, err := r.db.Beginx()
+// MissingHeaders returns missing headers for the provided id
+func (r *headerRepository) MissingHeaders(startingBlockNumber, endingBlockNumber int64, id string) ([]core.Header, error) {
+	return r.MissingHeadersForAll(startingBlockNumber, endingBlockNumber, []string{id})
+}
+
+// MissingHeadersForAll returns the headers that haven't been checked for at least one of the provided ids
+func (r *headerRepository) MissingHeadersForAll(startingBlockNumber, endingBlockNumber int64, ids []string) ([]core.Header, error) {
+	missingHeaders, err := r.MissingHeadersForRanges([]CheckRange{{
+		StartingBlock: startingBlockNumber,
+		EndingBlock:   endingBlockNumber,
+		IDs:           ids,
+	}})
 	if err != nil {
-		return err
+		return nil, err
 	}
-	for _, header := range headers {
-		pgStr := "INSERT INTO public.checked_headers (header_id, "
-		for _, id := range ids {
-			pgStr += id + ", "
-		}
-		pgStr = pgStr[:len(pgStr)-2] + ") VALUES ($1, "
-		for i := 0; i < len(ids); i++ {
-			pgStr += "1, "
-		}
-		pgStr = pgStr[:len(pgStr)-2] + ") ON CONFLICT (header_id) DO UPDATE SET "
-		for _, id := range ids {
-			pgStr += fmt.Sprintf("%s = checked_headers.%s + 1, ", id, id)
-		}
-		pgStr = pgStr[:len(pgStr)-2]
-		_, err = tx.Exec(pgStr, header.Id)
+	return missingHeaders[0], nil
+}
+
+// MissingHeadersForRanges returns, for each range, the continuous run of headers within it that haven't been checked
+// for at least one of its ids, in a single query
+func (r *headerRepository) MissingHeadersForRanges(ranges []CheckRange) ([][]core.Header, error) {
+	rangeIdxs := make([]int64, len(ranges))
+	startingBlocks := make([]int64, len(ranges))
+	endingBlocks := make([]int64, len(ranges))
+	var checkRangeIdxs, checkIDs []int64
+	for i, checkRange := range ranges {
+		rangeIdxs[i] = int64(i)
+		startingBlocks[i] = checkRange.StartingBlock
+		endingBlocks[i] = checkRange.EndingBlock
+		rangeCheckIDs, err := r.checkIDs(checkRange.IDs)
 		if err != nil {
-			rollbackErr := tx.Rollback()
-			if rollbackErr != nil {
-				logrus.Warnf("error rolling back transaction: %s", rollbackErr.Error())
-			}
-			return err
+			return nil, err
+		}
+		for _, checkID := range rangeCheckIDs {
+			checkRangeIdxs = append(checkRangeIdxs, int64(i))
+			checkIDs = append(checkIDs, checkID)
 		}
 	}
-	err = tx.Commit()
-	return err
+
+	var rows []struct {
+		RangeIdx int `db:"range_idx"`
+		core.Header
+	}
+	err := r.db.Select(&rows, `WITH ranges AS (
+			SELECT * FROM unnest($1::INTEGER[], $2::BIGINT[], $3::BIGINT[]) AS ranges (idx, start_block, end_block)
+		), range_checks AS (
+			SELECT * FROM unnest($4::INTEGER[], $5::INTEGER[]) AS range_checks (idx, event_id)
+		), check_counts AS (
+			SELECT idx, COUNT(*) AS check_count FROM range_checks GROUP BY idx
+		)
+		SELECT ranges.idx AS range_idx, headers.id, headers.block_number, headers.hash
+		FROM ranges
+		JOIN check_counts ON check_counts.idx = ranges.idx
+		JOIN public.headers ON headers.block_number >= ranges.start_block
+			AND (ranges.end_block = -1 OR headers.block_number <= ranges.end_block)
+		WHERE (SELECT COUNT(*) FROM public.checked_headers
+		       JOIN range_checks ON range_checks.event_id = checked_headers.event_id
+		       WHERE range_checks.idx = ranges.idx
+		       AND checked_headers.header_id = headers.id) < check_counts.check_count
+		ORDER BY ranges.idx, headers.block_number`,
+		pq.Array(rangeIdxs), pq.Array(startingBlocks), pq.Array(endingBlocks), pq.Array(checkRangeIdxs), pq.Array(checkIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	missingHeaders := make([][]core.Header, len(ranges))
+	for _, row := range rows {
+		missingHeaders[row.RangeIdx] = append(missingHeaders[row.RangeIdx], row.Header)
+	}
+	for i, headers := range missingHeaders {
+		missingHeaders[i] = continuousHeaders(headers)
+	}
+	return missingHeaders, nil
 }
 
-// MissingHeaders returns missing headers for the provided checked_headers column id
-func (r *headerRepository) MissingHeaders(startingBlockNumber, endingBlockNumber int64, id string) ([]core.Header, error) {
-	var result []core.Header
-	var query string
-	var err error
-	if endingBlockNumber == -1 {
-		query = `SELECT headers.id, headers.block_number, headers.hash FROM headers
-				LEFT JOIN checked_headers on headers.id = header_id
-				WHERE (header_id ISNULL OR checked_headers.` + id + `=0)
-				AND headers.block_number >= $1
-				ORDER BY headers.block_number`
-		err = r.db.Select(&result, query, startingBlockNumber)
-	} else {
-		query = `SELECT headers.id, headers.block_number, headers.hash FROM headers
-				LEFT JOIN checked_headers on headers.id = header_id
-				WHERE (header_id ISNULL OR checked_headers.` + id + `=0)
-				AND headers.block_number >= $1
-				AND headers.block_number <= $2
-				ORDER BY headers.block_number`
-		err = r.db.Select(&result, query, startingBlockNumber, endingBlockNumber)
-	}
-	return continuousHeaders(result), err
-}
-
-// MissingHeadersForAll returns missing headers for all of the provided checked_headers column ids
-func (r *headerRepository) MissingHeadersForAll(startingBlockNumber, endingBlockNumber int64, ids []string) ([]core.Header, error) {
-	var result []core.Header
-	var query string
-	var err error
-	baseQuery := `SELECT headers.id, headers.block_number, headers.hash FROM headers
-				  LEFT JOIN checked_headers on headers.id = header_id
-				  WHERE (header_id ISNULL`
-	for _, id := range ids {
-		baseQuery += ` OR checked_headers.` + id + `= 0`
-	}
-	if endingBlockNumber == -1 {
-		endStr := `) AND headers.block_number >= $1
-				  ORDER BY headers.block_number`
-		query = baseQuery + endStr
-		err = r.db.Select(&result, query, startingBlockNumber)
-	} else {
-		endStr := `) AND headers.block_number >= $1
-				  AND headers.block_number <= $2
-				  ORDER BY headers.block_number`
-		query = baseQuery + endStr
-		err = r.db.Select(&result, query, startingBlockNumber, endingBlockNumber)
-	}
-	return continuousHeaders(result), err
+// HeadersSynced returns true if there's a header at every block of every range; the ranges must be bounded, and
+// their ids aren't considered
+func (r *headerRepository) HeadersSynced(ranges []CheckRange) (bool, error) {
+	startingBlocks := make([]int64, len(ranges))
+	endingBlocks := make([]int64, len(ranges))
+	for i, checkRange := range ranges {
+		startingBlocks[i] = checkRange.StartingBlock
+		endingBlocks[i] = checkRange.EndingBlock
+	}
+
+	var synced bool
+	err := r.db.Get(&synced, `SELECT COALESCE(bool_and(
+			(SELECT COUNT(DISTINCT headers.block_number) FROM public.headers
+			 WHERE headers.block_number BETWEEN ranges.start_block AND ranges.end_block)
+			= ranges.end_block - ranges.start_block + 1), TRUE)
+		FROM unnest($1::BIGINT[], $2::BIGINT[]) AS ranges (start_block, end_block)`,
+		pq.Array(startingBlocks), pq.Array(endingBlocks))
+	return synced, err
 }
 
 // Returns a continuous set of headers
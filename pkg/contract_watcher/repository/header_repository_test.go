@@ -17,7 +17,7 @@
 package repository_test
 
 import (
-	"fmt"
+	"errors"
 
 	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/helpers/test_helpers"
 	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/helpers/test_helpers/mocks"
@@ -53,19 +53,26 @@ var _ = Describe("Repository", func() {
 	})
 
 	Describe("AddCheckColumn", func() {
-		It("Creates a column for the given eventID to mark if the header has been checked for that event", func() {
-			query := fmt.Sprintf("SELECT %s FROM checked_headers", eventIDs[0])
-			_, err := db.Exec(query)
-			Expect(err).To(HaveOccurred())
+		It("Registers the given eventID to mark if the header has been checked for that event", func() {
+			Expect(checkedEventNames(db)).To(BeEmpty())
 
-			err = contractHeaderRepo.AddCheckColumn(eventIDs[0])
+			err := contractHeaderRepo.AddCheckColumn(eventIDs[0])
 			Expect(err).ToNot(HaveOccurred())
 
-			_, err = db.Exec(query)
+			Expect(checkedEventNames(db)).To(ConsistOf("eventname_contractaddr"))
+		})
+
+		It("Doesn't register an eventID twice", func() {
+			err := contractHeaderRepo.AddCheckColumn(eventIDs[0])
 			Expect(err).ToNot(HaveOccurred())
+
+			err = repository.NewHeaderRepository(db).AddCheckColumn(eventIDs[0])
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(checkedEventNames(db)).To(ConsistOf("eventname_contractaddr"))
 		})
 
-		It("Caches column it creates so that it does not need to repeatedly query the database to check for it's existence", func() {
+		It("Caches the id it registers so that it does not need to repeatedly query the database for it", func() {
 			_, ok := contractHeaderRepo.CheckCache(eventIDs[0])
 			Expect(ok).To(Equal(false))
 
@@ -74,27 +81,24 @@ var _ = Describe("Repository", func() {
 
 			v, ok := contractHeaderRepo.CheckCache(eventIDs[0])
 			Expect(ok).To(Equal(true))
-			Expect(v).To(Equal(true))
+			var checkID int64
+			err = db.Get(&checkID, `SELECT id FROM public.checked_events WHERE name = 'eventname_contractaddr'`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(v).To(Equal(checkID))
 		})
 	})
 
 	Describe("AddCheckColumns", func() {
-		It("Creates a column for the given eventIDs to mark if the header has been checked for those events", func() {
-			for _, id := range eventIDs {
-				_, err := db.Exec(fmt.Sprintf("SELECT %s FROM checked_headers", id))
-				Expect(err).To(HaveOccurred())
-			}
+		It("Registers the given eventIDs to mark if the header has been checked for those events", func() {
+			Expect(checkedEventNames(db)).To(BeEmpty())
 
 			err := contractHeaderRepo.AddCheckColumns(eventIDs)
 			Expect(err).ToNot(HaveOccurred())
 
-			for _, id := range eventIDs {
-				_, err := db.Exec(fmt.Sprintf("SELECT %s FROM checked_headers", id))
-				Expect(err).ToNot(HaveOccurred())
-			}
+			Expect(checkedEventNames(db)).To(ConsistOf("eventname_contractaddr", "eventname_contractaddr2", "eventname_contractaddr3"))
 		})
 
-		It("Caches columns it creates so that it does not need to repeatedly query the database to check for it's existence", func() {
+		It("Caches the ids it registers so that it does not need to repeatedly query the database for them", func() {
 			for _, id := range eventIDs {
 				_, ok := contractHeaderRepo.CheckCache(id)
 				Expect(ok).To(Equal(false))
@@ -106,7 +110,7 @@ var _ = Describe("Repository", func() {
 			for _, id := range eventIDs {
 				v, ok := contractHeaderRepo.CheckCache(id)
 				Expect(ok).To(Equal(true))
-				Expect(v).To(Equal(true))
+				Expect(v).To(BeAssignableToTypeOf(int64(0)))
 			}
 		})
 	})
@@ -220,6 +224,108 @@ var _ = Describe("Repository", func() {
 
 			_, err = contractHeaderRepo.MissingHeadersForAll(mocks.MockHeader1.BlockNumber, mocks.MockHeader4.BlockNumber, badEventIDs)
 			Expect(err).To(HaveOccurred())
+			Expect(errors.Is(err, repository.ErrUnknownCheckID)).To(BeTrue())
+		})
+
+		It("Finds ids added by another repository instance", func() {
+			addHeaders(coreHeaderRepo)
+			err := repository.NewHeaderRepository(db).AddCheckColumns(eventIDs)
+			Expect(err).ToNot(HaveOccurred())
+
+			missingHeaders, err := contractHeaderRepo.MissingHeadersForAll(mocks.MockHeader1.BlockNumber, mocks.MockHeader4.BlockNumber, eventIDs)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(missingHeaders)).To(Equal(3))
+		})
+
+		It("Ignores duplicate ids", func() {
+			addHeaders(coreHeaderRepo)
+			err := contractHeaderRepo.AddCheckColumns(eventIDs)
+			Expect(err).ToNot(HaveOccurred())
+			headers, err := contractHeaderRepo.MissingHeadersForAll(mocks.MockHeader1.BlockNumber, mocks.MockHeader4.BlockNumber, eventIDs)
+			Expect(err).ToNot(HaveOccurred())
+			err = contractHeaderRepo.MarkHeaderCheckedForAll(headers[0].Id, eventIDs)
+			Expect(err).ToNot(HaveOccurred())
+
+			missingHeaders, err := contractHeaderRepo.MissingHeadersForAll(mocks.MockHeader1.BlockNumber, mocks.MockHeader4.BlockNumber, append(eventIDs, eventIDs[0]))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(missingHeaders)).To(Equal(0))
+		})
+	})
+
+	Describe("MissingHeadersForRanges", func() {
+		It("Returns each range's headers that have not been checked for all of its ids", func() {
+			addHeaders(coreHeaderRepo)
+			err := contractHeaderRepo.AddCheckColumns(eventIDs)
+			Expect(err).ToNot(HaveOccurred())
+			headers, err := contractHeaderRepo.MissingHeadersForAll(mocks.MockHeader1.BlockNumber, mocks.MockHeader1.BlockNumber, eventIDs)
+			Expect(err).ToNot(HaveOccurred())
+			err = contractHeaderRepo.MarkHeaderChecked(headers[0].Id, eventIDs[0])
+			Expect(err).ToNot(HaveOccurred())
+
+			missingHeaders, err := contractHeaderRepo.MissingHeadersForRanges([]repository.CheckRange{
+				{StartingBlock: mocks.MockHeader1.BlockNumber, EndingBlock: mocks.MockHeader3.BlockNumber, IDs: eventIDs[:1]},
+				{StartingBlock: mocks.MockHeader1.BlockNumber, EndingBlock: mocks.MockHeader2.BlockNumber, IDs: eventIDs},
+				{StartingBlock: mocks.MockHeader3.BlockNumber, EndingBlock: -1, IDs: eventIDs[1:]},
+			})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(missingHeaders).To(HaveLen(3))
+			Expect(blockNumbers(missingHeaders[0])).To(Equal([]int64{mocks.MockHeader2.BlockNumber, mocks.MockHeader3.BlockNumber}))
+			Expect(blockNumbers(missingHeaders[1])).To(Equal([]int64{mocks.MockHeader1.BlockNumber, mocks.MockHeader2.BlockNumber}))
+			Expect(blockNumbers(missingHeaders[2])).To(Equal([]int64{mocks.MockHeader3.BlockNumber}))
+		})
+
+		It("Returns only contiguous chunks of headers for each range", func() {
+			addDiscontinuousHeaders(coreHeaderRepo)
+			err := contractHeaderRepo.AddCheckColumns(eventIDs)
+			Expect(err).ToNot(HaveOccurred())
+
+			missingHeaders, err := contractHeaderRepo.MissingHeadersForRanges([]repository.CheckRange{
+				{StartingBlock: mocks.MockHeader1.BlockNumber, EndingBlock: mocks.MockHeader4.BlockNumber, IDs: eventIDs},
+				{StartingBlock: mocks.MockHeader4.BlockNumber, EndingBlock: -1, IDs: eventIDs},
+			})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(blockNumbers(missingHeaders[0])).To(Equal([]int64{mocks.MockHeader1.BlockNumber, mocks.MockHeader2.BlockNumber}))
+			Expect(blockNumbers(missingHeaders[1])).To(Equal([]int64{mocks.MockHeader4.BlockNumber}))
+		})
+
+		It("Fails if one of the ids does not yet exist in check_headers table", func() {
+			addHeaders(coreHeaderRepo)
+			err := contractHeaderRepo.AddCheckColumns(eventIDs)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = contractHeaderRepo.MissingHeadersForRanges([]repository.CheckRange{
+				{StartingBlock: mocks.MockHeader1.BlockNumber, EndingBlock: -1, IDs: []string{"notEventId"}},
+			})
+
+			Expect(errors.Is(err, repository.ErrUnknownCheckID)).To(BeTrue())
+		})
+	})
+
+	Describe("HeadersSynced", func() {
+		It("Returns true if there's a header at every block of every range", func() {
+			addHeaders(coreHeaderRepo)
+
+			synced, err := contractHeaderRepo.HeadersSynced([]repository.CheckRange{
+				{StartingBlock: mocks.MockHeader1.BlockNumber, EndingBlock: mocks.MockHeader3.BlockNumber},
+				{StartingBlock: mocks.MockHeader2.BlockNumber, EndingBlock: mocks.MockHeader2.BlockNumber},
+			})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(synced).To(BeTrue())
+		})
+
+		It("Returns false if a range has a block without a header", func() {
+			addDiscontinuousHeaders(coreHeaderRepo)
+
+			synced, err := contractHeaderRepo.HeadersSynced([]repository.CheckRange{
+				{StartingBlock: mocks.MockHeader1.BlockNumber, EndingBlock: mocks.MockHeader2.BlockNumber},
+				{StartingBlock: mocks.MockHeader1.BlockNumber, EndingBlock: mocks.MockHeader4.BlockNumber},
+			})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(synced).To(BeFalse())
 		})
 	})
 
@@ -279,6 +385,26 @@ var _ = Describe("Repository", func() {
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(missingHeaders)).To(Equal(2))
 		})
+
+		It("Counts the times the header was checked for each id", func() {
+			addHeaders(coreHeaderRepo)
+			err := contractHeaderRepo.AddCheckColumns(eventIDs)
+			Expect(err).ToNot(HaveOccurred())
+			missingHeaders, err := contractHeaderRepo.MissingHeadersForAll(mocks.MockHeader1.BlockNumber, mocks.MockHeader4.BlockNumber, eventIDs)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = contractHeaderRepo.MarkHeaderCheckedForAll(missingHeaders[0].Id, eventIDs)
+			Expect(err).ToNot(HaveOccurred())
+			err = contractHeaderRepo.MarkHeaderCheckedForAll(missingHeaders[0].Id, eventIDs[:1])
+			Expect(err).ToNot(HaveOccurred())
+
+			var checkCounts []int
+			err = db.Select(&checkCounts, `SELECT check_count FROM public.checked_headers
+				JOIN public.checked_events ON checked_events.id = checked_headers.event_id
+				WHERE header_id = $1 ORDER BY checked_events.name`, missingHeaders[0].Id)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(checkCounts).To(Equal([]int{2, 1, 1}))
+		})
 	})
 
 	Describe("MarkHeadersCheckedForAll", func() {
@@ -310,6 +436,21 @@ var _ = Describe("Repository", func() {
 	})
 })
 
+func checkedEventNames(db *postgres.DB) []string {
+	var names []string
+	err := db.Select(&names, `SELECT name FROM public.checked_events`)
+	Expect(err).NotTo(HaveOccurred())
+	return names
+}
+
+func blockNumbers(headers []core.Header) []int64 {
+	numbers := make([]int64, len(headers))
+	for i, header := range headers {
+		numbers[i] = header.BlockNumber
+	}
+	return numbers
+}
+
 func addHeaders(coreHeaderRepo datastore.HeaderRepository) {
 	_, err := coreHeaderRepo.CreateOrUpdateHeader(mocks.MockHeader1)
 	Expect(err).NotTo(HaveOccurred())
@@ -0,0 +1,206 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/golang-lru"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/types"
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres"
+	"github.com/sirupsen/logrus"
+)
+
+const methodCacheSize = 1000
+
+// MethodRepository is used to persist polled method results into custom tables
+type MethodRepository interface {
+	PersistResults(results []types.MethodResult, methodInfo types.Method, contractAddr string) error
+	CreateMethodTable(contractAddr string, method types.Method) (bool, error)
+	CheckTableCache(key string) (interface{}, bool)
+}
+
+type methodRepository struct {
+	db     *postgres.DB
+	tables *lru.Cache // Cache names of recently used tables to minimize db connections
+}
+
+// NewMethodRepository returns a new MethodRepository
+func NewMethodRepository(db *postgres.DB) MethodRepository {
+	mcs, _ := lru.New(methodCacheSize)
+	return &methodRepository{
+		db:     db,
+		tables: mcs,
+	}
+}
+
+// PersistResults creates a schema and table for the watched contract method if needed
+// Persists polled method results into this custom table
+func (r *methodRepository) PersistResults(results []types.MethodResult, methodInfo types.Method, contractAddr string) error {
+	if len(results) == 0 {
+		return errors.New("method repository error: passed empty results slice")
+	}
+	_, tableErr := r.CreateMethodTable(contractAddr, methodInfo)
+	if tableErr != nil {
+		return fmt.Errorf("error creating table for method %s on contract %s: %w", methodInfo.Name, contractAddr, tableErr)
+	}
+
+	return r.persistResults(results, methodInfo, contractAddr)
+}
+
+// Creates a custom postgres command to persist results for the given method (compatible with header synced vDB)
+func (r *methodRepository) persistResults(results []types.MethodResult, methodInfo types.Method, contractAddr string) error {
+	tx, txErr := r.db.Beginx()
+	if txErr != nil {
+		return fmt.Errorf("error beginning db transaction: %w", txErr)
+	}
+
+	columns := []string{"header_id"}
+	for _, arg := range methodInfo.Args {
+		columns = append(columns, strings.ToLower(arg.Name)+"_") // Add underscore after to avoid any collisions with reserved pg words
+	}
+	columns = append(columns, methodInfo.Return.Name)
+	placeholders := make([]string, len(columns))
+	for i := range columns {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	pgStr := fmt.Sprintf("INSERT INTO cw_%s.%s_method (%s) VALUES (%s) ON CONFLICT DO NOTHING",
+		strings.ToLower(contractAddr), strings.ToLower(methodInfo.Name), strings.Join(columns, ", "),
+		strings.Join(placeholders, ", "))
+	logrus.Tracef("query for inserting method results: %s", pgStr)
+
+	for _, result := range results {
+		data := make([]interface{}, 0, len(columns))
+		data = append(data, result.HeaderID)
+		var valueErr error
+		for i, input := range result.Inputs {
+			var value interface{}
+			value, valueErr = pgValue(methodInfo.Args[i].PgType, input)
+			if valueErr != nil {
+				break
+			}
+			data = append(data, value)
+		}
+		if valueErr == nil {
+			var output interface{}
+			output, valueErr = pgValue(methodInfo.Return.PgType, result.Output)
+			data = append(data, output)
+		}
+		if valueErr != nil {
+			rollbackErr := tx.Rollback()
+			if rollbackErr != nil {
+				logrus.Warnf("error rolling back transaction while persisting method results: %s", rollbackErr.Error())
+			}
+			return fmt.Errorf("error converting values of %s: %w", methodInfo.Name, valueErr)
+		}
+
+		_, execErr := tx.Exec(pgStr, data...)
+		if execErr != nil {
+			rollbackErr := tx.Rollback()
+			if rollbackErr != nil {
+				logrus.Warnf("error rolling back transaction while persisting method results: %s", rollbackErr.Error())
+			}
+			return fmt.Errorf("error executing query: %w", execErr)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// CreateMethodTable checks for the contract schema and method table and creates them if they do not already exist
+// Returns true if it created a new table; returns false if table already existed
+func (r *methodRepository) CreateMethodTable(contractAddr string, method types.Method) (bool, error) {
+	if contractAddr == "" {
+		return false, errors.New("error: no contract address specified")
+	}
+	tableID := fmt.Sprintf("cw_%s.%s_method", strings.ToLower(contractAddr), strings.ToLower(method.Name))
+	// Check cache before querying pq to see if table exists
+	_, ok := r.tables.Get(tableID)
+	if ok {
+		return false, nil
+	}
+	tableExists, checkTableErr := r.checkForTable(contractAddr, method.Name)
+	if checkTableErr != nil {
+		return false, fmt.Errorf("error checking for table: %w", checkTableErr)
+	}
+
+	if !tableExists {
+		createTableErr := r.newMethodTable(contractAddr, tableID, method)
+		if createTableErr != nil {
+			return false, fmt.Errorf("error creating table: %w", createTableErr)
+		}
+	}
+
+	// Add table id to cache
+	r.tables.Add(tableID, true)
+
+	return !tableExists, nil
+}
+
+// Creates a table for the given contract and method, with one row per header and set of arguments
+func (r *methodRepository) newMethodTable(contractAddr, tableID string, method types.Method) error {
+	tx, txErr := r.db.Beginx()
+	if txErr != nil {
+		return txErr
+	}
+	_, schemaErr := tx.Exec("CREATE SCHEMA IF NOT EXISTS cw_" + strings.ToLower(contractAddr))
+	if schemaErr != nil {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			logrus.Warnf("error rolling back transaction while creating method table: %s", rollbackErr.Error())
+		}
+		return schemaErr
+	}
+
+	pgStr := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s ", tableID)
+	pgStr = pgStr + "(id SERIAL, header_id INTEGER NOT NULL REFERENCES headers (id) ON DELETE CASCADE,"
+	uniqueColumns := []string{"header_id"}
+	for _, arg := range method.Args {
+		column := strings.ToLower(arg.Name) + "_"
+		pgStr = pgStr + fmt.Sprintf(" %s %s NOT NULL,", column, arg.PgType)
+		uniqueColumns = append(uniqueColumns, column)
+	}
+	pgStr = pgStr + fmt.Sprintf(" %s %s NOT NULL,", method.Return.Name, method.Return.PgType)
+	pgStr = pgStr + fmt.Sprintf(" UNIQUE (%s))", strings.Join(uniqueColumns, ", "))
+
+	_, tableErr := tx.Exec(pgStr)
+	if tableErr != nil {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			logrus.Warnf("error rolling back transaction while creating method table: %s", rollbackErr.Error())
+		}
+		return tableErr
+	}
+	return tx.Commit()
+}
+
+// Checks if a table already exists for the given contract and method
+func (r *methodRepository) checkForTable(contractAddr string, methodName string) (bool, error) {
+	pgStr := fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = 'cw_%s' AND table_name = '%s_method')", strings.ToLower(contractAddr), strings.ToLower(methodName))
+
+	var exists bool
+	err := r.db.Get(&exists, pgStr)
+
+	return exists, err
+}
+
+// CheckTableCache is used to query the table name cache
+func (r *methodRepository) CheckTableCache(key string) (interface{}, bool) {
+	return r.tables.Get(key)
+}
@@ -0,0 +1,110 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package repository_test
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/constants"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/contract"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/helpers/test_helpers"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/helpers/test_helpers/mocks"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/parser"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/repository"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/types"
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres"
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres/repositories"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Method repository", func() {
+	var (
+		db        *postgres.DB
+		dataStore repository.MethodRepository
+		con       *contract.Contract
+		balanceOf types.Method
+		headerID  int64
+		owner     = "0x09BbBBE21a5975cAc061D82f7b843bCE061BA391"
+	)
+
+	BeforeEach(func() {
+		db, con = test_helpers.SetupTusdRepo(nil)
+		dataStore = repository.NewMethodRepository(db)
+
+		prsr := parser.NewParser("")
+		Expect(prsr.ParseAbiStr(constants.TusdAbiString)).To(Succeed())
+		methods, err := prsr.GetMethods([]string{"balanceOf"})
+		Expect(err).ToNot(HaveOccurred())
+		balanceOf = methods["balanceOf"]
+
+		headerID, err = repositories.NewHeaderRepository(db).CreateOrUpdateHeader(mocks.MockHeader1)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		test_helpers.TearDown(db)
+	})
+
+	Describe("CreateMethodTable", func() {
+		It("Creates the schema and table if they don't exist", func() {
+			created, err := dataStore.CreateMethodTable(con.Address, balanceOf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(created).To(Equal(true))
+
+			created, err = dataStore.CreateMethodTable(con.Address, balanceOf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(created).To(Equal(false))
+
+			tableID := fmt.Sprintf("cw_%s.%s_method", strings.ToLower(con.Address), strings.ToLower(balanceOf.Name))
+			v, ok := dataStore.CheckTableCache(tableID)
+			Expect(ok).To(Equal(true))
+			Expect(v).To(Equal(true))
+		})
+	})
+
+	Describe("PersistResults", func() {
+		It("Persists method results keyed by header and arguments", func() {
+			results := []types.MethodResult{{HeaderID: headerID, Inputs: []string{owner}, Output: "1000"}}
+
+			err := dataStore.PersistResults(results, balanceOf, con.Address)
+			Expect(err).ToNot(HaveOccurred())
+			// Duplicates are ignored
+			err = dataStore.PersistResults(results, balanceOf, con.Address)
+			Expect(err).ToNot(HaveOccurred())
+
+			var persisted []struct {
+				HeaderID int64  `db:"header_id"`
+				Owner    string `db:"who_"`
+				Returned string
+			}
+			err = db.Select(&persisted, fmt.Sprintf("SELECT header_id, who_, returned FROM cw_%s.balanceof_method",
+				strings.ToLower(con.Address)))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(persisted)).To(Equal(1))
+			Expect(persisted[0].HeaderID).To(Equal(headerID))
+			Expect(persisted[0].Owner).To(Equal(owner))
+			Expect(persisted[0].Returned).To(Equal("1000"))
+		})
+
+		It("Fails with empty results", func() {
+			err := dataStore.PersistResults([]types.MethodResult{}, balanceOf, con.Address)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,185 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package repository
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/types"
+	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres"
+	"github.com/sirupsen/logrus"
+)
+
+// Migrates an existing event or call table to hold the given columns, leaving its base columns as they are
+// Missing columns are added as nullable, since rows persisted before the migration have no values for them
+// Columns whose type changed are altered in place, casting their values to the new type. Columns that are no longer
+// needed are dropped if they hold no values, e.g. columns of tuple fields that were stored whole before tuples were
+// flattened. Columns holding values that are no longer needed, or that can't be cast, are only dropped (and re-added)
+// if destructive migrations are allowed; otherwise ErrDestructiveMigration is returned and the table is left untouched
+func migrateTable(db *postgres.DB, tableID string, columns []types.Column, baseColumns map[string]bool, allowDestructiveMigrations bool) error {
+	existing, columnsErr := getTableColumns(db, tableID)
+	if columnsErr != nil {
+		return fmt.Errorf("error getting columns of %s: %w", tableID, columnsErr)
+	}
+
+	var added, retyped, dropped []types.Column
+	expected := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		expected[column.Name] = true
+		existingType, ok := existing[column.Name]
+		if !ok {
+			added = append(added, column)
+		} else if existingType != strings.ToLower(column.PgType) {
+			retyped = append(retyped, column)
+		}
+	}
+	for name, pgType := range existing {
+		if !baseColumns[name] && !expected[name] {
+			dropped = append(dropped, types.Column{Name: name, PgType: pgType})
+		}
+	}
+	sort.Slice(dropped, func(i, j int) bool { return dropped[i].Name < dropped[j].Name })
+	if len(added)+len(retyped)+len(dropped) == 0 {
+		return nil
+	}
+
+	tx, txErr := db.Beginx()
+	if txErr != nil {
+		return fmt.Errorf("error beginning db transaction: %w", txErr)
+	}
+
+	var unconvertible []types.Column
+	for _, column := range retyped {
+		converted, castErr := castColumn(tx, tableID, column)
+		if castErr != nil {
+			rollbackMigration(tx)
+			return castErr
+		}
+		if !converted {
+			unconvertible = append(unconvertible, column)
+		}
+	}
+	var discarded []types.Column
+	for _, column := range dropped {
+		var holdsValues bool
+		checkErr := tx.Get(&holdsValues, fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE %s IS NOT NULL)", tableID, column.Name))
+		if checkErr != nil {
+			rollbackMigration(tx)
+			return fmt.Errorf("error checking for values of %s: %w", column.Name, checkErr)
+		}
+		if holdsValues {
+			discarded = append(discarded, column)
+		}
+	}
+
+	if len(unconvertible)+len(discarded) > 0 && !allowDestructiveMigrations {
+		rollbackMigration(tx)
+		differences := make([]string, 0, len(unconvertible)+len(discarded))
+		for _, column := range unconvertible {
+			differences = append(differences, fmt.Sprintf("%s can't be converted from %s to %s", column.Name, existing[column.Name], column.PgType))
+		}
+		for _, column := range discarded {
+			differences = append(differences, fmt.Sprintf("%s is no longer needed", column.Name))
+		}
+		return fmt.Errorf("%w: %s (%s)", ErrDestructiveMigration, tableID, strings.Join(differences, "; "))
+	}
+
+	statements := make([]string, 0, len(dropped)+2*len(unconvertible)+len(added))
+	for _, column := range dropped {
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", tableID, column.Name))
+	}
+	for _, column := range unconvertible {
+		statements = append(statements,
+			fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", tableID, column.Name),
+			fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", tableID, column.Name, column.PgType))
+	}
+	for _, column := range added {
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", tableID, column.Name, column.PgType))
+	}
+	for _, statement := range statements {
+		logrus.Infof("migrating table: %s", statement)
+		_, execErr := tx.Exec(statement)
+		if execErr != nil {
+			rollbackMigration(tx)
+			return fmt.Errorf("error executing %q: %w", statement, execErr)
+		}
+	}
+	return tx.Commit()
+}
+
+// Alters the column to its new type, casting the values it holds
+// Returns false, leaving the column as it was, if postgres can't cast them
+func castColumn(tx *sqlx.Tx, tableID string, column types.Column) (bool, error) {
+	_, savepointErr := tx.Exec("SAVEPOINT cast_column")
+	if savepointErr != nil {
+		return false, fmt.Errorf("error creating savepoint: %w", savepointErr)
+	}
+	statement := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s USING %s::%s",
+		tableID, column.Name, column.PgType, column.Name, column.PgType)
+	_, alterErr := tx.Exec(statement)
+	if alterErr != nil {
+		logrus.Infof("can't convert %s of %s to %s: %s", column.Name, tableID, column.PgType, alterErr.Error())
+		_, rollbackErr := tx.Exec("ROLLBACK TO SAVEPOINT cast_column")
+		if rollbackErr != nil {
+			return false, fmt.Errorf("error rolling back to savepoint: %w", rollbackErr)
+		}
+		return false, nil
+	}
+	logrus.Infof("migrated table: %s", statement)
+	_, releaseErr := tx.Exec("RELEASE SAVEPOINT cast_column")
+	if releaseErr != nil {
+		return false, fmt.Errorf("error releasing savepoint: %w", releaseErr)
+	}
+	return true, nil
+}
+
+func rollbackMigration(tx *sqlx.Tx) {
+	rollbackErr := tx.Rollback()
+	if rollbackErr != nil {
+		logrus.Warnf("error rolling back transaction while migrating table: %s", rollbackErr.Error())
+	}
+}
+
+// Returns the lowercase postgres type of each of the table's columns, by name
+func getTableColumns(db *postgres.DB, tableID string) (map[string]string, error) {
+	var columns []struct {
+		Name   string `db:"name"`
+		PgType string `db:"pg_type"`
+	}
+	selectErr := db.Select(&columns, `SELECT attname AS name, format_type(atttypid, atttypmod) AS pg_type
+		FROM pg_attribute WHERE attrelid = $1::regclass AND attnum > 0 AND NOT attisdropped`, tableID)
+	if selectErr != nil {
+		return nil, selectErr
+	}
+	existing := make(map[string]string, len(columns))
+	for _, column := range columns {
+		existing[column.Name] = strings.ToLower(column.PgType)
+	}
+	return existing, nil
+}
+
+// Returns a signature of the columns, to tell whether a cached table was checked against the same columns
+func columnsSignature(columns []types.Column) string {
+	parts := make([]string, len(columns))
+	for i, column := range columns {
+		parts[i] = column.Name + " " + column.PgType
+	}
+	return strings.Join(parts, ",")
+}
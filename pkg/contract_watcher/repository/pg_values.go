@@ -0,0 +1,74 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/lib/pq"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/types"
+)
+
+// columnTypes maps the names of the event's columns to their postgres types
+func columnTypes(event types.Event) map[string]string {
+	pgTypes := make(map[string]string, len(event.Fields))
+	for _, field := range event.Fields {
+		for _, column := range field.Columns() {
+			pgTypes[column.Name] = column.PgType
+		}
+	}
+	return pgTypes
+}
+
+// pgValue converts a value resolved by types.FieldValues into a value for a column of the postgres type
+// Byte values are decoded from hex, and typed arrays from JSON arrays
+func pgValue(pgType, value string) (interface{}, error) {
+	if pgType == "BYTEA" {
+		return decodeHex(value)
+	}
+	if !strings.HasSuffix(pgType, "[]") {
+		return value, nil
+	}
+
+	var elems []string
+	err := json.Unmarshal([]byte(value), &elems)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding %s value: %w", pgType, err)
+	}
+	if pgType != "BYTEA[]" {
+		return pq.StringArray(elems), nil
+	}
+	byteElems := make([][]byte, len(elems))
+	for i, elem := range elems {
+		byteElems[i], err = decodeHex(elem)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pq.ByteaArray(byteElems), nil
+}
+
+func decodeHex(value string) ([]byte, error) {
+	decoded, err := hexutil.Decode(value)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding byte value %q: %w", value, err)
+	}
+	return decoded, nil
+}
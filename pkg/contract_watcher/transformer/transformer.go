@@ -20,36 +20,66 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	gethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/makerdao/vulcanizedb/libraries/shared/transactions"
 	"github.com/makerdao/vulcanizedb/pkg/config"
 	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/contract"
 	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/converter"
 	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/fetcher"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/filter"
 	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/parser"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/poller"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/proxy"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/registry"
 	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/repository"
 	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/retriever"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/types"
 	"github.com/makerdao/vulcanizedb/pkg/core"
 	"github.com/makerdao/vulcanizedb/pkg/datastore/postgres"
+	"github.com/makerdao/vulcanizedb/pkg/eth"
 	"github.com/sirupsen/logrus"
 )
 
+// ErrInvalidLayout is returned for anonymous event layouts with invalid topics, or for events that aren't watched or
+// aren't anonymous, and for watched anonymous events without a layout constraining at least one topic
+var ErrInvalidLayout = errors.New("invalid anonymous event layout")
+
+// ContractProgress summarizes the work done for a contract since the transformer was initialized
+type ContractProgress struct {
+	Address        string
+	StartingBlock  int64
+	EndingBlock    int64 // -1 if the contract is watched indefinitely
+	LastBlock      int64 // Highest header checked for the contract; -1 if none has been
+	HeadersChecked int
+	LogsPersisted  int
+	CallsPersisted int
+}
+
 // Transformer is the top level struct for transforming watched contract data
 // Requires a header synced vDB (headers) and a running eth node (or infura)
 type Transformer struct {
 	// Database interfaces
-	EventRepository  repository.EventRepository  // Holds transformed watched event log data
-	HeaderRepository repository.HeaderRepository // Interface for interaction with header repositories
+	EventRepository      repository.EventRepository      // Holds transformed watched event log data
+	MethodRepository     repository.MethodRepository     // Holds polled method results
+	HeaderRepository     repository.HeaderRepository     // Interface for interaction with header repositories
+	AbiVersionRepository repository.AbiVersionRepository // Records the abis contracts are watched with
+	CallRepository       repository.CallRepository       // Holds calls decoded from the input of transactions to contracts
 
 	// Pre-processing interfaces
-	Parser    parser.Parser            // Parses events and methods out of contract abi fetched using contract address
-	Retriever retriever.BlockRetriever // Retrieves first block for contract
+	Parser        parser.Parser            // Parses events and methods out of contract abi fetched using contract address
+	Retriever     retriever.BlockRetriever // Retrieves first block for contract
+	ProxyResolver proxy.Resolver           // Finds the implementations behind proxy contracts
 
 	// Processing interfaces
-	Fetcher   fetcher.LogFetcher  // Fetches event logs, using header hashes
-	Converter converter.Converter // Converts watched event logs into custom log
+	Fetcher   fetcher.LogFetcher               // Fetches event logs, using header hashes
+	Converter converter.Converter              // Converts watched event logs into custom log
+	Poller    poller.Poller                    // Polls watched contract methods
+	Syncer    transactions.ITransactionsSyncer // Syncs transactions sent to contracts with calls, so that they're decoded
 
 	// Store contract configuration information
 	Config config.ContractConfig
@@ -58,11 +88,14 @@ type Transformer struct {
 	Contracts map[string]*contract.Contract
 
 	// Internally configured transformer variables
-	contractAddresses []string            // Holds all contract addresses, for batch fetching of logs
-	sortedEventIds    map[string][]string // Map to sort event column ids by contract, for post fetch processing and persisting of logs
-	eventIds          []string            // Holds event column ids across all contract, for batch fetching of headers
-	eventFilters      []common.Hash       // Holds topic0 hashes across all contracts, for batch fetching of logs
-	Start             int64               // Hold the lowest starting block and the highest ending block
+	contractAddresses []string                     // Holds all contract addresses, for batch fetching of logs
+	sortedEventIds    map[string][]string          // Map to sort event, method and call column ids by contract, for fetching the headers each contract is missing
+	eventIds          []string                     // Holds event, method and call column ids across all contract, for batch fetching of headers
+	eventFilters      []common.Hash                // Holds topic0 hashes across all contracts, for batch fetching of logs
+	topicFilters      []fetcher.TopicFilter        // Holds the topics of events filtered on indexed fields, fetched separately
+	progress          map[string]*ContractProgress // Work done for each contract, for summarizing runs
+	apiKey            string                       // Etherscan api key, for fetching the abis of proxy implementations
+	Start             int64                        // Hold the lowest starting block and the highest ending block
 }
 
 // Order-of-operations:
@@ -73,16 +106,27 @@ type Transformer struct {
 
 // NewTransformer takes in a contract config, blockchain, and database, and returns a new Transformer
 func NewTransformer(con config.ContractConfig, bc core.BlockChain, db *postgres.DB) *Transformer {
+	registries := make([]registry.Registry, 0, 2)
+	if con.AbiDirectory != "" {
+		registries = append(registries, registry.NewDirectoryRegistry(con.AbiDirectory))
+	}
+	registries = append(registries, registry.NewDBRegistry(db))
 
 	return &Transformer{
-		Fetcher:          fetcher.NewFetcher(bc),
-		Parser:           parser.NewParser(con.Network),
-		HeaderRepository: repository.NewHeaderRepository(db),
-		Retriever:        retriever.NewBlockRetriever(db),
-		Converter:        converter.NewConverter(),
-		Contracts:        map[string]*contract.Contract{},
-		EventRepository:  repository.NewEventRepository(db),
-		Config:           con,
+		Fetcher:              fetcher.NewFetcher(bc),
+		Parser:               parser.NewParserWithRegistry(con.Network, registry.NewRegistry(registries...), con.Offline),
+		HeaderRepository:     repository.NewHeaderRepository(db),
+		Retriever:            retriever.NewBlockRetriever(db),
+		ProxyResolver:        proxy.NewResolver(bc),
+		Converter:            converter.NewConverter(),
+		Poller:               poller.NewPoller(bc),
+		Contracts:            map[string]*contract.Contract{},
+		EventRepository:      repository.NewEventRepository(db, con.AllowDestructiveMigrations),
+		MethodRepository:     repository.NewMethodRepository(db),
+		AbiVersionRepository: repository.NewAbiVersionRepository(db),
+		CallRepository:       repository.NewCallRepository(db, con.AllowDestructiveMigrations),
+		Syncer:               transactions.NewTransactionsSyncer(db, bc),
+		Config:               con,
 	}
 }
 
@@ -91,29 +135,24 @@ func NewTransformer(con config.ContractConfig, bc core.BlockChain, db *postgres.
 // Loops over all of the addr => filter sets
 // Uses parser to pull event info from abi
 // Use this info to generate event filters
+// The abis of proxy contracts are merged with those of their implementations
 func (tr *Transformer) Init(apiKey string) error {
 	// Initialize internally configured transformer settings
-	tr.contractAddresses = make([]string, 0)      // Holds all contract addresses, for batch fetching of logs
-	tr.sortedEventIds = make(map[string][]string) // Map to sort event column ids by contract, for post fetch processing and persisting of logs
-	tr.eventIds = make([]string, 0)               // Holds event column ids across all contract, for batch fetching of headers
-	tr.eventFilters = make([]common.Hash, 0)      // Holds topic0 hashes across all contracts, for batch fetching of logs
+	tr.contractAddresses = make([]string, 0)         // Holds all contract addresses, for batch fetching of logs
+	tr.sortedEventIds = make(map[string][]string)    // Map to sort event, method and call column ids by contract, for fetching the headers each contract is missing
+	tr.progress = make(map[string]*ContractProgress) // Work done for each contract, for summarizing runs
+	tr.eventIds = make([]string, 0)                  // Holds event, method and call column ids across all contract, for batch fetching of headers
+	tr.eventFilters = make([]common.Hash, 0)         // Holds topic0 hashes across all contracts, for batch fetching of logs
+	tr.topicFilters = make([]fetcher.TopicFilter, 0) // Holds the topics of events filtered on indexed fields, fetched separately
+	tr.apiKey = apiKey
 	tr.Start = 100000000000
 
 	// Iterate through all internal contract addresses
 	for contractAddr := range tr.Config.Addresses {
 		// Configure Abi
-		if tr.Config.Abis[contractAddr] == "" {
-			// If no abi is given in the config, this method will try fetching from internal look-up table and etherscan
-			parseErr := tr.Parser.Parse(contractAddr, apiKey)
-			if parseErr != nil {
-				return fmt.Errorf("error parsing contract by address: %w", parseErr)
-			}
-		} else {
-			// If we have an abi from the config, load that into the parser
-			parseErr := tr.Parser.ParseAbiStr(tr.Config.Abis[contractAddr])
-			if parseErr != nil {
-				return fmt.Errorf("error parsing contract abi: %w", parseErr)
-			}
+		contractAbi, abiErr := tr.abiFor(contractAddr)
+		if abiErr != nil {
+			return abiErr
 		}
 
 		// Get first block and most recent block number in the header repo
@@ -132,38 +171,122 @@ func (tr *Transformer) Init(apiKey string) error {
 			firstBlock = tr.Config.StartingBlocks[contractAddr]
 		}
 
+		// If the contract is a proxy, watch the events of its implementations too
+		implementations, implementationsErr := tr.getImplementations(contractAddr, contractAbi, firstBlock)
+		if implementationsErr != nil {
+			return implementationsErr
+		}
+		var proxyAbi string
+		if len(implementations) > 0 {
+			proxyAbi = contractAbi
+			mergeErr := tr.parseImplementationAbis(implementations)
+			if mergeErr != nil {
+				return mergeErr
+			}
+		}
+
 		// Remove any potential accidental duplicate inputs
 		eventArgs := map[string]bool{}
 		for _, arg := range tr.Config.EventArgs[contractAddr] {
 			eventArgs[arg] = true
 		}
 
+		methods, methodsErr := tr.Parser.GetMethods(tr.Config.Methods[contractAddr])
+		if methodsErr != nil {
+			return fmt.Errorf("error getting methods for contract %s: %w", contractAddr, methodsErr)
+		}
+		calls, callsErr := tr.Parser.GetCalls(tr.Config.Calls[contractAddr])
+		if callsErr != nil {
+			return fmt.Errorf("error getting calls for contract %s: %w", contractAddr, callsErr)
+		}
+		methodInterval := tr.Config.MethodIntervals[contractAddr]
+		if methodInterval < 1 {
+			methodInterval = 1
+		}
+		maxMethodCalls, hasMaxMethodCalls := tr.Config.MaxMethodCalls[contractAddr]
+		if !hasMaxMethodCalls {
+			maxMethodCalls = contract.DefaultMaxMethodCalls
+		}
+		endingBlock, hasEnd := tr.Config.EndingBlocks[contractAddr]
+		if !hasEnd {
+			endingBlock = -1
+		}
+
 		// Aggregate info into contract object and store for execution
 		con := contract.Contract{
-			Network:       tr.Config.Network,
-			Address:       contractAddr,
-			Abi:           tr.Parser.Abi(),
-			ParsedAbi:     tr.Parser.ParsedAbi(),
-			StartingBlock: firstBlock,
-			Events:        tr.Parser.GetEvents(tr.Config.Events[contractAddr]),
-			FilterArgs:    eventArgs,
+			Network:         tr.Config.Network,
+			Address:         contractAddr,
+			Abi:             tr.Parser.Abi(),
+			ParsedAbi:       tr.Parser.ParsedAbi(),
+			StartingBlock:   firstBlock,
+			EndingBlock:     endingBlock,
+			Events:          tr.Parser.GetEvents(tr.wantedEvents(contractAddr, len(implementations) > 0)),
+			FilterArgs:      eventArgs,
+			Methods:         methods,
+			MethodInterval:  methodInterval,
+			MaxMethodCalls:  maxMethodCalls,
+			Calls:           calls,
+			ProxyAbi:        proxyAbi,
+			Implementations: implementations,
 		}.Init()
 		tr.Contracts[contractAddr] = con
 		tr.contractAddresses = append(tr.contractAddresses, con.Address)
+		tr.progress[con.Address] = &ContractProgress{
+			Address:       con.Address,
+			StartingBlock: con.StartingBlock,
+			EndingBlock:   con.EndingBlock,
+			LastBlock:     -1,
+		}
 
-		// Create checked_headers columns for each event id and append to list of all event ids
+		// Record the abi, so that changes to it can be traced to the event table migrations they cause
+		version, changed, recordErr := tr.AbiVersionRepository.RecordAbi(con.Address, con.Abi)
+		if recordErr != nil {
+			return fmt.Errorf("error recording abi: %w", recordErr)
+		}
+		if changed && version > 1 {
+			logrus.Infof("abi of contract %s changed; recorded version %d", con.Address, version)
+		}
+
+		// Register each event id for checking headers and append to list of all event ids
 		tr.sortedEventIds[con.Address] = make([]string, 0, len(con.Events))
 		for _, event := range con.Events {
-			eventID := strings.ToLower(event.Name + "_" + con.Address)
-			addColumnErr := tr.HeaderRepository.AddCheckColumn(eventID)
+			watchErr := tr.watchEvent(con, event)
+			if watchErr != nil {
+				return watchErr
+			}
+		}
+		// Filters on events a proxy doesn't have yet apply once an upgrade adds them
+		if !con.IsProxy() && len(con.Filters) < len(tr.Config.EventFilters[contractAddr]) {
+			return fmt.Errorf("%w: contract %s has filters for events that aren't watched", filter.ErrInvalidFilter, contractAddr)
+		}
+		if !con.IsProxy() && len(con.Layouts) < len(tr.Config.AnonymousEvents[contractAddr]) {
+			return fmt.Errorf("%w: contract %s has layouts for events that aren't watched or aren't anonymous", ErrInvalidLayout, contractAddr)
+		}
+
+		// Register each method id for checking headers, so that each header is polled once
+		for _, method := range con.Methods {
+			methodID := strings.ToLower(method.Name + "_" + con.Address + "_method")
+			addColumnErr := tr.HeaderRepository.AddCheckColumn(methodID)
 			if addColumnErr != nil {
 				return fmt.Errorf("error adding check column: %w", addColumnErr)
 			}
-			// Keep track of this event id; sorted and unsorted
-			tr.sortedEventIds[con.Address] = append(tr.sortedEventIds[con.Address], eventID)
-			tr.eventIds = append(tr.eventIds, eventID)
-			// Append this event sig to the filters
-			tr.eventFilters = append(tr.eventFilters, event.Sig())
+			tr.sortedEventIds[con.Address] = append(tr.sortedEventIds[con.Address], methodID)
+			tr.eventIds = append(tr.eventIds, methodID)
+		}
+
+		// Create the table of each method whose calls are decoded, and register its id for checking headers
+		for _, call := range con.Calls {
+			_, tableErr := tr.CallRepository.CreateCallTable(con.Address, call)
+			if tableErr != nil {
+				return fmt.Errorf("error creating table for calls of %s on contract %s: %w", call.Name, con.Address, tableErr)
+			}
+			callID := strings.ToLower(call.Name + "_" + con.Address + "_call")
+			addColumnErr := tr.HeaderRepository.AddCheckColumn(callID)
+			if addColumnErr != nil {
+				return fmt.Errorf("error adding check column: %w", addColumnErr)
+			}
+			tr.sortedEventIds[con.Address] = append(tr.sortedEventIds[con.Address], callID)
+			tr.eventIds = append(tr.eventIds, callID)
 		}
 
 		// Update start to the lowest block
@@ -181,8 +304,9 @@ func (tr *Transformer) Execute() error {
 		return errors.New("error: transformer has no initialized contracts")
 	}
 
-	// Find unchecked headers for all events across all contracts; these are returned in asc order
-	missingHeaders, missingHeadersErr := tr.HeaderRepository.MissingHeadersForAll(tr.Start, -1, tr.eventIds)
+	// Find unchecked headers for all events across all contracts, within the range each is watched over; these are
+	// returned in asc order
+	missingHeaders, missingHeadersErr := tr.missingHeaders()
 	if missingHeadersErr != nil {
 		return fmt.Errorf("error getting missing headers: %s", missingHeadersErr.Error())
 	}
@@ -193,20 +317,33 @@ func (tr *Transformer) Execute() error {
 		// This way if we throw an error but don't bring the execution cycle down (how it is currently handled)
 		// we restart the cycle at this header
 		tr.Start = header.BlockNumber
+		// Only contracts watched at this header have their logs fetched and the header checked
+		addresses, topicFilters := tr.watchedAt(header.BlockNumber)
 		// Map to sort batch fetched logs by which contract they belong to, for post fetch processing
 		sortedLogs := make(map[string][]gethTypes.Log)
 		// And fetch all event logs across contracts at this header
-		allLogs, fetchErr := tr.Fetcher.FetchLogs(tr.contractAddresses, tr.eventFilters, header)
+		allLogs, fetchErr := tr.fetchLogs(addresses, tr.eventFilters, topicFilters, header)
 		if fetchErr != nil {
 			return fmt.Errorf("error fetching logs: %s", fetchErr.Error())
 		}
 
-		// If no logs are found mark the header checked for all of these eventIDs
+		// Decode the calls of transactions sent to contracts at this header, whether or not they emitted logs
+		callsErr := tr.decodeCalls(addresses, header)
+		if callsErr != nil {
+			return callsErr
+		}
+
+		// If no logs are found poll methods and mark the header checked for all of these eventIDs
 		if len(allLogs) < 1 {
-			markCheckedErr := tr.HeaderRepository.MarkHeaderCheckedForAll(header.Id, tr.eventIds)
+			pollErr := tr.pollMethods(header)
+			if pollErr != nil {
+				return pollErr
+			}
+			markCheckedErr := tr.HeaderRepository.MarkHeaderCheckedForAll(header.Id, tr.checkIDs(addresses))
 			if markCheckedErr != nil {
 				return fmt.Errorf("error marking header checked: %s", markCheckedErr.Error())
 			}
+			tr.recordChecked(addresses, header)
 			tr.Start = header.BlockNumber + 1 // Empty header; setup to start at the next header
 			logrus.Tracef("no logs found for block %d, continuing", header.BlockNumber)
 			continue
@@ -223,34 +360,50 @@ func (tr *Transformer) Execute() error {
 				logrus.Tracef("no logs found for contract %s at block %d, continuing", conAddr, header.BlockNumber)
 				continue
 			}
-			// Configure converter with this contract
 			con := tr.Contracts[conAddr]
+			// Switch proxies to the implementations they upgrade to, before decoding their logs
+			if con.IsProxy() {
+				var upgradeErr error
+				logs, upgradeErr = tr.trackUpgrades(con, logs, header)
+				if upgradeErr != nil {
+					return fmt.Errorf("error tracking proxy upgrades: %w", upgradeErr)
+				}
+			}
+			// Configure converter with this contract
 			tr.Converter.Update(con)
 
-			// Convert logs into batches of log mappings (eventName => []types.Logs
+			// Convert logs into batches of log mappings (eventSignature => []types.Logs
 			convertedLogs, convertErr := tr.Converter.ConvertBatch(logs, con.Events, header.Id)
 			if convertErr != nil {
 				return fmt.Errorf("error converting logs: %s", convertErr.Error())
 			}
 			// Cycle through each type of event log and persist them
-			for eventName, logs := range convertedLogs {
+			for signature, logs := range convertedLogs {
 				// If logs for this event are empty, mark them checked at this header and continue
 				if len(logs) < 1 {
-					logrus.Tracef("no logs found for event %s on contract %s at block %d, continuing", eventName, conAddr, header.BlockNumber)
+					logrus.Tracef("no logs found for event %s on contract %s at block %d, continuing", signature, conAddr, header.BlockNumber)
 					continue
 				}
 				// If logs aren't empty, persist them
-				persistErr := tr.EventRepository.PersistLogs(logs, con.Events[eventName], con.Address)
+				persistErr := tr.EventRepository.PersistLogs(logs, con.Events[signature], con.Address)
 				if persistErr != nil {
 					return fmt.Errorf("error persisting logs: %s", persistErr.Error())
 				}
+				tr.progress[con.Address].LogsPersisted += len(logs)
 			}
 		}
 
-		markCheckedErr := tr.HeaderRepository.MarkHeaderCheckedForAll(header.Id, tr.eventIds)
+		// Poll methods after persisting logs, so that values emitted at this header are used as arguments
+		pollErr := tr.pollMethods(header)
+		if pollErr != nil {
+			return pollErr
+		}
+
+		markCheckedErr := tr.HeaderRepository.MarkHeaderCheckedForAll(header.Id, tr.checkIDs(addresses))
 		if markCheckedErr != nil {
 			return fmt.Errorf("error marking header checked: %s", markCheckedErr.Error())
 		}
+		tr.recordChecked(addresses, header)
 
 		// Success; setup to start at the next header
 		tr.Start = header.BlockNumber + 1
@@ -259,6 +412,538 @@ func (tr *Transformer) Execute() error {
 	return nil
 }
 
+// Done returns true if every contract has an ending block, there's a header at every block of its range, and it has
+// been checked at every one of them
+func (tr *Transformer) Done() (bool, error) {
+	mostRecentBlock, retrieveErr := tr.Retriever.RetrieveMostRecentBlock()
+	if retrieveErr != nil {
+		if errors.Is(retrieveErr, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error retrieving most recent block: %w", retrieveErr)
+	}
+	ranges := make([]repository.CheckRange, 0, len(tr.Contracts))
+	for _, con := range tr.Contracts {
+		if con.EndingBlock == -1 || mostRecentBlock < con.EndingBlock {
+			return false, nil
+		}
+		if con.StartingBlock > con.EndingBlock {
+			continue
+		}
+		ranges = append(ranges, repository.CheckRange{
+			StartingBlock: con.StartingBlock,
+			EndingBlock:   con.EndingBlock,
+			IDs:           tr.sortedEventIds[con.Address],
+		})
+	}
+	if len(ranges) == 0 {
+		return true, nil
+	}
+
+	synced, syncedErr := tr.HeaderRepository.HeadersSynced(ranges)
+	if syncedErr != nil {
+		return false, fmt.Errorf("error checking headers are synced: %w", syncedErr)
+	}
+	if !synced {
+		return false, nil
+	}
+	missingHeaders, missingHeadersErr := tr.HeaderRepository.MissingHeadersForRanges(ranges)
+	if missingHeadersErr != nil {
+		return false, fmt.Errorf("error getting missing headers: %w", missingHeadersErr)
+	}
+	for _, headers := range missingHeaders {
+		if len(headers) > 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Summary returns the work done for each contract since the transformer was initialized, ordered by address
+func (tr *Transformer) Summary() []ContractProgress {
+	summary := make([]ContractProgress, 0, len(tr.progress))
+	for _, progress := range tr.progress {
+		summary = append(summary, *progress)
+	}
+	sort.Slice(summary, func(i, j int) bool { return summary[i].Address < summary[j].Address })
+	return summary
+}
+
+// Returns the headers missing checks for any contract within the range it's watched over, in ascending order
+// Each contract's missing headers are a continuous run, which may be cut short at a gap; headers past the shortest
+// such run are left for the next execution, so that advancing the start doesn't skip the headers after the gap
+// The missing headers of all contracts are fetched in a single query
+func (tr *Transformer) missingHeaders() ([]core.Header, error) {
+	ranges := make([]repository.CheckRange, 0, len(tr.Contracts))
+	for _, con := range tr.Contracts {
+		start := tr.Start
+		if start < con.StartingBlock {
+			start = con.StartingBlock
+		}
+		if con.EndingBlock != -1 && start > con.EndingBlock {
+			continue
+		}
+		ranges = append(ranges, repository.CheckRange{
+			StartingBlock: start,
+			EndingBlock:   con.EndingBlock,
+			IDs:           tr.sortedEventIds[con.Address],
+		})
+	}
+	if len(ranges) == 0 {
+		return nil, nil
+	}
+	missingHeadersByRange, missingHeadersErr := tr.HeaderRepository.MissingHeadersForRanges(ranges)
+	if missingHeadersErr != nil {
+		return nil, missingHeadersErr
+	}
+
+	var missingHeaders []core.Header
+	seen := make(map[int64]bool)
+	lastBlock := int64(-1)
+	for i, headers := range missingHeadersByRange {
+		if len(headers) == 0 {
+			continue
+		}
+		runEnd := headers[len(headers)-1].BlockNumber
+		if runEnd != ranges[i].EndingBlock && (lastBlock == -1 || runEnd < lastBlock) {
+			lastBlock = runEnd
+		}
+		for _, header := range headers {
+			if !seen[header.Id] {
+				seen[header.Id] = true
+				missingHeaders = append(missingHeaders, header)
+			}
+		}
+	}
+	sort.Slice(missingHeaders, func(i, j int) bool { return missingHeaders[i].BlockNumber < missingHeaders[j].BlockNumber })
+	if lastBlock == -1 {
+		return missingHeaders, nil
+	}
+	for i, header := range missingHeaders {
+		if header.BlockNumber > lastBlock {
+			return missingHeaders[:i], nil
+		}
+	}
+	return missingHeaders, nil
+}
+
+// Returns the addresses and topic filters of the contracts watched at the block
+func (tr *Transformer) watchedAt(blockNumber int64) ([]string, []fetcher.TopicFilter) {
+	addresses := make([]string, 0, len(tr.contractAddresses))
+	for _, addr := range tr.contractAddresses {
+		if tr.Contracts[addr].WatchedAt(blockNumber) {
+			addresses = append(addresses, addr)
+		}
+	}
+	if len(addresses) == len(tr.contractAddresses) {
+		return addresses, tr.topicFilters
+	}
+	topicFilters := make([]fetcher.TopicFilter, 0, len(tr.topicFilters))
+	for _, topicFilter := range tr.topicFilters {
+		if tr.Contracts[topicFilter.ContractAddress].WatchedAt(blockNumber) {
+			topicFilters = append(topicFilters, topicFilter)
+		}
+	}
+	return addresses, topicFilters
+}
+
+// Returns the check ids of the contracts' events, methods and calls, including those of events added by proxy upgrades
+func (tr *Transformer) checkIDs(addresses []string) []string {
+	if len(addresses) == len(tr.contractAddresses) {
+		return tr.eventIds
+	}
+	var ids []string
+	for _, addr := range addresses {
+		ids = append(ids, tr.sortedEventIds[addr]...)
+	}
+	return ids
+}
+
+// Records the header as checked for each of the contracts
+func (tr *Transformer) recordChecked(addresses []string, header core.Header) {
+	for _, addr := range addresses {
+		progress := tr.progress[addr]
+		progress.HeadersChecked++
+		if header.BlockNumber > progress.LastBlock {
+			progress.LastBlock = header.BlockNumber
+		}
+	}
+}
+
+// Creates (or migrates) the event's table, compiles its filter expression, if any, and registers its id for checking
+// headers, and adds it to the ids and filters used to fetch logs
+// Anonymous events must have a layout constraining at least one topic, since their logs would otherwise be fetched
+// with every log of the contract; when all of the contract's events are watched, those without one are skipped
+func (tr *Transformer) watchEvent(con *contract.Contract, event types.Event) error {
+	var layout types.LogLayout
+	if event.Anonymous {
+		var layoutErr error
+		layout, layoutErr = tr.layoutFor(con, event)
+		if layoutErr != nil {
+			return layoutErr
+		}
+		if !layout.ConstrainsTopics() {
+			if len(tr.Config.Events[con.Address]) > 0 {
+				return fmt.Errorf("%w: anonymous event %s on contract %s needs a layout constraining at least one topic, "+
+					"or every log of the contract is fetched for it", ErrInvalidLayout, event.Name, con.Address)
+			}
+			logrus.Warnf("skipping anonymous event %s on contract %s: configure a layout constraining at least one "+
+				"topic to watch it, since every log of the contract would be fetched for it otherwise", event.Name, con.Address)
+			delete(con.Events, event.Signature())
+			return nil
+		}
+	}
+
+	_, schemaErr := tr.EventRepository.CreateContractSchema(con.Address)
+	if schemaErr != nil {
+		return fmt.Errorf("error creating schema for contract %s: %w", con.Address, schemaErr)
+	}
+	_, tableErr := tr.EventRepository.CreateEventTable(con.Address, event)
+	if tableErr != nil {
+		return fmt.Errorf("error creating table for event %s on contract %s: %w", event.Name, con.Address, tableErr)
+	}
+	eventID := strings.ToLower(event.Name + "_" + con.Address)
+	addColumnErr := tr.HeaderRepository.AddCheckColumn(eventID)
+	if addColumnErr != nil {
+		return fmt.Errorf("error adding check column: %w", addColumnErr)
+	}
+	// Keep track of this event id; sorted and unsorted
+	tr.sortedEventIds[con.Address] = append(tr.sortedEventIds[con.Address], eventID)
+	tr.eventIds = append(tr.eventIds, eventID)
+	eventFilter, filterErr := tr.compileFilter(con, event)
+	if filterErr != nil {
+		return filterErr
+	}
+	// Anonymous events have no signature topic, so their logs are fetched by the topics of their layout
+	if event.Anonymous {
+		tr.topicFilters = append(tr.topicFilters, fetcher.TopicFilter{ContractAddress: con.Address, Topics: layout.Topics})
+		return nil
+	}
+	// Push the filter's conditions on indexed fields into the topics the event's logs are fetched with
+	if eventFilter != nil && len(eventFilter.Topics()) > 0 {
+		tr.topicFilters = append(tr.topicFilters, fetcher.TopicFilter{
+			ContractAddress: con.Address,
+			Topics:          append([][]common.Hash{{event.Sig()}}, eventFilter.Topics()...),
+		})
+		return nil
+	}
+	// Append this event sig to the filters
+	tr.eventFilters = append(tr.eventFilters, event.Sig())
+	return nil
+}
+
+// Compiles the filter expression configured for the event, if any, and adds it to the contract's filters
+func (tr *Transformer) compileFilter(con *contract.Contract, event types.Event) (*filter.Filter, error) {
+	for eventName, expression := range tr.Config.EventFilters[con.Address] {
+		if !strings.EqualFold(eventName, event.Name) {
+			continue
+		}
+		eventFilter, compileErr := filter.Compile(expression, event)
+		if compileErr != nil {
+			return nil, fmt.Errorf("error compiling filter for event %s on contract %s: %w", event.Name, con.Address, compileErr)
+		}
+		if con.Filters == nil {
+			con.Filters = make(map[string]*filter.Filter)
+		}
+		con.Filters[event.Name] = eventFilter
+		return eventFilter, nil
+	}
+	return nil, nil
+}
+
+// Returns the layout configured for the anonymous event, if any, and adds it to the contract's layouts
+func (tr *Transformer) layoutFor(con *contract.Contract, event types.Event) (types.LogLayout, error) {
+	for eventName, configured := range tr.Config.AnonymousEvents[con.Address] {
+		if !strings.EqualFold(eventName, event.Name) {
+			continue
+		}
+		layout := types.LogLayout{DataLength: int(configured.DataLength), Topics: make([][]common.Hash, len(configured.Topics))}
+		for i, values := range configured.Topics {
+			for _, value := range values {
+				topic, decodeErr := hexutil.Decode(value)
+				if decodeErr != nil || len(topic) > common.HashLength {
+					return types.LogLayout{}, fmt.Errorf("%w: topic %s of event %s on contract %s is not a 32 byte hex value",
+						ErrInvalidLayout, value, event.Name, con.Address)
+				}
+				layout.Topics[i] = append(layout.Topics[i], common.BytesToHash(topic))
+			}
+		}
+		if con.Layouts == nil {
+			con.Layouts = make(map[string]types.LogLayout)
+		}
+		con.Layouts[event.Name] = layout
+		return layout, nil
+	}
+	return types.LogLayout{}, nil
+}
+
+// Fetches the logs at the header for the event sigs on all of the addresses, and for each of the topic filters
+// Logs matching both are only returned once
+func (tr *Transformer) fetchLogs(addresses []string, sigs []common.Hash, topicFilters []fetcher.TopicFilter, header core.Header) ([]gethTypes.Log, error) {
+	var logs []gethTypes.Log
+	if len(sigs) > 0 {
+		var fetchErr error
+		logs, fetchErr = tr.Fetcher.FetchLogs(addresses, sigs, header)
+		if fetchErr != nil {
+			return nil, fetchErr
+		}
+	}
+	if len(topicFilters) == 0 {
+		return logs, nil
+	}
+	filteredLogs, fetchErr := tr.Fetcher.FetchFilteredLogs(topicFilters, header)
+	if fetchErr != nil {
+		return nil, fetchErr
+	}
+	type logKey struct {
+		blockHash common.Hash
+		index     uint
+	}
+	seen := make(map[logKey]bool, len(logs))
+	for _, log := range logs {
+		seen[logKey{log.BlockHash, log.Index}] = true
+	}
+	for _, log := range filteredLogs {
+		key := logKey{log.BlockHash, log.Index}
+		if !seen[key] {
+			seen[key] = true
+			logs = append(logs, log)
+		}
+	}
+	return logs, nil
+}
+
+// Returns the contract's abi from the config or, if none is given, from the parser
+func (tr *Transformer) abiFor(contractAddr string) (string, error) {
+	if tr.Config.Abis[contractAddr] != "" {
+		// If we have an abi from the config, load that into the parser
+		parseErr := tr.Parser.ParseAbiStr(tr.Config.Abis[contractAddr])
+		if parseErr != nil {
+			return "", fmt.Errorf("error parsing contract abi: %w", parseErr)
+		}
+		return tr.Parser.Abi(), nil
+	}
+	// If no abi is given in the config, this method will try fetching from the abi registry, internal look-up table, and etherscan
+	parseErr := tr.Parser.Parse(contractAddr, tr.apiKey)
+	if parseErr != nil {
+		return "", fmt.Errorf("error parsing contract by address: %w", parseErr)
+	}
+	return tr.Parser.Abi(), nil
+}
+
+// Returns the events to watch on the contract; proxies always watch their upgrades
+func (tr *Transformer) wantedEvents(contractAddr string, isProxy bool) []string {
+	wanted := tr.Config.Events[contractAddr]
+	if !isProxy || len(wanted) == 0 {
+		return wanted
+	}
+	return append(append(make([]string, 0, len(wanted)+len(proxy.UpgradeEvents)), wanted...), proxy.UpgradeEvents...)
+}
+
+// Returns the implementation behind the proxy at the block, with the proxy's abi merged with the implementation's
+// Returns false if the contract isn't a proxy
+func (tr *Transformer) implementationAt(proxyAddr, proxyAbi string, blockNumber int64) (contract.Implementation, bool, error) {
+	implementationAddr, resolveErr := tr.ProxyResolver.Implementation(proxyAddr, proxyAbi, blockNumber)
+	if resolveErr != nil {
+		return contract.Implementation{}, false, fmt.Errorf("error resolving implementation of %s: %w", proxyAddr, resolveErr)
+	}
+	if implementationAddr == "" {
+		return contract.Implementation{}, false, nil
+	}
+	implementationAbi, abiErr := tr.abiFor(implementationAddr)
+	if abiErr != nil {
+		return contract.Implementation{}, false, fmt.Errorf("error getting abi of implementation %s: %w", implementationAddr, abiErr)
+	}
+	mergedAbi, mergeErr := parser.MergeAbis(proxy.UpgradeEventsAbi, proxyAbi, implementationAbi)
+	if mergeErr != nil {
+		return contract.Implementation{}, false, fmt.Errorf("error merging abi of implementation %s: %w", implementationAddr, mergeErr)
+	}
+	parsedAbi, parseErr := eth.ParseAbi(mergedAbi)
+	if parseErr != nil {
+		return contract.Implementation{}, false, parseErr
+	}
+	return contract.Implementation{
+		Address:       implementationAddr,
+		StartingBlock: blockNumber,
+		Abi:           mergedAbi,
+		ParsedAbi:     parsedAbi,
+	}, true, nil
+}
+
+// Returns the implementations of a proxy: the one in effect at the first block, followed by those it was upgraded to
+// at the upgrades persisted by earlier runs; returns none if the contract isn't a proxy
+func (tr *Transformer) getImplementations(contractAddr, contractAbi string, firstBlock int64) ([]contract.Implementation, error) {
+	first, isProxy, firstErr := tr.implementationAt(contractAddr, contractAbi, firstBlock)
+	if firstErr != nil || !isProxy {
+		return nil, firstErr
+	}
+	history := contract.Contract{Implementations: []contract.Implementation{first}}
+
+	var upgradeBlocks []int64
+	for _, eventName := range proxy.UpgradeEvents {
+		blockNumbers, getErr := tr.EventRepository.GetEventBlockNumbers(contractAddr, eventName)
+		if getErr != nil {
+			return nil, fmt.Errorf("error getting upgrades of %s: %w", contractAddr, getErr)
+		}
+		upgradeBlocks = append(upgradeBlocks, blockNumbers...)
+	}
+	sort.Slice(upgradeBlocks, func(i, j int) bool { return upgradeBlocks[i] < upgradeBlocks[j] })
+	for _, blockNumber := range upgradeBlocks {
+		if blockNumber <= firstBlock {
+			continue
+		}
+		implementation, _, implementationErr := tr.implementationAt(contractAddr, contractAbi, blockNumber)
+		if implementationErr != nil {
+			return nil, implementationErr
+		}
+		if implementation.Address != "" && implementation.Address != history.ImplementationAt(blockNumber) {
+			history.AddImplementation(implementation)
+		}
+	}
+	return history.Implementations, nil
+}
+
+// Loads the abis of all of a proxy's implementations, merged, into the parser
+func (tr *Transformer) parseImplementationAbis(implementations []contract.Implementation) error {
+	abis := make([]string, len(implementations))
+	for i, implementation := range implementations {
+		abis[i] = implementation.Abi
+	}
+	mergedAbi, mergeErr := parser.MergeAbis(abis...)
+	if mergeErr != nil {
+		return fmt.Errorf("error merging implementation abis: %w", mergeErr)
+	}
+	parseErr := tr.Parser.ParseAbiStr(mergedAbi)
+	if parseErr != nil {
+		return fmt.Errorf("error parsing merged implementation abis: %w", parseErr)
+	}
+	return nil
+}
+
+// Follows a proxy's upgrade at the header: logs from the header on are decoded with the abi of the new
+// implementation, and any events it adds are watched from now on, including their logs at this header
+func (tr *Transformer) trackUpgrades(con *contract.Contract, logs []gethTypes.Log, header core.Header) ([]gethTypes.Log, error) {
+	upgraded := false
+	for _, log := range logs {
+		upgraded = upgraded || proxy.IsUpgrade(log)
+	}
+	if !upgraded {
+		return logs, nil
+	}
+	implementation, isProxy, implementationErr := tr.implementationAt(con.Address, con.ProxyAbi, header.BlockNumber)
+	if implementationErr != nil {
+		return nil, implementationErr
+	}
+	if !isProxy || implementation.Address == con.ImplementationAt(header.BlockNumber) {
+		return logs, nil
+	}
+	con.AddImplementation(implementation)
+	logrus.Infof("proxy %s upgraded to implementation %s at block %d", con.Address, implementation.Address, header.BlockNumber)
+
+	parseErr := tr.parseImplementationAbis(con.Implementations)
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	con.Abi = tr.Parser.Abi()
+	con.ParsedAbi = tr.Parser.ParsedAbi()
+	sigCount, topicFilterCount := len(tr.eventFilters), len(tr.topicFilters)
+	for name, event := range tr.Parser.GetEvents(tr.wantedEvents(con.Address, true)) {
+		if _, ok := con.Events[name]; ok {
+			continue
+		}
+		con.Events[name] = event
+		watchErr := tr.watchEvent(con, event)
+		if watchErr != nil {
+			return nil, watchErr
+		}
+	}
+	newSigs, newTopicFilters := tr.eventFilters[sigCount:], tr.topicFilters[topicFilterCount:]
+	if len(newSigs) == 0 && len(newTopicFilters) == 0 {
+		return logs, nil
+	}
+	newLogs, fetchErr := tr.fetchLogs([]string{con.Address}, newSigs, newTopicFilters, header)
+	if fetchErr != nil {
+		return nil, fmt.Errorf("error fetching logs: %w", fetchErr)
+	}
+	return append(logs, newLogs...), nil
+}
+
+// Polls the methods of each contract that is due at this header and persists the results
+func (tr *Transformer) pollMethods(header core.Header) error {
+	for _, con := range tr.Contracts {
+		if len(con.Methods) == 0 || header.BlockNumber%con.MethodInterval != 0 || !con.WatchedAt(header.BlockNumber) {
+			continue
+		}
+		results, pollErr := tr.Poller.PollMethods(con, header)
+		if pollErr != nil {
+			return fmt.Errorf("error polling methods: %w", pollErr)
+		}
+		for methodName, methodResults := range results {
+			if len(methodResults) < 1 {
+				continue
+			}
+			persistErr := tr.MethodRepository.PersistResults(methodResults, con.Methods[methodName], con.Address)
+			if persistErr != nil {
+				return fmt.Errorf("error persisting method results: %w", persistErr)
+			}
+		}
+		con.ClearEmittedArgs()
+	}
+
+	return nil
+}
+
+// Syncs the transactions in the header's block sent to contracts with watched calls, along with their receipts, then
+// decodes the input of those transactions and persists the calls
+// Transactions that aren't calls to a watched method, or whose input doesn't decode, are skipped
+func (tr *Transformer) decodeCalls(addresses []string, header core.Header) error {
+	var callers []*contract.Contract
+	var callerAddrs []string
+	for _, addr := range addresses {
+		if len(tr.Contracts[addr].Calls) > 0 {
+			callers = append(callers, tr.Contracts[addr])
+			callerAddrs = append(callerAddrs, addr)
+		}
+	}
+	if len(callers) == 0 {
+		return nil
+	}
+
+	syncErr := tr.Syncer.SyncTransactionsTo(header, callerAddrs)
+	if syncErr != nil {
+		return fmt.Errorf("error syncing transactions: %w", syncErr)
+	}
+
+	for _, con := range callers {
+		txs, getErr := tr.CallRepository.GetTransactions(con.Address, header.Id)
+		if getErr != nil {
+			return fmt.Errorf("error getting transactions: %w", getErr)
+		}
+		results := make(map[string][]types.CallResult)
+		for _, tx := range txs {
+			call, ok := con.CallFor(tx.Input)
+			if !ok {
+				continue
+			}
+			result, decodeErr := call.Decode(tx)
+			if decodeErr != nil {
+				logrus.Warnf("skipping call to %s on contract %s: %s", call.Name, con.Address, decodeErr.Error())
+				continue
+			}
+			results[call.Name] = append(results[call.Name], result)
+		}
+		for callName, callResults := range results {
+			persistErr := tr.CallRepository.PersistCalls(callResults, con.Calls[callName], con.Address)
+			if persistErr != nil {
+				return fmt.Errorf("error persisting calls: %w", persistErr)
+			}
+			tr.progress[con.Address].CallsPersisted += len(callResults)
+		}
+	}
+
+	return nil
+}
+
 // GetConfig returns the transformers config; satisfies the transformer interface
 func (tr *Transformer) GetConfig() config.ContractConfig {
 	return tr.Config
@@ -18,12 +18,27 @@ package transformer_test
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
 
+	"github.com/ethereum/go-ethereum/common"
+	gethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/makerdao/vulcanizedb/pkg/config"
 	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/contract"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/converter"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/fetcher"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/filter"
 	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/helpers/test_helpers/mocks"
 	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/parser"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/proxy"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/repository"
 	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/retriever"
 	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/transformer"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/types"
+	"github.com/makerdao/vulcanizedb/pkg/core"
 	"github.com/makerdao/vulcanizedb/pkg/fakes"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -65,6 +80,48 @@ var _ = Describe("Transformer", func() {
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring(fakes.FakeError.Error()))
 		})
+
+		It("Records the abi of each contract", func() {
+			abiVersionRepository := &fakes.MockAbiVersionRepository{}
+			t := getFakeTransformer(&fakes.MockBlockRetriever{}, &fakes.MockParser{})
+			t.AbiVersionRepository = abiVersionRepository
+
+			err := t.Init("")
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(abiVersionRepository.RecordedAbis).To(Equal(map[string][]string{fakeAddress: {"fake_abi"}}))
+		})
+
+		It("Fails to initialize if the abi can't be recorded", func() {
+			t := getFakeTransformer(&fakes.MockBlockRetriever{}, &fakes.MockParser{})
+			t.AbiVersionRepository = &fakes.MockAbiVersionRepository{RecordAbiErr: fakes.FakeError}
+
+			err := t.Init("")
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(fakes.FakeError.Error()))
+		})
+
+		It("Creates or migrates the tables of watched events", func() {
+			eventRepository := &fakes.MockContractWatcherEventRepository{}
+			t := getFakeTransformer(&fakes.MockBlockRetriever{}, &fakes.MockParser{EventName: "Transfer", Event: types.Event{Name: "Transfer"}})
+			t.EventRepository = eventRepository
+
+			err := t.Init("")
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(eventRepository.CreatedTables).To(ConsistOf("Transfer"))
+		})
+
+		It("Fails to initialize if an event table can't be migrated", func() {
+			t := getFakeTransformer(&fakes.MockBlockRetriever{}, &fakes.MockParser{EventName: "Transfer", Event: types.Event{Name: "Transfer"}})
+			t.EventRepository = &fakes.MockContractWatcherEventRepository{CreateEventTableErr: repository.ErrDestructiveMigration}
+
+			err := t.Init("")
+
+			Expect(err).To(HaveOccurred())
+			Expect(errors.Is(err, repository.ErrDestructiveMigration)).To(BeTrue())
+		})
 	})
 
 	Describe("Execute", func() {
@@ -112,14 +169,763 @@ var _ = Describe("Transformer", func() {
 			Expect(err.Error()).To(ContainSubstring(fakes.FakeError.Error()))
 		})
 	})
+
+	Describe("Method polling", func() {
+		var (
+			headerRepository *fakes.MockContractWatcherHeaderRepository
+			poller           *fakes.MockPoller
+			methodRepository *fakes.MockMethodRepository
+			parsr            *fakes.MockParser
+			totalSupply      = types.Method{Name: "totalSupply"}
+			t                transformer.Transformer
+		)
+
+		BeforeEach(func() {
+			headerRepository = &fakes.MockContractWatcherHeaderRepository{}
+			poller = &fakes.MockPoller{}
+			methodRepository = &fakes.MockMethodRepository{}
+			parsr = &fakes.MockParser{Methods: map[string]types.Method{"totalSupply": totalSupply}}
+			t = getFakeTransformer(&fakes.MockBlockRetriever{}, parsr)
+			t.HeaderRepository = headerRepository
+			t.Poller = poller
+			t.MethodRepository = methodRepository
+			t.Fetcher = fetcher.NewFetcher(fakes.NewMockBlockChain())
+			t.Config.Methods = map[string][]string{fakeAddress: {"totalSupply"}}
+			t.Config.MethodIntervals = map[string]int64{fakeAddress: 2}
+		})
+
+		It("Initializes contracts with their methods and interval", func() {
+			err := t.Init("")
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parsr.WantedMethods).To(Equal([]string{"totalSupply"}))
+			Expect(t.Contracts[fakeAddress].Methods).To(Equal(parsr.Methods))
+			Expect(t.Contracts[fakeAddress].MethodInterval).To(Equal(int64(2)))
+			Expect(t.Contracts[fakeAddress].MaxMethodCalls).To(Equal(int64(contract.DefaultMaxMethodCalls)))
+			Expect(headerRepository.AddedCheckColumns).To(ContainElement("totalsupply_" + fakeAddress + "_method"))
+		})
+
+		It("Initializes contracts with their configured bound on method calls", func() {
+			t.Config.MaxMethodCalls = map[string]int64{fakeAddress: 0}
+
+			err := t.Init("")
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(t.Contracts[fakeAddress].MaxMethodCalls).To(BeZero())
+		})
+
+		It("Fails to initialize if the methods can't be polled", func() {
+			parsr.GetMethodsErr = fakes.FakeError
+
+			err := t.Init("")
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(fakes.FakeError.Error()))
+		})
+
+		It("Polls methods at headers on the interval and persists the results", func() {
+			results := []types.MethodResult{{HeaderID: 2, Inputs: []string{}, Output: "1000"}}
+			poller.ResultsToReturn = map[string][]types.MethodResult{"totalSupply": results}
+			headerRepository.MissingHeadersToReturn = []core.Header{
+				{Id: 1, BlockNumber: 1},
+				{Id: 2, BlockNumber: 2},
+			}
+			Expect(t.Init("")).To(Succeed())
+
+			err := t.Execute()
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(poller.PolledBlockNumbers).To(Equal([]int64{2}))
+			Expect(methodRepository.PersistedResults).To(Equal([][]types.MethodResult{results}))
+			Expect(methodRepository.PersistedMethods).To(Equal([]types.Method{totalSupply}))
+			Expect(headerRepository.MarkedHeaderIDs).To(Equal([]int64{1, 2}))
+			Expect(headerRepository.MarkedCheckedIDs[1]).To(ContainElement("totalsupply_" + fakeAddress + "_method"))
+		})
+
+		It("Forgets the values emitted since the last poll once methods are polled", func() {
+			headerRepository.MissingHeadersToReturn = []core.Header{{Id: 2, BlockNumber: 2}}
+			Expect(t.Init("")).To(Succeed())
+			t.Contracts[fakeAddress].EmittedAddrs = map[string]bool{"0x0000000000000000000000000000000000000001": true}
+
+			err := t.Execute()
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(t.Contracts[fakeAddress].EmittedAddrs).To(BeEmpty())
+		})
+
+		It("Returns an error if polling fails", func() {
+			poller.PollErr = fakes.FakeError
+			headerRepository.MissingHeadersToReturn = []core.Header{{Id: 2, BlockNumber: 2}}
+			Expect(t.Init("")).To(Succeed())
+
+			err := t.Execute()
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(fakes.FakeError.Error()))
+			Expect(headerRepository.MarkedHeaderIDs).To(BeEmpty())
+		})
+
+		It("Keeps the values emitted since the last poll if polling fails", func() {
+			poller.PollErr = fakes.FakeError
+			headerRepository.MissingHeadersToReturn = []core.Header{{Id: 2, BlockNumber: 2}}
+			Expect(t.Init("")).To(Succeed())
+			t.Contracts[fakeAddress].EmittedAddrs = map[string]bool{"0x0000000000000000000000000000000000000001": true}
+
+			err := t.Execute()
+
+			Expect(err).To(HaveOccurred())
+			Expect(t.Contracts[fakeAddress].EmittedAddrs).NotTo(BeEmpty())
+		})
+	})
+
+	Describe("Proxies", func() {
+		var (
+			proxyAddr        = "0x00000000000000000000000000000000000abcde"
+			v1Addr           = "0x0000000000000000000000000000000000000001"
+			v2Addr           = "0x0000000000000000000000000000000000000002"
+			proxyAbi         = `[{"anonymous":false,"name":"Upgraded","type":"event","inputs":[{"indexed":true,"name":"implementation","type":"address"}]},{"constant":true,"inputs":[],"name":"admin","outputs":[{"name":"","type":"address"}],"payable":false,"stateMutability":"view","type":"function"}]`
+			transferAbi      = `{"anonymous":false,"name":"Transfer","type":"event","inputs":[{"indexed":true,"name":"from","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":false,"name":"value","type":"uint256"}]}`
+			mintAbi          = `{"anonymous":false,"name":"Mint","type":"event","inputs":[{"indexed":true,"name":"to","type":"address"},{"indexed":false,"name":"amount","type":"uint256"}]}`
+			v1Abi            = "[" + transferAbi + "]"
+			v2Abi            = "[" + transferAbi + "," + mintAbi + "]"
+			resolver         *fakes.MockProxyResolver
+			eventRepository  *fakes.MockContractWatcherEventRepository
+			headerRepository *fakes.MockContractWatcherHeaderRepository
+			logFetcher       *fakes.MockLogFetcher
+			t                transformer.Transformer
+		)
+
+		BeforeEach(func() {
+			resolver = &fakes.MockProxyResolver{ImplementationToReturn: v1Addr}
+			eventRepository = &fakes.MockContractWatcherEventRepository{}
+			headerRepository = &fakes.MockContractWatcherHeaderRepository{}
+			logFetcher = &fakes.MockLogFetcher{}
+			abiRegistry := &fakes.MockAbiRegistry{Abis: map[string]string{proxyAddr: proxyAbi, v1Addr: v1Abi, v2Addr: v2Abi}}
+			t = transformer.Transformer{
+				Parser:               parser.NewParserWithRegistry("", abiRegistry, true),
+				Retriever:            &fakes.MockBlockRetriever{FirstBlock: 1},
+				HeaderRepository:     headerRepository,
+				EventRepository:      eventRepository,
+				AbiVersionRepository: &fakes.MockAbiVersionRepository{},
+				ProxyResolver:        resolver,
+				Fetcher:              logFetcher,
+				Converter:            converter.NewConverter(),
+				Contracts:            map[string]*contract.Contract{},
+				Config: config.ContractConfig{
+					Addresses:      map[string]bool{proxyAddr: true},
+					Events:         map[string][]string{proxyAddr: {}},
+					StartingBlocks: map[string]int64{proxyAddr: 1},
+				},
+			}
+		})
+
+		It("Watches the events of the implementation behind a proxy, and its upgrades", func() {
+			t.Config.Events[proxyAddr] = []string{"Transfer"}
+
+			err := t.Init("")
+
+			Expect(err).NotTo(HaveOccurred())
+			con := t.Contracts[proxyAddr]
+			Expect(con.IsProxy()).To(BeTrue())
+			Expect(con.ImplementationAt(1)).To(Equal(v1Addr))
+			Expect(con.ProxyAbi).To(Equal(proxyAbi))
+			Expect(con.Events).To(HaveKey("Transfer(address,address,uint256)"))
+			Expect(con.Events).To(HaveKey("Upgraded(address)"))
+			Expect(con.Events).To(HaveKey("BeaconUpgraded(address)"))
+			Expect(con.ParsedAbi.Methods).To(HaveKey("admin"))
+			Expect(resolver.ResolvedBlockNumbers).To(Equal([]int64{1}))
+			Expect(headerRepository.AddedCheckColumns).To(ContainElement("transfer_" + proxyAddr))
+		})
+
+		It("Doesn't treat contracts without an implementation as proxies", func() {
+			resolver.ImplementationToReturn = ""
+			t.Config.Events[proxyAddr] = []string{"Transfer"}
+
+			err := t.Init("")
+
+			Expect(err).NotTo(HaveOccurred())
+			con := t.Contracts[proxyAddr]
+			Expect(con.IsProxy()).To(BeFalse())
+			Expect(con.Abi).To(Equal(proxyAbi))
+			Expect(con.Events).To(BeEmpty())
+		})
+
+		It("Follows upgrades persisted by earlier runs", func() {
+			eventRepository.EventBlockNumbers = map[string][]int64{"Upgraded": {5}}
+			resolver.ImplementationsAtBlocks = map[int64]string{5: v2Addr}
+
+			err := t.Init("")
+
+			Expect(err).NotTo(HaveOccurred())
+			con := t.Contracts[proxyAddr]
+			Expect(con.ImplementationAt(4)).To(Equal(v1Addr))
+			Expect(con.ImplementationAt(5)).To(Equal(v2Addr))
+			Expect(con.Events).To(HaveKey("Mint(address,uint256)"))
+		})
+
+		It("Fails to initialize if the implementation can't be resolved", func() {
+			resolver.ResolveErr = fakes.FakeError
+
+			err := t.Init("")
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(fakes.FakeError.Error()))
+		})
+
+		It("Switches to the implementation a proxy upgrades to, and watches its new events", func() {
+			resolver.ImplementationsAtBlocks = map[int64]string{10: v2Addr}
+			headerRepository.MissingHeadersToReturn = []core.Header{{Id: 1, BlockNumber: 10}}
+			upgradedLog := gethTypes.Log{
+				Address:     common.HexToAddress(proxyAddr),
+				Topics:      []common.Hash{proxy.UpgradedSig, common.HexToHash(v2Addr)},
+				BlockNumber: 10,
+			}
+			mintSig := crypto.Keccak256Hash([]byte("Mint(address,uint256)"))
+			mintLog := gethTypes.Log{
+				Address:     common.HexToAddress(proxyAddr),
+				Topics:      []common.Hash{mintSig, common.HexToHash("0xaf21")},
+				Data:        common.BigToHash(big.NewInt(100)).Bytes(),
+				BlockNumber: 10,
+				Index:       1,
+			}
+			logFetcher.LogsToReturn = [][]gethTypes.Log{{upgradedLog}, {mintLog}}
+			Expect(t.Init("")).To(Succeed())
+			Expect(t.Contracts[proxyAddr].Events).NotTo(HaveKey("Mint(address,uint256)"))
+
+			err := t.Execute()
+
+			Expect(err).NotTo(HaveOccurred())
+			con := t.Contracts[proxyAddr]
+			Expect(con.ImplementationAt(10)).To(Equal(v2Addr))
+			Expect(con.Events).To(HaveKey("Mint(address,uint256)"))
+			Expect(logFetcher.FetchedTopics[1]).To(Equal([]common.Hash{mintSig}))
+			Expect(eventRepository.PersistedLogs["Upgraded"]).To(HaveLen(1))
+			Expect(eventRepository.PersistedLogs["Mint"]).To(HaveLen(1))
+			Expect(eventRepository.PersistedLogs["Mint"][0].Values["amount"]).To(Equal("100"))
+			mintID := strings.ToLower("Mint_" + proxyAddr)
+			Expect(headerRepository.AddedCheckColumns).To(ContainElement(mintID))
+			Expect(headerRepository.MarkedCheckedIDs[0]).To(ContainElement(mintID))
+		})
+	})
+
+	Describe("Filters", func() {
+		var (
+			contractAddr    = "0x00000000000000000000000000000000000abcde"
+			recipient       = common.HexToAddress("0x0000000000000000000000000000000000000002")
+			tokenAbi        = `[{"anonymous":false,"name":"Transfer","type":"event","inputs":[{"indexed":true,"name":"from","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":false,"name":"value","type":"uint256"}]},{"anonymous":false,"name":"Approval","type":"event","inputs":[{"indexed":true,"name":"owner","type":"address"},{"indexed":true,"name":"spender","type":"address"},{"indexed":false,"name":"value","type":"uint256"}]}]`
+			transferSig     = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+			approvalSig     = crypto.Keccak256Hash([]byte("Approval(address,address,uint256)"))
+			eventRepository *fakes.MockContractWatcherEventRepository
+			logFetcher      *fakes.MockLogFetcher
+			t               transformer.Transformer
+		)
+
+		transferLog := func(index uint, value int64) gethTypes.Log {
+			return gethTypes.Log{
+				Address: common.HexToAddress(contractAddr),
+				Topics:  []common.Hash{transferSig, common.HexToHash("0x1"), recipient.Hash()},
+				Data:    common.BigToHash(big.NewInt(value)).Bytes(),
+				Index:   index,
+			}
+		}
+
+		BeforeEach(func() {
+			eventRepository = &fakes.MockContractWatcherEventRepository{}
+			logFetcher = &fakes.MockLogFetcher{}
+			t = transformer.Transformer{
+				Parser:               parser.NewParserWithRegistry("", &fakes.MockAbiRegistry{Abis: map[string]string{contractAddr: tokenAbi}}, true),
+				Retriever:            &fakes.MockBlockRetriever{FirstBlock: 1},
+				HeaderRepository:     &fakes.MockContractWatcherHeaderRepository{MissingHeadersToReturn: []core.Header{{Id: 1, BlockNumber: 1}}},
+				EventRepository:      eventRepository,
+				AbiVersionRepository: &fakes.MockAbiVersionRepository{},
+				ProxyResolver:        &fakes.MockProxyResolver{},
+				Fetcher:              logFetcher,
+				Converter:            converter.NewConverter(),
+				Contracts:            map[string]*contract.Contract{},
+				Config: config.ContractConfig{
+					Addresses:      map[string]bool{contractAddr: true},
+					Events:         map[string][]string{contractAddr: {}},
+					StartingBlocks: map[string]int64{contractAddr: 1},
+					EventFilters:   map[string]map[string]string{contractAddr: {"transfer": "to == " + recipient.Hex() + " AND value >= 1000"}},
+				},
+			}
+		})
+
+		It("Compiles the filters of watched events", func() {
+			err := t.Init("")
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(t.Contracts[contractAddr].Filters).To(HaveKey("Transfer"))
+		})
+
+		It("Fetches the logs of events filtered on indexed fields with their topics", func() {
+			Expect(t.Init("")).To(Succeed())
+
+			err := t.Execute()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(logFetcher.FetchedTopics).To(Equal([][]common.Hash{{approvalSig}}))
+			Expect(logFetcher.FetchedFilters).To(Equal([][]fetcher.TopicFilter{{{
+				ContractAddress: contractAddr,
+				Topics:          [][]common.Hash{{transferSig}, nil, {recipient.Hash()}},
+			}}}))
+		})
+
+		It("Only persists logs that match the filter, once", func() {
+			logFetcher.LogsToReturn = [][]gethTypes.Log{{transferLog(2, 1000)}}
+			logFetcher.FilteredLogsToReturn = []gethTypes.Log{transferLog(1, 999), transferLog(2, 1000)}
+			Expect(t.Init("")).To(Succeed())
+
+			err := t.Execute()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(eventRepository.PersistedLogs["Transfer"]).To(HaveLen(1))
+			Expect(eventRepository.PersistedLogs["Transfer"][0].Values["value"]).To(Equal("1000"))
+		})
+
+		It("Fails to initialize if a filter is invalid", func() {
+			t.Config.EventFilters[contractAddr]["transfer"] = "value == 0xnope"
+
+			err := t.Init("")
+
+			Expect(err).To(HaveOccurred())
+			Expect(errors.Is(err, filter.ErrInvalidFilter)).To(BeTrue())
+		})
+
+		It("Fails to initialize if a filter is for an event that isn't watched", func() {
+			t.Config.Events[contractAddr] = []string{"Approval"}
+
+			err := t.Init("")
+
+			Expect(err).To(HaveOccurred())
+			Expect(errors.Is(err, filter.ErrInvalidFilter)).To(BeTrue())
+		})
+	})
+
+	Describe("Anonymous and overloaded events", func() {
+		var (
+			contractAddr     = "0x00000000000000000000000000000000000abcde"
+			who              = common.HexToAddress("0x000000000000000000000000000000000000Af21")
+			tokenAbi         = `[{"anonymous":false,"name":"Transfer","type":"event","inputs":[{"indexed":true,"name":"from","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":false,"name":"value","type":"uint256"}]},{"anonymous":false,"name":"Transfer","type":"event","inputs":[{"indexed":true,"name":"from","type":"address"},{"indexed":false,"name":"value","type":"uint256"}]},{"anonymous":true,"name":"Poke","type":"event","inputs":[{"indexed":true,"name":"who","type":"address"},{"indexed":false,"name":"value","type":"uint256"}]}]`
+			transferSig      = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+			shortTransferSig = crypto.Keccak256Hash([]byte("Transfer(address,uint256)"))
+			eventRepository  *fakes.MockContractWatcherEventRepository
+			headerRepository *fakes.MockContractWatcherHeaderRepository
+			logFetcher       *fakes.MockLogFetcher
+			t                transformer.Transformer
+		)
+
+		BeforeEach(func() {
+			eventRepository = &fakes.MockContractWatcherEventRepository{}
+			headerRepository = &fakes.MockContractWatcherHeaderRepository{MissingHeadersToReturn: []core.Header{{Id: 1, BlockNumber: 1}}}
+			logFetcher = &fakes.MockLogFetcher{}
+			t = transformer.Transformer{
+				Parser:               parser.NewParserWithRegistry("", &fakes.MockAbiRegistry{Abis: map[string]string{contractAddr: tokenAbi}}, true),
+				Retriever:            &fakes.MockBlockRetriever{FirstBlock: 1},
+				HeaderRepository:     headerRepository,
+				EventRepository:      eventRepository,
+				AbiVersionRepository: &fakes.MockAbiVersionRepository{},
+				ProxyResolver:        &fakes.MockProxyResolver{},
+				Fetcher:              logFetcher,
+				Converter:            converter.NewConverter(),
+				Contracts:            map[string]*contract.Contract{},
+				Config: config.ContractConfig{
+					Addresses:       map[string]bool{contractAddr: true},
+					Events:          map[string][]string{contractAddr: {}},
+					StartingBlocks:  map[string]int64{contractAddr: 1},
+					AnonymousEvents: map[string]map[string]config.AnonymousEvent{contractAddr: {"poke": {Topics: [][]string{{who.Hex()}}}}},
+				},
+			}
+		})
+
+		It("Gives overloaded events their own tables, keeping the first overload's table", func() {
+			err := t.Init("")
+
+			Expect(err).NotTo(HaveOccurred())
+			shortTransferName := fmt.Sprintf("Transfer_%x", shortTransferSig[:4])
+			Expect(eventRepository.CreatedTables).To(ConsistOf("Transfer", shortTransferName, "Poke"))
+			Expect(headerRepository.AddedCheckColumns).To(ContainElement(strings.ToLower("Transfer_" + contractAddr)))
+			Expect(headerRepository.AddedCheckColumns).To(ContainElement(strings.ToLower(shortTransferName + "_" + contractAddr)))
+		})
+
+		It("Fetches the logs of anonymous events by the topics of their layout", func() {
+			Expect(t.Init("")).To(Succeed())
+
+			err := t.Execute()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(logFetcher.FetchedTopics).To(HaveLen(1))
+			Expect(logFetcher.FetchedTopics[0]).To(ConsistOf(transferSig, shortTransferSig))
+			Expect(logFetcher.FetchedFilters).To(Equal([][]fetcher.TopicFilter{{{
+				ContractAddress: contractAddr,
+				Topics:          [][]common.Hash{{who.Hash()}},
+			}}}))
+		})
+
+		It("Persists the logs of anonymous and overloaded events", func() {
+			value := common.BigToHash(big.NewInt(1000)).Bytes()
+			logFetcher.LogsToReturn = [][]gethTypes.Log{{
+				{Address: common.HexToAddress(contractAddr), Topics: []common.Hash{shortTransferSig, who.Hash()}, Data: value},
+			}}
+			logFetcher.FilteredLogsToReturn = []gethTypes.Log{
+				{Address: common.HexToAddress(contractAddr), Topics: []common.Hash{who.Hash()}, Data: value, Index: 1},
+			}
+			Expect(t.Init("")).To(Succeed())
+
+			err := t.Execute()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(eventRepository.PersistedLogs[fmt.Sprintf("Transfer_%x", shortTransferSig[:4])]).To(HaveLen(1))
+			Expect(eventRepository.PersistedLogs["Poke"]).To(HaveLen(1))
+			Expect(eventRepository.PersistedLogs["Poke"][0].Values["who"]).To(Equal(who.Hex()))
+		})
+
+		It("Fails to initialize if a layout's topics aren't 32 byte hex values", func() {
+			t.Config.AnonymousEvents[contractAddr]["poke"] = config.AnonymousEvent{Topics: [][]string{{"nope"}}}
+
+			err := t.Init("")
+
+			Expect(err).To(HaveOccurred())
+			Expect(errors.Is(err, transformer.ErrInvalidLayout)).To(BeTrue())
+		})
+
+		It("Skips anonymous events without a layout constraining a topic when all events are watched", func() {
+			t.Config.AnonymousEvents = nil
+
+			err := t.Init("")
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(eventRepository.CreatedTables).NotTo(ContainElement("Poke"))
+			Expect(t.Contracts[contractAddr].Events).NotTo(HaveKey("Poke(address,uint256)"))
+			Expect(t.Execute()).To(Succeed())
+			Expect(logFetcher.FetchedFilters).To(BeEmpty())
+		})
+
+		It("Fails to initialize if a named anonymous event has no layout constraining a topic", func() {
+			t.Config.Events[contractAddr] = []string{"Transfer", "Poke"}
+			t.Config.AnonymousEvents[contractAddr]["poke"] = config.AnonymousEvent{DataLength: 32}
+
+			err := t.Init("")
+
+			Expect(err).To(HaveOccurred())
+			Expect(errors.Is(err, transformer.ErrInvalidLayout)).To(BeTrue())
+		})
+
+		It("Fails to initialize if a layout is for an event that isn't anonymous", func() {
+			t.Config.AnonymousEvents[contractAddr]["transfer"] = config.AnonymousEvent{}
+
+			err := t.Init("")
+
+			Expect(err).To(HaveOccurred())
+			Expect(errors.Is(err, transformer.ErrInvalidLayout)).To(BeTrue())
+		})
+	})
+
+	Describe("Ending blocks", func() {
+		var (
+			endingAddr       = "0x0000000000000000000000000000000000000001"
+			continuingAddr   = "0x0000000000000000000000000000000000000002"
+			tokenAbi         = `[{"anonymous":false,"name":"Transfer","type":"event","inputs":[{"indexed":true,"name":"from","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":false,"name":"value","type":"uint256"}]}]`
+			blockRetriever   *fakes.MockBlockRetriever
+			headerRepository *fakes.MockContractWatcherHeaderRepository
+			logFetcher       *fakes.MockLogFetcher
+			t                transformer.Transformer
+		)
+
+		BeforeEach(func() {
+			blockRetriever = &fakes.MockBlockRetriever{FirstBlock: 1, MostRecentBlock: 3}
+			headerRepository = &fakes.MockContractWatcherHeaderRepository{MissingHeadersToReturn: []core.Header{
+				{Id: 1, BlockNumber: 1},
+				{Id: 2, BlockNumber: 2},
+				{Id: 3, BlockNumber: 3},
+			}}
+			logFetcher = &fakes.MockLogFetcher{}
+			abiRegistry := &fakes.MockAbiRegistry{Abis: map[string]string{endingAddr: tokenAbi, continuingAddr: tokenAbi}}
+			t = transformer.Transformer{
+				Parser:               parser.NewParserWithRegistry("", abiRegistry, true),
+				Retriever:            blockRetriever,
+				HeaderRepository:     headerRepository,
+				EventRepository:      &fakes.MockContractWatcherEventRepository{},
+				AbiVersionRepository: &fakes.MockAbiVersionRepository{},
+				ProxyResolver:        &fakes.MockProxyResolver{},
+				Fetcher:              logFetcher,
+				Converter:            converter.NewConverter(),
+				Contracts:            map[string]*contract.Contract{},
+				Config: config.ContractConfig{
+					Addresses:      map[string]bool{endingAddr: true, continuingAddr: true},
+					Events:         map[string][]string{endingAddr: {}, continuingAddr: {}},
+					StartingBlocks: map[string]int64{endingAddr: 1, continuingAddr: 1},
+					EndingBlocks:   map[string]int64{endingAddr: 2, continuingAddr: -1},
+				},
+			}
+		})
+
+		It("Initializes contracts with their ending blocks", func() {
+			delete(t.Config.EndingBlocks, continuingAddr)
+
+			err := t.Init("")
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(t.Contracts[endingAddr].EndingBlock).To(Equal(int64(2)))
+			Expect(t.Contracts[continuingAddr].EndingBlock).To(Equal(int64(-1)))
+		})
+
+		It("Stops fetching logs and checking headers for contracts past their ending block", func() {
+			endingID := "transfer_" + endingAddr
+			continuingID := "transfer_" + continuingAddr
+			Expect(t.Init("")).To(Succeed())
+
+			err := t.Execute()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(headerRepository.MarkedHeaderIDs).To(Equal([]int64{1, 2, 3}))
+			Expect(headerRepository.MarkedCheckedIDs[1]).To(ConsistOf(endingID, continuingID))
+			Expect(headerRepository.MarkedCheckedIDs[2]).To(ConsistOf(continuingID))
+			Expect(logFetcher.FetchedAddresses[1]).To(ConsistOf(endingAddr, continuingAddr))
+			Expect(logFetcher.FetchedAddresses[2]).To(ConsistOf(continuingAddr))
+		})
+
+		It("Gets the missing headers of every contract in a single query", func() {
+			Expect(t.Init("")).To(Succeed())
+
+			err := t.Execute()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(headerRepository.MissingHeadersRanges).To(HaveLen(1))
+			Expect(headerRepository.MissingHeadersRanges[0]).To(ConsistOf(
+				repository.CheckRange{StartingBlock: 1, EndingBlock: 2, IDs: []string{"transfer_" + endingAddr}},
+				repository.CheckRange{StartingBlock: 1, EndingBlock: -1, IDs: []string{"transfer_" + continuingAddr}},
+			))
+		})
+
+		It("Summarizes the work done for each contract", func() {
+			Expect(t.Init("")).To(Succeed())
+			Expect(t.Execute()).To(Succeed())
+
+			Expect(t.Summary()).To(Equal([]transformer.ContractProgress{
+				{Address: endingAddr, StartingBlock: 1, EndingBlock: 2, LastBlock: 2, HeadersChecked: 2},
+				{Address: continuingAddr, StartingBlock: 1, EndingBlock: -1, LastBlock: 3, HeadersChecked: 3},
+			}))
+		})
+
+		Describe("Done", func() {
+			BeforeEach(func() {
+				t.Config.EndingBlocks[continuingAddr] = 3
+				Expect(t.Init("")).To(Succeed())
+			})
+
+			It("Is done once every contract has been checked up to its ending block", func() {
+				headerRepository.MissingHeadersToReturn = nil
+
+				done, err := t.Done()
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(done).To(BeTrue())
+			})
+
+			It("Isn't done while a contract has headers left to check", func() {
+				headerRepository.MissingHeadersToReturn = []core.Header{{Id: 3, BlockNumber: 3}}
+
+				done, err := t.Done()
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(done).To(BeFalse())
+			})
+
+			It("Isn't done while a contract's range is missing headers", func() {
+				headerRepository.MissingHeadersToReturn = nil
+				headerRepository.HeadersNotSynced = true
+
+				done, err := t.Done()
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(done).To(BeFalse())
+			})
+
+			It("Isn't done while headers haven't been synced up to a contract's ending block", func() {
+				headerRepository.MissingHeadersToReturn = nil
+				blockRetriever.MostRecentBlock = 2
+
+				done, err := t.Done()
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(done).To(BeFalse())
+			})
+
+			It("Is never done while a contract is watched indefinitely", func() {
+				headerRepository.MissingHeadersToReturn = nil
+				t.Contracts[continuingAddr].EndingBlock = -1
+
+				done, err := t.Done()
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(done).To(BeFalse())
+			})
+		})
+	})
+
+	Describe("Calls", func() {
+		var (
+			tokenAddr = "0x0000000000000000000000000000000000000001"
+			tokenAbi  = `[
+				{"anonymous":false,"name":"Transfer","type":"event","inputs":[{"indexed":true,"name":"from","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":false,"name":"value","type":"uint256"}]},
+				{"constant":false,"name":"transfer","type":"function","inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]}
+			]`
+			callID           = "transfer_" + tokenAddr + "_call"
+			headerRepository *fakes.MockContractWatcherHeaderRepository
+			callRepository   *fakes.MockCallRepository
+			syncer           *fakes.MockTransactionSyncer
+			logFetcher       *fakes.MockLogFetcher
+			transferLog      gethTypes.Log
+			transferInput    []byte
+			t                transformer.Transformer
+		)
+
+		BeforeEach(func() {
+			headerRepository = &fakes.MockContractWatcherHeaderRepository{MissingHeadersToReturn: []core.Header{{Id: 1, BlockNumber: 1}}}
+			callRepository = &fakes.MockCallRepository{}
+			syncer = &fakes.MockTransactionSyncer{}
+			logFetcher = &fakes.MockLogFetcher{}
+			transferLog = gethTypes.Log{
+				Address: common.HexToAddress(tokenAddr),
+				Topics: []common.Hash{
+					crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)")),
+					common.HexToHash("0x2"),
+					common.HexToHash("0x3"),
+				},
+				Data:   common.LeftPadBytes(big.NewInt(1000).Bytes(), 32),
+				TxHash: common.HexToHash("0x4"),
+			}
+			transferInput = append(crypto.Keccak256([]byte("transfer(address,uint256)"))[:4],
+				append(common.LeftPadBytes(common.FromHex("0x3"), 32), common.LeftPadBytes(big.NewInt(1000).Bytes(), 32)...)...)
+			abiRegistry := &fakes.MockAbiRegistry{Abis: map[string]string{tokenAddr: tokenAbi}}
+			t = transformer.Transformer{
+				Parser:               parser.NewParserWithRegistry("", abiRegistry, true),
+				Retriever:            &fakes.MockBlockRetriever{FirstBlock: 1},
+				HeaderRepository:     headerRepository,
+				EventRepository:      &fakes.MockContractWatcherEventRepository{},
+				AbiVersionRepository: &fakes.MockAbiVersionRepository{},
+				CallRepository:       callRepository,
+				ProxyResolver:        &fakes.MockProxyResolver{},
+				Fetcher:              logFetcher,
+				Converter:            converter.NewConverter(),
+				Syncer:               syncer,
+				Contracts:            map[string]*contract.Contract{},
+				Config: config.ContractConfig{
+					Addresses:      map[string]bool{tokenAddr: true},
+					Events:         map[string][]string{tokenAddr: {}},
+					StartingBlocks: map[string]int64{tokenAddr: 1},
+					Calls:          map[string][]string{tokenAddr: {"transfer"}},
+				},
+			}
+		})
+
+		It("Creates the tables of decoded calls and registers their ids for checking headers", func() {
+			err := t.Init("")
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(t.Contracts[tokenAddr].Calls).To(HaveKey("transfer"))
+			Expect(callRepository.CreatedTables).To(ConsistOf("transfer"))
+			Expect(headerRepository.AddedCheckColumns).To(ContainElement(callID))
+		})
+
+		It("Fails to initialize with calls of methods that aren't in the abi", func() {
+			t.Config.Calls[tokenAddr] = []string{"notAMethod"}
+
+			err := t.Init("")
+
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Fails to initialize if a call table can't be created", func() {
+			callRepository.CreateCallTableErr = fakes.FakeError
+
+			err := t.Init("")
+
+			Expect(err).To(HaveOccurred())
+			Expect(errors.Is(err, fakes.FakeError)).To(BeTrue())
+		})
+
+		It("Syncs the transactions sent to the contract, then decodes and persists their calls", func() {
+			tx := types.Transaction{HeaderID: 1, Hash: "0x4", TxIndex: 0, From: "0x5", Input: transferInput,
+				Status: sql.NullInt64{Int64: 1, Valid: true}}
+			logFetcher.LogsToReturn = [][]gethTypes.Log{{transferLog}}
+			callRepository.TransactionsToReturn = map[int64][]types.Transaction{1: {
+				tx,
+				{HeaderID: 1, Hash: "0x6", TxIndex: 1, From: "0x5"},                                      // Plain ether transfer
+				{HeaderID: 1, Hash: "0x7", TxIndex: 2, From: "0x5", Input: common.FromHex("0x095ea7b3")}, // Unwatched method
+				{HeaderID: 1, Hash: "0x8", TxIndex: 3, From: "0x5", Input: transferInput[:20]},           // Truncated input
+			}}
+			Expect(t.Init("")).To(Succeed())
+
+			err := t.Execute()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(syncer.SyncedToHeaders).To(Equal([]core.Header{{Id: 1, BlockNumber: 1}}))
+			Expect(syncer.SyncedToAddresses).To(Equal([][]string{{tokenAddr}}))
+			Expect(callRepository.QueriedAddresses).To(Equal([]string{tokenAddr}))
+			Expect(callRepository.PersistedCalls).To(HaveLen(1))
+			Expect(callRepository.PersistedCalls[0].Name).To(Equal("transfer"))
+			Expect(callRepository.PersistedResults).To(Equal([][]types.CallResult{{{
+				Transaction: tx,
+				Values:      map[string]string{"to": common.HexToAddress("0x3").Hex(), "value": "1000"},
+			}}}))
+			Expect(headerRepository.MarkedCheckedIDs[0]).To(ContainElement(callID))
+			Expect(t.Summary()[0].CallsPersisted).To(Equal(1))
+			Expect(t.Summary()[0].LogsPersisted).To(Equal(1))
+		})
+
+		It("Decodes the calls of reverted transactions at headers without logs", func() {
+			reverted := types.Transaction{HeaderID: 1, Hash: "0x4", From: "0x5", Input: transferInput,
+				Status: sql.NullInt64{Int64: 0, Valid: true}}
+			callRepository.TransactionsToReturn = map[int64][]types.Transaction{1: {reverted}}
+			Expect(t.Init("")).To(Succeed())
+
+			err := t.Execute()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(syncer.SyncedToHeaders).To(Equal([]core.Header{{Id: 1, BlockNumber: 1}}))
+			Expect(syncer.SyncTransactionsCalled).To(BeFalse())
+			Expect(callRepository.PersistedCalls).To(HaveLen(1))
+			Expect(callRepository.PersistedResults[0][0].Transaction).To(Equal(reverted))
+			Expect(headerRepository.MarkedCheckedIDs[0]).To(ContainElement(callID))
+		})
+
+		It("Doesn't sync or decode transactions for contracts without calls", func() {
+			t.Config.Calls = nil
+			logFetcher.LogsToReturn = [][]gethTypes.Log{{transferLog}}
+			Expect(t.Init("")).To(Succeed())
+
+			err := t.Execute()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(syncer.SyncedToHeaders).To(BeEmpty())
+			Expect(callRepository.QueriedAddresses).To(BeEmpty())
+		})
+
+		It("Fails if transactions can't be synced", func() {
+			syncer.SyncTransactionsToErr = fakes.FakeError
+			logFetcher.LogsToReturn = [][]gethTypes.Log{{transferLog}}
+			Expect(t.Init("")).To(Succeed())
+
+			err := t.Execute()
+
+			Expect(err).To(HaveOccurred())
+			Expect(errors.Is(err, fakes.FakeError)).To(BeTrue())
+			Expect(headerRepository.MarkedHeaderIDs).To(BeEmpty())
+		})
+	})
 })
 
 func getFakeTransformer(blockRetriever retriever.BlockRetriever, parsr parser.Parser) transformer.Transformer {
 	return transformer.Transformer{
-		Parser:           parsr,
-		Retriever:        blockRetriever,
-		HeaderRepository: &fakes.MockContractWatcherHeaderRepository{},
-		Contracts:        map[string]*contract.Contract{},
-		Config:           mocks.MockConfig,
+		Parser:               parsr,
+		Retriever:            blockRetriever,
+		HeaderRepository:     &fakes.MockContractWatcherHeaderRepository{},
+		EventRepository:      &fakes.MockContractWatcherEventRepository{},
+		AbiVersionRepository: &fakes.MockAbiVersionRepository{},
+		ProxyResolver:        &fakes.MockProxyResolver{},
+		Contracts:            map[string]*contract.Contract{},
+		Config:               mocks.MockConfig,
 	}
 }
@@ -0,0 +1,120 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+// Call is our custom type for a contract method whose calls are decoded from the input of transactions
+type Call struct {
+	Name    string // Distinct name of the method, used to name its table; see CallNames
+	RawName string // Name of the method in the contract, shared by overloaded methods
+	ID      []byte // Four byte selector the method's calldata starts with
+	Args    []Field
+}
+
+// Transaction holds the fields of a synced transaction needed to decode its input as a call
+type Transaction struct {
+	HeaderID int64         `db:"header_id"`
+	Hash     string        `db:"hash"`
+	TxIndex  int64         `db:"tx_index"`
+	From     string        `db:"tx_from"`
+	Input    []byte        `db:"input_data"`
+	Status   sql.NullInt64 `db:"status"` // Status of the transaction's receipt; invalid if its receipt hasn't been synced
+}
+
+// CallResult holds the arguments decoded from a transaction's input
+type CallResult struct {
+	Transaction
+	Values map[string]string // Map of argument column names to their values
+}
+
+// NewCall unpacks abi.Method into our custom Call struct
+// Unnamed arguments are named after their position (arg0, arg1, ...)
+func NewCall(m abi.Method) Call {
+	args := make([]Field, len(m.Inputs))
+	for i, input := range m.Inputs {
+		args[i] = Field{}
+		args[i].Name = input.Name
+		if args[i].Name == "" {
+			args[i].Name = fmt.Sprintf("arg%d", i)
+		}
+		args[i].Type = input.Type
+		args[i].PgType = pgType(input.Type)
+	}
+
+	return Call{
+		Name:    m.RawName,
+		RawName: m.RawName,
+		ID:      m.ID,
+		Args:    args,
+	}
+}
+
+// CallNames returns the distinct names of the abi's methods, keyed by the abi's name for them
+// Overloaded methods are suffixed with their selector (e.g. transfer_a9059cbb), like overloaded events
+func CallNames(parsedAbi abi.ABI) map[string]string {
+	overloads := make(map[string]int, len(parsedAbi.Methods))
+	for _, m := range parsedAbi.Methods {
+		overloads[m.RawName]++
+	}
+	names := make(map[string]string, len(parsedAbi.Methods))
+	for key, m := range parsedAbi.Methods {
+		names[key] = m.RawName
+		if overloads[m.RawName] > 1 {
+			names[key] = fmt.Sprintf("%s_%x", m.RawName, m.ID)
+		}
+	}
+	return names
+}
+
+// Matches returns true if the input is calldata for the method, i.e. starts with its selector
+func (c Call) Matches(input []byte) bool {
+	return len(input) >= len(c.ID) && len(c.ID) > 0 && bytes.Equal(input[:len(c.ID)], c.ID)
+}
+
+// Decode unpacks the transaction's input into the values of the call's argument columns
+func (c Call) Decode(tx Transaction) (CallResult, error) {
+	if !c.Matches(tx.Input) {
+		return CallResult{}, fmt.Errorf("input of transaction %s is not a call to %s", tx.Hash, c.Name)
+	}
+	arguments := make(abi.Arguments, len(c.Args))
+	for i, arg := range c.Args {
+		arguments[i] = arg.Argument
+	}
+	decoded, err := arguments.UnpackValues(tx.Input[len(c.ID):])
+	if err != nil {
+		return CallResult{}, fmt.Errorf("error decoding input of transaction %s: %w", tx.Hash, err)
+	}
+
+	values := map[string]string{}
+	for i, arg := range c.Args {
+		argValues, err := FieldValues(arg, decoded[i])
+		if err != nil {
+			return CallResult{}, err
+		}
+		for name, value := range argValues {
+			values[name] = value
+		}
+	}
+	return CallResult{Transaction: tx, Values: values}, nil
+}
@@ -22,12 +22,14 @@ import (
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
+	gethTypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 )
 
 // Event is our custom event type
 type Event struct {
-	Name      string
+	Name      string // Distinct name of the event, used to name its table; see EventNames
+	RawName   string // Name of the event in the contract, shared by overloaded events; defaults to Name
 	Anonymous bool
 	Fields    []Field
 }
@@ -38,6 +40,12 @@ type Field struct {
 	PgType       string // Holds type used when committing data held in this field to postgres
 }
 
+// Column is a postgres column holding a field's value, or part of it for a tuple field
+type Column struct {
+	Name   string // Name of the value in Log.Values; the column name is its lower case form with a trailing underscore
+	PgType string
+}
+
 // Log is used to hold instance of an event log data
 type Log struct {
 	HeaderID         int64             // header ID
@@ -47,7 +55,24 @@ type Log struct {
 	Raw              []byte // json.Unmarshalled byte array of geth/core/types.Log{}
 }
 
+// LogLayout identifies the logs of an anonymous event, which have no signature topic to be matched by
+type LogLayout struct {
+	Topics     [][]common.Hash // Values allowed at each topic position; empty positions allow any value
+	DataLength int             // Length of the log data in bytes; if zero, it's derived from the event's fields when they're static
+}
+
+// ConstrainsTopics returns true if the layout restricts at least one topic position to given values
+func (layout LogLayout) ConstrainsTopics() bool {
+	for _, values := range layout.Topics {
+		if len(values) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
 // NewEvent unpacks abi.Event into our custom Event struct
+// Overloaded events are all named after the contract's name for them, see EventNames for telling them apart
 func NewEvent(e abi.Event) Event {
 	fields := make([]Field, len(e.Inputs))
 	for i, input := range e.Inputs {
@@ -55,39 +80,176 @@ func NewEvent(e abi.Event) Event {
 		fields[i].Name = input.Name
 		fields[i].Type = input.Type
 		fields[i].Indexed = input.Indexed
-		// Fill in pg type based on abi type
-		switch fields[i].Type.T {
-		case abi.HashTy, abi.AddressTy:
+		fields[i].PgType = pgType(input.Type)
+		// Indexed values of dynamic types are only available as the keccak256 hash of their encoding
+		if input.Indexed && isComposite(input.Type) {
 			fields[i].PgType = "CHARACTER VARYING(66)"
-		case abi.IntTy, abi.UintTy:
-			fields[i].PgType = "NUMERIC"
-		case abi.BoolTy:
-			fields[i].PgType = "BOOLEAN"
-		case abi.BytesTy, abi.FixedBytesTy:
-			fields[i].PgType = "BYTEA"
-		case abi.ArrayTy:
-			fields[i].PgType = "TEXT[]"
-		case abi.FixedPointTy:
-			fields[i].PgType = "MONEY" // use shopspring/decimal for fixed point numbers in go and money type in postgres?
-		default:
-			fields[i].PgType = "TEXT"
 		}
 	}
 
 	return Event{
-		Name:      e.Name,
+		Name:      e.RawName,
+		RawName:   e.RawName,
 		Anonymous: e.Anonymous,
 		Fields:    fields,
 	}
 }
 
-// Sig returns the hash signature for an event
-func (e Event) Sig() common.Hash {
+// EventNames returns the distinct names of the abi's events, keyed by the abi's name for them
+// The first of overloaded events in the abi, which share a name in the contract, keeps the name, so that it keeps the
+// table it was persisted to before overloads were told apart; the others are suffixed with the first four bytes of
+// their signature hash (e.g. Transfer_ddf252ad), so that each gets its own table
+func EventNames(parsedAbi abi.ABI) map[string]string {
+	overloads := make(map[string]int, len(parsedAbi.Events))
+	for _, e := range parsedAbi.Events {
+		overloads[e.RawName]++
+	}
+	names := make(map[string]string, len(parsedAbi.Events))
+	for key, e := range parsedAbi.Events {
+		names[key] = e.RawName
+		// the abi keys the first overload by its name and the others by the name and an index
+		if overloads[e.RawName] > 1 && key != e.RawName {
+			names[key] = fmt.Sprintf("%s_%x", e.RawName, e.ID[:4])
+		}
+	}
+	return names
+}
+
+// Columns returns the columns holding the field's value
+// Tuples are flattened into a column per element, named after the field and the element (e.g. order_amount_); tuples
+// nested in arrays are stored as JSONB
+func (f Field) Columns() []Column {
+	if f.Type.T == abi.TupleTy && !f.Indexed {
+		return tupleColumns(f.Name, f.Type)
+	}
+	return []Column{{Name: f.Name, PgType: f.PgType}}
+}
+
+func tupleColumns(name string, t abi.Type) []Column {
+	var columns []Column
+	for i, elem := range t.TupleElems {
+		elemName := name + "_" + t.TupleRawNames[i]
+		if elem.T == abi.TupleTy {
+			columns = append(columns, tupleColumns(elemName, *elem)...)
+		} else {
+			columns = append(columns, Column{Name: elemName, PgType: pgType(*elem)})
+		}
+	}
+	return columns
+}
+
+// pgType returns the postgres type used to hold values of the abi type
+// Arrays of scalars are typed arrays; arrays of arrays or tuples, which postgres arrays can't represent, are JSONB
+func pgType(t abi.Type) string {
+	switch t.T {
+	case abi.HashTy, abi.AddressTy:
+		return "CHARACTER VARYING(66)"
+	case abi.IntTy, abi.UintTy, abi.FixedPointTy:
+		return "NUMERIC"
+	case abi.BoolTy:
+		return "BOOLEAN"
+	case abi.BytesTy, abi.FixedBytesTy, abi.FunctionTy:
+		return "BYTEA"
+	case abi.ArrayTy, abi.SliceTy:
+		if isComposite(*t.Elem) {
+			return "JSONB"
+		}
+		return pgType(*t.Elem) + "[]"
+	case abi.TupleTy:
+		return "JSONB"
+	default:
+		return "TEXT"
+	}
+}
+
+func isComposite(t abi.Type) bool {
+	return t.T == abi.ArrayTy || t.T == abi.SliceTy || t.T == abi.TupleTy
+}
+
+// Signature returns the event's canonical signature, e.g. Transfer(address,address,uint256), which identifies it
+// among overloaded events
+func (e Event) Signature() string {
 	types := make([]string, len(e.Fields))
 
 	for i, input := range e.Fields {
 		types[i] = input.Type.String()
 	}
 
-	return crypto.Keccak256Hash([]byte(fmt.Sprintf("%v(%v)", e.Name, strings.Join(types, ","))))
+	name := e.RawName
+	if name == "" {
+		name = e.Name
+	}
+	return fmt.Sprintf("%v(%v)", name, strings.Join(types, ","))
+}
+
+// Sig returns the hash signature for an event
+func (e Event) Sig() common.Hash {
+	return crypto.Keccak256Hash([]byte(e.Signature()))
+}
+
+// Matches returns true if the log has the shape of the event under the layout: a topic for each indexed field, with
+// the allowed values, and data of the layout's length
+// Used for anonymous events, whose logs have no signature topic
+func (l LogLayout) Matches(e Event, log gethTypes.Log) bool {
+	var indexed, dataLength int
+	dynamic := false
+	for _, field := range e.Fields {
+		if field.Indexed {
+			indexed++
+			continue
+		}
+		size, static := staticSize(field.Type)
+		dataLength += size
+		dynamic = dynamic || !static
+	}
+	if len(log.Topics) != indexed || len(l.Topics) > indexed {
+		return false
+	}
+	for i, allowed := range l.Topics {
+		if len(allowed) > 0 && !containsHash(allowed, log.Topics[i]) {
+			return false
+		}
+	}
+	if l.DataLength > 0 {
+		return len(log.Data) == l.DataLength
+	}
+	if dynamic {
+		return len(log.Data) >= dataLength && len(log.Data)%32 == 0
+	}
+	return len(log.Data) == dataLength
+}
+
+// staticSize returns the size of the type's abi encoding, or of its head if it's dynamic
+func staticSize(t abi.Type) (int, bool) {
+	switch t.T {
+	case abi.StringTy, abi.BytesTy, abi.SliceTy:
+		return 32, false
+	case abi.ArrayTy:
+		elemSize, static := staticSize(*t.Elem)
+		if !static {
+			return 32, false
+		}
+		return t.Size * elemSize, true
+	case abi.TupleTy:
+		size := 0
+		for _, elem := range t.TupleElems {
+			elemSize, static := staticSize(*elem)
+			if !static {
+				return 32, false
+			}
+			size += elemSize
+		}
+		return size, true
+	default:
+		return 32, true
+	}
+}
+
+func containsHash(hashes []common.Hash, hash common.Hash) bool {
+	for _, h := range hashes {
+		if h == hash {
+			return true
+		}
+	}
+	return false
 }
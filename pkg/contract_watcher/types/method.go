@@ -0,0 +1,82 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+// Method is our custom type for a contract getter polled by the watcher
+type Method struct {
+	Name   string
+	Args   []Field
+	Return Field
+}
+
+// MethodResult holds the value returned by a method call at a header, for the given arguments
+type MethodResult struct {
+	HeaderID int64
+	Inputs   []string // Argument values, in the order of the method's args
+	Output   string
+}
+
+// NewMethod unpacks abi.Method into our custom Method struct
+// Only constant methods with a single return value, and arguments that can be taken from values emitted by events
+// (addresses and 32 byte values), can be polled
+func NewMethod(m abi.Method) (Method, error) {
+	if !m.IsConstant() {
+		return Method{}, fmt.Errorf("method %s is not a view function", m.Name)
+	}
+	if len(m.Outputs) != 1 {
+		return Method{}, fmt.Errorf("method %s returns %d values, expected 1", m.Name, len(m.Outputs))
+	}
+	output := m.Outputs[0].Type
+	if output.T == abi.ArrayTy || output.T == abi.SliceTy || output.T == abi.TupleTy {
+		return Method{}, fmt.Errorf("method %s returns unsupported type %s", m.Name, output.String())
+	}
+
+	args := make([]Field, len(m.Inputs))
+	for i, input := range m.Inputs {
+		if !IsEmittedType(input.Type) {
+			return Method{}, fmt.Errorf("method %s takes unsupported argument type %s", m.Name, input.Type.String())
+		}
+		args[i] = Field{}
+		args[i].Name = input.Name
+		if args[i].Name == "" {
+			args[i].Name = fmt.Sprintf("arg%d", i)
+		}
+		args[i].Type = input.Type
+		args[i].PgType = pgType(input.Type)
+	}
+	ret := Field{}
+	ret.Name = "returned"
+	ret.Type = output
+	ret.PgType = pgType(output)
+
+	return Method{
+		Name:   m.Name,
+		Args:   args,
+		Return: ret,
+	}, nil
+}
+
+// IsEmittedType returns true for the abi types whose values emitted by events are collected as method arguments
+func IsEmittedType(t abi.Type) bool {
+	return t.T == abi.AddressTy || (t.T == abi.FixedBytesTy && t.Size == 32)
+}
@@ -0,0 +1,159 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// FieldValues resolves a field's decoded value into the string values of its columns, keyed by column name
+// Scalars are resolved with ValueToString, typed arrays are JSON arrays of those strings, and JSONB columns hold JSON
+// with arrays as arrays and tuples as objects keyed by element name
+func FieldValues(field Field, value interface{}) (map[string]string, error) {
+	values := map[string]string{}
+	// Indexed values of dynamic types are only available as the keccak256 hash of their encoding
+	if field.Indexed && isComposite(field.Type) {
+		str, err := ValueToString(value)
+		if err != nil {
+			return nil, err
+		}
+		values[field.Name] = str
+		return values, nil
+	}
+	err := columnValues(field.Name, field.Type, reflect.ValueOf(value), values)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving value of %s: %w", field.Name, err)
+	}
+	return values, nil
+}
+
+func columnValues(name string, t abi.Type, v reflect.Value, values map[string]string) error {
+	if t.T == abi.TupleTy {
+		for i, elem := range t.TupleElems {
+			err := columnValues(name+"_"+t.TupleRawNames[i], *elem, v.Field(i), values)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var str string
+	var err error
+	switch t.T {
+	case abi.ArrayTy, abi.SliceTy:
+		str, err = arrayValue(t, v)
+	default:
+		str, err = ValueToString(v.Interface())
+	}
+	values[name] = str
+	return err
+}
+
+func arrayValue(t abi.Type, v reflect.Value) (string, error) {
+	var decoded interface{}
+	if isComposite(*t.Elem) {
+		jsonValue, err := toJSONValue(t, v)
+		if err != nil {
+			return "", err
+		}
+		decoded = jsonValue
+	} else {
+		elems := make([]string, v.Len())
+		for i := range elems {
+			elem, err := ValueToString(v.Index(i).Interface())
+			if err != nil {
+				return "", err
+			}
+			elems[i] = elem
+		}
+		decoded = elems
+	}
+	encoded, err := json.Marshal(decoded)
+	return string(encoded), err
+}
+
+func toJSONValue(t abi.Type, v reflect.Value) (interface{}, error) {
+	switch t.T {
+	case abi.ArrayTy, abi.SliceTy:
+		elems := make([]interface{}, v.Len())
+		for i := range elems {
+			elem, err := toJSONValue(*t.Elem, v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			elems[i] = elem
+		}
+		return elems, nil
+	case abi.TupleTy:
+		elems := make(map[string]interface{}, len(t.TupleElems))
+		for i, elemType := range t.TupleElems {
+			elem, err := toJSONValue(*elemType, v.Field(i))
+			if err != nil {
+				return nil, err
+			}
+			elems[t.TupleRawNames[i]] = elem
+		}
+		return elems, nil
+	case abi.BoolTy:
+		return v.Bool(), nil
+	default:
+		// Numbers are kept as strings so that they don't lose precision
+		return ValueToString(v.Interface())
+	}
+}
+
+// ValueToString resolves a value unpacked from the abi into a string that postgres can store
+func ValueToString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case *big.Int:
+		return v.String(), nil
+	case common.Address:
+		return v.String(), nil
+	case common.Hash:
+		return v.String(), nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case []byte:
+		return hexutil.Encode(v), nil
+	}
+	reflected := reflect.ValueOf(value)
+	switch reflected.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(reflected.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(reflected.Uint(), 10), nil
+	case reflect.Array:
+		// Fixed size byte arrays (bytes1 to bytes32, function)
+		if reflected.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, reflected.Len())
+			reflect.Copy(reflect.ValueOf(b), reflected)
+			return hexutil.Encode(b), nil
+		}
+	}
+	return "", fmt.Errorf("error: unhandled abi type %T", value)
+}
@@ -44,3 +44,9 @@ type RpcTransaction struct {
 	From             string
 	TransactionIndex string `json:"transactionIndex"`
 }
+
+// RpcBlock is a block fetched over RPC with its full transactions
+type RpcBlock struct {
+	Hash         string           `json:"hash"`
+	Transactions []RpcTransaction `json:"transactions"`
+}
@@ -75,6 +75,34 @@ func (repo headerRepository) CreateTransactionInTx(tx *sqlx.Tx, headerID int64,
 	return txId, err
 }
 
+// CreateTransactionsWithReceipts upserts the transactions and their receipts in one db transaction
+func (repo headerRepository) CreateTransactionsWithReceipts(headerID int64, transactions []core.TransactionModel) error {
+	tx, beginErr := repo.db.Beginx()
+	if beginErr != nil {
+		return fmt.Errorf("error beginning db transaction: %w", beginErr)
+	}
+	for _, transaction := range transactions {
+		transactionID, transactionErr := repo.CreateTransactionInTx(tx, headerID, transaction)
+		if transactionErr != nil {
+			rollback(tx)
+			return fmt.Errorf("error creating transaction %s: %w", transaction.Hash, transactionErr)
+		}
+		_, receiptErr := ReceiptRepository{}.CreateReceiptInTx(headerID, transactionID, transaction.Receipt, tx)
+		if receiptErr != nil {
+			rollback(tx)
+			return fmt.Errorf("error creating receipt of transaction %s: %w", transaction.Hash, receiptErr)
+		}
+	}
+	return tx.Commit()
+}
+
+func rollback(tx *sqlx.Tx) {
+	rollbackErr := tx.Rollback()
+	if rollbackErr != nil {
+		logrus.Warnf("error rolling back transactions and receipts: %s", rollbackErr.Error())
+	}
+}
+
 func (repo headerRepository) GetHeaderByBlockNumber(blockNumber int64) (core.Header, error) {
 	var header core.Header
 	err := repo.db.Get(&header,
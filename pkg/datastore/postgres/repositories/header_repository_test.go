@@ -273,6 +273,40 @@ var _ = Describe("Block header repository", func() {
 		})
 	})
 
+	Describe("creating transactions with receipts", func() {
+		It("adds each transaction and its receipt", func() {
+			headerID, err := repo.CreateOrUpdateHeader(header)
+			Expect(err).NotTo(HaveOccurred())
+			txHash := common.HexToHash("0x9876")
+			transaction := core.TransactionModel{
+				Data:    []byte{},
+				From:    common.HexToAddress("0x1234").Hex(),
+				Hash:    txHash.Hex(),
+				Raw:     []byte{},
+				Receipt: core.Receipt{GasUsed: 21000, Status: 0, TxHash: txHash.Hex(), Rlp: []byte{1}},
+				To:      common.HexToAddress("0x5678").Hex(),
+				TxIndex: 1,
+				Value:   "0",
+			}
+
+			insertErr := repo.CreateTransactionsWithReceipts(headerID, []core.TransactionModel{transaction})
+
+			Expect(insertErr).NotTo(HaveOccurred())
+			var receipt struct {
+				Hash    string
+				GasUsed uint64 `db:"gas_used"`
+				Status  int
+			}
+			readErr := db.Get(&receipt, `SELECT transactions.hash, receipts.gas_used, receipts.status
+				FROM public.receipts JOIN public.transactions ON transactions.id = receipts.transaction_id
+				WHERE receipts.header_id = $1`, headerID)
+			Expect(readErr).NotTo(HaveOccurred())
+			Expect(receipt.Hash).To(Equal(txHash.Hex()))
+			Expect(receipt.GasUsed).To(Equal(uint64(21000)))
+			Expect(receipt.Status).To(Equal(0))
+		})
+	})
+
 	Describe("Getting a header by block number", func() {
 		It("returns header if it exists", func() {
 			_, createErr := repo.CreateOrUpdateHeader(header)
@@ -18,6 +18,7 @@ package eth
 
 import (
 	"errors"
+	"fmt"
 	"math/big"
 	"strconv"
 
@@ -30,7 +31,11 @@ import (
 	"golang.org/x/net/context"
 )
 
-var ErrEmptyHeader = errors.New("empty header returned over RPC")
+var (
+	ErrEmptyHeader     = errors.New("empty header returned over RPC")
+	ErrBlockNotFound   = errors.New("block not found over RPC")
+	ErrReceiptNotFound = errors.New("transaction receipt not found over RPC")
+)
 
 const MAX_BATCH_SIZE = 100
 
@@ -96,6 +101,52 @@ func (blockChain *BlockChain) GetTransactions(transactionHashes []common.Hash) (
 	return blockChain.transactionConverter.ConvertRpcTransactionsToModels(transactions)
 }
 
+// GetBlockTransactions returns every transaction in the block with the given hash
+func (blockChain *BlockChain) GetBlockTransactions(blockHash common.Hash) ([]core.TransactionModel, error) {
+	var block core.RpcBlock
+	rpcErr := blockChain.rpcClient.CallContext(context.Background(), &block, "eth_getBlockByHash", blockHash, true)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	if block.Hash == "" {
+		return nil, fmt.Errorf("%w: %s", ErrBlockNotFound, blockHash.Hex())
+	}
+	return blockChain.transactionConverter.ConvertRpcTransactionsToModels(block.Transactions)
+}
+
+// GetTransactionReceipts returns the receipt of each transaction, in the order of their hashes
+func (blockChain *BlockChain) GetTransactionReceipts(transactionHashes []common.Hash) ([]core.Receipt, error) {
+	var batch []core.BatchElem
+	gethReceipts := make([]*types.Receipt, len(transactionHashes))
+
+	for index, transactionHash := range transactionHashes {
+		batchElem := core.BatchElem{
+			Method: "eth_getTransactionReceipt",
+			Result: &gethReceipts[index],
+			Args:   []interface{}{transactionHash},
+		}
+		batch = append(batch, batchElem)
+	}
+
+	rpcErr := blockChain.rpcClient.BatchCall(batch)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	receipts := make([]core.Receipt, len(transactionHashes))
+	for index, gethReceipt := range gethReceipts {
+		if gethReceipt == nil {
+			return nil, fmt.Errorf("%w: %s", ErrReceiptNotFound, transactionHashes[index].Hex())
+		}
+		receipt, convertErr := converters.ToCoreReceipt(gethReceipt)
+		if convertErr != nil {
+			return nil, convertErr
+		}
+		receipts[index] = receipt
+	}
+	return receipts, nil
+}
+
 func (blockChain *BlockChain) LastBlock() (*big.Int, error) {
 	block, err := blockChain.ethClient.HeaderByNumber(context.Background(), nil)
 	if err != nil {
@@ -131,6 +182,17 @@ func (blockChain *BlockChain) BatchGetStorageAt(account common.Address, keys []c
 	return result, nil
 }
 
+func (blockChain *BlockChain) GetProof(account common.Address, keys []common.Hash, blockNumber *big.Int) (core.AccountProof, error) {
+	hexKeys := make([]string, len(keys))
+	for index, key := range keys {
+		hexKeys[index] = key.Hex()
+	}
+	var proof core.AccountProof
+	err := blockChain.rpcClient.CallContext(context.Background(), &proof, "eth_getProof", account.Hex(), hexKeys,
+		hexutil.EncodeBig(blockNumber))
+	return proof, err
+}
+
 func (blockChain *BlockChain) Node() core.Node {
 	return blockChain.node
 }
@@ -181,6 +181,44 @@ var _ = Describe("Geth blockchain", func() {
 		})
 	})
 
+	Describe("getting a block's transactions", func() {
+		It("fetches the block by hash with its full transactions", func() {
+			mockRpcClient.BlockToReturn = core.RpcBlock{Hash: fakes.FakeHash.Hex(), Transactions: []core.RpcTransaction{{}}}
+
+			_, err := blockChain.GetBlockTransactions(fakes.FakeHash)
+
+			Expect(err).NotTo(HaveOccurred())
+			mockRpcClient.AssertCallContextCalledWith(context.Background(), &core.RpcBlock{}, "eth_getBlockByHash")
+			Expect(mockTransactionConverter.ConvertRpcTransactionsToModelsCalled).To(BeTrue())
+		})
+
+		It("returns an error if the block isn't found", func() {
+			_, err := blockChain.GetBlockTransactions(fakes.FakeHash)
+
+			Expect(err).To(MatchError(eth.ErrBlockNotFound))
+		})
+	})
+
+	Describe("getting transaction receipts", func() {
+		It("fetches the receipt of each transaction", func() {
+			mockRpcClient.ReceiptToReturn = &types.Receipt{Status: types.ReceiptStatusFailed, GasUsed: 21000}
+
+			receipts, err := blockChain.GetTransactionReceipts([]common.Hash{{}, {}})
+
+			Expect(err).NotTo(HaveOccurred())
+			mockRpcClient.AssertBatchCalledWith("eth_getTransactionReceipt", 2)
+			Expect(len(receipts)).To(Equal(2))
+			Expect(receipts[0].Status).To(Equal(0))
+			Expect(receipts[0].GasUsed).To(Equal(uint64(21000)))
+		})
+
+		It("returns an error if a receipt isn't found", func() {
+			_, err := blockChain.GetTransactionReceipts([]common.Hash{{}})
+
+			Expect(err).To(MatchError(eth.ErrReceiptNotFound))
+		})
+	})
+
 	Describe("getting the most recent block number", func() {
 		It("fetches latest header from ethClient", func() {
 			blockNumber := int64(100)
@@ -218,4 +256,13 @@ var _ = Describe("Geth blockchain", func() {
 			Expect(result).To(Equal(map[common.Hash][]byte{fakeKey: fakeStorageValue}))
 		})
 	})
+
+	Describe("getting a storage proof at a given block", func() {
+		It("fetches the proof with eth_getProof", func() {
+			_, err := blockChain.GetProof(fakes.FakeAddress, []common.Hash{test_data.FakeHash()}, big.NewInt(rand.Int63()))
+
+			Expect(err).NotTo(HaveOccurred())
+			mockRpcClient.AssertCallContextCalledWith(context.Background(), &core.AccountProof{}, "eth_getProof")
+		})
+	})
 })
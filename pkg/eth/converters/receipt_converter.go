@@ -0,0 +1,85 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package converters
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/makerdao/vulcanizedb/pkg/core"
+)
+
+// ToCoreReceipt converts a receipt fetched from the node into our receipt type
+// Pre-Byzantium receipts carry a post-transaction state root instead of a status, and get a status of -99
+func ToCoreReceipt(gethReceipt *types.Receipt) (core.Receipt, error) {
+	receiptRlp, rlpErr := rlp.EncodeToBytes(gethReceipt)
+	if rlpErr != nil {
+		return core.Receipt{}, fmt.Errorf("error encoding receipt of transaction %s: %w", gethReceipt.TxHash.Hex(),
+			rlpErr)
+	}
+	stateRoot, status := postStateOrStatus(gethReceipt)
+	return core.Receipt{
+		Bloom:             hexutil.Encode(gethReceipt.Bloom.Bytes()),
+		ContractAddress:   contractAddress(gethReceipt),
+		CumulativeGasUsed: gethReceipt.CumulativeGasUsed,
+		GasUsed:           gethReceipt.GasUsed,
+		Logs:              toCoreReceiptLogs(gethReceipt.Logs),
+		StateRoot:         stateRoot,
+		Status:            status,
+		TxHash:            gethReceipt.TxHash.Hex(),
+		Rlp:               receiptRlp,
+	}, nil
+}
+
+func postStateOrStatus(gethReceipt *types.Receipt) (string, int) {
+	if len(gethReceipt.PostState) != 0 {
+		return hexutil.Encode(gethReceipt.PostState), -99
+	}
+	return "", int(gethReceipt.Status)
+}
+
+// contractAddress is empty unless the transaction created a contract
+func contractAddress(gethReceipt *types.Receipt) string {
+	if gethReceipt.ContractAddress == (common.Address{}) {
+		return ""
+	}
+	return gethReceipt.ContractAddress.Hex()
+}
+
+func toCoreReceiptLogs(gethLogs []*types.Log) []core.ReceiptLog {
+	logs := make([]core.ReceiptLog, 0, len(gethLogs))
+	for _, gethLog := range gethLogs {
+		var topics core.Topics
+		for index, topic := range gethLog.Topics {
+			if index < len(topics) {
+				topics[index] = topic.Hex()
+			}
+		}
+		logs = append(logs, core.ReceiptLog{
+			BlockNumber: int64(gethLog.BlockNumber),
+			TxHash:      gethLog.TxHash.Hex(),
+			Address:     gethLog.Address.Hex(),
+			Topics:      topics,
+			Index:       int64(gethLog.Index),
+			Data:        hexutil.Encode(gethLog.Data),
+		})
+	}
+	return logs
+}
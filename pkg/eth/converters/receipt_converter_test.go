@@ -0,0 +1,77 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package converters_test
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/makerdao/vulcanizedb/pkg/eth/converters"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Receipt converter", func() {
+	var (
+		txHash  = common.HexToHash("0x1")
+		address = common.HexToAddress("0x2")
+	)
+
+	It("converts a receipt's fields", func() {
+		receipt := &types.Receipt{
+			Status:            types.ReceiptStatusFailed,
+			CumulativeGasUsed: 50000,
+			GasUsed:           21000,
+			TxHash:            txHash,
+			Logs: []*types.Log{{
+				Address:     address,
+				Topics:      []common.Hash{txHash},
+				Data:        []byte{1},
+				BlockNumber: 3,
+				TxHash:      txHash,
+				Index:       4,
+			}},
+		}
+
+		converted, err := converters.ToCoreReceipt(receipt)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(converted.Status).To(Equal(0))
+		Expect(converted.StateRoot).To(BeEmpty())
+		Expect(converted.CumulativeGasUsed).To(Equal(uint64(50000)))
+		Expect(converted.GasUsed).To(Equal(uint64(21000)))
+		Expect(converted.TxHash).To(Equal(txHash.Hex()))
+		Expect(converted.ContractAddress).To(BeEmpty())
+		Expect(converted.Rlp).NotTo(BeEmpty())
+		Expect(len(converted.Logs)).To(Equal(1))
+		Expect(converted.Logs[0].Address).To(Equal(address.Hex()))
+		Expect(converted.Logs[0].Topics[0]).To(Equal(txHash.Hex()))
+		Expect(converted.Logs[0].Data).To(Equal(hexutil.Encode([]byte{1})))
+		Expect(converted.Logs[0].Index).To(Equal(int64(4)))
+	})
+
+	It("keeps the state root of pre-Byzantium receipts in place of a status", func() {
+		receipt := &types.Receipt{PostState: txHash.Bytes(), ContractAddress: address}
+
+		converted, err := converters.ToCoreReceipt(receipt)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(converted.StateRoot).To(Equal(txHash.Hex()))
+		Expect(converted.Status).To(Equal(-99))
+		Expect(converted.ContractAddress).To(Equal(address.Hex()))
+	})
+})
@@ -1,8 +1,10 @@
 package fakes
 
 type MockBlockRetriever struct {
-	FirstBlock    int64
-	FirstBlockErr error
+	FirstBlock         int64
+	FirstBlockErr      error
+	MostRecentBlock    int64
+	MostRecentBlockErr error
 }
 
 func (retriever *MockBlockRetriever) RetrieveFirstBlock() (int64, error) {
@@ -10,5 +12,5 @@ func (retriever *MockBlockRetriever) RetrieveFirstBlock() (int64, error) {
 }
 
 func (retriever *MockBlockRetriever) RetrieveMostRecentBlock() (int64, error) {
-	return 0, nil
+	return retriever.MostRecentBlock, retriever.MostRecentBlockErr
 }
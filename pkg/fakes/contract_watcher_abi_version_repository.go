@@ -0,0 +1,40 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fakes
+
+type MockAbiVersionRepository struct {
+	RecordAbiErr    error
+	RecordedAbis    map[string][]string
+	RecordAddresses []string
+}
+
+// RecordAbi records the abi as the contract's next version if it differs from the last one recorded
+func (repository *MockAbiVersionRepository) RecordAbi(contractAddr, abi string) (int64, bool, error) {
+	if repository.RecordAbiErr != nil {
+		return 0, false, repository.RecordAbiErr
+	}
+	if repository.RecordedAbis == nil {
+		repository.RecordedAbis = map[string][]string{}
+	}
+	repository.RecordAddresses = append(repository.RecordAddresses, contractAddr)
+	versions := repository.RecordedAbis[contractAddr]
+	if len(versions) > 0 && versions[len(versions)-1] == abi {
+		return int64(len(versions)), false, nil
+	}
+	repository.RecordedAbis[contractAddr] = append(versions, abi)
+	return int64(len(versions) + 1), true, nil
+}
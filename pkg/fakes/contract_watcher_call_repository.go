@@ -0,0 +1,50 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fakes
+
+import "github.com/makerdao/vulcanizedb/pkg/contract_watcher/types"
+
+type MockCallRepository struct {
+	TransactionsToReturn map[int64][]types.Transaction
+	GetTransactionsErr   error
+	QueriedAddresses     []string
+	CreatedTables        []string
+	CreateCallTableErr   error
+	PersistedResults     [][]types.CallResult
+	PersistedCalls       []types.Call
+	PersistErr           error
+}
+
+func (repository *MockCallRepository) GetTransactions(contractAddr string, headerID int64) ([]types.Transaction, error) {
+	repository.QueriedAddresses = append(repository.QueriedAddresses, contractAddr)
+	return repository.TransactionsToReturn[headerID], repository.GetTransactionsErr
+}
+
+func (repository *MockCallRepository) PersistCalls(results []types.CallResult, call types.Call, contractAddr string) error {
+	repository.PersistedResults = append(repository.PersistedResults, results)
+	repository.PersistedCalls = append(repository.PersistedCalls, call)
+	return repository.PersistErr
+}
+
+func (repository *MockCallRepository) CreateCallTable(contractAddr string, call types.Call) (bool, error) {
+	repository.CreatedTables = append(repository.CreatedTables, call.Name)
+	return repository.CreateCallTableErr == nil, repository.CreateCallTableErr
+}
+
+func (*MockCallRepository) CheckTableCache(key string) (interface{}, bool) {
+	return nil, false
+}
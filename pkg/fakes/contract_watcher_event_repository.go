@@ -0,0 +1,92 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fakes
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/fetcher"
+	cwTypes "github.com/makerdao/vulcanizedb/pkg/contract_watcher/types"
+	"github.com/makerdao/vulcanizedb/pkg/core"
+)
+
+type MockContractWatcherEventRepository struct {
+	CreateEventTableErr error
+	CreatedTables       []string
+	EventBlockNumbers   map[string][]int64
+	PersistedLogs       map[string][]cwTypes.Log
+	PersistedEvents     map[string]cwTypes.Event
+}
+
+func (repository *MockContractWatcherEventRepository) PersistLogs(logs []cwTypes.Log, eventInfo cwTypes.Event, contractAddr string) error {
+	if repository.PersistedLogs == nil {
+		repository.PersistedLogs = map[string][]cwTypes.Log{}
+		repository.PersistedEvents = map[string]cwTypes.Event{}
+	}
+	repository.PersistedLogs[eventInfo.Name] = append(repository.PersistedLogs[eventInfo.Name], logs...)
+	repository.PersistedEvents[eventInfo.Name] = eventInfo
+	return nil
+}
+
+func (repository *MockContractWatcherEventRepository) CreateEventTable(contractAddr string, event cwTypes.Event) (bool, error) {
+	if repository.CreateEventTableErr != nil {
+		return false, repository.CreateEventTableErr
+	}
+	repository.CreatedTables = append(repository.CreatedTables, event.Name)
+	return true, nil
+}
+
+func (*MockContractWatcherEventRepository) CreateContractSchema(contractName string) (bool, error) {
+	return true, nil
+}
+
+func (*MockContractWatcherEventRepository) CheckSchemaCache(key string) (interface{}, bool) {
+	return nil, false
+}
+
+func (*MockContractWatcherEventRepository) CheckTableCache(key string) (interface{}, bool) {
+	return nil, false
+}
+
+func (repository *MockContractWatcherEventRepository) GetEventBlockNumbers(contractAddr, eventName string) ([]int64, error) {
+	return repository.EventBlockNumbers[eventName], nil
+}
+
+type MockLogFetcher struct {
+	LogsToReturn         [][]types.Log
+	FetchedAddresses     [][]string
+	FetchedTopics        [][]common.Hash
+	FilteredLogsToReturn []types.Log
+	FetchedFilters       [][]fetcher.TopicFilter
+}
+
+// FetchLogs returns the next of LogsToReturn, or no logs once they are exhausted
+func (fetcher *MockLogFetcher) FetchLogs(contractAddresses []string, topics []common.Hash, missingHeader core.Header) ([]types.Log, error) {
+	fetcher.FetchedAddresses = append(fetcher.FetchedAddresses, contractAddresses)
+	fetcher.FetchedTopics = append(fetcher.FetchedTopics, topics)
+	if len(fetcher.LogsToReturn) == 0 {
+		return nil, nil
+	}
+	logs := fetcher.LogsToReturn[0]
+	fetcher.LogsToReturn = fetcher.LogsToReturn[1:]
+	return logs, nil
+}
+
+func (fetcher *MockLogFetcher) FetchFilteredLogs(filters []fetcher.TopicFilter, missingHeader core.Header) ([]types.Log, error) {
+	fetcher.FetchedFilters = append(fetcher.FetchedFilters, filters)
+	return fetcher.FilteredLogsToReturn, nil
+}
@@ -1,11 +1,22 @@
 package fakes
 
-import "github.com/makerdao/vulcanizedb/pkg/core"
+import (
+	cwRepository "github.com/makerdao/vulcanizedb/pkg/contract_watcher/repository"
+	"github.com/makerdao/vulcanizedb/pkg/core"
+)
 
 type MockContractWatcherHeaderRepository struct {
+	AddedCheckColumns      []string
+	MissingHeadersToReturn []core.Header
+	MissingHeadersIDs      []string
+	MissingHeadersRanges   [][]cwRepository.CheckRange
+	HeadersNotSynced       bool
+	MarkedHeaderIDs        []int64
+	MarkedCheckedIDs       [][]string
 }
 
-func (*MockContractWatcherHeaderRepository) AddCheckColumn(id string) error {
+func (repository *MockContractWatcherHeaderRepository) AddCheckColumn(id string) error {
+	repository.AddedCheckColumns = append(repository.AddedCheckColumns, id)
 	return nil
 }
 
@@ -17,8 +28,10 @@ func (*MockContractWatcherHeaderRepository) MarkHeaderChecked(headerID int64, ev
 	panic("implement me")
 }
 
-func (*MockContractWatcherHeaderRepository) MarkHeaderCheckedForAll(headerID int64, ids []string) error {
-	panic("implement me")
+func (repository *MockContractWatcherHeaderRepository) MarkHeaderCheckedForAll(headerID int64, ids []string) error {
+	repository.MarkedHeaderIDs = append(repository.MarkedHeaderIDs, headerID)
+	repository.MarkedCheckedIDs = append(repository.MarkedCheckedIDs, ids)
+	return nil
 }
 
 func (*MockContractWatcherHeaderRepository) MarkHeadersCheckedForAll(headers []core.Header, ids []string) error {
@@ -29,8 +42,28 @@ func (*MockContractWatcherHeaderRepository) MissingHeaders(startingBlockNumber i
 	panic("implement me")
 }
 
-func (*MockContractWatcherHeaderRepository) MissingHeadersForAll(startingBlockNumber, endingBlockNumber int64, ids []string) ([]core.Header, error) {
-	panic("implement me")
+func (repository *MockContractWatcherHeaderRepository) MissingHeadersForAll(startingBlockNumber, endingBlockNumber int64, ids []string) ([]core.Header, error) {
+	repository.MissingHeadersIDs = ids
+	var headers []core.Header
+	for _, header := range repository.MissingHeadersToReturn {
+		if header.BlockNumber >= startingBlockNumber && (endingBlockNumber == -1 || header.BlockNumber <= endingBlockNumber) {
+			headers = append(headers, header)
+		}
+	}
+	return headers, nil
+}
+
+func (repository *MockContractWatcherHeaderRepository) MissingHeadersForRanges(ranges []cwRepository.CheckRange) ([][]core.Header, error) {
+	repository.MissingHeadersRanges = append(repository.MissingHeadersRanges, ranges)
+	missingHeaders := make([][]core.Header, len(ranges))
+	for i, checkRange := range ranges {
+		missingHeaders[i], _ = repository.MissingHeadersForAll(checkRange.StartingBlock, checkRange.EndingBlock, checkRange.IDs)
+	}
+	return missingHeaders, nil
+}
+
+func (repository *MockContractWatcherHeaderRepository) HeadersSynced(ranges []cwRepository.CheckRange) (bool, error) {
+	return !repository.HeadersNotSynced, nil
 }
 
 func (*MockContractWatcherHeaderRepository) CheckCache(key string) (interface{}, bool) {
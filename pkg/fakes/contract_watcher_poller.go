@@ -0,0 +1,56 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fakes
+
+import (
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/contract"
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/types"
+	"github.com/makerdao/vulcanizedb/pkg/core"
+)
+
+type MockPoller struct {
+	PolledBlockNumbers []int64
+	PolledContracts    []string
+	ResultsToReturn    map[string][]types.MethodResult
+	PollErr            error
+}
+
+func (poller *MockPoller) PollMethods(con *contract.Contract, header core.Header) (map[string][]types.MethodResult, error) {
+	poller.PolledBlockNumbers = append(poller.PolledBlockNumbers, header.BlockNumber)
+	poller.PolledContracts = append(poller.PolledContracts, con.Address)
+	return poller.ResultsToReturn, poller.PollErr
+}
+
+type MockMethodRepository struct {
+	PersistedResults [][]types.MethodResult
+	PersistedMethods []types.Method
+	PersistErr       error
+}
+
+func (repository *MockMethodRepository) PersistResults(results []types.MethodResult, methodInfo types.Method, contractAddr string) error {
+	repository.PersistedResults = append(repository.PersistedResults, results)
+	repository.PersistedMethods = append(repository.PersistedMethods, methodInfo)
+	return repository.PersistErr
+}
+
+func (*MockMethodRepository) CreateMethodTable(contractAddr string, method types.Method) (bool, error) {
+	return true, nil
+}
+
+func (*MockMethodRepository) CheckTableCache(key string) (interface{}, bool) {
+	return nil, false
+}
@@ -0,0 +1,50 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fakes
+
+import (
+	"github.com/makerdao/vulcanizedb/pkg/contract_watcher/registry"
+)
+
+type MockAbiRegistry struct {
+	Abis         map[string]string
+	GetAbiErr    error
+	GetAddresses []string
+	PutAbiErr    error
+	PutAddresses []string
+	PutAbis      []string
+	PutSources   []string
+}
+
+func (r *MockAbiRegistry) GetAbi(contractAddr string) (string, error) {
+	r.GetAddresses = append(r.GetAddresses, contractAddr)
+	if r.GetAbiErr != nil {
+		return "", r.GetAbiErr
+	}
+	abi, ok := r.Abis[contractAddr]
+	if !ok {
+		return "", registry.ErrAbiNotFound
+	}
+	return abi, nil
+}
+
+func (r *MockAbiRegistry) PutAbi(contractAddr, abi, source string) error {
+	r.PutAddresses = append(r.PutAddresses, contractAddr)
+	r.PutAbis = append(r.PutAbis, abi)
+	r.PutSources = append(r.PutSources, source)
+	return r.PutAbiErr
+}
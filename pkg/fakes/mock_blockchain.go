@@ -18,6 +18,7 @@ package fakes
 
 import (
 	"math/big"
+	"sync"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
@@ -29,6 +30,16 @@ import (
 type MockBlockChain struct {
 	BatchGetStorageAtCalls             []BatchGetStorageAtCall
 	BatchGetStorageAtError             error
+	FetchContractDataPassedArgs        [][]interface{}
+	FetchContractDataResult            interface{}
+	BlockTransactions                  []core.TransactionModel
+	GetBlockTransactionsError          error
+	GetBlockTransactionsPassedHashes   []common.Hash
+	Receipts                           map[common.Hash]core.Receipt
+	GetTransactionReceiptsError        error
+	GetTransactionReceiptsPassedHashes [][]common.Hash
+	GetProofCalls                      []BatchGetStorageAtCall
+	GetProofError                      error
 	GetTransactionsCalled              bool
 	GetTransactionsError               error
 	GetTransactionsPassedHashes        []common.Hash
@@ -40,22 +51,42 @@ type MockBlockChain struct {
 	fetchContractDataPassedMethod      string
 	fetchContractDataPassedMethodArgs  []interface{}
 	fetchContractDataPassedResult      interface{}
+	headerHashes                       map[int64]string
+	headerHashesMutex                  sync.Mutex
 	lastBlock                          *big.Int
 	lastBlockErr                       error
 	logQuery                           ethereum.FilterQuery
 	logQueryErr                        error
 	logQueryReturnLogs                 []types.Log
 	node                               core.Node
+	proofsToReturn                     map[common.Address]map[int64]core.AccountProof
 	storageValuesToReturn              map[common.Address]map[int64][]byte
+	storageKeyValuesToReturn           map[common.Address]map[common.Hash][]byte
+	storageMutex                       sync.Mutex
 }
 
 func NewMockBlockChain() *MockBlockChain {
 	return &MockBlockChain{
-		node:                  core.Node{GenesisBlock: "GENESIS", NetworkID: 1, ID: "x123", ClientName: "Geth"},
-		storageValuesToReturn: make(map[common.Address]map[int64][]byte),
+		node:                     core.Node{GenesisBlock: "GENESIS", NetworkID: 1, ID: "x123", ClientName: "Geth"},
+		storageValuesToReturn:    make(map[common.Address]map[int64][]byte),
+		storageKeyValuesToReturn: make(map[common.Address]map[common.Hash][]byte),
+		headerHashes:             make(map[int64]string),
+		proofsToReturn:           make(map[common.Address]map[int64]core.AccountProof),
 	}
 }
 
+func (blockChain *MockBlockChain) SetHeaderHash(blockNumber int64, hash string) {
+	blockChain.headerHashesMutex.Lock()
+	defer blockChain.headerHashesMutex.Unlock()
+	blockChain.headerHashes[blockNumber] = hash
+}
+
+func (blockChain *MockBlockChain) getHeaderHash(blockNumber int64) string {
+	blockChain.headerHashesMutex.Lock()
+	defer blockChain.headerHashesMutex.Unlock()
+	return blockChain.headerHashes[blockNumber]
+}
+
 func (blockChain *MockBlockChain) SetFetchContractDataErr(err error) {
 	blockChain.fetchContractDataErr = err
 }
@@ -83,6 +114,10 @@ func (blockChain *MockBlockChain) FetchContractData(abiJSON string, address stri
 	blockChain.fetchContractDataPassedMethodArgs = methodArgs
 	blockChain.fetchContractDataPassedResult = result
 	blockChain.fetchContractDataPassedBlockNumber = blockNumber
+	blockChain.FetchContractDataPassedArgs = append(blockChain.FetchContractDataPassedArgs, methodArgs)
+	if output, ok := result.(*interface{}); ok && blockChain.fetchContractDataErr == nil {
+		*output = blockChain.FetchContractDataResult
+	}
 	return blockChain.fetchContractDataErr
 }
 
@@ -92,13 +127,13 @@ func (blockChain *MockBlockChain) GetEthLogsWithCustomQuery(query ethereum.Filte
 }
 
 func (blockChain *MockBlockChain) GetHeaderByNumber(blockNumber int64) (core.Header, error) {
-	return core.Header{BlockNumber: blockNumber}, nil
+	return core.Header{BlockNumber: blockNumber, Hash: blockChain.getHeaderHash(blockNumber)}, nil
 }
 
 func (blockChain *MockBlockChain) GetHeadersByNumbers(blockNumbers []int64) ([]core.Header, error) {
 	var headers []core.Header
 	for _, blockNumber := range blockNumbers {
-		var header = core.Header{BlockNumber: blockNumber}
+		var header = core.Header{BlockNumber: blockNumber, Hash: blockChain.getHeaderHash(blockNumber)}
 		headers = append(headers, header)
 	}
 	return headers, nil
@@ -110,6 +145,22 @@ func (blockChain *MockBlockChain) GetTransactions(transactionHashes []common.Has
 	return blockChain.Transactions, blockChain.GetTransactionsError
 }
 
+func (blockChain *MockBlockChain) GetBlockTransactions(blockHash common.Hash) ([]core.TransactionModel, error) {
+	blockChain.GetBlockTransactionsPassedHashes = append(blockChain.GetBlockTransactionsPassedHashes, blockHash)
+	return blockChain.BlockTransactions, blockChain.GetBlockTransactionsError
+}
+
+// GetTransactionReceipts returns an empty receipt for transactions without one in Receipts
+func (blockChain *MockBlockChain) GetTransactionReceipts(transactionHashes []common.Hash) ([]core.Receipt, error) {
+	blockChain.GetTransactionReceiptsPassedHashes = append(blockChain.GetTransactionReceiptsPassedHashes,
+		transactionHashes)
+	receipts := make([]core.Receipt, len(transactionHashes))
+	for index, transactionHash := range transactionHashes {
+		receipts[index] = blockChain.Receipts[transactionHash]
+	}
+	return receipts, blockChain.GetTransactionReceiptsError
+}
+
 func (blockChain *MockBlockChain) CallContract(contractHash string, input []byte, blockNumber *big.Int) ([]byte, error) {
 	return []byte{}, nil
 }
@@ -125,6 +176,8 @@ type BatchGetStorageAtCall struct {
 }
 
 func (blockChain *MockBlockChain) BatchGetStorageAt(account common.Address, keys []common.Hash, blockNumber *big.Int) (map[common.Hash][]byte, error) {
+	blockChain.storageMutex.Lock()
+	defer blockChain.storageMutex.Unlock()
 	var storageToReturn = make(map[common.Hash][]byte)
 	blockChain.BatchGetStorageAtCalls = append(blockChain.BatchGetStorageAtCalls, BatchGetStorageAtCall{
 		Account:     account,
@@ -132,6 +185,10 @@ func (blockChain *MockBlockChain) BatchGetStorageAt(account common.Address, keys
 		BlockNumber: blockNumber,
 	})
 	for _, key := range keys {
+		if value, ok := blockChain.storageKeyValuesToReturn[account][key]; ok {
+			storageToReturn[key] = value
+			continue
+		}
 		storageToReturn[key] = blockChain.storageValuesToReturn[account][blockNumber.Int64()]
 	}
 
@@ -146,6 +203,35 @@ func (blockChain *MockBlockChain) SetStorageValuesToReturn(blockNumber int64, ad
 	blockChain.storageValuesToReturn[address][blockNumber] = value
 }
 
+// SetStorageKeyValueToReturn sets the value returned for the key at any block, taking precedence over
+// SetStorageValuesToReturn
+func (blockChain *MockBlockChain) SetStorageKeyValueToReturn(address common.Address, key common.Hash, value []byte) {
+	_, ok := blockChain.storageKeyValuesToReturn[address]
+	if !ok {
+		blockChain.storageKeyValuesToReturn[address] = map[common.Hash][]byte{}
+	}
+	blockChain.storageKeyValuesToReturn[address][key] = value
+}
+
+func (blockChain *MockBlockChain) GetProof(account common.Address, keys []common.Hash, blockNumber *big.Int) (core.AccountProof, error) {
+	blockChain.storageMutex.Lock()
+	defer blockChain.storageMutex.Unlock()
+	blockChain.GetProofCalls = append(blockChain.GetProofCalls, BatchGetStorageAtCall{
+		Account:     account,
+		Keys:        keys,
+		BlockNumber: blockNumber,
+	})
+	return blockChain.proofsToReturn[account][blockNumber.Int64()], blockChain.GetProofError
+}
+
+func (blockChain *MockBlockChain) SetProofToReturn(blockNumber int64, address common.Address, proof core.AccountProof) {
+	_, ok := blockChain.proofsToReturn[address]
+	if !ok {
+		blockChain.proofsToReturn[address] = map[int64]core.AccountProof{}
+	}
+	blockChain.proofsToReturn[address][blockNumber] = proof
+}
+
 func (blockChain *MockBlockChain) Node() core.Node {
 	return blockChain.node
 }
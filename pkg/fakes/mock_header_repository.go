@@ -17,32 +17,38 @@
 package fakes
 
 import (
+	"sync"
+
 	"github.com/jmoiron/sqlx"
 	"github.com/makerdao/vulcanizedb/pkg/core"
 	. "github.com/onsi/gomega"
 )
 
 type MockHeaderRepository struct {
-	AllHeaders                             []core.Header
-	CreateTransactionsCalled               bool
-	CreateTransactionsError                error
-	GetHeaderByBlockNumberError            error
-	GetHeaderByBlockNumberReturnHash       string
-	GetHeaderByBlockNumberReturnID         int64
-	GetHeaderByIDError                     error
-	GetHeaderByIDHeaderToReturn            core.Header
-	GetHeaderPassedBlockNumber             int64
-	GetHeadersInRangeEndingBlocks          []int64
-	GetHeadersInRangeError                 error
-	GetHeadersInRangeStartingBlocks        []int64
-	MostRecentHeaderBlockNumber            int64
-	MostRecentHeaderBlockNumberErr         error
-	createOrUpdateHeaderCallCount          int
-	createOrUpdateHeaderErr                error
-	createOrUpdateHeaderPassedBlockNumbers []int64
-	createOrUpdateHeaderReturnID           int64
-	headerExists                           bool
-	missingBlockNumbers                    []int64
+	AllHeaders                                       []core.Header
+	CreateTransactionsCalled                         bool
+	CreateTransactionsError                          error
+	CreateTransactionsWithReceiptsError              error
+	CreateTransactionsWithReceiptsPassedTransactions []core.TransactionModel
+	GetHeaderByBlockNumberError                      error
+	GetHeaderByBlockNumberReturnHash                 string
+	GetHeaderByBlockNumberReturnID                   int64
+	GetHeaderByBlockNumberReturnRaw                  []byte
+	GetHeaderByIDError                               error
+	GetHeaderByIDHeaderToReturn                      core.Header
+	GetHeaderPassedBlockNumber                       int64
+	GetHeadersInRangeEndingBlocks                    []int64
+	GetHeadersInRangeError                           error
+	GetHeadersInRangeStartingBlocks                  []int64
+	MostRecentHeaderBlockNumber                      int64
+	MostRecentHeaderBlockNumberErr                   error
+	createOrUpdateHeaderCallCount                    int
+	createOrUpdateHeaderErr                          error
+	createOrUpdateHeaderPassedBlockNumbers           []int64
+	createOrUpdateHeaderReturnID                     int64
+	headerExists                                     bool
+	missingBlockNumbers                              []int64
+	getHeadersInRangeMutex                           sync.Mutex
 }
 
 func NewMockHeaderRepository() *MockHeaderRepository {
@@ -72,6 +78,12 @@ func (mock *MockHeaderRepository) CreateTransactions(headerID int64, transaction
 	return mock.CreateTransactionsError
 }
 
+func (mock *MockHeaderRepository) CreateTransactionsWithReceipts(headerID int64, transactions []core.TransactionModel) error {
+	mock.CreateTransactionsWithReceiptsPassedTransactions = append(
+		mock.CreateTransactionsWithReceiptsPassedTransactions, transactions...)
+	return mock.CreateTransactionsWithReceiptsError
+}
+
 func (mock *MockHeaderRepository) CreateTransactionInTx(tx *sqlx.Tx, headerID int64, transaction core.TransactionModel) (int64, error) {
 	panic("implement me")
 }
@@ -82,6 +94,7 @@ func (mock *MockHeaderRepository) GetHeaderByBlockNumber(blockNumber int64) (cor
 		Id:          mock.GetHeaderByBlockNumberReturnID,
 		BlockNumber: blockNumber,
 		Hash:        mock.GetHeaderByBlockNumberReturnHash,
+		Raw:         mock.GetHeaderByBlockNumberReturnRaw,
 	}, mock.GetHeaderByBlockNumberError
 }
 
@@ -90,6 +103,8 @@ func (mock *MockHeaderRepository) GetHeaderByID(id int64) (core.Header, error) {
 }
 
 func (mock *MockHeaderRepository) GetHeadersInRange(startingBlock, endingBlock int64) ([]core.Header, error) {
+	mock.getHeadersInRangeMutex.Lock()
+	defer mock.getHeadersInRangeMutex.Unlock()
 	mock.GetHeadersInRangeStartingBlocks = append(mock.GetHeadersInRangeStartingBlocks, startingBlock)
 	mock.GetHeadersInRangeEndingBlocks = append(mock.GetHeadersInRangeEndingBlocks, endingBlock)
 	return mock.AllHeaders, mock.GetHeadersInRangeError
@@ -0,0 +1,103 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fakes
+
+import (
+	"sync"
+
+	"github.com/makerdao/vulcanizedb/pkg/fs"
+)
+
+type MockLineTailer struct {
+	Lines            chan fs.Line
+	PassedCheckpoint fs.Checkpoint
+	TailFromCalled   bool
+	TailFromErr      error
+}
+
+func NewMockLineTailer() *MockLineTailer {
+	return &MockLineTailer{
+		Lines: make(chan fs.Line),
+	}
+}
+
+// TailFrom forwards lines sent to the mock until it's closed, then returns TailFromErr
+func (mock *MockLineTailer) TailFrom(checkpoint fs.Checkpoint, lines chan<- fs.Line) error {
+	mock.PassedCheckpoint = checkpoint
+	mock.TailFromCalled = true
+	if mock.TailFromErr != nil {
+		return mock.TailFromErr
+	}
+	for line := range mock.Lines {
+		lines <- line
+	}
+	return nil
+}
+
+type MockCheckpointStore struct {
+	CheckpointToLoad fs.Checkpoint
+	LoadErr          error
+	SaveErr          error
+	mutex            sync.Mutex
+	savedCheckpoints []fs.Checkpoint
+}
+
+func (store *MockCheckpointStore) Load() (fs.Checkpoint, error) {
+	return store.CheckpointToLoad, store.LoadErr
+}
+
+func (store *MockCheckpointStore) Save(checkpoint fs.Checkpoint) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.savedCheckpoints = append(store.savedCheckpoints, checkpoint)
+	return store.SaveErr
+}
+
+func (store *MockCheckpointStore) SavedCheckpoints() []fs.Checkpoint {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	return append([]fs.Checkpoint{}, store.savedCheckpoints...)
+}
+
+type QuarantinedLine struct {
+	LineNumber int64
+	Text       string
+	Reason     error
+}
+
+type MockQuarantine struct {
+	QuarantineErr    error
+	mutex            sync.Mutex
+	quarantinedLines []QuarantinedLine
+}
+
+func (quarantine *MockQuarantine) Quarantine(lineNumber int64, text string, reason error) error {
+	quarantine.mutex.Lock()
+	defer quarantine.mutex.Unlock()
+	quarantine.quarantinedLines = append(quarantine.quarantinedLines, QuarantinedLine{
+		LineNumber: lineNumber,
+		Text:       text,
+		Reason:     reason,
+	})
+	return quarantine.QuarantineErr
+}
+
+func (quarantine *MockQuarantine) QuarantinedLines() []QuarantinedLine {
+	quarantine.mutex.Lock()
+	defer quarantine.mutex.Unlock()
+	return append([]QuarantinedLine{}, quarantine.quarantinedLines...)
+}
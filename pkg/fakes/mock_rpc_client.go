@@ -52,6 +52,8 @@ type MockRpcClient struct {
 	returnPOAHeaders     []core.POAHeader
 	returnPOWHeaders     []*types.Header
 	StorageValueToReturn []byte
+	BlockToReturn        core.RpcBlock
+	ReceiptToReturn      *types.Receipt
 }
 
 func NewMockRpcClient() *MockRpcClient {
@@ -103,6 +105,9 @@ func (c *MockRpcClient) BatchCall(batch []core.BatchElem) error {
 		if p, ok := batchElem.Result.(*hexutil.Bytes); ok {
 			*p = c.StorageValueToReturn
 		}
+		if p, ok := batchElem.Result.(**types.Receipt); ok {
+			*p = c.ReceiptToReturn
+		}
 	}
 
 	return nil
@@ -124,6 +129,13 @@ func (c *MockRpcClient) CallContext(ctx context.Context, result interface{}, met
 		if c.callContextErr != nil {
 			return c.callContextErr
 		}
+	case "eth_getBlockByHash":
+		if p, ok := result.(*core.RpcBlock); ok {
+			*p = c.BlockToReturn
+		}
+		if c.callContextErr != nil {
+			return c.callContextErr
+		}
 	case "parity_versionInfo":
 		if p, ok := result.(*core.ParityNodeInfo); ok {
 			*p = c.ParityNodeInfo
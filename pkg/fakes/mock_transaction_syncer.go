@@ -16,14 +16,30 @@
 
 package fakes
 
-import "github.com/ethereum/go-ethereum/core/types"
+import (
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/makerdao/vulcanizedb/pkg/core"
+)
 
 type MockTransactionSyncer struct {
 	SyncTransactionsCalled bool
 	SyncTransactionsError  error
+	SyncedHeaderIDs        []int64
+	SyncedLogs             [][]types.Log
+	SyncedToHeaders        []core.Header
+	SyncedToAddresses      [][]string
+	SyncTransactionsToErr  error
 }
 
 func (syncer *MockTransactionSyncer) SyncTransactions(headerID int64, logs []types.Log) error {
 	syncer.SyncTransactionsCalled = true
+	syncer.SyncedHeaderIDs = append(syncer.SyncedHeaderIDs, headerID)
+	syncer.SyncedLogs = append(syncer.SyncedLogs, logs)
 	return syncer.SyncTransactionsError
 }
+
+func (syncer *MockTransactionSyncer) SyncTransactionsTo(header core.Header, addresses []string) error {
+	syncer.SyncedToHeaders = append(syncer.SyncedToHeaders, header)
+	syncer.SyncedToAddresses = append(syncer.SyncedToAddresses, addresses)
+	return syncer.SyncTransactionsToErr
+}
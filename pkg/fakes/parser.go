@@ -22,9 +22,15 @@ import (
 )
 
 type MockParser struct {
-	AbiToReturn string
-	EventName   string
-	Event       types.Event
+	AbiToReturn   string
+	EventName     string
+	Event         types.Event
+	Methods       map[string]types.Method
+	GetMethodsErr error
+	WantedMethods []string
+	Calls         map[string]types.Call
+	GetCallsErr   error
+	WantedCalls   []string
 }
 
 func (*MockParser) Parse(contractAddr, apiKey string) error {
@@ -47,3 +53,13 @@ func (*MockParser) ParsedAbi() abi.ABI {
 func (parser *MockParser) GetEvents(wanted []string) map[string]types.Event {
 	return map[string]types.Event{parser.EventName: parser.Event}
 }
+
+func (parser *MockParser) GetMethods(wanted []string) (map[string]types.Method, error) {
+	parser.WantedMethods = wanted
+	return parser.Methods, parser.GetMethodsErr
+}
+
+func (parser *MockParser) GetCalls(wanted []string) (map[string]types.Call, error) {
+	parser.WantedCalls = wanted
+	return parser.Calls, parser.GetCallsErr
+}
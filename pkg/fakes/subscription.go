@@ -1,7 +1,8 @@
 package fakes
 
 type MockSubscription struct {
-	Errs chan error
+	Errs              chan error
+	UnsubscribeCalled bool
 }
 
 func (m *MockSubscription) Err() <-chan error {
@@ -9,5 +10,5 @@ func (m *MockSubscription) Err() <-chan error {
 }
 
 func (m *MockSubscription) Unsubscribe() {
-	panic("implement me")
+	m.UnsubscribeCalled = true
 }
@@ -0,0 +1,64 @@
+package fs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// Checkpoint is a position in a tailed file: the file's inode, and the byte offset and number of the last line read
+type Checkpoint struct {
+	Inode      uint64 `json:"inode"`
+	Offset     int64  `json:"offset"`
+	LineNumber int64  `json:"lineNumber"`
+}
+
+type CheckpointStore interface {
+	Load() (Checkpoint, error)
+	Save(checkpoint Checkpoint) error
+}
+
+type fileCheckpointStore struct {
+	path string
+}
+
+// NewFileCheckpointStore persists checkpoints as JSON in the file at path
+func NewFileCheckpointStore(path string) fileCheckpointStore {
+	return fileCheckpointStore{path: path}
+}
+
+// Load returns the saved checkpoint, or an empty checkpoint if none has been saved
+func (store fileCheckpointStore) Load() (Checkpoint, error) {
+	var checkpoint Checkpoint
+	contents, readErr := ioutil.ReadFile(store.path)
+	if readErr != nil {
+		if os.IsNotExist(readErr) {
+			return checkpoint, nil
+		}
+		return checkpoint, fmt.Errorf("error reading checkpoint %s: %w", store.path, readErr)
+	}
+	unmarshalErr := json.Unmarshal(contents, &checkpoint)
+	if unmarshalErr != nil {
+		return checkpoint, fmt.Errorf("error parsing checkpoint %s: %w", store.path, unmarshalErr)
+	}
+	return checkpoint, nil
+}
+
+// Save writes the checkpoint to a temporary file and renames it into place, so a crash never leaves a partial file
+func (store fileCheckpointStore) Save(checkpoint Checkpoint) error {
+	contents, marshalErr := json.Marshal(checkpoint)
+	if marshalErr != nil {
+		return fmt.Errorf("error encoding checkpoint: %w", marshalErr)
+	}
+	tmpPath := store.path + ".tmp"
+	writeErr := ioutil.WriteFile(tmpPath, contents, 0644)
+	if writeErr != nil {
+		return fmt.Errorf("error writing checkpoint %s: %w", tmpPath, writeErr)
+	}
+	renameErr := os.Rename(tmpPath, store.path)
+	if renameErr != nil {
+		return fmt.Errorf("error replacing checkpoint %s: %w", store.path, renameErr)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package fs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/makerdao/vulcanizedb/pkg/fs"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("checkpoint store", func() {
+	var (
+		dir   string
+		path  string
+		store fs.CheckpointStore
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "checkpoint")
+		Expect(err).NotTo(HaveOccurred())
+		path = filepath.Join(dir, "diffs.checkpoint")
+		store = fs.NewFileCheckpointStore(path)
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("loads an empty checkpoint if none has been saved", func() {
+		checkpoint, err := store.Load()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(checkpoint).To(Equal(fs.Checkpoint{}))
+	})
+
+	It("loads the last saved checkpoint", func() {
+		Expect(store.Save(fs.Checkpoint{Inode: 1, Offset: 10, LineNumber: 1})).To(Succeed())
+		Expect(store.Save(fs.Checkpoint{Inode: 1, Offset: 20, LineNumber: 2})).To(Succeed())
+
+		checkpoint, err := store.Load()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(checkpoint).To(Equal(fs.Checkpoint{Inode: 1, Offset: 20, LineNumber: 2}))
+	})
+
+	It("returns an error if the checkpoint file is corrupt", func() {
+		Expect(ioutil.WriteFile(path, []byte("{"), 0644)).To(Succeed())
+
+		_, err := store.Load()
+
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,60 @@
+package fs
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// IsGzipped reports whether the file at path is expected to be gzip-compressed, based on its extension
+func IsGzipped(path string) bool {
+	return strings.HasSuffix(path, ".gz")
+}
+
+// OpenDecompressed opens the file at path, decompressing it if it's gzipped
+func OpenDecompressed(path string) (io.ReadCloser, error) {
+	file, openErr := os.Open(path)
+	if openErr != nil {
+		return nil, fmt.Errorf("error opening file %s: %w", path, openErr)
+	}
+	if !IsGzipped(path) {
+		return file, nil
+	}
+	reader, gzipErr := gzip.NewReader(file)
+	if gzipErr != nil {
+		file.Close()
+		return nil, fmt.Errorf("error decompressing file %s: %w", path, gzipErr)
+	}
+	return gzipFile{Reader: reader, file: file}, nil
+}
+
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (f gzipFile) Close() error {
+	readerErr := f.Reader.Close()
+	fileErr := f.file.Close()
+	if readerErr != nil {
+		return readerErr
+	}
+	return fileErr
+}
+
+// ReadFirstLine returns the first line of the file at path, without its line ending
+func ReadFirstLine(path string) (string, error) {
+	file, openErr := OpenDecompressed(path)
+	if openErr != nil {
+		return "", openErr
+	}
+	defer file.Close()
+	line, readErr := bufio.NewReader(file).ReadString('\n')
+	if readErr != nil && readErr != io.EOF {
+		return "", fmt.Errorf("error reading file %s: %w", path, readErr)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
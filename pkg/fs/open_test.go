@@ -0,0 +1,55 @@
+package fs_test
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/makerdao/vulcanizedb/pkg/fs"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("opening files", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "open")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("decompresses gzipped files", func() {
+		path := filepath.Join(dir, "diffs.csv.gz")
+		file, err := os.Create(path)
+		Expect(err).NotTo(HaveOccurred())
+		writer := gzip.NewWriter(file)
+		_, err = writer.Write([]byte("header\nrow\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(writer.Close()).To(Succeed())
+		Expect(file.Close()).To(Succeed())
+
+		reader, openErr := fs.OpenDecompressed(path)
+		Expect(openErr).NotTo(HaveOccurred())
+		contents, readErr := ioutil.ReadAll(reader)
+		Expect(readErr).NotTo(HaveOccurred())
+		Expect(reader.Close()).To(Succeed())
+
+		Expect(string(contents)).To(Equal("header\nrow\n"))
+	})
+
+	It("reads the first line of a file", func() {
+		path := filepath.Join(dir, "diffs.csv")
+		Expect(ioutil.WriteFile(path, []byte("header\r\nrow\n"), 0644)).To(Succeed())
+
+		line, err := fs.ReadFirstLine(path)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(line).To(Equal("header"))
+	})
+})
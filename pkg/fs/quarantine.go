@@ -0,0 +1,35 @@
+package fs
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Quarantine records lines that couldn't be processed so that they can be inspected without halting ingestion
+type Quarantine interface {
+	Quarantine(lineNumber int64, text string, reason error) error
+}
+
+type fileQuarantine struct {
+	path string
+}
+
+// NewFileQuarantine appends quarantined lines to the file at path as "<line number>\t<line>\t<reason>"
+func NewFileQuarantine(path string) fileQuarantine {
+	return fileQuarantine{path: path}
+}
+
+func (quarantine fileQuarantine) Quarantine(lineNumber int64, text string, reason error) error {
+	file, openErr := os.OpenFile(quarantine.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if openErr != nil {
+		return fmt.Errorf("error opening quarantine file %s: %w", quarantine.path, openErr)
+	}
+	entry := fmt.Sprintf("%d\t%s\t%s\n", lineNumber, text, strings.ReplaceAll(reason.Error(), "\n", " "))
+	_, writeErr := file.WriteString(entry)
+	if writeErr != nil {
+		file.Close()
+		return fmt.Errorf("error writing quarantine file %s: %w", quarantine.path, writeErr)
+	}
+	return file.Close()
+}
@@ -0,0 +1,29 @@
+package fs_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/makerdao/vulcanizedb/pkg/fs"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("quarantine", func() {
+	It("appends each line with its line number and the reason it was quarantined", func() {
+		dir, err := ioutil.TempDir("", "quarantine")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "diffs.quarantine")
+		quarantine := fs.NewFileQuarantine(path)
+
+		Expect(quarantine.Quarantine(3, "invalid", errors.New("bad row"))).To(Succeed())
+		Expect(quarantine.Quarantine(7, "a,b", errors.New("too\nshort"))).To(Succeed())
+
+		contents, readErr := ioutil.ReadFile(path)
+		Expect(readErr).NotTo(HaveOccurred())
+		Expect(string(contents)).To(Equal("3\tinvalid\tbad row\n7\ta,b\ttoo short\n"))
+	})
+})
@@ -1,15 +1,179 @@
 package fs
 
-import "github.com/hpcloud/tail"
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"time"
 
-type Tailer interface {
-	Tail() (*tail.Tail, error)
+	"github.com/sirupsen/logrus"
+)
+
+var DefaultTailPollInterval = 250 * time.Millisecond
+
+// Line is a complete line read from a tailed file, with the checkpoint just past it
+type Line struct {
+	Text       string
+	LineNumber int64
+	Checkpoint Checkpoint
+}
+
+type LineTailer interface {
+	// TailFrom sends lines starting after the checkpoint, following the file indefinitely. It only returns on error.
+	TailFrom(checkpoint Checkpoint, lines chan<- Line) error
+}
+
+// RotatingFileTailer follows a file like `tail -F`: when the file is renamed or removed and recreated (e.g. by
+// logrotate), the rest of the old file is read before switching to the new one. A truncated file is re-read from the
+// start.
+type RotatingFileTailer struct {
+	Path         string
+	PollInterval time.Duration
+}
+
+func NewRotatingFileTailer(path string) RotatingFileTailer {
+	return RotatingFileTailer{Path: path, PollInterval: DefaultTailPollInterval}
+}
+
+type tailedFile struct {
+	file       *os.File
+	info       os.FileInfo
+	reader     *bufio.Reader
+	partial    string
+	checkpoint Checkpoint
+	readOffset int64
+}
+
+func (tailer RotatingFileTailer) TailFrom(checkpoint Checkpoint, lines chan<- Line) error {
+	current, openErr := tailer.open(checkpoint)
+	if openErr != nil {
+		return openErr
+	}
+	defer func() { current.file.Close() }()
+
+	for {
+		readErr := current.readLines(lines)
+		if readErr != nil {
+			return readErr
+		}
+
+		info, statErr := os.Stat(tailer.Path)
+		if statErr != nil {
+			if os.IsNotExist(statErr) {
+				// the file has been moved and not yet recreated
+				time.Sleep(tailer.PollInterval)
+				continue
+			}
+			return fmt.Errorf("error checking tailed file %s: %w", tailer.Path, statErr)
+		}
+
+		if !os.SameFile(info, current.info) {
+			// lines may have been written to the old file between reaching its end and checking for rotation
+			drainErr := current.readLines(lines)
+			if drainErr != nil {
+				return drainErr
+			}
+			current.flushPartial(lines)
+			logrus.Infof("%s was rotated after line %d, following the new file", tailer.Path, current.checkpoint.LineNumber)
+			current.file.Close()
+			current, openErr = tailer.open(Checkpoint{})
+			if openErr != nil {
+				return openErr
+			}
+			continue
+		}
+
+		if info.Size() < current.readOffset {
+			logrus.Warnf("%s was truncated, reading from the start", tailer.Path)
+			current.file.Close()
+			current, openErr = tailer.open(Checkpoint{})
+			if openErr != nil {
+				return openErr
+			}
+			continue
+		}
+
+		if info.Size() == current.readOffset {
+			time.Sleep(tailer.PollInterval)
+		}
+	}
+}
+
+// open opens the file at the tailer's path, resuming from the checkpoint if it refers to the same file
+func (tailer RotatingFileTailer) open(checkpoint Checkpoint) (*tailedFile, error) {
+	file, openErr := os.Open(tailer.Path)
+	if openErr != nil {
+		return nil, fmt.Errorf("error opening tailed file %s: %w", tailer.Path, openErr)
+	}
+	info, statErr := file.Stat()
+	if statErr != nil {
+		file.Close()
+		return nil, fmt.Errorf("error checking tailed file %s: %w", tailer.Path, statErr)
+	}
+
+	start := Checkpoint{Inode: inode(info)}
+	if checkpoint.Inode == start.Inode && checkpoint.Offset <= info.Size() {
+		start = checkpoint
+	} else if checkpoint.Inode != 0 {
+		logrus.Warnf("%s has been replaced since the checkpoint at line %d, reading from the start",
+			tailer.Path, checkpoint.LineNumber)
+	}
+	_, seekErr := file.Seek(start.Offset, io.SeekStart)
+	if seekErr != nil {
+		file.Close()
+		return nil, fmt.Errorf("error seeking to offset %d of %s: %w", start.Offset, tailer.Path, seekErr)
+	}
+
+	return &tailedFile{
+		file:       file,
+		info:       info,
+		reader:     bufio.NewReader(file),
+		checkpoint: start,
+		readOffset: start.Offset,
+	}, nil
+}
+
+// readLines sends every complete line up to the current end of the file
+func (tailed *tailedFile) readLines(lines chan<- Line) error {
+	for {
+		chunk, readErr := tailed.reader.ReadString('\n')
+		tailed.readOffset += int64(len(chunk))
+		if readErr == io.EOF {
+			tailed.partial += chunk
+			return nil
+		}
+		if readErr != nil {
+			return fmt.Errorf("error reading tailed file %s: %w", tailed.file.Name(), readErr)
+		}
+		tailed.send(tailed.partial+chunk, lines)
+		tailed.partial = ""
+	}
+}
+
+// flushPartial sends a final line that was never terminated with a newline
+func (tailed *tailedFile) flushPartial(lines chan<- Line) {
+	if tailed.partial != "" {
+		tailed.send(tailed.partial, lines)
+		tailed.partial = ""
+	}
 }
 
-type FileTailer struct {
-	Path string
+func (tailed *tailedFile) send(rawLine string, lines chan<- Line) {
+	tailed.checkpoint.Offset += int64(len(rawLine))
+	tailed.checkpoint.LineNumber++
+	lines <- Line{
+		Text:       strings.TrimRight(rawLine, "\r\n"),
+		LineNumber: tailed.checkpoint.LineNumber,
+		Checkpoint: tailed.checkpoint,
+	}
 }
 
-func (tailer FileTailer) Tail() (*tail.Tail, error) {
-	return tail.TailFile(tailer.Path, tail.Config{Follow: true})
+func inode(info os.FileInfo) uint64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Ino)
+	}
+	return 0
 }
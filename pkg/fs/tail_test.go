@@ -0,0 +1,135 @@
+package fs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/makerdao/vulcanizedb/pkg/fs"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("rotating file tailer", func() {
+	var (
+		dir    string
+		path   string
+		tailer fs.RotatingFileTailer
+		lines  chan fs.Line
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "tail")
+		Expect(err).NotTo(HaveOccurred())
+		path = filepath.Join(dir, "diffs.csv")
+		tailer = fs.NewRotatingFileTailer(path)
+		tailer.PollInterval = 10 * time.Millisecond
+		lines = make(chan fs.Line, 10)
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	appendToFile := func(filePath, contents string) {
+		file, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = file.WriteString(contents)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(file.Close()).To(Succeed())
+	}
+
+	nextText := func() string {
+		var line fs.Line
+		Eventually(lines).Should(Receive(&line))
+		return line.Text
+	}
+
+	It("returns an error if the file doesn't exist", func() {
+		err := tailer.TailFrom(fs.Checkpoint{}, lines)
+
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("sends existing and appended lines with the checkpoint after each", func() {
+		appendToFile(path, "first\n")
+
+		go tailer.TailFrom(fs.Checkpoint{}, lines)
+		var first fs.Line
+		Eventually(lines).Should(Receive(&first))
+		appendToFile(path, "second\n")
+		var second fs.Line
+		Eventually(lines).Should(Receive(&second))
+
+		Expect(first.Text).To(Equal("first"))
+		Expect(first.LineNumber).To(Equal(int64(1)))
+		Expect(first.Checkpoint.Offset).To(Equal(int64(6)))
+		Expect(first.Checkpoint.Inode).NotTo(BeZero())
+		Expect(second.Text).To(Equal("second"))
+		Expect(second.Checkpoint).To(Equal(fs.Checkpoint{Inode: first.Checkpoint.Inode, Offset: 13, LineNumber: 2}))
+	})
+
+	It("waits for a partial line to be completed", func() {
+		appendToFile(path, "par")
+
+		go tailer.TailFrom(fs.Checkpoint{}, lines)
+		Consistently(lines).ShouldNot(Receive())
+		appendToFile(path, "tial\n")
+
+		Expect(nextText()).To(Equal("partial"))
+	})
+
+	It("resumes from a checkpoint in the same file", func() {
+		appendToFile(path, "first\n")
+		go tailer.TailFrom(fs.Checkpoint{}, lines)
+		var first fs.Line
+		Eventually(lines).Should(Receive(&first))
+		appendToFile(path, "second\n")
+
+		resumedLines := make(chan fs.Line, 10)
+		go tailer.TailFrom(first.Checkpoint, resumedLines)
+
+		var resumed fs.Line
+		Eventually(resumedLines).Should(Receive(&resumed))
+		Expect(resumed.Text).To(Equal("second"))
+		Expect(resumed.LineNumber).To(Equal(int64(2)))
+	})
+
+	It("reads from the start if the checkpoint is for a different file", func() {
+		appendToFile(path, "first\nsecond\n")
+
+		go tailer.TailFrom(fs.Checkpoint{Inode: 1, Offset: 6, LineNumber: 1}, lines)
+
+		Expect(nextText()).To(Equal("first"))
+		Expect(nextText()).To(Equal("second"))
+	})
+
+	It("finishes a rotated file before following the new one", func() {
+		appendToFile(path, "first\n")
+		go tailer.TailFrom(fs.Checkpoint{}, lines)
+		Expect(nextText()).To(Equal("first"))
+
+		rotatedPath := path + ".1"
+		Expect(os.Rename(path, rotatedPath)).To(Succeed())
+		appendToFile(rotatedPath, "second\n")
+		appendToFile(path, "third\n")
+
+		Expect(nextText()).To(Equal("second"))
+		var third fs.Line
+		Eventually(lines).Should(Receive(&third))
+		Expect(third.Text).To(Equal("third"))
+		Expect(third.LineNumber).To(Equal(int64(1)))
+	})
+
+	It("reads a truncated file from the start", func() {
+		appendToFile(path, "first line\n")
+		go tailer.TailFrom(fs.Checkpoint{}, lines)
+		Expect(nextText()).To(Equal("first line"))
+
+		Expect(ioutil.WriteFile(path, []byte("new\n"), 0644)).To(Succeed())
+
+		Expect(nextText()).To(Equal("new"))
+	})
+})
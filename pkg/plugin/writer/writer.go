@@ -60,6 +60,7 @@ func (w *writer) WritePlugin() error {
 	f.ImportAlias("github.com/makerdao/vulcanizedb/libraries/shared/transformer", "interface")
 	f.ImportAlias("github.com/makerdao/vulcanizedb/libraries/shared/factories/event", "event")
 	f.ImportAlias("github.com/makerdao/vulcanizedb/libraries/shared/factories/storage", "storage")
+	f.ImportAlias("github.com/makerdao/vulcanizedb/libraries/shared/factories/account", "account")
 	for name, transformer := range w.GenConfig.Transformers {
 		f.ImportAlias(transformer.RepositoryPath+"/"+transformer.Path, name)
 	}
@@ -87,6 +88,12 @@ func (w *writer) WritePlugin() error {
 		Index().Qual(
 			"github.com/makerdao/vulcanizedb/libraries/shared/transformer",
 			"ContractTransformerInitializer").Values(code[config.EthContract]...))) // Exports the collected event and storage transformer initializers
+	f.Func().Params(Id("e").Id("exporter")).Id("ExportAccounts").Params().Index().Qual(
+		"github.com/makerdao/vulcanizedb/libraries/shared/factories/account", "TransformerInitializer",
+	).Block(Return(
+		Index().Qual(
+			"github.com/makerdao/vulcanizedb/libraries/shared/factories/account",
+			"TransformerInitializer").Values(code[config.EthAccount]...))) // Exports the collected account transformer initializers
 
 	// Write code to destination file
 	err = f.Save(goFile)
@@ -108,6 +115,8 @@ func (w *writer) collectTransformers() (map[config.TransformerType][]Code, error
 			code[config.EthStorage] = append(code[config.EthStorage], Qual(path, "StorageTransformerInitializer"))
 		case config.EthContract:
 			code[config.EthContract] = append(code[config.EthContract], Qual(path, "ContractTransformerInitializer"))
+		case config.EthAccount:
+			code[config.EthAccount] = append(code[config.EthAccount], Qual(path, "AccountTransformerInitializer"))
 		default:
 			return nil, errors.New(fmt.Sprintf("invalid transformer type %s", transformer.Type))
 		}
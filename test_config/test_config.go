@@ -86,15 +86,22 @@ func NewTestDB(node core.Node) *postgres.DB {
 }
 
 func CleanTestDB(db *postgres.DB) {
+	db.MustExec("DELETE FROM public.account_diff")
 	db.MustExec("DELETE FROM public.addresses")
 	db.MustExec("DELETE FROM public.checked_headers")
+	db.MustExec("DELETE FROM public.checked_events")
+	db.MustExec("DELETE FROM public.contract_abi")
+	db.MustExec("DELETE FROM public.contract_abi_version")
 	// can't delete from eth_nodes since this function is called after the required eth_node is persisted
 	db.MustExec("DELETE FROM public.goose_db_version")
 	db.MustExec("DELETE FROM public.event_logs")
 	db.MustExec("DELETE FROM public.receipts")
 	db.MustExec("DELETE FROM public.transactions")
 	db.MustExec("DELETE FROM public.headers")
+	db.MustExec("DELETE FROM public.storage_backfill_range")
 	db.MustExec("DELETE FROM public.storage_diff")
+	db.MustExec("DELETE FROM public.storage_fetcher_checkpoint")
+	db.MustExec("DELETE FROM public.storage_snapshot")
 	db.MustExec("DELETE FROM public.watched_logs")
 }
 